@@ -0,0 +1,164 @@
+// migrate 在两个存储之间复制账号、API Key、模型与设置（运行时配置、功能开关），保留原 ID，
+// 用于切换存储（更换 Redis 实例、DB 或 key 前缀）而无需手动导出 / 导入 JSON。
+// 先以 -dry-run 查看迁移计划与冲突，再正式执行：
+//
+//	go run ./cmd/migrate -from redis://:pass@old:6379/0 -to redis://new:6379/0?prefix=orchids: -dry-run
+//
+// 目前唯一的存储后端为 Redis（store_mode 只支持 redis），其他后端加入后在 parseStoreURL 中接入即可。
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"orchids-api/internal/store"
+)
+
+func main() {
+	from := flag.String("from", "", "Source store, e.g. redis://:password@host:6379/0?prefix=orchids:")
+	to := flag.String("to", "", "Target store, same format as -from")
+	dryRun := flag.Bool("dry-run", false, "Print the migration plan without writing to the target")
+	overwrite := flag.Bool("overwrite", false, "Overwrite target records that differ from the source")
+	skipConflicts := flag.Bool("skip-conflicts", false, "Migrate non-conflicting records and leave conflicts untouched")
+	asJSON := flag.Bool("json", false, "Print the plan as JSON")
+	verbose := flag.Bool("v", false, "Also list records that are skipped as identical")
+	timeout := flag.Duration("timeout", 5*time.Minute, "Overall timeout")
+	flag.Parse()
+
+	if *from == "" || *to == "" {
+		fmt.Println("Error: Must provide both -from and -to")
+		fmt.Println("Usage:")
+		fmt.Println("  go run ./cmd/migrate -from redis://old:6379/0 -to redis://new:6379/1 -dry-run")
+		os.Exit(1)
+	}
+	srcOpts, err := parseStoreURL(*from)
+	if err != nil {
+		fmt.Printf("Error parsing -from: %v\n", err)
+		os.Exit(1)
+	}
+	dstOpts, err := parseStoreURL(*to)
+	if err != nil {
+		fmt.Printf("Error parsing -to: %v\n", err)
+		os.Exit(1)
+	}
+	if srcOpts == dstOpts {
+		fmt.Println("Error: -from and -to point to the same store")
+		os.Exit(1)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	src, err := store.New(srcOpts)
+	if err != nil {
+		fmt.Printf("Error opening source: %v\n", err)
+		os.Exit(1)
+	}
+	defer src.Close()
+	dst, err := store.New(dstOpts)
+	if err != nil {
+		fmt.Printf("Error opening target: %v\n", err)
+		os.Exit(1)
+	}
+	defer dst.Close()
+
+	srcSnap, err := src.Export(ctx)
+	if err != nil {
+		fmt.Printf("Error reading source: %v\n", err)
+		os.Exit(1)
+	}
+	dstSnap, err := dst.Export(ctx)
+	if err != nil {
+		fmt.Printf("Error reading target: %v\n", err)
+		os.Exit(1)
+	}
+
+	plan := store.PlanMigration(srcSnap, dstSnap, *overwrite)
+	printPlan(plan, *asJSON, *verbose)
+
+	conflicts := plan.Count(store.ActionConflict)
+	if *dryRun {
+		if conflicts > 0 {
+			os.Exit(2)
+		}
+		return
+	}
+	if conflicts > 0 && !*skipConflicts {
+		fmt.Printf("Aborted: %d conflict(s). Re-run with -overwrite to replace target records or -skip-conflicts to leave them.\n", conflicts)
+		os.Exit(2)
+	}
+
+	applied, err := dst.ApplyMigration(ctx, srcSnap, plan)
+	if err != nil {
+		fmt.Printf("Error after %d record(s) written: %v\n", applied, err)
+		os.Exit(1)
+	}
+	fmt.Printf("Migrated %d record(s).\n", applied)
+	if conflicts > 0 {
+		fmt.Printf("%d conflict(s) left untouched.\n", conflicts)
+	}
+}
+
+// parseStoreURL 解析 redis://[:password@]host[:port][/db][?prefix=...]
+func parseStoreURL(raw string) (store.Options, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return store.Options{}, err
+	}
+	if u.Scheme != "redis" {
+		return store.Options{}, fmt.Errorf("unsupported store %q (only redis:// is available)", u.Scheme)
+	}
+	opts := store.Options{StoreMode: "redis", RedisAddr: u.Host, RedisPrefix: u.Query().Get("prefix"), SkipSeed: true}
+	if u.Port() == "" {
+		opts.RedisAddr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.User != nil {
+		opts.RedisPassword, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		if opts.RedisDB, err = strconv.Atoi(db); err != nil || opts.RedisDB < 0 {
+			return store.Options{}, fmt.Errorf("invalid redis db %q", db)
+		}
+	}
+	// 与 store 中的默认前缀保持一致，便于判断两端是否相同
+	if opts.RedisPrefix == "" {
+		opts.RedisPrefix = "orchids:"
+	}
+	if !strings.HasSuffix(opts.RedisPrefix, ":") {
+		opts.RedisPrefix += ":"
+	}
+	return opts, nil
+}
+
+func printPlan(plan store.MigrationPlan, asJSON, verbose bool) {
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(plan)
+		return
+	}
+	for _, item := range plan.Items {
+		if item.Action == store.ActionSkip && !verbose {
+			continue
+		}
+		label := item.ID
+		if item.Name != "" {
+			label += " (" + item.Name + ")"
+		}
+		line := fmt.Sprintf("%-9s %-8s %s", item.Action, item.Kind, label)
+		if item.Reason != "" && item.Action != store.ActionCreate {
+			line += ": " + item.Reason
+		}
+		fmt.Println(line)
+	}
+	fmt.Printf("Plan: %d to create, %d to overwrite, %d identical, %d conflict(s)\n",
+		plan.Count(store.ActionCreate), plan.Count(store.ActionOverwrite), plan.Count(store.ActionSkip), plan.Count(store.ActionConflict))
+}
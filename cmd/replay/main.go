@@ -0,0 +1,217 @@
+// replay 读取调试日志目录（debug-logs/ 或从 /api/debug-logs 下载并解压的 zip）中记录的
+// 客户端请求，按原始时间间隔（可用 -speed 加速）回放到目标实例，用于升级前在预发环境验证。
+//
+//	go run ./cmd/replay -dir debug-logs -target http://staging:3002 -key sk-xxx -speed 10
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 与 internal/debug 中的文件名保持一致
+const (
+	metaFile    = "0_meta.json"
+	requestFile = "1_claude_request.json"
+)
+
+// defaultPath 用于旧版本未记录请求路径的日志
+const defaultPath = "/orchids/v1/messages"
+
+type entryMeta struct {
+	TraceID   string    `json:"trace_id"`
+	Path      string    `json:"path"`
+	StartedAt time.Time `json:"started_at"`
+}
+
+type recorded struct {
+	dir  string
+	meta entryMeta
+	body []byte
+}
+
+type result struct {
+	rec      recorded
+	status   int
+	duration time.Duration
+	err      error
+}
+
+func main() {
+	dir := flag.String("dir", "debug-logs", "Directory containing debug log entries")
+	target := flag.String("target", "http://localhost:3002", "Target base URL")
+	key := flag.String("key", "", "API key sent as x-api-key")
+	path := flag.String("path", "", "Override request path (default: recorded path)")
+	speed := flag.Float64("speed", 1, "Pacing multiplier: 1 = original timing, 10 = 10x faster, 0 = no delay")
+	concurrency := flag.Int("concurrency", 8, "Maximum in-flight requests")
+	limit := flag.Int("limit", 0, "Replay at most N requests (0 = all)")
+	timeout := flag.Duration("timeout", 5*time.Minute, "Per-request timeout")
+	flag.Parse()
+
+	if *speed < 0 || *concurrency < 1 {
+		fmt.Println("Error: -speed must be >= 0 and -concurrency >= 1")
+		os.Exit(1)
+	}
+
+	records, err := loadRecords(*dir)
+	if err != nil {
+		fmt.Printf("Error reading %s: %v\n", *dir, err)
+		os.Exit(1)
+	}
+	if *limit > 0 && len(records) > *limit {
+		records = records[:*limit]
+	}
+	if len(records) == 0 {
+		fmt.Printf("No recorded requests found in %s\n", *dir)
+		os.Exit(1)
+	}
+	fmt.Printf("Replaying %d requests against %s (speed %gx)\n", len(records), *target, *speed)
+
+	client := &http.Client{Timeout: *timeout}
+	base := strings.TrimRight(*target, "/")
+	sem := make(chan struct{}, *concurrency)
+	results := make(chan result, len(records))
+	var wg sync.WaitGroup
+
+	start := time.Now()
+	first := records[0].meta.StartedAt
+	for _, rec := range records {
+		if *speed > 0 && !first.IsZero() && !rec.meta.StartedAt.IsZero() {
+			offset := time.Duration(float64(rec.meta.StartedAt.Sub(first)) / *speed)
+			if wait := offset - time.Since(start); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+		reqPath := *path
+		if reqPath == "" {
+			reqPath = rec.meta.Path
+		}
+		if reqPath == "" {
+			reqPath = defaultPath
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+		go func(rec recorded, url string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results <- send(client, url, *key, rec)
+		}(rec, base+reqPath)
+	}
+	wg.Wait()
+	close(results)
+
+	summarize(results, time.Since(start))
+}
+
+// loadRecords 递归查找包含请求体的日志目录，按原始开始时间排序
+func loadRecords(root string) ([]recorded, error) {
+	var records []recorded
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || d.Name() != requestFile {
+			return nil
+		}
+		body, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		rec := recorded{dir: filepath.Dir(p), body: body}
+		if data, err := os.ReadFile(filepath.Join(rec.dir, metaFile)); err == nil {
+			json.Unmarshal(data, &rec.meta)
+		}
+		if rec.meta.StartedAt.IsZero() {
+			if info, err := d.Info(); err == nil {
+				rec.meta.StartedAt = info.ModTime()
+			}
+		}
+		records = append(records, rec)
+		return nil
+	})
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].meta.StartedAt.Before(records[j].meta.StartedAt)
+	})
+	return records, err
+}
+
+// send 发送一个请求并读完响应（流式响应会一直读到结束），返回状态码与总耗时
+func send(client *http.Client, url, key string, rec recorded) result {
+	res := result{rec: rec}
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(rec.body))
+	if err != nil {
+		res.err = err
+		return res
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if key != "" {
+		req.Header.Set("x-api-key", key)
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		res.err = err
+		res.duration = time.Since(start)
+		return res
+	}
+	defer resp.Body.Close()
+	_, res.err = io.Copy(io.Discard, resp.Body)
+	res.status = resp.StatusCode
+	res.duration = time.Since(start)
+	return res
+}
+
+func summarize(results <-chan result, elapsed time.Duration) {
+	statuses := map[int]int{}
+	var durations []time.Duration
+	failed, serverErrors := 0, 0
+	for res := range results {
+		name := filepath.Base(res.rec.dir)
+		if res.err != nil {
+			failed++
+			fmt.Printf("FAIL %s %s: %v\n", name, res.rec.meta.Path, res.err)
+			continue
+		}
+		statuses[res.status]++
+		durations = append(durations, res.duration)
+		if res.status >= 500 {
+			serverErrors++
+		}
+		if res.status >= 400 {
+			fmt.Printf("%d  %s %s (%s)\n", res.status, name, res.rec.meta.Path, res.duration.Round(time.Millisecond))
+		}
+	}
+
+	fmt.Printf("\nDone in %s: %d responses, %d transport errors\n", elapsed.Round(time.Millisecond), len(durations), failed)
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	for _, code := range codes {
+		fmt.Printf("  status %d: %d\n", code, statuses[code])
+	}
+	if len(durations) > 0 {
+		sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+		pct := func(p float64) time.Duration { return durations[int(p*float64(len(durations)-1))] }
+		fmt.Printf("  latency p50=%s p95=%s max=%s\n",
+			pct(0.5).Round(time.Millisecond), pct(0.95).Round(time.Millisecond), durations[len(durations)-1].Round(time.Millisecond))
+	}
+	// 有传输错误或 5xx 时以非零状态退出，便于在 CI 中使用
+	if failed > 0 || serverErrors > 0 {
+		os.Exit(1)
+	}
+}
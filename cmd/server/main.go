@@ -6,6 +6,7 @@ import (
 	"flag"
 	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -14,20 +15,30 @@ import (
 	"syscall"
 	"time"
 
+	"orchids-api/internal/accountstats"
 	"orchids-api/internal/api"
 	"orchids-api/internal/auth"
 	"orchids-api/internal/clerk"
 	"orchids-api/internal/config"
 	"orchids-api/internal/debug"
+	"orchids-api/internal/events"
+	"orchids-api/internal/flags"
 	"orchids-api/internal/handler"
+	"orchids-api/internal/health"
+	"orchids-api/internal/listener"
 	"orchids-api/internal/loadbalancer"
+	"orchids-api/internal/logsink"
+	"orchids-api/internal/metrics"
 	"orchids-api/internal/middleware"
-	"orchids-api/internal/orchids"
+	"orchids-api/internal/modelsync"
 	"orchids-api/internal/prompt"
+	"orchids-api/internal/report"
 	"orchids-api/internal/store"
 	"orchids-api/internal/summarycache"
 	"orchids-api/internal/template"
 	"orchids-api/internal/tokencache"
+	"orchids-api/internal/toolspill"
+	"orchids-api/internal/version"
 	"orchids-api/internal/warp"
 	"orchids-api/web"
 
@@ -50,17 +61,31 @@ func main() {
 		level = slog.LevelDebug
 	}
 
-	logger := slog.New(slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level}))
+	logSinks, sinkErr := logsink.Open(os.Stdout, cfg)
+	logger := slog.New(logSinks.Handler(&slog.HandlerOptions{Level: level}))
 	slog.SetDefault(logger)
+	if sinkErr != nil {
+		slog.Error("Failed to open log sink", "error", sinkErr)
+	}
+	if names := logSinks.Names(); len(names) > 0 {
+		slog.Info("Log sinks enabled", "sinks", names)
+	}
+	buildInfo := version.Get()
+	slog.Info("Orchids API starting", "version", buildInfo.Version, "commit", buildInfo.Commit, "build_date", buildInfo.BuildDate, "go_version", buildInfo.GoVersion, "platform", buildInfo.Platform)
 
-	// 启动时清空所有调试日志
-	if cfg.DebugEnabled {
-		if err := debug.CleanupAllLogs(); err != nil {
+	// 按保留策略清理调试日志（启动时及每 10 分钟）
+	debugRetention := debug.Retention{
+		MaxAge:        time.Duration(cfg.DebugLogMaxAgeHours) * time.Hour,
+		MaxTotalBytes: int64(cfg.DebugLogMaxSizeMB) << 20,
+	}
+	pruneDebugLogs := func() {
+		if removed, err := debug.Prune(debugRetention); err != nil {
 			slog.Warn("清理调试日志失败", "error", err)
-		} else {
-			slog.Info("已清空调试日志目录")
+		} else if removed > 0 {
+			slog.Info("已清理过期调试日志", "removed", removed)
 		}
 	}
+	pruneDebugLogs()
 
 	s, err := store.New(store.Options{
 		StoreMode:     cfg.StoreMode,
@@ -79,10 +104,15 @@ func main() {
 
 	// 从 Redis 加载已保存的配置（如果存在）
 	if savedConfig, err := s.GetSetting(context.Background(), "config"); err == nil && savedConfig != "" {
-		if err := json.Unmarshal([]byte(savedConfig), cfg); err != nil {
+		if from, err := config.UnmarshalMigrated([]byte(savedConfig), cfg); err != nil {
 			slog.Warn("Failed to load config from Redis, using file config", "error", err)
 		} else {
-			slog.Info("Config loaded from Redis")
+			slog.Info("Config loaded from Redis", "from_schema", from)
+			// Redis 中保存的是密钥引用而非明文（见 Config.MarshalJSON），重新读取挂载的密钥（*_file / *_env 与引用值）
+			if err := cfg.ResolveSecrets(context.Background()); err != nil {
+				slog.Error("Failed to resolve config secrets", "error", err)
+				os.Exit(1)
+			}
 			// 重新应用默认值，防止 Redis 中缺少新增字段导致零值覆盖
 			config.ApplyDefaults(cfg)
 			// Enforce lower refresh interval if it's too high (legacy default was 30)
@@ -99,6 +129,7 @@ func main() {
 	}
 
 	lb := loadbalancer.NewWithCacheTTL(s, time.Duration(cfg.LoadBalancerCacheTTL)*time.Second)
+	lb.SetQueueTimeout(func() time.Duration { return time.Duration(cfg.AccountQueueTimeout) * time.Second })
 	apiHandler := api.New(s, cfg.AdminUser, cfg.AdminPass, cfg, resolvedCfgPath)
 	h := handler.NewWithLoadBalancer(cfg, lb)
 
@@ -106,6 +137,50 @@ func main() {
 	h.SetTokenCache(tokenCache)
 	apiHandler.SetTokenCache(tokenCache)
 
+	toolSpill := toolspill.New(cfg.ToolResultSpillDir)
+	h.SetToolSpill(toolSpill)
+	apiHandler.SetToolSpill(toolSpill)
+	apiHandler.SetBasePath(cfg.BasePath)
+
+	modelSyncer := modelsync.New(s, cfg)
+	apiHandler.SetModelSyncer(modelSyncer)
+	accountTracker := accountstats.New(cfg.AnomalyWindowHours)
+	h.SetAccountStats(accountTracker)
+	apiHandler.SetAccountStats(accountTracker)
+	apiHandler.SetLoadBalancer(lb)
+	apiHandler.SetLogSinks(logSinks)
+	flagManager := flags.New(s)
+	if err := flagManager.Load(context.Background()); err != nil {
+		slog.Warn("Failed to load feature flags, using defaults", "error", err)
+	}
+	h.SetFlags(flagManager)
+	eventBus := events.New(cfg)
+	if eventBus != nil {
+		slog.Info("Event bus enabled", "sinks", len(cfg.EventSinks), "usage_webhook", cfg.UsageWebhookURL != "", "usage_redis_stream", cfg.UsageRedisStream)
+	} else {
+		eventBus = &events.Bus{}
+	}
+	// 报表器始终订阅，未配置 report_schedule 时也可通过 /api/reports 预览
+	reporter := report.New(s, accountTracker, cfg)
+	eventBus.Subscribe(reporter, events.RequestCompleted, events.AccountStatusChanged)
+	apiHandler.SetReporter(reporter)
+	h.SetEventBus(eventBus)
+	lb.SetEventBus(eventBus)
+	modelSyncer.SetEventBus(eventBus)
+	apiHandler.SetEventBus(eventBus)
+	apiHandler.SetFlags(flagManager)
+	canary := middleware.NewCanarySplitter(func() (string, float64) {
+		return cfg.CanaryURL, cfg.CanaryPercent
+	})
+	apiHandler.SetCanary(canary)
+	signatures := middleware.NewSignatureVerifier(func() middleware.SigningSettings {
+		return middleware.SigningSettings{
+			Secrets:      cfg.RequestSigningSecrets,
+			MaxSkew:      time.Duration(cfg.RequestSigningMaxSkewSeconds) * time.Second,
+			MaxBodyBytes: cfg.MaxRequestBytes,
+		}
+	})
+
 	cacheMode := strings.ToLower(cfg.SummaryCacheMode)
 	if cacheMode != "off" {
 		stats := summarycache.NewStats()
@@ -143,13 +218,34 @@ func main() {
 	}
 	slog.Info("Template renderer initialized")
 
+	if cfg.ChaosEnabled {
+		slog.Warn("Chaos testing layer enabled: upstream messages will be randomly delayed, dropped, truncated or corrupted",
+			"seed", cfg.ChaosSeed,
+			"delay_rate", cfg.ChaosDelayRate,
+			"drop_rate", cfg.ChaosDropRate,
+			"truncate_rate", cfg.ChaosTruncateRate,
+			"corrupt_rate", cfg.ChaosCorruptRate,
+		)
+	}
+
 	mux := http.NewServeMux()
 
 	limiter := middleware.NewConcurrencyLimiter(cfg.ConcurrencyLimit, time.Duration(cfg.ConcurrencyTimeout)*time.Second, cfg.AdaptiveTimeout)
+	limiter.SetMaxQueue(func() int { return cfg.MaxQueueLength })
+	limiter.SetFairShare(func() bool { return cfg.FairScheduling }, func(key string) int { return cfg.FairShareWeights[key] })
 	mux.HandleFunc("/orchids/v1/messages", limiter.Limit(h.HandleMessages))
 	mux.HandleFunc("/orchids/v1/messages/count_tokens", limiter.Limit(h.HandleCountTokens))
 	mux.HandleFunc("/warp/v1/messages", limiter.Limit(h.HandleMessages))
 	mux.HandleFunc("/warp/v1/messages/count_tokens", limiter.Limit(h.HandleCountTokens))
+	// WebSocket 传输：每个文本帧一个请求，复用同一限制器与处理流程
+	mux.HandleFunc("/orchids/v1/messages/ws", h.MessagesWebSocket(limiter.Limit(h.HandleMessages)))
+	mux.HandleFunc("/warp/v1/messages/ws", h.MessagesWebSocket(limiter.Limit(h.HandleMessages)))
+	// 长轮询传输：POST 创建请求，GET /v1/streams/{token}/events 分批取回事件
+	mux.HandleFunc("/orchids/v1/streams", h.LongPollStart(limiter.Limit(h.HandleMessages)))
+	mux.HandleFunc("/warp/v1/streams", h.LongPollStart(limiter.Limit(h.HandleMessages)))
+	mux.HandleFunc("/v1/streams/", h.HandleStreamEvents)
+	mux.HandleFunc("/orchids/v1/streams/", h.HandleStreamEvents)
+	mux.HandleFunc("/warp/v1/streams/", h.HandleStreamEvents)
 	// Public Model Routes (Orchids & Warp separate channels)
 	mux.HandleFunc("/orchids/v1/models", h.HandleModels)
 	mux.HandleFunc("/orchids/v1/models/", h.HandleModelByID)
@@ -159,33 +255,59 @@ func main() {
 	mux.HandleFunc("/v1/models", h.HandleModels)
 	mux.HandleFunc("/v1/models/", h.HandleModelByID)
 
+	// 取消进行中的请求（按 trace id，需与发起请求的 API Key 一致）；不经过并发限制器
+	mux.HandleFunc("/v1/requests/", h.HandleCancelRequest)
+	mux.HandleFunc("/orchids/v1/requests/", h.HandleCancelRequest)
+	mux.HandleFunc("/warp/v1/requests/", h.HandleCancelRequest)
+
 	// OpenAI Compatibility - Channel Specific
 	mux.HandleFunc("/orchids/v1/chat/completions", limiter.Limit(h.HandleMessages))
 	mux.HandleFunc("/warp/v1/chat/completions", limiter.Limit(h.HandleMessages))
 
 	// Public routes
 	mux.HandleFunc("/api/login", apiHandler.HandleLogin)
-	mux.HandleFunc("/api/logout", apiHandler.HandleLogout)
+	mux.HandleFunc("/api/logout", middleware.CSRFProtect(apiHandler.HandleLogout))
 
 	// Admin API with session auth
 	mux.HandleFunc("/api/accounts", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleAccounts))
+	mux.HandleFunc("/api/accounts/batch", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleAccountsBatch))
 	mux.HandleFunc("/api/accounts/", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleAccountByID))
 	mux.HandleFunc("/api/keys", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleKeys))
 	mux.HandleFunc("/api/keys/", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleKeyByID))
 	mux.HandleFunc("/api/models", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleModels))
+	mux.HandleFunc("/api/models/sync", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleModelSync))
 	mux.HandleFunc("/api/models/", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleModelByID))
+	mux.HandleFunc("/api/channel-models", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleChannelModels))
 	mux.HandleFunc("/api/export", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleExport))
 	mux.HandleFunc("/api/import", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleImport))
 	mux.HandleFunc("/api/config", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleConfig))
+	mux.HandleFunc("/api/config/validate", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleConfigValidate))
+	mux.HandleFunc("/api/config/history", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleConfigHistory))
+	mux.HandleFunc("/api/config/rollback/", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleConfigRollback))
 	mux.HandleFunc("/api/config/cache/stats", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleCacheStats))
 	mux.HandleFunc("/api/config/cache/clear", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleCacheClear))
+	mux.HandleFunc("/api/analytics/accounts", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleAccountAnalytics))
+	mux.HandleFunc("/api/debug-logs", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleDebugLogs))
+	mux.HandleFunc("/api/debug-logs/", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleDebugLogByID))
+	mux.HandleFunc("/api/tool-results/", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleToolResultByID))
+	mux.HandleFunc("/api/support-bundle", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleSupportBundle))
+	mux.HandleFunc("/api/profile", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleProfile))
+	mux.HandleFunc("/api/canary", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleCanary))
+	mux.HandleFunc("/api/reports", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleReports))
+	mux.HandleFunc("/api/flags", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleFlags))
+	mux.HandleFunc("/api/flags/", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleFlagByName))
+	mux.HandleFunc("/api/conversations/tokens", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, h.HandleConversationTokens))
+	mux.HandleFunc("/api/conversations/artifacts", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, h.HandleConversationArtifacts))
+	mux.HandleFunc("/api/token-cache", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleTokenCache))
+	mux.HandleFunc("/api/token-cache/", middleware.SessionAuth(cfg.AdminPass, cfg.AdminToken, apiHandler.HandleTokenCacheByAccount))
 
 	// Protected Web UI
 	staticHandler := http.StripPrefix(cfg.AdminPath, web.StaticHandler())
+	loginHandler := web.LoginHandler(cfg.BasePath)
 	mux.HandleFunc(cfg.AdminPath+"/", func(w http.ResponseWriter, r *http.Request) {
 		// Serve login page (static)
 		if r.URL.Path == cfg.AdminPath+"/login.html" {
-			staticHandler.ServeHTTP(w, r)
+			loginHandler.ServeHTTP(w, r)
 			return
 		}
 
@@ -229,6 +351,17 @@ func main() {
 		w.Header().Set("Content-Type", "application/json")
 		w.Write([]byte(`{"status":"ok"}`))
 	})
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(buildInfo)
+	})
+	healthChecker := health.New(health.DefaultTimeout,
+		health.PingCheck("redis", s.Ping),
+		health.AccountsCheck(s.ListAccounts),
+		health.LoadedCheck("templates", tmplRenderer.Loaded),
+	)
+	mux.HandleFunc("/health/ready", healthChecker.ServeReady)
+	mux.HandleFunc("/health/live", healthChecker.ServeLive)
 
 	// Prometheus metrics endpoint
 	mux.Handle("/metrics", promhttp.Handler())
@@ -239,12 +372,60 @@ func main() {
 		slog.Info("pprof enabled", "path", "/debug/pprof/")
 	}
 
-	server := &http.Server{
-		Addr: ":" + cfg.Port,
-		Handler: middleware.Chain(
+	// newHandler 为一个监听构建中间件链，allow 限制该监听提供的路由（nil 为全部）
+	newHandler := func(allow func(path string) bool) http.Handler {
+		routes := []func(http.Handler) http.Handler{
+			// 最外层：去掉 base_path 前缀，后续中间件与路由只看到 /v1/...、/api/... 等站内路径
+			middleware.BasePath(cfg.BasePath),
+		}
+		if allow != nil {
+			routes = append(routes, middleware.RestrictRoutes(allow))
+		}
+		return middleware.Chain(append(routes,
+			// 每个请求读取当前配置，后续中间件与 handler 通过 middleware.ClientIP 读取客户端 IP
+			middleware.RealIP(func() []string { return cfg.TrustedProxies }),
 			middleware.TraceMiddleware,
-			middleware.LoggingMiddleware,
-		)(mux),
+			middleware.AccessLog(middleware.AccessLogOptions{
+				SuccessSampleRate: cfg.AccessLogSampleRate,
+				RouteLevels:       cfg.AccessLogRouteLevels,
+			}),
+			middleware.AdminMetrics(func(r *http.Request) string {
+				_, pattern := mux.Handler(r)
+				return pattern
+			}),
+			middleware.Recover,
+			signatures.Middleware,
+			// 放在 CORS 之前：转发到 canary 的请求由 canary 自己返回 CORS 头
+			canary.Middleware,
+			// 每个请求读取当前配置，/api/config 修改后立即生效
+			middleware.CORS(func(group string) (middleware.CORSPolicy, bool) {
+				policy, ok := cfg.CORS[group]
+				return middleware.CORSPolicy(policy), ok
+			}),
+			// 最内层：自定义渠道前缀改写为 /{channel}/...，外层中间件看到的仍是客户端请求的路径
+			middleware.ChannelPrefixes(func() map[string]string { return cfg.ChannelPrefixes }, config.PrefixChannels...),
+		)...)(mux)
+	}
+
+	// 配置 admin_listen_addr 后管理路由只在该地址提供，公开监听只提供 API 路由
+	adminPath := cfg.AdminPath
+	var publicRoutes func(string) bool
+	var adminServer *http.Server
+	if cfg.AdminListenAddr != "" {
+		publicRoutes = func(path string) bool { return !middleware.IsAdminRoute(path, adminPath) }
+		adminServer = &http.Server{
+			Addr: cfg.AdminListenAddr,
+			Handler: newHandler(func(path string) bool {
+				return middleware.IsAdminRoute(path, adminPath) || middleware.IsProbeRoute(path)
+			}),
+			ReadHeaderTimeout: 10 * time.Second,
+			ReadTimeout:       30 * time.Second,
+			IdleTimeout:       60 * time.Second,
+		}
+	}
+	server := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           newHandler(publicRoutes),
 		ReadHeaderTimeout: 10 * time.Second,
 		ReadTimeout:       30 * time.Second,
 		IdleTimeout:       60 * time.Second,
@@ -253,6 +434,8 @@ func main() {
 	// Create context for background goroutines
 	ctx, cancelBackground := context.WithCancel(context.Background())
 	defer cancelBackground()
+	flagManager.Watch(ctx)
+	s.WatchModelChanges(ctx)
 
 	if cfg.AutoRefreshToken {
 		interval := time.Duration(cfg.TokenRefreshInterval) * time.Minute
@@ -260,6 +443,7 @@ func main() {
 			interval = 30 * time.Minute
 		}
 		slog.Info("Auto refresh token enabled", "interval", interval.String())
+		refreshLockTTL := interval * 9 / 10
 
 		refreshAccounts := func() {
 			accounts, err := s.GetEnabledAccounts(context.Background())
@@ -267,7 +451,17 @@ func main() {
 				slog.Error("Auto refresh token: list accounts failed", "error", err)
 				return
 			}
+			startedAt := time.Now()
+			var refreshed, failed int
 			for _, acc := range accounts {
+				// 同一账号每个刷新周期只由一个副本刷新；锁到期自动释放
+				locked, err := s.TryLock(ctx, fmt.Sprintf("token_refresh:%d", acc.ID), refreshLockTTL)
+				if err != nil {
+					slog.Warn("Auto refresh token: acquire lock failed, refreshing anyway", "account", acc.Name, "error", err)
+				} else if !locked {
+					slog.Debug("Auto refresh token: refreshed by another replica", "account", acc.Name)
+					continue
+				}
 				if strings.EqualFold(acc.AccountType, "warp") {
 					if !acc.QuotaResetAt.IsZero() && time.Now().Before(acc.QuotaResetAt) {
 						continue
@@ -291,12 +485,16 @@ func main() {
 							}
 						}
 						slog.Warn("Auto refresh token failed", "account", acc.Name, "type", "warp", "http_status", httpStatus, "error", err)
+						accountTracker.RecordRefresh(acc.ID, err)
+						metrics.AdminTasks.WithLabelValues("token_refresh", "failure").Inc()
+						failed++
 						continue
 					}
 					if jwt != "" {
 						acc.Token = jwt
 					}
 					warpClient.SyncAccountState()
+					accountTracker.RecordRefresh(acc.ID, nil)
 
 					// Sync Warp usage quota via GraphQL
 					limitCtx, limitCancel := context.WithTimeout(context.Background(), 15*time.Second)
@@ -329,6 +527,8 @@ func main() {
 					if err := s.UpdateAccount(context.Background(), acc); err != nil {
 						slog.Warn("Auto refresh token: update account failed", "account", acc.Name, "type", "warp", "error", err)
 					}
+					metrics.AdminTasks.WithLabelValues("token_refresh", "success").Inc()
+					refreshed++
 					continue
 				}
 				if strings.TrimSpace(acc.ClientCookie) == "" {
@@ -346,6 +546,9 @@ func main() {
 						lb.MarkAccountStatus(context.Background(), acc, "401")
 					}
 					slog.Warn("Auto refresh token failed", "account", acc.Name, "error", err)
+					accountTracker.RecordRefresh(acc.ID, err)
+					metrics.AdminTasks.WithLabelValues("token_refresh", "failure").Inc()
+					failed++
 					continue
 				}
 				if info.SessionID != "" {
@@ -372,8 +575,26 @@ func main() {
 
 				if err := s.UpdateAccount(context.Background(), acc); err != nil {
 					slog.Warn("Auto refresh token: update account failed", "account", acc.Name, "error", err)
+					accountTracker.RecordRefresh(acc.ID, err)
+					metrics.AdminTasks.WithLabelValues("token_refresh", "failure").Inc()
+					failed++
 					continue
 				}
+				accountTracker.RecordRefresh(acc.ID, nil)
+				metrics.AdminTasks.WithLabelValues("token_refresh", "success").Inc()
+				refreshed++
+			}
+			if refreshed+failed > 0 {
+				result := "success"
+				if failed > 0 {
+					result = "failure"
+				}
+				eventBus.Publish(events.TaskFinished, events.Task{
+					Task:       "token_refresh",
+					Result:     result,
+					Detail:     fmt.Sprintf("%d refreshed, %d failed", refreshed, failed),
+					DurationMs: time.Since(startedAt).Milliseconds(),
+				})
 			}
 		}
 
@@ -416,177 +637,69 @@ func main() {
 	}()
 
 	// 上游模型同步
-	go func() {
-		defer func() {
-			if err := recover(); err != nil {
-				slog.Error("Panic in upstream model sync loop", "error", err)
-			}
-		}()
-
-		syncModels := func() {
-			accounts, err := s.GetEnabledAccounts(context.Background())
-			if err != nil {
-				slog.Warn("上游模型同步: 获取账号失败", "error", err)
-				return
-			}
-			// 找到第一个可用的 Orchids 账号来获取上游模型
-			var client *orchids.Client
-			hasOrchidsAccount := false
-			for _, acc := range accounts {
-				if strings.EqualFold(acc.AccountType, "warp") {
-					continue
-				}
-				hasOrchidsAccount = true
-				client = orchids.NewFromAccount(acc, cfg)
-				break
-			}
-			if client == nil {
-				if !hasOrchidsAccount {
-					slog.Debug("上游模型同步: 无 Orchids 账号，跳过")
-					return
-				}
-				// 兜底：存在 Orchids 账号但构造失败时仍尝试默认配置
-				client = orchids.New(cfg)
-			}
-
-			fetchCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			defer cancel()
-
-			upstreamModels, err := client.FetchUpstreamModels(fetchCtx)
-			if err != nil {
-				slog.Warn("上游模型同步: 获取失败", "error", err)
-				return
-			}
-			if len(upstreamModels) == 0 {
-				slog.Debug("上游模型同步: 无模型返回")
-				return
-			}
-
-			added := 0
-			for _, um := range upstreamModels {
-				modelID := strings.TrimSpace(um.ID)
-				if modelID == "" {
-					continue
-				}
-				// 检查本地是否已存在
-				if _, err := s.GetModelByModelID(context.Background(), modelID); err == nil {
-					continue
-				}
-				// 新模型，添加到 store
-				channel := "Orchids"
-				if strings.TrimSpace(um.OwnedBy) != "" {
-					channel = um.OwnedBy
-				}
-				newModel := &store.Model{
-					Channel: channel,
-					ModelID: modelID,
-					Name:    modelID,
-					Status:  store.ModelStatusAvailable,
-				}
-				if err := s.CreateModel(context.Background(), newModel); err != nil {
-					slog.Warn("上游模型同步: 创建模型失败", "model_id", modelID, "error", err)
-					continue
+	modelSyncInterval := time.Duration(cfg.ModelSyncInterval) * time.Minute
+	if modelSyncInterval > 0 {
+		go func() {
+			defer func() {
+				if err := recover(); err != nil {
+					slog.Error("Panic in upstream model sync loop", "error", err)
 				}
-				added++
-				slog.Info("上游模型同步: 新增模型", "model_id", modelID, "channel", channel)
-			}
-			if added > 0 {
-				slog.Info("上游模型同步完成", "total_upstream", len(upstreamModels), "added", added)
-			} else {
-				slog.Debug("上游模型同步完成，无新增", "total_upstream", len(upstreamModels))
-			}
-		}
+			}()
 
-		syncWarpModels := func() {
-			accounts, err := s.GetEnabledAccounts(context.Background())
-			if err != nil {
-				slog.Warn("Warp 模型同步: 获取账号失败", "error", err)
-				return
-			}
-			// 找到第一个可用的 Warp 账号
-			var warpAcc *store.Account
-			for _, acc := range accounts {
-				if strings.EqualFold(acc.AccountType, "warp") && strings.TrimSpace(acc.Token) != "" {
-					warpAcc = acc
-					break
+			// 多副本部署时每个周期只由抢到锁的副本同步；锁不主动释放，到期即进入下一轮选举
+			syncIfLeader := func() {
+				leader, err := s.TryLock(ctx, "model_sync", modelSyncInterval*9/10)
+				if err != nil {
+					slog.Warn("上游模型同步: 获取选举锁失败，继续本地同步", "error", err)
+				} else if !leader {
+					slog.Debug("上游模型同步: 其他副本持有选举锁，跳过本轮")
+					return
 				}
-			}
-			if warpAcc == nil {
-				slog.Debug("Warp 模型同步: 无可用 Warp 账号")
-				return
+				modelSyncer.Run(ctx)
 			}
 
-			warpClient := warp.NewFromAccount(warpAcc, cfg)
-			fetchCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
-			defer cancel()
-
-			choices, err := warpClient.GetFeatureModelChoices(fetchCtx)
-			if err != nil {
-				slog.Warn("Warp 模型同步: 获取失败", "error", err)
+			// 启动时延迟 10 秒执行，等待 token 刷新完成
+			select {
+			case <-ctx.Done():
 				return
+			case <-time.After(10 * time.Second):
 			}
+			syncIfLeader()
 
-			// Collect all unique models from all categories
-			seen := make(map[string]bool)
-			added := 0
-			categories := []*warp.FeatureModelCategory{choices.AgentMode, choices.Planning, choices.Coding, choices.CliAgent}
-			for _, cat := range categories {
-				if cat == nil {
-					continue
-				}
-				for _, choice := range cat.Choices {
-					modelID := strings.TrimSpace(choice.ID)
-					if modelID == "" || seen[modelID] {
-						continue
-					}
-					seen[modelID] = true
-					// Check if model already exists
-					if _, err := s.GetModelByModelID(context.Background(), modelID); err == nil {
-						continue
-					}
-					displayName := choice.DisplayName
-					if displayName == "" {
-						displayName = modelID
-					}
-					newModel := &store.Model{
-						Channel: "Warp",
-						ModelID: modelID,
-						Name:    displayName + " (Warp)",
-						Status:  store.ModelStatusAvailable,
-					}
-					if err := s.CreateModel(context.Background(), newModel); err != nil {
-						slog.Warn("Warp 模型同步: 创建模型失败", "model_id", modelID, "error", err)
-						continue
-					}
-					added++
-					slog.Info("Warp 模型同步: 新增模型", "model_id", modelID, "name", displayName)
+			ticker := time.NewTicker(modelSyncInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					syncIfLeader()
 				}
 			}
-			if added > 0 {
-				slog.Info("Warp 模型同步完成", "added", added)
-			} else {
-				slog.Debug("Warp 模型同步完成，无新增")
-			}
-		}
+		}()
+		slog.Info("Upstream model sync enabled", "interval", modelSyncInterval.String(), "sources", modelSyncer.Sources())
+	}
 
-		// 启动时延迟 10 秒执行，等待 token 刷新完成
-		select {
-		case <-ctx.Done():
-			return
-		case <-time.After(10 * time.Second):
-		}
-		syncModels()
-		syncWarpModels()
+	if reporter.Enabled() {
+		go reporter.Run(ctx)
+		slog.Info("Scheduled reports enabled", "schedule", cfg.ReportSchedule, "hour", cfg.ReportHour, "recipients", len(cfg.ReportRecipients))
+	}
 
-		ticker := time.NewTicker(30 * time.Minute)
+	// 请求级捕获（X-Debug-Capture）不依赖 debug_enabled，清理任务始终运行
+	go func() {
+		ticker := time.NewTicker(10 * time.Minute)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-ctx.Done():
 				return
 			case <-ticker.C:
-				syncModels()
-				syncWarpModels()
+				pruneDebugLogs()
+				if removed, err := toolSpill.Prune(time.Duration(cfg.ToolResultSpillMaxAgeHours) * time.Hour); err != nil {
+					slog.Warn("清理 tool_result 原文失败", "error", err)
+				} else if removed > 0 {
+					slog.Info("已清理过期 tool_result 原文", "removed", removed)
+				}
 			}
 		}
 	}()
@@ -602,6 +715,9 @@ func main() {
 		// Stop background goroutines first
 		cancelBackground()
 
+		// 新的消息请求立即拒绝，进行中的流在宽限期后收到 error 事件结束
+		h.BeginShutdown(time.Duration(max(cfg.ShutdownStreamGrace, 0))*time.Second, time.Duration(cfg.ShutdownRetryAfter)*time.Second)
+
 		// Give existing requests time to complete
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 		defer cancel()
@@ -609,17 +725,82 @@ func main() {
 		if err := server.Shutdown(shutdownCtx); err != nil {
 			slog.Error("Server shutdown error", "error", err)
 		}
+		if adminServer != nil {
+			if err := adminServer.Shutdown(shutdownCtx); err != nil {
+				slog.Error("Admin server shutdown error", "error", err)
+			}
+		}
 		close(idleConnsClosed)
 	}()
 
-	slog.Info("Server running", "port", cfg.Port)
-	slog.Info("Admin UI available", "url", fmt.Sprintf("http://localhost:%s%s", cfg.Port, cfg.AdminPath))
+	// systemd socket 激活传入的监听代替 TCP 端口；unix_socket 在此之外额外监听
+	var listeners []net.Listener
+	if cfg.SystemdSocket {
+		inherited, err := listener.Systemd()
+		if err != nil {
+			slog.Error("Inherit systemd sockets failed", "error", err)
+			os.Exit(1)
+		}
+		if len(inherited) == 0 {
+			slog.Warn("systemd_socket 已开启但进程不是由 systemd socket 激活的，改为监听 TCP 端口", "port", cfg.Port)
+		}
+		listeners = inherited
+	}
+	if len(listeners) == 0 {
+		l, err := net.Listen("tcp", server.Addr)
+		if err != nil {
+			slog.Error("Server start failed", "error", err)
+			os.Exit(1)
+		}
+		listeners = append(listeners, l)
+	}
+	if cfg.UnixSocket != "" {
+		l, err := listener.Unix(cfg.UnixSocket, cfg.UnixSocketFileMode())
+		if err != nil {
+			slog.Error("Listen on unix socket failed", "path", cfg.UnixSocket, "error", err)
+			os.Exit(1)
+		}
+		listeners = append(listeners, l)
+	}
+	for _, l := range listeners {
+		slog.Info("Server running", "network", l.Addr().Network(), "addr", l.Addr().String())
+	}
+	adminURL := fmt.Sprintf("http://localhost:%s%s%s", cfg.Port, cfg.BasePath, cfg.AdminPath)
+	var adminListener net.Listener
+	if adminServer != nil {
+		l, err := net.Listen("tcp", adminServer.Addr)
+		if err != nil {
+			slog.Error("Listen on admin address failed", "addr", adminServer.Addr, "error", err)
+			os.Exit(1)
+		}
+		adminListener = l
+		adminURL = fmt.Sprintf("http://%s%s%s", l.Addr(), cfg.BasePath, cfg.AdminPath)
+	}
+	slog.Info("Admin UI available", "url", adminURL)
 
-	if err := server.ListenAndServe(); err != http.ErrServerClosed {
-		slog.Error("Server start failed", "error", err)
-		os.Exit(1)
+	serveErr := make(chan error, len(listeners)+1)
+	for _, l := range listeners {
+		go func() { serveErr <- server.Serve(l) }()
+	}
+	serving := len(listeners)
+	if adminServer != nil {
+		go func() { serveErr <- adminServer.Serve(adminListener) }()
+		serving++
+	}
+	// 关闭时每个监听的 Serve 都返回 ErrServerClosed，其他错误立即退出
+	for range serving {
+		if err := <-serveErr; err != http.ErrServerClosed {
+			slog.Error("Server start failed", "error", err)
+			os.Exit(1)
+		}
 	}
 
 	<-idleConnsClosed
+	if err := eventBus.Close(); err != nil {
+		slog.Warn("Flush events failed", "error", err)
+	}
 	slog.Info("Server shutdown gracefully")
+	if err := logSinks.Close(); err != nil {
+		fmt.Fprintln(os.Stderr, "flush log sinks:", err)
+	}
 }
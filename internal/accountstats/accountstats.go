@@ -0,0 +1,314 @@
+// Package accountstats 按小时桶记录每个账号的请求、错误与 token 用量，
+// 用于用量排行与异常检测（错误率突增、用量远超历史均值）
+package accountstats
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWindowHours = 24
+	// maxRecentErrors 为每个账号保留的最近错误条数
+	maxRecentErrors = 20
+)
+
+// 异常类型
+const (
+	AnomalyErrorRate  = "error_rate"
+	AnomalyUsageSpike = "usage_spike"
+)
+
+type bucket struct {
+	hour     int64
+	requests int64
+	errors   int64
+	tokens   int64
+}
+
+type series struct {
+	buckets   []bucket
+	firstHour int64
+	lastError string
+	// recent 为最近错误的环形缓冲，next 指向下一个写入位置
+	recent      []ErrorEvent
+	next        int
+	lastRefresh *RefreshResult
+}
+
+// ErrorEvent 为一次上游失败，Status 为分类后的账号状态（可为空）
+type ErrorEvent struct {
+	At     time.Time `json:"at"`
+	Status string    `json:"status,omitempty"`
+}
+
+// RefreshResult 为最近一次 token 刷新的结果
+type RefreshResult struct {
+	At    time.Time `json:"at"`
+	OK    bool      `json:"ok"`
+	Error string    `json:"error,omitempty"`
+}
+
+// Tracker 在内存中保留最近 window 小时的统计，进程重启后重新累积
+type Tracker struct {
+	mu       sync.Mutex
+	window   int
+	accounts map[int64]*series
+	now      func() time.Time
+}
+
+// New 创建统计器，windowHours <= 0 时使用 24 小时
+func New(windowHours int) *Tracker {
+	if windowHours <= 0 {
+		windowHours = defaultWindowHours
+	}
+	return &Tracker{
+		window:   windowHours,
+		accounts: make(map[int64]*series),
+		now:      time.Now,
+	}
+}
+
+// WindowHours 返回统计窗口（小时）
+func (t *Tracker) WindowHours() int {
+	return t.window
+}
+
+// RecordSuccess 记录一次成功请求及其 token 用量
+func (t *Tracker) RecordSuccess(accountID int64, tokens int) {
+	if t == nil || accountID <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := t.currentBucket(accountID)
+	b.requests++
+	if tokens > 0 {
+		b.tokens += int64(tokens)
+	}
+}
+
+// RecordError 记录一次上游失败，status 为分类后的账号状态（可为空）
+func (t *Tracker) RecordError(accountID int64, status string) {
+	if t == nil || accountID <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	b := t.currentBucket(accountID)
+	b.requests++
+	b.errors++
+	s := t.accounts[accountID]
+	if status != "" {
+		s.lastError = status
+	}
+	ev := ErrorEvent{At: t.now(), Status: status}
+	if len(s.recent) < maxRecentErrors {
+		s.recent = append(s.recent, ev)
+	} else {
+		s.recent[s.next] = ev
+	}
+	s.next = (s.next + 1) % maxRecentErrors
+}
+
+// RecordRefresh 记录一次 token 刷新结果，err 为 nil 表示成功
+func (t *Tracker) RecordRefresh(accountID int64, err error) {
+	if t == nil || accountID <= 0 {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	res := &RefreshResult{At: t.now(), OK: err == nil}
+	if err != nil {
+		res.Error = err.Error()
+	}
+	t.seriesFor(accountID).lastRefresh = res
+}
+
+// seriesFor 返回账号的统计序列，不存在时创建，调用方持有 t.mu
+func (t *Tracker) seriesFor(accountID int64) *series {
+	s, ok := t.accounts[accountID]
+	if !ok {
+		s = &series{buckets: make([]bucket, t.window), firstHour: t.now().Unix() / 3600}
+		t.accounts[accountID] = s
+	}
+	return s
+}
+
+// currentBucket 返回当前小时的桶，调用方持有 t.mu
+func (t *Tracker) currentBucket(accountID int64) *bucket {
+	hour := t.now().Unix() / 3600
+	s := t.seriesFor(accountID)
+	b := &s.buckets[hour%int64(t.window)]
+	if b.hour != hour {
+		*b = bucket{hour: hour}
+	}
+	return b
+}
+
+// AccountStats 为单个账号在窗口内的统计。Current* 为当前小时，
+// AvgHourly* 为窗口内此前各小时的均值（不足一个完整小时的历史时为 0）
+type AccountStats struct {
+	AccountID         int64   `json:"account_id"`
+	Requests          int64   `json:"requests"`
+	Errors            int64   `json:"errors"`
+	Tokens            int64   `json:"tokens"`
+	ErrorRate         float64 `json:"error_rate"`
+	CurrentRequests   int64   `json:"current_requests"`
+	CurrentErrors     int64   `json:"current_errors"`
+	CurrentTokens     int64   `json:"current_tokens"`
+	CurrentErrorRate  float64 `json:"current_error_rate"`
+	AvgHourlyRequests float64 `json:"avg_hourly_requests"`
+	AvgHourlyTokens   float64 `json:"avg_hourly_tokens"`
+	LastError         string  `json:"last_error,omitempty"`
+}
+
+// Snapshot 返回所有账号的窗口统计，按 token 用量降序
+func (t *Tracker) Snapshot() []AccountStats {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	hour := t.now().Unix() / 3600
+	out := make([]AccountStats, 0, len(t.accounts))
+	for id, s := range t.accounts {
+		if st := s.stats(id, hour, t.window); st.Requests > 0 {
+			out = append(out, st)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Tokens != out[j].Tokens {
+			return out[i].Tokens > out[j].Tokens
+		}
+		return out[i].AccountID < out[j].AccountID
+	})
+	return out
+}
+
+// stats 汇总截至 hour 的窗口统计，调用方持有 t.mu
+func (s *series) stats(id, hour int64, window int) AccountStats {
+	oldest := hour - int64(window) + 1
+	st := AccountStats{AccountID: id, LastError: s.lastError}
+	var prevRequests, prevTokens int64
+	for _, b := range s.buckets {
+		if b.hour < oldest || b.hour > hour {
+			continue
+		}
+		st.Requests += b.requests
+		st.Errors += b.errors
+		st.Tokens += b.tokens
+		if b.hour == hour {
+			st.CurrentRequests = b.requests
+			st.CurrentErrors = b.errors
+			st.CurrentTokens = b.tokens
+		} else {
+			prevRequests += b.requests
+			prevTokens += b.tokens
+		}
+	}
+	if st.Requests == 0 {
+		return st
+	}
+	// 历史小时数从账号首次出现算起，避免新账号的均值被空桶稀释
+	start := s.firstHour
+	if start < oldest {
+		start = oldest
+	}
+	if prevHours := hour - start; prevHours > 0 {
+		st.AvgHourlyRequests = float64(prevRequests) / float64(prevHours)
+		st.AvgHourlyTokens = float64(prevTokens) / float64(prevHours)
+	}
+	st.ErrorRate = float64(st.Errors) / float64(st.Requests)
+	if st.CurrentRequests > 0 {
+		st.CurrentErrorRate = float64(st.CurrentErrors) / float64(st.CurrentRequests)
+	}
+	return st
+}
+
+// HourlyUsage 为单个小时桶的统计
+type HourlyUsage struct {
+	Hour     time.Time `json:"hour"`
+	Requests int64     `json:"requests"`
+	Errors   int64     `json:"errors"`
+	Tokens   int64     `json:"tokens"`
+}
+
+// Detail 为单个账号的详细统计，供账号详情页使用
+type Detail struct {
+	Stats        AccountStats   `json:"stats"`
+	History      []HourlyUsage  `json:"history"`
+	RecentErrors []ErrorEvent   `json:"recent_errors"`
+	LastRefresh  *RefreshResult `json:"last_refresh,omitempty"`
+}
+
+// Detail 返回账号的窗口统计、逐小时用量（从旧到新，含空小时）、
+// 最近错误（从新到旧）与最近一次刷新结果；账号无记录时返回零值统计
+func (t *Tracker) Detail(accountID int64) Detail {
+	d := Detail{Stats: AccountStats{AccountID: accountID}, History: []HourlyUsage{}, RecentErrors: []ErrorEvent{}}
+	if t == nil {
+		return d
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.accounts[accountID]
+	if !ok {
+		return d
+	}
+	hour := t.now().Unix() / 3600
+	d.Stats = s.stats(accountID, hour, t.window)
+	for h := hour - int64(t.window) + 1; h <= hour; h++ {
+		u := HourlyUsage{Hour: time.Unix(h*3600, 0).UTC()}
+		if b := s.buckets[h%int64(t.window)]; b.hour == h {
+			u.Requests, u.Errors, u.Tokens = b.requests, b.errors, b.tokens
+		}
+		d.History = append(d.History, u)
+	}
+	for i := 1; i <= len(s.recent); i++ {
+		d.RecentErrors = append(d.RecentErrors, s.recent[(s.next-i+maxRecentErrors)%maxRecentErrors])
+	}
+	if s.lastRefresh != nil {
+		r := *s.lastRefresh
+		d.LastRefresh = &r
+	}
+	return d
+}
+
+// Thresholds 为异常检测阈值，值 <= 0 的项不检测
+type Thresholds struct {
+	// ErrorRate 当前小时错误率上限（0-1）
+	ErrorRate float64
+	// MinRequests 当前小时请求数达到该值才判断错误率，避免小样本误报
+	MinRequests int
+	// SpikeFactor 当前小时 token 用量超过历史小时均值的倍数
+	SpikeFactor float64
+}
+
+// Anomaly 描述一个异常账号
+type Anomaly struct {
+	AccountID int64   `json:"account_id"`
+	Kind      string  `json:"kind"`
+	Value     float64 `json:"value"`
+	Threshold float64 `json:"threshold"`
+}
+
+// Detect 按阈值从统计中找出异常账号
+func Detect(stats []AccountStats, th Thresholds) []Anomaly {
+	var out []Anomaly
+	for _, st := range stats {
+		if th.ErrorRate > 0 && st.CurrentRequests >= int64(th.MinRequests) && st.CurrentRequests > 0 &&
+			st.CurrentErrorRate >= th.ErrorRate {
+			out = append(out, Anomaly{AccountID: st.AccountID, Kind: AnomalyErrorRate, Value: st.CurrentErrorRate, Threshold: th.ErrorRate})
+		}
+		if th.SpikeFactor > 0 && st.AvgHourlyTokens > 0 {
+			ratio := float64(st.CurrentTokens) / st.AvgHourlyTokens
+			if ratio >= th.SpikeFactor {
+				out = append(out, Anomaly{AccountID: st.AccountID, Kind: AnomalyUsageSpike, Value: ratio, Threshold: th.SpikeFactor})
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,117 @@
+package accountstats
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newTestTracker(window int, now *time.Time) *Tracker {
+	t := New(window)
+	t.now = func() time.Time { return *now }
+	return t
+}
+
+func TestTracker_ErrorRateAnomaly(t *testing.T) {
+	now := time.Date(2026, 1, 1, 10, 0, 0, 0, time.UTC)
+	tr := newTestTracker(24, &now)
+
+	for i := 0; i < 4; i++ {
+		tr.RecordSuccess(1, 100)
+	}
+	for i := 0; i < 6; i++ {
+		tr.RecordError(1, "403")
+	}
+	tr.RecordError(2, "429") // 样本太少，不报告
+
+	stats := tr.Snapshot()
+	if len(stats) != 2 {
+		t.Fatalf("len(stats)=%d want 2", len(stats))
+	}
+	if stats[0].AccountID != 1 || stats[0].Requests != 10 || stats[0].Errors != 6 || stats[0].LastError != "403" {
+		t.Fatalf("unexpected stats: %+v", stats[0])
+	}
+
+	anomalies := Detect(stats, Thresholds{ErrorRate: 0.5, MinRequests: 5})
+	if len(anomalies) != 1 || anomalies[0].AccountID != 1 || anomalies[0].Kind != AnomalyErrorRate {
+		t.Fatalf("unexpected anomalies: %+v", anomalies)
+	}
+}
+
+func TestTracker_UsageSpikeAgainstTrailingAverage(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr := newTestTracker(24, &now)
+
+	for h := 0; h < 4; h++ {
+		tr.RecordSuccess(1, 1000)
+		tr.RecordSuccess(2, 1000)
+		now = now.Add(time.Hour)
+	}
+	tr.RecordSuccess(1, 5000)
+	tr.RecordSuccess(2, 1500)
+
+	stats := tr.Snapshot()
+	var spiking *AccountStats
+	for i := range stats {
+		if stats[i].AccountID == 1 {
+			spiking = &stats[i]
+		}
+	}
+	if spiking == nil || spiking.AvgHourlyTokens != 1000 || spiking.CurrentTokens != 5000 {
+		t.Fatalf("unexpected stats: %+v", spiking)
+	}
+
+	anomalies := Detect(stats, Thresholds{SpikeFactor: 3})
+	if len(anomalies) != 1 || anomalies[0].AccountID != 1 || anomalies[0].Kind != AnomalyUsageSpike {
+		t.Fatalf("unexpected anomalies: %+v", anomalies)
+	}
+}
+
+func TestTracker_DropsBucketsOutsideWindow(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr := newTestTracker(3, &now)
+
+	tr.RecordSuccess(1, 100)
+	now = now.Add(3 * time.Hour)
+	tr.RecordSuccess(1, 50)
+
+	stats := tr.Snapshot()
+	if len(stats) != 1 || stats[0].Tokens != 50 || stats[0].Requests != 1 {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func TestTracker_Detail(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	tr := newTestTracker(3, &now)
+
+	tr.RecordSuccess(1, 100)
+	now = now.Add(time.Hour)
+	for i := 0; i < maxRecentErrors+2; i++ {
+		tr.RecordError(1, "429")
+	}
+	tr.RecordError(1, "403")
+	tr.RecordRefresh(1, errors.New("boom"))
+
+	d := tr.Detail(1)
+	if d.Stats.Requests != maxRecentErrors+4 || d.Stats.LastError != "403" {
+		t.Fatalf("unexpected stats: %+v", d.Stats)
+	}
+	if len(d.History) != 3 || d.History[0].Requests != 0 || d.History[1].Tokens != 100 || d.History[2].Errors != maxRecentErrors+3 {
+		t.Fatalf("unexpected history: %+v", d.History)
+	}
+	if len(d.RecentErrors) != maxRecentErrors || d.RecentErrors[0].Status != "403" || d.RecentErrors[1].Status != "429" {
+		t.Fatalf("unexpected recent errors: %+v", d.RecentErrors)
+	}
+	if d.LastRefresh == nil || d.LastRefresh.OK || d.LastRefresh.Error != "boom" {
+		t.Fatalf("unexpected last refresh: %+v", d.LastRefresh)
+	}
+
+	tr.RecordRefresh(2, nil)
+	if d := tr.Detail(2); d.LastRefresh == nil || !d.LastRefresh.OK || d.Stats.Requests != 0 {
+		t.Fatalf("unexpected detail for refreshed-only account: %+v", d)
+	}
+	if len(tr.Snapshot()) != 1 {
+		t.Fatalf("refresh-only account should not appear in snapshot")
+	}
+}
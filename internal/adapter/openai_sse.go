@@ -1,6 +1,12 @@
 package adapter
 
-import "encoding/json"
+import (
+	"bytes"
+	"encoding/json"
+	"strconv"
+
+	"orchids-api/internal/perf"
+)
 
 // BuildOpenAIChunk 将 Anthropic SSE 事件转换为 OpenAI chunk。
 func BuildOpenAIChunk(msgID string, created int64, event string, data []byte) ([]byte, bool) {
@@ -99,3 +105,18 @@ func BuildOpenAIChunk(msgID string, created int64, event string, data []byte) ([
 	}
 	return bytes, true
 }
+
+// WriteOpenAIDeltaChunk 直接把单字段 delta（content / reasoning_content）编码为 OpenAI chunk，
+// 输出与 BuildOpenAIChunk 对同一事件的结果逐字节一致，但不经过 map 和二次 JSON 解析。
+func WriteOpenAIDeltaChunk(buf *bytes.Buffer, msgID string, created int64, field, value string) {
+	buf.WriteString(`{"choices":[{"delta":{"`)
+	buf.WriteString(field)
+	buf.WriteString(`":`)
+	perf.WriteJSONString(buf, value)
+	buf.WriteString(`},"index":0}],"created":`)
+	var num [20]byte
+	buf.Write(strconv.AppendInt(num[:0], created, 10))
+	buf.WriteString(`,"id":`)
+	perf.WriteJSONString(buf, msgID)
+	buf.WriteString(`,"model":"","object":"chat.completion.chunk"}`)
+}
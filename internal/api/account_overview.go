@@ -0,0 +1,80 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"orchids-api/internal/loadbalancer"
+	"orchids-api/internal/orchids"
+	"orchids-api/internal/warp"
+)
+
+func (a *API) SetLoadBalancer(lb *loadbalancer.LoadBalancer) {
+	a.lb = lb
+}
+
+// accountTokenCache 为账号当前的 token 缓存状态：Orchids 账号返回缓存的 JWT（已脱敏）与换取退避，
+// Warp 账号返回会话状态
+type accountTokenCache struct {
+	Cached       bool                     `json:"cached"`
+	Orchids      *orchids.CachedTokenInfo `json:"orchids,omitempty"`
+	Warp         *warp.SessionInfo        `json:"warp,omitempty"`
+	TokenBackoff string                   `json:"token_backoff,omitempty"`
+}
+
+// handleAccountOverview 处理 GET /api/accounts/{id}/overview：一次返回账号详情页所需的账号记录、
+// 窗口统计、逐小时用量、最近错误、token 缓存、当前连接数与最近一次刷新结果。
+// 统计类数据保存在各实例内存中，只反映处理本请求的实例
+func (a *API) handleAccountOverview(w http.ResponseWriter, r *http.Request, id int64) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	acc, err := a.store.GetAccount(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	var cache accountTokenCache
+	if strings.EqualFold(acc.AccountType, "warp") {
+		if info, ok := warp.LookupSession(acc.ID); ok {
+			cache.Cached = info.HasToken
+			cache.Warp = &info
+		}
+	} else {
+		if info, ok := orchids.LookupCachedToken(acc.SessionID); ok {
+			cache.Cached = true
+			cache.Orchids = &info
+		}
+		if remaining, ok := orchids.TokenBackoffRemaining(acc.SessionID, acc.ID); ok {
+			cache.TokenBackoff = remaining.Round(time.Second).String()
+		}
+	}
+
+	var connections int64
+	if a.lb != nil {
+		connections = a.lb.ActiveConnections(acc.ID)
+	}
+
+	detail := a.accountStats.Detail(acc.ID)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"account":       accountOutput(acc),
+		"window_hours":  a.accountStatsWindow(),
+		"stats":         detail.Stats,
+		"usage_history": detail.History,
+		"recent_errors": detail.RecentErrors,
+		"last_refresh":  detail.LastRefresh,
+		"token_cache":   cache,
+		"connections":   connections,
+	})
+}
+
+func (a *API) accountStatsWindow() int {
+	if a.accountStats == nil {
+		return 0
+	}
+	return a.accountStats.WindowHours()
+}
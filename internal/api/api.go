@@ -9,18 +9,30 @@ import (
 	"log/slog"
 	"math/big"
 	"net/http"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"orchids-api/internal/accountstats"
 	"orchids-api/internal/auth"
 	"orchids-api/internal/clerk"
 	"orchids-api/internal/config"
+	"orchids-api/internal/debug"
+	"orchids-api/internal/events"
+	"orchids-api/internal/flags"
+	"orchids-api/internal/loadbalancer"
+	"orchids-api/internal/logsink"
+	"orchids-api/internal/metrics"
+	"orchids-api/internal/middleware"
+	"orchids-api/internal/modelsync"
 	"orchids-api/internal/orchids"
 	"orchids-api/internal/prompt"
+	"orchids-api/internal/report"
 	"orchids-api/internal/store"
 	"orchids-api/internal/tokencache"
+	"orchids-api/internal/toolspill"
 	"orchids-api/internal/warp"
 )
 
@@ -28,11 +40,25 @@ type API struct {
 	store        *store.Store
 	summaryCache prompt.SummaryCache
 	tokenCache   tokencache.Cache
+	modelSyncer  *modelsync.Syncer
+	accountStats *accountstats.Tracker
+	logSinks     *logsink.Set
+	flags        *flags.Manager
+	canary       *middleware.CanarySplitter
+	events       *events.Bus
+	reporter     *report.Reporter
+	lb           *loadbalancer.LoadBalancer
+	toolSpill    *toolspill.Store
+	basePath     string // 启动时的 base_path，作为会话 Cookie 的 Path
 	adminUser    string
 	adminPass    string
 	configMu     sync.RWMutex
 	config       interface{} // Using interface{} to avoid circular dependency if any, or just use *config.Config
 	configPath   string      // Path to config.json
+
+	// HandleProfile 的并发与频率限制
+	profileMu     sync.Mutex
+	lastProfileAt time.Time
 }
 
 func normalizeWarpTokenInput(acc *store.Account) {
@@ -62,6 +88,35 @@ func normalizeWarpTokenOutput(acc *store.Account) *store.Account {
 	return &copyAcc
 }
 
+// accountOutput 为管理接口返回的账号：凭据字段脱敏，完整凭据只能通过 /api/accounts/{id}/credentials 的 POST 查看
+func accountOutput(acc *store.Account) *store.Account {
+	out := normalizeWarpTokenOutput(acc)
+	if out == nil {
+		return nil
+	}
+	out.ClientCookie = auth.MaskSensitive(out.ClientCookie)
+	out.SessionCookie = auth.MaskSensitive(out.SessionCookie)
+	out.RefreshToken = auth.MaskSensitive(out.RefreshToken)
+	out.Token = auth.MaskSensitive(out.Token)
+	return out
+}
+
+// keepMaskedCredentials 将 PUT 请求中原样提交的脱敏值还原为已保存的凭据，
+// 编辑账号时未修改凭据的客户端（如管理界面）不会用脱敏值覆盖原值
+func keepMaskedCredentials(acc, existing *store.Account) {
+	saved := normalizeWarpTokenOutput(existing)
+	for _, f := range []struct{ submitted, saved *string }{
+		{&acc.ClientCookie, &saved.ClientCookie},
+		{&acc.SessionCookie, &saved.SessionCookie},
+		{&acc.RefreshToken, &saved.RefreshToken},
+		{&acc.Token, &saved.Token},
+	} {
+		if *f.submitted != "" && *f.submitted == auth.MaskSensitive(*f.saved) {
+			*f.submitted = *f.saved
+		}
+	}
+}
+
 type ExportData struct {
 	Version  int             `json:"version"`
 	ExportAt time.Time       `json:"export_at"`
@@ -77,6 +132,7 @@ type ImportResult struct {
 type CreateKeyResponse struct {
 	ID        int64     `json:"id"`
 	Key       string    `json:"key"`
+	KeyID     string    `json:"key_id"`
 	Name      string    `json:"name"`
 	KeyPrefix string    `json:"key_prefix"`
 	KeySuffix string    `json:"key_suffix"`
@@ -114,6 +170,7 @@ func (a *API) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	}
 
 	if req.Username != a.adminUser || req.Password != a.adminPass {
+		slog.Warn("Admin login failed", "audit", "admin_login_failed", "username", req.Username, "client_ip", middleware.ClientIP(r))
 		http.Error(w, "Invalid credentials", http.StatusUnauthorized)
 		return
 	}
@@ -128,7 +185,7 @@ func (a *API) HandleLogin(w http.ResponseWriter, r *http.Request) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session_token",
 		Value:    token,
-		Path:     "/",
+		Path:     a.cookiePath(),
 		HttpOnly: true,
 		Secure:   true,
 		SameSite: http.SameSiteLaxMode,
@@ -148,7 +205,7 @@ func (a *API) HandleLogout(w http.ResponseWriter, r *http.Request) {
 	http.SetCookie(w, &http.Cookie{
 		Name:     "session_token",
 		Value:    "",
-		Path:     "/",
+		Path:     a.cookiePath(),
 		HttpOnly: true,
 		MaxAge:   -1,
 	})
@@ -167,7 +224,31 @@ func (a *API) HandleConfig(w http.ResponseWriter, r *http.Request) {
 	case http.MethodPost:
 		// Update config under write lock
 		a.configMu.Lock()
-		if err := json.NewDecoder(r.Body).Decode(a.config); err != nil {
+		previous, _ := json.Marshal(a.config)
+		if current, ok := a.config.(*config.Config); ok {
+			// 先合并到副本并校验，校验失败时不影响运行中的配置
+			var candidate config.Config
+			json.Unmarshal(previous, &candidate)
+			if err := json.NewDecoder(r.Body).Decode(&candidate); err != nil {
+				a.configMu.Unlock()
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			// 配置 JSON 中的密钥字段为引用，解析后才能作为运行中的配置
+			if err := candidate.ResolveSecrets(r.Context()); err != nil {
+				a.configMu.Unlock()
+				http.Error(w, "Failed to resolve config secrets: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			if errs := validateConfigUpdate(&candidate); len(errs) > 0 {
+				a.configMu.Unlock()
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(configValidateResponse{SchemaVersion: config.CurrentSchemaVersion, Errors: errs})
+				return
+			}
+			candidate.SchemaVersion = config.CurrentSchemaVersion
+			*current = candidate
+		} else if err := json.NewDecoder(r.Body).Decode(a.config); err != nil {
 			a.configMu.Unlock()
 			http.Error(w, err.Error(), http.StatusBadRequest)
 			return
@@ -186,6 +267,7 @@ func (a *API) HandleConfig(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, "Failed to save config to Redis: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		a.recordConfigVersion(r, previous, data, 0)
 
 		a.configMu.RLock()
 		w.WriteHeader(http.StatusOK)
@@ -201,17 +283,31 @@ func (a *API) HandleAccounts(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
+		q, paged, err := parseListQuery(r, accountSortFields, "-id")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		accounts, err := a.store.ListAccounts(r.Context())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if paged {
+			resp, err := pageAccounts(accounts, q, strings.TrimSpace(r.URL.Query().Get("type")))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
 		if accounts == nil {
 			accounts = []*store.Account{}
 		}
 		normalized := make([]*store.Account, 0, len(accounts))
 		for _, acc := range accounts {
-			normalized = append(normalized, normalizeWarpTokenOutput(acc))
+			normalized = append(normalized, accountOutput(acc))
 		}
 		json.NewEncoder(w).Encode(normalized)
 
@@ -268,7 +364,7 @@ func (a *API) HandleAccounts(w http.ResponseWriter, r *http.Request) {
 		}
 
 		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(normalizeWarpTokenOutput(&acc))
+		json.NewEncoder(w).Encode(accountOutput(&acc))
 
 	default:
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -286,8 +382,16 @@ func (a *API) HandleAccountByID(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if len(parts) > 1 && parts[1] == "credentials" {
+		a.handleAccountCredentials(w, r, id)
+		return
+	}
 	isRefresh := len(parts) > 1 && parts[1] == "refresh"
 	isUsage := len(parts) > 1 && parts[1] == "usage"
+	if len(parts) > 1 && parts[1] == "overview" {
+		a.handleAccountOverview(w, r, id)
+		return
+	}
 
 	switch r.Method {
 	case http.MethodGet:
@@ -326,6 +430,7 @@ func (a *API) HandleAccountByID(w http.ResponseWriter, r *http.Request) {
 				a.configMu.RUnlock()
 				warpClient := warp.NewFromAccount(acc, cfg)
 				jwt, err := warpClient.RefreshAccount(r.Context())
+				a.accountStats.RecordRefresh(acc.ID, err)
 				if err != nil {
 					status := http.StatusBadRequest
 					if code := warp.HTTPStatusCode(err); code >= 400 {
@@ -349,6 +454,8 @@ func (a *API) HandleAccountByID(w http.ResponseWriter, r *http.Request) {
 						}
 						a.configMu.RUnlock()
 
+						// 手动刷新不受换取失败退避限制
+						orchids.InvalidateCachedToken(acc.SessionID)
 						orchidsClient := orchids.NewFromAccount(acc, cfg)
 						jwt, jwtErr := orchidsClient.GetToken()
 						if jwtErr == nil && strings.TrimSpace(jwt) != "" {
@@ -360,11 +467,13 @@ func (a *API) HandleAccountByID(w http.ResponseWriter, r *http.Request) {
 						}
 					}
 
+					a.accountStats.RecordRefresh(acc.ID, refreshErr)
 					if refreshErr != nil {
 						http.Error(w, "Failed to refresh account: "+refreshErr.Error(), http.StatusBadRequest)
 						return
 					}
 				} else {
+					a.accountStats.RecordRefresh(acc.ID, nil)
 					slog.Info("Orchids refresh: clerk info", "account_id", id, "has_jwt", info.JWT != "", "email", info.Email)
 					acc.SessionID = info.SessionID
 					acc.ClientUat = info.ClientUat
@@ -379,6 +488,7 @@ func (a *API) HandleAccountByID(w http.ResponseWriter, r *http.Request) {
 			}
 
 			// 刷新成功后清理账号状态
+			previous := acc.StatusCode
 			acc.StatusCode = ""
 			acc.LastAttempt = time.Time{}
 			acc.QuotaResetAt = time.Time{}
@@ -387,7 +497,16 @@ func (a *API) HandleAccountByID(w http.ResponseWriter, r *http.Request) {
 				http.Error(w, "Failed to save refreshed account: "+err.Error(), http.StatusInternalServerError)
 				return
 			}
-			json.NewEncoder(w).Encode(normalizeWarpTokenOutput(acc))
+			if previous != "" {
+				a.events.Publish(events.AccountStatusChanged, events.AccountStatus{
+					AccountID:   acc.ID,
+					AccountName: acc.Name,
+					AccountType: acc.AccountType,
+					Previous:    previous,
+					Reason:      "管理员手动刷新",
+				})
+			}
+			json.NewEncoder(w).Encode(accountOutput(acc))
 			return
 		}
 		acc, err := a.store.GetAccount(r.Context(), id)
@@ -395,7 +514,7 @@ func (a *API) HandleAccountByID(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusNotFound)
 			return
 		}
-		json.NewEncoder(w).Encode(normalizeWarpTokenOutput(acc))
+		json.NewEncoder(w).Encode(accountOutput(acc))
 
 	case http.MethodPut:
 		existing, err := a.store.GetAccount(r.Context(), id)
@@ -413,6 +532,7 @@ func (a *API) HandleAccountByID(w http.ResponseWriter, r *http.Request) {
 		if strings.TrimSpace(acc.AccountType) == "" {
 			acc.AccountType = existing.AccountType
 		}
+		keepMaskedCredentials(&acc, existing)
 		if strings.TrimSpace(acc.AccountType) == "" {
 			acc.AccountType = "orchids"
 		}
@@ -461,7 +581,7 @@ func (a *API) HandleAccountByID(w http.ResponseWriter, r *http.Request) {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		json.NewEncoder(w).Encode(normalizeWarpTokenOutput(&acc))
+		json.NewEncoder(w).Encode(accountOutput(&acc))
 
 	case http.MethodDelete:
 		if err := a.store.DeleteAccount(r.Context(), id); err != nil {
@@ -475,6 +595,111 @@ func (a *API) HandleAccountByID(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// accountBatchRequest 为 PATCH /api/accounts/batch 的请求体。
+// filter 中 ids 与 account_type 同时给出时取交集。
+type accountBatchRequest struct {
+	Filter struct {
+		IDs         []int64 `json:"ids"`
+		AccountType string  `json:"account_type"`
+	} `json:"filter"`
+	Update store.AccountPatch `json:"update"`
+}
+
+// HandleAccountsBatch 将同一份部分更新原子地应用到匹配的账号上
+func (a *API) HandleAccountsBatch(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req accountBatchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Update.IsEmpty() {
+		http.Error(w, "update must set at least one of enabled, weight, max_concurrent, agent_mode", http.StatusBadRequest)
+		return
+	}
+	if req.Update.Weight != nil && *req.Update.Weight < 0 {
+		http.Error(w, "weight must be >= 0", http.StatusBadRequest)
+		return
+	}
+	if req.Update.MaxConcurrent != nil && *req.Update.MaxConcurrent < 0 {
+		http.Error(w, "max_concurrent must be >= 0", http.StatusBadRequest)
+		return
+	}
+	accountType := strings.TrimSpace(req.Filter.AccountType)
+	if len(req.Filter.IDs) == 0 && accountType == "" {
+		http.Error(w, "filter must set ids or account_type", http.StatusBadRequest)
+		return
+	}
+
+	accounts, err := a.store.ListAccounts(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	byID := make(map[int64]*store.Account, len(accounts))
+	for _, acc := range accounts {
+		byID[acc.ID] = acc
+	}
+
+	var ids []int64
+	if len(req.Filter.IDs) > 0 {
+		seen := make(map[int64]bool, len(req.Filter.IDs))
+		for _, id := range req.Filter.IDs {
+			if seen[id] {
+				continue
+			}
+			seen[id] = true
+			acc, ok := byID[id]
+			if !ok {
+				http.Error(w, "account "+strconv.FormatInt(id, 10)+" not found", http.StatusNotFound)
+				return
+			}
+			if accountType != "" && !strings.EqualFold(acc.AccountType, accountType) {
+				continue
+			}
+			ids = append(ids, id)
+		}
+	} else {
+		for _, acc := range accounts {
+			if strings.EqualFold(acc.AccountType, accountType) {
+				ids = append(ids, acc.ID)
+			}
+		}
+	}
+
+	updated, err := a.store.BatchUpdateAccounts(r.Context(), ids, req.Update)
+	if err != nil {
+		metrics.AdminTasks.WithLabelValues("accounts_batch", "failure").Inc()
+		a.events.Publish(events.TaskFinished, events.Task{Task: "accounts_batch", Result: "failure", Detail: err.Error()})
+		switch {
+		case errors.Is(err, store.ErrNoRows):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, store.ErrConflict):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	metrics.AdminTasks.WithLabelValues("accounts_batch", "success").Inc()
+	a.events.Publish(events.TaskFinished, events.Task{Task: "accounts_batch", Result: "success", Detail: strconv.Itoa(len(updated)) + " accounts updated"})
+	slog.Info("批量更新账号", "audit", "accounts_batch", "ids", ids)
+
+	out := make([]*store.Account, 0, len(updated))
+	for _, acc := range updated {
+		out = append(out, accountOutput(acc))
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"updated":  len(out),
+		"accounts": out,
+	})
+}
+
 func (a *API) HandleExport(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -498,6 +723,8 @@ func (a *API) HandleExport(w http.ResponseWriter, r *http.Request) {
 		exportData.Accounts[i].RequestCount = 0
 	}
 
+	// 导出用于迁移与备份，包含完整凭据
+	slog.Info("Accounts exported", "audit", "accounts_export", "count", len(accounts), "author", a.configAuthor(r), "client_ip", middleware.ClientIP(r))
 	w.Header().Set("Content-Type", "application/json")
 	w.Header().Set("Content-Disposition", "attachment; filename=accounts_export.json")
 	json.NewEncoder(w).Encode(exportData)
@@ -576,11 +803,25 @@ func (a *API) HandleKeys(w http.ResponseWriter, r *http.Request) {
 
 	switch r.Method {
 	case http.MethodGet:
+		q, paged, err := parseListQuery(r, keySortFields, "id")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		keys, err := a.store.ListApiKeys(r.Context())
 		if err != nil {
 			http.Error(w, err.Error(), http.StatusInternalServerError)
 			return
 		}
+		if paged {
+			resp, err := pageKeys(keys, q)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
 		json.NewEncoder(w).Encode(keys)
 
 	case http.MethodPost:
@@ -623,6 +864,7 @@ func (a *API) HandleKeys(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(CreateKeyResponse{
 			ID:        key.ID,
 			Key:       fullKey,
+			KeyID:     hashStr[:12],
 			Name:      key.Name,
 			KeyPrefix: key.KeyPrefix,
 			KeySuffix: key.KeySuffix,
@@ -781,6 +1023,211 @@ func (a *API) SetTokenCache(c tokencache.Cache) {
 	a.tokenCache = c
 }
 
+func (a *API) SetEventBus(b *events.Bus) {
+	a.events = b
+}
+
+// SetBasePath 设置部署的路径前缀（base_path），会话 Cookie 只在该前缀下发送
+func (a *API) SetBasePath(basePath string) {
+	a.basePath = basePath
+}
+
+func (a *API) cookiePath() string {
+	if a.basePath == "" {
+		return "/"
+	}
+	return a.basePath
+}
+
+func (a *API) SetModelSyncer(sy *modelsync.Syncer) {
+	a.modelSyncer = sy
+}
+
+// HandleModelSync 处理 /api/models/sync：GET 返回最近一次同步报告，
+// POST 立即同步（?source=orchids|warp，缺省为配置的全部来源）并返回差异报告
+func (a *API) HandleModelSync(w http.ResponseWriter, r *http.Request) {
+	if a.modelSyncer == nil {
+		http.Error(w, "Model sync not configured", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"reports": a.modelSyncer.LastReports(),
+		})
+	case http.MethodPost:
+		var sources []string
+		if source := strings.TrimSpace(r.URL.Query().Get("source")); source != "" && source != "all" {
+			if !modelsync.ValidSource(source) {
+				http.Error(w, "Invalid source", http.StatusBadRequest)
+				return
+			}
+			sources = []string{source}
+		}
+		reports := a.modelSyncer.Run(r.Context(), sources...)
+		slog.Info("Manual model sync triggered", "sources", sources)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"reports": reports,
+		})
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (a *API) SetAccountStats(tracker *accountstats.Tracker) {
+	a.accountStats = tracker
+}
+
+// accountAnalytics 为排行榜中的一项：窗口统计加上账号展示信息
+type accountAnalytics struct {
+	accountstats.AccountStats
+	Name        string `json:"name"`
+	AccountType string `json:"account_type"`
+	Enabled     bool   `json:"enabled"`
+	StatusCode  string `json:"status_code,omitempty"`
+}
+
+// HandleAccountAnalytics 处理 /api/analytics/accounts：返回窗口内的账号用量排行
+// （?sort=tokens|requests|errors|error_rate，?limit=N）以及按配置阈值检测到的异常账号
+func (a *API) HandleAccountAnalytics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.accountStats == nil {
+		http.Error(w, "Account analytics not configured", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	stats := a.accountStats.Snapshot()
+	anomalies := accountstats.Detect(stats, a.anomalyThresholds())
+	if anomalies == nil {
+		anomalies = []accountstats.Anomaly{}
+	}
+
+	switch r.URL.Query().Get("sort") {
+	case "", "tokens":
+	case "requests":
+		sort.SliceStable(stats, func(i, j int) bool { return stats[i].Requests > stats[j].Requests })
+	case "errors":
+		sort.SliceStable(stats, func(i, j int) bool { return stats[i].Errors > stats[j].Errors })
+	case "error_rate":
+		sort.SliceStable(stats, func(i, j int) bool { return stats[i].ErrorRate > stats[j].ErrorRate })
+	default:
+		http.Error(w, "Invalid sort", http.StatusBadRequest)
+		return
+	}
+	if raw := r.URL.Query().Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		if limit < len(stats) {
+			stats = stats[:limit]
+		}
+	}
+
+	byID := map[int64]*store.Account{}
+	if accounts, err := a.store.ListAccounts(r.Context()); err == nil {
+		for _, acc := range accounts {
+			byID[acc.ID] = acc
+		}
+	} else {
+		slog.Warn("Account analytics: list accounts failed", "error", err)
+	}
+	leaderboard := make([]accountAnalytics, 0, len(stats))
+	for _, st := range stats {
+		item := accountAnalytics{AccountStats: st}
+		if acc, ok := byID[st.AccountID]; ok {
+			item.Name = acc.Name
+			item.AccountType = acc.AccountType
+			item.Enabled = acc.Enabled
+			item.StatusCode = acc.StatusCode
+		}
+		leaderboard = append(leaderboard, item)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"window_hours": a.accountStats.WindowHours(),
+		"leaderboard":  leaderboard,
+		"anomalies":    anomalies,
+	})
+}
+
+func (a *API) anomalyThresholds() accountstats.Thresholds {
+	a.configMu.RLock()
+	cfg, ok := a.config.(*config.Config)
+	a.configMu.RUnlock()
+	if !ok || cfg == nil {
+		return accountstats.Thresholds{}
+	}
+	return accountstats.Thresholds{
+		ErrorRate:   cfg.AnomalyErrorRate,
+		MinRequests: cfg.AnomalyMinRequests,
+		SpikeFactor: cfg.AnomalySpikeFactor,
+	}
+}
+
+// HandleDebugLogs 处理 /api/debug-logs：列出保留的调试日志（?trace_id= 过滤）
+func (a *API) HandleDebugLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	var (
+		entries []debug.Entry
+		err     error
+	)
+	if traceID := strings.TrimSpace(r.URL.Query().Get("trace_id")); traceID != "" {
+		entries, err = debug.Find(traceID)
+		if errors.Is(err, debug.ErrEntryNotFound) {
+			entries, err = nil, nil
+		}
+	} else {
+		entries, err = debug.List()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if entries == nil {
+		entries = []debug.Entry{}
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"entries": entries,
+	})
+}
+
+// HandleDebugLogByID 处理 /api/debug-logs/{id|trace_id}：下载脱敏后的调试日志 zip
+func (a *API) HandleDebugLogByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/debug-logs/")
+	entries, err := debug.Find(id)
+	if errors.Is(err, debug.ErrEntryNotFound) {
+		http.Error(w, "Debug log not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="debug-`+entries[0].ID+`.zip"`)
+	if err := debug.WriteArchive(w, entries); err != nil {
+		slog.Warn("Debug log archive failed", "id", id, "error", err)
+	}
+}
+
 func (a *API) HandleCacheStats(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -804,11 +1251,20 @@ func (a *API) HandleCacheStats(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	resp := map[string]interface{}{
 		"count":      count,
 		"size_bytes": size,
 		"status":     "enabled",
-	})
+	}
+	if counted, ok := a.tokenCache.(interface {
+		Counters() (hits, misses, evictions uint64)
+	}); ok {
+		hits, misses, evictions := counted.Counters()
+		resp["hits"] = hits
+		resp["misses"] = misses
+		resp["evictions"] = evictions
+	}
+	json.NewEncoder(w).Encode(resp)
 }
 
 func (a *API) HandleCacheClear(w http.ResponseWriter, r *http.Request) {
@@ -830,6 +1286,71 @@ func (a *API) HandleCacheClear(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
+// HandleTokenCache 列出 Orchids 账号当前缓存的 JWT（已脱敏）及剩余 TTL
+func (a *API) HandleTokenCache(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	accounts, err := a.store.ListAccounts(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	entries := make([]map[string]interface{}, 0, len(accounts))
+	for _, acc := range accounts {
+		if strings.EqualFold(acc.AccountType, "warp") {
+			continue
+		}
+		info, ok := orchids.LookupCachedToken(acc.SessionID)
+		if !ok {
+			continue
+		}
+		entries = append(entries, map[string]interface{}{
+			"account_id":  acc.ID,
+			"name":        acc.Name,
+			"token":       info.Token,
+			"expires_at":  info.ExpiresAt,
+			"ttl_seconds": info.TTLSeconds,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"count":   len(entries),
+		"entries": entries,
+	})
+}
+
+// HandleTokenCacheByAccount 清除指定账号缓存的 JWT：DELETE /api/token-cache/{account_id}
+func (a *API) HandleTokenCacheByAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseInt(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/token-cache/"), "/"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	acc, err := a.store.GetAccount(r.Context(), id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	_, cached := orchids.LookupCachedToken(acc.SessionID)
+	orchids.InvalidateCachedToken(acc.SessionID)
+	slog.Info("Cached token invalidated", "account_id", acc.ID, "had_entry", cached)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"account_id":  acc.ID,
+		"invalidated": cached,
+	})
+}
+
 func (a *API) cacheTokenCountEnabled() bool {
 	a.configMu.RLock()
 	cfg, ok := a.config.(*config.Config)
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"orchids-api/internal/middleware"
+)
+
+func (a *API) SetCanary(s *middleware.CanarySplitter) {
+	a.canary = s
+}
+
+// HandleCanary 处理 /api/canary：GET 返回本实例与 canary 的请求数、状态码分布与耗时对比，
+// DELETE 清零统计（修改 canary_url 时也会自动清零）
+func (a *API) HandleCanary(w http.ResponseWriter, r *http.Request) {
+	if a.canary == nil {
+		http.Error(w, "Canary not configured", http.StatusServiceUnavailable)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodDelete:
+		a.canary.Reset()
+		slog.Info("Canary stats reset", "audit", "canary_stats_reset", "author", a.configAuthor(r))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(a.canary.Stats())
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"orchids-api/internal/config"
+)
+
+// channelModel 为模型在某个渠道下的可见性
+type channelModel struct {
+	ModelID string `json:"model_id"`
+	Channel string `json:"channel"`
+	Enabled bool   `json:"enabled"`
+	Allowed bool   `json:"allowed"`
+}
+
+// HandleChannelModels 处理 GET /api/channel-models：返回当前 channel_models 配置，以及按该配置
+// 各渠道（public / orchids / warp）的模型列表中每个模型是否可用。修改通过 POST /api/config 提交
+// {"channel_models": {...}}，立即生效
+func (a *API) HandleChannelModels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	models, err := a.store.ListModels(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	a.configMu.RLock()
+	cfg, _ := a.config.(*config.Config)
+	var policies map[string]config.ChannelModelPolicy
+	channels := make(map[string][]channelModel, len(config.ModelChannels))
+	if cfg != nil {
+		policies = cfg.ChannelModels
+	}
+	for _, channel := range config.ModelChannels {
+		list := []channelModel{}
+		for _, m := range models {
+			mChannel := strings.ToLower(strings.TrimSpace(m.Channel))
+			if mChannel == "" {
+				mChannel = config.ChannelOrchids
+			}
+			// 与 /v1/models 一致：public 列出全部渠道的模型，其他渠道只列出本渠道的模型
+			if channel != config.ChannelPublic && mChannel != channel {
+				continue
+			}
+			list = append(list, channelModel{
+				ModelID: m.ModelID,
+				Channel: mChannel,
+				Enabled: m.Status.Enabled(),
+				Allowed: cfg.ModelAllowed(channel, m.ModelID),
+			})
+		}
+		channels[channel] = list
+	}
+	a.configMu.RUnlock()
+
+	if policies == nil {
+		policies = map[string]config.ChannelModelPolicy{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"policies": policies,
+		"channels": channels,
+	})
+}
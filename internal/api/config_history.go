@@ -0,0 +1,175 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/store"
+)
+
+// configAuthor 返回本次修改配置的管理员标识：Basic Auth 用户名、会话登录的管理员或 admin token
+func (a *API) configAuthor(r *http.Request) string {
+	if user, _, ok := r.BasicAuth(); ok && user != "" {
+		return user
+	}
+	if _, err := r.Cookie("session_token"); err == nil {
+		return a.adminUser
+	}
+	return "admin-token"
+}
+
+// recordConfigVersion 在配置保存到 Redis 后追加一条历史记录；失败只记日志，不影响保存结果
+func (a *API) recordConfigVersion(r *http.Request, previous, current []byte, rollbackOf int64) {
+	keep := 0
+	a.configMu.RLock()
+	if cfg, ok := a.config.(*config.Config); ok {
+		keep = cfg.ConfigHistoryLimit
+	}
+	a.configMu.RUnlock()
+
+	v := &store.ConfigVersion{
+		Author:     a.configAuthor(r),
+		Changed:    changedConfigKeys(previous, current),
+		RollbackOf: rollbackOf,
+		Config:     string(current),
+	}
+	if err := a.store.AppendConfigVersion(r.Context(), v, keep); err != nil {
+		slog.Warn("Failed to record config history", "error", err)
+		return
+	}
+	slog.Info("Config saved", "audit", "config_update", "version", v.Version, "author", v.Author, "changed", v.Changed, "rollback_of", rollbackOf)
+}
+
+// changedConfigKeys 比较两份配置 JSON 的顶层字段，返回有变化的字段名（已排序）
+func changedConfigKeys(previous, current []byte) []string {
+	var before, after map[string]interface{}
+	if json.Unmarshal(previous, &before) != nil || json.Unmarshal(current, &after) != nil {
+		return nil
+	}
+	var changed []string
+	for key, value := range after {
+		if !reflect.DeepEqual(before[key], value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// HandleConfigHistory 处理 /api/config/history：列出保留的配置版本（不含配置内容），
+// ?version= 返回指定版本的完整快照
+func (a *API) HandleConfigHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	if raw := strings.TrimSpace(r.URL.Query().Get("version")); raw != "" {
+		version, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			http.Error(w, "Invalid version", http.StatusBadRequest)
+			return
+		}
+		v, err := a.store.GetConfigVersion(r.Context(), version)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if v == nil {
+			http.Error(w, "Config version not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(v)
+		return
+	}
+
+	versions, err := a.store.ListConfigVersions(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	summaries := make([]store.ConfigVersion, 0, len(versions))
+	for _, v := range versions {
+		item := *v
+		item.Config = ""
+		summaries = append(summaries, item)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"versions": summaries})
+}
+
+// HandleConfigRollback 处理 /api/config/rollback/{version}：将运行中的配置恢复为指定历史版本，
+// 回滚本身也会记录为一个新版本
+func (a *API) HandleConfigRollback(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	version, err := strconv.ParseInt(strings.TrimPrefix(r.URL.Path, "/api/config/rollback/"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid version", http.StatusBadRequest)
+		return
+	}
+	v, err := a.store.GetConfigVersion(r.Context(), version)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if v == nil {
+		http.Error(w, "Config version not found", http.StatusNotFound)
+		return
+	}
+
+	var candidate config.Config
+	if _, err := config.UnmarshalMigrated([]byte(v.Config), &candidate); err != nil {
+		http.Error(w, "Stored config version is invalid: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := candidate.ResolveSecrets(r.Context()); err != nil {
+		http.Error(w, "Failed to resolve config secrets: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if errs := validateConfigUpdate(&candidate); len(errs) > 0 {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(configValidateResponse{SchemaVersion: config.CurrentSchemaVersion, Errors: errs})
+		return
+	}
+
+	a.configMu.Lock()
+	current, ok := a.config.(*config.Config)
+	if !ok {
+		a.configMu.Unlock()
+		http.Error(w, "Config not available", http.StatusInternalServerError)
+		return
+	}
+	previous, _ := json.Marshal(current)
+	*current = candidate
+	data, err := json.Marshal(current)
+	a.configMu.Unlock()
+	if err != nil {
+		http.Error(w, "Failed to marshal config: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := a.store.SetSetting(r.Context(), "config", string(data)); err != nil {
+		http.Error(w, "Failed to save config to Redis: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	a.recordConfigVersion(r, previous, data, version)
+
+	a.configMu.RLock()
+	json.NewEncoder(w).Encode(a.config)
+	a.configMu.RUnlock()
+}
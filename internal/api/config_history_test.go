@@ -0,0 +1,57 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/store"
+)
+
+func TestConfigRollback_RejectsInvalidSnapshot(t *testing.T) {
+	a, _ := newTestAPI(t)
+	cfg := &config.Config{}
+	config.ApplyDefaults(cfg)
+	cfg.RedisAddr = "127.0.0.1:6379"
+	cfg.SummaryCacheRedisAddr = "127.0.0.1:6379"
+	a.config = cfg
+	ctx := context.Background()
+
+	valid := `{"schema_version":1,"redis_addr":"127.0.0.1:6379","summary_cache_redis_addr":"127.0.0.1:6379","port":"4000"}`
+	invalid := `{"schema_version":1,"redis_addr":"127.0.0.1:6379","summary_cache_redis_addr":"127.0.0.1:6379","upstream_mode":"bogus"}`
+	for _, snapshot := range []string{valid, invalid} {
+		if err := a.store.AppendConfigVersion(ctx, &store.ConfigVersion{Author: "admin", Config: snapshot}, 0); err != nil {
+			t.Fatalf("AppendConfigVersion: %v", err)
+		}
+	}
+
+	rec := serve(a.HandleConfigRollback, http.MethodPost, "/api/config/rollback/2", nil)
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), "upstream_mode") {
+		t.Fatalf("invalid rollback status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if cfg.UpstreamMode != "sse" {
+		t.Fatalf("rejected rollback changed upstream_mode to %q", cfg.UpstreamMode)
+	}
+	if saved, _ := a.store.GetSetting(ctx, "config"); saved != "" {
+		t.Fatalf("rejected rollback saved config: %s", saved)
+	}
+
+	rec = serve(a.HandleConfigRollback, http.MethodPost, "/api/config/rollback/99", nil)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("missing version status = %d", rec.Code)
+	}
+
+	rec = serve(a.HandleConfigRollback, http.MethodPost, "/api/config/rollback/1", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rollback status = %d: %s", rec.Code, rec.Body.String())
+	}
+	if cfg.Port != "4000" {
+		t.Fatalf("port = %q after rollback", cfg.Port)
+	}
+	versions, _ := a.store.ListConfigVersions(ctx)
+	if len(versions) != 3 || versions[0].RollbackOf != 1 {
+		t.Fatalf("versions after rollback: %d, latest %+v", len(versions), versions[0])
+	}
+}
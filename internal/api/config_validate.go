@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/store"
+)
+
+// maxConfigBodyBytes 限制提交的配置 JSON 大小
+const maxConfigBodyBytes = 1 << 20
+
+type configValidateResponse struct {
+	Valid         bool                `json:"valid"`
+	SchemaVersion int                 `json:"schema_version"`
+	MigratedFrom  int                 `json:"migrated_from"`
+	Errors        []config.FieldError `json:"errors"`
+}
+
+// HandleConfigValidate 处理 /api/config/validate：对候选配置执行迁移、类型与取值校验，
+// 并检查 Redis 是否可达；不会应用配置
+func (a *API) HandleConfigValidate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxConfigBodyBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := configValidateResponse{SchemaVersion: config.CurrentSchemaVersion}
+	cfg, from, errs := config.DecodeCandidate(data)
+	resp.MigratedFrom = from
+	if cfg != nil {
+		config.ApplyDefaults(cfg)
+		errs = append(errs, config.Validate(cfg)...)
+		if cfg.RedisAddr != "" {
+			if err := store.PingRedis(r.Context(), cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB); err != nil {
+				errs = append(errs, config.FieldError{Field: "redis_addr", Message: err.Error()})
+			}
+		}
+	}
+	if errs == nil {
+		errs = []config.FieldError{}
+	}
+	resp.Errors = errs
+	resp.Valid = len(errs) == 0
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// validateConfigUpdate 校验合并后的配置；返回的错误用于拒绝 POST /api/config
+func validateConfigUpdate(cfg *config.Config) []config.FieldError {
+	candidate := *cfg
+	config.ApplyDefaults(&candidate)
+	return config.Validate(&candidate)
+}
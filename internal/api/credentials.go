@@ -0,0 +1,89 @@
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"orchids-api/internal/auth"
+	"orchids-api/internal/middleware"
+	"orchids-api/internal/store"
+)
+
+// accountCredentials 为账号保存的凭据，GET 时各字段为脱敏值
+type accountCredentials struct {
+	AccountID     int64  `json:"account_id"`
+	AccountType   string `json:"account_type"`
+	ClientCookie  string `json:"client_cookie,omitempty"`
+	SessionCookie string `json:"session_cookie,omitempty"`
+	RefreshToken  string `json:"refresh_token,omitempty"`
+	Token         string `json:"token,omitempty"`
+	Masked        bool   `json:"masked"`
+}
+
+func credentialsOf(acc *store.Account, mask bool) accountCredentials {
+	acc = normalizeWarpTokenOutput(acc)
+	c := accountCredentials{
+		AccountID:     acc.ID,
+		AccountType:   acc.AccountType,
+		ClientCookie:  acc.ClientCookie,
+		SessionCookie: acc.SessionCookie,
+		RefreshToken:  acc.RefreshToken,
+		Token:         acc.Token,
+		Masked:        mask,
+	}
+	if mask {
+		c.ClientCookie = auth.MaskSensitive(c.ClientCookie)
+		c.SessionCookie = auth.MaskSensitive(c.SessionCookie)
+		c.RefreshToken = auth.MaskSensitive(c.RefreshToken)
+		c.Token = auth.MaskSensitive(c.Token)
+	}
+	return c
+}
+
+// handleAccountCredentials 处理 /api/accounts/{id}/credentials：GET 返回脱敏后的凭据；
+// POST 需在请求体中再次提供管理员密码，通过后返回完整凭据，成功与失败都记录审计日志
+func (a *API) handleAccountCredentials(w http.ResponseWriter, r *http.Request, id int64) {
+	w.Header().Set("Cache-Control", "no-store")
+
+	switch r.Method {
+	case http.MethodGet:
+		acc, err := a.store.GetAccount(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(credentialsOf(acc, true))
+
+	case http.MethodPost:
+		var req struct {
+			Password string `json:"password"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		author := a.configAuthor(r)
+		// admin token 调用方同样要求密码：token 可能长期保存在脚本中，查看凭据需要操作者本人确认
+		if a.adminPass == "" {
+			http.Error(w, "Re-authentication unavailable: admin password is not configured", http.StatusForbidden)
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(req.Password), []byte(a.adminPass)) != 1 {
+			slog.Warn("Account credential reveal denied", "audit", "account_credential_reveal_denied", "account_id", id, "author", author, "client_ip", middleware.ClientIP(r))
+			http.Error(w, "Re-authentication failed", http.StatusUnauthorized)
+			return
+		}
+		acc, err := a.store.GetAccount(r.Context(), id)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		slog.Info("Account credential revealed", "audit", "account_credential_reveal", "account_id", id, "account_type", acc.AccountType, "author", author, "client_ip", middleware.ClientIP(r))
+		json.NewEncoder(w).Encode(credentialsOf(acc, false))
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
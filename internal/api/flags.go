@@ -0,0 +1,77 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"orchids-api/internal/flags"
+)
+
+func (a *API) SetFlags(m *flags.Manager) {
+	a.flags = m
+}
+
+// HandleFlags 处理 /api/flags：GET 列出已配置与代码中使用的开关，POST 新增或替换一个开关（立即生效并通知其他副本）
+func (a *API) HandleFlags(w http.ResponseWriter, r *http.Request) {
+	if a.flags == nil {
+		http.Error(w, "Flags not configured", http.StatusServiceUnavailable)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	switch r.Method {
+	case http.MethodGet:
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"flags": a.flags.List(),
+			"known": flags.Known,
+		})
+	case http.MethodPost:
+		var f flags.Flag
+		if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.Name = strings.TrimSpace(f.Name)
+		f.UpdatedAt = time.Now()
+		f.UpdatedBy = a.configAuthor(r)
+		if err := f.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := a.flags.Set(r.Context(), f); err != nil {
+			http.Error(w, "Failed to save flag: "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		slog.Info("Feature flag updated", "audit", "flag_update", "flag", f.Name, "enabled", f.Enabled, "rollout", f.Rollout, "keys", len(f.Keys), "author", f.UpdatedBy)
+		json.NewEncoder(w).Encode(f)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// HandleFlagByName 处理 /api/flags/{name}：DELETE 删除开关，之后恢复代码默认行为
+func (a *API) HandleFlagByName(w http.ResponseWriter, r *http.Request) {
+	if a.flags == nil {
+		http.Error(w, "Flags not configured", http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	name := strings.TrimSpace(strings.TrimPrefix(r.URL.Path, "/api/flags/"))
+	found, err := a.flags.Delete(r.Context(), name)
+	if err != nil {
+		http.Error(w, "Failed to delete flag: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !found {
+		http.Error(w, "Flag not found", http.StatusNotFound)
+		return
+	}
+	slog.Info("Feature flag deleted", "audit", "flag_delete", "flag", name, "author", a.configAuthor(r))
+	w.WriteHeader(http.StatusNoContent)
+}
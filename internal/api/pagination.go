@@ -0,0 +1,285 @@
+package api
+
+import (
+	"cmp"
+	"errors"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"orchids-api/internal/store"
+)
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 500
+)
+
+// listQuery 为列表接口的分页、排序、搜索与状态筛选参数
+type listQuery struct {
+	Page     int
+	PageSize int
+	Sort     string
+	Desc     bool
+	Search   string
+	Status   string
+}
+
+// parseListQuery 解析 ?page=&page_size=&sort=&q=&status=（以及由调用方处理的 type），sort 以 "-" 开头表示降序。
+// 未携带任何参数时 paged 为 false，调用方返回完整数组以兼容旧客户端
+func parseListQuery(r *http.Request, sortFields []string, defaultSort string) (q listQuery, paged bool, err error) {
+	values := r.URL.Query()
+	for _, name := range []string{"page", "page_size", "sort", "q", "status", "type"} {
+		if values.Has(name) {
+			paged = true
+		}
+	}
+
+	q = listQuery{Page: 1, PageSize: defaultPageSize}
+	if raw := values.Get("page"); raw != "" {
+		if q.Page, err = strconv.Atoi(raw); err != nil || q.Page < 1 {
+			return q, paged, errors.New("invalid page")
+		}
+	}
+	if raw := values.Get("page_size"); raw != "" {
+		if q.PageSize, err = strconv.Atoi(raw); err != nil || q.PageSize < 1 || q.PageSize > maxPageSize {
+			return q, paged, errors.New("invalid page_size (1-" + strconv.Itoa(maxPageSize) + ")")
+		}
+	}
+
+	sortBy := strings.TrimSpace(values.Get("sort"))
+	if sortBy == "" {
+		sortBy = defaultSort
+	}
+	q.Sort, q.Desc = strings.CutPrefix(sortBy, "-")
+	if !slices.Contains(sortFields, q.Sort) {
+		return q, paged, errors.New("invalid sort (want one of " + strings.Join(sortFields, ", ") + ", optionally prefixed with -)")
+	}
+	q.Search = strings.ToLower(strings.TrimSpace(values.Get("q")))
+	q.Status = strings.ToLower(strings.TrimSpace(values.Get("status")))
+	return q, paged, nil
+}
+
+// less 按排序方向包装比较结果：cmp < 0 表示 a 排在 b 前（升序）
+func (q listQuery) less(cmp int) bool {
+	if q.Desc {
+		return cmp > 0
+	}
+	return cmp < 0
+}
+
+// bounds 返回当前页在 total 条结果中的下标范围，页码超出时返回空页
+func (q listQuery) bounds(total int) (start, end int) {
+	// 先比较页码再相乘，过大的 page 相乘会溢出为负数
+	if q.Page < 1 || q.Page-1 > total/q.PageSize {
+		return total, total
+	}
+	start = min((q.Page-1)*q.PageSize, total)
+	end = min(start+q.PageSize, total)
+	return start, end
+}
+
+// matches 报告 fields 中是否有包含搜索词的字段（不区分大小写），未搜索时总是 true
+func (q listQuery) matches(fields ...string) bool {
+	if q.Search == "" {
+		return true
+	}
+	for _, f := range fields {
+		if strings.Contains(strings.ToLower(f), q.Search) {
+			return true
+		}
+	}
+	return false
+}
+
+// pageResponse 为分页列表的响应，Summary 为不受筛选影响的整体统计（可选）
+type pageResponse struct {
+	Items      interface{} `json:"items"`
+	Total      int         `json:"total"`
+	Page       int         `json:"page"`
+	PageSize   int         `json:"page_size"`
+	TotalPages int         `json:"total_pages"`
+	Summary    interface{} `json:"summary,omitempty"`
+}
+
+func newPageResponse(q listQuery, items interface{}, total int) pageResponse {
+	pages := (total + q.PageSize - 1) / q.PageSize
+	return pageResponse{Items: items, Total: total, Page: q.Page, PageSize: q.PageSize, TotalPages: max(pages, 1)}
+}
+
+func compareStrings(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+func compareBools(a, b bool) int {
+	switch {
+	case a == b:
+		return 0
+	case !a:
+		return -1
+	}
+	return 1
+}
+
+var accountSortFields = []string{"id", "name", "account_type", "enabled", "status_code", "weight", "request_count", "usage_daily", "usage_current", "last_used_at", "created_at"}
+
+// accountListSummary 为全部账号（不受筛选影响）的统计，供管理界面的统计卡片与渠道标签使用
+type accountListSummary struct {
+	Total      int            `json:"total"`
+	Enabled    int            `json:"enabled"`
+	Abnormal   int            `json:"abnormal"`
+	UsageDaily float64        `json:"usage_daily"`
+	ByType     map[string]int `json:"by_type"`
+}
+
+func accountAbnormal(acc *store.Account) bool {
+	return !acc.Enabled || acc.StatusCode != ""
+}
+
+// pageAccounts 按 ?type=&status=enabled|disabled|abnormal|normal&q=&sort=&page=&page_size= 筛选、排序并分页
+func pageAccounts(accounts []*store.Account, q listQuery, accountType string) (pageResponse, error) {
+	summary := accountListSummary{ByType: map[string]int{}}
+	filtered := make([]*store.Account, 0, len(accounts))
+	for _, acc := range accounts {
+		typ := strings.ToLower(acc.AccountType)
+		if typ == "" {
+			typ = "orchids"
+		}
+		summary.Total++
+		summary.ByType[typ]++
+		summary.UsageDaily += acc.UsageDaily
+		if acc.Enabled {
+			summary.Enabled++
+		}
+		if accountAbnormal(acc) {
+			summary.Abnormal++
+		}
+
+		if accountType != "" && !strings.EqualFold(typ, accountType) {
+			continue
+		}
+		switch q.Status {
+		case "":
+		case "enabled":
+			if !acc.Enabled {
+				continue
+			}
+		case "disabled":
+			if acc.Enabled {
+				continue
+			}
+		case "abnormal":
+			if !accountAbnormal(acc) {
+				continue
+			}
+		case "normal":
+			if accountAbnormal(acc) {
+				continue
+			}
+		default:
+			return pageResponse{}, errors.New("invalid status (want enabled, disabled, abnormal or normal)")
+		}
+		if !q.matches(acc.Name, acc.Email, strconv.FormatInt(acc.ID, 10), acc.StatusCode, acc.Subscription) {
+			continue
+		}
+		filtered = append(filtered, acc)
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		a, b := filtered[i], filtered[j]
+		var c int
+		switch q.Sort {
+		case "name":
+			c = compareStrings(a.Name, b.Name)
+		case "account_type":
+			c = compareStrings(a.AccountType, b.AccountType)
+		case "enabled":
+			c = compareBools(a.Enabled, b.Enabled)
+		case "status_code":
+			c = strings.Compare(a.StatusCode, b.StatusCode)
+		case "weight":
+			c = cmp.Compare(a.Weight, b.Weight)
+		case "request_count":
+			c = cmp.Compare(a.RequestCount, b.RequestCount)
+		case "usage_daily":
+			c = cmp.Compare(a.UsageDaily, b.UsageDaily)
+		case "usage_current":
+			c = cmp.Compare(a.UsageCurrent, b.UsageCurrent)
+		case "last_used_at":
+			c = a.LastUsedAt.Compare(b.LastUsedAt)
+		case "created_at":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		}
+		if c == 0 {
+			c = cmp.Compare(a.ID, b.ID)
+		}
+		return q.less(c)
+	})
+
+	start, end := q.bounds(len(filtered))
+	items := make([]*store.Account, 0, end-start)
+	for _, acc := range filtered[start:end] {
+		items = append(items, accountOutput(acc))
+	}
+	resp := newPageResponse(q, items, len(filtered))
+	resp.Summary = summary
+	return resp, nil
+}
+
+var keySortFields = []string{"id", "name", "enabled", "created_at", "last_used_at"}
+
+// pageKeys 按 ?status=enabled|disabled&q=&sort=&page=&page_size= 筛选、排序并分页
+func pageKeys(keys []*store.ApiKey, q listQuery) (pageResponse, error) {
+	filtered := make([]*store.ApiKey, 0, len(keys))
+	for _, k := range keys {
+		switch q.Status {
+		case "":
+		case "enabled":
+			if !k.Enabled {
+				continue
+			}
+		case "disabled":
+			if k.Enabled {
+				continue
+			}
+		default:
+			return pageResponse{}, errors.New("invalid status (want enabled or disabled)")
+		}
+		if !q.matches(k.Name, k.KeySuffix, k.KeyID, strconv.FormatInt(k.ID, 10)) {
+			continue
+		}
+		filtered = append(filtered, k)
+	}
+
+	sort.SliceStable(filtered, func(i, j int) bool {
+		a, b := filtered[i], filtered[j]
+		var c int
+		switch q.Sort {
+		case "name":
+			c = compareStrings(a.Name, b.Name)
+		case "enabled":
+			c = compareBools(a.Enabled, b.Enabled)
+		case "created_at":
+			c = a.CreatedAt.Compare(b.CreatedAt)
+		case "last_used_at":
+			var at, bt time.Time
+			if a.LastUsedAt != nil {
+				at = *a.LastUsedAt
+			}
+			if b.LastUsedAt != nil {
+				bt = *b.LastUsedAt
+			}
+			c = at.Compare(bt)
+		}
+		if c == 0 {
+			c = cmp.Compare(a.ID, b.ID)
+		}
+		return q.less(c)
+	})
+
+	start, end := q.bounds(len(filtered))
+	return newPageResponse(q, filtered[start:end], len(filtered)), nil
+}
@@ -0,0 +1,112 @@
+package api
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"orchids-api/internal/store"
+)
+
+func TestParseListQuery(t *testing.T) {
+	r := httptest.NewRequest("GET", "/api/accounts", nil)
+	if _, paged, err := parseListQuery(r, accountSortFields, "id"); err != nil || paged {
+		t.Fatalf("no params: paged=%v err=%v", paged, err)
+	}
+
+	r = httptest.NewRequest("GET", "/api/accounts?page=2&page_size=5&sort=-weight&q=Foo&status=Enabled", nil)
+	q, paged, err := parseListQuery(r, accountSortFields, "id")
+	if err != nil || !paged {
+		t.Fatalf("paged=%v err=%v", paged, err)
+	}
+	if q.Page != 2 || q.PageSize != 5 || q.Sort != "weight" || !q.Desc || q.Search != "foo" || q.Status != "enabled" {
+		t.Fatalf("q = %+v", q)
+	}
+
+	for _, raw := range []string{"page=0", "page=-1", "page=x", "page_size=0", "page_size=501", "sort=bogus"} {
+		r := httptest.NewRequest("GET", "/api/accounts?"+raw, nil)
+		if _, _, err := parseListQuery(r, accountSortFields, "id"); err == nil {
+			t.Fatalf("%s: expected error", raw)
+		}
+	}
+}
+
+func TestListQueryBounds(t *testing.T) {
+	cases := []struct {
+		page, size, total int
+		start, end        int
+	}{
+		{1, 20, 0, 0, 0},
+		{1, 20, 45, 0, 20},
+		{3, 20, 45, 40, 45},
+		{4, 20, 45, 45, 45},
+		{922337203685477581, 20, 45, 45, 45},
+		{int(^uint(0) >> 1), 500, 3, 3, 3},
+	}
+	for _, tc := range cases {
+		q := listQuery{Page: tc.page, PageSize: tc.size}
+		if start, end := q.bounds(tc.total); start != tc.start || end != tc.end {
+			t.Fatalf("page=%d size=%d total=%d: bounds = (%d, %d), want (%d, %d)", tc.page, tc.size, tc.total, start, end, tc.start, tc.end)
+		}
+	}
+}
+
+func TestPageAccounts(t *testing.T) {
+	accounts := []*store.Account{
+		{ID: 1, Name: "alpha", AccountType: "orchids", Enabled: true, Weight: 3},
+		{ID: 2, Name: "beta", AccountType: "warp", Enabled: false, Weight: 1},
+		{ID: 3, Name: "gamma", AccountType: "orchids", Enabled: true, StatusCode: "429", Weight: 2},
+	}
+
+	resp, err := pageAccounts(accounts, listQuery{Page: 1, PageSize: 2, Sort: "weight", Desc: true}, "")
+	if err != nil {
+		t.Fatalf("pageAccounts: %v", err)
+	}
+	items := resp.Items.([]*store.Account)
+	if len(items) != 2 || items[0].ID != 1 || items[1].ID != 3 || resp.Total != 3 || resp.TotalPages != 2 {
+		t.Fatalf("resp = %+v", resp)
+	}
+	summary := resp.Summary.(accountListSummary)
+	if summary.Total != 3 || summary.Enabled != 2 || summary.Abnormal != 2 || summary.ByType["warp"] != 1 {
+		t.Fatalf("summary = %+v", summary)
+	}
+
+	resp, _ = pageAccounts(accounts, listQuery{Page: 1, PageSize: 20, Sort: "id", Status: "normal"}, "orchids")
+	if items := resp.Items.([]*store.Account); len(items) != 1 || items[0].ID != 1 {
+		t.Fatalf("filtered items = %+v", resp.Items)
+	}
+
+	resp, err = pageAccounts(accounts, listQuery{Page: 922337203685477581, PageSize: 20, Sort: "id"}, "")
+	if err != nil || len(resp.Items.([]*store.Account)) != 0 || resp.Total != 3 {
+		t.Fatalf("out-of-range page: resp=%+v err=%v", resp, err)
+	}
+
+	if _, err := pageAccounts(accounts, listQuery{Page: 1, PageSize: 20, Sort: "id", Status: "bogus"}, ""); err == nil {
+		t.Fatal("invalid status accepted")
+	}
+}
+
+func TestPageKeys(t *testing.T) {
+	keys := []*store.ApiKey{
+		{ID: 1, Name: "ci", KeyID: "aaaaaaaaaaaa", Enabled: true},
+		{ID: 2, Name: "Dev", KeyID: "bbbbbbbbbbbb", Enabled: false},
+		{ID: 3, Name: "prod", KeyID: "cccccccccccc", Enabled: true},
+	}
+
+	resp, err := pageKeys(keys, listQuery{Page: 1, PageSize: 20, Sort: "name", Desc: true, Status: "enabled"})
+	if err != nil {
+		t.Fatalf("pageKeys: %v", err)
+	}
+	if items := resp.Items.([]*store.ApiKey); len(items) != 2 || items[0].ID != 3 || items[1].ID != 1 {
+		t.Fatalf("items = %+v", resp.Items)
+	}
+
+	resp, _ = pageKeys(keys, listQuery{Page: 1, PageSize: 20, Sort: "id", Search: "dev"})
+	if items := resp.Items.([]*store.ApiKey); len(items) != 1 || items[0].ID != 2 {
+		t.Fatalf("search items = %+v", resp.Items)
+	}
+
+	resp, err = pageKeys(keys, listQuery{Page: 922337203685477581, PageSize: 20, Sort: "id"})
+	if err != nil || len(resp.Items.([]*store.ApiKey)) != 0 {
+		t.Fatalf("out-of-range page: resp=%+v err=%v", resp, err)
+	}
+}
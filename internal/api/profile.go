@@ -0,0 +1,113 @@
+package api
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"runtime/pprof"
+	"strconv"
+	"time"
+)
+
+// 性能剖析的默认与最大采样时长
+const (
+	defaultProfileSeconds = 30
+	maxProfileSeconds     = 120
+)
+
+// profileMinInterval 为两次采集之间的最短间隔；CPU 采样本身有开销，避免被反复触发
+const profileMinInterval = time.Minute
+
+// HandleProfile 处理 /api/profile：采集 ?seconds=（默认 30 秒）的 CPU profile，
+// 结束时附带 heap、allocs 与 goroutine 快照打包为 zip，可用 go tool pprof 离线分析。
+// 同一时刻只允许一次采集，两次采集之间至少间隔 profileMinInterval
+func (a *API) HandleProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	seconds := defaultProfileSeconds
+	if raw := r.URL.Query().Get("seconds"); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 1 || n > maxProfileSeconds {
+			http.Error(w, "seconds must be between 1 and "+strconv.Itoa(maxProfileSeconds), http.StatusBadRequest)
+			return
+		}
+		seconds = n
+	}
+
+	if !a.profileMu.TryLock() {
+		http.Error(w, "A profile capture is already running", http.StatusConflict)
+		return
+	}
+	defer a.profileMu.Unlock()
+	if wait := profileMinInterval - time.Since(a.lastProfileAt); wait > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		http.Error(w, "Profile captured recently, retry later", http.StatusTooManyRequests)
+		return
+	}
+
+	var cpu bytes.Buffer
+	if err := pprof.StartCPUProfile(&cpu); err != nil {
+		// 通常是 /debug/pprof/profile 正在采样
+		http.Error(w, "Failed to start CPU profile: "+err.Error(), http.StatusConflict)
+		return
+	}
+	a.lastProfileAt = time.Now()
+	author := a.configAuthor(r)
+	slog.Info("Profile capture started", "audit", "profile_capture", "seconds", seconds, "author", author)
+
+	timer := time.NewTimer(time.Duration(seconds) * time.Second)
+	select {
+	case <-timer.C:
+	case <-r.Context().Done():
+		timer.Stop()
+		pprof.StopCPUProfile()
+		slog.Info("Profile capture canceled", "author", author)
+		return
+	}
+	pprof.StopCPUProfile()
+
+	files := []struct {
+		name  string
+		write func(*bytes.Buffer) error
+	}{
+		{"cpu.pprof", func(b *bytes.Buffer) error { _, err := b.Write(cpu.Bytes()); return err }},
+		{"heap.pprof", func(b *bytes.Buffer) error { return pprof.Lookup("heap").WriteTo(b, 0) }},
+		{"allocs.pprof", func(b *bytes.Buffer) error { return pprof.Lookup("allocs").WriteTo(b, 0) }},
+		{"goroutine.pprof", func(b *bytes.Buffer) error { return pprof.Lookup("goroutine").WriteTo(b, 0) }},
+		// 可直接阅读的完整调用栈
+		{"goroutines.txt", func(b *bytes.Buffer) error { return pprof.Lookup("goroutine").WriteTo(b, 2) }},
+		{"version.json", func(b *bytes.Buffer) error {
+			enc := json.NewEncoder(b)
+			enc.SetIndent("", "  ")
+			return enc.Encode(versionInfo())
+		}},
+	}
+
+	name := "profile-" + time.Now().UTC().Format("20060102-150405") + ".zip"
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+name+`"`)
+
+	zw := zip.NewWriter(w)
+	var buf bytes.Buffer
+	for _, f := range files {
+		buf.Reset()
+		if err := f.write(&buf); err != nil {
+			slog.Warn("Profile bundle write failed", "file", f.name, "error", err)
+			continue
+		}
+		fw, err := zw.Create(f.name)
+		if err != nil {
+			slog.Warn("Profile bundle write failed", "file", f.name, "error", err)
+			return
+		}
+		fw.Write(buf.Bytes())
+	}
+	if err := zw.Close(); err != nil {
+		slog.Warn("Profile bundle close failed", "error", err)
+	}
+	slog.Info("Profile bundle generated", "audit", "profile_capture", "seconds", seconds, "cpu_bytes", cpu.Len(), "author", author)
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+
+	"orchids-api/internal/report"
+)
+
+func (a *API) SetReporter(r *report.Reporter) {
+	a.reporter = r
+}
+
+// HandleReports 处理 /api/reports：GET 预览当前周期的报表（?period=daily|weekly，?format=text 返回邮件正文），
+// POST 立即发送并开始新的周期
+func (a *API) HandleReports(w http.ResponseWriter, r *http.Request) {
+	if a.reporter == nil {
+		http.Error(w, "Reports not configured", http.StatusServiceUnavailable)
+		return
+	}
+	period := r.URL.Query().Get("period")
+	switch period {
+	case "":
+		period = report.Daily
+	case report.Daily, report.Weekly:
+	default:
+		http.Error(w, "Invalid period", http.StatusBadRequest)
+		return
+	}
+
+	var summary report.Summary
+	switch r.Method {
+	case http.MethodGet:
+		summary = a.reporter.Build(r.Context(), period)
+	case http.MethodPost:
+		var err error
+		summary, err = a.reporter.Send(r.Context(), period)
+		if err != nil {
+			http.Error(w, "Failed to send report: "+err.Error(), http.StatusBadGateway)
+			return
+		}
+		slog.Info("Report sent manually", "audit", "report_send", "period", period, "author", a.configAuthor(r))
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if r.URL.Query().Get("format") == "text" {
+		subject, body := report.Render(summary)
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte("Subject: " + subject + "\n\n" + body))
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
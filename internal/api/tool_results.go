@@ -0,0 +1,37 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"orchids-api/internal/toolspill"
+)
+
+func (a *API) SetToolSpill(s *toolspill.Store) {
+	a.toolSpill = s
+}
+
+// HandleToolResultByID 处理 GET /api/tool-results/{id}：返回 prompt 中被替换为摘录的 tool_result 原文
+func (a *API) HandleToolResultByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if a.toolSpill == nil {
+		http.Error(w, "Tool result storage not configured", http.StatusNotFound)
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/tool-results/")
+	data, err := a.toolSpill.Get(id)
+	if errors.Is(err, toolspill.ErrNotFound) {
+		http.Error(w, "Tool result not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(data)
+}
@@ -1,7 +1,9 @@
 package auth
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"sync"
@@ -83,6 +85,31 @@ func CleanupExpiredSessions() {
 	}
 }
 
+// csrfKey 为进程内随机密钥；会话本身也只保存在进程内，重启后两者一起失效
+var csrfKey = func() []byte {
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		panic(fmt.Sprintf("failed to generate csrf key: %v", err))
+	}
+	return key
+}()
+
+// CSRFToken 返回与会话绑定的 CSRF token（会话 token 的 HMAC），同一会话内保持不变，
+// 无需额外存储；管理页面渲染时注入，前端在写操作的 X-CSRF-Token 请求头中回传
+func CSRFToken(sessionToken string) string {
+	mac := hmac.New(sha256.New, csrfKey)
+	mac.Write([]byte(sessionToken))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidateCSRFToken 报告 token 是否属于该会话
+func ValidateCSRFToken(sessionToken, token string) bool {
+	if sessionToken == "" || token == "" {
+		return false
+	}
+	return hmac.Equal([]byte(CSRFToken(sessionToken)), []byte(token))
+}
+
 func MaskSensitive(value string) string {
 	if value == "" {
 		return ""
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"path"
+	"strings"
+)
+
+// 模型范围按渠道配置：orchids 对应 /orchids/v1/...，warp 对应 /warp/v1/...，
+// public 对应不带渠道前缀的 /v1/...
+const (
+	ChannelPublic  = "public"
+	ChannelOrchids = "orchids"
+	ChannelWarp    = "warp"
+)
+
+// ModelChannels 为可配置的全部渠道
+var ModelChannels = []string{ChannelPublic, ChannelOrchids, ChannelWarp}
+
+// ChannelModelPolicy 为一个渠道对外暴露的模型范围。模式为 path.Match 通配（如 "claude-*"），不区分大小写；
+// Deny 优先于 Allow，Allow 为空表示除 Deny 外全部允许
+type ChannelModelPolicy struct {
+	Allow []string `json:"allow,omitempty"`
+	Deny  []string `json:"deny,omitempty"`
+}
+
+// Allows 报告模型是否在该策略允许的范围内
+func (p ChannelModelPolicy) Allows(model string) bool {
+	model = strings.ToLower(strings.TrimSpace(model))
+	if matchModel(p.Deny, model) {
+		return false
+	}
+	return len(p.Allow) == 0 || matchModel(p.Allow, model)
+}
+
+func matchModel(patterns []string, model string) bool {
+	for _, p := range patterns {
+		if ok, _ := path.Match(strings.ToLower(strings.TrimSpace(p)), model); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// PrefixChannels 为可以配置路径前缀的渠道（public 渠道没有前缀）
+var PrefixChannels = []string{ChannelOrchids, ChannelWarp}
+
+// reservedPrefixes 为已被其他路由占用、不能作为渠道前缀的路径
+var reservedPrefixes = []string{"/v1", "/api", "/health", "/metrics", "/version", "/debug"}
+
+// ModelAllowed 报告 channel（空字符串视为 public）是否允许使用 model，未配置该渠道时总是允许
+func (c *Config) ModelAllowed(channel, model string) bool {
+	if c == nil || len(c.ChannelModels) == 0 {
+		return true
+	}
+	if channel == "" {
+		channel = ChannelPublic
+	}
+	policy, ok := c.ChannelModels[strings.ToLower(channel)]
+	return !ok || policy.Allows(model)
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -13,8 +14,17 @@ import (
 	"strings"
 )
 
+// DefaultMaxRequestBytes 为未配置 max_request_bytes 时的请求体上限
+const DefaultMaxRequestBytes = 50 * 1024 * 1024 // 50MB
+
 type Config struct {
+	SchemaVersion int `json:"schema_version"`
+
 	Port                      string   `json:"port"`
+	UnixSocket                string   `json:"unix_socket"`       // 额外监听的 Unix domain socket 路径，为空不监听
+	UnixSocketMode            string   `json:"unix_socket_mode"`  // socket 文件权限（八进制），默认 0660
+	SystemdSocket             bool     `json:"systemd_socket"`    // 使用 systemd socket 激活传入的监听代替 TCP 端口
+	AdminListenAddr           string   `json:"admin_listen_addr"` // 管理路由的独立监听地址（如 127.0.0.1:3003），为空时与 API 共用端口
 	DebugEnabled              bool     `json:"debug_enabled"`
 	SessionID                 string   `json:"session_id"`
 	ClientCookie              string   `json:"client_cookie"`
@@ -27,6 +37,7 @@ type Config struct {
 	AdminUser                 string   `json:"admin_user"`
 	AdminPass                 string   `json:"admin_pass"`
 	AdminPath                 string   `json:"admin_path"`
+	BasePath                  string   `json:"base_path"` // 反向代理路径前缀（如 /ai-gateway），需重启生效
 	DebugLogSSE               bool     `json:"debug_log_sse"`
 	SuppressThinking          bool     `json:"suppress_thinking"`
 	OutputTokenMode           string   `json:"output_token_mode"`
@@ -80,7 +91,83 @@ type Config struct {
 	LoadBalancerCacheTTL int    `json:"load_balancer_cache_ttl"`
 	ConcurrencyLimit     int    `json:"concurrency_limit"`
 	ConcurrencyTimeout   int    `json:"concurrency_timeout"`
+	AccountQueueTimeout  int    `json:"account_queue_timeout"` // 候选账号都达到 max_concurrent 时等待连接释放的秒数，负数不等待
+	ShutdownStreamGrace  int    `json:"shutdown_stream_grace"` // 关闭时进行中的请求最多再运行的秒数，之后发送 error 事件结束，负数立即结束
+	ShutdownRetryAfter   int    `json:"shutdown_retry_after"`  // 关闭时返回给客户端的建议重试间隔（秒）
+	MaxQueueLength       int    `json:"max_queue_length"`      // 等待并发槽位的请求数上限，超过时直接返回 529，0 不限制
+	FairScheduling       bool   `json:"fair_scheduling"`       // 并发槽位不足时按 API Key 轮流放行排队请求（deficit round-robin）
 	AdaptiveTimeout      bool   `json:"adaptive_timeout"`
+	StallTimeout         int    `json:"stall_timeout"`
+	StallAbortTimeout    int    `json:"stall_abort_timeout"`
+	KeepAliveInterval    int    `json:"keep_alive_interval"`
+	KeepAliveMode        string `json:"keep_alive_mode"`
+	MaxRequestBytes      int64  `json:"max_request_bytes"`
+
+	// 长轮询传输未结束的请求数上限，负数不限制
+	LongPollMaxStreams       int `json:"long_poll_max_streams"`
+	LongPollMaxStreamsPerKey int `json:"long_poll_max_streams_per_key"`
+
+	// 输出标注：需要标识 AI 生成内容的部署可在响应末尾追加说明文本，或在响应中附带部署标识。
+	// attribution_footer 追加在文本输出之后（流式为最后一个 text 块），以 tool_use 结束的响应不追加；
+	// attribution_label 作为 X-Attribution 响应头与非流式响应的 attribution 字段
+	AttributionFooter string `json:"attribution_footer"`
+	AttributionLabel  string `json:"attribution_label"`
+	// post_translation：上游只输出英文时，把非流式响应的文本用一次额外的上游调用翻译为请求的语言
+	// （请求体 response_language，其次 Accept-Language）；与 post_translation_source_language 相同时不翻译
+	PostTranslation               bool   `json:"post_translation"`
+	PostTranslationModel          string `json:"post_translation_model"`           // 翻译使用的模型，为空时与原请求相同
+	PostTranslationSourceLanguage string `json:"post_translation_source_language"` // 上游输出的语言，默认 en
+	// OpenAISSEConformance 为 true 时 chat/completions 流式响应逐帧校验并修复为严格的 chunk 格式
+	OpenAISSEConformance bool `json:"openai_sse_conformance"`
+
+	// Upstream model sync
+	ModelSyncInterval int      `json:"model_sync_interval"`
+	ModelSyncSources  []string `json:"model_sync_sources"`
+	// /v1/models 响应的 Cache-Control max-age（秒），0 表示 no-cache（客户端每次用 ETag 校验）
+	ModelsCacheMaxAge int `json:"models_cache_max_age"`
+
+	// Access log
+	AccessLogSampleRate  float64           `json:"access_log_sample_rate"`
+	AccessLogRouteLevels map[string]string `json:"access_log_route_levels"`
+
+	// 受信任的反向代理（IP 或 CIDR），只有来自这些地址的 X-Forwarded-For / X-Real-IP 才被采信
+	TrustedProxies []string `json:"trusted_proxies"`
+
+	// CORS，按路由分组（public / api / admin）配置，未配置的分组不返回 CORS 头
+	CORS map[string]CORSPolicy `json:"cors"`
+
+	// Debug log retention
+	DebugLogMaxAgeHours int `json:"debug_log_max_age_hours"`
+	DebugLogMaxSizeMB   int `json:"debug_log_max_size_mb"`
+
+	// Log sinks
+	LogFile           string            `json:"log_file"`
+	LogFileMaxSizeMB  int               `json:"log_file_max_size_mb"`
+	LogFileMaxBackups int               `json:"log_file_max_backups"`
+	LogSyslogAddr     string            `json:"log_syslog_addr"`
+	LogLokiURL        string            `json:"log_loki_url"`
+	LogLokiLabels     map[string]string `json:"log_loki_labels"`
+
+	// reasoning_effort（minimal/low/medium/high）到 thinking/standard 模式的映射
+	ReasoningEffortMap map[string]string `json:"reasoning_effort_map"`
+
+	// 客户端系统提示的 token 上限（0 表示不限制），超出时按 truncate/summarize/reject 策略处理
+	SystemPromptMaxTokens    int    `json:"system_prompt_max_tokens"`
+	SystemPromptTrimStrategy string `json:"system_prompt_trim_strategy"`
+
+	// anthropic-beta 请求头：未识别的 beta 按 reject（返回 400）或 ignore 处理，
+	// anthropic_beta_allow 中的名称视为已识别（不改变代理行为）
+	AnthropicBetaUnknown string   `json:"anthropic_beta_unknown"`
+	AnthropicBetaAllow   []string `json:"anthropic_beta_allow"`
+
+	// Config history
+	ConfigHistoryLimit int `json:"config_history_limit"`
+
+	// Account anomaly detection
+	AnomalyWindowHours int     `json:"anomaly_window_hours"`
+	AnomalyErrorRate   float64 `json:"anomaly_error_rate"`
+	AnomalyMinRequests int     `json:"anomaly_min_requests"`
+	AnomalySpikeFactor float64 `json:"anomaly_spike_factor"`
 
 	// Proxy Configuration
 	ProxyHTTP   string   `json:"proxy_http"`
@@ -93,6 +180,115 @@ type Config struct {
 	AutoRegEnabled   bool   `json:"auto_reg_enabled"`
 	AutoRegThreshold int    `json:"auto_reg_threshold"`
 	AutoRegScript    string `json:"auto_reg_script"`
+
+	// 请求签名：API Key ID（/api/keys 返回的 key_id）-> HMAC 共享密钥，配置了密钥的 key 访问 /v1 接口时必须签名
+	RequestSigningSecrets        map[string]string `json:"request_signing_secrets"`
+	RequestSigningMaxSkewSeconds int               `json:"request_signing_max_skew_seconds"`
+
+	// 用量事件（外部计费）：每个消息请求结束后推送到 webhook 和/或 Redis Stream（使用 redis_addr 连接）。
+	// 兼容旧配置，等价于只订阅 request.completed 且只发送事件数据的 event_sinks 目标
+	UsageWebhookURL        string `json:"usage_webhook_url"`
+	UsageWebhookSecret     string `json:"usage_webhook_secret"`
+	UsageRedisStream       string `json:"usage_redis_stream"`
+	UsageRedisStreamMaxLen int64  `json:"usage_redis_stream_max_len"`
+
+	// 事件总线：请求完成、账号状态变化、后台任务结束等系统事件推送到一个或多个目标
+	EventSinks []EventSink `json:"event_sinks"`
+
+	// 定时报表邮件：report_schedule 为 daily / weekly（为空关闭），在服务器本地时间 report_hour 点
+	// 通过 SMTP 发送请求、token、账号错误、被禁用账号与额度接近上限的汇总
+	ReportSchedule       string   `json:"report_schedule"`
+	ReportHour           int      `json:"report_hour"`
+	ReportRecipients     []string `json:"report_recipients"`
+	ReportFrom           string   `json:"report_from"`
+	ReportSMTPAddr       string   `json:"report_smtp_addr"`
+	ReportSMTPUser       string   `json:"report_smtp_user"`
+	ReportSMTPPass       string   `json:"report_smtp_pass"`
+	ReportQuotaWarnRatio float64  `json:"report_quota_warn_ratio"`
+
+	// 超大 tool_result：超过 tool_result_max_bytes（负数不限制）的原文保存到 tool_result_spill_dir，
+	// prompt 中替换为引用与首尾共 tool_result_excerpt_bytes 的摘录。Warp 默认原样透传，warp_tool_result_spill 开启后同样处理
+	ToolResultMaxBytes         int    `json:"tool_result_max_bytes"`
+	ToolResultExcerptBytes     int    `json:"tool_result_excerpt_bytes"`
+	ToolResultSpillDir         string `json:"tool_result_spill_dir"`
+	ToolResultSpillMaxAgeHours int    `json:"tool_result_spill_max_age_hours"`
+	WarpToolResultSpill        bool   `json:"warp_tool_result_spill"`
+
+	// 会话 artifact：同一会话中不小于 conversation_artifact_min_bytes 的 tool_result 只发送一次，
+	// 之后内容相同的 tool_result 替换为引用；每个会话最多保留 conversation_artifact_max_count 个，随会话过期清理
+	ConversationArtifacts        bool `json:"conversation_artifacts"`
+	ConversationArtifactMinBytes int  `json:"conversation_artifact_min_bytes"`
+	ConversationArtifactMaxCount int  `json:"conversation_artifact_max_count"`
+
+	// 按渠道（public / orchids / warp）限制可用模型，模型列表与消息请求都会按此过滤
+	ChannelModels map[string]ChannelModelPolicy `json:"channel_models"`
+
+	// 渠道路径前缀：前缀（如 /ai）-> 渠道（orchids / warp），为空时使用默认的 /orchids、/warp。
+	// 配置后只接受映射中的前缀，隐藏实际使用的上游；要保留默认前缀需同时写入 {"/orchids": "orchids"}
+	ChannelPrefixes map[string]string `json:"channel_prefixes"`
+
+	// 上游思考内容的展示方式：passthrough（原样输出 thinking 块）/ status（流式响应中定期输出一行简短的状态文本）/
+	// hide（不输出）。为空时按 suppress_thinking 取 passthrough 或 hide；reasoning_display_keys 按 API Key ID 覆盖
+	ReasoningDisplay        string            `json:"reasoning_display"`
+	ReasoningDisplayKeys    map[string]string `json:"reasoning_display_keys"`
+	ReasoningStatusInterval int               `json:"reasoning_status_interval"`
+
+	// tool_result 指令注入检测：off（默认）/ log（只记录审计日志）/ neutralize（转义伪造的角色标签并在内容前加提示，同时记录）；
+	// tool_injection_guard_keys 按 API Key ID 覆盖
+	ToolInjectionGuard     string            `json:"tool_injection_guard"`
+	ToolInjectionGuardKeys map[string]string `json:"tool_injection_guard_keys"`
+
+	// 模型输出过滤：output_filter 为 mask 时按 output_filter_rules 脱敏流式 delta 与非流式响应中的文本，
+	// output_filter_keys 按 API Key ID 覆盖。流式响应保留末尾 output_filter_holdback 字节，以便匹配跨 delta 的内容
+	OutputFilter         string             `json:"output_filter"`
+	OutputFilterKeys     map[string]string  `json:"output_filter_keys"`
+	OutputFilterRules    []OutputFilterRule `json:"output_filter_rules"`
+	OutputFilterHoldback int                `json:"output_filter_holdback"`
+
+	// fair_scheduling 的 API Key 权重（key 为 API Key ID），排队时按权重比例获得并发槽位，未配置的 key 权重为 1
+	FairShareWeights map[string]int `json:"fair_share_weights"`
+
+	// Canary：按百分比（0-100）把消息请求转发到另一个实例，对比状态码与耗时
+	CanaryURL     string  `json:"canary_url"`
+	CanaryPercent float64 `json:"canary_percent"`
+
+	// Chaos testing (soak tests only, never enable in production)
+	ChaosEnabled      bool    `json:"chaos_enabled"`
+	ChaosSeed         int64   `json:"chaos_seed"`
+	ChaosDelayRate    float64 `json:"chaos_delay_rate"`
+	ChaosMaxDelayMs   int     `json:"chaos_max_delay_ms"`
+	ChaosDropRate     float64 `json:"chaos_drop_rate"`
+	ChaosTruncateRate float64 `json:"chaos_truncate_rate"`
+	ChaosCorruptRate  float64 `json:"chaos_corrupt_rate"`
+
+	// 配置文件中的 *_file / *_env 间接字段（字段名 -> 引用），不参与序列化
+	secretRefs map[string]string
+}
+
+// CORSPolicy 为一个路由分组的跨域策略，字段顺序需与 middleware.CORSPolicy 保持一致
+type CORSPolicy struct {
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods,omitempty"`
+	AllowedHeaders   []string `json:"allowed_headers,omitempty"`
+	ExposedHeaders   []string `json:"exposed_headers,omitempty"`
+	AllowCredentials bool     `json:"allow_credentials,omitempty"`
+	MaxAge           int      `json:"max_age,omitempty"`
+}
+
+// EventSink 为事件总线的一个目标
+type EventSink struct {
+	// Name 用于日志与指标标签，默认为 Type
+	Name string `json:"name,omitempty"`
+	// Type 为 webhook / redis_stream / nats
+	Type string `json:"type"`
+	// URL 为 webhook 地址，或 nats://[user:pass@]host:port
+	URL    string `json:"url,omitempty"`
+	Secret string `json:"secret,omitempty"`
+	// Stream 为 redis_stream 的 stream 名称，或 nats 的 subject 前缀（实际 subject 为 前缀.事件类型）
+	Stream string `json:"stream,omitempty"`
+	MaxLen int64  `json:"max_len,omitempty"`
+	// Events 为订阅的事件类型，支持 "account.*" 形式的前缀匹配，为空表示全部
+	Events []string `json:"events,omitempty"`
 }
 
 func Load(path string) (*Config, string, error) {
@@ -107,17 +303,23 @@ func Load(path string) (*Config, string, error) {
 	}
 
 	cfg := Config{}
+	// raw 保留原始键值，用于解析 *_file / *_env 等不属于 Config 的间接字段
+	var raw map[string]interface{}
 	ext := strings.ToLower(filepath.Ext(resolvedPath))
 	switch ext {
 	case ".json":
 		if err := json.Unmarshal(data, &cfg); err != nil {
 			return nil, "", fmt.Errorf("failed to parse config json: %w", err)
 		}
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return nil, "", fmt.Errorf("failed to parse config json: %w", err)
+		}
 	case ".yaml", ".yml":
 		m, err := parseYAMLFlat(data)
 		if err != nil {
 			return nil, "", err
 		}
+		raw = m
 		raw, err := json.Marshal(m)
 		if err != nil {
 			return nil, "", fmt.Errorf("failed to normalize yaml: %w", err)
@@ -129,6 +331,17 @@ func Load(path string) (*Config, string, error) {
 		return nil, "", fmt.Errorf("unsupported config extension: %s", ext)
 	}
 
+	if from, err := MigrateConfig(&cfg); err != nil {
+		return nil, "", fmt.Errorf("failed to migrate config: %w", err)
+	} else if from != CurrentSchemaVersion {
+		slog.Info("配置已迁移", "from_schema", from, "to_schema", CurrentSchemaVersion, "path", resolvedPath)
+	}
+	if cfg.secretRefs, err = secretRefsFrom(&cfg, raw); err != nil {
+		return nil, "", fmt.Errorf("failed to resolve config secrets: %w", err)
+	}
+	if err := cfg.ResolveSecrets(context.Background()); err != nil {
+		return nil, "", fmt.Errorf("failed to resolve config secrets: %w", err)
+	}
 	ApplyDefaults(&cfg)
 	return &cfg, resolvedPath, nil
 }
@@ -152,6 +365,9 @@ func ApplyDefaults(cfg *Config) {
 	if cfg.Port == "" {
 		cfg.Port = "3002"
 	}
+	if cfg.UnixSocketMode == "" {
+		cfg.UnixSocketMode = "0660"
+	}
 	if cfg.AdminUser == "" {
 		cfg.AdminUser = "admin"
 	}
@@ -162,6 +378,7 @@ func ApplyDefaults(cfg *Config) {
 	if cfg.AdminPath == "" {
 		cfg.AdminPath = "/admin"
 	}
+	cfg.BasePath = strings.TrimRight(strings.TrimSpace(cfg.BasePath), "/")
 	if cfg.OutputTokenMode == "" {
 		cfg.OutputTokenMode = "final"
 	}
@@ -207,6 +424,39 @@ func ApplyDefaults(cfg *Config) {
 	if cfg.ContextKeepTurns == 0 {
 		cfg.ContextKeepTurns = 6
 	}
+	if cfg.SystemPromptTrimStrategy == "" {
+		cfg.SystemPromptTrimStrategy = "truncate"
+	}
+	if cfg.RequestSigningMaxSkewSeconds == 0 {
+		cfg.RequestSigningMaxSkewSeconds = 300
+	}
+	if cfg.ToolResultMaxBytes == 0 {
+		cfg.ToolResultMaxBytes = 102400
+	}
+	if cfg.ToolResultExcerptBytes == 0 {
+		cfg.ToolResultExcerptBytes = 8192
+	}
+	if cfg.ToolResultSpillDir == "" {
+		cfg.ToolResultSpillDir = "tool-results"
+	}
+	if cfg.ToolResultSpillMaxAgeHours == 0 {
+		cfg.ToolResultSpillMaxAgeHours = 24
+	}
+	if cfg.ConversationArtifactMinBytes == 0 {
+		cfg.ConversationArtifactMinBytes = 2048
+	}
+	if cfg.ConversationArtifactMaxCount == 0 {
+		cfg.ConversationArtifactMaxCount = 200
+	}
+	if cfg.ReasoningStatusInterval == 0 {
+		cfg.ReasoningStatusInterval = 3
+	}
+	if cfg.AnthropicBetaUnknown == "" {
+		cfg.AnthropicBetaUnknown = "ignore"
+	}
+	if cfg.ReportQuotaWarnRatio == 0 {
+		cfg.ReportQuotaWarnRatio = 0.9
+	}
 	if cfg.OrchidsAPIBaseURL == "" {
 		cfg.OrchidsAPIBaseURL = "https://orchids-server.calmstone-6964e08a.westeurope.azurecontainerapps.io"
 	}
@@ -277,9 +527,83 @@ func ApplyDefaults(cfg *Config) {
 	if cfg.ConcurrencyLimit == 0 {
 		cfg.ConcurrencyLimit = 100
 	}
+	if cfg.ShutdownStreamGrace == 0 {
+		cfg.ShutdownStreamGrace = 10
+	}
+	if cfg.ShutdownRetryAfter == 0 {
+		cfg.ShutdownRetryAfter = 5
+	}
 	if cfg.ConcurrencyTimeout == 0 {
 		cfg.ConcurrencyTimeout = 300
 	}
+	if cfg.OutputFilterHoldback == 0 {
+		cfg.OutputFilterHoldback = 128
+	}
+	if cfg.AccountQueueTimeout == 0 {
+		cfg.AccountQueueTimeout = 30
+	}
+	if cfg.StallTimeout == 0 {
+		cfg.StallTimeout = 60
+	}
+	if cfg.StallAbortTimeout == 0 {
+		cfg.StallAbortTimeout = 180
+	}
+	if cfg.KeepAliveInterval == 0 {
+		cfg.KeepAliveInterval = 15
+	}
+	if cfg.KeepAliveMode == "" {
+		cfg.KeepAliveMode = "comment"
+	}
+	if cfg.MaxRequestBytes == 0 {
+		cfg.MaxRequestBytes = DefaultMaxRequestBytes
+	}
+	if cfg.LongPollMaxStreams == 0 {
+		cfg.LongPollMaxStreams = 1000
+	}
+	if cfg.LongPollMaxStreamsPerKey == 0 {
+		cfg.LongPollMaxStreamsPerKey = 16
+	}
+	if cfg.ModelSyncInterval == 0 {
+		cfg.ModelSyncInterval = 30
+	}
+	if cfg.AccessLogSampleRate == 0 {
+		cfg.AccessLogSampleRate = 1
+	}
+	if cfg.DebugLogMaxAgeHours == 0 {
+		cfg.DebugLogMaxAgeHours = 24
+	}
+	if cfg.DebugLogMaxSizeMB == 0 {
+		cfg.DebugLogMaxSizeMB = 500
+	}
+	if cfg.LogFileMaxSizeMB <= 0 {
+		cfg.LogFileMaxSizeMB = 100
+	}
+	if cfg.LogFileMaxBackups <= 0 {
+		cfg.LogFileMaxBackups = 5
+	}
+	if len(cfg.ReasoningEffortMap) == 0 {
+		cfg.ReasoningEffortMap = map[string]string{
+			"minimal": "standard",
+			"low":     "standard",
+			"medium":  "thinking",
+			"high":    "thinking",
+		}
+	}
+	if cfg.ConfigHistoryLimit <= 0 {
+		cfg.ConfigHistoryLimit = 20
+	}
+	if cfg.AnomalyWindowHours <= 0 {
+		cfg.AnomalyWindowHours = 24
+	}
+	if cfg.AnomalyErrorRate == 0 {
+		cfg.AnomalyErrorRate = 0.5
+	}
+	if cfg.AnomalyMinRequests == 0 {
+		cfg.AnomalyMinRequests = 10
+	}
+	if cfg.AnomalySpikeFactor == 0 {
+		cfg.AnomalySpikeFactor = 3
+	}
 
 	// Auto Reg defaults
 	if cfg.AutoRegThreshold == 0 {
@@ -290,6 +614,27 @@ func ApplyDefaults(cfg *Config) {
 	}
 }
 
+// UnixSocketFileMode 返回 unix_socket_mode 对应的文件权限，无法解析时使用 0660
+func (c *Config) UnixSocketFileMode() os.FileMode {
+	mode, err := strconv.ParseUint(c.UnixSocketMode, 8, 32)
+	if err != nil || mode > 0777 {
+		return 0660
+	}
+	return os.FileMode(mode)
+}
+
+// modeForKey 解析按 API Key 覆盖的模式：keyID（为空表示未使用 key 认证）在 keys 中有值时优先，
+// 其次为全局值 global，都未配置时返回 fallback。返回值统一为小写
+func modeForKey(keys map[string]string, keyID, global, fallback string) string {
+	if mode, ok := keys[keyID]; ok && keyID != "" {
+		return strings.ToLower(mode)
+	}
+	if global != "" {
+		return strings.ToLower(global)
+	}
+	return fallback
+}
+
 func (c *Config) GetCookies() string {
 	if strings.TrimSpace(c.SessionCookie) != "" {
 		return "__client=" + c.ClientCookie + "; __session=" + c.SessionCookie
@@ -0,0 +1,31 @@
+package config
+
+// 模型输出过滤策略
+const (
+	OutputFilterOff  = "off"
+	OutputFilterMask = "mask"
+)
+
+// OutputFilterModes 为 output_filter 的全部取值
+var OutputFilterModes = []string{OutputFilterOff, OutputFilterMask}
+
+// OutputFilterPresets 为 output_filter_rules 中 preset 的可选值：secrets 为内置的凭据规则
+// （Bearer token、JWT、sk-/AKIA/ghp_ 开头的 key 与 PEM 私钥）
+var OutputFilterPresets = []string{"secrets"}
+
+// OutputFilterRule 为一条输出过滤规则：Pattern（RE2 正则）与 Preset 二选一，命中内容替换为 Replacement
+// （默认 [REDACTED]，可使用 $1 等分组引用）。Name 用于脱敏计数，默认取 Preset 或 Pattern
+type OutputFilterRule struct {
+	Name        string `json:"name,omitempty"`
+	Pattern     string `json:"pattern,omitempty"`
+	Preset      string `json:"preset,omitempty"`
+	Replacement string `json:"replacement,omitempty"`
+}
+
+// OutputFilterFor 返回 API Key 的输出过滤策略：output_filter_keys 优先，其次 output_filter，都未配置时为 off
+func (c *Config) OutputFilterFor(keyID string) string {
+	if c == nil {
+		return OutputFilterOff
+	}
+	return modeForKey(c.OutputFilterKeys, keyID, c.OutputFilter, OutputFilterOff)
+}
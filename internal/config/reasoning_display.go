@@ -0,0 +1,24 @@
+package config
+
+// 上游思考内容的展示方式
+const (
+	ReasoningPassthrough = "passthrough"
+	ReasoningStatus      = "status"
+	ReasoningHide        = "hide"
+)
+
+// ReasoningDisplayModes 为 reasoning_display 的全部取值
+var ReasoningDisplayModes = []string{ReasoningPassthrough, ReasoningStatus, ReasoningHide}
+
+// ReasoningDisplayFor 返回 API Key 的思考展示方式：reasoning_display_keys 优先，其次 reasoning_display，
+// 都未配置时按 suppress_thinking 决定
+func (c *Config) ReasoningDisplayFor(keyID string) string {
+	if c == nil {
+		return ReasoningPassthrough
+	}
+	fallback := ReasoningPassthrough
+	if c.SuppressThinking {
+		fallback = ReasoningHide
+	}
+	return modeForKey(c.ReasoningDisplayKeys, keyID, c.ReasoningDisplay, fallback)
+}
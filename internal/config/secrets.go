@@ -0,0 +1,372 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 配置中的密钥可以不直接写入配置文件，而是在加载时从以下来源解析：
+//
+//   - 间接字段：任意字符串字段加 _file / _env 后缀，如 "redis_password_file": "/run/secrets/redis"、
+//     "admin_pass_env": "ORCHIDS_ADMIN_PASS"，分别读取文件内容（去掉末尾换行）与环境变量
+//   - 引用值：字段值为 file://、env://、vault://、aws-sm:// URI，如 "admin_token": "vault://secret/data/orchids#admin_token"
+//
+// 间接字段优先于同名字段的直接值；任一引用解析失败时加载失败，避免带着空密钥启动
+const (
+	secretFileSuffix = "_file"
+	secretEnvSuffix  = "_env"
+)
+
+// secretTimeout 为访问外部密钥管理服务的超时
+const secretTimeout = 10 * time.Second
+
+// secretHTTPClient 用于访问 Vault / AWS Secrets Manager，测试中可替换
+var secretHTTPClient = &http.Client{Timeout: secretTimeout}
+
+// secretResolvers 按 URI scheme 解析引用值，ref 为去掉 "scheme://" 后的部分
+var secretResolvers = map[string]func(ctx context.Context, ref string) (string, error){
+	"file":   resolveFileSecret,
+	"env":    resolveEnvSecret,
+	"vault":  resolveVaultSecret,
+	"aws-sm": resolveAWSSecret,
+}
+
+// secretRefsFrom 从配置文件的原始键值中提取 _file / _env 间接字段，返回 字段名 -> 引用（scheme://ref 形式）
+func secretRefsFrom(cfg *Config, raw map[string]interface{}) (map[string]string, error) {
+	fields := stringFields(cfg)
+	refs := map[string]string{}
+	for key, value := range raw {
+		if _, isField := fields[key]; isField {
+			continue
+		}
+		var base, scheme string
+		switch {
+		case strings.HasSuffix(key, secretFileSuffix):
+			base, scheme = strings.TrimSuffix(key, secretFileSuffix), "file"
+		case strings.HasSuffix(key, secretEnvSuffix):
+			base, scheme = strings.TrimSuffix(key, secretEnvSuffix), "env"
+		default:
+			continue
+		}
+		if _, ok := fields[base]; !ok {
+			continue
+		}
+		ref, ok := value.(string)
+		if !ok || strings.TrimSpace(ref) == "" {
+			return nil, fmt.Errorf("%s: must be a non-empty string", key)
+		}
+		refs[base] = scheme + "://" + strings.TrimSpace(ref)
+	}
+	return refs, nil
+}
+
+// ResolveSecrets 解析配置文件中的 _file / _env 间接字段以及字段值中的密钥引用，将实际值写入配置。
+// 引用在每次调用时重新读取：字段值本身是引用时以字段值为准（如从 Redis 覆盖的配置），
+// 否则使用记录的引用，因此覆盖为旧的明文后再次调用即可恢复为挂载的最新密钥
+func (c *Config) ResolveSecrets(ctx context.Context) error {
+	fields := stringFields(c)
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field := fields[name]
+		value := field.String()
+		if ref, ok := c.secretRefs[name]; ok && !isSecretRef(value) {
+			value = ref
+		}
+		if !isSecretRef(value) {
+			continue
+		}
+		scheme, ref, _ := strings.Cut(value, "://")
+		resolve := secretResolvers[scheme]
+		// 记录引用，之后再次解析时不受已写入的实际值影响
+		if c.secretRefs == nil {
+			c.secretRefs = map[string]string{}
+		}
+		c.secretRefs[name] = value
+		resolved, err := resolve(ctx, ref)
+		if err != nil {
+			return fmt.Errorf("%s: %s:// reference: %w", name, scheme, err)
+		}
+		field.SetString(resolved)
+	}
+	return nil
+}
+
+// isSecretRef 报告 value 是否为可解析的密钥引用（scheme 在 secretResolvers 中）
+func isSecretRef(value string) bool {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return false
+	}
+	_, ok = secretResolvers[scheme]
+	return ok
+}
+
+// MarshalJSON 将由引用解析得到的字段写回引用本身（_file / _env 间接字段写为 file:// / env:// 形式），
+// 解析后的密钥只保留在运行中的配置里，不会随 /api/config 返回，也不会保存到 Redis 与配置历史
+func (c Config) MarshalJSON() ([]byte, error) {
+	type plain Config
+	if len(c.secretRefs) > 0 {
+		fields := stringFields(&c)
+		for name, ref := range c.secretRefs {
+			if field, ok := fields[name]; ok {
+				field.SetString(ref)
+			}
+		}
+	}
+	return json.Marshal(plain(c))
+}
+
+// stringFields 返回 Config 中按 JSON 名索引的可写字符串字段
+func stringFields(cfg *Config) map[string]reflect.Value {
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	fields := make(map[string]reflect.Value, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" || !t.Field(i).IsExported() || t.Field(i).Type.Kind() != reflect.String {
+			continue
+		}
+		fields[name] = v.Field(i)
+	}
+	return fields
+}
+
+func resolveFileSecret(_ context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+func resolveEnvSecret(_ context.Context, name string) (string, error) {
+	value, ok := os.LookupEnv(name)
+	if !ok || value == "" {
+		return "", fmt.Errorf("environment variable %s is not set", name)
+	}
+	return value, nil
+}
+
+// resolveVaultSecret 读取 Vault 中的密钥，ref 形如 "secret/data/orchids#redis_password"（# 后为字段名，缺省为 value），
+// 同时支持 KV v1 与 v2。地址与凭据取自 VAULT_ADDR、VAULT_TOKEN（或 VAULT_TOKEN_FILE）、VAULT_NAMESPACE
+func resolveVaultSecret(ctx context.Context, ref string) (string, error) {
+	path, key, _ := strings.Cut(ref, "#")
+	if key == "" {
+		key = "value"
+	}
+	addr := strings.TrimRight(os.Getenv("VAULT_ADDR"), "/")
+	if addr == "" {
+		return "", fmt.Errorf("VAULT_ADDR is not set")
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		if tokenFile := os.Getenv("VAULT_TOKEN_FILE"); tokenFile != "" {
+			t, err := resolveFileSecret(ctx, tokenFile)
+			if err != nil {
+				return "", fmt.Errorf("read VAULT_TOKEN_FILE: %w", err)
+			}
+			token = t
+		}
+	}
+	if token == "" {
+		return "", fmt.Errorf("VAULT_TOKEN is not set")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, secretTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr+"/v1/"+strings.TrimLeft(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	if ns := os.Getenv("VAULT_NAMESPACE"); ns != "" {
+		req.Header.Set("X-Vault-Namespace", ns)
+	}
+	var body struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	if err := doSecretRequest(req, &body); err != nil {
+		return "", err
+	}
+	data := body.Data
+	// KV v2 的字段位于 data.data 下
+	if nested, ok := data["data"].(map[string]interface{}); ok {
+		if _, hasMeta := data["metadata"]; hasMeta {
+			data = nested
+		}
+	}
+	value, ok := data[key].(string)
+	if !ok {
+		return "", fmt.Errorf("vault secret %s has no string field %q", path, key)
+	}
+	return value, nil
+}
+
+// resolveAWSSecret 读取 AWS Secrets Manager 中的密钥，ref 形如 "orchids/prod?region=us-east-1#redis_password"：
+// 没有 # 时返回整个 SecretString，否则将 SecretString 解析为 JSON 并取对应字段。
+// 凭据取自 AWS_ACCESS_KEY_ID、AWS_SECRET_ACCESS_KEY、AWS_SESSION_TOKEN，区域缺省取 AWS_REGION / AWS_DEFAULT_REGION，
+// AWS_ENDPOINT_URL_SECRETS_MANAGER 可覆盖服务地址
+func resolveAWSSecret(ctx context.Context, ref string) (string, error) {
+	ref, key, _ := strings.Cut(ref, "#")
+	secretID, query, _ := strings.Cut(ref, "?")
+	params, err := url.ParseQuery(query)
+	if err != nil {
+		return "", err
+	}
+	if secretID == "" {
+		return "", fmt.Errorf("missing secret id")
+	}
+	region := params.Get("region")
+	if region == "" {
+		region = os.Getenv("AWS_REGION")
+	}
+	if region == "" {
+		region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+	if region == "" {
+		return "", fmt.Errorf("AWS region is not set")
+	}
+	creds := awsCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return "", fmt.Errorf("AWS_ACCESS_KEY_ID / AWS_SECRET_ACCESS_KEY are not set")
+	}
+	endpoint := os.Getenv("AWS_ENDPOINT_URL_SECRETS_MANAGER")
+	if endpoint == "" {
+		endpoint = "https://secretsmanager." + region + ".amazonaws.com"
+	}
+
+	payload, _ := json.Marshal(map[string]string{"SecretId": secretID})
+	ctx, cancel := context.WithTimeout(ctx, secretTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimRight(endpoint, "/")+"/", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "secretsmanager.GetSecretValue")
+	signAWSRequest(req, payload, region, "secretsmanager", creds, time.Now().UTC())
+
+	var body struct {
+		SecretString string `json:"SecretString"`
+	}
+	if err := doSecretRequest(req, &body); err != nil {
+		return "", err
+	}
+	if key == "" {
+		return body.SecretString, nil
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal([]byte(body.SecretString), &fields); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object: %w", secretID, err)
+	}
+	value, ok := fields[key].(string)
+	if !ok {
+		return "", fmt.Errorf("secret %s has no string field %q", secretID, key)
+	}
+	return value, nil
+}
+
+func doSecretRequest(req *http.Request, out interface{}) error {
+	resp, err := secretHTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		// 错误响应体不含密钥内容，截断后附带便于排查
+		if len(data) > 256 {
+			data = data[:256]
+		}
+		return fmt.Errorf("%s returned %d: %s", req.URL.Host, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, out)
+}
+
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+}
+
+// signAWSRequest 为请求添加 AWS Signature Version 4 签名
+func signAWSRequest(req *http.Request, payload []byte, region, service string, creds awsCredentials, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(payload)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+creds.SecretAccessKey), date)
+	for _, part := range []string{region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,201 @@
+package config
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoad_ResolvesFileAndEnvSecrets(t *testing.T) {
+	secretFile := writeConfigFile(t, "redis_password", "s3cret\n")
+	tokenFile := writeConfigFile(t, "admin_token", "tok-from-file\n")
+	t.Setenv("TEST_ORCHIDS_ADMIN_PASS", "pass-from-env")
+
+	path := writeConfigFile(t, "config.json", `{
+		"redis_password": "ignored",
+		"redis_password_file": "`+secretFile+`",
+		"admin_pass_env": "TEST_ORCHIDS_ADMIN_PASS",
+		"admin_token": "file://`+tokenFile+`",
+		"log_file": "orchids.log"
+	}`)
+	cfg, _, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.RedisPassword != "s3cret" {
+		t.Fatalf("RedisPassword = %q, want file content", cfg.RedisPassword)
+	}
+	if cfg.AdminPass != "pass-from-env" {
+		t.Fatalf("AdminPass = %q, want env value", cfg.AdminPass)
+	}
+	if cfg.AdminToken != "tok-from-file" {
+		t.Fatalf("AdminToken = %q, want file:// value", cfg.AdminToken)
+	}
+	if cfg.LogFile != "orchids.log" {
+		t.Fatalf("LogFile = %q, *_file fields that exist in Config must be left alone", cfg.LogFile)
+	}
+
+	// 模拟 Redis 覆盖后重新解析：密钥恢复为挂载的最新值
+	if err := os.WriteFile(secretFile, []byte("rotated"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := UnmarshalMigrated([]byte(`{"redis_password":"stale","admin_token":"stale"}`), cfg); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.ResolveSecrets(context.Background()); err != nil {
+		t.Fatalf("ResolveSecrets: %v", err)
+	}
+	if cfg.RedisPassword != "rotated" || cfg.AdminToken != "tok-from-file" {
+		t.Fatalf("after overlay: redis_password=%q admin_token=%q", cfg.RedisPassword, cfg.AdminToken)
+	}
+}
+
+func TestConfigMarshal_PersistsReferences(t *testing.T) {
+	tokenFile := writeConfigFile(t, "admin_token", "tok-from-file\n")
+	t.Setenv("TEST_ORCHIDS_ADMIN_PASS", "pass-from-env")
+	path := writeConfigFile(t, "config.json", `{
+		"admin_pass_env": "TEST_ORCHIDS_ADMIN_PASS",
+		"admin_token": "file://`+tokenFile+`",
+		"redis_password": "plain-value"
+	}`)
+	cfg, _, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	if strings.Contains(string(data), "pass-from-env") || strings.Contains(string(data), "tok-from-file") {
+		t.Fatalf("resolved secrets serialized: %s", data)
+	}
+	var out map[string]interface{}
+	json.Unmarshal(data, &out)
+	if out["admin_pass"] != "env://TEST_ORCHIDS_ADMIN_PASS" || out["admin_token"] != "file://"+tokenFile || out["redis_password"] != "plain-value" {
+		t.Fatalf("admin_pass=%v admin_token=%v redis_password=%v", out["admin_pass"], out["admin_token"], out["redis_password"])
+	}
+	if cfg.AdminPass != "pass-from-env" || cfg.AdminToken != "tok-from-file" {
+		t.Fatalf("runtime values changed: admin_pass=%q admin_token=%q", cfg.AdminPass, cfg.AdminToken)
+	}
+
+	// 保存的 JSON 重新加载后解析为实际值，且再次序列化仍为引用
+	var restored Config
+	if _, err := UnmarshalMigrated(data, &restored); err != nil {
+		t.Fatal(err)
+	}
+	if err := restored.ResolveSecrets(context.Background()); err != nil {
+		t.Fatalf("ResolveSecrets: %v", err)
+	}
+	if restored.AdminPass != "pass-from-env" || restored.AdminToken != "tok-from-file" {
+		t.Fatalf("restored admin_pass=%q admin_token=%q", restored.AdminPass, restored.AdminToken)
+	}
+	again, _ := json.Marshal(&restored)
+	if strings.Contains(string(again), "pass-from-env") {
+		t.Fatalf("restored config serialized plaintext: %s", again)
+	}
+}
+
+func TestLoad_UnresolvableSecretFails(t *testing.T) {
+	path := writeConfigFile(t, "config.yaml", "admin_pass_env: TEST_ORCHIDS_MISSING_ENV\n")
+	if _, _, err := Load(path); err == nil || !strings.Contains(err.Error(), "admin_pass") {
+		t.Fatalf("Load error = %v, want admin_pass failure", err)
+	}
+}
+
+func TestResolveSecrets_Vault(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "vault-token" {
+			http.Error(w, "permission denied", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/secret/data/orchids":
+			w.Write([]byte(`{"data":{"data":{"redis_password":"kv2"},"metadata":{"version":3}}}`))
+		case "/v1/kv/orchids":
+			w.Write([]byte(`{"data":{"value":"kv1"}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("VAULT_ADDR", srv.URL)
+	t.Setenv("VAULT_TOKEN", "vault-token")
+
+	cfg := &Config{RedisPassword: "vault://secret/data/orchids#redis_password", AdminToken: "vault://kv/orchids"}
+	if err := cfg.ResolveSecrets(context.Background()); err != nil {
+		t.Fatalf("ResolveSecrets: %v", err)
+	}
+	if cfg.RedisPassword != "kv2" || cfg.AdminToken != "kv1" {
+		t.Fatalf("redis_password=%q admin_token=%q", cfg.RedisPassword, cfg.AdminToken)
+	}
+
+	t.Setenv("VAULT_TOKEN", "wrong")
+	cfg = &Config{AdminPass: "vault://secret/data/orchids#admin_pass"}
+	if err := cfg.ResolveSecrets(context.Background()); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Fatalf("err = %v, want 403", err)
+	}
+}
+
+func TestResolveSecrets_AWSSecretsManager(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" {
+			http.Error(w, "bad target", http.StatusBadRequest)
+			return
+		}
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKIDTEST/") || !strings.Contains(auth, "/eu-west-1/secretsmanager/aws4_request") ||
+			!strings.Contains(auth, "x-amz-security-token") {
+			http.Error(w, "bad signature: "+auth, http.StatusForbidden)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		var req struct{ SecretId string }
+		json.Unmarshal(body, &req)
+		switch req.SecretId {
+		case "orchids/prod":
+			w.Write([]byte(`{"SecretString":"{\"redis_password\":\"from-aws\"}"}`))
+		case "orchids/token":
+			w.Write([]byte(`{"SecretString":"plain-token"}`))
+		default:
+			http.Error(w, `{"__type":"ResourceNotFoundException"}`, http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("AWS_ENDPOINT_URL_SECRETS_MANAGER", srv.URL)
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDTEST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+	t.Setenv("AWS_SESSION_TOKEN", "session")
+
+	cfg := &Config{
+		RedisPassword: "aws-sm://orchids/prod?region=eu-west-1#redis_password",
+		AdminToken:    "aws-sm://orchids/token?region=eu-west-1",
+	}
+	if err := cfg.ResolveSecrets(context.Background()); err != nil {
+		t.Fatalf("ResolveSecrets: %v", err)
+	}
+	if cfg.RedisPassword != "from-aws" || cfg.AdminToken != "plain-token" {
+		t.Fatalf("redis_password=%q admin_token=%q", cfg.RedisPassword, cfg.AdminToken)
+	}
+
+	cfg = &Config{AdminPass: "aws-sm://missing?region=eu-west-1"}
+	if err := cfg.ResolveSecrets(context.Background()); err == nil || !strings.Contains(err.Error(), "ResourceNotFoundException") {
+		t.Fatalf("err = %v, want not found", err)
+	}
+}
@@ -0,0 +1,20 @@
+package config
+
+// tool_result 指令注入检测的处理方式
+const (
+	ToolInjectionOff        = "off"
+	ToolInjectionLog        = "log"
+	ToolInjectionNeutralize = "neutralize"
+)
+
+// ToolInjectionModes 为 tool_injection_guard 的全部取值
+var ToolInjectionModes = []string{ToolInjectionOff, ToolInjectionLog, ToolInjectionNeutralize}
+
+// ToolInjectionGuardFor 返回 API Key 的 tool_result 注入检测方式：tool_injection_guard_keys 优先，
+// 其次 tool_injection_guard，都未配置时为 off
+func (c *Config) ToolInjectionGuardFor(keyID string) string {
+	if c == nil {
+		return ToolInjectionOff
+	}
+	return modeForKey(c.ToolInjectionGuardKeys, keyID, c.ToolInjectionGuard, ToolInjectionOff)
+}
@@ -0,0 +1,503 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CurrentSchemaVersion 为当前配置结构版本。旧配置（缺少 schema_version 或版本更低）
+// 在加载时由 Migrate 逐级升级
+const CurrentSchemaVersion = 1
+
+// FieldError 为一项校验结果，Field 为 JSON 字段名
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// migrations[i] 将 schema_version i 的原始配置升级到 i+1
+var migrations = []func(raw map[string]interface{}){
+	// v0 -> v1：旧版 cache_strategy 接受 "mix" 作为 "mixed" 的别名
+	func(raw map[string]interface{}) {
+		if s, ok := raw["cache_strategy"].(string); ok && strings.EqualFold(strings.TrimSpace(s), "mix") {
+			raw["cache_strategy"] = "mixed"
+		}
+	},
+}
+
+// Migrate 将原始配置升级到 CurrentSchemaVersion，返回原始版本。
+// 版本高于当前支持时返回错误，避免旧二进制静默丢弃新字段语义
+func Migrate(raw map[string]interface{}) (int, error) {
+	from := 0
+	switch v := raw["schema_version"].(type) {
+	case nil:
+	case float64:
+		from = int(v)
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return 0, fmt.Errorf("invalid schema_version %q", v)
+		}
+		from = int(n)
+	default:
+		return 0, fmt.Errorf("invalid schema_version type %T", v)
+	}
+	if from < 0 {
+		return from, fmt.Errorf("invalid schema_version %d", from)
+	}
+	if from > CurrentSchemaVersion {
+		return from, fmt.Errorf("schema_version %d is newer than supported version %d", from, CurrentSchemaVersion)
+	}
+	for v := from; v < CurrentSchemaVersion; v++ {
+		migrations[v](raw)
+	}
+	raw["schema_version"] = CurrentSchemaVersion
+	return from, nil
+}
+
+// MigrateConfig 对已解析的配置应用迁移，用于从文件或 Redis 加载的配置
+func MigrateConfig(cfg *Config) (int, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return 0, err
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return 0, err
+	}
+	from, err := Migrate(raw)
+	if err != nil || from == CurrentSchemaVersion {
+		return from, err
+	}
+	data, err = json.Marshal(raw)
+	if err != nil {
+		return from, err
+	}
+	var migrated Config
+	if err := json.Unmarshal(data, &migrated); err != nil {
+		return from, err
+	}
+	*cfg = migrated
+	return from, nil
+}
+
+// UnmarshalMigrated 将保存的配置 JSON 迁移到当前版本后覆盖到 cfg 上（缺失字段保留 cfg 原值）
+func UnmarshalMigrated(data []byte, cfg *Config) (int, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return 0, err
+	}
+	from, err := Migrate(raw)
+	if err != nil {
+		return from, err
+	}
+	migrated, err := json.Marshal(raw)
+	if err != nil {
+		return from, err
+	}
+	return from, json.Unmarshal(migrated, cfg)
+}
+
+// DecodeCandidate 解析待校验的配置 JSON：先迁移，再严格解码（未知字段与类型错误作为 FieldError 返回）
+func DecodeCandidate(data []byte) (*Config, int, []FieldError) {
+	var raw map[string]interface{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := dec.Decode(&raw); err != nil {
+		return nil, 0, []FieldError{{Field: "", Message: "invalid JSON: " + err.Error()}}
+	}
+	from, err := Migrate(raw)
+	if err != nil {
+		return nil, from, []FieldError{{Field: "schema_version", Message: err.Error()}}
+	}
+	normalized, err := json.Marshal(raw)
+	if err != nil {
+		return nil, from, []FieldError{{Field: "", Message: err.Error()}}
+	}
+
+	var cfg Config
+	var errs []FieldError
+	strict := json.NewDecoder(bytes.NewReader(normalized))
+	strict.DisallowUnknownFields()
+	for {
+		err := strict.Decode(&cfg)
+		if err == nil || errors.Is(err, io.EOF) {
+			break
+		}
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			errs = append(errs, FieldError{Field: typeErr.Field, Message: fmt.Sprintf("expected %s, got %s", typeErr.Type, typeErr.Value)})
+			break
+		}
+		msg := err.Error()
+		if field, ok := strings.CutPrefix(msg, "json: unknown field "); ok {
+			errs = append(errs, FieldError{Field: strings.Trim(field, `"`), Message: "unknown field"})
+			// 去掉未知字段后重新解码，以便继续报告其余问题
+			delete(raw, strings.Trim(field, `"`))
+			normalized, _ = json.Marshal(raw)
+			strict = json.NewDecoder(bytes.NewReader(normalized))
+			strict.DisallowUnknownFields()
+			cfg = Config{}
+			continue
+		}
+		errs = append(errs, FieldError{Message: msg})
+		break
+	}
+	return &cfg, from, errs
+}
+
+// Validate 检查取值范围、枚举值与互斥/依赖关系。cfg 应已应用 ApplyDefaults
+func Validate(cfg *Config) []FieldError {
+	var errs []FieldError
+	add := func(field, format string, args ...interface{}) {
+		errs = append(errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		add("port", "must be a number between 1 and 65535")
+	}
+	if mode, err := strconv.ParseUint(cfg.UnixSocketMode, 8, 32); err != nil || mode > 0777 {
+		add("unix_socket_mode", "%q must be an octal file mode such as 0660", cfg.UnixSocketMode)
+	}
+	if cfg.AdminListenAddr != "" {
+		if _, port, err := net.SplitHostPort(cfg.AdminListenAddr); err != nil {
+			add("admin_listen_addr", "%q must be host:port", cfg.AdminListenAddr)
+		} else if port == cfg.Port {
+			add("admin_listen_addr", "port must differ from port %s", cfg.Port)
+		}
+	}
+	if !strings.HasPrefix(cfg.AdminPath, "/") {
+		add("admin_path", "must start with /")
+	}
+	if cfg.BasePath != "" {
+		switch {
+		case !strings.HasPrefix(cfg.BasePath, "/"):
+			add("base_path", "must start with /")
+		case path.Clean(cfg.BasePath) != cfg.BasePath || strings.ContainsAny(cfg.BasePath, "?#"):
+			add("base_path", "%q must be a clean path without query or fragment", cfg.BasePath)
+		}
+	}
+
+	enums := []struct {
+		field, value string
+		allowed      []string
+	}{
+		{"store_mode", cfg.StoreMode, []string{"redis"}},
+		{"summary_cache_mode", cfg.SummaryCacheMode, []string{"memory", "redis", "off"}},
+		{"upstream_mode", cfg.UpstreamMode, []string{"sse", "ws"}},
+		{"orchids_impl", cfg.OrchidsImpl, []string{"legacy", "aiclient"}},
+		{"orchids_cc_entrypoint_mode", cfg.OrchidsCCEntrypointMode, []string{"auto", "keep", "strip"}},
+		{"cache_strategy", cfg.CacheStrategy, []string{"none", "off", "split", "mixed"}},
+		{"keep_alive_mode", cfg.KeepAliveMode, []string{"comment", "event"}},
+		{"system_prompt_trim_strategy", cfg.SystemPromptTrimStrategy, []string{"truncate", "summarize", "reject"}},
+		{"anthropic_beta_unknown", cfg.AnthropicBetaUnknown, []string{"reject", "ignore"}},
+	}
+	for _, e := range enums {
+		if !containsFold(e.allowed, e.value) {
+			add(e.field, "must be one of %s", strings.Join(e.allowed, ", "))
+		}
+	}
+
+	nonNegative := []struct {
+		field string
+		value int
+	}{
+		{"max_retries", cfg.MaxRetries},
+		{"retry_delay", cfg.RetryDelay},
+		{"account_switch_count", cfg.AccountSwitchCount},
+		{"request_timeout", cfg.RequestTimeout},
+		{"concurrency_limit", cfg.ConcurrencyLimit},
+		{"max_queue_length", cfg.MaxQueueLength},
+		{"output_filter_holdback", cfg.OutputFilterHoldback},
+		{"shutdown_retry_after", cfg.ShutdownRetryAfter},
+		{"concurrency_timeout", cfg.ConcurrencyTimeout},
+		{"context_max_tokens", cfg.ContextMaxTokens},
+		{"system_prompt_max_tokens", cfg.SystemPromptMaxTokens},
+		{"summary_cache_size", cfg.SummaryCacheSize},
+		{"redis_db", cfg.RedisDB},
+		{"anomaly_min_requests", cfg.AnomalyMinRequests},
+		{"request_signing_max_skew_seconds", cfg.RequestSigningMaxSkewSeconds},
+		{"models_cache_max_age", cfg.ModelsCacheMaxAge},
+		{"reasoning_status_interval", cfg.ReasoningStatusInterval},
+		{"tool_result_excerpt_bytes", cfg.ToolResultExcerptBytes},
+		{"conversation_artifact_min_bytes", cfg.ConversationArtifactMinBytes},
+	}
+	// 与 http.Server.Shutdown 的 30 秒超时配合，宽限期结束后还要留出发送 error 事件的时间
+	if cfg.ShutdownStreamGrace >= 30 {
+		add("shutdown_stream_grace", "must be less than 30 seconds")
+	}
+	for _, n := range nonNegative {
+		if n.value < 0 {
+			add(n.field, "must be >= 0")
+		}
+	}
+	if cfg.StallTimeout > 0 && cfg.StallAbortTimeout > 0 && cfg.StallAbortTimeout < cfg.StallTimeout {
+		add("stall_abort_timeout", "must be >= stall_timeout")
+	}
+
+	rates := []struct {
+		field string
+		value float64
+	}{
+		{"access_log_sample_rate", cfg.AccessLogSampleRate},
+		{"anomaly_error_rate", cfg.AnomalyErrorRate},
+		{"report_quota_warn_ratio", cfg.ReportQuotaWarnRatio},
+		{"chaos_delay_rate", cfg.ChaosDelayRate},
+		{"chaos_drop_rate", cfg.ChaosDropRate},
+		{"chaos_truncate_rate", cfg.ChaosTruncateRate},
+		{"chaos_corrupt_rate", cfg.ChaosCorruptRate},
+	}
+	for _, r := range rates {
+		if r.value > 1 {
+			add(r.field, "must be <= 1")
+		}
+	}
+
+	if strings.TrimSpace(cfg.RedisAddr) == "" {
+		add("redis_addr", "is required when store_mode is redis")
+	}
+	if strings.EqualFold(cfg.SummaryCacheMode, "redis") && strings.TrimSpace(cfg.SummaryCacheRedisAddr) == "" {
+		add("summary_cache_redis_addr", "is required when summary_cache_mode is redis")
+	}
+	if (cfg.ProxyUser != "" || cfg.ProxyPass != "") && cfg.ProxyHTTP == "" && cfg.ProxyHTTPS == "" {
+		add("proxy_user", "proxy credentials require proxy_http or proxy_https")
+	}
+	if cfg.LogSyslogAddr != "" && cfg.LogSyslogAddr != "local" &&
+		!strings.HasPrefix(cfg.LogSyslogAddr, "udp://") && !strings.HasPrefix(cfg.LogSyslogAddr, "tcp://") {
+		add("log_syslog_addr", "must be local, udp://host:port or tcp://host:port")
+	}
+	if cfg.LogLokiURL != "" && !strings.HasPrefix(cfg.LogLokiURL, "http://") && !strings.HasPrefix(cfg.LogLokiURL, "https://") {
+		add("log_loki_url", "must be an http(s) URL")
+	}
+	if cfg.UsageWebhookURL != "" && !strings.HasPrefix(cfg.UsageWebhookURL, "http://") && !strings.HasPrefix(cfg.UsageWebhookURL, "https://") {
+		add("usage_webhook_url", "must be an http(s) URL")
+	}
+	if cfg.UsageRedisStreamMaxLen < 0 {
+		add("usage_redis_stream_max_len", "must be >= 0")
+	}
+	for keyID, secret := range cfg.RequestSigningSecrets {
+		field := "request_signing_secrets." + keyID
+		if !isAPIKeyID(keyID) {
+			add(field, "key must be a 12-character api key id (key_id in /api/keys)")
+		} else if len(secret) < 16 {
+			add(field, "secret must be at least 16 characters")
+		}
+	}
+	if cfg.CanaryPercent < 0 || cfg.CanaryPercent > 100 {
+		add("canary_percent", "must be between 0 and 100")
+	}
+	if cfg.CanaryURL != "" {
+		if u, err := url.Parse(cfg.CanaryURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			add("canary_url", "must be an http(s) URL")
+		}
+	} else if cfg.CanaryPercent > 0 {
+		add("canary_url", "is required when canary_percent > 0")
+	}
+	if cfg.ReportSchedule != "" {
+		if !containsFold([]string{"daily", "weekly"}, cfg.ReportSchedule) {
+			add("report_schedule", "must be daily, weekly or empty")
+		}
+		if len(cfg.ReportRecipients) == 0 {
+			add("report_recipients", "is required when report_schedule is set")
+		}
+		if _, _, err := net.SplitHostPort(cfg.ReportSMTPAddr); err != nil {
+			add("report_smtp_addr", "must be host:port when report_schedule is set")
+		}
+	}
+	if cfg.ReportHour < 0 || cfg.ReportHour > 23 {
+		add("report_hour", "must be between 0 and 23")
+	}
+	for i, sink := range cfg.EventSinks {
+		field := fmt.Sprintf("event_sinks[%d]", i)
+		switch strings.ToLower(sink.Type) {
+		case "webhook":
+			if !strings.HasPrefix(sink.URL, "http://") && !strings.HasPrefix(sink.URL, "https://") {
+				add(field, "webhook url must be an http(s) URL")
+			}
+		case "redis_stream":
+		case "nats":
+			if u, err := url.Parse(sink.URL); err != nil || u.Scheme != "nats" || u.Host == "" {
+				add(field, "nats url must look like nats://host:port")
+			}
+		case "kafka":
+			add(field, "kafka is not supported (bridge via nats or redis_stream)")
+		default:
+			add(field, "unknown type %q (want webhook, redis_stream or nats)", sink.Type)
+		}
+		if sink.MaxLen < 0 {
+			add(field, "max_len must be >= 0")
+		}
+		for _, ev := range sink.Events {
+			if strings.TrimSpace(ev) == "" {
+				add(field, "events must not contain empty names")
+			}
+		}
+	}
+	for channel, policy := range cfg.ChannelModels {
+		field := "channel_models." + channel
+		if !slices.Contains(ModelChannels, channel) {
+			add(field, "unknown channel (want public, orchids or warp)")
+			continue
+		}
+		for _, p := range append(append([]string{}, policy.Allow...), policy.Deny...) {
+			if strings.TrimSpace(p) == "" {
+				add(field, "patterns must not be empty")
+			} else if _, err := path.Match(p, ""); err != nil {
+				add(field, "invalid pattern %q", p)
+			}
+		}
+	}
+	if cfg.ToolResultMaxBytes > 0 && cfg.ToolResultExcerptBytes >= cfg.ToolResultMaxBytes {
+		add("tool_result_excerpt_bytes", "must be smaller than tool_result_max_bytes")
+	}
+	if cfg.ReasoningDisplay != "" && !slices.Contains(ReasoningDisplayModes, cfg.ReasoningDisplay) {
+		add("reasoning_display", "must be passthrough, status, hide or empty")
+	}
+	for keyID, mode := range cfg.ReasoningDisplayKeys {
+		field := "reasoning_display_keys." + keyID
+		if !isAPIKeyID(keyID) {
+			add(field, "key must be a 12-character api key id (key_id in /api/keys)")
+		} else if !slices.Contains(ReasoningDisplayModes, mode) {
+			add(field, "must be passthrough, status or hide")
+		}
+	}
+	if strings.ContainsAny(cfg.AttributionLabel, "\r\n\x00") {
+		add("attribution_label", "must not contain line breaks (sent as a response header)")
+	}
+	if strings.ContainsAny(cfg.PostTranslationSourceLanguage, " \r\n,;") {
+		add("post_translation_source_language", "must be a single language tag such as en")
+	}
+	if cfg.OutputFilter != "" && !slices.Contains(OutputFilterModes, strings.ToLower(cfg.OutputFilter)) {
+		add("output_filter", "must be off, mask or empty")
+	}
+	for keyID, mode := range cfg.OutputFilterKeys {
+		field := "output_filter_keys." + keyID
+		if !isAPIKeyID(keyID) {
+			add(field, "key must be a 12-character api key id (key_id in /api/keys)")
+		} else if !slices.Contains(OutputFilterModes, strings.ToLower(mode)) {
+			add(field, "must be off or mask")
+		}
+	}
+	for i, rule := range cfg.OutputFilterRules {
+		field := fmt.Sprintf("output_filter_rules[%d]", i)
+		switch {
+		case (rule.Pattern == "") == (rule.Preset == ""):
+			add(field, "must set exactly one of pattern or preset")
+		case rule.Preset != "" && !slices.Contains(OutputFilterPresets, rule.Preset):
+			add(field+".preset", "unknown preset %q (want secrets)", rule.Preset)
+		case rule.Pattern != "":
+			if _, err := regexp.Compile(rule.Pattern); err != nil {
+				add(field+".pattern", "%v", err)
+			}
+		}
+	}
+	if cfg.ToolInjectionGuard != "" && !slices.Contains(ToolInjectionModes, strings.ToLower(cfg.ToolInjectionGuard)) {
+		add("tool_injection_guard", "must be off, log, neutralize or empty")
+	}
+	for keyID, mode := range cfg.ToolInjectionGuardKeys {
+		field := "tool_injection_guard_keys." + keyID
+		if !isAPIKeyID(keyID) {
+			add(field, "key must be a 12-character api key id (key_id in /api/keys)")
+		} else if !slices.Contains(ToolInjectionModes, strings.ToLower(mode)) {
+			add(field, "must be off, log or neutralize")
+		}
+	}
+	for keyID, weight := range cfg.FairShareWeights {
+		field := "fair_share_weights." + keyID
+		if !isAPIKeyID(keyID) {
+			add(field, "key must be a 12-character api key id (key_id in /api/keys)")
+		} else if weight < 1 {
+			add(field, "must be >= 1")
+		}
+	}
+	for prefix, channel := range cfg.ChannelPrefixes {
+		field := "channel_prefixes." + prefix
+		switch {
+		case !slices.Contains(PrefixChannels, channel):
+			add(field, "unknown channel %q (want orchids or warp)", channel)
+		case len(prefix) < 2 || prefix[0] != '/' || strings.Count(prefix, "/") != 1 || prefix != strings.ToLower(prefix):
+			add(field, "must be a single lowercase path segment like /ai")
+		case slices.Contains(reservedPrefixes, prefix) || prefix == strings.TrimRight(cfg.AdminPath, "/"):
+			add(field, "conflicts with a built-in route")
+		}
+	}
+	for i, proxy := range cfg.TrustedProxies {
+		proxy = strings.TrimSpace(proxy)
+		if _, err := netip.ParsePrefix(proxy); err == nil {
+			continue
+		}
+		if _, err := netip.ParseAddr(proxy); err != nil {
+			add(fmt.Sprintf("trusted_proxies[%d]", i), "%q must be an IP address or CIDR", proxy)
+		}
+	}
+	for group, policy := range cfg.CORS {
+		field := "cors." + group
+		if !containsFold([]string{"public", "api", "admin"}, group) {
+			add(field, "unknown route group (want public, api or admin)")
+			continue
+		}
+		for _, origin := range policy.AllowedOrigins {
+			origin = strings.TrimSpace(origin)
+			if origin == "*" {
+				if policy.AllowCredentials {
+					add(field, "allow_credentials cannot be used with origin *")
+				}
+				continue
+			}
+			if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" {
+				add(field, "invalid origin %q (want *, scheme://host[:port] or scheme://*.domain)", origin)
+			}
+		}
+		if policy.MaxAge < 0 {
+			add(field, "max_age must be >= 0")
+		}
+	}
+	for effort, mode := range cfg.ReasoningEffortMap {
+		if !containsFold([]string{"minimal", "low", "medium", "high"}, effort) {
+			add("reasoning_effort_map", "unknown reasoning_effort %q (want minimal, low, medium or high)", effort)
+		}
+		if !containsFold([]string{"thinking", "standard"}, mode) {
+			add("reasoning_effort_map", "mode for %q must be thinking or standard", effort)
+		}
+	}
+	for _, source := range cfg.ModelSyncSources {
+		if !containsFold([]string{"orchids", "warp"}, source) {
+			add("model_sync_sources", "unknown source %q (want orchids or warp)", source)
+		}
+	}
+	return errs
+}
+
+func containsFold(list []string, value string) bool {
+	value = strings.TrimSpace(value)
+	for _, item := range list {
+		if strings.EqualFold(item, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAPIKeyID 判断是否为 API Key 摘要前 12 位（小写十六进制）
+func isAPIKeyID(s string) bool {
+	if len(s) != 12 {
+		return false
+	}
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,180 @@
+package config
+
+import "testing"
+
+func TestDecodeCandidate_MigratesAndReportsFields(t *testing.T) {
+	cfg, from, errs := DecodeCandidate([]byte(`{"cache_strategy":"mix","bogus":1,"port":"3002","redis_addr":"127.0.0.1:6379"}`))
+	if from != 0 {
+		t.Fatalf("migrated_from = %d, want 0", from)
+	}
+	if len(errs) != 1 || errs[0].Field != "bogus" {
+		t.Fatalf("errs = %+v, want unknown field bogus", errs)
+	}
+	if cfg.CacheStrategy != "mixed" || cfg.SchemaVersion != CurrentSchemaVersion {
+		t.Fatalf("migration not applied: cache_strategy=%q schema_version=%d", cfg.CacheStrategy, cfg.SchemaVersion)
+	}
+
+	if _, _, errs := DecodeCandidate([]byte(`{"max_retries":"3"}`)); len(errs) != 1 || errs[0].Field != "max_retries" {
+		t.Fatalf("type error not reported: %+v", errs)
+	}
+	if _, _, errs := DecodeCandidate([]byte(`{"schema_version":99}`)); len(errs) != 1 || errs[0].Field != "schema_version" {
+		t.Fatalf("newer schema accepted: %+v", errs)
+	}
+	if _, _, errs := DecodeCandidate([]byte(`{"schema_version":-1}`)); len(errs) != 1 || errs[0].Field != "schema_version" {
+		t.Fatalf("negative schema accepted: %+v", errs)
+	}
+}
+
+func TestMigrate_RejectsNegativeVersion(t *testing.T) {
+	if _, err := Migrate(map[string]interface{}{"schema_version": float64(-1)}); err == nil {
+		t.Fatal("Migrate accepted schema_version -1")
+	}
+	var cfg Config
+	if _, err := UnmarshalMigrated([]byte(`{"schema_version":-3}`), &cfg); err == nil {
+		t.Fatal("UnmarshalMigrated accepted schema_version -3")
+	}
+	if _, err := MigrateConfig(&Config{SchemaVersion: -1}); err == nil {
+		t.Fatal("MigrateConfig accepted schema_version -1")
+	}
+}
+
+func TestValidate_RangesAndDependencies(t *testing.T) {
+	cfg := &Config{RedisAddr: "127.0.0.1:6379"}
+	ApplyDefaults(cfg)
+	if errs := Validate(cfg); len(errs) != 0 {
+		t.Fatalf("defaults should be valid, got %+v", errs)
+	}
+
+	cfg.Port = "70000"
+	cfg.UpstreamMode = "grpc"
+	cfg.StallTimeout, cfg.StallAbortTimeout = 30, 10
+	cfg.ProxyUser, cfg.ProxyHTTP, cfg.ProxyHTTPS = "u", "", ""
+	cfg.SystemPromptTrimStrategy = "drop"
+	cfg.CanaryPercent = 150
+	cfg.RequestSigningSecrets = map[string]string{"sk-abc": "0123456789abcdef", "0123456789ab": "short"}
+	got := map[string]bool{}
+	for _, e := range Validate(cfg) {
+		got[e.Field] = true
+	}
+	for _, field := range []string{"port", "upstream_mode", "stall_abort_timeout", "proxy_user", "system_prompt_trim_strategy", "canary_percent", "canary_url", "request_signing_secrets.sk-abc", "request_signing_secrets.0123456789ab"} {
+		if !got[field] {
+			t.Errorf("expected error for %s, got %v", field, got)
+		}
+	}
+}
+
+func TestValidate_CORS(t *testing.T) {
+	cfg := &Config{RedisAddr: "127.0.0.1:6379"}
+	ApplyDefaults(cfg)
+	cfg.CORS = map[string]CORSPolicy{
+		"api":     {AllowedOrigins: []string{"https://app.example.com", "https://*.example.com", "http://localhost:5173"}},
+		"admin":   {AllowedOrigins: []string{"*"}, AllowCredentials: true},
+		"public":  {AllowedOrigins: []string{"example.com", "https://a.com/path"}},
+		"imagine": {AllowedOrigins: []string{"*"}},
+	}
+	got := map[string]int{}
+	for _, e := range Validate(cfg) {
+		got[e.Field]++
+	}
+	if got["cors.api"] != 0 || got["cors.admin"] != 1 || got["cors.public"] != 2 || got["cors.imagine"] != 1 {
+		t.Fatalf("errors = %v", got)
+	}
+}
+
+func TestChannelModels(t *testing.T) {
+	cfg := &Config{RedisAddr: "127.0.0.1:6379"}
+	ApplyDefaults(cfg)
+	cfg.ChannelModels = map[string]ChannelModelPolicy{
+		"orchids": {Allow: []string{"claude-*"}, Deny: []string{"*-thinking"}},
+		"warp":    {Deny: []string{"gpt-5-*"}},
+		"public":  {Allow: []string{"[bad"}},
+		"Imagine": {Allow: []string{"*"}},
+	}
+	got := map[string]int{}
+	for _, e := range Validate(cfg) {
+		got[e.Field]++
+	}
+	if got["channel_models.orchids"] != 0 || got["channel_models.warp"] != 0 || got["channel_models.public"] != 1 || got["channel_models.Imagine"] != 1 {
+		t.Fatalf("errors = %v", got)
+	}
+
+	delete(cfg.ChannelModels, "public")
+	cases := []struct {
+		channel, model string
+		want           bool
+	}{
+		{"orchids", "claude-sonnet-4-5", true},
+		{"orchids", "Claude-Opus-4-5", true},
+		{"orchids", "claude-opus-4-5-thinking", false},
+		{"orchids", "gpt-4o", false},
+		{"warp", "gpt-5-high", false},
+		{"warp", "auto", true},
+		{"", "anything", true},
+	}
+	for _, c := range cases {
+		if got := cfg.ModelAllowed(c.channel, c.model); got != c.want {
+			t.Errorf("ModelAllowed(%q, %q) = %v, want %v", c.channel, c.model, got, c.want)
+		}
+	}
+}
+
+func TestReasoningDisplay(t *testing.T) {
+	cfg := &Config{RedisAddr: "127.0.0.1:6379"}
+	ApplyDefaults(cfg)
+	cfg.ReasoningDisplay = "summary"
+	cfg.ReasoningDisplayKeys = map[string]string{
+		"0123456789ab": "status",
+		"0123456789ac": "verbose",
+		"short":        "hide",
+	}
+	got := map[string]int{}
+	for _, e := range Validate(cfg) {
+		got[e.Field]++
+	}
+	if got["reasoning_display"] != 1 || got["reasoning_display_keys.0123456789ab"] != 0 ||
+		got["reasoning_display_keys.0123456789ac"] != 1 || got["reasoning_display_keys.short"] != 1 {
+		t.Fatalf("errors = %v", got)
+	}
+
+	cfg.ReasoningDisplay = ""
+	cfg.SuppressThinking = true
+	if mode := cfg.ReasoningDisplayFor(""); mode != ReasoningHide {
+		t.Fatalf("default with suppress_thinking = %q", mode)
+	}
+	if mode := cfg.ReasoningDisplayFor("0123456789ab"); mode != ReasoningStatus {
+		t.Fatalf("key override = %q", mode)
+	}
+	cfg.ReasoningDisplay = ReasoningPassthrough
+	if mode := cfg.ReasoningDisplayFor("ffffffffffff"); mode != ReasoningPassthrough {
+		t.Fatalf("reasoning_display = %q", mode)
+	}
+}
+
+func TestChannelPrefixes(t *testing.T) {
+	cfg := &Config{RedisAddr: "127.0.0.1:6379"}
+	ApplyDefaults(cfg)
+	cfg.ChannelPrefixes = map[string]string{
+		"/ai":     "orchids",
+		"/fast":   "warp",
+		"/grok":   "grok",
+		"/a/b":    "orchids",
+		"/API":    "orchids",
+		"/api":    "orchids",
+		"/admin":  "warp",
+		"noslash": "warp",
+	}
+	got := map[string]int{}
+	for _, e := range Validate(cfg) {
+		got[e.Field]++
+	}
+	for _, ok := range []string{"/ai", "/fast"} {
+		if got["channel_prefixes."+ok] != 0 {
+			t.Errorf("%s rejected: %v", ok, got)
+		}
+	}
+	for _, bad := range []string{"/grok", "/a/b", "/API", "/api", "/admin", "noslash"} {
+		if got["channel_prefixes."+bad] != 1 {
+			t.Errorf("%s not rejected: %v", bad, got)
+		}
+	}
+}
@@ -20,10 +20,25 @@ type Logger struct {
 	outFile    *os.File
 	mu         sync.Mutex
 	startTime  time.Time
+	meta       entryMeta
 }
 
 // New 创建新的调试日志记录器
 func New(enabled bool, sseEnabled bool) *Logger {
+	return NewWithTrace(enabled, sseEnabled, "")
+}
+
+// NewWithTrace 创建调试日志记录器，并在 0_meta.json 中记录 trace ID，供按 trace 检索
+func NewWithTrace(enabled bool, sseEnabled bool, traceID string) *Logger {
+	return newLogger(enabled, sseEnabled, traceID, false)
+}
+
+// NewCapture 为单个请求开启完整捕获（含 SSE），不受全局 debug_enabled 影响
+func NewCapture(traceID string) *Logger {
+	return newLogger(true, true, traceID, true)
+}
+
+func newLogger(enabled bool, sseEnabled bool, traceID string, capture bool) *Logger {
 	if !enabled {
 		return &Logger{enabled: false}
 	}
@@ -35,25 +50,37 @@ func New(enabled bool, sseEnabled bool) *Logger {
 	if _, err := rand.Read(randBytes[:]); err == nil {
 		suffix = hex.EncodeToString(randBytes[:])
 	}
-	dir := filepath.Join("debug-logs", fmt.Sprintf("%s_%s", timestamp, suffix))
+	dir := filepath.Join(logsRoot, fmt.Sprintf("%s_%s", timestamp, suffix))
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return &Logger{enabled: false}
 	}
 
-	return &Logger{
+	l := &Logger{
 		enabled:    true,
 		sseEnabled: sseEnabled,
 		dir:        dir,
-		startTime:  time.Now(),
+		startTime:  now,
+		meta:       entryMeta{TraceID: traceID, StartedAt: now, Capture: capture},
 	}
+	l.writeJSON(metaFile, l.meta)
+	return l
+}
+
+// SetPath 在 0_meta.json 中记录请求路径，供 cmd/replay 回放到相同的接口
+func (l *Logger) SetPath(path string) {
+	if !l.enabled {
+		return
+	}
+	l.meta.Path = path
+	l.writeJSON(metaFile, l.meta)
 }
 
 // CleanupAllLogs 清空所有调试日志（启动时调用）
 func CleanupAllLogs() error {
-	if err := os.RemoveAll("debug-logs"); err != nil {
+	if err := os.RemoveAll(logsRoot); err != nil {
 		return err
 	}
-	return os.MkdirAll("debug-logs", 0755)
+	return os.MkdirAll(logsRoot, 0755)
 }
 
 // Dir 返回日志目录
@@ -147,6 +174,11 @@ func (l *Logger) LogUpstreamSSE(eventType string, data string) {
 	fmt.Fprintf(l.rawFile, "[%dms] %s: %s\n", elapsed, eventType, data)
 }
 
+// SSEEnabled 返回是否记录客户端 SSE 输出，调用方可据此跳过日志数据的准备
+func (l *Logger) SSEEnabled() bool {
+	return l != nil && l.enabled && l.sseEnabled
+}
+
 // LogOutputSSE 记录 5. 转换给客户端的 SSE（追加写入）
 func (l *Logger) LogOutputSSE(event string, data string) {
 	if !l.enabled || !l.sseEnabled {
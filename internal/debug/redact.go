@@ -0,0 +1,23 @@
+package debug
+
+import "regexp"
+
+// 下载调试日志时的脱敏规则：凭据类字段、Bearer token、JWT、API Key 与邮箱地址
+var redactRules = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`(?i)("(?:authorization|cookie|set-cookie|x-api-key|client_cookie|session_cookie|refresh_token|token|password|admin_pass|admin_token)"\s*:\s*")(?:[^"\\]|\\.)*"`), `${1}[REDACTED]"`},
+	{regexp.MustCompile(`(?i)\bBearer\s+[A-Za-z0-9._~+/=-]+`), "Bearer [REDACTED]"},
+	{regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]*`), "[REDACTED_JWT]"},
+	{regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{8,}`), "[REDACTED_KEY]"},
+	{regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`), "[REDACTED_EMAIL]"},
+}
+
+// Redact 对调试日志内容脱敏，用于通过管理接口导出
+func Redact(data []byte) []byte {
+	for _, rule := range redactRules {
+		data = rule.re.ReplaceAll(data, []byte(rule.repl))
+	}
+	return data
+}
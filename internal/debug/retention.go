@@ -0,0 +1,193 @@
+package debug
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// logsRoot 为调试日志根目录，每个请求一个子目录
+const logsRoot = "debug-logs"
+
+// metaFile 记录请求目录对应的 trace ID
+const metaFile = "0_meta.json"
+
+// ErrEntryNotFound 表示指定的调试日志不存在
+var ErrEntryNotFound = errors.New("debug log not found")
+
+type entryMeta struct {
+	TraceID   string    `json:"trace_id,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	Capture   bool      `json:"capture,omitempty"`
+}
+
+// Entry 描述一个请求的调试日志目录
+type Entry struct {
+	ID        string    `json:"id"`
+	TraceID   string    `json:"trace_id,omitempty"`
+	Path      string    `json:"path,omitempty"`
+	StartedAt time.Time `json:"started_at"`
+	Capture   bool      `json:"capture,omitempty"`
+	SizeBytes int64     `json:"size_bytes"`
+	Files     []string  `json:"files"`
+}
+
+// List 返回所有调试日志目录，按开始时间倒序
+func List() ([]Entry, error) {
+	dirs, err := os.ReadDir(logsRoot)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, 0, len(dirs))
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		entry, err := readEntry(d.Name())
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].StartedAt.After(entries[j].StartedAt) })
+	return entries, nil
+}
+
+func readEntry(id string) (Entry, error) {
+	dir := filepath.Join(logsRoot, id)
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return Entry{}, err
+	}
+	entry := Entry{ID: id}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		entry.SizeBytes += info.Size()
+		entry.Files = append(entry.Files, f.Name())
+		if entry.StartedAt.IsZero() || info.ModTime().Before(entry.StartedAt) {
+			entry.StartedAt = info.ModTime()
+		}
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, metaFile)); err == nil {
+		var meta entryMeta
+		if json.Unmarshal(data, &meta) == nil {
+			entry.TraceID = meta.TraceID
+			entry.Path = meta.Path
+			entry.Capture = meta.Capture
+			if !meta.StartedAt.IsZero() {
+				entry.StartedAt = meta.StartedAt
+			}
+		}
+	}
+	return entry, nil
+}
+
+// Find 按目录 ID 或 trace ID 查找调试日志；同一 trace 可能对应多个目录
+func Find(idOrTrace string) ([]Entry, error) {
+	idOrTrace = strings.TrimSpace(idOrTrace)
+	if idOrTrace == "" || !validEntryID(idOrTrace) {
+		return nil, ErrEntryNotFound
+	}
+	entries, err := List()
+	if err != nil {
+		return nil, err
+	}
+	var out []Entry
+	for _, e := range entries {
+		if e.ID == idOrTrace || e.TraceID == idOrTrace {
+			out = append(out, e)
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrEntryNotFound
+	}
+	return out, nil
+}
+
+// validEntryID 拒绝包含路径分隔符或 ".." 的 ID，避免读取日志目录以外的文件
+func validEntryID(id string) bool {
+	return id != "." && !strings.Contains(id, "..") && !strings.ContainsAny(id, `/\`)
+}
+
+// WriteArchive 将 entries 打包为 zip 写入 w，文件内容经过 Redact 脱敏
+func WriteArchive(w io.Writer, entries []Entry) error {
+	zw := zip.NewWriter(w)
+	if err := AppendArchive(zw, "", entries); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// AppendArchive 将 entries 以 prefix 为前缀追加到已有的 zip 中，文件内容经过 Redact 脱敏
+func AppendArchive(zw *zip.Writer, prefix string, entries []Entry) error {
+	for _, e := range entries {
+		for _, name := range e.Files {
+			data, err := os.ReadFile(filepath.Join(logsRoot, e.ID, name))
+			if err != nil {
+				continue
+			}
+			fw, err := zw.Create(prefix + e.ID + "/" + name)
+			if err != nil {
+				return err
+			}
+			if _, err := fw.Write(Redact(data)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Retention 为调试日志保留策略，值 <= 0 的项不限制
+type Retention struct {
+	MaxAge        time.Duration
+	MaxTotalBytes int64
+}
+
+// Prune 删除超过 MaxAge 的调试日志，并在总大小超过 MaxTotalBytes 时从最旧的开始删除。
+// 返回删除的目录数
+func Prune(r Retention) (int, error) {
+	entries, err := List()
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	for _, e := range entries {
+		total += e.SizeBytes
+	}
+
+	removed := 0
+	cutoff := time.Now().Add(-r.MaxAge)
+	// entries 按时间倒序，从最旧的一端开始删除
+	for i := len(entries) - 1; i >= 0; i-- {
+		e := entries[i]
+		expired := r.MaxAge > 0 && e.StartedAt.Before(cutoff)
+		oversize := r.MaxTotalBytes > 0 && total > r.MaxTotalBytes
+		if !expired && !oversize {
+			continue
+		}
+		if err := os.RemoveAll(filepath.Join(logsRoot, e.ID)); err != nil {
+			return removed, err
+		}
+		total -= e.SizeBytes
+		removed++
+	}
+	return removed, nil
+}
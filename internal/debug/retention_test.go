@@ -0,0 +1,101 @@
+package debug
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// chdirTemp 切换到临时目录，使 debug-logs 写入隔离的位置
+func chdirTemp(t *testing.T) {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+}
+
+func TestFindAndArchiveByTraceID(t *testing.T) {
+	chdirTemp(t)
+
+	l := NewWithTrace(true, false, "trace-abc")
+	l.SetPath("/orchids/v1/messages")
+	l.LogUpstreamRequest("https://example.com", map[string]string{"Authorization": "Bearer secret-token"}, map[string]string{"email": "dev@example.com"})
+	l.Close()
+	NewWithTrace(true, false, "other").Close()
+
+	entries, err := Find("trace-abc")
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("Find = %v, %v", entries, err)
+	}
+	if entries[0].Path != "/orchids/v1/messages" {
+		t.Fatalf("Path = %q", entries[0].Path)
+	}
+	if _, err := Find("../etc"); err != ErrEntryNotFound {
+		t.Fatalf("expected ErrEntryNotFound for traversal, got %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteArchive(&buf, entries); err != nil {
+		t.Fatalf("WriteArchive: %v", err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var found bool
+	for _, f := range zr.File {
+		if !strings.HasSuffix(f.Name, "3_upstream_request.json") {
+			continue
+		}
+		found = true
+		rc, _ := f.Open()
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		if bytes.Contains(data, []byte("secret-token")) || bytes.Contains(data, []byte("dev@example.com")) {
+			t.Fatalf("archive not redacted: %s", data)
+		}
+	}
+	if !found {
+		t.Fatalf("upstream request missing from archive")
+	}
+}
+
+func TestPrune_ByAgeAndSize(t *testing.T) {
+	chdirTemp(t)
+
+	old := NewWithTrace(true, false, "old")
+	old.writeJSON(metaFile, entryMeta{TraceID: "old", StartedAt: time.Now().Add(-48 * time.Hour)})
+	old.Close()
+	for i := 0; i < 3; i++ {
+		l := NewWithTrace(true, false, "recent")
+		l.writeFile("2_converted_prompt.md", strings.Repeat("x", 1000))
+		l.writeJSON(metaFile, entryMeta{TraceID: "recent", StartedAt: time.Now().Add(time.Duration(i) * time.Second)})
+		l.Close()
+	}
+
+	removed, err := Prune(Retention{MaxAge: 24 * time.Hour, MaxTotalBytes: 2500})
+	if err != nil {
+		t.Fatalf("Prune: %v", err)
+	}
+	if removed != 2 {
+		t.Fatalf("removed = %d, want 2 (expired + oldest over size)", removed)
+	}
+	entries, _ := List()
+	if len(entries) != 2 {
+		t.Fatalf("remaining = %d, want 2", len(entries))
+	}
+	dirs, _ := os.ReadDir(filepath.Join(logsRoot))
+	if len(dirs) != 2 {
+		t.Fatalf("dirs on disk = %d, want 2", len(dirs))
+	}
+}
@@ -0,0 +1,178 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"orchids-api/internal/metrics"
+)
+
+const (
+	flushInterval = time.Second
+	maxBatch      = 200
+	// maxPending 为目标不可用时最多保留的事件数
+	maxPending  = 10000
+	sendTimeout = 10 * time.Second
+)
+
+// BatcherOptions 控制批量发送的节奏与缓冲上限，零值字段使用事件目标的默认值
+type BatcherOptions struct {
+	FlushInterval time.Duration
+	// MaxBatch 为单次 send 的最大条数，缓冲达到该值时立即发送
+	MaxBatch int
+	// MaxPending 为目标不可用时最多保留的条数，超出后丢弃最旧的
+	MaxPending  int
+	SendTimeout time.Duration
+	// OnDrop 在丢弃 n 条最旧的条目时调用；调用时持有缓冲锁，不能阻塞
+	OnDrop func(n int)
+	// OnSend 在每批发送后调用，err 为 nil 表示发送成功
+	OnSend func(n int, err error)
+}
+
+// Batcher 在后台按批调用 send，失败的批次放回队首等待下次重试。
+// 事件目标与 logsink 的 Loki 推送共用这一实现
+type Batcher[T any] struct {
+	opts BatcherOptions
+	send func(ctx context.Context, batch []T) error
+
+	mu      sync.Mutex
+	pending []T
+
+	kick chan struct{}
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+// NewBatcher 创建 Batcher 并启动后台发送
+func NewBatcher[T any](opts BatcherOptions, send func(ctx context.Context, batch []T) error) *Batcher[T] {
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = flushInterval
+	}
+	if opts.MaxBatch <= 0 {
+		opts.MaxBatch = maxBatch
+	}
+	if opts.MaxPending <= 0 {
+		opts.MaxPending = maxPending
+	}
+	if opts.SendTimeout <= 0 {
+		opts.SendTimeout = sendTimeout
+	}
+	b := &Batcher[T]{
+		opts: opts,
+		send: send,
+		kick: make(chan struct{}, 1),
+		done: make(chan struct{}),
+	}
+	b.wg.Add(1)
+	go b.loop()
+	return b
+}
+
+// batcher 为事件目标使用的 Batcher，按 name 记录 bus 指标并在发送失败时输出日志
+type batcher = Batcher[Event]
+
+func newBatcher(name string, send func(ctx context.Context, batch []Event) error) *batcher {
+	return NewBatcher(BatcherOptions{
+		OnDrop: func(n int) { metrics.BusEvents.WithLabelValues(name, "dropped").Add(float64(n)) },
+		OnSend: func(n int, err error) {
+			if err != nil {
+				metrics.BusEvents.WithLabelValues(name, "failed").Add(float64(n))
+				slog.Warn("Event delivery failed", "sink", name, "events", n, "error", err)
+				return
+			}
+			metrics.BusEvents.WithLabelValues(name, "sent").Add(float64(n))
+		},
+	}, send)
+}
+
+func (b *Batcher[T]) Publish(item T) {
+	b.mu.Lock()
+	if len(b.pending) >= b.opts.MaxPending {
+		b.pending = b.pending[1:]
+		b.dropped(1)
+	}
+	b.pending = append(b.pending, item)
+	full := len(b.pending) >= b.opts.MaxBatch
+	b.mu.Unlock()
+
+	if full {
+		select {
+		case b.kick <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Pending 返回尚未发送成功的条数
+func (b *Batcher[T]) Pending() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.pending)
+}
+
+func (b *Batcher[T]) dropped(n int) {
+	if b.opts.OnDrop != nil {
+		b.opts.OnDrop(n)
+	}
+}
+
+func (b *Batcher[T]) loop() {
+	defer b.wg.Done()
+	ticker := time.NewTicker(b.opts.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-b.kick:
+		case <-b.done:
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), b.opts.SendTimeout)
+		b.flush(ctx)
+		cancel()
+	}
+}
+
+func (b *Batcher[T]) flush(ctx context.Context) error {
+	for {
+		b.mu.Lock()
+		n := min(len(b.pending), b.opts.MaxBatch)
+		batch := b.pending[:n:n]
+		b.pending = b.pending[n:]
+		b.mu.Unlock()
+		if n == 0 {
+			return nil
+		}
+
+		err := b.send(ctx, batch)
+		if b.opts.OnSend != nil {
+			b.opts.OnSend(len(batch), err)
+		}
+		if err != nil {
+			b.mu.Lock()
+			b.pending = append(batch, b.pending...)
+			if over := len(b.pending) - b.opts.MaxPending; over > 0 {
+				b.pending = b.pending[over:]
+				b.dropped(over)
+			}
+			b.mu.Unlock()
+			return err
+		}
+	}
+}
+
+// Close 停止后台发送并尝试发出剩余条目
+func (b *Batcher[T]) Close() error {
+	select {
+	case <-b.done:
+		return nil
+	default:
+	}
+	close(b.done)
+	b.wg.Wait()
+	ctx, cancel := context.WithTimeout(context.Background(), b.opts.SendTimeout)
+	defer cancel()
+	return b.flush(ctx)
+}
@@ -0,0 +1,195 @@
+// Package events 为系统事件总线：请求完成、账号状态变化、后台任务结束等事件
+// 推送到 webhook、Redis Stream 或 NATS，供计费、告警与运维系统订阅。
+// 发送在后台批量进行，不阻塞调用方；目标不可用时在内存中保留有限的待发事件，超出后丢弃最旧的
+package events
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"orchids-api/internal/config"
+)
+
+// 事件类型
+const (
+	RequestCompleted     = "request.completed"
+	AccountStatusChanged = "account.status_changed"
+	TaskFinished         = "task.finished"
+)
+
+// Event 为事件信封，Data 为对应类型的数据（Usage / AccountStatus / Task）
+type Event struct {
+	ID   string          `json:"id"`
+	Type string          `json:"type"`
+	Time time.Time       `json:"time"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Usage 为 request.completed 的数据：一次消息请求的用量
+type Usage struct {
+	ID            string    `json:"id"`
+	TraceID       string    `json:"trace_id,omitempty"`
+	Timestamp     time.Time `json:"timestamp"`
+	APIKeyID      string    `json:"api_key_id,omitempty"`
+	Channel       string    `json:"channel"`
+	Model         string    `json:"model"`
+	UpstreamModel string    `json:"upstream_model,omitempty"`
+	AccountID     int64     `json:"account_id,omitempty"`
+	InputTokens   int       `json:"input_tokens"`
+	OutputTokens  int       `json:"output_tokens"`
+	// Cost 按模型官方标价估算，未知模型为 0；不代表上游账号的实际计费
+	Cost       float64 `json:"cost"`
+	Currency   string  `json:"currency,omitempty"`
+	Stream     bool    `json:"stream"`
+	DurationMs int64   `json:"duration_ms"`
+}
+
+// AccountStatus 为 account.status_changed 的数据，Status 为空表示账号已恢复
+type AccountStatus struct {
+	AccountID   int64  `json:"account_id"`
+	AccountName string `json:"account_name,omitempty"`
+	AccountType string `json:"account_type,omitempty"`
+	Previous    string `json:"previous"`
+	Status      string `json:"status"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// Task 为 task.finished 的数据，Task / Result 与 orchids_admin_tasks_total 的标签一致
+type Task struct {
+	Task       string `json:"task"`
+	Result     string `json:"result"`
+	AccountID  int64  `json:"account_id,omitempty"`
+	Detail     string `json:"detail,omitempty"`
+	DurationMs int64  `json:"duration_ms,omitempty"`
+}
+
+// Sink 接收事件，Publish 不得阻塞
+type Sink interface {
+	Publish(e Event)
+	Close() error
+}
+
+type subscription struct {
+	sink   Sink
+	events []string
+}
+
+// Bus 将事件分发给订阅了该类型的目标。nil *Bus 可安全调用，未配置目标时调用方无需判空
+type Bus struct {
+	subs []subscription
+}
+
+// Subscribe 添加目标，events 为订阅的事件类型（支持 "account.*" 前缀匹配），为空表示全部
+func (b *Bus) Subscribe(sink Sink, events ...string) {
+	b.subs = append(b.subs, subscription{sink: sink, events: events})
+}
+
+// Publish 发出一个事件；没有目标订阅该类型时不做序列化
+func (b *Bus) Publish(eventType string, data interface{}) {
+	if b == nil {
+		return
+	}
+	var e Event
+	for _, sub := range b.subs {
+		if !matches(sub.events, eventType) {
+			continue
+		}
+		if e.ID == "" {
+			raw, err := json.Marshal(data)
+			if err != nil {
+				slog.Warn("Event marshal failed", "type", eventType, "error", err)
+				return
+			}
+			e = Event{ID: NewID("evt_"), Type: eventType, Time: time.Now().UTC(), Data: raw}
+		}
+		sub.sink.Publish(e)
+	}
+}
+
+// Close 停止所有目标并尝试发出剩余事件
+func (b *Bus) Close() error {
+	if b == nil {
+		return nil
+	}
+	var errs []error
+	for _, sub := range b.subs {
+		errs = append(errs, sub.sink.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func matches(patterns []string, eventType string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		p = strings.TrimSpace(p)
+		if p == "*" || p == eventType {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(p, "*"); ok && strings.HasPrefix(eventType, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewID 返回带前缀的随机 ID；投递为至少一次，接收方可按 ID 去重
+func NewID(prefix string) string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
+
+const (
+	defaultRedisStream = "orchids:events"
+	defaultNATSSubject = "orchids.events"
+)
+
+// New 按配置创建事件总线；未配置任何目标时返回 nil。
+// usage_* 旧配置映射为只订阅 request.completed、只发送事件数据的目标，保持原有消息格式
+func New(cfg *config.Config) *Bus {
+	b := &Bus{}
+	for _, sc := range cfg.EventSinks {
+		name := sc.Name
+		if name == "" {
+			name = strings.ToLower(sc.Type)
+		}
+		var sink Sink
+		switch strings.ToLower(sc.Type) {
+		case "webhook":
+			sink = NewWebhook(name, sc.URL, sc.Secret, webhookFormat{})
+		case "redis_stream":
+			stream := sc.Stream
+			if stream == "" {
+				stream = defaultRedisStream
+			}
+			sink = NewRedisStream(name, cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB, stream, sc.MaxLen, false)
+		case "nats":
+			subject := sc.Stream
+			if subject == "" {
+				subject = defaultNATSSubject
+			}
+			sink = NewNATS(name, sc.URL, subject)
+		default:
+			slog.Warn("Unsupported event sink, skipped", "name", name, "type", sc.Type)
+			continue
+		}
+		b.Subscribe(sink, sc.Events...)
+	}
+	if cfg.UsageWebhookURL != "" {
+		b.Subscribe(NewWebhook("usage_webhook", cfg.UsageWebhookURL, cfg.UsageWebhookSecret, usageWebhookFormat), RequestCompleted)
+	}
+	if cfg.UsageRedisStream != "" {
+		b.Subscribe(NewRedisStream("usage_redis_stream", cfg.RedisAddr, cfg.RedisPassword, cfg.RedisDB, cfg.UsageRedisStream, cfg.UsageRedisStreamMaxLen, true), RequestCompleted)
+	}
+	if len(b.subs) == 0 {
+		return nil
+	}
+	return b
+}
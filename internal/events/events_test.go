@@ -0,0 +1,185 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestWebhook_BatchesSignsAndRetries(t *testing.T) {
+	const secret = "billing-secret"
+	var mu sync.Mutex
+	var received []Event
+	fail := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mac := hmac.New(sha256.New, []byte(secret))
+		mac.Write([]byte(r.Header.Get(TimestampHeader) + "."))
+		mac.Write(body)
+		if r.Header.Get(SignatureHeader) != "v1="+hex.EncodeToString(mac.Sum(nil)) {
+			t.Errorf("bad signature %q", r.Header.Get(SignatureHeader))
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			fail = false
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var payload struct {
+			Events []Event `json:"events"`
+		}
+		json.Unmarshal(body, &payload)
+		received = append(received, payload.Events...)
+	}))
+	defer srv.Close()
+
+	p := NewWebhook("webhook", srv.URL, secret, webhookFormat{}).(*batcher)
+	close(p.done) // 停止后台循环，由测试手动 flush
+	p.wg.Wait()
+	p.Publish(Event{ID: "a", Type: TaskFinished, Data: json.RawMessage(`{"task":"model_sync"}`)})
+	p.Publish(Event{ID: "b", Type: TaskFinished, Data: json.RawMessage(`{"task":"token_refresh"}`)})
+
+	if err := p.flush(context.Background()); err == nil {
+		t.Fatal("first flush should fail")
+	}
+	if err := p.flush(context.Background()); err != nil {
+		t.Fatalf("retry flush: %v", err)
+	}
+	if len(received) != 2 || received[0].ID != "a" || received[1].ID != "b" || received[1].Type != TaskFinished {
+		t.Fatalf("received = %+v", received)
+	}
+	if len(p.pending) != 0 {
+		t.Fatalf("pending = %d after successful flush", len(p.pending))
+	}
+}
+
+func TestBus_FiltersAndLegacyUsageFormat(t *testing.T) {
+	var mu sync.Mutex
+	var bodies [][]byte
+	var sigHeader string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, body)
+		sigHeader = r.Header.Get(UsageSignatureHeader)
+	}))
+	defer srv.Close()
+
+	legacy := NewWebhook("usage_webhook", srv.URL, "s", usageWebhookFormat).(*batcher)
+	all := &recordingSink{}
+	accounts := &recordingSink{}
+	bus := &Bus{}
+	bus.Subscribe(legacy, RequestCompleted)
+	bus.Subscribe(all)
+	bus.Subscribe(accounts, "account.*")
+
+	bus.Publish(RequestCompleted, Usage{ID: "usage_1", Model: "claude-sonnet-4-5", InputTokens: 10})
+	bus.Publish(AccountStatusChanged, AccountStatus{AccountID: 3, Previous: "", Status: "429"})
+	bus.Publish(TaskFinished, Task{Task: "model_sync", Result: "success"})
+	if err := bus.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	if len(all.events) != 3 || len(accounts.events) != 1 || accounts.events[0].Type != AccountStatusChanged {
+		t.Fatalf("all = %d, accounts = %+v", len(all.events), accounts.events)
+	}
+	if all.events[0].ID == "" || all.events[0].ID == all.events[1].ID {
+		t.Fatalf("event ids = %q, %q", all.events[0].ID, all.events[1].ID)
+	}
+	if len(bodies) != 1 || sigHeader == "" {
+		t.Fatalf("legacy webhook bodies = %d, signature = %q", len(bodies), sigHeader)
+	}
+	var payload struct {
+		Events []Usage `json:"events"`
+	}
+	json.Unmarshal(bodies[0], &payload)
+	if len(payload.Events) != 1 || payload.Events[0].ID != "usage_1" || payload.Events[0].InputTokens != 10 {
+		t.Fatalf("legacy payload = %s", bodies[0])
+	}
+}
+
+func TestNATS_PublishesWithPingConfirmation(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	type pub struct {
+		subject string
+		payload string
+	}
+	got := make(chan pub, 4)
+	connectLine := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.Write([]byte(`INFO {"server_id":"test","max_payload":1048576}` + "\r\n"))
+		r := bufio.NewReader(conn)
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimSpace(line)
+			switch {
+			case strings.HasPrefix(line, "CONNECT "):
+				connectLine <- line
+			case strings.HasPrefix(line, "PUB "):
+				var subject string
+				var n int
+				fmt.Sscanf(line, "PUB %s %d", &subject, &n)
+				payload := make([]byte, n+2)
+				io.ReadFull(r, payload)
+				got <- pub{subject: subject, payload: string(payload[:n])}
+			case line == "PING":
+				conn.Write([]byte("PONG\r\n"))
+			}
+		}
+	}()
+
+	s := NewNATS("nats", "nats://secret-token@"+ln.Addr().String(), "orchids.events").(*natsSink)
+	close(s.done) // 停止后台循环，由测试手动 flush
+	s.wg.Wait()
+	s.Publish(Event{ID: "evt_1", Type: TaskFinished, Data: json.RawMessage(`{"task":"model_sync"}`)})
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.flush(ctx); err != nil {
+		t.Fatalf("flush: %v", err)
+	}
+	defer s.Close()
+
+	if line := <-connectLine; !strings.Contains(line, `"auth_token":"secret-token"`) {
+		t.Fatalf("connect = %s", line)
+	}
+	p := <-got
+	if p.subject != "orchids.events.task.finished" {
+		t.Fatalf("subject = %q", p.subject)
+	}
+	var e Event
+	if err := json.Unmarshal([]byte(p.payload), &e); err != nil || e.ID != "evt_1" || string(e.Data) != `{"task":"model_sync"}` {
+		t.Fatalf("payload = %s (%v)", p.payload, err)
+	}
+}
+
+type recordingSink struct{ events []Event }
+
+func (s *recordingSink) Publish(e Event) { s.events = append(s.events, e) }
+func (s *recordingSink) Close() error    { return nil }
@@ -0,0 +1,51 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NewRedisStream 创建 Redis Stream 目标：每个事件 XADD 到 stream，字段 event 为事件 JSON
+// （dataOnly 时只写事件数据，兼容 usage_redis_stream）。
+// maxLen > 0 时按近似长度裁剪，避免消费者停止后 stream 无限增长
+func NewRedisStream(name, addr, password string, db int, stream string, maxLen int64, dataOnly bool) Sink {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+	b := newBatcher(name, func(ctx context.Context, batch []Event) error {
+		pipe := client.Pipeline()
+		for _, e := range batch {
+			var data []byte = e.Data
+			if !dataOnly {
+				var err error
+				if data, err = json.Marshal(e); err != nil {
+					return err
+				}
+			}
+			pipe.XAdd(ctx, &redis.XAddArgs{
+				Stream: stream,
+				MaxLen: maxLen,
+				Approx: maxLen > 0,
+				Values: map[string]interface{}{"type": e.Type, "event": data},
+			})
+		}
+		_, err := pipe.Exec(ctx)
+		return err
+	})
+	return &redisStream{batcher: b, client: client}
+}
+
+type redisStream struct {
+	*batcher
+	client *redis.Client
+}
+
+func (s *redisStream) Close() error {
+	err := s.batcher.Close()
+	s.client.Close()
+	return err
+}
@@ -0,0 +1,85 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 签名头：值为 "v1=" + hex(HMAC-SHA256(secret, 时间戳 + "." + 请求体))，时间戳为 Unix 秒
+const (
+	SignatureHeader = "X-Orchids-Event-Signature"
+	TimestampHeader = "X-Orchids-Event-Timestamp"
+
+	// usage_webhook_url 沿用的旧签名头
+	UsageSignatureHeader = "X-Orchids-Usage-Signature"
+	UsageTimestampHeader = "X-Orchids-Usage-Timestamp"
+)
+
+// webhookFormat 决定请求体与签名头；零值为事件总线格式（完整信封）
+type webhookFormat struct {
+	dataOnly        bool
+	signatureHeader string
+	timestampHeader string
+}
+
+// usageWebhookFormat 为 usage_webhook_url 的旧格式：events 数组只包含用量数据
+var usageWebhookFormat = webhookFormat{dataOnly: true, signatureHeader: UsageSignatureHeader, timestampHeader: UsageTimestampHeader}
+
+// NewWebhook 创建 webhook 目标：每批事件以 {"events": [...]} POST 到 url，2xx 视为成功。
+// secret 非空时附带签名头，接收方可据此校验来源
+func NewWebhook(name, url, secret string, format webhookFormat) Sink {
+	if format.signatureHeader == "" {
+		format.signatureHeader, format.timestampHeader = SignatureHeader, TimestampHeader
+	}
+	client := &http.Client{Timeout: sendTimeout}
+	return newBatcher(name, func(ctx context.Context, batch []Event) error {
+		var payload interface{} = batch
+		if format.dataOnly {
+			payload = dataOf(batch)
+		}
+		body, err := json.Marshal(map[string]interface{}{"events": payload})
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if secret != "" {
+			ts := strconv.FormatInt(time.Now().Unix(), 10)
+			mac := hmac.New(sha256.New, []byte(secret))
+			mac.Write([]byte(ts + "."))
+			mac.Write(body)
+			req.Header.Set(format.timestampHeader, ts)
+			req.Header.Set(format.signatureHeader, "v1="+hex.EncodeToString(mac.Sum(nil)))
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+			return fmt.Errorf("event webhook: status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+		}
+		return nil
+	})
+}
+
+func dataOf(batch []Event) []json.RawMessage {
+	out := make([]json.RawMessage, len(batch))
+	for i, e := range batch {
+		out[i] = e.Data
+	}
+	return out
+}
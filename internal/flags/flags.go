@@ -0,0 +1,249 @@
+// Package flags 提供功能开关：开关保存在设置存储中，进程内缓存，
+// 通过设置变更通知在各副本间失效，用于灰度新的上游、实验性 prompt 策略与重试策略
+package flags
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"log/slog"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SettingKey 为开关在设置存储中的 key
+const SettingKey = "flags"
+
+// 代码中使用的开关。未配置的开关使用调用方给出的默认值，保持原有行为
+const (
+	ProviderWarp       = "provider.warp"
+	PromptAIClient     = "prompt.orchids_aiclient"
+	RetryAccountSwitch = "retry.account_switch"
+)
+
+// KnownFlag 描述代码中使用的开关，供管理接口展示
+type KnownFlag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Default     string `json:"default"`
+}
+
+// Known 列出代码中使用的开关
+var Known = []KnownFlag{
+	{Name: ProviderWarp, Description: "允许请求路由到 Warp 上游", Default: "on"},
+	{Name: PromptAIClient, Description: "Orchids 请求使用 aiclient prompt 构建方式", Default: "orchids_impl == aiclient"},
+	{Name: RetryAccountSwitch, Description: "可重试错误时切换到其他账号", Default: "on"},
+}
+
+var validName = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]{0,63}$`)
+
+// Flag 为一个功能开关。Enabled 为总开关；开启后 Keys 中列出的 key 始终命中，
+// 其余 key 按 Rollout（0-100）百分比稳定分桶命中
+type Flag struct {
+	Name        string    `json:"name"`
+	Enabled     bool      `json:"enabled"`
+	Rollout     int       `json:"rollout"`
+	Keys        []string  `json:"keys,omitempty"`
+	Description string    `json:"description,omitempty"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	UpdatedBy   string    `json:"updated_by,omitempty"`
+}
+
+// Validate 检查开关名与灰度比例
+func (f Flag) Validate() error {
+	if !validName.MatchString(f.Name) {
+		return fmt.Errorf("invalid flag name %q", f.Name)
+	}
+	if f.Rollout < 0 || f.Rollout > 100 {
+		return fmt.Errorf("rollout must be between 0 and 100")
+	}
+	return nil
+}
+
+// matches 报告开关对 key 是否生效
+func (f *Flag) matches(key string) bool {
+	if !f.Enabled {
+		return false
+	}
+	for _, k := range f.Keys {
+		if k == key {
+			return true
+		}
+	}
+	if f.Rollout >= 100 {
+		return true
+	}
+	if f.Rollout <= 0 || key == "" {
+		return false
+	}
+	return bucket(f.Name, key) < f.Rollout
+}
+
+// bucket 将 (name, key) 稳定映射到 [0, 100)，同一 key 在不同开关上分桶独立
+func bucket(name, key string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{0})
+	h.Write([]byte(key))
+	return int(h.Sum32() % 100)
+}
+
+// Backend 为开关的持久化与变更通知，store.Store 实现了该接口
+type Backend interface {
+	GetSetting(ctx context.Context, key string) (string, error)
+	SetSetting(ctx context.Context, key, value string) error
+	PublishSettingChange(ctx context.Context, key string) error
+	WatchSettingChanges(ctx context.Context) <-chan string
+}
+
+// Manager 缓存开关并提供求值。nil Manager 的所有开关都取默认值
+type Manager struct {
+	backend Backend
+
+	mu    sync.RWMutex
+	flags map[string]*Flag
+
+	// writeMu 串行化 update 的读取-修改-写回，避免并发的 Set/Delete 相互覆盖
+	writeMu sync.Mutex
+}
+
+func New(backend Backend) *Manager {
+	return &Manager{backend: backend, flags: map[string]*Flag{}}
+}
+
+// Enabled 返回开关 name 对 key（通常为 API Key ID）是否生效；开关未配置时返回 def
+func (m *Manager) Enabled(name, key string, def bool) bool {
+	if m == nil {
+		return def
+	}
+	m.mu.RLock()
+	f, ok := m.flags[name]
+	m.mu.RUnlock()
+	if !ok {
+		return def
+	}
+	return f.matches(key)
+}
+
+// List 返回已配置的开关，按名称排序
+func (m *Manager) List() []Flag {
+	if m == nil {
+		return nil
+	}
+	m.mu.RLock()
+	out := make([]Flag, 0, len(m.flags))
+	for _, f := range m.flags {
+		item := *f
+		item.Keys = append([]string(nil), f.Keys...)
+		out = append(out, item)
+	}
+	m.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+// Load 从设置存储重新加载开关
+func (m *Manager) Load(ctx context.Context) error {
+	flags, err := m.read(ctx)
+	if err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.flags = flags
+	m.mu.Unlock()
+	return nil
+}
+
+func (m *Manager) read(ctx context.Context) (map[string]*Flag, error) {
+	raw, err := m.backend.GetSetting(ctx, SettingKey)
+	if err != nil {
+		return nil, err
+	}
+	flags := map[string]*Flag{}
+	if strings.TrimSpace(raw) == "" {
+		return flags, nil
+	}
+	var list []*Flag
+	if err := json.Unmarshal([]byte(raw), &list); err != nil {
+		return nil, fmt.Errorf("decode flags: %w", err)
+	}
+	for _, f := range list {
+		if f != nil && f.Name != "" {
+			flags[f.Name] = f
+		}
+	}
+	return flags, nil
+}
+
+// Set 新增或替换开关，保存后通知其他副本重新加载
+func (m *Manager) Set(ctx context.Context, f Flag) error {
+	if err := f.Validate(); err != nil {
+		return err
+	}
+	if f.UpdatedAt.IsZero() {
+		f.UpdatedAt = time.Now()
+	}
+	return m.update(ctx, func(flags map[string]*Flag) { flags[f.Name] = &f })
+}
+
+// Delete 删除开关，之后该开关取调用方默认值
+func (m *Manager) Delete(ctx context.Context, name string) (bool, error) {
+	found := false
+	err := m.update(ctx, func(flags map[string]*Flag) {
+		_, found = flags[name]
+		delete(flags, name)
+	})
+	return found, err
+}
+
+// update 基于存储中的最新内容修改并写回，避免覆盖其他副本刚写入的开关；同一进程内的更新串行执行
+func (m *Manager) update(ctx context.Context, mutate func(map[string]*Flag)) error {
+	m.writeMu.Lock()
+	defer m.writeMu.Unlock()
+	flags, err := m.read(ctx)
+	if err != nil {
+		return err
+	}
+	mutate(flags)
+
+	list := make([]*Flag, 0, len(flags))
+	for _, f := range flags {
+		list = append(list, f)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	if err := m.backend.SetSetting(ctx, SettingKey, string(data)); err != nil {
+		return err
+	}
+	m.mu.Lock()
+	m.flags = flags
+	m.mu.Unlock()
+	if err := m.backend.PublishSettingChange(ctx, SettingKey); err != nil {
+		slog.Warn("Failed to publish flag change", "error", err)
+	}
+	return nil
+}
+
+// Watch 订阅设置变更通知并在开关变更时重新加载，直到 ctx 结束
+func (m *Manager) Watch(ctx context.Context) {
+	changes := m.backend.WatchSettingChanges(ctx)
+	go func() {
+		for key := range changes {
+			if key != SettingKey {
+				continue
+			}
+			if err := m.Load(ctx); err != nil {
+				slog.Warn("Failed to reload flags", "error", err)
+				continue
+			}
+			slog.Debug("Flags reloaded")
+		}
+	}()
+}
@@ -0,0 +1,160 @@
+package flags
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// memBackend 为内存实现的设置存储，Publish 会广播给所有 Watch
+type memBackend struct {
+	mu       sync.Mutex
+	settings map[string]string
+	watchers []chan string
+	// writeDelay 放大读取与写回之间的窗口，用于暴露并发覆盖
+	writeDelay time.Duration
+}
+
+func newMemBackend() *memBackend {
+	return &memBackend{settings: map[string]string{}}
+}
+
+func (b *memBackend) GetSetting(_ context.Context, key string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.settings[key], nil
+}
+
+func (b *memBackend) SetSetting(_ context.Context, key, value string) error {
+	time.Sleep(b.writeDelay)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.settings[key] = value
+	return nil
+}
+
+func (b *memBackend) PublishSettingChange(_ context.Context, key string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, ch := range b.watchers {
+		ch <- key
+	}
+	return nil
+}
+
+func (b *memBackend) WatchSettingChanges(ctx context.Context) <-chan string {
+	ch := make(chan string, 16)
+	b.mu.Lock()
+	b.watchers = append(b.watchers, ch)
+	b.mu.Unlock()
+	return ch
+}
+
+func TestEnabled_DefaultsKeysAndRollout(t *testing.T) {
+	var nilManager *Manager
+	if !nilManager.Enabled(ProviderWarp, "k", true) {
+		t.Fatalf("nil manager should return default")
+	}
+
+	m := New(newMemBackend())
+	ctx := context.Background()
+	if m.Enabled(RetryAccountSwitch, "k", true) != true || m.Enabled(RetryAccountSwitch, "k", false) != false {
+		t.Fatalf("unset flag should return default")
+	}
+
+	if err := m.Set(ctx, Flag{Name: "exp.keys", Enabled: true, Keys: []string{"vip"}}); err != nil {
+		t.Fatal(err)
+	}
+	if !m.Enabled("exp.keys", "vip", false) || m.Enabled("exp.keys", "other", true) {
+		t.Fatalf("key allow-list not applied")
+	}
+
+	if err := m.Set(ctx, Flag{Name: "exp.half", Enabled: true, Rollout: 50}); err != nil {
+		t.Fatal(err)
+	}
+	on := 0
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		first := m.Enabled("exp.half", key, false)
+		if first != m.Enabled("exp.half", key, false) {
+			t.Fatalf("rollout not stable for %s", key)
+		}
+		if first {
+			on++
+		}
+	}
+	if on < 400 || on > 600 {
+		t.Fatalf("50%% rollout enabled %d/1000 keys", on)
+	}
+
+	if err := m.Set(ctx, Flag{Name: "exp.half", Enabled: false, Rollout: 100}); err != nil {
+		t.Fatal(err)
+	}
+	if m.Enabled("exp.half", "key-1", true) {
+		t.Fatalf("disabled flag should be off regardless of rollout")
+	}
+	if err := m.Set(ctx, Flag{Name: "Bad Name"}); err == nil {
+		t.Fatalf("invalid name accepted")
+	}
+}
+
+func TestWatch_ReloadsOnChangeFromOtherReplica(t *testing.T) {
+	backend := newMemBackend()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	replica := New(backend)
+	replica.Watch(ctx)
+	writer := New(backend)
+	if err := writer.Set(ctx, Flag{Name: ProviderWarp, Enabled: false}); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for replica.Enabled(ProviderWarp, "", true) {
+		if time.Now().After(deadline) {
+			t.Fatalf("replica did not reload flags")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if found, err := writer.Delete(ctx, ProviderWarp); err != nil || !found {
+		t.Fatalf("Delete = %v, %v", found, err)
+	}
+	deadline = time.Now().Add(time.Second)
+	for !replica.Enabled(ProviderWarp, "", true) {
+		if time.Now().After(deadline) {
+			t.Fatalf("replica did not observe delete")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestSet_ConcurrentUpdatesAreNotLost(t *testing.T) {
+	backend := newMemBackend()
+	backend.writeDelay = time.Millisecond
+	m := New(backend)
+	ctx := context.Background()
+
+	const n = 20
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := m.Set(ctx, Flag{Name: fmt.Sprintf("exp.flag-%d", i), Enabled: true}); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	if err := m.Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(m.List()); got != n {
+		t.Fatalf("stored %d flags, want %d", got, n)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"orchids-api/internal/events"
 	"orchids-api/internal/store"
 )
 
@@ -60,7 +61,7 @@ func hasExplicitHTTPStatus(lower string, code string) bool {
 	return false
 }
 
-func markAccountStatus(ctx context.Context, store *store.Store, acc *store.Account, status string) {
+func markAccountStatus(ctx context.Context, store *store.Store, bus *events.Bus, acc *store.Account, status string) {
 	if acc == nil || store == nil || status == "" {
 		return
 	}
@@ -73,6 +74,7 @@ func markAccountStatus(ctx context.Context, store *store.Store, acc *store.Accou
 		return
 	}
 
+	previous := acc.StatusCode
 	acc.StatusCode = status
 	acc.LastAttempt = now
 
@@ -81,4 +83,12 @@ func markAccountStatus(ctx context.Context, store *store.Store, acc *store.Accou
 		return
 	}
 	slog.Info("账号状态已标记", "account_id", acc.ID, "status", status)
+	bus.Publish(events.AccountStatusChanged, events.AccountStatus{
+		AccountID:   acc.ID,
+		AccountName: acc.Name,
+		AccountType: acc.AccountType,
+		Previous:    previous,
+		Status:      status,
+		Reason:      "上游请求失败",
+	})
 }
@@ -0,0 +1,36 @@
+package handler
+
+// AttributionHeader 在配置 attribution_label 时随消息响应返回，标识生成内容的代理部署
+const AttributionHeader = "X-Attribution"
+
+// attributionFooterText 返回追加在响应末尾的标注文本：已有文本输出时与正文以空行分隔；
+// 未配置或响应以 tool_use 结束（客户端需要立即执行工具）时返回空
+func (h *streamHandler) attributionFooterText(stopReason string) string {
+	if h.config == nil || h.config.AttributionFooter == "" || stopReason == "tool_use" {
+		return ""
+	}
+	h.mu.Lock()
+	hasText := h.hasTextOutput
+	h.mu.Unlock()
+	if hasText {
+		return "\n\n" + h.config.AttributionFooter
+	}
+	return h.config.AttributionFooter
+}
+
+// emitAttributionFooter 在流式响应结束前以单独的 text 块写出标注文本
+func (h *streamHandler) emitAttributionFooter(stopReason string, write func(event, data string)) {
+	if text := h.attributionFooterText(stopReason); text != "" {
+		h.emitTextBlockWithWriter(text, write)
+	}
+}
+
+// appendAttributionBlock 为非流式响应的 content 追加标注文本块
+func (h *streamHandler) appendAttributionBlock(stopReason string) {
+	if text := h.attributionFooterText(stopReason); text != "" {
+		h.contentBlocks = append(h.contentBlocks, map[string]interface{}{
+			"type": "text",
+			"text": text,
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/prompt"
+	"orchids-api/internal/upstream"
+)
+
+func TestHandleMessages_AttributionFooter(t *testing.T) {
+	events := []upstream.SSEMessage{
+		{Type: "model", Event: map[string]interface{}{"type": "text-start"}},
+		{Type: "model", Event: map[string]interface{}{"type": "text-delta", "delta": "Hello"}},
+		{Type: "model", Event: map[string]interface{}{"type": "text-end"}},
+		{Type: "model", Event: map[string]interface{}{"type": "finish", "finishReason": "stop"}},
+	}
+	do := func(stream bool) *httptest.ResponseRecorder {
+		h := &Handler{
+			config: &config.Config{AttributionFooter: "— generated via example-proxy", AttributionLabel: "example-proxy/eu-1"},
+			client: &fakeClient{events: events},
+		}
+		body, _ := json.Marshal(ClaudeRequest{
+			Model:    "gpt-test",
+			Messages: []prompt.Message{{Role: "user", Content: prompt.MessageContent{Text: "Hi"}}},
+			Stream:   stream,
+		})
+		rec := httptest.NewRecorder()
+		h.HandleMessages(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewReader(body)))
+		if rec.Header().Get(AttributionHeader) != "example-proxy/eu-1" {
+			t.Fatalf("stream=%v: attribution header = %q", stream, rec.Header().Get(AttributionHeader))
+		}
+		return rec
+	}
+
+	var resp struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+		Attribution string `json:"attribution"`
+	}
+	if err := json.NewDecoder(do(false).Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Content) != 2 || resp.Content[0].Text != "Hello" || resp.Content[1].Text != "\n\n— generated via example-proxy" {
+		t.Fatalf("content = %+v", resp.Content)
+	}
+	if resp.Attribution != "example-proxy/eu-1" {
+		t.Fatalf("attribution = %q", resp.Attribution)
+	}
+
+	out := do(true).Body.String()
+	footer := strings.Index(out, `"text":"\n\n— generated via example-proxy"`)
+	if footer < 0 || footer > strings.Index(out, "event: message_delta") {
+		t.Fatalf("footer delta missing or after message_delta:\n%s", out)
+	}
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// AnthropicBetaHeader 为 Anthropic SDK 声明 beta 功能的请求头，值为逗号分隔的 beta 名称
+const AnthropicBetaHeader = "anthropic-beta"
+
+// IgnoredBetaHeader 列出本次请求中被忽略的未识别 beta，提示客户端这些功能未生效
+const IgnoredBetaHeader = "X-Anthropic-Beta-Ignored"
+
+// betaFeatures 为 anthropic-beta 映射到的代理侧行为
+type betaFeatures struct {
+	// PromptCaching 客户端使用 cache_control：cache_strategy 为 none/off 时按 split 补充缓存断点
+	PromptCaching bool
+	// LongContext 客户端声明长上下文：不按 context_max_tokens 压缩历史
+	LongContext bool
+}
+
+// betaDateSuffix 匹配 beta 名称末尾的版本日期（如 -2024-07-31 / -20250219），按去掉日期后的名称识别，
+// 同一功能的新版本无需改代码
+var betaDateSuffix = regexp.MustCompile(`-(\d{4}-\d{2}-\d{2}|\d{8})$`)
+
+// knownBetas 为已识别的 beta；值为 nil 的表示上游本身支持或与代理无关，接受但不改变行为
+var knownBetas = map[string]func(*betaFeatures){
+	"prompt-caching":              func(f *betaFeatures) { f.PromptCaching = true },
+	"extended-cache-ttl":          func(f *betaFeatures) { f.PromptCaching = true },
+	"context-1m":                  func(f *betaFeatures) { f.LongContext = true },
+	"token-efficient-tools":       nil,
+	"fine-grained-tool-streaming": nil,
+	"interleaved-thinking":        nil,
+	"output-128k":                 nil,
+	"claude-code":                 nil,
+	"oauth":                       nil,
+	"context-management":          nil,
+}
+
+// parseAnthropicBetas 解析所有 anthropic-beta 请求头，返回映射后的行为与未识别的 beta 名称；
+// allow 中的名称（完整名称或去掉日期后的名称）视为已识别
+func parseAnthropicBetas(values []string, allow []string) (betaFeatures, []string) {
+	var features betaFeatures
+	var unknown []string
+	for _, value := range values {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.ToLower(strings.TrimSpace(name))
+			if name == "" {
+				continue
+			}
+			base := betaDateSuffix.ReplaceAllString(name, "")
+			apply, ok := knownBetas[base]
+			if !ok {
+				if !slices.ContainsFunc(allow, func(a string) bool {
+					a = strings.ToLower(strings.TrimSpace(a))
+					return a == name || a == base
+				}) && !slices.Contains(unknown, name) {
+					unknown = append(unknown, name)
+				}
+				continue
+			}
+			if apply != nil {
+				apply(&features)
+			}
+		}
+	}
+	return features, unknown
+}
+
+// anthropicBetas 解析请求的 anthropic-beta。未识别的 beta 默认忽略并通过 IgnoredBetaHeader 告知客户端；
+// anthropic_beta_unknown 为 reject 时返回 invalid_request_error（与 Anthropic 对未知 beta 的处理一致）
+func (h *Handler) anthropicBetas(w http.ResponseWriter, r *http.Request) (betaFeatures, bool) {
+	var allow []string
+	var reject bool
+	if h.config != nil {
+		allow = h.config.AnthropicBetaAllow
+		reject = strings.EqualFold(h.config.AnthropicBetaUnknown, "reject")
+	}
+	features, unknown := parseAnthropicBetas(r.Header.Values(AnthropicBetaHeader), allow)
+	if len(unknown) == 0 {
+		return features, true
+	}
+	if !reject {
+		slog.Info("Ignoring unsupported anthropic-beta", "betas", unknown, "path", r.URL.Path, "user_agent", r.UserAgent())
+		w.Header().Set(IgnoredBetaHeader, strings.Join(unknown, ","))
+		return features, true
+	}
+	slog.Info("Rejected unsupported anthropic-beta", "betas", unknown, "path", r.URL.Path, "user_agent", r.UserAgent())
+	h.writeErrorResponse(w, "invalid_request_error",
+		"Unsupported anthropic-beta: "+strings.Join(unknown, ", "), http.StatusBadRequest)
+	return features, false
+}
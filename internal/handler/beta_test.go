@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"orchids-api/internal/config"
+)
+
+func TestParseAnthropicBetas(t *testing.T) {
+	features, unknown := parseAnthropicBetas(
+		[]string{"prompt-caching-2024-07-31, token-efficient-tools-2025-02-19", "context-1m-2025-08-07,files-api-2025-04-14,Mystery-Beta"},
+		[]string{"files-api"},
+	)
+	if !features.PromptCaching || !features.LongContext {
+		t.Fatalf("features = %+v", features)
+	}
+	if !reflect.DeepEqual(unknown, []string{"mystery-beta"}) {
+		t.Fatalf("unknown = %v", unknown)
+	}
+}
+
+func TestHandleCountTokens_UnknownBetaPolicy(t *testing.T) {
+	body := `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hello"}]}`
+	send := func(policy string) *httptest.ResponseRecorder {
+		h := &Handler{config: &config.Config{AnthropicBetaUnknown: policy}}
+		req := httptest.NewRequest(http.MethodPost, "/orchids/v1/messages/count_tokens", bytes.NewReader([]byte(body)))
+		req.Header.Set(AnthropicBetaHeader, "prompt-caching-2024-07-31,made-up-2030-01-01")
+		rec := httptest.NewRecorder()
+		h.HandleCountTokens(rec, req)
+		return rec
+	}
+
+	rec := send("reject")
+	if rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "made-up-2030-01-01") {
+		t.Fatalf("reject: status = %d body = %s", rec.Code, rec.Body.String())
+	}
+	if got := rec.Header().Get(IgnoredBetaHeader); got != "" {
+		t.Fatalf("reject: %s = %q", IgnoredBetaHeader, got)
+	}
+	for _, policy := range []string{"ignore", ""} {
+		rec := send(policy)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("policy %q: status = %d body = %s", policy, rec.Code, rec.Body.String())
+		}
+		if got := rec.Header().Get(IgnoredBetaHeader); got != "made-up-2030-01-01" {
+			t.Fatalf("policy %q: %s = %q", policy, IgnoredBetaHeader, got)
+		}
+	}
+}
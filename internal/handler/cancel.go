@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"orchids-api/internal/middleware"
+)
+
+// errRequestCancelled 作为 context cause，区分服务端取消与客户端断开
+var errRequestCancelled = errors.New("request cancelled via API")
+
+// inflightRequest 记录一个可被 DELETE /v1/requests/{trace_id} 取消的进行中请求
+type inflightRequest struct {
+	cancel    context.CancelCauseFunc
+	keyHash   string
+	startedAt time.Time
+}
+
+// inflightKey 为登记表的 key。trace id 可由客户端通过 X-Trace-ID 指定，按 API Key 区分，
+// 避免其他调用方复用同一 trace id 顶替登记
+func inflightKey(traceID, keyHash string) string {
+	return keyHash + "\x00" + traceID
+}
+
+// registerInflight 为请求创建可取消的 context 并按 trace id 与 API Key 登记，返回的函数用于请求结束时注销。
+// trace id 为空（未经过 TraceMiddleware）时不登记；同一 key 的 trace id 已有进行中的请求时保留先登记的请求。
+func (h *Handler) registerInflight(r *http.Request) (*http.Request, func()) {
+	traceID := middleware.GetTraceID(r.Context())
+	ctx, cancel := context.WithCancelCause(r.Context())
+	r = r.WithContext(ctx)
+	// 关闭宽限期结束时以 errServerShutdown 取消，与是否有 trace id 无关
+	stopShutdown := context.AfterFunc(h.shutdownContext(), func() { cancel(errServerShutdown) })
+	if traceID == "" {
+		return r, func() {
+			stopShutdown()
+			cancel(nil)
+		}
+	}
+
+	entry := &inflightRequest{
+		cancel:    cancel,
+		keyHash:   middleware.HashAPIKey(middleware.APIKeyFromRequest(r)),
+		startedAt: time.Now(),
+	}
+	key := inflightKey(traceID, entry.keyHash)
+	h.inflightMu.Lock()
+	if h.inflight == nil {
+		h.inflight = make(map[string]*inflightRequest)
+	}
+	if _, taken := h.inflight[key]; !taken {
+		h.inflight[key] = entry
+	}
+	h.inflightMu.Unlock()
+
+	return r, func() {
+		h.inflightMu.Lock()
+		// 客户端复用 trace id 时只删除自己的登记
+		if h.inflight[key] == entry {
+			delete(h.inflight, key)
+		}
+		h.inflightMu.Unlock()
+		stopShutdown()
+		cancel(nil)
+	}
+}
+
+// cancelInflight 取消指定 trace id 的请求，仅当调用方 API Key 与发起方一致时生效。
+// 未携带 API Key 的请求无法证明归属，既不能取消也不能被取消
+func (h *Handler) cancelInflight(traceID, keyHash string) (time.Time, bool) {
+	if keyHash == "" {
+		return time.Time{}, false
+	}
+	key := inflightKey(traceID, keyHash)
+	h.inflightMu.Lock()
+	entry, ok := h.inflight[key]
+	if !ok {
+		h.inflightMu.Unlock()
+		return time.Time{}, false
+	}
+	delete(h.inflight, key)
+	h.inflightMu.Unlock()
+
+	entry.cancel(errRequestCancelled)
+	return entry.startedAt, true
+}
+
+// HandleCancelRequest handles DELETE /v1/requests/{trace_id}.
+func (h *Handler) HandleCancelRequest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		h.writeErrorResponse(w, "invalid_request_error", "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	idx := strings.Index(r.URL.Path, "/requests/")
+	traceID := ""
+	if idx >= 0 {
+		traceID = strings.Trim(r.URL.Path[idx+len("/requests/"):], "/")
+	}
+	if traceID == "" {
+		h.writeErrorResponse(w, "invalid_request_error", "trace_id is required", http.StatusBadRequest)
+		return
+	}
+
+	// 不区分“不存在”与“不属于该 key”，避免通过返回码探测其他调用方的 trace id
+	startedAt, ok := h.cancelInflight(traceID, middleware.HashAPIKey(middleware.APIKeyFromRequest(r)))
+	if !ok {
+		h.writeErrorResponse(w, "not_found_error", "No in-flight request with trace_id "+traceID, http.StatusNotFound)
+		return
+	}
+	slog.Info("In-flight request cancelled", "trace_id", traceID, "elapsed", time.Since(startedAt))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"trace_id":  traceID,
+		"cancelled": true,
+	})
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/debug"
+	"orchids-api/internal/middleware"
+	"orchids-api/internal/upstream"
+)
+
+type blockingClient struct {
+	started chan struct{}
+}
+
+func (c *blockingClient) SendRequest(ctx context.Context, prompt string, chatHistory []interface{}, model string, onMessage func(upstream.SSEMessage), logger *debug.Logger) error {
+	close(c.started)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestHandleCancelRequest_CancelsInflightForSameKey(t *testing.T) {
+	client := &blockingClient{started: make(chan struct{})}
+	h := &Handler{
+		config:            &config.Config{},
+		client:            client,
+		sessionWorkdirs:   map[string]string{},
+		sessionConvIDs:    map[string]string{},
+		sessionLastAccess: map[string]time.Time{},
+		recentRequests:    map[string]*recentRequest{},
+	}
+
+	const traceID = "trace-cancel-1"
+	req := httptest.NewRequest(http.MethodPost, "/orchids/v1/messages", bytes.NewReader(makeWarpRequestBody(t, "hello", "")))
+	req.Header.Set("x-api-key", "sk-owner")
+	req = req.WithContext(middleware.WithTraceID(req.Context(), traceID))
+	rec := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		h.HandleMessages(rec, req)
+		close(done)
+	}()
+
+	select {
+	case <-client.started:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("upstream request was not started")
+	}
+
+	other := httptest.NewRequest(http.MethodDelete, "/v1/requests/"+traceID, nil)
+	other.Header.Set("Authorization", "Bearer sk-someone-else")
+	otherRec := httptest.NewRecorder()
+	h.HandleCancelRequest(otherRec, other)
+	if otherRec.Code != http.StatusNotFound {
+		t.Fatalf("cancel with other key status = %d, want %d", otherRec.Code, http.StatusNotFound)
+	}
+
+	owner := httptest.NewRequest(http.MethodDelete, "/v1/requests/"+traceID, nil)
+	owner.Header.Set("Authorization", "Bearer sk-owner")
+	ownerRec := httptest.NewRecorder()
+	h.HandleCancelRequest(ownerRec, owner)
+	if ownerRec.Code != http.StatusOK {
+		t.Fatalf("cancel with owner key status = %d, want %d", ownerRec.Code, http.StatusOK)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("HandleMessages did not return after cancellation")
+	}
+
+	again := httptest.NewRecorder()
+	h.HandleCancelRequest(again, owner)
+	if again.Code != http.StatusNotFound {
+		t.Fatalf("second cancel status = %d, want %d", again.Code, http.StatusNotFound)
+	}
+}
+
+func TestCancelInflight_OwnershipAndTraceIDReuse(t *testing.T) {
+	h := &Handler{config: &config.Config{}}
+	register := func(traceID, apiKey string) (*http.Request, func()) {
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+		if apiKey != "" {
+			req.Header.Set("x-api-key", apiKey)
+		}
+		return h.registerInflight(req.WithContext(middleware.WithTraceID(req.Context(), traceID)))
+	}
+
+	// 未携带 API Key 的请求不能被任何人取消
+	keyless, done := register("trace-keyless", "")
+	defer done()
+	if _, ok := h.cancelInflight("trace-keyless", ""); ok || keyless.Context().Err() != nil {
+		t.Fatal("keyless request cancelled")
+	}
+
+	// 其他 key 复用 trace id 不会顶替原请求的登记
+	first, doneFirst := register("trace-shared", "sk-owner")
+	defer doneFirst()
+	second, doneSecond := register("trace-shared", "sk-attacker")
+	defer doneSecond()
+	if _, ok := h.cancelInflight("trace-shared", middleware.HashAPIKey("sk-owner")); !ok {
+		t.Fatal("owner could not cancel after trace id reuse")
+	}
+	if first.Context().Err() == nil || second.Context().Err() != nil {
+		t.Fatalf("cancelled wrong request: first=%v second=%v", first.Context().Err(), second.Context().Err())
+	}
+
+	// 同一 key 重复使用 trace id 时先登记的请求仍可取消，后者结束时不会注销前者
+	older, doneOlder := register("trace-dup", "sk-owner")
+	defer doneOlder()
+	_, doneNewer := register("trace-dup", "sk-owner")
+	doneNewer()
+	if _, ok := h.cancelInflight("trace-dup", middleware.HashAPIKey("sk-owner")); !ok || older.Context().Err() == nil {
+		t.Fatal("first request with reused trace id could not be cancelled")
+	}
+}
@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"orchids-api/internal/adapter"
+	"orchids-api/internal/config"
+	"orchids-api/internal/debug"
+	"orchids-api/internal/sseconform"
+	"orchids-api/internal/testing/golden"
+	"orchids-api/internal/upstream"
+)
+
+// testMessageStart 与 HandleMessages 一样在上游事件之前写出 message_start
+func testMessageStart(sh *streamHandler) {
+	sh.writeSSE("message_start", `{"type":"message_start","message":{"id":"`+sh.msgID+`","type":"message","role":"assistant","content":[]}}`)
+}
+
+func TestChaosSoak_StreamStaysWellFormed(t *testing.T) {
+	transcripts, err := filepath.Glob(filepath.Join("testdata", "golden", "*.jsonl"))
+	if err != nil || len(transcripts) == 0 {
+		t.Fatalf("no transcripts: %v", err)
+	}
+	seeds := 300
+	if testing.Short() {
+		seeds = 30
+	}
+	for _, path := range transcripts {
+		entries, err := golden.Load(path)
+		if err != nil {
+			t.Fatalf("load %s: %v", path, err)
+		}
+		for seed := int64(1); seed <= int64(seeds); seed++ {
+			chaos := upstream.NewChaos(upstream.ChaosConfig{
+				Seed:         seed,
+				DropRate:     0.1,
+				TruncateRate: 0.2,
+				CorruptRate:  0.2,
+			})
+			rec := httptest.NewRecorder()
+			sh := newStreamHandler(&config.Config{}, rec, debug.New(false, false), false, true, adapter.FormatAnthropic, "")
+			testMessageStart(sh)
+			onMessage := chaos.Wrap(context.Background(), sh.handleMessage)
+			for _, e := range entries {
+				onMessage(upstream.SSEMessage{Type: e.Type, Event: e.Event})
+			}
+			sh.forceFinishIfMissing()
+			sh.finishResponse("end_turn")
+			sh.release()
+
+			if err := sseconform.ValidateAnthropic(rec.Body.String()); err != nil {
+				t.Fatalf("%s seed %d: %v\n%s", filepath.Base(path), seed, err, rec.Body.String())
+			}
+		}
+	}
+}
+
+// 相同种子必须产生相同的扰动结果（与 map 遍历顺序无关），soak 失败时才能按种子复现
+func TestChaos_SeedIsReproducible(t *testing.T) {
+	entries, err := golden.Load(filepath.Join("testdata", "golden", "orchids_tool_call.jsonl"))
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	run := func(seed int64) []upstream.SSEMessage {
+		chaos := upstream.NewChaos(upstream.ChaosConfig{Seed: seed, TruncateRate: 0.5, CorruptRate: 0.5})
+		var out []upstream.SSEMessage
+		onMessage := chaos.Wrap(context.Background(), func(msg upstream.SSEMessage) { out = append(out, msg) })
+		for _, e := range entries {
+			onMessage(upstream.SSEMessage{Type: e.Type, Event: e.Event})
+		}
+		return out
+	}
+	for seed := int64(1); seed <= 20; seed++ {
+		want := run(seed)
+		for i := 0; i < 5; i++ {
+			if got := run(seed); !reflect.DeepEqual(got, want) {
+				t.Fatalf("seed %d run %d differs:\n got %v\nwant %v", seed, i, got, want)
+			}
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"orchids-api/internal/prompt"
+	"orchids-api/internal/toolspill"
+)
+
+// ConversationArtifact 为会话中保存过一次的 tool_result（如读取的长文件），ID 与 /api/tool-results/{id} 一致
+type ConversationArtifact struct {
+	ID        string    `json:"id"`
+	ToolUseID string    `json:"tool_use_id"`
+	Bytes     int       `json:"bytes"`
+	Refs      int       `json:"refs"` // 以引用代替原文发送的次数
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// conversationArtifacts 为一个会话的 artifact，按首次出现顺序保存，超过 conversation_artifact_max_count 时淘汰最早的
+type conversationArtifacts struct {
+	byID  map[string]*ConversationArtifact
+	order []string
+}
+
+// bridgeToolResults 在开启 conversation_artifacts 时，将会话中内容重复的 tool_result 替换为对首次出现的引用。
+// 只在原文仍能被上游看到时替换：本次请求中较早的消息已包含该内容，或该内容在之前的轮次已发送且上游会话仍然绑定
+// （如 Warp 复用 conversationID）。返回处理后的消息副本与替换的块数
+func (h *Handler) bridgeToolResults(conversationKey string, messages []prompt.Message) ([]prompt.Message, int) {
+	if h.config == nil || !h.config.ConversationArtifacts || conversationKey == "" {
+		return messages, 0
+	}
+	minBytes := h.config.ConversationArtifactMinBytes
+	bridged := cloneMessages(messages)
+	now := time.Now()
+	var stored []string
+	replaced := 0
+
+	h.sessionWorkdirsMu.Lock()
+	if h.sessionArtifacts == nil {
+		h.sessionArtifacts = make(map[string]*conversationArtifacts)
+	}
+	if h.sessionLastAccess == nil {
+		h.sessionLastAccess = make(map[string]time.Time)
+	}
+	arts, known := h.sessionArtifacts[conversationKey]
+	if !known {
+		arts = &conversationArtifacts{byID: make(map[string]*ConversationArtifact)}
+		h.sessionArtifacts[conversationKey] = arts
+	}
+	_, upstreamBound := h.sessionConvIDs[conversationKey]
+	seen := make(map[string]struct{})
+	var contents map[string]string
+
+	for i := range bridged {
+		msg := &bridged[i]
+		if msg.Role != "user" || msg.Content.Blocks == nil {
+			continue
+		}
+		for j := range msg.Content.Blocks {
+			block := &msg.Content.Blocks[j]
+			if block.Type != "tool_result" {
+				continue
+			}
+			// 只含文本块的数组同样可以桥接，含图片等其他块时保持原样
+			text, ok := toolResultPlainText(block.Content)
+			if !ok || len(text) < minBytes {
+				continue
+			}
+			id := toolspill.ID(text)
+			art, exists := arts.byID[id]
+			_, inRequest := seen[id]
+			if exists && (inRequest || upstreamBound) && art.ToolUseID != block.ToolUseID {
+				block.Content = fmt.Sprintf("[tool result identical to artifact %s (the result of tool_use %s earlier in this conversation, %d bytes); content omitted]",
+					id, art.ToolUseID, art.Bytes)
+				art.Refs++
+				replaced++
+				continue
+			}
+			seen[id] = struct{}{}
+			if !exists {
+				arts.byID[id] = &ConversationArtifact{ID: id, ToolUseID: block.ToolUseID, Bytes: len(text), CreatedAt: now}
+				arts.order = append(arts.order, id)
+				if contents == nil {
+					contents = make(map[string]string)
+				}
+				contents[id] = text
+				stored = append(stored, id)
+			}
+		}
+	}
+	var orphans []string
+	for _, id := range arts.evict(h.config.ConversationArtifactMaxCount) {
+		if !h.artifactReferencedLocked(id) {
+			orphans = append(orphans, id)
+		}
+	}
+	total := len(arts.order)
+	h.sessionLastAccess[conversationKey] = now
+	h.cleanupSessionWorkdirsLocked()
+	h.sessionWorkdirsMu.Unlock()
+
+	if h.toolSpill != nil {
+		for _, id := range stored {
+			if _, err := h.toolSpill.Put(contents[id]); err != nil {
+				slog.Warn("保存会话 artifact 失败", "session", conversationKey, "id", id, "error", err)
+			}
+		}
+	}
+	h.removeOrphanArtifacts(orphans)
+	if replaced > 0 {
+		slog.Info("重复的 tool_result 已替换为 artifact 引用", "session", conversationKey, "replaced", replaced, "artifacts", total)
+	}
+	return bridged, replaced
+}
+
+// evict 淘汰最早的 artifact 直到不超过 maxCount（<= 0 不限制），返回被淘汰的 ID
+func (a *conversationArtifacts) evict(maxCount int) []string {
+	if maxCount <= 0 || len(a.order) <= maxCount {
+		return nil
+	}
+	n := len(a.order) - maxCount
+	evicted := append([]string(nil), a.order[:n]...)
+	for _, id := range evicted {
+		delete(a.byID, id)
+	}
+	a.order = append(a.order[:0], a.order[n:]...)
+	return evicted
+}
+
+// expireArtifactsLocked 在会话过期时移除其 artifact，返回不再被其他会话引用的 ID。
+// Must be called with sessionWorkdirsMu held for writing.
+func (h *Handler) expireArtifactsLocked(key string) []string {
+	arts, ok := h.sessionArtifacts[key]
+	if !ok {
+		return nil
+	}
+	delete(h.sessionArtifacts, key)
+	var orphans []string
+	for _, id := range arts.order {
+		if !h.artifactReferencedLocked(id) {
+			orphans = append(orphans, id)
+		}
+	}
+	return orphans
+}
+
+func (h *Handler) artifactReferencedLocked(id string) bool {
+	for _, other := range h.sessionArtifacts {
+		if _, ok := other.byID[id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// removeOrphanArtifacts 删除已不属于任何会话的 artifact 原文
+func (h *Handler) removeOrphanArtifacts(ids []string) {
+	if h.toolSpill == nil || len(ids) == 0 {
+		return
+	}
+	for _, id := range ids {
+		if err := h.toolSpill.Remove(id); err != nil {
+			slog.Warn("删除会话 artifact 失败", "id", id, "error", err)
+		}
+	}
+}
+
+// HandleConversationArtifacts 处理 /api/conversations/artifacts?conversation_id=：列出会话保存的 artifact，
+// 原文通过 /api/tool-results/{id} 查看
+func (h *Handler) HandleConversationArtifacts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id := strings.TrimSpace(r.URL.Query().Get("conversation_id"))
+	if id == "" {
+		http.Error(w, "conversation_id is required", http.StatusBadRequest)
+		return
+	}
+
+	h.sessionWorkdirsMu.RLock()
+	arts, ok := h.sessionArtifacts[id]
+	var list []ConversationArtifact
+	if ok {
+		list = make([]ConversationArtifact, 0, len(arts.order))
+		for _, artID := range arts.order {
+			list = append(list, *arts.byID[artID])
+		}
+	}
+	h.sessionWorkdirsMu.RUnlock()
+	if !ok {
+		http.Error(w, "Conversation not found", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"conversation_id": id, "artifacts": list})
+}
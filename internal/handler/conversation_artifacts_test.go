@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/prompt"
+	"orchids-api/internal/toolspill"
+)
+
+func toolResultMessage(toolUseID, content string) prompt.Message {
+	return prompt.Message{Role: "user", Content: prompt.MessageContent{Blocks: []prompt.ContentBlock{
+		{Type: "tool_result", ToolUseID: toolUseID, Content: content},
+	}}}
+}
+
+func newArtifactTestHandler(t *testing.T) (*Handler, *toolspill.Store) {
+	spill := toolspill.New(filepath.Join(t.TempDir(), "tool-results"))
+	h := &Handler{
+		config:            &config.Config{ConversationArtifacts: true, ConversationArtifactMinBytes: 100, ConversationArtifactMaxCount: 10},
+		sessionWorkdirs:   map[string]string{},
+		sessionConvIDs:    map[string]string{},
+		sessionLastAccess: map[string]time.Time{},
+	}
+	h.SetToolSpill(spill)
+	return h, spill
+}
+
+func TestBridgeToolResults_ReplacesRepeatsInRequest(t *testing.T) {
+	h, spill := newArtifactTestHandler(t)
+	file := strings.Repeat("package main\n", 50)
+	messages := []prompt.Message{
+		toolResultMessage("toolu_1", file),
+		{Role: "assistant", Content: prompt.MessageContent{Text: "ok"}},
+		toolResultMessage("toolu_2", file),
+		toolResultMessage("toolu_3", "short"),
+	}
+
+	bridged, replaced := h.bridgeToolResults("conv-1", messages)
+	if replaced != 1 {
+		t.Fatalf("replaced = %d, want 1", replaced)
+	}
+	if bridged[0].Content.Blocks[0].Content != file {
+		t.Fatal("first occurrence must keep the full content")
+	}
+	ref, _ := bridged[2].Content.Blocks[0].Content.(string)
+	id := toolspill.ID(file)
+	if !strings.Contains(ref, "artifact "+id) || !strings.Contains(ref, "toolu_1") {
+		t.Fatalf("reference = %q", ref)
+	}
+	if messages[2].Content.Blocks[0].Content != file {
+		t.Fatal("original messages were modified")
+	}
+	if data, err := spill.Get(id); err != nil || string(data) != file {
+		t.Fatalf("artifact not stored: %v", err)
+	}
+
+	// 下一轮重发完整历史：原文仍在首次出现的位置，引用保持不变
+	again, replaced := h.bridgeToolResults("conv-1", messages)
+	if replaced != 1 || again[2].Content.Blocks[0].Content != ref {
+		t.Fatalf("second turn replaced = %d, content = %v", replaced, again[2].Content.Blocks[0].Content)
+	}
+}
+
+func TestBridgeToolResults_CrossTurnRequiresUpstreamSession(t *testing.T) {
+	h, _ := newArtifactTestHandler(t)
+	file := strings.Repeat("x", 200)
+	h.bridgeToolResults("conv-1", []prompt.Message{toolResultMessage("toolu_1", file)})
+
+	// 只发送增量消息时，没有绑定上游会话就无法引用之前轮次的内容
+	incremental := []prompt.Message{toolResultMessage("toolu_2", file)}
+	if _, replaced := h.bridgeToolResults("conv-1", incremental); replaced != 0 {
+		t.Fatalf("replaced without upstream session = %d", replaced)
+	}
+	h.sessionConvIDs["conv-1"] = "warp-conv"
+	if _, replaced := h.bridgeToolResults("conv-1", incremental); replaced != 1 {
+		t.Fatalf("replaced with upstream session = %d", replaced)
+	}
+}
+
+func TestBridgeToolResults_ExpiresWithSession(t *testing.T) {
+	h, spill := newArtifactTestHandler(t)
+	file := strings.Repeat("y", 200)
+	h.bridgeToolResults("conv-1", []prompt.Message{toolResultMessage("toolu_1", file)})
+	id := toolspill.ID(file)
+
+	h.sessionWorkdirsMu.Lock()
+	orphans := h.expireArtifactsLocked("conv-1")
+	h.sessionWorkdirsMu.Unlock()
+	if len(orphans) != 1 || orphans[0] != id {
+		t.Fatalf("orphans = %v", orphans)
+	}
+	h.removeOrphanArtifacts(orphans)
+	if _, err := spill.Get(id); err == nil {
+		t.Fatal("artifact still stored after session expired")
+	}
+	if _, ok := h.sessionArtifacts["conv-1"]; ok {
+		t.Fatal("session artifacts not removed")
+	}
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"log/slog"
+	"time"
+
+	"orchids-api/internal/prompt"
+)
+
+// branchHashLen 为会话历史中每条消息保留的哈希前缀长度，只用于比较，足以避免误判
+const branchHashLen = 16
+
+// detectConversationBranch 记录会话本次请求的消息哈希，并与上一次比较：正常的下一轮请求是上次历史的严格延续；
+// 与上次历史有共同前缀却没有延续时，说明客户端重新生成了回复或编辑了较早的消息（分叉），此时清除绑定在旧分支上的上游会话 ID，
+// 避免上游在已被放弃的对话上继续。返回分叉点（与上次历史相同的消息数）
+func (h *Handler) detectConversationBranch(conversationKey string, messages []prompt.Message) (int, bool) {
+	if conversationKey == "" || len(messages) == 0 {
+		return 0, false
+	}
+	hashes := prompt.HashMessages(messages)
+	for i, hash := range hashes {
+		if len(hash) > branchHashLen {
+			hashes[i] = hash[:branchHashLen]
+		}
+	}
+
+	h.sessionWorkdirsMu.Lock()
+	defer h.sessionWorkdirsMu.Unlock()
+	if h.sessionHistory == nil {
+		h.sessionHistory = make(map[string][]string)
+	}
+	if h.sessionLastAccess == nil {
+		h.sessionLastAccess = make(map[string]time.Time)
+	}
+	prev := h.sessionHistory[conversationKey]
+	h.sessionHistory[conversationKey] = hashes
+	h.sessionLastAccess[conversationKey] = time.Now()
+	if len(prev) == 0 {
+		return 0, false
+	}
+
+	common := 0
+	for common < len(prev) && common < len(hashes) && prev[common] == hashes[common] {
+		common++
+	}
+	// 与上次没有任何共同消息时视为只发送增量消息的客户端（依赖上游会话保存历史），不是分叉
+	if common == 0 || (common == len(prev) && len(hashes) > len(prev)) {
+		return common, false
+	}
+	if _, ok := h.sessionConvIDs[conversationKey]; ok {
+		delete(h.sessionConvIDs, conversationKey)
+		slog.Info("会话分叉，放弃旧分支的上游会话", "session", conversationKey, "common_messages", common, "previous_messages", len(prev), "messages", len(hashes))
+	}
+	return common, true
+}
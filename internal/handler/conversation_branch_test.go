@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"testing"
+
+	"orchids-api/internal/prompt"
+)
+
+func TestDetectConversationBranch(t *testing.T) {
+	h := &Handler{sessionConvIDs: map[string]string{}}
+	msg := func(role, text string) prompt.Message {
+		return prompt.Message{Role: role, Content: prompt.MessageContent{Text: text}}
+	}
+	turn1 := []prompt.Message{msg("user", "hi")}
+	turn2 := append(turn1, msg("assistant", "hello"), msg("user", "write a test"))
+
+	if _, branched := h.detectConversationBranch("conv", turn1); branched {
+		t.Fatal("first request reported as branch")
+	}
+	h.sessionConvIDs["conv"] = "upstream-1"
+	if _, branched := h.detectConversationBranch("conv", turn2); branched || h.sessionConvIDs["conv"] != "upstream-1" {
+		t.Fatal("continuation reported as branch")
+	}
+
+	// 重新生成：历史与上次相同（去掉了上次生成的 assistant 回复）
+	common, branched := h.detectConversationBranch("conv", turn2)
+	if !branched || common != 3 {
+		t.Fatalf("regenerate: common=%d branched=%v", common, branched)
+	}
+	if _, ok := h.sessionConvIDs["conv"]; ok {
+		t.Fatal("upstream session of abandoned branch was kept")
+	}
+
+	// 编辑较早的用户消息
+	edited := append(append([]prompt.Message{}, turn1...), msg("assistant", "hello"), msg("user", "write docs"))
+	if common, branched := h.detectConversationBranch("conv", edited); !branched || common != 2 {
+		t.Fatalf("edit: common=%d branched=%v", common, branched)
+	}
+
+	// 只发送最新消息的客户端依赖上游会话，不视为分叉
+	h.sessionConvIDs["delta"] = "upstream-2"
+	h.detectConversationBranch("delta", []prompt.Message{msg("user", "first")})
+	if _, branched := h.detectConversationBranch("delta", []prompt.Message{msg("user", "second")}); branched || h.sessionConvIDs["delta"] != "upstream-2" {
+		t.Fatal("delta-only request reported as branch")
+	}
+}
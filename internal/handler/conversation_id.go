@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+
+	"orchids-api/internal/middleware"
+	"orchids-api/internal/prompt"
+)
+
+// ConversationIDHeader 返回本次请求使用的会话 ID；客户端未提供时为服务端生成的 ID，回传即可继续同一会话
+const ConversationIDHeader = "X-Conversation-Id"
+
+// generatedConversationPrefix 为服务端生成的会话 ID 前缀
+const generatedConversationPrefix = "conv_"
+
+// generateConversationID 在客户端未提供会话 ID 时根据 API key、系统提示与首条消息生成稳定的 ID：
+// 无状态客户端每轮都重发完整历史，首条消息不变，因此同一对话的后续请求得到相同的 ID，
+// 会话级的上游会话复用与摘要缓存因此同样生效。首条消息相同的不同对话会得到相同的 ID，
+// 由 detectConversationBranch 在历史不再延续时放弃旧的上游会话
+func generateConversationID(r *http.Request, req ClaudeRequest) string {
+	if len(req.Messages) == 0 {
+		return ""
+	}
+	hasher := sha256.New()
+	hasher.Write([]byte(middleware.APIKeyID(r)))
+	hasher.Write([]byte{0})
+	for _, item := range req.System {
+		hasher.Write([]byte(item.Text))
+		hasher.Write([]byte{0})
+	}
+	hasher.Write([]byte(prompt.HashMessages(req.Messages[:1])[0]))
+	return generatedConversationPrefix + hex.EncodeToString(hasher.Sum(nil))[:24]
+}
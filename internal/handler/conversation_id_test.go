@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/prompt"
+)
+
+func statelessRequest(texts ...string) ClaudeRequest {
+	req := ClaudeRequest{Model: "claude-opus-4-6", Tools: []interface{}{}}
+	for i, text := range texts {
+		role := "user"
+		if i%2 == 1 {
+			role = "assistant"
+		}
+		req.Messages = append(req.Messages, prompt.Message{Role: role, Content: prompt.MessageContent{Text: text}})
+	}
+	return req
+}
+
+func TestGenerateConversationID_StableAcrossTurns(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	r.Header.Set("x-api-key", "sk-test")
+
+	first := generateConversationID(r, statelessRequest("hello"))
+	if !strings.HasPrefix(first, generatedConversationPrefix) {
+		t.Fatalf("id = %q, want %s prefix", first, generatedConversationPrefix)
+	}
+	if next := generateConversationID(r, statelessRequest("hello", "hi", "again")); next != first {
+		t.Fatalf("id changed across turns: %q -> %q", first, next)
+	}
+	if other := generateConversationID(r, statelessRequest("different")); other == first {
+		t.Fatalf("different first message produced the same id %q", other)
+	}
+
+	other := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	other.Header.Set("x-api-key", "sk-other")
+	if id := generateConversationID(other, statelessRequest("hello")); id == first {
+		t.Fatalf("different api key produced the same id %q", id)
+	}
+
+	withSystem := statelessRequest("hello")
+	withSystem.System = []prompt.SystemItem{{Type: "text", Text: "be brief"}}
+	if id := generateConversationID(r, withSystem); id == first {
+		t.Fatalf("different system prompt produced the same id %q", id)
+	}
+
+	if id := generateConversationID(r, ClaudeRequest{}); id != "" {
+		t.Fatalf("id without messages = %q, want empty", id)
+	}
+}
+
+func TestHandleMessages_GeneratedConversationIDPinsUpstreamSession(t *testing.T) {
+	t.Parallel()
+
+	client := &fakePayloadClient{
+		conversationIDsByOp: []string{"warp_upstream_conv_stateless"},
+	}
+	h := &Handler{
+		config:            &config.Config{DebugEnabled: false},
+		client:            client,
+		sessionWorkdirs:   map[string]string{},
+		sessionConvIDs:    map[string]string{},
+		sessionLastAccess: map[string]time.Time{},
+		recentRequests:    map[string]*recentRequest{},
+	}
+
+	send := func(req ClaudeRequest) *httptest.ResponseRecorder {
+		body, err := json.Marshal(req)
+		if err != nil {
+			t.Fatalf("marshal request: %v", err)
+		}
+		rec := httptest.NewRecorder()
+		h.HandleMessages(rec, httptest.NewRequest(http.MethodPost, "/warp/v1/messages", bytes.NewReader(body)))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+		}
+		return rec
+	}
+
+	rec1 := send(statelessRequest("first"))
+	id := rec1.Header().Get(ConversationIDHeader)
+	if !strings.HasPrefix(id, generatedConversationPrefix) {
+		t.Fatalf("%s = %q, want generated id", ConversationIDHeader, id)
+	}
+
+	rec2 := send(statelessRequest("first", "reply", "second"))
+	if got := rec2.Header().Get(ConversationIDHeader); got != id {
+		t.Fatalf("second %s = %q, want %q", ConversationIDHeader, got, id)
+	}
+
+	calls := client.snapshotCalls()
+	if len(calls) != 2 {
+		t.Fatalf("expected 2 upstream calls, got %d", len(calls))
+	}
+	if calls[1].ChatSessionID != "warp_upstream_conv_stateless" {
+		t.Fatalf("second ChatSessionID = %q, want reused upstream session", calls[1].ChatSessionID)
+	}
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ConversationTokensHeader 返回当前会话累计的 token 用量，格式为 input=N,output=M,requests=K。
+// 非流式响应包含本次请求；流式响应的响应头为本次之前的累计值，结束时通过同名 trailer 给出包含本次的值
+const ConversationTokensHeader = "X-Conversation-Tokens"
+
+// ConversationUsage 为单个会话累计的 token 用量
+type ConversationUsage struct {
+	ConversationID string    `json:"conversation_id"`
+	InputTokens    int64     `json:"input_tokens"`
+	OutputTokens   int64     `json:"output_tokens"`
+	Requests       int64     `json:"requests"`
+	LastUsed       time.Time `json:"last_used"`
+}
+
+func (u ConversationUsage) headerValue() string {
+	return fmt.Sprintf("input=%d,output=%d,requests=%d", u.InputTokens, u.OutputTokens, u.Requests)
+}
+
+// conversationUsage 返回会话当前的累计用量
+func (h *Handler) conversationUsage(key string) ConversationUsage {
+	h.sessionWorkdirsMu.RLock()
+	defer h.sessionWorkdirsMu.RUnlock()
+	if u, ok := h.sessionTokens[key]; ok {
+		return *u
+	}
+	return ConversationUsage{ConversationID: key}
+}
+
+// recordConversationTokens 累加会话用量并返回累加后的值；与工作目录等会话状态一同过期
+func (h *Handler) recordConversationTokens(key string, input, output int) ConversationUsage {
+	now := time.Now()
+	h.sessionWorkdirsMu.Lock()
+	defer h.sessionWorkdirsMu.Unlock()
+	if h.sessionTokens == nil {
+		h.sessionTokens = make(map[string]*ConversationUsage)
+	}
+	if h.sessionLastAccess == nil {
+		h.sessionLastAccess = make(map[string]time.Time)
+	}
+	u, ok := h.sessionTokens[key]
+	if !ok {
+		u = &ConversationUsage{ConversationID: key}
+		h.sessionTokens[key] = u
+	}
+	u.InputTokens += int64(input)
+	u.OutputTokens += int64(output)
+	u.Requests++
+	u.LastUsed = now
+	h.sessionLastAccess[key] = now
+	h.cleanupSessionWorkdirsLocked()
+	return *u
+}
+
+// HandleConversationTokens 处理 /api/conversations/tokens：按最近使用时间列出会话累计用量，
+// ?conversation_id= 只返回指定会话
+func (h *Handler) HandleConversationTokens(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	if id := strings.TrimSpace(r.URL.Query().Get("conversation_id")); id != "" {
+		h.sessionWorkdirsMu.RLock()
+		u, ok := h.sessionTokens[id]
+		var usage ConversationUsage
+		if ok {
+			usage = *u
+		}
+		h.sessionWorkdirsMu.RUnlock()
+		if !ok {
+			http.Error(w, "Conversation not found", http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(usage)
+		return
+	}
+
+	h.sessionWorkdirsMu.RLock()
+	list := make([]ConversationUsage, 0, len(h.sessionTokens))
+	for _, u := range h.sessionTokens {
+		list = append(list, *u)
+	}
+	h.sessionWorkdirsMu.RUnlock()
+	sort.Slice(list, func(i, j int) bool { return list[i].LastUsed.After(list[j].LastUsed) })
+	json.NewEncoder(w).Encode(map[string]interface{}{"conversations": list})
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRecordConversationTokens_Accumulates(t *testing.T) {
+	h := &Handler{}
+	h.recordConversationTokens("conv-1", 100, 20)
+	usage := h.recordConversationTokens("conv-1", 150, 30)
+	h.recordConversationTokens("conv-2", 10, 1)
+
+	if usage.InputTokens != 250 || usage.OutputTokens != 50 || usage.Requests != 2 {
+		t.Fatalf("usage = %+v", usage)
+	}
+	if got := usage.headerValue(); got != "input=250,output=50,requests=2" {
+		t.Fatalf("header = %q", got)
+	}
+
+	rec := httptest.NewRecorder()
+	h.HandleConversationTokens(rec, httptest.NewRequest(http.MethodGet, "/api/conversations/tokens?conversation_id=conv-1", nil))
+	var one ConversationUsage
+	if err := json.Unmarshal(rec.Body.Bytes(), &one); err != nil || one.InputTokens != 250 {
+		t.Fatalf("lookup = %s (%v)", rec.Body.String(), err)
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleConversationTokens(rec, httptest.NewRequest(http.MethodGet, "/api/conversations/tokens?conversation_id=missing", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("missing conversation status = %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.HandleConversationTokens(rec, httptest.NewRequest(http.MethodGet, "/api/conversations/tokens", nil))
+	var list struct {
+		Conversations []ConversationUsage `json:"conversations"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list.Conversations) != 2 || list.Conversations[0].ConversationID != "conv-2" {
+		t.Fatalf("list = %s (%v)", rec.Body.String(), err)
+	}
+}
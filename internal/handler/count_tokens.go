@@ -1,13 +1,33 @@
 package handler
 
 import (
+	"encoding/base64"
 	"encoding/json"
+	"errors"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
 	"net/http"
+	"strings"
 
-	"orchids-api/internal/debug"
+	"orchids-api/internal/adapter"
 	"orchids-api/internal/prompt"
 )
 
+// 以下常量对齐 Anthropic count_tokens 的计费口径
+const (
+	// toolUseSystemTokens 为带 tools 时上游自动注入的工具使用系统提示
+	toolUseSystemTokens = 346
+	// messageOverheadTokens 为每条消息的角色与分隔标记
+	messageOverheadTokens = 3
+	// 图片按 (宽 × 高) / 750 计费，长边超过 1568 时先等比缩放，单张上限约 1600
+	imageTokenPixels   = 750
+	imageMaxLongEdge   = 1568
+	imageMaxTokens     = 1600
+	imageDefaultTokens = imageMaxTokens
+)
+
 // HandleCountTokens handles /v1/messages/count_tokens requests.
 func (h *Handler) HandleCountTokens(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -15,35 +35,40 @@ func (h *Handler) HandleCountTokens(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 计数请求不需要原始字节（无去重指纹），直接流式解码
+	if limit := h.maxRequestBytes(); limit > 0 {
+		if r.ContentLength > limit {
+			h.writeRequestTooLarge(w)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
 	var req ClaudeRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			h.writeRequestTooLarge(w)
+			return
+		}
 		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
+	if issues := validateClaudeRequest(req, adapter.FormatAnthropic); len(issues) > 0 {
+		h.writeValidationError(w, issues)
+		return
+	}
+	if _, ok := h.anthropicBetas(w, r); !ok {
+		return
+	}
 
-	logger := debug.New(h.config.DebugEnabled, h.config.DebugLogSSE)
+	logger := h.newDebugLogger(r)
 	defer logger.Close()
 	logger.LogIncomingRequest(req)
 
-	conversationKey := conversationKeyForRequest(r, req)
-	opts := prompt.PromptOptions{
-		Context:          r.Context(),
-		ConversationID:   conversationKey,
-		MaxTokens:        h.config.ContextMaxTokens,
-		SummaryMaxTokens: h.config.ContextSummaryMaxTokens,
-		KeepTurns:        h.config.ContextKeepTurns,
-		SummaryCache:     h.summaryCache,
-	}
-
-	builtPrompt := prompt.BuildPromptV2WithOptions(prompt.ClaudeAPIRequest{
-		Model:    req.Model,
-		Messages: req.Messages,
-		System:   req.System,
-		Tools:    req.Tools,
-		Stream:   false,
-	}, opts)
-
-	inputTokens := h.estimateInputTokens(r.Context(), req.Model, builtPrompt)
+	// 按客户端看到的原始请求计数（而不是代理改写后的 prompt），与 Anthropic 的结果保持一致，
+	// 否则工具较多的 agent 会据此错误地安排上下文预算
+	text, fixed := countableRequest(req)
+	inputTokens := h.estimateInputTokens(r.Context(), req.Model, text) + fixed
 
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(map[string]int{
@@ -53,3 +78,140 @@ func (h *Handler) HandleCountTokens(w http.ResponseWriter, r *http.Request) {
 		_ = err
 	}
 }
+
+// countableRequest 将请求拆成需要分词计数的文本与固定开销（图片、工具系统提示、消息标记）。
+// system 的 cache_control 只影响计费方式，对应文本照常计入；历史 assistant 轮次中的 thinking 不计入
+func countableRequest(req ClaudeRequest) (string, int) {
+	var sb strings.Builder
+	fixed := 0
+
+	for _, item := range req.System {
+		writeCountable(&sb, item.Text)
+	}
+
+	if len(req.Tools) > 0 {
+		fixed += toolUseSystemTokens
+		for _, tool := range req.Tools {
+			writeCountable(&sb, toolSchemaText(tool))
+		}
+	}
+
+	for _, msg := range req.Messages {
+		fixed += messageOverheadTokens
+		if msg.Content.IsString() {
+			writeCountable(&sb, msg.Content.GetText())
+			continue
+		}
+		for _, block := range msg.Content.GetBlocks() {
+			switch block.Type {
+			case "text":
+				writeCountable(&sb, block.Text)
+			case "image":
+				fixed += imageTokens(block.Source)
+			case "tool_use":
+				writeCountable(&sb, block.Name)
+				if block.Input != nil {
+					if data, err := json.Marshal(block.Input); err == nil {
+						writeCountable(&sb, string(data))
+					}
+				}
+			case "tool_result":
+				fixed += countToolResult(&sb, block.Content)
+			case "thinking", "redacted_thinking":
+			default:
+				if data, err := json.Marshal(block); err == nil {
+					writeCountable(&sb, string(data))
+				}
+			}
+		}
+	}
+	return sb.String(), fixed
+}
+
+func writeCountable(sb *strings.Builder, text string) {
+	if text == "" {
+		return
+	}
+	if sb.Len() > 0 {
+		sb.WriteByte('\n')
+	}
+	sb.WriteString(text)
+}
+
+// toolSchemaText 返回工具定义中会发给模型的部分（名称、描述与 input_schema），去掉 cache_control
+func toolSchemaText(tool interface{}) string {
+	tm, ok := tool.(map[string]interface{})
+	if !ok {
+		data, _ := json.Marshal(tool)
+		return string(data)
+	}
+	schema := make(map[string]interface{}, len(tm))
+	for k, v := range tm {
+		if k == "cache_control" {
+			continue
+		}
+		schema[k] = v
+	}
+	data, _ := json.Marshal(schema)
+	return string(data)
+}
+
+// countToolResult 写入 tool_result 中的文本，返回其中图片的 token 数
+func countToolResult(sb *strings.Builder, content interface{}) int {
+	switch v := content.(type) {
+	case nil:
+		return 0
+	case string:
+		writeCountable(sb, v)
+		return 0
+	case []interface{}:
+		fixed := 0
+		for _, item := range v {
+			m, ok := item.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			switch m["type"] {
+			case "text":
+				text, _ := m["text"].(string)
+				writeCountable(sb, text)
+			case "image":
+				var src prompt.ImageSource
+				if data, err := json.Marshal(m["source"]); err == nil {
+					json.Unmarshal(data, &src)
+				}
+				fixed += imageTokens(&src)
+			default:
+				data, _ := json.Marshal(m)
+				writeCountable(sb, string(data))
+			}
+		}
+		return fixed
+	default:
+		data, _ := json.Marshal(v)
+		writeCountable(sb, string(data))
+		return 0
+	}
+}
+
+// imageTokens 按图片尺寸估算 token；无法解析尺寸（URL 图片或未知格式）时按单张上限计
+func imageTokens(src *prompt.ImageSource) int {
+	if src == nil || src.Type != "base64" || src.Data == "" {
+		return imageDefaultTokens
+	}
+	cfg, _, err := image.DecodeConfig(base64.NewDecoder(base64.StdEncoding, strings.NewReader(src.Data)))
+	if err != nil || cfg.Width <= 0 || cfg.Height <= 0 {
+		return imageDefaultTokens
+	}
+	return imageTokensForSize(cfg.Width, cfg.Height)
+}
+
+func imageTokensForSize(width, height int) int {
+	w, h := float64(width), float64(height)
+	if long := max(w, h); long > imageMaxLongEdge {
+		scale := imageMaxLongEdge / long
+		w, h = w*scale, h*scale
+	}
+	tokens := int(w*h/imageTokenPixels + 0.5)
+	return max(1, min(tokens, imageMaxTokens))
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"orchids-api/internal/config"
+)
+
+func countTokens(t *testing.T, body string) int {
+	t.Helper()
+	h := &Handler{config: &config.Config{}}
+	req := httptest.NewRequest(http.MethodPost, "/orchids/v1/messages/count_tokens", bytes.NewReader([]byte(body)))
+	rec := httptest.NewRecorder()
+	h.HandleCountTokens(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var resp struct {
+		InputTokens int `json:"input_tokens"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.InputTokens
+}
+
+func TestHandleCountTokens_CountsToolSchemas(t *testing.T) {
+	base := `{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hello"}]`
+	plain := countTokens(t, base+`}`)
+	withTools := countTokens(t, base+`,"tools":[{"name":"Read","description":"Read a file from the local filesystem and return its contents with line numbers","input_schema":{"type":"object","properties":{"file_path":{"type":"string","description":"Absolute path"},"offset":{"type":"integer"},"limit":{"type":"integer"}},"required":["file_path"]},"cache_control":{"type":"ephemeral"}}]}`)
+	if diff := withTools - plain; diff < toolUseSystemTokens+20 {
+		t.Fatalf("tool schema added %d tokens, want > %d", diff, toolUseSystemTokens+20)
+	}
+}
+
+func TestHandleCountTokens_ImagesAndThinking(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 200, 150))); err != nil {
+		t.Fatal(err)
+	}
+	img := base64.StdEncoding.EncodeToString(buf.Bytes())
+
+	plain := countTokens(t, `{"model":"m","messages":[{"role":"user","content":[{"type":"text","text":"describe"}]}]}`)
+	withImage := countTokens(t, `{"model":"m","messages":[{"role":"user","content":[{"type":"text","text":"describe"},{"type":"image","source":{"type":"base64","media_type":"image/png","data":"`+img+`"}}]}]}`)
+	if want := imageTokensForSize(200, 150); withImage-plain != want {
+		t.Fatalf("image added %d tokens, want %d", withImage-plain, want)
+	}
+
+	withThinking := countTokens(t, `{"model":"m","messages":[{"role":"user","content":[{"type":"text","text":"describe"}]},{"role":"assistant","content":[{"type":"thinking","thinking":"a very long chain of thought that should not be billed again"},{"type":"text","text":"ok"}]}]}`)
+	withoutThinking := countTokens(t, `{"model":"m","messages":[{"role":"user","content":[{"type":"text","text":"describe"}]},{"role":"assistant","content":[{"type":"text","text":"ok"}]}]}`)
+	if withThinking != withoutThinking {
+		t.Fatalf("thinking counted: %d vs %d", withThinking, withoutThinking)
+	}
+}
+
+func TestImageTokensForSize(t *testing.T) {
+	cases := []struct{ w, h, want int }{
+		{200, 200, 53},
+		{1000, 1000, 1333},
+		{4000, 3000, imageMaxTokens},
+	}
+	for _, tc := range cases {
+		if got := imageTokensForSize(tc.w, tc.h); got != tc.want {
+			t.Errorf("imageTokensForSize(%d, %d) = %d, want %d", tc.w, tc.h, got, tc.want)
+		}
+	}
+}
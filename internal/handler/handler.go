@@ -8,7 +8,6 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"log/slog"
 	"net/http"
 	rtdebug "runtime/debug"
@@ -16,15 +15,21 @@ import (
 	"sync"
 	"time"
 
+	"orchids-api/internal/accountstats"
 	"orchids-api/internal/adapter"
 	"orchids-api/internal/config"
 	"orchids-api/internal/debug"
+	"orchids-api/internal/events"
+	"orchids-api/internal/flags"
 	"orchids-api/internal/loadbalancer"
+	"orchids-api/internal/middleware"
 	"orchids-api/internal/orchids"
+	"orchids-api/internal/perf"
 	"orchids-api/internal/prompt"
 	"orchids-api/internal/store"
 	"orchids-api/internal/summarycache"
 	"orchids-api/internal/tokencache"
+	"orchids-api/internal/toolspill"
 	"orchids-api/internal/upstream"
 	"orchids-api/internal/util"
 	"orchids-api/internal/warp"
@@ -38,16 +43,42 @@ type Handler struct {
 	summaryStats *summarycache.Stats
 	summaryLog   bool
 	tokenCache   tokencache.Cache
+	accountStats *accountstats.Tracker
+	flags        *flags.Manager
+	events       *events.Bus
 
 	sessionWorkdirsMu sync.RWMutex
-	sessionWorkdirs   map[string]string    // Map conversationKey -> string (workdir)
-	sessionConvIDs    map[string]string    // Map conversationKey -> upstream warp conversationID
-	sessionLastAccess map[string]time.Time // Map conversationKey -> last access time
+	sessionWorkdirs   map[string]string                 // Map conversationKey -> string (workdir)
+	sessionConvIDs    map[string]string                 // Map conversationKey -> upstream warp conversationID
+	sessionLastAccess map[string]time.Time              // Map conversationKey -> last access time
+	sessionTokens     map[string]*ConversationUsage     // Map conversationKey -> cumulative token usage
+	sessionEfforts    map[string]string                 // Map conversationKey -> last reasoning_effort
+	sessionHistory    map[string][]string               // Map conversationKey -> message hashes of the last request
+	sessionArtifacts  map[string]*conversationArtifacts // Map conversationKey -> tool_result artifacts
 	sessionCleanupRun time.Time
 
 	recentReqMu      sync.Mutex
 	recentRequests   map[string]*recentRequest
 	recentCleanupRun time.Time
+
+	inflightMu sync.Mutex
+	inflight   map[string]*inflightRequest // Map traceID -> cancellable in-flight request
+
+	shutdownMu     sync.Mutex
+	shutdownCtx    context.Context // 关闭宽限期结束时取消，见 BeginShutdown
+	shutdownCancel context.CancelFunc
+	draining       bool
+	retryAfter     time.Duration
+
+	streamsMu sync.Mutex
+	streams   map[string]*pollStream // Map stream token -> long-polling request
+
+	models    modelListCache   // 按渠道缓存的 /v1/models 响应
+	toolSpill *toolspill.Store // 超大 tool_result 原文
+
+	outputFilterMu       sync.Mutex
+	outputFilterRaw      []config.OutputFilterRule // outputFilterCompiled 对应的配置
+	outputFilterCompiled *outputFilter
 }
 
 type UpstreamClient interface {
@@ -66,6 +97,11 @@ type ClaudeRequest struct {
 	Stream         bool                   `json:"stream"`
 	ConversationID string                 `json:"conversation_id"`
 	Metadata       map[string]interface{} `json:"metadata"`
+	// ReasoningEffort 为 OpenAI 客户端的 reasoning_effort（minimal/low/medium/high）
+	ReasoningEffort string      `json:"reasoning_effort,omitempty"`
+	ToolChoice      *ToolChoice `json:"tool_choice,omitempty"`
+	// ResponseLanguage 为扩展字段，开启 post_translation 时要求把输出翻译为该语言（BCP 47，如 zh-CN）
+	ResponseLanguage string `json:"response_language,omitempty"`
 }
 
 type toolCall struct {
@@ -74,8 +110,6 @@ type toolCall struct {
 	input string
 }
 
-const keepAliveInterval = 15 * time.Second
-const maxRequestBytes = 50 * 1024 * 1024 // 50MB
 const duplicateWindow = 2 * time.Second
 const duplicateCleanupWindow = 10 * time.Second
 
@@ -112,6 +146,18 @@ func (h *Handler) SetTokenCache(cache tokencache.Cache) {
 	h.tokenCache = cache
 }
 
+func (h *Handler) SetAccountStats(tracker *accountstats.Tracker) {
+	h.accountStats = tracker
+}
+
+func (h *Handler) SetFlags(m *flags.Manager) {
+	h.flags = m
+}
+
+func (h *Handler) SetEventBus(b *events.Bus) {
+	h.events = b
+}
+
 func (h *Handler) writeErrorResponse(w http.ResponseWriter, errType string, message string, code int) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(code)
@@ -251,37 +297,44 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 		h.writeErrorResponse(w, "invalid_request_error", "Method not allowed", http.StatusMethodNotAllowed)
 		return
 	}
+	if draining, retryAfter := h.shuttingDown(); draining {
+		h.writeShuttingDown(w, retryAfter)
+		return
+	}
+	accessLog := middleware.AccessLogFieldsFrom(r.Context())
+	accessLog.SetChannel(channelFromPath(r.URL.Path))
 
 	var req ClaudeRequest
-	if maxRequestBytes > 0 {
-		r.Body = http.MaxBytesReader(w, r.Body, maxRequestBytes)
+	body, ok := h.decodeRequestBody(w, r, &req)
+	if !ok {
+		return
 	}
-	bodyBytes, err := io.ReadAll(r.Body)
-	if err != nil {
-		if maxRequestBytes > 0 {
-			var maxErr *http.MaxBytesError
-			if errors.As(err, &maxErr) {
-				h.writeErrorResponse(w, "invalid_request_error", "Request body too large", http.StatusRequestEntityTooLarge)
-				return
-			}
-		}
-		h.writeErrorResponse(w, "invalid_request_error", "Invalid request body", http.StatusBadRequest)
+	reqHash := h.computeRequestHash(r, body.Bytes())
+	bodyLen := body.Len()
+	perf.ReleaseByteBuffer(body)
+	if issues := validateClaudeRequest(req, adapter.DetectResponseFormat(r.URL.Path)); len(issues) > 0 {
+		slog.Debug("Request validation failed", "path", r.URL.Path, "issues", len(issues), "first", issues[0].Field)
+		h.writeValidationError(w, issues)
 		return
 	}
-	if err := json.Unmarshal(bodyBytes, &req); err != nil {
-		h.writeErrorResponse(w, "invalid_request_error", "Invalid request body", http.StatusBadRequest)
+	if channel := channelFromPath(r.URL.Path); !h.config.ModelAllowed(channel, req.Model) {
+		slog.Debug("Model not allowed on channel", "path", r.URL.Path, "model", req.Model)
+		h.writeErrorResponse(w, "permission_error", fmt.Sprintf("model %q is not available on this channel", req.Model), http.StatusForbidden)
+		return
+	}
+	betas, ok := h.anthropicBetas(w, r)
+	if !ok {
 		return
 	}
 
 	// 初始化调试日志
-	logger := debug.New(h.config.DebugEnabled, h.config.DebugLogSSE)
+	logger := h.newDebugLogger(r)
 	defer logger.Close()
 
 	// 1. 记录进入的 Claude 请求
 	logger.LogIncomingRequest(req)
 
-	reqHash := h.computeRequestHash(r, bodyBytes)
-	slog.Debug("Request fingerprint", "hash", reqHash, "path", r.URL.Path, "content_length", len(bodyBytes), "retry", r.Header.Get("X-Stainless-Retry-Count"))
+	slog.Debug("Request fingerprint", "hash", reqHash, "path", r.URL.Path, "content_length", bodyLen, "retry", r.Header.Get("X-Stainless-Retry-Count"))
 	if dup, inFlight := h.registerRequest(reqHash); dup {
 		slog.Warn("Duplicate request suppressed", "hash", reqHash, "in_flight", inFlight, "path", r.URL.Path, "user_agent", r.UserAgent())
 		logger.LogEarlyExit("duplicate_request", map[string]interface{}{
@@ -294,6 +347,10 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 	}
 	defer h.finishRequest(reqHash)
 
+	// 登记可取消的上游 context，支持 DELETE /v1/requests/{trace_id}
+	r, unregisterInflight := h.registerInflight(r)
+	defer unregisterInflight()
+
 	// ...
 	if ok, command := isCommandPrefixRequest(req); ok {
 		slog.Debug("Handling command prefix request", "command", command)
@@ -316,6 +373,10 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 	}
 
 	cacheStrategy := h.config.CacheStrategy
+	if betas.PromptCaching && (cacheStrategy == "" || cacheStrategy == "none" || cacheStrategy == "off") {
+		// 客户端声明了 prompt-caching，至少补充 system 与历史轮次的缓存断点
+		cacheStrategy = "split"
+	}
 	if cacheStrategy != "" && cacheStrategy != "none" {
 		applyCacheStrategy(&req, cacheStrategy)
 	}
@@ -327,6 +388,15 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 
 	// Context and Conversation Key
 	conversationKey := conversationKeyForRequest(r, req)
+	if conversationKey == "" {
+		conversationKey = generateConversationID(r, req)
+	}
+	if conversationKey != "" {
+		w.Header().Set(ConversationIDHeader, conversationKey)
+	}
+	if h.config.AttributionLabel != "" {
+		w.Header().Set(AttributionHeader, h.config.AttributionLabel)
+	}
 
 	forcedChannel := channelFromPath(r.URL.Path)
 	effectiveWorkdir, prevWorkdir, workdirChanged := h.resolveWorkdir(r, req, conversationKey)
@@ -341,6 +411,20 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 			h.sessionWorkdirsMu.Unlock()
 		}
 	}
+	// 重新生成或编辑较早消息时不再复用旧分支的上游会话；摘要缓存在构建 prompt 时按共同前缀截断
+	h.detectConversationBranch(conversationKey, req.Messages)
+
+	// 系统提示超出上限时在选择账号前裁剪或拒绝，Orchids 与 Warp 的各种 prompt 构建方式都使用裁剪后的结果
+	if !h.limitSystemPrompt(w, &req, logger) {
+		return
+	}
+
+	flagKey := middleware.APIKeyID(r)
+	if strings.EqualFold(forcedChannel, "warp") && !h.flags.Enabled(flags.ProviderWarp, flagKey, true) {
+		logger.LogEarlyExit("provider_disabled", map[string]interface{}{"channel": forcedChannel})
+		h.writeErrorResponse(w, "overloaded_error", "warp provider is disabled", http.StatusServiceUnavailable)
+		return
+	}
 
 	// 选择账号 (Initial Selection)
 	failedAccountIDs := []int64{}
@@ -358,6 +442,10 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	slog.Debug("Checkpoint: selectAccount success")
+	accessLog.SetModel(req.Model)
+	if currentAccount != nil {
+		accessLog.SetAccount(currentAccount.ID)
+	}
 
 	// 捕获账号快照，用于请求结束后检测 forceRefreshToken 是否更新了账号信息
 	var accountSnapshot *store.Account
@@ -370,15 +458,20 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 	if currentAccount != nil && strings.EqualFold(currentAccount.AccountType, "warp") {
 		isWarpRequest = true
 	}
+	// 会话中重复的 tool_result 替换为对首次出现的引用，需在摘录前按完整内容比较
+	req.Messages, _ = h.bridgeToolResults(conversationKey, req.Messages)
+	// 摘录前检测完整的 tool_result，避免注入内容落在被省略的部分之外时漏检
+	req.Messages, _ = h.guardToolResults(req.Messages, flagKey)
 	if isWarpRequest {
 		// Warp passthrough mode: do not trim history/tool results.
 		slog.Debug("Checkpoint: warp passthrough, skip trim/sanitize")
+		if h.config.WarpToolResultSpill {
+			req.Messages, _ = h.limitToolResults(req.Messages, "warp")
+		}
 	} else {
-		// Orchids: Compress HUGE tool results (>100KB) to prevent upstream 413/Timeout
-		// 100KB limit is generous enough for most code but prevents MB-sized payloads.
+		// Orchids: 超大 tool_result（默认 >100KB）替换为摘录，避免上游 413/超时
 		slog.Debug("Checkpoint: compressing tool results")
-		compressed, _ := compressToolResults(req.Messages, 102400, "orchids")
-		req.Messages = compressed
+		req.Messages, _ = h.limitToolResults(req.Messages, "orchids")
 		if sanitized, changed := sanitizeSystemItems(req.System, false, h.config); changed {
 			req.System = sanitized
 			slog.Info("系统提示已移除 cc_entrypoint", "mode", h.config.OrchidsCCEntrypointMode, "warp", false)
@@ -386,10 +479,9 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 	}
 	slog.Debug("Checkpoint: message processing done")
 
-	// 手动管理连接计数，账号切换时需要释放旧账号、获取新账号
+	// 选择账号时已占用连接（受 max_concurrent 限制），账号切换时需要释放旧账号
 	trackedAccountID := int64(0)
 	if currentAccount != nil && h.loadBalancer != nil {
-		h.loadBalancer.AcquireConnection(currentAccount.ID)
 		trackedAccountID = currentAccount.ID
 	}
 	defer func() {
@@ -404,7 +496,8 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 	}
 
 	suggestionMode := isSuggestionMode(req.Messages)
-	noThinking := suggestionMode || h.config.SuppressThinking
+	reasoningDisplay := h.config.ReasoningDisplayFor(flagKey)
+	noThinking := suggestionMode || reasoningDisplay != config.ReasoningPassthrough
 	gateNoTools := false
 	suppressThinking := noThinking
 	if suggestionMode {
@@ -418,6 +511,11 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 		effectiveTools = nil
 		slog.Debug("tool_gate: disabled tools for short non-code request")
 	}
+	noTools := gateNoTools
+	if req.ToolChoice.disablesTools() {
+		effectiveTools = nil
+		noTools = true
+	}
 
 	// 构建 prompt（V2 Markdown 格式）
 	startBuild := time.Now()
@@ -435,11 +533,14 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 		SummaryCache:     h.summaryCache,
 		ProjectRoot:      effectiveWorkdir,
 	}
+	if betas.LongContext {
+		opts.MaxTokens = 0
+	}
 
 	slog.Debug("Starting prompt build...", "conversation_id", conversationKey)
 	isOrchidsAIClient := false
-	if _, ok := apiClient.(*orchids.Client); ok && strings.EqualFold(strings.TrimSpace(h.config.OrchidsImpl), "aiclient") {
-		isOrchidsAIClient = true
+	if _, ok := apiClient.(*orchids.Client); ok {
+		isOrchidsAIClient = h.flags.Enabled(flags.PromptAIClient, flagKey, strings.EqualFold(strings.TrimSpace(h.config.OrchidsImpl), "aiclient"))
 	}
 
 	var aiClientHistory []map[string]string
@@ -479,9 +580,14 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 
 	// 映射模型
 	mappedModel := mapModel(req.Model)
-	if currentAccount != nil && strings.EqualFold(currentAccount.AccountType, "warp") {
+	isWarpAccount := currentAccount != nil && strings.EqualFold(currentAccount.AccountType, "warp")
+	if isWarpAccount {
 		mappedModel = req.Model
 	}
+	if effort := h.reasoningEffortFor(conversationKey, req.ReasoningEffort); effort != "" {
+		mappedModel = h.applyReasoningEffort(mappedModel, effort, isWarpAccount)
+		slog.Debug("Reasoning effort applied", "reasoning_effort", effort, "model", mappedModel)
+	}
 	slog.Info("Model mapping", "original", req.Model, "mapped", mappedModel)
 
 	isStream := req.Stream
@@ -491,6 +597,10 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "text/event-stream")
 		w.Header().Set("Cache-Control", "no-cache")
 		w.Header().Set("Connection", "keep-alive")
+		if conversationKey != "" {
+			w.Header().Set(ConversationTokensHeader, h.conversationUsage(conversationKey).headerValue())
+			w.Header().Set("Trailer", ConversationTokensHeader)
+		}
 		streamingStarted = true
 
 		if _, ok := w.(http.Flusher); !ok {
@@ -519,6 +629,8 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 
 	if gateNoTools {
 		builtPrompt = injectToolGate(builtPrompt, "This is a short, non-code request. Do NOT call tools or perform any file operations. Answer directly.")
+	} else if gate := toolChoiceGate(req.ToolChoice, effectiveTools); gate != "" {
+		builtPrompt = injectToolGate(builtPrompt, gate)
 	}
 
 	// 2. 记录转换后的 prompt
@@ -534,7 +646,16 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 	sh := newStreamHandler(
 		h.config, w, logger, suppressThinking, isStream, responseFormat, effectiveWorkdir,
 	)
+	if reasoningDisplay == config.ReasoningStatus && isStream && !suggestionMode {
+		sh.reasoningStatus = newReasoningStatus(time.Duration(h.config.ReasoningStatusInterval) * time.Second)
+	}
+	if filter := h.outputFilterFor(flagKey); filter != nil {
+		sh.outputFilter = newOutputFilterState(filter)
+		sh.thinkingFilter = sh.outputFilter.fork()
+		defer sh.recordOutputFilter(flagKey)
+	}
 	sh.seedSideEffectDedupFromMessages(upstreamMessages)
+	sh.toolChoice = req.ToolChoice
 	sh.setUsageTokens(inputTokens, -1) // Correctly initialize input tokens
 	// 捕获上游返回的 conversationID，持久化到 session 以便后续请求复用
 	sh.onConversationID = func(id string) {
@@ -549,6 +670,11 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 		slog.Debug("Warp conversationID captured", "key", conversationKey, "id", id)
 	}
 	defer sh.release()
+	defer func() {
+		sh.mu.Lock()
+		accessLog.SetSSEChunks(sh.sseChunks)
+		sh.mu.Unlock()
+	}()
 
 	// 发送 message_start
 	startData, _ := json.Marshal(map[string]interface{}{
@@ -560,37 +686,28 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 			"content": []interface{}{},
 			"model":   req.Model,
 			"usage":   map[string]int{"input_tokens": inputTokens, "output_tokens": 0},
+			// 非标准字段，客户端据此获知（可能由服务端生成的）会话 ID
+			"metadata": map[string]string{"conversation_id": conversationKey},
 		},
 	})
 	sh.writeSSE("message_start", string(startData))
 
 	slog.Debug("New request received")
 
+	// 上游卡顿看门狗
+	r, stopWatchdog := h.startStallWatchdog(r, sh)
+	defer stopWatchdog()
+
 	// KeepAlive
-	var keepAliveStop chan struct{}
 	if isStream {
-		keepAliveStop = make(chan struct{})
-		defer close(keepAliveStop)
-		ticker := time.NewTicker(keepAliveInterval)
-		go func() {
-			defer ticker.Stop()
-			for {
-				select {
-				case <-ticker.C:
-					sh.mu.Lock()
-					done := sh.hasReturn
-					sh.mu.Unlock()
-					if done {
-						return
-					}
-					sh.writeKeepAlive()
-				case <-keepAliveStop:
-					return
-				case <-r.Context().Done():
-					return
-				}
-			}
-		}()
+		stopKeepAlive := h.startKeepAlive(r.Context(), sh)
+		defer stopKeepAlive()
+	}
+
+	// 混沌测试层：仅在 chaos_enabled 时扰动上游消息
+	onUpstreamMessage := sh.handleMessage
+	if chaos := h.newChaos(); chaos != nil {
+		onUpstreamMessage = chaos.Wrap(r.Context(), sh.handleMessage)
 	}
 
 	// Main execution
@@ -625,7 +742,7 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 			Messages:      payloadMessages,
 			System:        payloadSystem,
 			Tools:         effectiveTools,
-			NoTools:       gateNoTools,
+			NoTools:       noTools,
 			NoThinking:    noThinking,
 			ChatSessionID: chatSessionID,
 		}
@@ -652,6 +769,7 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 					}
 				}
 				noopHandler := func(msg upstream.SSEMessage) {
+					sh.touchActivity()
 					if msg.Type == "error" {
 						slog.Warn("Warp intermediate batch error", "event", msg.Event)
 					}
@@ -661,7 +779,7 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 					batchReq.Messages = batch
 					isLast := i == len(warpBatches)-1
 					if isLast {
-						err = sender.SendRequestWithPayload(r.Context(), batchReq, sh.handleMessage, logger)
+						err = sender.SendRequestWithPayload(r.Context(), batchReq, onUpstreamMessage, logger)
 					} else {
 						err = sender.SendRequestWithPayload(r.Context(), batchReq, noopHandler, nil)
 					}
@@ -671,7 +789,7 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 				}
 			} else {
 				slog.Warn("Falling back to legacy SendRequest (Workdir lost!)", "type", fmt.Sprintf("%T", apiClient))
-				err = apiClient.SendRequest(r.Context(), builtPrompt, chatHistory, mappedModel, sh.handleMessage, logger)
+				err = apiClient.SendRequest(r.Context(), builtPrompt, chatHistory, mappedModel, onUpstreamMessage, logger)
 			}
 			slog.Debug("Upstream Client Returned", "error", err)
 
@@ -680,10 +798,25 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 				break
 			}
 
+			if errors.Is(context.Cause(r.Context()), errServerShutdown) {
+				_, retryAfter := h.shuttingDown()
+				sh.InjectShutdownError(retryAfter)
+				sh.finishResponse("end_turn")
+				return
+			}
+			if errors.Is(context.Cause(r.Context()), errUpstreamStalled) {
+				sh.InjectStallError(sh.idleFor())
+				sh.finishResponse("end_turn")
+				return
+			}
+
 			// Check for non-retriable errors
 			errStr := err.Error()
 			errClass := classifyUpstreamError(errStr)
 			slog.Error("Request error", "error", err, "category", errClass.category, "retryable", errClass.retryable)
+			if currentAccount != nil {
+				h.accountStats.RecordError(currentAccount.ID, classifyAccountStatus(errStr))
+			}
 			// 标记账号状态（auth 类错误始终标记，无论是否可重试）
 			if currentAccount != nil && h.loadBalancer != nil && h.loadBalancer.Store != nil {
 				if status := classifyAccountStatus(errStr); status != "" {
@@ -691,7 +824,7 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 					// We want to rotate accounts on 429 even if we retry the request on a new account
 					if !errClass.retryable || errClass.category == "auth" || status == "429" {
 						slog.Info("标记账号状态", "account_id", currentAccount.ID, "status", status, "category", errClass.category)
-						markAccountStatus(r.Context(), h.loadBalancer.Store, currentAccount, status)
+						markAccountStatus(r.Context(), h.loadBalancer.Store, h.events, currentAccount, status)
 					}
 				}
 			}
@@ -722,7 +855,7 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 			retriesRemaining--
-			if errClass.switchAccount && currentAccount != nil && h.loadBalancer != nil {
+			if errClass.switchAccount && currentAccount != nil && h.loadBalancer != nil && h.flags.Enabled(flags.RetryAccountSwitch, flagKey, true) {
 				if _, ok := failedAccountSet[currentAccount.ID]; !ok {
 					failedAccountSet[currentAccount.ID] = struct{}{}
 					failedAccountIDs = append(failedAccountIDs, currentAccount.ID)
@@ -739,8 +872,8 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 				apiClient, currentAccount, retryErr = h.selectAccount(r.Context(), req.Model, forcedChannel, failedAccountIDs)
 				if retryErr == nil {
 					if currentAccount != nil {
-						h.loadBalancer.AcquireConnection(currentAccount.ID)
 						trackedAccountID = currentAccount.ID
+						accessLog.SetAccount(currentAccount.ID)
 						slog.Debug("Switched to account", "account", currentAccount.Name)
 					} else {
 						slog.Debug("Switched to default upstream config")
@@ -770,6 +903,12 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 		sh.finishResponse("end_turn")
 	}
 
+	if conversationKey != "" {
+		usage := h.recordConversationTokens(conversationKey, sh.inputTokens, sh.outputTokens)
+		// 流式响应已声明同名 trailer，此处的值会在响应结束时发出
+		w.Header().Set(ConversationTokensHeader, usage.headerValue())
+	}
+
 	if !isStream {
 		stopReason := sh.finalStopReason
 		if stopReason == "" {
@@ -800,6 +939,12 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 				"text": sh.responseText.String(),
 			})
 		}
+		if h.config.PostTranslation {
+			if lang := h.responseLanguage(r, req); lang != "" && h.translateTextBlocks(r.Context(), apiClient, mappedModel, lang, sh) {
+				w.Header().Set(TranslatedLanguageHeader, lang)
+			}
+		}
+		sh.appendAttributionBlock(stopReason)
 
 		response := map[string]interface{}{
 			"id":            sh.msgID,
@@ -814,6 +959,9 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 				"output_tokens": sh.outputTokens,
 			},
 		}
+		if h.config.AttributionLabel != "" {
+			response["attribution"] = h.config.AttributionLabel
+		}
 
 		if err := json.NewEncoder(w).Encode(response); err != nil {
 			slog.Error("Failed to write JSON response", "error", err)
@@ -824,6 +972,8 @@ func (h *Handler) HandleMessages(w http.ResponseWriter, r *http.Request) {
 	// Sync state and update stats using helpers
 	h.syncWarpState(currentAccount, apiClient, accountSnapshot)
 	h.updateAccountStats(currentAccount, sh.inputTokens, sh.outputTokens)
+	accessLog.SetTokens(sh.inputTokens, sh.outputTokens)
+	h.publishUsage(r, req, sh, currentAccount, forcedChannel, mappedModel, time.Since(startTime))
 }
 
 func randomSessionID() string {
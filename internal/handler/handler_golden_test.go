@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/prompt"
+	"orchids-api/internal/testing/golden"
+)
+
+var goldenMsgIDPattern = regexp.MustCompile(`msg_\d+`)
+
+// 回放 testdata/golden/*.jsonl 中的上游 transcript，并与对应的 .sse / .json golden 输出比较。
+// 修改协议转换逻辑后使用 UPDATE_GOLDEN=1 go test ./internal/handler -run Golden 重新生成。
+func TestGoldenTranscripts(t *testing.T) {
+	transcripts, err := filepath.Glob(filepath.Join("testdata", "golden", "*.jsonl"))
+	if err != nil {
+		t.Fatalf("glob transcripts: %v", err)
+	}
+	if len(transcripts) == 0 {
+		t.Fatalf("no golden transcripts found")
+	}
+
+	for _, path := range transcripts {
+		base := strings.TrimSuffix(path, ".jsonl")
+		for _, stream := range []bool{true, false} {
+			name := filepath.Base(base) + "/nonstream"
+			goldenPath := base + ".json"
+			if stream {
+				name = filepath.Base(base) + "/stream"
+				goldenPath = base + ".sse"
+			}
+			t.Run(name, func(t *testing.T) {
+				client, err := golden.LoadReplayClient(path)
+				if err != nil {
+					t.Fatalf("load transcript: %v", err)
+				}
+				h := &Handler{
+					config:            &config.Config{},
+					client:            client,
+					sessionWorkdirs:   map[string]string{},
+					sessionConvIDs:    map[string]string{},
+					sessionLastAccess: map[string]time.Time{},
+					recentRequests:    map[string]*recentRequest{},
+				}
+				body, err := json.Marshal(ClaudeRequest{
+					Model:    "claude-sonnet-4-5",
+					Messages: []prompt.Message{{Role: "user", Content: prompt.MessageContent{Text: "golden replay"}}},
+					Stream:   stream,
+				})
+				if err != nil {
+					t.Fatalf("marshal request: %v", err)
+				}
+				req := httptest.NewRequest(http.MethodPost, "/orchids/v1/messages", bytes.NewReader(body))
+				rec := httptest.NewRecorder()
+				h.HandleMessages(rec, req)
+				if rec.Code != http.StatusOK {
+					t.Fatalf("status = %d, body = %s", rec.Code, rec.Body.String())
+				}
+				got := goldenMsgIDPattern.ReplaceAll(rec.Body.Bytes(), []byte("msg_GOLDEN"))
+				golden.Assert(t, goldenPath, got)
+			})
+		}
+	}
+}
@@ -9,8 +9,11 @@ import (
 	"strings"
 	"time"
 
+	"orchids-api/internal/debug"
+	"orchids-api/internal/middleware"
 	"orchids-api/internal/orchids"
 	"orchids-api/internal/store"
+	"orchids-api/internal/upstream"
 	"orchids-api/internal/warp"
 )
 
@@ -104,8 +107,32 @@ func (h *Handler) selectAccount(ctx context.Context, model, forcedChannel string
 	return nil, nil, errors.New("no client configured")
 }
 
+// DebugCaptureHeader 设为 1 且请求携带管理员凭据时，即使全局 debug_enabled 关闭也完整捕获该请求
+const DebugCaptureHeader = "X-Debug-Capture"
+
+// newDebugLogger 按全局配置或请求级捕获开关创建调试日志记录器
+func (h *Handler) newDebugLogger(r *http.Request) *debug.Logger {
+	traceID := middleware.GetTraceID(r.Context())
+	if r.Header.Get(DebugCaptureHeader) == "1" {
+		if middleware.IsAdminRequest(r, h.config.AdminPass, h.config.AdminToken) {
+			slog.Info("Debug capture enabled for request", "trace_id", traceID)
+			logger := debug.NewCapture(traceID)
+			logger.SetPath(r.URL.Path)
+			return logger
+		}
+		slog.Warn("Ignoring debug capture header without admin credentials", "trace_id", traceID)
+	}
+	logger := debug.NewWithTrace(h.config.DebugEnabled, h.config.DebugLogSSE, traceID)
+	logger.SetPath(r.URL.Path)
+	return logger
+}
+
 func (h *Handler) updateAccountStats(account *store.Account, inputTokens, outputTokens int) {
-	if account == nil || h.loadBalancer == nil {
+	if account == nil {
+		return
+	}
+	h.accountStats.RecordSuccess(account.ID, inputTokens+outputTokens)
+	if h.loadBalancer == nil {
 		return
 	}
 	go func(accountID int64, inputTokens, outputTokens int) {
@@ -155,17 +182,25 @@ const (
 // Must be called with sessionWorkdirsMu held for writing.
 func (h *Handler) cleanupSessionWorkdirsLocked() {
 	now := time.Now()
-	if len(h.sessionWorkdirs) < sessionMaxSize && now.Sub(h.sessionCleanupRun) < sessionCleanupInterval {
+	if len(h.sessionWorkdirs) < sessionMaxSize && len(h.sessionTokens) < sessionMaxSize && now.Sub(h.sessionCleanupRun) < sessionCleanupInterval {
 		return
 	}
+	var orphans []string
 	for key, lastAccess := range h.sessionLastAccess {
 		if now.Sub(lastAccess) > sessionMaxAge {
 			delete(h.sessionWorkdirs, key)
 			delete(h.sessionConvIDs, key)
 			delete(h.sessionLastAccess, key)
+			delete(h.sessionTokens, key)
+			delete(h.sessionEfforts, key)
+			delete(h.sessionHistory, key)
+			orphans = append(orphans, h.expireArtifactsLocked(key)...)
 		}
 	}
 	h.sessionCleanupRun = now
+	if len(orphans) > 0 {
+		go h.removeOrphanArtifacts(orphans)
+	}
 }
 
 type upstreamErrorClass struct {
@@ -228,3 +263,22 @@ func computeRetryDelay(base time.Duration, attempt int, category string) time.Du
 	}
 	return delay
 }
+
+// newChaos 根据配置为单个请求创建混沌扰动层，未启用时返回 nil
+func (h *Handler) newChaos() *upstream.Chaos {
+	if h.config == nil || !h.config.ChaosEnabled {
+		return nil
+	}
+	cfg := upstream.ChaosConfig{
+		Seed:         h.config.ChaosSeed,
+		DelayRate:    h.config.ChaosDelayRate,
+		MaxDelay:     time.Duration(h.config.ChaosMaxDelayMs) * time.Millisecond,
+		DropRate:     h.config.ChaosDropRate,
+		TruncateRate: h.config.ChaosTruncateRate,
+		CorruptRate:  h.config.ChaosCorruptRate,
+	}
+	if !cfg.Active() {
+		return nil
+	}
+	return upstream.NewChaos(cfg)
+}
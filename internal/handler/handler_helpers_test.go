@@ -5,6 +5,8 @@ import (
 	"net/http/httptest"
 	"testing"
 	"time"
+
+	"orchids-api/internal/config"
 )
 
 func TestResolveWorkdir_NoSessionFallbackWithoutExplicitConversation(t *testing.T) {
@@ -27,3 +29,22 @@ func TestResolveWorkdir_NoSessionFallbackWithoutExplicitConversation(t *testing.
 		t.Fatalf("expected changed=false when no new workdir")
 	}
 }
+
+func TestNewDebugLogger_CaptureHeaderRequiresAdmin(t *testing.T) {
+	t.Chdir(t.TempDir())
+	h := &Handler{config: &config.Config{AdminToken: "admin-secret"}}
+
+	r := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	r.Header.Set(DebugCaptureHeader, "1")
+	r.Header.Set("Authorization", "Bearer sk-client")
+	if dir := h.newDebugLogger(r).Dir(); dir != "" {
+		t.Fatalf("capture enabled without admin credentials: %q", dir)
+	}
+
+	r.Header.Set("X-Admin-Token", "admin-secret")
+	logger := h.newDebugLogger(r)
+	defer logger.Close()
+	if logger.Dir() == "" || !logger.SSEEnabled() {
+		t.Fatalf("expected full capture for admin request")
+	}
+}
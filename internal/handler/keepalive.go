@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"context"
+	"time"
+)
+
+const defaultKeepAliveInterval = 15 * time.Second
+
+const (
+	// keepAliveComment 为 SSE 注释帧，所有客户端都会忽略
+	keepAliveComment = ": ping\n\n"
+	// keepAliveEvent 为 Anthropic 风格的 ping 事件，仅用于 Anthropic 格式响应
+	keepAliveEvent = "event: ping\ndata: {\"type\":\"ping\"}\n\n"
+)
+
+// keepAliveInterval 返回心跳间隔：未配置时使用默认值，负数表示关闭
+func (h *Handler) keepAliveInterval() time.Duration {
+	if h.config == nil || h.config.KeepAliveInterval == 0 {
+		return defaultKeepAliveInterval
+	}
+	if h.config.KeepAliveInterval < 0 {
+		return 0
+	}
+	return time.Duration(h.config.KeepAliveInterval) * time.Second
+}
+
+// startKeepAlive 周期性向流式响应写入心跳，防止代理/负载均衡因空闲断开长时间的工具调用。
+// 响应结束、ctx 取消或调用返回的 stop 函数后退出。
+func (h *Handler) startKeepAlive(ctx context.Context, sh *streamHandler) func() {
+	interval := h.keepAliveInterval()
+	if interval <= 0 || !sh.isStream {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				sh.mu.Lock()
+				done := sh.hasReturn
+				sh.mu.Unlock()
+				if done {
+					return
+				}
+				sh.writeKeepAlive()
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() { close(stop) }
+}
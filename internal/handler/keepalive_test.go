@@ -0,0 +1,102 @@
+package handler
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"orchids-api/internal/adapter"
+	"orchids-api/internal/config"
+)
+
+type flushCountingRecorder struct {
+	*httptest.ResponseRecorder
+	mu      sync.Mutex
+	flushes int
+}
+
+func (f *flushCountingRecorder) Flush() {
+	f.mu.Lock()
+	f.flushes++
+	f.mu.Unlock()
+	f.ResponseRecorder.Flush()
+}
+
+func (f *flushCountingRecorder) flushCount() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.flushes
+}
+
+func TestWriteKeepAlive_FramesAndFlush(t *testing.T) {
+	cases := []struct {
+		name   string
+		mode   string
+		format adapter.ResponseFormat
+		want   string
+	}{
+		{name: "comment", mode: "comment", format: adapter.FormatAnthropic, want: keepAliveComment},
+		{name: "event", mode: "event", format: adapter.FormatAnthropic, want: keepAliveEvent},
+		{name: "openai always comment", mode: "event", format: adapter.FormatOpenAI, want: keepAliveComment},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := &flushCountingRecorder{ResponseRecorder: httptest.NewRecorder()}
+			sh := newStreamHandler(&config.Config{KeepAliveMode: tc.mode}, rec, nil, false, true, tc.format, "")
+			defer sh.release()
+
+			sh.writeKeepAlive()
+			if got := rec.Body.String(); got != tc.want {
+				t.Fatalf("keep-alive frame = %q, want %q", got, tc.want)
+			}
+			if rec.flushCount() != 1 {
+				t.Fatalf("flushes = %d, want 1", rec.flushCount())
+			}
+		})
+	}
+}
+
+func TestStartKeepAlive_StopsAfterFinish(t *testing.T) {
+	rec := &flushCountingRecorder{ResponseRecorder: httptest.NewRecorder()}
+	cfg := &config.Config{KeepAliveInterval: 1}
+	h := &Handler{config: cfg}
+	sh := newStreamHandler(cfg, rec, nil, false, true, adapter.FormatAnthropic, "")
+	defer sh.release()
+
+	stop := h.startKeepAlive(context.Background(), sh)
+	defer stop()
+
+	deadline := time.Now().Add(3 * time.Second)
+	for rec.flushCount() == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if rec.flushCount() == 0 {
+		t.Fatalf("expected keep-alive to be flushed")
+	}
+
+	sh.mu.Lock()
+	sh.hasReturn = true
+	body := rec.Body.String()
+	sh.mu.Unlock()
+	if !strings.Contains(body, ": ping") {
+		t.Fatalf("expected ping comment, got %q", body)
+	}
+
+	flushed := rec.flushCount()
+	time.Sleep(1500 * time.Millisecond)
+	if rec.flushCount() != flushed {
+		t.Fatalf("keep-alive kept writing after response finished")
+	}
+}
+
+func TestKeepAliveInterval_Defaults(t *testing.T) {
+	if got := (&Handler{config: &config.Config{}}).keepAliveInterval(); got != defaultKeepAliveInterval {
+		t.Fatalf("default interval = %v", got)
+	}
+	if got := (&Handler{config: &config.Config{KeepAliveInterval: -1}}).keepAliveInterval(); got != 0 {
+		t.Fatalf("disabled interval = %v", got)
+	}
+}
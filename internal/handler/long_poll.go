@@ -0,0 +1,288 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"orchids-api/internal/middleware"
+)
+
+const (
+	pollDefaultWait = 25 * time.Second
+	pollMaxWait     = 55 * time.Second
+	// pollIdleTimeout 内没有任何轮询时取消请求，避免客户端离开后继续消耗账号
+	pollIdleTimeout = 2 * time.Minute
+	// pollRetention 为请求结束后事件的保留时间，供客户端取回最后一批
+	pollRetention = 5 * time.Minute
+)
+
+// pollStream 为一个长轮询请求的事件缓冲
+type pollStream struct {
+	keyHash string
+	cancel  context.CancelFunc
+
+	mu         sync.Mutex
+	events     []json.RawMessage
+	done       bool
+	notify     chan struct{} // 有新事件或结束时关闭并替换
+	lastPoll   time.Time
+	finishedAt time.Time
+}
+
+func (s *pollStream) append(data []byte) error {
+	s.mu.Lock()
+	s.events = append(s.events, json.RawMessage(append([]byte(nil), data...)))
+	close(s.notify)
+	s.notify = make(chan struct{})
+	s.mu.Unlock()
+	return nil
+}
+
+func (s *pollStream) finish() {
+	s.mu.Lock()
+	s.done = true
+	s.finishedAt = time.Now()
+	close(s.notify)
+	s.notify = make(chan struct{})
+	s.mu.Unlock()
+}
+
+// since 返回 cursor 之后的事件；没有新事件且未结束时返回等待用的 channel
+func (s *pollStream) since(cursor int) ([]json.RawMessage, bool, <-chan struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastPoll = time.Now()
+	if cursor < len(s.events) {
+		return append([]json.RawMessage(nil), s.events[cursor:]...), s.done, nil
+	}
+	if s.done {
+		return nil, true, nil
+	}
+	return nil, false, s.notify
+}
+
+func newStreamToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// cleanupStreamsLocked 取消长时间未被轮询的请求，并删除已结束且超过保留时间的事件
+func (h *Handler) cleanupStreamsLocked(now time.Time) {
+	for token, s := range h.streams {
+		s.mu.Lock()
+		idle := !s.done && now.Sub(s.lastPoll) > pollIdleTimeout
+		expired := s.done && now.Sub(s.finishedAt) > pollRetention
+		s.mu.Unlock()
+		if idle {
+			s.cancel()
+		}
+		if idle || expired {
+			delete(h.streams, token)
+		}
+	}
+}
+
+// liveStreamsLocked 返回未结束的长轮询请求总数，以及其中属于 keyHash 的数量
+func (h *Handler) liveStreamsLocked(keyHash string) (total, perKey int) {
+	for _, s := range h.streams {
+		s.mu.Lock()
+		done := s.done
+		s.mu.Unlock()
+		if done {
+			continue
+		}
+		total++
+		if s.keyHash == keyHash {
+			perKey++
+		}
+	}
+	return total, perKey
+}
+
+// streamLimitLocked 检查 long_poll_max_streams / long_poll_max_streams_per_key，
+// 超限时返回错误类型与状态码，未超限时 code 为 0。配置为 0 或负数时不限制
+func (h *Handler) streamLimitLocked(keyHash string) (errType string, code int) {
+	if h.config == nil || (h.config.LongPollMaxStreams <= 0 && h.config.LongPollMaxStreamsPerKey <= 0) {
+		return "", 0
+	}
+	total, perKey := h.liveStreamsLocked(keyHash)
+	if limit := h.config.LongPollMaxStreamsPerKey; limit > 0 && perKey >= limit {
+		return "rate_limit_error", http.StatusTooManyRequests
+	}
+	if limit := h.config.LongPollMaxStreams; limit > 0 && total >= limit {
+		return "overloaded_error", middleware.StatusOverloaded
+	}
+	return "", 0
+}
+
+// LongPollStart 返回 /{orchids,warp}/v1/streams 的处理函数：请求体与 /v1/messages 相同，
+// 立即返回 stream_token，请求在后台经 next（同一限制器与处理流程）执行，客户端通过
+// events_url（/v1/streams/{token}/events，配置 base_path 时带前缀）分批取回事件。用于同时屏蔽 SSE 与 WebSocket 的网络环境。
+// 事件只保存在本进程内，token 只能在创建它的副本上使用
+func (h *Handler) LongPollStart(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			h.writeErrorResponse(w, "invalid_request_error", "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if limit := h.maxRequestBytes(); limit > 0 {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				h.writeRequestTooLarge(w)
+				return
+			}
+			h.writeErrorResponse(w, "invalid_request_error", "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		body, err := forceStream(data)
+		if err != nil {
+			h.writeErrorResponse(w, "invalid_request_error", "Invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		// 后台请求不随本次 HTTP 请求结束而取消，保留 trace ID 等 context 值；
+		// 访问日志字段在 202 返回后即被读取，后台请求不再写入
+		ctx, cancel := context.WithCancel(middleware.WithoutAccessLog(context.WithoutCancel(r.Context())))
+		now := time.Now()
+		stream := &pollStream{
+			keyHash:  middleware.HashAPIKey(middleware.APIKeyFromRequest(r)),
+			cancel:   cancel,
+			notify:   make(chan struct{}),
+			lastPoll: now,
+		}
+		token := newStreamToken()
+		h.streamsMu.Lock()
+		if h.streams == nil {
+			h.streams = make(map[string]*pollStream)
+		}
+		h.cleanupStreamsLocked(now)
+		if errType, code := h.streamLimitLocked(stream.keyHash); code != 0 {
+			h.streamsMu.Unlock()
+			cancel()
+			w.Header().Set("Retry-After", "5")
+			h.writeErrorResponse(w, errType, "Too many pending long-poll streams, poll or cancel existing streams and retry", code)
+			return
+		}
+		h.streams[token] = stream
+		h.streamsMu.Unlock()
+
+		req := derivedMessageRequest(ctx, r, strings.TrimSuffix(r.URL.Path, "/streams")+"/messages", body)
+		go func() {
+			defer cancel()
+			pw := newSSEFrameWriter(stream.append)
+			next(pw, req)
+			pw.finish()
+			stream.finish()
+		}()
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{
+			"stream_token": token,
+			"events_url":   middleware.BasePathFrom(r) + "/v1/streams/" + token + "/events",
+		})
+	}
+}
+
+// HandleStreamEvents 处理 /v1/streams/{token}/events（GET，?cursor=&wait=）与 /v1/streams/{token}（DELETE 取消）。
+// 没有新事件时最多等待 wait 秒（默认 25，上限 55）；只有发起请求的同一 API Key 可以访问
+func (h *Handler) HandleStreamEvents(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	idx := strings.Index(path, "/streams/")
+	if idx < 0 {
+		http.NotFound(w, r)
+		return
+	}
+	rest := path[idx+len("/streams/"):]
+	token, suffix, _ := strings.Cut(rest, "/")
+
+	h.streamsMu.Lock()
+	h.cleanupStreamsLocked(time.Now())
+	stream, ok := h.streams[token]
+	if ok && stream.keyHash != middleware.HashAPIKey(middleware.APIKeyFromRequest(r)) {
+		ok = false
+	}
+	if ok && r.Method == http.MethodDelete && suffix == "" {
+		delete(h.streams, token)
+	}
+	h.streamsMu.Unlock()
+	if !ok {
+		h.writeErrorResponse(w, "not_found_error", "Stream not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodDelete && suffix == "":
+		stream.cancel()
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case r.Method == http.MethodGet && suffix == "events":
+	default:
+		h.writeErrorResponse(w, "invalid_request_error", "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	cursor, _ := strconv.Atoi(r.URL.Query().Get("cursor"))
+	if cursor < 0 {
+		cursor = 0
+	}
+	wait := pollDefaultWait
+	if v := r.URL.Query().Get("wait"); v != "" {
+		if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+			wait = min(time.Duration(secs)*time.Second, pollMaxWait)
+		}
+	}
+
+	events, done, notify := stream.since(cursor)
+	if notify != nil && wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-notify:
+		case <-timer.C:
+		case <-r.Context().Done():
+		}
+		timer.Stop()
+		events, done, _ = stream.since(cursor)
+	}
+	if events == nil {
+		events = []json.RawMessage{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events":      events,
+		"next_cursor": cursor + len(events),
+		"done":        done,
+	})
+}
+
+// derivedMessageRequest 基于客户端原始请求构造交给 HandleMessages 的 POST 请求，保留鉴权与会话相关的请求头
+func derivedMessageRequest(ctx context.Context, r *http.Request, path string, body []byte) *http.Request {
+	req := r.Clone(ctx)
+	req.Method = http.MethodPost
+	req.URL.Path = path
+	req.URL.RawQuery = ""
+	req.RequestURI = ""
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.ContentLength = int64(len(body))
+	for _, name := range []string{"Connection", "Upgrade", "Sec-Websocket-Key", "Sec-Websocket-Version", "Sec-Websocket-Extensions", "Sec-Websocket-Protocol"} {
+		req.Header.Del(name)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return req
+}
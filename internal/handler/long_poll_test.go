@@ -0,0 +1,199 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/middleware"
+)
+
+func TestLongPoll_DeliversEventsInBatches(t *testing.T) {
+	h := &Handler{config: &config.Config{}}
+	release := make(chan struct{})
+	next := func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/orchids/v1/messages" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: message_start\ndata: {\"type\":\"message_start\"}\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+		fmt.Fprint(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+		w.(http.Flusher).Flush()
+	}
+
+	start := httptest.NewRequest(http.MethodPost, "/orchids/v1/streams", strings.NewReader(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`))
+	start.Header.Set("x-api-key", "sk-test")
+	rec := httptest.NewRecorder()
+	h.LongPollStart(next)(rec, start)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("start status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		StreamToken string `json:"stream_token"`
+		EventsURL   string `json:"events_url"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+
+	type batch struct {
+		Events     []map[string]interface{} `json:"events"`
+		NextCursor int                      `json:"next_cursor"`
+		Done       bool                     `json:"done"`
+	}
+	poll := func(cursor int, key string) (int, batch) {
+		req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("%s?cursor=%d&wait=5", created.EventsURL, cursor), nil)
+		req.Header.Set("x-api-key", key)
+		rec := httptest.NewRecorder()
+		h.HandleStreamEvents(rec, req)
+		var b batch
+		json.Unmarshal(rec.Body.Bytes(), &b)
+		return rec.Code, b
+	}
+
+	if code, _ := poll(0, "sk-other"); code != http.StatusNotFound {
+		t.Fatalf("other key status = %d, want 404", code)
+	}
+	_, first := poll(0, "sk-test")
+	if len(first.Events) != 1 || first.Events[0]["type"] != "message_start" || first.Done {
+		t.Fatalf("first batch = %+v", first)
+	}
+	close(release)
+	var rest []map[string]interface{}
+	cursor, done := first.NextCursor, false
+	for !done {
+		_, b := poll(cursor, "sk-test")
+		rest = append(rest, b.Events...)
+		cursor, done = b.NextCursor, b.Done
+	}
+	if len(rest) != 1 || rest[0]["type"] != "message_stop" || cursor != 2 {
+		t.Fatalf("remaining events = %v, cursor = %d", rest, cursor)
+	}
+}
+
+func TestLongPoll_BackgroundRequestDetachedFromAccessLog(t *testing.T) {
+	h := &Handler{config: &config.Config{}}
+	done := make(chan *middleware.AccessLogFields, 1)
+	next := func(w http.ResponseWriter, r *http.Request) {
+		fields := middleware.AccessLogFieldsFrom(r.Context())
+		fields.SetModel("m")
+		fields.SetTokens(1, 2)
+		done <- fields
+	}
+	var logged *middleware.AccessLogFields
+	probe := func(w http.ResponseWriter, r *http.Request) {
+		logged = middleware.AccessLogFieldsFrom(r.Context())
+		h.LongPollStart(next)(w, r)
+	}
+	srv := middleware.AccessLog(middleware.AccessLogOptions{SuccessSampleRate: 1})(http.HandlerFunc(probe))
+
+	start := httptest.NewRequest(http.MethodPost, "/orchids/v1/streams", strings.NewReader(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`))
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, start)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("start status = %d", rec.Code)
+	}
+	if bg := <-done; bg != nil {
+		t.Fatalf("background request shares access log fields")
+	}
+	if logged == nil || logged.Model != "" {
+		t.Fatalf("request access log fields = %+v", logged)
+	}
+}
+
+func TestLongPoll_EventsURLUnderBasePath(t *testing.T) {
+	h := &Handler{config: &config.Config{}}
+	next := func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/orchids/v1/streams", h.LongPollStart(next))
+	mux.HandleFunc("/v1/streams/", h.HandleStreamEvents)
+	srv := middleware.BasePath("/ai-gateway")(mux)
+
+	start := httptest.NewRequest(http.MethodPost, "/ai-gateway/orchids/v1/streams", strings.NewReader(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`))
+	start.Header.Set("x-api-key", "sk-test")
+	rec := httptest.NewRecorder()
+	srv.ServeHTTP(rec, start)
+	if rec.Code != http.StatusAccepted {
+		t.Fatalf("start status = %d: %s", rec.Code, rec.Body.String())
+	}
+	var created struct {
+		StreamToken string `json:"stream_token"`
+		EventsURL   string `json:"events_url"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &created)
+	if want := "/ai-gateway/v1/streams/" + created.StreamToken + "/events"; created.EventsURL != want {
+		t.Fatalf("events_url = %q, want %q", created.EventsURL, want)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, created.EventsURL+"?cursor=0&wait=5", nil)
+	req.Header.Set("x-api-key", "sk-test")
+	rec = httptest.NewRecorder()
+	srv.ServeHTTP(rec, req)
+	var b struct {
+		Events []map[string]interface{} `json:"events"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &b)
+	if rec.Code != http.StatusOK || len(b.Events) != 1 || b.Events[0]["type"] != "message_stop" {
+		t.Fatalf("poll via events_url: status = %d body = %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestLongPoll_LimitsLiveStreams(t *testing.T) {
+	h := &Handler{config: &config.Config{LongPollMaxStreams: 3, LongPollMaxStreamsPerKey: 2}}
+	release := make(chan struct{})
+	defer close(release)
+	next := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}
+	start := func(key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/orchids/v1/streams", strings.NewReader(`{"model":"m","messages":[{"role":"user","content":"hi"}]}`))
+		req.Header.Set("x-api-key", key)
+		rec := httptest.NewRecorder()
+		h.LongPollStart(next)(rec, req)
+		return rec
+	}
+
+	var tokens []string
+	for i := 0; i < 2; i++ {
+		rec := start("sk-a")
+		if rec.Code != http.StatusAccepted {
+			t.Fatalf("stream %d: status = %d", i, rec.Code)
+		}
+		var created struct {
+			StreamToken string `json:"stream_token"`
+		}
+		json.Unmarshal(rec.Body.Bytes(), &created)
+		tokens = append(tokens, created.StreamToken)
+	}
+	if rec := start("sk-a"); rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("per-key limit: status = %d, Retry-After = %q", rec.Code, rec.Header().Get("Retry-After"))
+	}
+	if rec := start("sk-b"); rec.Code != http.StatusAccepted {
+		t.Fatalf("other key: status = %d", rec.Code)
+	}
+	if rec := start("sk-c"); rec.Code != middleware.StatusOverloaded {
+		t.Fatalf("global limit: status = %d, want 529", rec.Code)
+	}
+
+	// 取消后释放名额
+	del := httptest.NewRequest(http.MethodDelete, "/v1/streams/"+tokens[0], nil)
+	del.Header.Set("x-api-key", "sk-a")
+	rec := httptest.NewRecorder()
+	h.HandleStreamEvents(rec, del)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("cancel: status = %d", rec.Code)
+	}
+	if rec := start("sk-a"); rec.Code != http.StatusAccepted {
+		t.Fatalf("after cancel: status = %d", rec.Code)
+	}
+}
@@ -0,0 +1,259 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"orchids-api/internal/middleware"
+)
+
+const (
+	wsWriteWait    = 10 * time.Second
+	wsPongWait     = 60 * time.Second
+	wsPingInterval = 25 * time.Second
+	wsMaxQueued    = 8
+)
+
+// 浏览器的 WebSocket 握手不受 CORS 约束，跨站页面可以直接建立连接并读取响应，
+// CheckOrigin 在 MessagesWebSocket 中设置为 checkWebSocketOrigin
+var wsUpgrader = websocket.Upgrader{
+	ReadBufferSize:  32 * 1024,
+	WriteBufferSize: 32 * 1024,
+}
+
+// checkWebSocketOrigin 允许没有 Origin 的非浏览器客户端、与服务同源的页面，
+// 以及 cors 配置中 api 分组白名单内的 Origin；api 分组未配置时拒绝其余跨站请求
+func (h *Handler) checkWebSocketOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	policy, ok := h.config.CORS[middleware.CORSGroupAPI]
+	if !ok || !middleware.CORSPolicy(policy).AllowsOrigin(origin) {
+		slog.Warn("WebSocket upgrade rejected: origin not allowed", "origin", origin)
+		return false
+	}
+	return true
+}
+
+// MessagesWebSocket 返回 /{orchids,warp}/v1/messages/ws 的处理函数。
+// 客户端每发送一个文本帧即一个 ClaudeRequest（强制流式），按顺序交给 next 处理——
+// 与 HTTP 接口相同的并发限制与 HandleMessages 流程——并将 SSE 事件的 data 逐条作为 JSON 文本帧返回
+func (h *Handler) MessagesWebSocket(next http.HandlerFunc) http.HandlerFunc {
+	upgrader := wsUpgrader
+	upgrader.CheckOrigin = h.checkWebSocketOrigin
+	return func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			slog.Warn("WebSocket upgrade failed", "error", err)
+			return
+		}
+		defer conn.Close()
+
+		if limit := h.maxRequestBytes(); limit > 0 {
+			conn.SetReadLimit(limit)
+		}
+
+		ctx, cancel := context.WithCancel(r.Context())
+		defer cancel()
+
+		// 读协程负责控制帧与断线检测；连接关闭时取消正在处理的请求
+		frames := make(chan []byte, wsMaxQueued)
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		conn.SetPongHandler(func(string) error {
+			return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		})
+		go func() {
+			defer cancel()
+			defer close(frames)
+			for {
+				msgType, data, err := conn.ReadMessage()
+				if err != nil {
+					return
+				}
+				if msgType != websocket.TextMessage {
+					continue
+				}
+				select {
+				case frames <- data:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		go func() {
+			ticker := time.NewTicker(wsPingInterval)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait)); err != nil {
+						cancel()
+						return
+					}
+				}
+			}
+		}()
+
+		path := strings.TrimSuffix(r.URL.Path, "/ws")
+		for data := range frames {
+			body, err := forceStream(data)
+			if err != nil {
+				writeWSFrame(conn, wsErrorFrame("invalid_request_error", "Invalid request body"))
+				continue
+			}
+			req := derivedMessageRequest(ctx, r, path, body)
+
+			ew := newSSEFrameWriter(func(data []byte) error { return writeWSFrame(conn, data) })
+			next(ew, req)
+			ew.finish()
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}
+}
+
+// forceStream 将请求的 stream 字段置为 true，其余字段原样保留
+func forceStream(data []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	fields["stream"] = json.RawMessage("true")
+	return json.Marshal(fields)
+}
+
+func wsErrorFrame(errType, message string) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type": "error",
+		"error": map[string]string{
+			"type":    errType,
+			"message": message,
+		},
+	})
+	return data
+}
+
+func writeWSFrame(conn *websocket.Conn, data []byte) error {
+	conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// sseFrameWriter 作为 HandleMessages 的 ResponseWriter：缓冲 SSE 输出，每次 Flush 时把完整事件的 data
+// 交给 emit（WebSocket 帧或长轮询事件）；非 SSE 响应（错误等）在结束时整体交给 emit
+type sseFrameWriter struct {
+	mu     sync.Mutex
+	emit   func(data []byte) error
+	header http.Header
+	status int
+	buf    bytes.Buffer
+	failed bool
+}
+
+func newSSEFrameWriter(emit func(data []byte) error) *sseFrameWriter {
+	return &sseFrameWriter{emit: emit, header: http.Header{}}
+}
+
+func (w *sseFrameWriter) Header() http.Header { return w.header }
+
+func (w *sseFrameWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *sseFrameWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.buf.Write(b)
+}
+
+func (w *sseFrameWriter) isSSE() bool {
+	return strings.HasPrefix(w.header.Get("Content-Type"), "text/event-stream")
+}
+
+func (w *sseFrameWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.flushLocked()
+}
+
+func (w *sseFrameWriter) flushLocked() {
+	if !w.isSSE() {
+		return
+	}
+	for {
+		data := w.buf.Bytes()
+		end := bytes.Index(data, []byte("\n\n"))
+		if end < 0 {
+			return
+		}
+		event := append([]byte(nil), data[:end]...)
+		w.buf.Next(end + 2)
+		w.sendEvent(event)
+	}
+}
+
+// sendEvent 提取一个 SSE 事件的 data 行；注释行（keep-alive）没有 data，直接丢弃
+func (w *sseFrameWriter) sendEvent(event []byte) {
+	var payload [][]byte
+	for _, line := range bytes.Split(event, []byte("\n")) {
+		if rest, ok := bytes.CutPrefix(line, []byte("data:")); ok {
+			payload = append(payload, bytes.TrimPrefix(rest, []byte(" ")))
+		}
+	}
+	if len(payload) == 0 {
+		return
+	}
+	w.send(bytes.Join(payload, []byte("\n")))
+}
+
+func (w *sseFrameWriter) send(data []byte) {
+	if w.failed {
+		return
+	}
+	if err := w.emit(data); err != nil {
+		w.failed = true
+		slog.Debug("Event delivery failed", "error", err)
+	}
+}
+
+// finish 发送剩余内容，应在 HandleMessages 返回后调用
+func (w *sseFrameWriter) finish() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.isSSE() {
+		w.flushLocked()
+		if rest := bytes.TrimSpace(w.buf.Bytes()); len(rest) > 0 {
+			w.sendEvent(rest)
+		}
+		return
+	}
+	body := bytes.TrimSpace(w.buf.Bytes())
+	if len(body) == 0 {
+		return
+	}
+	if w.status >= http.StatusBadRequest && !json.Valid(body) {
+		// 并发限制等返回纯文本错误，包装为与 SSE error 事件相同的结构
+		body = wsErrorFrame("api_error", string(body))
+	}
+	w.send(body)
+}
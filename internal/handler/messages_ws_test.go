@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"orchids-api/internal/config"
+)
+
+func TestMessagesWebSocket_StreamsEventsAsFrames(t *testing.T) {
+	h := &Handler{config: &config.Config{}}
+	var gotPath string
+	var gotStream bool
+	next := func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		var req ClaudeRequest
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &req)
+		gotStream = req.Stream
+		if req.Model == "busy" {
+			http.Error(w, "server busy", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, ": keep-alive\n\n")
+		fmt.Fprint(w, "event: message_start\ndata: {\"type\":\"message_start\"}\n\n")
+		w.(http.Flusher).Flush()
+		fmt.Fprint(w, "event: message_stop\ndata: {\"type\":\"message_stop\"}\n\n")
+		w.(http.Flusher).Flush()
+	}
+	srv := httptest.NewServer(h.MessagesWebSocket(next))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/orchids/v1/messages/ws", nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	read := func() map[string]interface{} {
+		t.Helper()
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		var frame map[string]interface{}
+		if err := json.Unmarshal(data, &frame); err != nil {
+			t.Fatalf("frame is not JSON: %s", data)
+		}
+		return frame
+	}
+
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"model":"claude-sonnet-4-5","messages":[{"role":"user","content":"hi"}]}`))
+	if frame := read(); frame["type"] != "message_start" {
+		t.Fatalf("first frame = %v", frame)
+	}
+	if frame := read(); frame["type"] != "message_stop" {
+		t.Fatalf("second frame = %v", frame)
+	}
+	if gotPath != "/orchids/v1/messages" || !gotStream {
+		t.Fatalf("next got path=%q stream=%v", gotPath, gotStream)
+	}
+
+	// 同一连接上的后续请求；纯文本错误包装为 error 帧
+	conn.WriteMessage(websocket.TextMessage, []byte(`{"model":"busy","messages":[{"role":"user","content":"hi"}]}`))
+	frame := read()
+	errObj, _ := frame["error"].(map[string]interface{})
+	if frame["type"] != "error" || !strings.Contains(fmt.Sprint(errObj["message"]), "server busy") {
+		t.Fatalf("error frame = %v", frame)
+	}
+}
+
+func TestMessagesWebSocket_RejectsCrossSiteOrigin(t *testing.T) {
+	h := &Handler{config: &config.Config{CORS: map[string]config.CORSPolicy{
+		"api": {AllowedOrigins: []string{"https://app.example.com"}},
+	}}}
+	next := func(w http.ResponseWriter, r *http.Request) {}
+	srv := httptest.NewServer(h.MessagesWebSocket(next))
+	defer srv.Close()
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + "/orchids/v1/messages/ws"
+
+	cases := []struct {
+		origin string
+		ok     bool
+	}{
+		{"", true},
+		{srv.URL, true},
+		{"https://app.example.com", true},
+		{"https://evil.example.net", false},
+	}
+	for _, tc := range cases {
+		header := http.Header{}
+		if tc.origin != "" {
+			header.Set("Origin", tc.origin)
+		}
+		conn, resp, err := websocket.DefaultDialer.Dial(wsURL, header)
+		if tc.ok {
+			if err != nil {
+				t.Fatalf("origin %q: dial: %v", tc.origin, err)
+			}
+			conn.Close()
+			continue
+		}
+		if err == nil {
+			conn.Close()
+			t.Fatalf("origin %q: upgrade should be rejected", tc.origin)
+		}
+		if resp == nil || resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("origin %q: resp = %v, want 403", tc.origin, resp)
+		}
+	}
+
+	// 未配置 api 分组时只允许同源
+	h.config.CORS = nil
+	header := http.Header{"Origin": []string{"https://app.example.com"}}
+	if conn, _, err := websocket.DefaultDialer.Dial(wsURL, header); err == nil {
+		conn.Close()
+		t.Fatalf("cross-site origin accepted without api CORS policy")
+	}
+}
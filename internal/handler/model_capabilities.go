@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"strings"
+
+	"orchids-api/internal/store"
+)
+
+// ModelPricing 为模型官方标价（每百万 token），仅供客户端估算，不代表上游账号的实际计费
+type ModelPricing struct {
+	InputPerMTok  float64 `json:"input_per_mtok"`
+	OutputPerMTok float64 `json:"output_per_mtok"`
+	Currency      string  `json:"currency"`
+}
+
+// ModelCapabilities 为 /v1/models/{id}/capabilities 的响应
+type ModelCapabilities struct {
+	ID            string `json:"id"`
+	Object        string `json:"object"`
+	Channel       string `json:"channel"`
+	UpstreamModel string `json:"upstream_model"`
+	// MaxContextTokens / MaxOutputTokens 为上游模型本身的上限，未知模型（如 Warp auto）为 0 并省略
+	MaxContextTokens int `json:"max_context_tokens,omitempty"`
+	MaxOutputTokens  int `json:"max_output_tokens,omitempty"`
+	// ContextBudgetTokens 为 context_max_tokens：超出部分的历史会被摘要压缩后再发往上游
+	ContextBudgetTokens int           `json:"context_budget_tokens"`
+	SupportsTools       bool          `json:"supports_tools"`
+	SupportsVision      bool          `json:"supports_vision"`
+	SupportsThinking    bool          `json:"supports_thinking"`
+	StreamingFormats    []string      `json:"streaming_formats"`
+	Pricing             *ModelPricing `json:"pricing,omitempty"`
+}
+
+// modelFamily 按上游模型名中的子串匹配，先匹配先生效
+type modelFamily struct {
+	patterns         []string
+	maxContextTokens int
+	maxOutputTokens  int
+	pricing          ModelPricing
+}
+
+var modelFamilies = []modelFamily{
+	{[]string{"opus-4-6", "4-6-opus", "opus-4-5", "4-5-opus"}, 200000, 64000, ModelPricing{5, 25, "USD"}},
+	{[]string{"opus"}, 200000, 32000, ModelPricing{15, 75, "USD"}},
+	{[]string{"sonnet"}, 200000, 64000, ModelPricing{3, 15, "USD"}},
+	{[]string{"haiku"}, 200000, 64000, ModelPricing{1, 5, "USD"}},
+	{[]string{"gpt-5"}, 400000, 128000, ModelPricing{1.25, 10, "USD"}},
+	{[]string{"gpt-4.1"}, 1047576, 32768, ModelPricing{2, 8, "USD"}},
+	{[]string{"gpt-4o"}, 128000, 16384, ModelPricing{2.5, 10, "USD"}},
+	{[]string{"o4-mini"}, 200000, 100000, ModelPricing{1.1, 4.4, "USD"}},
+	{[]string{"o3"}, 200000, 100000, ModelPricing{2, 8, "USD"}},
+	{[]string{"gemini-3"}, 1048576, 65536, ModelPricing{2, 12, "USD"}},
+	{[]string{"gemini-2-5", "gemini-2.5"}, 1048576, 65536, ModelPricing{1.25, 10, "USD"}},
+}
+
+// streamingFormats 为两个通道都支持的流式传输方式
+var streamingFormats = []string{"anthropic_sse", "openai_sse", "websocket", "long_poll"}
+
+// modelCapabilities 返回模型经本服务调用时的能力，按实际转发行为而不是模型自身能力给出：
+// 图片在 Orchids 与 Warp 请求中都只以文本占位发送，因此 supports_vision 始终为 false
+func (h *Handler) modelCapabilities(m *store.Model) ModelCapabilities {
+	channel := strings.ToLower(strings.TrimSpace(m.Channel))
+	if channel == "" {
+		channel = "orchids"
+	}
+
+	// 与 HandleMessages 一致：Orchids 经 mapModel 映射，Warp 原样交给客户端归一化
+	upstreamModel := m.ModelID
+	supportsTools := true
+	if channel == "warp" {
+		supportsTools = h.config == nil || h.config.WarpDisableTools == nil || !*h.config.WarpDisableTools
+	} else {
+		upstreamModel = mapModel(m.ModelID)
+	}
+
+	caps := ModelCapabilities{
+		ID:               m.ModelID,
+		Object:           "model.capabilities",
+		Channel:          channel,
+		UpstreamModel:    upstreamModel,
+		SupportsTools:    supportsTools,
+		SupportsThinking: strings.Contains(strings.ToLower(upstreamModel), "thinking"),
+		StreamingFormats: streamingFormats,
+	}
+	if h.config != nil {
+		caps.ContextBudgetTokens = h.config.ContextMaxTokens
+	}
+
+	if family, ok := familyFor(upstreamModel); ok {
+		pricing := family.pricing
+		caps.MaxContextTokens = family.maxContextTokens
+		caps.MaxOutputTokens = family.maxOutputTokens
+		caps.Pricing = &pricing
+	}
+	return caps
+}
+
+// familyFor 返回上游模型所属的模型系列
+func familyFor(upstreamModel string) (modelFamily, bool) {
+	lower := strings.ToLower(upstreamModel)
+	for _, family := range modelFamilies {
+		for _, pattern := range family.patterns {
+			if strings.Contains(lower, pattern) {
+				return family, true
+			}
+		}
+	}
+	return modelFamily{}, false
+}
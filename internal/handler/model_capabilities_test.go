@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"testing"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/store"
+)
+
+func TestModelCapabilities(t *testing.T) {
+	disabled := true
+	h := &Handler{config: &config.Config{ContextMaxTokens: 8000, WarpDisableTools: &disabled}}
+
+	orchids := h.modelCapabilities(&store.Model{ModelID: "claude-sonnet-4-5-thinking"})
+	if orchids.Channel != "orchids" || orchids.UpstreamModel != "claude-sonnet-4-5-thinking" {
+		t.Fatalf("orchids mapping = %+v", orchids)
+	}
+	if !orchids.SupportsTools || !orchids.SupportsThinking || orchids.SupportsVision {
+		t.Fatalf("orchids flags = %+v", orchids)
+	}
+	if orchids.MaxContextTokens != 200000 || orchids.Pricing == nil || orchids.Pricing.InputPerMTok != 3 || orchids.ContextBudgetTokens != 8000 {
+		t.Fatalf("orchids limits = %+v pricing = %+v", orchids, orchids.Pricing)
+	}
+
+	// Orchids 通配名称按 mapModel 映射后查表
+	if caps := h.modelCapabilities(&store.Model{ModelID: "opus"}); caps.UpstreamModel != "claude-opus-4-6" || caps.Pricing.OutputPerMTok != 25 {
+		t.Fatalf("opus alias = %+v", caps)
+	}
+
+	warp := h.modelCapabilities(&store.Model{ModelID: "gpt-5-high", Channel: "Warp"})
+	if warp.Channel != "warp" || warp.UpstreamModel != "gpt-5-high" || warp.SupportsTools || warp.MaxContextTokens != 400000 {
+		t.Fatalf("warp caps = %+v", warp)
+	}
+
+	auto := h.modelCapabilities(&store.Model{ModelID: "auto", Channel: "warp"})
+	if auto.MaxContextTokens != 0 || auto.Pricing != nil || len(auto.StreamingFormats) == 0 {
+		t.Fatalf("unknown model caps = %+v", auto)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"strings"
+	"time"
 )
 
 type PublicModelResponse struct {
@@ -24,8 +25,6 @@ func (h *Handler) HandleModels(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-
 	// Determine channel filter based on path prefix
 	filterChannel := channelFromPath(r.URL.Path)
 
@@ -34,6 +33,16 @@ func (h *Handler) HandleModels(w http.ResponseWriter, r *http.Request) {
 		h.writeErrorResponse(w, "api_error", "Model store not configured", http.StatusServiceUnavailable)
 		return
 	}
+
+	// 部分客户端每隔几秒轮询一次，模型未变更时直接返回缓存的响应
+	now := time.Now()
+	gen := h.loadBalancer.Store.ModelsGeneration()
+	policy := h.modelPolicyFingerprint(filterChannel)
+	if e, ok := h.models.get(filterChannel, gen, policy, now); ok {
+		h.writeCachedModels(w, r, e)
+		return
+	}
+
 	allModels, err := h.loadBalancer.Store.ListModels(ctx)
 	if err != nil {
 		h.writeErrorResponse(w, "api_error", "Failed to fetch models: "+err.Error(), http.StatusInternalServerError)
@@ -57,6 +66,10 @@ func (h *Handler) HandleModels(w http.ResponseWriter, r *http.Request) {
 		if !m.Status.Enabled() {
 			continue
 		}
+		// channel_models 中排除的模型不对该渠道暴露
+		if !h.config.ModelAllowed(filterChannel, m.ModelID) {
+			continue
+		}
 
 		publicModels = append(publicModels, PublicModelResponse{
 			ID:      m.ModelID, // Use the actual model ID (e.g. "claude-3-opus") not the DB ID
@@ -71,9 +84,14 @@ func (h *Handler) HandleModels(w http.ResponseWriter, r *http.Request) {
 		Data:   publicModels,
 	}
 
-	if err := json.NewEncoder(w).Encode(resp); err != nil {
+	body, err := json.Marshal(resp)
+	if err != nil {
 		h.writeErrorResponse(w, "api_error", "Failed to encode response", http.StatusInternalServerError)
+		return
 	}
+	e := newModelListEntry(gen, policy, now, append(body, '\n'))
+	h.models.put(filterChannel, e)
+	h.writeCachedModels(w, r, e)
 }
 
 // HandleModelByID is optional for public API but good for completeness
@@ -97,6 +115,9 @@ func (h *Handler) HandleModelByID(w http.ResponseWriter, r *http.Request) {
 		id = strings.TrimPrefix(path, "/v1/models/")
 	}
 
+	// /v1/models/{id}/capabilities
+	id, capabilities := strings.CutSuffix(id, "/capabilities")
+
 	if id == "" {
 		h.writeErrorResponse(w, "invalid_request_error", "Model ID required", http.StatusBadRequest)
 		return
@@ -126,6 +147,17 @@ func (h *Handler) HandleModelByID(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 	}
+	if !h.config.ModelAllowed(filterChannel, m.ModelID) {
+		h.writeErrorResponse(w, "invalid_request_error", "Model not found in this channel", http.StatusNotFound)
+		return
+	}
+
+	if capabilities {
+		if err := json.NewEncoder(w).Encode(h.modelCapabilities(m)); err != nil {
+			h.writeErrorResponse(w, "api_error", "Failed to encode response", http.StatusInternalServerError)
+		}
+		return
+	}
 
 	resp := PublicModelResponse{
 		ID:      m.ModelID,
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// modelListCacheTTL 为模型列表快照的最长有效期，防止错过其他副本的变更通知后一直返回旧列表
+const modelListCacheTTL = 30 * time.Second
+
+// modelListCache 按渠道缓存 /v1/models 的响应体，模型变更代数或 channel_models 配置变化时重建
+type modelListCache struct {
+	mu      sync.Mutex
+	entries map[string]modelListEntry
+}
+
+type modelListEntry struct {
+	gen     uint64
+	policy  string
+	builtAt time.Time
+	body    []byte
+	etag    string
+}
+
+func (c *modelListCache) get(channel string, gen uint64, policy string, now time.Time) (modelListEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[channel]
+	if !ok || e.gen != gen || e.policy != policy || now.Sub(e.builtAt) > modelListCacheTTL {
+		return modelListEntry{}, false
+	}
+	return e, true
+}
+
+func (c *modelListCache) put(channel string, e modelListEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]modelListEntry)
+	}
+	c.entries[channel] = e
+}
+
+// newModelListEntry 计算响应体的强 ETag
+func newModelListEntry(gen uint64, policy string, now time.Time, body []byte) modelListEntry {
+	sum := sha256.Sum256(body)
+	return modelListEntry{gen: gen, policy: policy, builtAt: now, body: body, etag: `"` + hex.EncodeToString(sum[:8]) + `"`}
+}
+
+// modelPolicyFingerprint 返回影响该渠道模型列表的 channel_models 配置的指纹
+func (h *Handler) modelPolicyFingerprint(channel string) string {
+	if h.config == nil || len(h.config.ChannelModels) == 0 {
+		return ""
+	}
+	key := channel
+	if key == "" {
+		key = "public"
+	}
+	policy, ok := h.config.ChannelModels[key]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("%q|%q", policy.Allow, policy.Deny)
+}
+
+// modelsCacheControl 按 models_cache_max_age 返回 Cache-Control
+func (h *Handler) modelsCacheControl() string {
+	if h.config == nil || h.config.ModelsCacheMaxAge <= 0 {
+		return "no-cache"
+	}
+	return "private, max-age=" + strconv.Itoa(h.config.ModelsCacheMaxAge)
+}
+
+// etagMatches 报告 If-None-Match 是否包含 etag（弱比较，支持 * 与逗号分隔的多个值）
+func etagMatches(ifNoneMatch, etag string) bool {
+	if ifNoneMatch == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCachedModels 写出带 ETag 的模型列表，客户端缓存仍有效时返回 304
+func (h *Handler) writeCachedModels(w http.ResponseWriter, r *http.Request, e modelListEntry) {
+	w.Header().Set("ETag", e.etag)
+	w.Header().Set("Cache-Control", h.modelsCacheControl())
+	if etagMatches(r.Header.Get("If-None-Match"), e.etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(e.body)
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"orchids-api/internal/config"
+)
+
+func TestEtagMatches(t *testing.T) {
+	etag := `"abc"`
+	cases := map[string]bool{
+		"":           false,
+		`"abc"`:      true,
+		`W/"abc"`:    true,
+		`"x", "abc"`: true,
+		"*":          true,
+		`"abcd"`:     false,
+	}
+	for header, want := range cases {
+		if got := etagMatches(header, etag); got != want {
+			t.Errorf("etagMatches(%q) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestModelListCache_Invalidation(t *testing.T) {
+	var c modelListCache
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c.put("orchids", newModelListEntry(1, "", now, []byte(`{"object":"list"}`)))
+
+	if _, ok := c.get("orchids", 1, "", now.Add(time.Second)); !ok {
+		t.Fatalf("expected cache hit")
+	}
+	if _, ok := c.get("warp", 1, "", now); ok {
+		t.Fatalf("channels must be cached separately")
+	}
+	if _, ok := c.get("orchids", 2, "", now); ok {
+		t.Fatalf("model change must invalidate the entry")
+	}
+	if _, ok := c.get("orchids", 1, `["claude-*"]`, now); ok {
+		t.Fatalf("channel_models change must invalidate the entry")
+	}
+	if _, ok := c.get("orchids", 1, "", now.Add(modelListCacheTTL+time.Second)); ok {
+		t.Fatalf("entry must expire after the TTL")
+	}
+}
+
+func TestWriteCachedModels_NotModified(t *testing.T) {
+	h := &Handler{config: &config.Config{ModelsCacheMaxAge: 60}}
+	e := newModelListEntry(1, "", time.Now(), []byte(`{"object":"list"}`))
+
+	rec := httptest.NewRecorder()
+	h.writeCachedModels(rec, httptest.NewRequest(http.MethodGet, "/v1/models", nil), e)
+	if rec.Code != http.StatusOK || rec.Header().Get("ETag") != e.etag || rec.Header().Get("Cache-Control") != "private, max-age=60" {
+		t.Fatalf("status=%d headers=%v", rec.Code, rec.Header())
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+	req.Header.Set("If-None-Match", e.etag)
+	rec = httptest.NewRecorder()
+	h.writeCachedModels(rec, req, e)
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Fatalf("status=%d body=%q", rec.Code, rec.Body.String())
+	}
+}
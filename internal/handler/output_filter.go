@@ -0,0 +1,382 @@
+package handler
+
+import (
+	"encoding/json"
+	"log/slog"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/metrics"
+	"orchids-api/internal/perf"
+)
+
+const defaultOutputFilterReplacement = "[REDACTED]"
+
+// outputFilterPattern 为 preset 中的一条规则。open 非空时为可能很长的命中（如 PEM 私钥）的起始标记：
+// 流式输出中出现 open 而尚未形成完整命中时，从标记处开始暂缓输出，直到命中完成或超过 outputFilterMaxPending
+type outputFilterPattern struct {
+	pattern string
+	open    string
+}
+
+// outputFilterMaxPending 为起始标记之后最多暂缓的字节数，防止未闭合的标记无限占用内存；
+// 足以容纳 4096 位 RSA 私钥
+const outputFilterMaxPending = 16 * 1024
+
+// outputFilterPresets 为 output_filter_rules 中 preset 展开的规则
+var outputFilterPresets = map[string][]outputFilterPattern{
+	"secrets": {
+		{pattern: `(?i)\bBearer\s+[A-Za-z0-9._~+/=-]{16,}`},
+		{pattern: `\beyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`},
+		{pattern: `\bsk-[A-Za-z0-9_-]{16,}`},
+		{pattern: `\b(AKIA|ASIA)[0-9A-Z]{16}\b`},
+		{pattern: `\b(ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36}\b|\bgithub_pat_[A-Za-z0-9_]{22,}`},
+		{
+			pattern: `-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`,
+			open:    `-----BEGIN [A-Z ]*PRIVATE KEY-----`,
+		},
+	},
+}
+
+type outputFilterRule struct {
+	name string
+	re   *regexp.Regexp
+	open *regexp.Regexp
+	repl string
+}
+
+// outputFilter 为编译后的 output_filter_rules，只读，可在请求间共享
+type outputFilter struct {
+	rules    []outputFilterRule
+	holdback int
+}
+
+// outputFilterFor 返回 API Key 对应的输出过滤器，策略为 off 或没有规则时返回 nil。
+// 编译结果按 output_filter_rules 缓存，配置变更后重新编译
+func (h *Handler) outputFilterFor(keyID string) *outputFilter {
+	if h.config.OutputFilterFor(keyID) != config.OutputFilterMask || len(h.config.OutputFilterRules) == 0 {
+		return nil
+	}
+	raw := h.config.OutputFilterRules
+	holdback := h.config.OutputFilterHoldback
+	h.outputFilterMu.Lock()
+	defer h.outputFilterMu.Unlock()
+	if h.outputFilterCompiled == nil || !slices.Equal(raw, h.outputFilterRaw) || h.outputFilterCompiled.holdback != holdback {
+		h.outputFilterRaw = slices.Clone(raw)
+		h.outputFilterCompiled = compileOutputFilter(raw, holdback)
+	}
+	if len(h.outputFilterCompiled.rules) == 0 {
+		return nil
+	}
+	return h.outputFilterCompiled
+}
+
+// compileOutputFilter 编译规则，无法编译的规则（配置校验会报告）被跳过
+func compileOutputFilter(raw []config.OutputFilterRule, holdback int) *outputFilter {
+	f := &outputFilter{holdback: max(holdback, 0)}
+	for _, r := range raw {
+		repl := r.Replacement
+		if repl == "" {
+			repl = defaultOutputFilterReplacement
+		}
+		patterns := []outputFilterPattern{{pattern: r.Pattern}}
+		name := r.Name
+		if r.Preset != "" {
+			patterns = outputFilterPresets[r.Preset]
+			if name == "" {
+				name = r.Preset
+			}
+		} else if name == "" {
+			name = r.Pattern
+		}
+		for _, p := range patterns {
+			re, err := regexp.Compile(p.pattern)
+			if err != nil {
+				slog.Warn("Invalid output_filter_rules pattern, skipped", "rule", name, "error", err)
+				continue
+			}
+			rule := outputFilterRule{name: name, re: re, repl: repl}
+			if p.open != "" {
+				rule.open = regexp.MustCompile(p.open)
+			}
+			f.rules = append(f.rules, rule)
+		}
+	}
+	return f
+}
+
+// mask 替换 text 中所有命中的内容，并把命中次数累加到 counts
+func (f *outputFilter) mask(text string, counts map[string]int) string {
+	for _, rule := range f.rules {
+		if n := len(rule.re.FindAllStringIndex(text, -1)); n > 0 {
+			counts[rule.name] += n
+			text = rule.re.ReplaceAllString(text, rule.repl)
+		}
+	}
+	return text
+}
+
+// safePrefix 返回 pending 中可以输出的长度：末尾 holdback 字节暂不输出，不截断任何规则的命中，
+// 也不输出未闭合的起始标记之后的内容，使跨 delta 的内容在完整到达后再匹配
+func (f *outputFilter) safePrefix(pending string) int {
+	safe := len(pending) - f.holdback
+	if safe <= 0 {
+		return 0
+	}
+	for changed := true; changed; {
+		changed = false
+		for _, rule := range f.rules {
+			matches := rule.re.FindAllStringIndex(pending, -1)
+			for _, loc := range matches {
+				if loc[0] < safe && loc[1] > safe {
+					safe = loc[0]
+					changed = true
+				}
+			}
+			if start := rule.unclosedStart(pending, matches); start >= 0 && start < safe && len(pending)-start <= outputFilterMaxPending {
+				safe = start
+				changed = true
+			}
+		}
+	}
+	for safe > 0 && !utf8.RuneStart(pending[safe]) {
+		safe--
+	}
+	return safe
+}
+
+// unclosedStart 返回第一个不属于完整命中的起始标记的位置，没有时返回 -1
+func (r outputFilterRule) unclosedStart(pending string, matches [][]int) int {
+	if r.open == nil {
+		return -1
+	}
+	for _, loc := range r.open.FindAllStringIndex(pending, -1) {
+		closed := false
+		for _, m := range matches {
+			if loc[0] >= m[0] && loc[0] < m[1] {
+				closed = true
+				break
+			}
+		}
+		if !closed {
+			return loc[0]
+		}
+	}
+	return -1
+}
+
+// maskJSON 对 JSON 值中的每个字符串脱敏（对象的键保持不变），返回脱敏后的值与是否有改动
+func (f *outputFilter) maskJSON(v interface{}, counts map[string]int) (interface{}, bool) {
+	switch val := v.(type) {
+	case string:
+		masked := f.mask(val, counts)
+		return masked, masked != val
+	case map[string]interface{}:
+		changed := false
+		for k, child := range val {
+			if masked, ok := f.maskJSON(child, counts); ok {
+				val[k] = masked
+				changed = true
+			}
+		}
+		return val, changed
+	case []interface{}:
+		changed := false
+		for i, child := range val {
+			if masked, ok := f.maskJSON(child, counts); ok {
+				val[i] = masked
+				changed = true
+			}
+		}
+		return val, changed
+	}
+	return v, false
+}
+
+// maskJSONText 对 JSON 文本脱敏：按字符串值匹配后重新编码，保证替换不会破坏 JSON 结构；
+// 没有命中时原样返回，无法解析时按原文匹配
+func (f *outputFilter) maskJSONText(data string, counts map[string]int) string {
+	var v interface{}
+	if err := json.Unmarshal([]byte(data), &v); err != nil {
+		return f.mask(data, counts)
+	}
+	v, changed := f.maskJSON(v, counts)
+	if !changed {
+		return data
+	}
+	out, err := json.Marshal(v)
+	if err != nil {
+		return f.mask(data, counts)
+	}
+	return string(out)
+}
+
+// outputFilterState 为一个内容块的过滤状态：块内暂存的文本与各规则的脱敏次数，由 streamHandler.mu 保护
+type outputFilterState struct {
+	filter  *outputFilter
+	pending string
+	counts  map[string]int
+}
+
+func newOutputFilterState(f *outputFilter) *outputFilterState {
+	return &outputFilterState{filter: f, counts: make(map[string]int)}
+}
+
+// fork 返回共享规则与计数、但有独立暂存区的状态，用于与 text 块交替出现的 thinking 块
+func (s *outputFilterState) fork() *outputFilterState {
+	return &outputFilterState{filter: s.filter, counts: s.counts}
+}
+
+// push 追加一段 delta，返回脱敏后可以立即输出的部分
+func (s *outputFilterState) push(delta string) string {
+	s.pending += delta
+	safe := s.filter.safePrefix(s.pending)
+	if safe == 0 {
+		return ""
+	}
+	out := s.filter.mask(s.pending[:safe], s.counts)
+	s.pending = s.pending[safe:]
+	return out
+}
+
+// flush 返回暂存文本脱敏后的结果，在 text 块结束时调用
+func (s *outputFilterState) flush() string {
+	if s.pending == "" {
+		return ""
+	}
+	out := s.filter.mask(s.pending, s.counts)
+	s.pending = ""
+	return out
+}
+
+// filterTextDelta 对模型输出的 text delta 脱敏，未启用输出过滤时原样返回
+func (h *streamHandler) filterTextDelta(delta string) string {
+	if h.outputFilter == nil {
+		return delta
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.outputFilter.push(delta)
+}
+
+// filterThinkingDelta 对 thinking delta 脱敏，暂存区与 text 块分开，在 thinking 块结束时取出
+func (h *streamHandler) filterThinkingDelta(delta string) string {
+	if h.thinkingFilter == nil {
+		return delta
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.thinkingFilter.push(delta)
+}
+
+// filterText 对一次性输出的完整文本脱敏
+func (h *streamHandler) filterText(text string) string {
+	if h.outputFilter == nil {
+		return text
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.outputFilter.filter.mask(text, h.outputFilter.counts)
+}
+
+// filterToolInput 对 tool_use 的输入 JSON 脱敏，流式的 input_json_delta 与非流式的 content 共用
+func (h *streamHandler) filterToolInput(input string) string {
+	if h.outputFilter == nil || input == "" {
+		return input
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.outputFilter.filter.maskJSONText(input, h.outputFilter.counts)
+}
+
+// filterPassthroughEvent 对原样转发的上游事件脱敏。其中的文本同时经 thinking/text delta 输出并计数，这里不重复计数
+func (h *streamHandler) filterPassthroughEvent(data []byte) string {
+	if h.outputFilter == nil {
+		return string(data)
+	}
+	return h.outputFilter.filter.maskJSONText(string(data), map[string]int{})
+}
+
+// drainOutputFilterLocked 在当前 text / thinking 块结束前取出暂存的内容：写入块内容，并返回需要写出的 content_block_delta。
+// 调用方需持有 h.mu
+func (h *streamHandler) drainOutputFilterLocked() (string, bool) {
+	var (
+		state     *outputFilterState
+		builder   *strings.Builder
+		sseIdx    int
+		deltaType string
+		field     string
+	)
+	switch h.activeBlockType {
+	case "text":
+		state, builder, sseIdx = h.outputFilter, h.textBlockBuilders[h.activeTextBlockIndex], h.activeTextSSEIndex
+		deltaType, field = "text_delta", "text"
+	case "thinking":
+		state, builder, sseIdx = h.thinkingFilter, h.thinkingBlockBuilders[h.activeThinkingBlockIndex], h.activeThinkingSSEIndex
+		deltaType, field = "thinking_delta", "thinking"
+	}
+	if state == nil {
+		return "", false
+	}
+	tail := state.flush()
+	if tail == "" {
+		return "", false
+	}
+	if builder != nil {
+		builder.WriteString(tail)
+	}
+	if !h.isStream {
+		if field == "text" {
+			h.responseText.WriteString(tail)
+		}
+		return "", false
+	}
+	m := perf.AcquireMap()
+	m["type"] = "content_block_delta"
+	m["index"] = sseIdx
+	delta := perf.AcquireMap()
+	delta["type"] = deltaType
+	delta[field] = tail
+	m["delta"] = delta
+	data, err := json.Marshal(m)
+	perf.ReleaseMap(delta)
+	perf.ReleaseMap(m)
+	if err != nil {
+		return "", false
+	}
+	return string(data), true
+}
+
+// recordOutputFilter 输出本次响应的脱敏计数
+func (h *streamHandler) recordOutputFilter(keyID string) {
+	if h.outputFilter == nil {
+		return
+	}
+	h.mu.Lock()
+	counts := make(map[string]int, len(h.outputFilter.counts))
+	total := 0
+	for name, n := range h.outputFilter.counts {
+		counts[name] = n
+		total += n
+	}
+	h.mu.Unlock()
+	if total == 0 {
+		return
+	}
+	for name, n := range counts {
+		metrics.OutputFilterRedactionsTotal.WithLabelValues(name).Add(float64(n))
+	}
+	slog.Info("Output filter masked model output", "key_id", keyID, "redactions", total, "rules", strings.Join(sortedKeys(counts), ","))
+}
+
+func sortedKeys(m map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
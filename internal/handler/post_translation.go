@@ -0,0 +1,160 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"orchids-api/internal/adapter"
+	"orchids-api/internal/debug"
+	"orchids-api/internal/prompt"
+	"orchids-api/internal/upstream"
+)
+
+// TranslatedLanguageHeader 在响应文本经过 post_translation 翻译后返回目标语言
+const TranslatedLanguageHeader = "X-Translated-Language"
+
+const postTranslationSystemPrompt = "You are a translation engine. Translate the user's text into the language identified by the BCP 47 tag %s. " +
+	"Preserve Markdown structure, code blocks, inline code, URLs, file paths and placeholders exactly; do not translate code. " +
+	"Output only the translation, without any preamble or explanation."
+
+// responseLanguage 返回请求要求的输出语言：请求体的 response_language 优先，其次 Accept-Language 中权重最高的语言；
+// 与 post_translation_source_language 相同（只比较主语言）或未指定时返回空
+func (h *Handler) responseLanguage(r *http.Request, req ClaudeRequest) string {
+	lang := strings.TrimSpace(req.ResponseLanguage)
+	if lang == "" {
+		lang = preferredLanguage(r.Header.Get("Accept-Language"))
+	}
+	if lang == "" || strings.ContainsAny(lang, "\r\n") {
+		return ""
+	}
+	source := h.config.PostTranslationSourceLanguage
+	if source == "" {
+		source = "en"
+	}
+	if strings.EqualFold(primaryLanguage(lang), primaryLanguage(source)) {
+		return ""
+	}
+	return lang
+}
+
+// preferredLanguage 解析 Accept-Language，返回 q 值最高的语言标签（相同时取先出现的），忽略 * 与 q=0
+func preferredLanguage(header string) string {
+	type tag struct {
+		lang string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		lang := strings.TrimSpace(fields[0])
+		if lang == "" || lang == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range fields[1:] {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			tags = append(tags, tag{lang: lang, q: q})
+		}
+	}
+	if len(tags) == 0 {
+		return ""
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	return tags[0].lang
+}
+
+func primaryLanguage(tag string) string {
+	primary, _, _ := strings.Cut(strings.ReplaceAll(tag, "_", "-"), "-")
+	return strings.ToLower(primary)
+}
+
+// translateTextBlocks 用一次额外的上游调用把非流式响应中的每个 text 块翻译为 lang，
+// 在附加 attribution_footer 之前调用；任一块翻译失败时保留该块原文。返回是否有块被翻译
+func (h *Handler) translateTextBlocks(ctx context.Context, client UpstreamClient, model, lang string, sh *streamHandler) bool {
+	if client == nil {
+		return false
+	}
+	if h.config.PostTranslationModel != "" {
+		model = h.config.PostTranslationModel
+	}
+	translated := false
+	for i := range sh.contentBlocks {
+		if blockType, _ := sh.contentBlocks[i]["type"].(string); blockType != "text" {
+			continue
+		}
+		text, _ := sh.contentBlocks[i]["text"].(string)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		out, err := h.translateText(ctx, client, model, lang, text)
+		if err != nil {
+			slog.Warn("Post-translation failed, returning original text", "language", lang, "model", model, "error", err)
+			continue
+		}
+		if strings.TrimSpace(out) == "" {
+			continue
+		}
+		sh.contentBlocks[i]["text"] = out
+		translated = true
+	}
+	return translated
+}
+
+// translateText 发送翻译请求，复用 streamHandler 的非流式模式解析上游事件（Orchids 与 Warp 格式相同处理）
+func (h *Handler) translateText(ctx context.Context, client UpstreamClient, model, lang, text string) (string, error) {
+	system := fmt.Sprintf(postTranslationSystemPrompt, lang)
+	logger := debug.New(false, false)
+	// 非流式 streamHandler 不会写出 w，这里不需要真实的 ResponseWriter
+	tsh := newStreamHandler(h.config, nil, logger, true, false, adapter.FormatAnthropic, "")
+	defer tsh.release()
+
+	var err error
+	if sender, ok := client.(UpstreamPayloadClient); ok {
+		err = sender.SendRequestWithPayload(ctx, upstream.UpstreamRequest{
+			Prompt:     text,
+			Model:      model,
+			Messages:   []prompt.Message{{Role: "user", Content: prompt.MessageContent{Text: text}}},
+			System:     []prompt.SystemItem{{Type: "text", Text: system}},
+			NoTools:    true,
+			NoThinking: true,
+		}, tsh.handleMessage, logger)
+	} else {
+		err = client.SendRequest(ctx, system+"\n\n"+text, nil, model, tsh.handleMessage, logger)
+	}
+	if err != nil {
+		return "", err
+	}
+	return tsh.textOutput(), nil
+}
+
+// textOutput 按顺序拼接非流式模式下收集到的 text 块
+func (h *streamHandler) textOutput() string {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var sb strings.Builder
+	for i, block := range h.contentBlocks {
+		if blockType, _ := block["type"].(string); blockType != "text" {
+			continue
+		}
+		if builder, ok := h.textBlockBuilders[i]; ok {
+			sb.WriteString(builder.String())
+		} else if text, ok := block["text"].(string); ok {
+			sb.WriteString(text)
+		}
+	}
+	if sb.Len() == 0 {
+		return h.responseText.String()
+	}
+	return sb.String()
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/debug"
+	"orchids-api/internal/prompt"
+	"orchids-api/internal/upstream"
+)
+
+// sequenceClient 依次以 replies 中的文本作为每次调用的响应，并记录收到的 prompt
+type sequenceClient struct {
+	replies []string
+	prompts []string
+}
+
+func (c *sequenceClient) SendRequest(ctx context.Context, prompt string, chatHistory []interface{}, model string, onMessage func(upstream.SSEMessage), logger *debug.Logger) error {
+	reply := c.replies[len(c.prompts)]
+	c.prompts = append(c.prompts, prompt)
+	onMessage(upstream.SSEMessage{Type: "model", Event: map[string]interface{}{"type": "text-start"}})
+	onMessage(upstream.SSEMessage{Type: "model", Event: map[string]interface{}{"type": "text-delta", "delta": reply}})
+	onMessage(upstream.SSEMessage{Type: "model", Event: map[string]interface{}{"type": "text-end"}})
+	onMessage(upstream.SSEMessage{Type: "model", Event: map[string]interface{}{"type": "finish", "finishReason": "stop"}})
+	return nil
+}
+
+func TestPreferredLanguage(t *testing.T) {
+	cases := map[string]string{
+		"":                             "",
+		"fr-FR,fr;q=0.9,en;q=0.8":      "fr-FR",
+		"en;q=0.5, zh-CN;q=0.9, *;q=1": "zh-CN",
+		"de;q=0, ja":                   "ja",
+		"es;q=0.7,pt;q=0.7":            "es",
+		"ko;q=not-a-number":            "ko",
+	}
+	for header, want := range cases {
+		if got := preferredLanguage(header); got != want {
+			t.Errorf("preferredLanguage(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestHandleMessages_PostTranslation(t *testing.T) {
+	do := func(lang string, body ClaudeRequest) (*sequenceClient, *httptest.ResponseRecorder) {
+		client := &sequenceClient{replies: []string{"Hello", "Bonjour"}}
+		h := &Handler{
+			config: &config.Config{PostTranslation: true, AttributionFooter: "footer"},
+			client: client,
+		}
+		raw, _ := json.Marshal(body)
+		req := httptest.NewRequest(http.MethodPost, "/v1/messages", bytes.NewReader(raw))
+		if lang != "" {
+			req.Header.Set("Accept-Language", lang)
+		}
+		rec := httptest.NewRecorder()
+		h.HandleMessages(rec, req)
+		return client, rec
+	}
+	body := ClaudeRequest{
+		Model:    "gpt-test",
+		Messages: []prompt.Message{{Role: "user", Content: prompt.MessageContent{Text: "Hi"}}},
+	}
+
+	client, rec := do("fr-FR,fr;q=0.9", body)
+	var resp struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	// 标注文本不参与翻译
+	if len(resp.Content) != 2 || resp.Content[0].Text != "Bonjour" || resp.Content[1].Text != "\n\nfooter" {
+		t.Fatalf("content = %+v", resp.Content)
+	}
+	if len(client.prompts) != 2 || !strings.Contains(client.prompts[1], "fr-FR") || !strings.HasSuffix(client.prompts[1], "Hello") {
+		t.Fatalf("translation prompt = %q", client.prompts)
+	}
+	if rec.Header().Get(TranslatedLanguageHeader) != "fr-FR" {
+		t.Fatalf("translated language header = %q", rec.Header().Get(TranslatedLanguageHeader))
+	}
+
+	// 与上游语言相同时不翻译；response_language 优先于 Accept-Language
+	if client, _ := do("en-US", body); len(client.prompts) != 1 {
+		t.Fatalf("same language translated: %d calls", len(client.prompts))
+	}
+	body.ResponseLanguage = "en"
+	if client, _ := do("fr", body); len(client.prompts) != 1 {
+		t.Fatalf("response_language ignored: %d calls", len(client.prompts))
+	}
+}
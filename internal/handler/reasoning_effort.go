@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+// reasoning_effort 映射后的模式
+const (
+	reasoningModeThinking = "thinking"
+	reasoningModeStandard = "standard"
+)
+
+var reasoningEfforts = []string{"minimal", "low", "medium", "high"}
+
+// reasoningEffortFor 返回本次请求生效的 reasoning_effort：请求显式指定时记录到会话，
+// 未指定时沿用该会话上一次的值，避免客户端只在首轮发送时后续轮次退回默认模型
+func (h *Handler) reasoningEffortFor(conversationKey, effort string) string {
+	effort = strings.ToLower(strings.TrimSpace(effort))
+	if effort != "" && !slices.Contains(reasoningEfforts, effort) {
+		slog.Debug("Ignoring unknown reasoning_effort", "reasoning_effort", effort)
+		effort = ""
+	}
+	if conversationKey == "" {
+		return effort
+	}
+
+	h.sessionWorkdirsMu.Lock()
+	defer h.sessionWorkdirsMu.Unlock()
+	if effort == "" {
+		return h.sessionEfforts[conversationKey]
+	}
+	if h.sessionEfforts == nil {
+		h.sessionEfforts = make(map[string]string)
+	}
+	if h.sessionLastAccess == nil {
+		h.sessionLastAccess = make(map[string]time.Time)
+	}
+	h.sessionEfforts[conversationKey] = effort
+	h.sessionLastAccess[conversationKey] = time.Now()
+	return effort
+}
+
+// reasoningMode 按 reasoning_effort_map 返回 effort 对应的模式
+func (h *Handler) reasoningMode(effort string) string {
+	if h.config != nil {
+		if mode, ok := h.config.ReasoningEffortMap[effort]; ok {
+			return strings.ToLower(strings.TrimSpace(mode))
+		}
+	}
+	if effort == "medium" || effort == "high" {
+		return reasoningModeThinking
+	}
+	return reasoningModeStandard
+}
+
+// applyReasoningEffort 根据 effort 调整已映射的上游模型：
+// Orchids 与 Warp 的 Claude 模型在 -thinking 变体与普通变体间切换（仅限上游存在的变体），
+// Warp 的 GPT-5 系列直接使用 -low/-medium/-high 档位
+func (h *Handler) applyReasoningEffort(model, effort string, isWarp bool) string {
+	lower := strings.ToLower(model)
+	if isWarp && strings.HasPrefix(lower, "gpt-5") {
+		level := effort
+		if level == "minimal" {
+			level = "low"
+		}
+		for _, suffix := range []string{"-low", "-medium", "-high"} {
+			if base, ok := strings.CutSuffix(lower, suffix); ok {
+				return base + "-" + level
+			}
+		}
+		return lower + "-" + level
+	}
+
+	thinking := h.reasoningMode(effort) == reasoningModeThinking
+	base := strings.TrimSuffix(lower, "-thinking")
+	if !isWarp {
+		if thinking {
+			return mapModel(base + "-thinking")
+		}
+		return mapModel(base)
+	}
+	if !strings.Contains(base, "4-5-sonnet") && !strings.Contains(base, "4-5-opus") {
+		return model
+	}
+	if thinking {
+		return base + "-thinking"
+	}
+	return base
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"testing"
+
+	"orchids-api/internal/config"
+)
+
+func TestApplyReasoningEffort(t *testing.T) {
+	h := &Handler{config: &config.Config{ReasoningEffortMap: map[string]string{"low": "standard", "medium": "thinking", "high": "thinking"}}}
+	cases := []struct {
+		model, effort string
+		warp          bool
+		want          string
+	}{
+		{"claude-sonnet-4-5", "high", false, "claude-sonnet-4-5-thinking"},
+		{"claude-opus-4-6-thinking", "low", false, "claude-opus-4-6"},
+		{"claude-haiku-4-5", "high", false, "claude-haiku-4-5"}, // 没有 thinking 变体
+		{"gpt-5", "high", true, "gpt-5-high"},
+		{"gpt-5-1-codex-medium", "minimal", true, "gpt-5-1-codex-low"},
+		{"claude-4-5-sonnet", "medium", true, "claude-4-5-sonnet-thinking"},
+		{"claude-4-5-opus-thinking", "low", true, "claude-4-5-opus"},
+		{"auto", "high", true, "auto"},
+	}
+	for _, tc := range cases {
+		if got := h.applyReasoningEffort(tc.model, tc.effort, tc.warp); got != tc.want {
+			t.Errorf("applyReasoningEffort(%q, %q, warp=%v) = %q, want %q", tc.model, tc.effort, tc.warp, got, tc.want)
+		}
+	}
+}
+
+func TestReasoningEffortFor_StickyPerConversation(t *testing.T) {
+	h := &Handler{}
+	if got := h.reasoningEffortFor("conv-1", "HIGH"); got != "high" {
+		t.Fatalf("explicit effort = %q", got)
+	}
+	if got := h.reasoningEffortFor("conv-1", ""); got != "high" {
+		t.Fatalf("sticky effort = %q, want high", got)
+	}
+	if got := h.reasoningEffortFor("conv-1", "extreme"); got != "high" {
+		t.Fatalf("unknown effort should keep sticky value, got %q", got)
+	}
+	if got := h.reasoningEffortFor("conv-2", ""); got != "" {
+		t.Fatalf("other conversation effort = %q", got)
+	}
+	if got := h.reasoningEffortFor("", "low"); got != "low" {
+		t.Fatalf("no conversation effort = %q", got)
+	}
+}
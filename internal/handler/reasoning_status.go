@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"orchids-api/internal/upstream"
+)
+
+const (
+	// reasoningStatusMaxRunes 为单条状态文本的最大字符数（不含省略号）
+	reasoningStatusMaxRunes = 80
+	// reasoningStatusMaxPending 为缓冲的思考内容上限，超出仍未出现完整句子时直接截取
+	reasoningStatusMaxPending = 600
+)
+
+// reasoningStatus 将上游思考增量转换为定期输出的简短状态文本（如 "Analyzing repository structure…"），
+// 供无法渲染 thinking 块的客户端展示进度。两条状态之间至少间隔 interval，相同的状态不重复输出
+type reasoningStatus struct {
+	interval time.Duration
+	now      func() time.Time
+
+	pending strings.Builder
+	last    string
+	lastAt  time.Time
+	count   int
+}
+
+func newReasoningStatus(interval time.Duration) *reasoningStatus {
+	return &reasoningStatus{interval: interval, now: time.Now}
+}
+
+// add 缓冲一段思考增量，到达输出间隔且已有完整句子时返回新的状态文本
+func (s *reasoningStatus) add(delta string) (string, bool) {
+	s.pending.WriteString(delta)
+	if s.count > 0 && s.now().Sub(s.lastAt) < s.interval {
+		return "", false
+	}
+	text := s.pending.String()
+	status, consumed := summarizeReasoning(text)
+	if consumed == 0 {
+		if len(text) < reasoningStatusMaxPending {
+			return "", false
+		}
+		consumed = len(text)
+	}
+	// 保留该句之后的内容，作为下一条状态的开头
+	s.pending.Reset()
+	s.pending.WriteString(text[consumed:])
+	return s.take(status)
+}
+
+// flush 在一段思考结束时调用：本次请求尚未输出过状态时，用已缓冲的内容输出一条
+func (s *reasoningStatus) flush() (string, bool) {
+	status, _ := summarizeReasoning(s.pending.String())
+	s.pending.Reset()
+	if s.count > 0 {
+		return "", false
+	}
+	return s.take(status)
+}
+
+func (s *reasoningStatus) take(status string) (string, bool) {
+	if status == "" || status == s.last {
+		return "", false
+	}
+	s.last, s.lastAt = status, s.now()
+	s.count++
+	return status, true
+}
+
+// summarizeReasoning 取思考内容中第一个完整句子（或 Markdown 标题行）作为状态文本，
+// 去掉标题与加粗标记并截断到 reasoningStatusMaxRunes，以省略号结尾。
+// consumed 为该句结束位置的字节偏移，句子尚未完整时为 0
+func summarizeReasoning(text string) (status string, consumed int) {
+	offset := 0
+	for offset < len(text) {
+		line, _, hasNewline := strings.Cut(text[offset:], "\n")
+		lineEnd := offset + len(line)
+		if hasNewline {
+			lineEnd++
+		}
+		// 句末标点之后的内容留给下一条状态；最后一行可能仍在输出中，只在出现句末标点时视为完整
+		complete := hasNewline
+		if end := sentenceEnd(line); end > 0 {
+			line, lineEnd, complete = line[:end], offset+end, true
+		}
+		offset = lineEnd
+
+		line = strings.TrimLeft(strings.TrimSpace(line), "#*->• \t")
+		line = strings.TrimRight(line, ".。:：!！?？;；* \t")
+		if line == "" {
+			continue
+		}
+		if utf8.RuneCountInString(line) > reasoningStatusMaxRunes {
+			line = strings.TrimSpace(string([]rune(line)[:reasoningStatusMaxRunes]))
+		}
+		if !complete {
+			return line + "…", 0
+		}
+		return line + "…", lineEnd
+	}
+	return "", 0
+}
+
+// sentenceEnd 返回 s 中第一个句末标点之后的字节偏移，未找到时返回 -1。
+// 英文句号、问号、叹号需后跟空白，避免截断版本号与文件名
+func sentenceEnd(s string) int {
+	for i, r := range s {
+		switch r {
+		case '。', '！', '？':
+			return i + utf8.RuneLen(r)
+		case '.', '!', '?':
+			next := i + 1
+			if next < len(s) && (s[next] == ' ' || s[next] == '\t') {
+				return next
+			}
+		}
+	}
+	return -1
+}
+
+// reasoningDeltaText 提取 model.reasoning-delta（delta 字段）或 coding_agent.reasoning.chunk（data.text）中的文本
+func reasoningDeltaText(msg upstream.SSEMessage) string {
+	if delta, ok := msg.Event["delta"].(string); ok {
+		return delta
+	}
+	if data, ok := msg.Event["data"].(map[string]interface{}); ok {
+		text, _ := data["text"].(string)
+		return text
+	}
+	return ""
+}
+
+// handleReasoningStatus 在 reasoning_display 为 status 时处理被隐藏的思考事件，返回 true 表示已处理
+func (h *streamHandler) handleReasoningStatus(eventKey string, msg upstream.SSEMessage) bool {
+	if h.reasoningStatus == nil {
+		return false
+	}
+	var status string
+	var ok bool
+	switch eventKey {
+	case "model.reasoning-delta", "coding_agent.reasoning.chunk":
+		status, ok = h.reasoningStatus.add(reasoningDeltaText(msg))
+	case "model.reasoning-end", "coding_agent.reasoning.completed":
+		status, ok = h.reasoningStatus.flush()
+	default:
+		return false
+	}
+	if ok {
+		h.emitReasoningStatus(status)
+	}
+	return true
+}
+
+// emitReasoningStatus 以普通文本增量输出一行状态。状态不是回答内容，
+// 不影响 hasTextOutput（仍需要时会补发 Write 内容）
+func (h *streamHandler) emitReasoningStatus(status string) {
+	h.mu.Lock()
+	hadText := h.hasTextOutput
+	h.mu.Unlock()
+
+	line := "[" + status + "]\n\n"
+	if hadText || h.reasoningStatus.count > 1 {
+		line = "\n" + line
+	}
+	h.emitTextDelta(line)
+
+	h.mu.Lock()
+	h.hasTextOutput = hadText
+	h.mu.Unlock()
+}
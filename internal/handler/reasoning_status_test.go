@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"orchids-api/internal/adapter"
+	"orchids-api/internal/config"
+	"orchids-api/internal/debug"
+	"orchids-api/internal/upstream"
+)
+
+func TestSummarizeReasoning(t *testing.T) {
+	cases := []struct {
+		in       string
+		want     string
+		consumed int
+	}{
+		{"**Analyzing repository structure**\n\nI need to look at", "Analyzing repository structure…", 35},
+		{"Let me check the config. Then I will", "Let me check the config…", 24},
+		{"Reading go.mod to find the version", "Reading go.mod to find the version…", 0},
+		{"\n\n## 检查配置文件\n", "检查配置文件…", 24},
+		{"先看一下目录结构。然后", "先看一下目录结构…", 27},
+		{strings.Repeat("a", 100) + "\n", strings.Repeat("a", 80) + "…", 101},
+		{"   \n", "", 0},
+	}
+	for _, c := range cases {
+		got, consumed := summarizeReasoning(c.in)
+		if got != c.want || consumed != c.consumed {
+			t.Errorf("summarizeReasoning(%q) = %q, %d; want %q, %d", c.in, got, consumed, c.want, c.consumed)
+		}
+	}
+}
+
+func TestReasoningStatus_Interval(t *testing.T) {
+	now := time.Unix(0, 0)
+	s := newReasoningStatus(3 * time.Second)
+	s.now = func() time.Time { return now }
+
+	if _, ok := s.add("Looking at the"); ok {
+		t.Fatal("incomplete sentence should not emit")
+	}
+	if status, ok := s.add(" handler.\nNext"); !ok || status != "Looking at the handler…" {
+		t.Fatalf("first status = %q, %v", status, ok)
+	}
+	now = now.Add(time.Second)
+	if _, ok := s.add(" I will open the tests.\n"); ok {
+		t.Fatal("status emitted before interval elapsed")
+	}
+	now = now.Add(3 * time.Second)
+	if status, ok := s.add("Checking imports. Then the"); !ok || status != "Next I will open the tests…" {
+		t.Fatalf("second status = %q, %v", status, ok)
+	}
+	now = now.Add(3 * time.Second)
+	if status, ok := s.add(" docs."); !ok || status != "Checking imports…" {
+		t.Fatalf("third status = %q, %v", status, ok)
+	}
+	if _, ok := s.flush(); ok {
+		t.Fatal("flush should not emit after a status was already shown")
+	}
+}
+
+func TestStreamHandler_ReasoningStatus(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sh := newStreamHandler(&config.Config{}, rec, debug.New(false, false), true, true, adapter.FormatAnthropic, "")
+	sh.reasoningStatus = newReasoningStatus(time.Hour)
+	defer sh.release()
+
+	sh.handleMessage(upstream.SSEMessage{Type: "model", Event: map[string]interface{}{"type": "reasoning-start", "id": "0"}})
+	sh.handleMessage(upstream.SSEMessage{Type: "model", Event: map[string]interface{}{"type": "reasoning-delta", "id": "0", "delta": "Analyzing repository structure"}})
+	sh.handleMessage(upstream.SSEMessage{Type: "model", Event: map[string]interface{}{"type": "reasoning-end", "id": "0"}})
+	sh.handleMessage(upstream.SSEMessage{Type: "model", Event: map[string]interface{}{"type": "text-delta", "id": "1", "delta": "Done."}})
+
+	body := rec.Body.String()
+	if strings.Contains(body, "thinking") {
+		t.Fatalf("thinking block leaked:\n%s", body)
+	}
+	if !strings.Contains(body, `"text":"[Analyzing repository structure…]\n\n"`) {
+		t.Fatalf("status text missing:\n%s", body)
+	}
+	if !strings.Contains(body, `"text":"Done."`) {
+		t.Fatalf("answer text missing:\n%s", body)
+	}
+}
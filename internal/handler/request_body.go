@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/perf"
+)
+
+// maxBodyPreGrow 为按 Content-Length 预分配缓冲区的上限。Content-Length 由客户端声明，
+// 超出部分交给 ReadFrom 按实际读到的数据增长
+const maxBodyPreGrow = 64 * 1024
+
+// errRequestTooLarge 表示请求体超过 max_request_bytes
+var errRequestTooLarge = errors.New("request body too large")
+
+// maxRequestBytes 返回请求体上限，0 表示不限制
+func (h *Handler) maxRequestBytes() int64 {
+	if h.config == nil || h.config.MaxRequestBytes == 0 {
+		return config.DefaultMaxRequestBytes
+	}
+	if h.config.MaxRequestBytes < 0 {
+		return 0
+	}
+	return h.config.MaxRequestBytes
+}
+
+// readRequestBody 将请求体读入池化缓冲区，超过上限时返回 errRequestTooLarge。
+// Content-Length 已声明超限时直接拒绝，不读取请求体。调用方负责 perf.ReleaseByteBuffer。
+func (h *Handler) readRequestBody(w http.ResponseWriter, r *http.Request) (*bytes.Buffer, error) {
+	limit := h.maxRequestBytes()
+	if limit > 0 {
+		if r.ContentLength > limit {
+			return nil, errRequestTooLarge
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+	}
+
+	buf := perf.AcquireByteBuffer()
+	if r.ContentLength > 0 {
+		buf.Grow(int(min(r.ContentLength, maxBodyPreGrow)))
+	}
+	if _, err := buf.ReadFrom(r.Body); err != nil {
+		perf.ReleaseByteBuffer(buf)
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, errRequestTooLarge
+		}
+		return nil, err
+	}
+	return buf, nil
+}
+
+// decodeRequestBody 读取并解析 JSON 请求体，失败时已写出错误响应并返回 false。
+// 返回的缓冲区在 ok 为 true 时有效，使用完毕后需 perf.ReleaseByteBuffer。
+func (h *Handler) decodeRequestBody(w http.ResponseWriter, r *http.Request, v interface{}) (*bytes.Buffer, bool) {
+	buf, err := h.readRequestBody(w, r)
+	if err != nil {
+		if errors.Is(err, errRequestTooLarge) {
+			h.writeRequestTooLarge(w)
+			return nil, false
+		}
+		h.writeErrorResponse(w, "invalid_request_error", "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+	if err := json.Unmarshal(buf.Bytes(), v); err != nil {
+		perf.ReleaseByteBuffer(buf)
+		h.writeErrorResponse(w, "invalid_request_error", "Invalid request body", http.StatusBadRequest)
+		return nil, false
+	}
+	return buf, true
+}
+
+// writeRequestTooLarge 返回 413，并提示上限与常见的缩减方式
+func (h *Handler) writeRequestTooLarge(w http.ResponseWriter) {
+	msg := fmt.Sprintf("Request body exceeds the %s limit (max_request_bytes). Trim the conversation history or large tool results/images and retry.", formatByteSize(h.maxRequestBytes()))
+	h.writeErrorResponse(w, "request_too_large", msg, http.StatusRequestEntityTooLarge)
+}
+
+func formatByteSize(n int64) string {
+	switch {
+	case n >= 1<<20 && n%(1<<20) == 0:
+		return fmt.Sprintf("%d MB", n>>20)
+	case n >= 1<<10 && n%(1<<10) == 0:
+		return fmt.Sprintf("%d KB", n>>10)
+	default:
+		return fmt.Sprintf("%d bytes", n)
+	}
+}
@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/perf"
+)
+
+func TestHandleMessages_RejectsOversizedBody(t *testing.T) {
+	h := &Handler{config: &config.Config{MaxRequestBytes: 1024}}
+	body := makeWarpRequestBody(t, strings.Repeat("x", 4096), "")
+
+	cases := []struct {
+		name          string
+		contentLength int64
+	}{
+		{name: "declared length", contentLength: int64(len(body))},
+		{name: "chunked", contentLength: -1},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/orchids/v1/messages", bytes.NewReader(body))
+			req.ContentLength = tc.contentLength
+			rec := httptest.NewRecorder()
+			h.HandleMessages(rec, req)
+
+			if rec.Code != http.StatusRequestEntityTooLarge {
+				t.Fatalf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+			}
+			var resp struct {
+				Error struct {
+					Type    string `json:"type"`
+					Message string `json:"message"`
+				} `json:"error"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decode response: %v", err)
+			}
+			if resp.Error.Type != "request_too_large" || !strings.Contains(resp.Error.Message, "1 KB") {
+				t.Fatalf("unexpected error: %+v", resp.Error)
+			}
+		})
+	}
+}
+
+func TestHandleCountTokens_RejectsOversizedBody(t *testing.T) {
+	h := &Handler{config: &config.Config{MaxRequestBytes: 512}}
+	req := httptest.NewRequest(http.MethodPost, "/orchids/v1/messages/count_tokens", bytes.NewReader(makeWarpRequestBody(t, strings.Repeat("y", 2048), "")))
+	req.ContentLength = -1
+	rec := httptest.NewRecorder()
+	h.HandleCountTokens(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+}
+
+func TestMaxRequestBytes_Defaults(t *testing.T) {
+	if got := (&Handler{config: &config.Config{}}).maxRequestBytes(); got != config.DefaultMaxRequestBytes {
+		t.Fatalf("default limit = %d", got)
+	}
+	if got := (&Handler{config: &config.Config{MaxRequestBytes: -1}}).maxRequestBytes(); got != 0 {
+		t.Fatalf("disabled limit = %d", got)
+	}
+}
+
+func TestReadRequestBody_DoesNotTrustContentLength(t *testing.T) {
+	for _, limit := range []int64{0, -1} {
+		h := &Handler{config: &config.Config{MaxRequestBytes: limit}}
+		req := httptest.NewRequest(http.MethodPost, "/orchids/v1/messages", strings.NewReader(`{"model":"x"}`))
+		req.ContentLength = 40 << 20
+		if limit < 0 {
+			req.ContentLength = 80 << 20
+		}
+		buf, err := h.readRequestBody(httptest.NewRecorder(), req)
+		if err != nil {
+			t.Fatalf("limit %d: readRequestBody: %v", limit, err)
+		}
+		if buf.String() != `{"model":"x"}` {
+			t.Fatalf("limit %d: body = %q", limit, buf.String())
+		}
+		if buf.Cap() > 1<<20 {
+			t.Fatalf("limit %d: buffer pre-grown to %d bytes from Content-Length", limit, buf.Cap())
+		}
+		perf.ReleaseByteBuffer(buf)
+	}
+}
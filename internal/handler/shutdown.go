@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"orchids-api/internal/adapter"
+)
+
+// errServerShutdown 作为 context cause，表示服务关闭时仍未结束的请求被中止
+var errServerShutdown = errors.New("server shutting down")
+
+// BeginShutdown 进入关闭流程：新的消息请求立即返回 503 与 Retry-After，进行中的请求最多再运行 grace，
+// 之后被取消，流式响应收到一条带 retry_after 的 error 事件后正常结束，而不是在连接关闭时被截断。
+// 需在 http.Server.Shutdown 之前调用，grace 应小于 Shutdown 的超时
+func (h *Handler) BeginShutdown(grace, retryAfter time.Duration) {
+	h.shutdownMu.Lock()
+	if h.draining {
+		h.shutdownMu.Unlock()
+		return
+	}
+	h.draining = true
+	h.retryAfter = retryAfter
+	_, cancel := h.shutdownContextLocked()
+	h.shutdownMu.Unlock()
+
+	h.inflightMu.Lock()
+	n := len(h.inflight)
+	h.inflightMu.Unlock()
+	slog.Info("Handler draining, rejecting new requests", "inflight", n, "grace", grace, "retry_after", retryAfter)
+	if grace <= 0 {
+		cancel()
+		return
+	}
+	time.AfterFunc(grace, cancel)
+}
+
+// shuttingDown 返回是否已进入关闭流程及建议的重试间隔
+func (h *Handler) shuttingDown() (bool, time.Duration) {
+	h.shutdownMu.Lock()
+	defer h.shutdownMu.Unlock()
+	return h.draining, h.retryAfter
+}
+
+// shutdownContext 返回在关闭宽限期结束时取消的 context，进行中的请求据此中止
+func (h *Handler) shutdownContext() context.Context {
+	h.shutdownMu.Lock()
+	defer h.shutdownMu.Unlock()
+	ctx, _ := h.shutdownContextLocked()
+	return ctx
+}
+
+func (h *Handler) shutdownContextLocked() (context.Context, context.CancelFunc) {
+	if h.shutdownCtx == nil {
+		h.shutdownCtx, h.shutdownCancel = context.WithCancel(context.Background())
+	}
+	return h.shutdownCtx, h.shutdownCancel
+}
+
+// writeShuttingDown 拒绝关闭流程中到达的新请求
+func (h *Handler) writeShuttingDown(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(retryAfterSeconds(retryAfter)))
+	h.writeErrorResponse(w, "overloaded_error", "Server is shutting down, retry on another instance", http.StatusServiceUnavailable)
+}
+
+func retryAfterSeconds(d time.Duration) int {
+	return max(int((d+time.Second-1)/time.Second), 1)
+}
+
+// InjectShutdownError 通知客户端请求因服务关闭被中止，retry_after 为建议的重试间隔（秒）
+func (h *streamHandler) InjectShutdownError(retryAfter time.Duration) {
+	secs := retryAfterSeconds(retryAfter)
+	errorMsg := fmt.Sprintf("Request aborted: server is shutting down, retry after %ds", secs)
+	if !h.isStream || h.responseFormat == adapter.FormatOpenAI {
+		h.InjectErrorText("Injecting shutdown error to client", errorMsg)
+		return
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"type": "error",
+		"error": map[string]interface{}{
+			"type":        "overloaded_error",
+			"message":     errorMsg,
+			"retry_after": secs,
+		},
+	})
+	h.writeSSE("error", string(data))
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/prompt"
+)
+
+func TestBeginShutdown_EndsStreamsWithRetryHint(t *testing.T) {
+	client := &blockingClient{started: make(chan struct{})}
+	h := &Handler{
+		config:            &config.Config{},
+		client:            client,
+		sessionWorkdirs:   map[string]string{},
+		sessionConvIDs:    map[string]string{},
+		sessionLastAccess: map[string]time.Time{},
+		recentRequests:    map[string]*recentRequest{},
+	}
+	body, err := json.Marshal(ClaudeRequest{
+		Model:    "claude-opus-4-6",
+		Messages: []prompt.Message{{Role: "user", Content: prompt.MessageContent{Text: "hello"}}},
+		Stream:   true,
+	})
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		h.HandleMessages(rec, httptest.NewRequest(http.MethodPost, "/orchids/v1/messages", bytes.NewReader(body)))
+		close(done)
+	}()
+	select {
+	case <-client.started:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("upstream request was not started")
+	}
+
+	h.BeginShutdown(50*time.Millisecond, 7*time.Second)
+
+	// 宽限期内到达的新请求立即拒绝
+	rejected := httptest.NewRecorder()
+	h.HandleMessages(rejected, httptest.NewRequest(http.MethodPost, "/orchids/v1/messages", bytes.NewReader(body)))
+	if rejected.Code != http.StatusServiceUnavailable || rejected.Header().Get("Retry-After") != "7" {
+		t.Fatalf("new request during shutdown: %d Retry-After=%q", rejected.Code, rejected.Header().Get("Retry-After"))
+	}
+
+	select {
+	case <-done:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("in-flight stream was not ended after the grace period")
+	}
+	out := rec.Body.String()
+	if !strings.Contains(out, "event: error") || !strings.Contains(out, `"retry_after":7`) || !strings.Contains(out, "overloaded_error") {
+		t.Fatalf("expected shutdown error event, got %q", out)
+	}
+	if !strings.Contains(out, "event: message_stop") {
+		t.Fatalf("expected stream to be finished, got %q", out)
+	}
+}
@@ -4,10 +4,13 @@ import (
 	"encoding/json"
 	"fmt"
 	"hash/fnv"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"orchids-api/internal/adapter"
@@ -15,6 +18,7 @@ import (
 	"orchids-api/internal/debug"
 	"orchids-api/internal/perf"
 	"orchids-api/internal/prompt"
+	"orchids-api/internal/sseconform"
 	"orchids-api/internal/tiktoken"
 	"orchids-api/internal/upstream"
 )
@@ -48,6 +52,7 @@ type streamHandler struct {
 	activeTextBlockIndex     int
 	activeTextSSEIndex       int
 	activeBlockType          string // "thinking", "text", "tool_use"
+	sseChunks                int    // 已写出的 SSE 帧数（含 ping 与 [DONE]）
 
 	// Buffers and Builders
 	responseText          *strings.Builder
@@ -76,13 +81,28 @@ type streamHandler struct {
 	toolDedupCount     int
 	toolDedupKeys      map[string]int
 	introDedup         map[string]struct{}
+	toolChoice         *ToolChoice
+	forcedToolCalled   bool // tool_choice 为 tool 时指定工具是否已被调用
 
 	// Throttling
 	lastScanTime time.Time
+	lastActivity atomic.Int64 // 最近一次上游消息的 UnixNano，供卡顿看门狗使用
+
+	// reasoningStatus 非 nil 时（reasoning_display 为 status）将被隐藏的思考内容转换为状态文本
+	reasoningStatus *reasoningStatus
+	// outputFilter 非 nil 时（output_filter 为 mask）模型输出的文本、tool_use 输入在写出前脱敏；
+	// thinkingFilter 与其共享规则和计数，单独暂存 thinking 块的内容
+	outputFilter   *outputFilterState
+	thinkingFilter *outputFilterState
 
 	// Callbacks
 	onConversationID func(string) // 上游返回 conversationID 时回调
 
+	// conformance 仅在 debug 模式的 Anthropic 流式响应中启用，逐帧校验并修复事件序列
+	conformance *sseconform.AnthropicValidator
+	// openAIConformance 在 openai_sse_conformance 开启时校验并修复 OpenAI chunk
+	openAIConformance *sseconform.OpenAIValidator
+
 	// Logger
 	logger *debug.Logger
 }
@@ -145,6 +165,12 @@ func newStreamHandler(
 		activeTextSSEIndex:       -1,
 		activeBlockType:          "",
 	}
+	if cfg.DebugEnabled && isStream && responseFormat == adapter.FormatAnthropic {
+		h.conformance = sseconform.NewAnthropicValidator()
+	}
+	if cfg.OpenAISSEConformance && isStream && responseFormat == adapter.FormatOpenAI {
+		h.openAIConformance = sseconform.NewOpenAIValidator()
+	}
 	return h
 }
 
@@ -179,25 +205,127 @@ func (h *streamHandler) writeSSE(event, data string) {
 		return
 	}
 
-	if _, err := fmt.Fprintf(h.w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+	if err := h.writeFrameLocked(event, data, nil); err != nil {
 		h.markWriteErrorLocked(event, err)
 		return
 	}
+
+	h.logger.LogOutputSSE(event, data)
+}
+
+// writeSSEBytes 与 writeSSE 相同，但直接接收已编码的 JSON，避免热路径上的 string 拷贝
+func (h *streamHandler) writeSSEBytes(event string, data []byte) {
+	if !h.isStream {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.hasReturn {
+		return
+	}
+	if h.responseFormat == adapter.FormatOpenAI {
+		if err := h.writeOpenAIChunkLocked(event, data); err != nil {
+			h.markWriteErrorLocked(event, err)
+		}
+		return
+	}
+
+	if err := h.writeFrameLocked(event, "", data); err != nil {
+		h.markWriteErrorLocked(event, err)
+		return
+	}
+	if h.logger.SSEEnabled() {
+		h.logger.LogOutputSSE(event, string(data))
+	}
+}
+
+// writeFrameLocked 写出一个 Anthropic 帧，启用 conformance 时先经校验器修复或丢弃不合规的帧。
+// data 与 raw 二选一：raw 非 nil 时优先使用。调用方需持有 h.mu。
+func (h *streamHandler) writeFrameLocked(event, data string, raw []byte) error {
+	if h.conformance == nil {
+		return h.writeRawFrameLocked(event, data, raw)
+	}
+	if raw == nil {
+		raw = []byte(data)
+	}
+	frames, err := h.conformance.Repair(event, raw)
+	if err != nil {
+		slog.Warn("SSE 事件序列不合规，已修复或丢弃", "event", event, "error", err, "frames", len(frames))
+	}
+	for _, frame := range frames {
+		if err := h.writeRawFrameLocked(frame.Event, "", frame.Data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeRawFrameLocked 将 "event: ...\ndata: ...\n\n" 组装进池化缓冲区后一次写出并 flush
+func (h *streamHandler) writeRawFrameLocked(event, data string, raw []byte) error {
+	buf := perf.AcquireByteBuffer()
+	defer perf.ReleaseByteBuffer(buf)
+	buf.WriteString("event: ")
+	buf.WriteString(event)
+	buf.WriteString("\ndata: ")
+	if raw != nil {
+		buf.Write(raw)
+	} else {
+		buf.WriteString(data)
+	}
+	buf.WriteString("\n\n")
+	if _, err := h.w.Write(buf.Bytes()); err != nil {
+		return err
+	}
+	h.sseChunks++
 	if h.flusher != nil {
 		h.flusher.Flush()
 	}
-
-	h.logger.LogOutputSSE(event, data)
+	return nil
 }
 
 func (h *streamHandler) writeOpenAISSE(event, data string) error {
-	bytes, ok := adapter.BuildOpenAIChunk(h.msgID, h.startTime.Unix(), event, []byte(data))
+	buf := perf.AcquireByteBuffer()
+	defer perf.ReleaseByteBuffer(buf)
+	buf.WriteString(data)
+	return h.writeOpenAIChunkLocked(event, buf.Bytes())
+}
+
+func (h *streamHandler) writeOpenAIChunkLocked(event string, data []byte) error {
+	chunk, ok := adapter.BuildOpenAIChunk(h.msgID, h.startTime.Unix(), event, data)
 	if !ok {
 		return nil
 	}
-	if _, err := fmt.Fprintf(h.w, "data: %s\n\n", string(bytes)); err != nil {
+	return h.writeOpenAIDataLocked(chunk)
+}
+
+// writeOpenAIDataLocked 写出一个 "data: ..." 帧（chunk JSON 或 [DONE]），
+// 开启 openai_sse_conformance 时先经校验器修复。调用方需持有 h.mu。
+func (h *streamHandler) writeOpenAIDataLocked(data []byte) error {
+	if h.openAIConformance == nil {
+		return h.writeOpenAIFrameLocked(data)
+	}
+	frames, err := h.openAIConformance.Repair(data)
+	if err != nil {
+		slog.Debug("OpenAI chunk 不合规，已修复或丢弃", "error", err, "frames", len(frames))
+	}
+	for _, frame := range frames {
+		if err := h.writeOpenAIFrameLocked(frame); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *streamHandler) writeOpenAIFrameLocked(data []byte) error {
+	buf := perf.AcquireByteBuffer()
+	defer perf.ReleaseByteBuffer(buf)
+	buf.WriteString("data: ")
+	buf.Write(data)
+	buf.WriteString("\n\n")
+	if _, err := h.w.Write(buf.Bytes()); err != nil {
 		return err
 	}
+	h.sseChunks++
 	if h.flusher != nil {
 		h.flusher.Flush()
 	}
@@ -218,24 +346,17 @@ func (h *streamHandler) writeFinalSSE(event, data string) {
 		}
 		// Send [DONE] at the very end
 		if event == "message_stop" {
-			if _, err := fmt.Fprintf(h.w, "data: [DONE]\n\n"); err != nil {
+			if err := h.writeOpenAIDataLocked([]byte(sseconform.OpenAIDone)); err != nil {
 				h.markWriteErrorLocked(event, err)
-				return
-			}
-			if h.flusher != nil {
-				h.flusher.Flush()
 			}
 		}
 		return
 	}
 
-	if _, err := fmt.Fprintf(h.w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+	if err := h.writeFrameLocked(event, data, nil); err != nil {
 		h.markWriteErrorLocked(event, err)
 		return
 	}
-	if h.flusher != nil {
-		h.flusher.Flush()
-	}
 
 	h.logger.LogOutputSSE(event, data)
 }
@@ -249,7 +370,11 @@ func (h *streamHandler) writeKeepAlive() {
 	if h.hasReturn {
 		return
 	}
-	if _, err := fmt.Fprintf(h.w, ": keep-alive\n\n"); err != nil {
+	frame := keepAliveComment
+	if h.responseFormat != adapter.FormatOpenAI && h.config != nil && strings.EqualFold(h.config.KeepAliveMode, "event") {
+		frame = keepAliveEvent
+	}
+	if _, err := io.WriteString(h.w, frame); err != nil {
 		h.markWriteErrorLocked("keep-alive", err)
 		return
 	}
@@ -495,7 +620,7 @@ func sanitizeToolInput(name, input string) string {
 func (h *streamHandler) emitToolCallNonStream(call toolCall) {
 	h.addOutputTokens(call.name)
 	h.addOutputTokens(call.input)
-	inputJSON := strings.TrimSpace(call.input)
+	inputJSON := h.filterToolInput(strings.TrimSpace(call.input))
 	if inputJSON == "" {
 		inputJSON = "{}"
 	}
@@ -524,7 +649,7 @@ func (h *streamHandler) emitToolCallStream(call toolCall, idx int, write func(ev
 
 	h.addOutputTokens(call.name)
 	h.addOutputTokens(call.input)
-	inputJSON := strings.TrimSpace(call.input)
+	inputJSON := h.filterToolInput(strings.TrimSpace(call.input))
 	if inputJSON == "" {
 		inputJSON = "{}"
 	}
@@ -583,11 +708,14 @@ func (h *streamHandler) emitToolUseFromInput(toolID, toolName, inputStr string)
 	h.mu.Unlock()
 
 	h.addOutputTokens(toolName)
-	inputJSON := strings.TrimSpace(inputStr)
+	inputJSON := h.filterToolInput(strings.TrimSpace(inputStr))
 	if inputJSON == "" {
 		inputJSON = "{}"
 	}
 
+	// 上游可能缺失 text-end/reasoning-end，先关闭仍打开的块，保证 start/stop 成对
+	h.closeActiveBlock()
+
 	h.mu.Lock()
 	h.blockIndex++
 	idx := h.blockIndex
@@ -668,20 +796,28 @@ func (h *streamHandler) finishResponse(stopReason string) {
 	h.hasReturn = true
 	h.finalStopReason = stopReason
 	h.mu.Unlock()
+	if stopReason != "tool_use" && h.toolChoice.forcesTool() {
+		slog.Warn("tool_choice 要求调用工具，但上游未调用", "tool_choice", h.toolChoice.Type, "tool", h.toolChoice.Name, "stop_reason", stopReason)
+	}
 
 	if h.isStream {
 		var blockStopData string
 		h.mu.Lock()
+		tailData, hasTail := h.drainOutputFilterLocked()
 		if stopData, ok := h.popActiveBlockStopDataLocked(); ok {
 			blockStopData = stopData
 		}
 		h.mu.Unlock()
+		if hasTail {
+			h.writeFinalSSE("content_block_delta", tailData)
+		}
 		if blockStopData != "" {
 			h.writeFinalSSE("content_block_stop", blockStopData)
 		}
 		if stopReason != "tool_use" {
 			h.emitWriteChunkFallbackIfNeeded(h.writeFinalSSE)
 		}
+		h.emitAttributionFooter(stopReason, h.writeFinalSSE)
 		h.flushPendingToolCalls(stopReason, h.writeFinalSSE)
 		h.finalizeOutputTokens()
 		deltaMap := perf.AcquireMap()
@@ -712,6 +848,9 @@ func (h *streamHandler) finishResponse(stopReason string) {
 		}
 		perf.ReleaseMap(stopMap)
 	} else {
+		h.mu.Lock()
+		h.drainOutputFilterLocked()
+		h.mu.Unlock()
 		if stopReason != "tool_use" {
 			h.emitWriteChunkFallbackIfNeeded(h.writeFinalSSE)
 		}
@@ -863,6 +1002,9 @@ func (h *streamHandler) popActiveBlockStopDataLocked() (string, bool) {
 }
 
 func (h *streamHandler) closeActiveBlockLocked() {
+	if tailData, ok := h.drainOutputFilterLocked(); ok {
+		h.writeSSELocked("content_block_delta", tailData)
+	}
 	stopData, ok := h.popActiveBlockStopDataLocked()
 	if !ok {
 		return
@@ -883,13 +1025,10 @@ func (h *streamHandler) writeSSELocked(event, data string) {
 		}
 		return
 	}
-	if _, err := fmt.Fprintf(h.w, "event: %s\ndata: %s\n\n", event, data); err != nil {
+	if err := h.writeFrameLocked(event, data, nil); err != nil {
 		h.markWriteErrorLocked(event, err)
 		return
 	}
-	if h.flusher != nil {
-		h.flusher.Flush()
-	}
 	h.logger.LogOutputSSE(event, data)
 	// Log to slog only when debug enabled
 	if h.config != nil && h.config.DebugEnabled {
@@ -965,6 +1104,7 @@ func (h *streamHandler) emitWriteChunkFallbackIfNeeded(write func(event, data st
 	text := h.writeChunkBuffer.String()
 	h.hasTextOutput = true
 	h.mu.Unlock()
+	text = h.filterText(text)
 
 	if h.isStream {
 		h.emitTextBlockWithWriter(text, write)
@@ -995,6 +1135,9 @@ func (h *streamHandler) shouldAcceptToolCall(call toolCall) bool {
 		}
 		return false
 	}
+	if !h.toolChoiceAllows(call.name) {
+		return false
+	}
 	if key := sideEffectToolDedupKey(nameKey, call.input); key != "" {
 		maskedKey := maskDedupKey(key)
 		h.mu.Lock()
@@ -1289,6 +1432,7 @@ func extractEventMessage(event map[string]interface{}, fallback string) string {
 }
 
 func (h *streamHandler) handleMessage(msg upstream.SSEMessage) {
+	h.touchActivity()
 	if h.config.DebugEnabled && msg.Type != "content_block_delta" {
 		slog.Debug("Incoming SSE", "type", msg.Type)
 	}
@@ -1315,6 +1459,9 @@ func (h *streamHandler) handleMessage(msg upstream.SSEMessage) {
 		}
 	}
 	if h.suppressThinking {
+		if h.handleReasoningStatus(eventKey, msg) {
+			return
+		}
 		if strings.HasPrefix(eventKey, "model.reasoning-") ||
 			strings.HasPrefix(eventKey, "coding_agent.reasoning") ||
 			eventKey == "coding_agent.start" ||
@@ -1412,6 +1559,9 @@ func (h *streamHandler) handleMessage(msg upstream.SSEMessage) {
 		if h.isStream {
 			h.addOutputTokens(delta)
 		}
+		if delta = h.filterThinkingDelta(delta); delta == "" {
+			return
+		}
 		// Always update internal state for history
 		h.mu.Lock()
 		if internalIdx >= 0 && internalIdx < len(h.contentBlocks) {
@@ -1469,6 +1619,9 @@ func (h *streamHandler) handleMessage(msg upstream.SSEMessage) {
 			h.mu.Unlock()
 		}
 		h.addOutputTokens(delta)
+		if delta = h.filterTextDelta(delta); delta == "" {
+			return
+		}
 		if !h.isStream {
 			h.responseText.WriteString(delta)
 		}
@@ -1508,7 +1661,7 @@ func (h *streamHandler) handleMessage(msg upstream.SSEMessage) {
 		}
 		if h.isStream {
 			data, _ := json.Marshal(msg.Event)
-			h.writeSSE(msg.Type, string(data))
+			h.writeSSE(msg.Type, h.filterPassthroughEvent(data))
 		}
 		return
 
@@ -1532,7 +1685,7 @@ func (h *streamHandler) handleMessage(msg upstream.SSEMessage) {
 				h.emitThinkingDelta(fmt.Sprintf("\n[%s %s...]\n", op, path))
 
 				rawData, _ := json.Marshal(msg.Event)
-				h.writeSSE(msg.Type, string(rawData))
+				h.writeSSE(msg.Type, h.filterPassthroughEvent(rawData))
 			}
 		}
 		return
@@ -1558,7 +1711,7 @@ func (h *streamHandler) handleMessage(msg upstream.SSEMessage) {
 			}
 			if !h.suppressThinking {
 				rawData, _ := json.Marshal(msg.Event)
-				h.writeSSE(msg.Type, string(rawData))
+				h.writeSSE(msg.Type, h.filterPassthroughEvent(rawData))
 			}
 		}
 		return
@@ -1568,7 +1721,7 @@ func (h *streamHandler) handleMessage(msg upstream.SSEMessage) {
 			if !h.suppressThinking {
 				h.emitThinkingDelta("\n[Done]\n")
 				data, _ := json.Marshal(msg.Event)
-				h.writeSSE(msg.Type, string(data))
+				h.writeSSE(msg.Type, h.filterPassthroughEvent(data))
 			}
 		}
 		return
@@ -1588,7 +1741,7 @@ func (h *streamHandler) handleMessage(msg upstream.SSEMessage) {
 		}
 		if h.isStream {
 			data, _ := json.Marshal(msg.Event)
-			h.writeSSE(msg.Type, string(data))
+			h.writeSSE(msg.Type, h.filterPassthroughEvent(data))
 		} else {
 			h.writeKeepAlive()
 		}
@@ -1798,6 +1951,9 @@ func (h *streamHandler) emitThinkingDelta(delta string) {
 	}
 
 	h.addOutputTokens(delta)
+	if delta = h.filterThinkingDelta(delta); delta == "" {
+		return
+	}
 
 	h.mu.Lock()
 	if internalIdx >= 0 && internalIdx < len(h.contentBlocks) {
@@ -1810,17 +1966,52 @@ func (h *streamHandler) emitThinkingDelta(delta string) {
 	}
 	h.mu.Unlock()
 
-	m := perf.AcquireMap()
-	m["type"] = "content_block_delta"
-	m["index"] = sseIdx
-	deltaMap := perf.AcquireMap()
-	deltaMap["type"] = "thinking_delta"
-	deltaMap["thinking"] = delta
-	m["delta"] = deltaMap
-	data, _ := json.Marshal(m)
-	h.writeSSE("content_block_delta", string(data))
-	perf.ReleaseMap(deltaMap)
-	perf.ReleaseMap(m)
+	h.writeDeltaEvent(sseIdx, "thinking_delta", "thinking", delta)
+}
+
+// writeDeltaEvent 直接在池化缓冲区中编码 content_block_delta，
+// 字段顺序与 json.Marshal(map) 一致：{"delta":{"<field>":...,"type":...},"index":N,"type":"content_block_delta"}
+func (h *streamHandler) writeDeltaEvent(index int, deltaType, field, value string) {
+	if !h.isStream {
+		return
+	}
+	if h.responseFormat == adapter.FormatOpenAI {
+		h.writeOpenAIDelta(deltaType, value)
+		return
+	}
+	buf := perf.AcquireByteBuffer()
+	defer perf.ReleaseByteBuffer(buf)
+	buf.WriteString(`{"delta":{"`)
+	buf.WriteString(field)
+	buf.WriteString(`":`)
+	perf.WriteJSONString(buf, value)
+	buf.WriteString(`,"type":"`)
+	buf.WriteString(deltaType)
+	buf.WriteString(`"},"index":`)
+	var num [20]byte
+	buf.Write(strconv.AppendInt(num[:0], int64(index), 10))
+	buf.WriteString(`,"type":"content_block_delta"}`)
+	h.writeSSEBytes("content_block_delta", buf.Bytes())
+}
+
+// writeOpenAIDelta 为 OpenAI 格式直接生成 chat.completion.chunk，跳过 Anthropic 事件的编码与再解析
+func (h *streamHandler) writeOpenAIDelta(deltaType, value string) {
+	field := "content"
+	if deltaType == "thinking_delta" {
+		field = "reasoning_content"
+	}
+	buf := perf.AcquireByteBuffer()
+	defer perf.ReleaseByteBuffer(buf)
+	adapter.WriteOpenAIDeltaChunk(buf, h.msgID, h.startTime.Unix(), field, value)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.hasReturn {
+		return
+	}
+	if err := h.writeOpenAIDataLocked(buf.Bytes()); err != nil {
+		h.markWriteErrorLocked("content_block_delta", err)
+	}
 }
 
 func (h *streamHandler) emitTextDelta(delta string) {
@@ -1842,6 +2033,9 @@ func (h *streamHandler) emitTextDelta(delta string) {
 	}
 
 	h.addOutputTokens(delta)
+	if delta = h.filterTextDelta(delta); delta == "" {
+		return
+	}
 
 	h.mu.Lock()
 	if internalIdx >= 0 && internalIdx < len(h.contentBlocks) {
@@ -1854,17 +2048,7 @@ func (h *streamHandler) emitTextDelta(delta string) {
 	}
 	h.mu.Unlock()
 
-	m := perf.AcquireMap()
-	m["type"] = "content_block_delta"
-	m["index"] = sseIdx
-	deltaMap := perf.AcquireMap()
-	deltaMap["type"] = "text_delta"
-	deltaMap["text"] = delta
-	m["delta"] = deltaMap
-	data, _ := json.Marshal(m)
-	h.writeSSE("content_block_delta", string(data))
-	perf.ReleaseMap(deltaMap)
-	perf.ReleaseMap(m)
+	h.writeDeltaEvent(sseIdx, "text_delta", "text", delta)
 }
 
 // InjectErrorText injects an error message as a text delta into the stream or buffer.
@@ -1875,6 +2059,13 @@ func (h *streamHandler) InjectErrorText(logMsg, errorMsg string) {
 	h.markTextOutput()
 	idx := h.ensureBlock("text")
 	internalIdx := h.activeTextBlockIndex
+	// 先写出过滤器暂存的模型输出，保持与错误提示的先后顺序
+	h.mu.Lock()
+	tailData, hasTail := h.drainOutputFilterLocked()
+	h.mu.Unlock()
+	if hasTail {
+		h.writeSSE("content_block_delta", tailData)
+	}
 
 	if h.isStream {
 		m := perf.AcquireMap()
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"orchids-api/internal/adapter"
+	"orchids-api/internal/config"
+	"orchids-api/internal/debug"
+	"orchids-api/internal/sseconform"
+	"orchids-api/internal/upstream"
+)
+
+// checkOpenAIStream 校验 OpenAI 流式输出：每个 data 帧都是合法 JSON，且以 [DONE] 结尾
+func checkOpenAIStream(body string) error {
+	done := false
+	for i, frame := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		if strings.HasPrefix(frame, ":") {
+			continue
+		}
+		if !strings.HasPrefix(frame, "data: ") {
+			return fmt.Errorf("frame %d malformed: %q", i, frame)
+		}
+		if done {
+			return fmt.Errorf("frame %d after [DONE]", i)
+		}
+		data := strings.TrimPrefix(frame, "data: ")
+		if data == "[DONE]" {
+			done = true
+			continue
+		}
+		if !json.Valid([]byte(data)) {
+			return fmt.Errorf("frame %d invalid json: %q", i, data)
+		}
+	}
+	if !done {
+		return fmt.Errorf("stream missing [DONE]")
+	}
+	return nil
+}
+
+// FuzzStreamHandlerTranscript 将任意上游事件序列（JSONL，每行 {"type","event"}）送入 streamHandler，
+// 要求输出始终是结构合法的 SSE 流。
+func FuzzStreamHandlerTranscript(f *testing.F) {
+	transcripts, _ := filepath.Glob(filepath.Join("testdata", "golden", "*.jsonl"))
+	for _, path := range transcripts {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			f.Fatalf("read %s: %v", path, err)
+		}
+		f.Add(data, false)
+		f.Add(data, true)
+	}
+	f.Add([]byte(`{"type":"model","event":{"type":"tool-input-start","id":"t1","toolName":"Read"}}
+{"type":"model","event":{"type":"tool-input-delta","id":"t1","delta":"{\"file_path\""}}
+{"type":"model","event":{"type":"text-delta","id":"0","delta":"mid"}}`), false)
+
+	// 每次执行都会打印强制结束的告警，fuzz 时静默日志避免刷屏
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	f.Cleanup(func() { slog.SetDefault(prev) })
+
+	f.Fuzz(func(t *testing.T, data []byte, openai bool) {
+		format := adapter.FormatAnthropic
+		if openai {
+			format = adapter.FormatOpenAI
+		}
+		rec := httptest.NewRecorder()
+		sh := newStreamHandler(&config.Config{}, rec, debug.New(false, false), false, true, format, "")
+		defer sh.release()
+		testMessageStart(sh)
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+		for scanner.Scan() {
+			var entry struct {
+				Type  string                 `json:"type"`
+				Event map[string]interface{} `json:"event"`
+			}
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil || entry.Type == "" {
+				continue
+			}
+			sh.handleMessage(upstream.SSEMessage{Type: entry.Type, Event: entry.Event})
+		}
+		sh.forceFinishIfMissing()
+		sh.finishResponse("end_turn")
+
+		check := sseconform.ValidateAnthropic
+		if openai {
+			check = checkOpenAIStream
+		}
+		if err := check(rec.Body.String()); err != nil {
+			t.Fatalf("%v\n%s", err, rec.Body.String())
+		}
+	})
+}
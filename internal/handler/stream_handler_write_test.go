@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"orchids-api/internal/adapter"
+	"orchids-api/internal/config"
+	"orchids-api/internal/debug"
+	"orchids-api/internal/sseconform"
+	"orchids-api/internal/testing/golden"
+	"orchids-api/internal/upstream"
+)
+
+// discardResponseWriter 丢弃输出，用于测量 SSE 写路径自身的分配
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (d *discardResponseWriter) Header() http.Header {
+	if d.header == nil {
+		d.header = make(http.Header)
+	}
+	return d.header
+}
+func (d *discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (d *discardResponseWriter) WriteHeader(int)             {}
+func (d *discardResponseWriter) Flush()                      {}
+
+func TestWriteDeltaEvent_MatchesJSONMarshal(t *testing.T) {
+	inputs := []string{
+		"plain",
+		"quote \" backslash \\ newline \n tab \t",
+		"<html> & 'single'",
+		"control \x01\x1f",
+		"unicode 中文 emoji 🎉 sep \u2028\u2029",
+		"invalid \xff\xfe utf8",
+	}
+	for _, in := range inputs {
+		rec := httptest.NewRecorder()
+		sh := newStreamHandler(&config.Config{}, rec, debug.New(false, false), false, true, adapter.FormatAnthropic, "")
+		sh.writeDeltaEvent(3, "text_delta", "text", in)
+		sh.release()
+
+		want, err := json.Marshal(map[string]interface{}{
+			"type":  "content_block_delta",
+			"index": 3,
+			"delta": map[string]interface{}{"type": "text_delta", "text": in},
+		})
+		if err != nil {
+			t.Fatalf("marshal: %v", err)
+		}
+		got := strings.TrimSuffix(strings.TrimPrefix(rec.Body.String(), "event: content_block_delta\ndata: "), "\n\n")
+		if got != string(want) {
+			t.Fatalf("input %q:\n got  %s\n want %s", in, got, want)
+		}
+	}
+}
+
+func TestWriteOpenAIDelta_MatchesBuildOpenAIChunk(t *testing.T) {
+	cases := []struct{ deltaType, field string }{
+		{"text_delta", "text"},
+		{"thinking_delta", "thinking"},
+	}
+	for _, tc := range cases {
+		in := "mixed <b>&\"quoted\"</b>\n中文"
+		rec := httptest.NewRecorder()
+		sh := newStreamHandler(&config.Config{}, rec, debug.New(false, false), false, true, adapter.FormatOpenAI, "")
+		sh.writeDeltaEvent(0, tc.deltaType, tc.field, in)
+
+		event, _ := json.Marshal(map[string]interface{}{
+			"type":  "content_block_delta",
+			"index": 0,
+			"delta": map[string]interface{}{"type": tc.deltaType, tc.field: in},
+		})
+		want, ok := adapter.BuildOpenAIChunk(sh.msgID, sh.startTime.Unix(), "content_block_delta", event)
+		sh.release()
+		if !ok {
+			t.Fatalf("BuildOpenAIChunk rejected %s", tc.deltaType)
+		}
+		if got := rec.Body.String(); got != "data: "+string(want)+"\n\n" {
+			t.Fatalf("%s:\n got  %q\n want %q", tc.deltaType, got, "data: "+string(want)+"\n\n")
+		}
+	}
+}
+
+func benchmarkTextDelta(b *testing.B, format adapter.ResponseFormat) {
+	cfg := &config.Config{OutputTokenMode: "final"}
+	sh := newStreamHandler(cfg, &discardResponseWriter{}, debug.New(false, false), false, true, format, "")
+	defer sh.release()
+	sh.ensureBlock("text")
+	const delta = "func main() {\n\tfmt.Println(\"hello <world> & friends\")\n}\n"
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sh.emitTextDelta(delta)
+	}
+}
+
+func BenchmarkEmitTextDelta_Anthropic(b *testing.B) {
+	benchmarkTextDelta(b, adapter.FormatAnthropic)
+}
+
+func BenchmarkEmitTextDelta_OpenAI(b *testing.B) {
+	benchmarkTextDelta(b, adapter.FormatOpenAI)
+}
+
+func BenchmarkWriteSSE(b *testing.B) {
+	sh := newStreamHandler(&config.Config{}, &discardResponseWriter{}, debug.New(false, false), false, true, adapter.FormatAnthropic, "")
+	defer sh.release()
+	data := `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hello"}}`
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		sh.writeSSE("content_block_delta", data)
+	}
+}
+
+func TestStreamHandler_DebugConformanceRepairsFrames(t *testing.T) {
+	rec := httptest.NewRecorder()
+	sh := newStreamHandler(&config.Config{DebugEnabled: true}, rec, debug.New(false, false), false, true, adapter.FormatAnthropic, "")
+	defer sh.release()
+
+	testMessageStart(sh)
+	sh.ensureBlock("text")
+	sh.writeDeltaEvent(5, "text_delta", "text", "stray")
+	sh.emitTextDelta("hello")
+	// 重复的 message_start 与未关闭块上的 message_stop
+	testMessageStart(sh)
+	sh.writeSSE("message_stop", `{"type":"message_stop"}`)
+	sh.finishResponse("end_turn")
+
+	body := rec.Body.String()
+	if err := sseconform.ValidateAnthropic(body); err != nil {
+		t.Fatalf("debug stream not repaired: %v\n%s", err, body)
+	}
+	if strings.Contains(body, "stray") || !strings.Contains(body, "hello") {
+		t.Fatalf("unexpected repaired stream:\n%s", body)
+	}
+}
+
+func TestStreamHandler_OpenAIConformance(t *testing.T) {
+	transcripts, err := filepath.Glob(filepath.Join("testdata", "golden", "*.jsonl"))
+	if err != nil || len(transcripts) == 0 {
+		t.Fatalf("no transcripts: %v", err)
+	}
+	for _, path := range transcripts {
+		entries, err := golden.Load(path)
+		if err != nil {
+			t.Fatalf("load %s: %v", path, err)
+		}
+		rec := httptest.NewRecorder()
+		cfg := &config.Config{OpenAISSEConformance: true}
+		sh := newStreamHandler(cfg, rec, debug.New(false, false), false, true, adapter.FormatOpenAI, "")
+		testMessageStart(sh)
+		for _, e := range entries {
+			sh.handleMessage(upstream.SSEMessage{Type: e.Type, Event: e.Event})
+		}
+		sh.forceFinishIfMissing()
+		sh.finishResponse("end_turn")
+		sh.release()
+
+		if err := sseconform.ValidateOpenAI(rec.Body.String()); err != nil {
+			t.Fatalf("%s: %v\n%s", filepath.Base(path), err, rec.Body.String())
+		}
+	}
+}
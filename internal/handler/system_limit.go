@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"orchids-api/internal/debug"
+	"orchids-api/internal/metrics"
+	"orchids-api/internal/prompt"
+)
+
+// limitSystemPrompt 按 system_prompt_max_tokens 处理请求的系统提示。reject 策略超出上限时写出 413 并返回 false
+func (h *Handler) limitSystemPrompt(w http.ResponseWriter, req *ClaudeRequest, logger *debug.Logger) bool {
+	if h.config == nil || h.config.SystemPromptMaxTokens <= 0 {
+		return true
+	}
+	strategy := strings.ToLower(strings.TrimSpace(h.config.SystemPromptTrimStrategy))
+	limited, trimmed, err := prompt.LimitSystemPrompt(req.System, h.config.SystemPromptMaxTokens, strategy)
+	var tooLarge *prompt.SystemPromptTooLargeError
+	if errors.As(err, &tooLarge) {
+		metrics.SystemPromptTrims.WithLabelValues(prompt.SystemTrimReject).Inc()
+		slog.Warn("系统提示超出上限，已拒绝请求", "tokens", tooLarge.Tokens, "max_tokens", tooLarge.MaxTokens)
+		logger.LogEarlyExit("system_prompt_too_large", map[string]interface{}{
+			"tokens":     tooLarge.Tokens,
+			"max_tokens": tooLarge.MaxTokens,
+		})
+		h.writeErrorResponse(w, "request_too_large", tooLarge.Error(), http.StatusRequestEntityTooLarge)
+		return false
+	}
+	if trimmed {
+		if strategy != prompt.SystemTrimSummarize {
+			strategy = prompt.SystemTrimTruncate
+		}
+		metrics.SystemPromptTrims.WithLabelValues(strategy).Inc()
+		slog.Info("系统提示超出上限，已裁剪", "strategy", strategy, "tokens", prompt.SystemPromptTokens(req.System), "max_tokens", h.config.SystemPromptMaxTokens)
+		req.System = limited
+	}
+	return true
+}
@@ -0,0 +1,120 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// tool_choice 类型（Anthropic 取值，OpenAI 的 required / function 归一化为 any / tool）
+const (
+	toolChoiceAuto = "auto"
+	toolChoiceAny  = "any"
+	toolChoiceTool = "tool"
+	toolChoiceNone = "none"
+)
+
+// ToolChoice 为请求的 tool_choice，兼容 Anthropic 的 {"type":"auto|any|tool|none","name":...}
+// 与 OpenAI 的 "auto" / "none" / "required" / {"type":"function","function":{"name":...}}
+type ToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+func (c *ToolChoice) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		c.Type = normalizeToolChoiceType(s)
+		return nil
+	}
+	var raw struct {
+		Type     string `json:"type"`
+		Name     string `json:"name"`
+		Function struct {
+			Name string `json:"name"`
+		} `json:"function"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return fmt.Errorf("tool_choice must be a string or an object: %w", err)
+	}
+	c.Type = normalizeToolChoiceType(raw.Type)
+	c.Name = strings.TrimSpace(raw.Name)
+	if c.Name == "" {
+		c.Name = strings.TrimSpace(raw.Function.Name)
+	}
+	return nil
+}
+
+func normalizeToolChoiceType(t string) string {
+	t = strings.ToLower(strings.TrimSpace(t))
+	switch t {
+	case "required":
+		return toolChoiceAny
+	case "function":
+		return toolChoiceTool
+	}
+	return t
+}
+
+// forcesTool 报告是否要求模型必须调用工具
+func (c *ToolChoice) forcesTool() bool {
+	return c != nil && (c.Type == toolChoiceAny || c.Type == toolChoiceTool)
+}
+
+func (c *ToolChoice) disablesTools() bool {
+	return c != nil && c.Type == toolChoiceNone
+}
+
+// toolChoiceGate 返回注入 prompt 的 tool_gate 指令；tools 为空（如 warp_disable_tools）时不强制调用
+func toolChoiceGate(c *ToolChoice, tools []interface{}) string {
+	switch {
+	case c.disablesTools():
+		return "Tool use is disabled for this request. Do NOT call any tools. Answer directly."
+	case !c.forcesTool() || len(tools) == 0:
+		return ""
+	case c.Type == toolChoiceTool:
+		return fmt.Sprintf("You MUST respond by calling the `%s` tool first. Do not call any other tool before it and do not answer with plain text only.", c.Name)
+	default:
+		return "You MUST respond by calling one of the available tools. Do not answer with plain text only."
+	}
+}
+
+// toolSpecName 返回工具定义的名称，兼容 Anthropic（name）与 OpenAI（function.name）格式
+func toolSpecName(tool interface{}) string {
+	tm, ok := tool.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	if fn, ok := tm["function"].(map[string]interface{}); ok {
+		if name, ok := fn["name"].(string); ok && strings.TrimSpace(name) != "" {
+			return strings.TrimSpace(name)
+		}
+	}
+	name, _ := tm["name"].(string)
+	return strings.TrimSpace(name)
+}
+
+// toolChoiceAllows 按 tool_choice 过滤上游的工具调用：none 时全部丢弃；
+// tool 时首个被接受的调用必须是指定工具，在它之前出现的其他工具调用被丢弃
+func (h *streamHandler) toolChoiceAllows(name string) bool {
+	c := h.toolChoice
+	switch {
+	case c.disablesTools():
+		slog.Debug("tool_choice none: tool call suppressed", "tool", name)
+		return false
+	case c == nil || c.Type != toolChoiceTool:
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.forcedToolCalled {
+		return true
+	}
+	if !strings.EqualFold(strings.TrimSpace(name), c.Name) {
+		slog.Warn("tool_choice 指定的工具未被首先调用，已丢弃该调用", "tool", name, "required", c.Name)
+		return false
+	}
+	h.forcedToolCalled = true
+	return true
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"orchids-api/internal/adapter"
+	"orchids-api/internal/config"
+	"orchids-api/internal/debug"
+	"orchids-api/internal/prompt"
+	"orchids-api/internal/upstream"
+)
+
+func TestToolChoice_UnmarshalJSON(t *testing.T) {
+	cases := map[string]ToolChoice{
+		`"auto"`:                        {Type: toolChoiceAuto},
+		`"none"`:                        {Type: toolChoiceNone},
+		`"required"`:                    {Type: toolChoiceAny},
+		`{"type":"any"}`:                {Type: toolChoiceAny},
+		`{"type":"tool","name":"Read"}`: {Type: toolChoiceTool, Name: "Read"},
+		`{"type":"function","function":{"name":"Bash"}}`: {Type: toolChoiceTool, Name: "Bash"},
+	}
+	for in, want := range cases {
+		var got ToolChoice
+		if err := json.Unmarshal([]byte(in), &got); err != nil {
+			t.Fatalf("%s: %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("%s: got %+v, want %+v", in, got, want)
+		}
+	}
+	if err := json.Unmarshal([]byte(`1`), &ToolChoice{}); err == nil {
+		t.Fatal("expected error for numeric tool_choice")
+	}
+}
+
+func TestValidateClaudeRequest_ToolChoice(t *testing.T) {
+	tools := []interface{}{
+		map[string]interface{}{"name": "Read"},
+		map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "Bash"}},
+	}
+	cases := []struct {
+		choice ToolChoice
+		tools  []interface{}
+		field  string
+	}{
+		{ToolChoice{Type: toolChoiceTool, Name: "Bash"}, tools, ""},
+		{ToolChoice{Type: toolChoiceNone}, nil, ""},
+		{ToolChoice{Type: toolChoiceAny}, nil, "tool_choice"},
+		{ToolChoice{Type: toolChoiceTool}, tools, "tool_choice.name"},
+		{ToolChoice{Type: toolChoiceTool, Name: "Write"}, tools, "tool_choice.name"},
+		{ToolChoice{Type: "sometimes"}, tools, "tool_choice.type"},
+	}
+	for _, tc := range cases {
+		choice := tc.choice
+		req := ClaudeRequest{
+			Messages:   []prompt.Message{{Role: "user", Content: prompt.MessageContent{Text: "hi"}}},
+			Tools:      tc.tools,
+			ToolChoice: &choice,
+		}
+		issues := validateClaudeRequest(req, adapter.FormatAnthropic)
+		if tc.field == "" {
+			if len(issues) != 0 {
+				t.Fatalf("%+v: unexpected issues %+v", choice, issues)
+			}
+			continue
+		}
+		if len(issues) != 1 || issues[0].Field != tc.field {
+			t.Fatalf("%+v: got %+v, want field %s", choice, issues, tc.field)
+		}
+	}
+}
+
+func TestToolChoiceGate(t *testing.T) {
+	tools := []interface{}{map[string]interface{}{"name": "Read"}}
+	if gate := toolChoiceGate(nil, tools); gate != "" {
+		t.Fatalf("auto gate = %q", gate)
+	}
+	if gate := toolChoiceGate(&ToolChoice{Type: toolChoiceNone}, nil); !strings.Contains(gate, "Do NOT call") {
+		t.Fatalf("none gate = %q", gate)
+	}
+	if gate := toolChoiceGate(&ToolChoice{Type: toolChoiceTool, Name: "Read"}, tools); !strings.Contains(gate, "`Read`") {
+		t.Fatalf("tool gate = %q", gate)
+	}
+	// 工具被 warp_disable_tools 等移除时不强制调用
+	if gate := toolChoiceGate(&ToolChoice{Type: toolChoiceAny}, nil); gate != "" {
+		t.Fatalf("any gate without tools = %q", gate)
+	}
+}
+
+func runToolChoiceStream(choice *ToolChoice, calls ...toolCall) *streamHandler {
+	h := newStreamHandler(&config.Config{}, httptest.NewRecorder(), debug.New(false, false), false, false, adapter.FormatAnthropic, "")
+	h.toolChoice = choice
+	for _, call := range calls {
+		h.handleMessage(upstream.SSEMessage{
+			Type:  "model.tool-call",
+			Event: map[string]interface{}{"toolCallId": call.id, "toolName": call.name, "input": call.input},
+		})
+	}
+	h.handleMessage(upstream.SSEMessage{
+		Type:  "model.finish",
+		Event: map[string]interface{}{"finishReason": "tool_use"},
+	})
+	return h
+}
+
+func TestStreamHandler_ToolChoice(t *testing.T) {
+	read := toolCall{id: "t1", name: "Read", input: `{"file_path":"/tmp/a"}`}
+	bash := toolCall{id: "t2", name: "Bash", input: `{"command":"ls"}`}
+
+	h := runToolChoiceStream(&ToolChoice{Type: toolChoiceNone}, read)
+	defer h.release()
+	if len(h.contentBlocks) != 0 || h.finalStopReason != "end_turn" {
+		t.Fatalf("none: blocks=%v stop_reason=%q", h.contentBlocks, h.finalStopReason)
+	}
+
+	h = runToolChoiceStream(&ToolChoice{Type: toolChoiceTool, Name: "Bash"}, read, bash, toolCall{id: "t3", name: "Read", input: `{"file_path":"/tmp/b"}`})
+	defer h.release()
+	if len(h.contentBlocks) != 2 || h.contentBlocks[0]["name"] != "Bash" || h.contentBlocks[1]["name"] != "Read" {
+		t.Fatalf("tool: blocks=%v", h.contentBlocks)
+	}
+	if h.finalStopReason != "tool_use" {
+		t.Fatalf("tool: stop_reason=%q", h.finalStopReason)
+	}
+
+	h = runToolChoiceStream(&ToolChoice{Type: toolChoiceTool, Name: "Bash"}, read)
+	defer h.release()
+	if len(h.contentBlocks) != 0 || h.finalStopReason != "end_turn" {
+		t.Fatalf("tool not called: blocks=%v stop_reason=%q", h.contentBlocks, h.finalStopReason)
+	}
+}
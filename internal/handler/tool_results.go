@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"orchids-api/internal/prompt"
+	"orchids-api/internal/toolspill"
+)
+
+func (h *Handler) SetToolSpill(s *toolspill.Store) {
+	h.toolSpill = s
+}
+
+// limitToolResults 将超过 tool_result_max_bytes 的 tool_result 替换为引用与首尾摘录，
+// 原文保存到 toolSpill（未配置或保存失败时只保留摘录）。返回处理后的消息副本与处理的块数
+func (h *Handler) limitToolResults(messages []prompt.Message, channel string) ([]prompt.Message, int) {
+	if h.config == nil || h.config.ToolResultMaxBytes <= 0 {
+		return messages, 0
+	}
+	maxBytes := h.config.ToolResultMaxBytes
+	limited := cloneMessages(messages)
+	count := 0
+	var spilledBytes int
+
+	for i := range limited {
+		msg := &limited[i]
+		if msg.Role != "user" || msg.Content.Blocks == nil {
+			continue
+		}
+		for j := range msg.Content.Blocks {
+			block := &msg.Content.Blocks[j]
+			if block.Type != "tool_result" {
+				continue
+			}
+			text, ok := toolResultText(block.Content)
+			if !ok || len(text) <= maxBytes {
+				continue
+			}
+			ref := ""
+			if h.toolSpill != nil {
+				id, err := h.toolSpill.Put(text)
+				if err != nil {
+					slog.Warn("保存 tool_result 原文失败", "channel", channel, "bytes", len(text), "error", err)
+				} else {
+					ref = id
+				}
+			}
+			block.Content = toolResultExcerpt(text, ref, h.config.ToolResultExcerptBytes)
+			spilledBytes += len(text)
+			count++
+		}
+	}
+
+	if count > 0 {
+		slog.Info("超大 tool_result 已替换为摘录", "channel", channel, "blocks", count, "bytes", spilledBytes, "stored", h.toolSpill != nil)
+	}
+	return limited, count
+}
+
+// toolResultText 返回 tool_result 内容的文本：字符串与只含 text 块的数组见 toolResultPlainText；
+// 其他结构（如含图片）序列化为 JSON 计算大小
+func toolResultText(content interface{}) (string, bool) {
+	if text, ok := toolResultPlainText(content); ok {
+		return text, true
+	}
+	if c, ok := content.([]interface{}); ok {
+		raw, err := json.Marshal(c)
+		if err != nil {
+			return "", false
+		}
+		return string(raw), true
+	}
+	return "", false
+}
+
+// toolResultPlainText 返回字符串内容本身，或只含 text 块的数组拼接后的文本
+func toolResultPlainText(content interface{}) (string, bool) {
+	switch c := content.(type) {
+	case string:
+		return c, true
+	case []interface{}:
+		var sb strings.Builder
+		for _, item := range c {
+			m, ok := item.(map[string]interface{})
+			text, isText := m["text"].(string)
+			if !ok || m["type"] != "text" || !isText {
+				return "", false
+			}
+			if sb.Len() > 0 {
+				sb.WriteString("\n")
+			}
+			sb.WriteString(text)
+		}
+		return sb.String(), true
+	}
+	return "", false
+}
+
+// toolResultExcerpt 生成替换后的 tool_result 内容：说明行 + 开头 excerptBytes*3/4 + 结尾 excerptBytes/4
+func toolResultExcerpt(text, ref string, excerptBytes int) string {
+	headEnd := truncateUTF8(text, excerptBytes*3/4)
+	tailStart := len(text) - excerptBytes/4
+	for tailStart < len(text) && tailStart > headEnd && !utf8.RuneStart(text[tailStart]) {
+		tailStart++
+	}
+	if tailStart < headEnd {
+		tailStart = headEnd
+	}
+
+	var sb strings.Builder
+	if ref != "" {
+		fmt.Fprintf(&sb, "[tool result truncated: %d bytes total, full output stored as tool-result %s; showing the first %d and last %d bytes]\n",
+			len(text), ref, headEnd, len(text)-tailStart)
+	} else {
+		fmt.Fprintf(&sb, "[tool result truncated: %d bytes total; showing the first %d and last %d bytes]\n",
+			len(text), headEnd, len(text)-tailStart)
+	}
+	sb.WriteString(text[:headEnd])
+	if omitted := tailStart - headEnd; omitted > 0 {
+		fmt.Fprintf(&sb, "\n... [%d bytes omitted] ...\n", omitted)
+	}
+	sb.WriteString(text[tailStart:])
+	return sb.String()
+}
@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/prompt"
+	"orchids-api/internal/toolspill"
+)
+
+func TestLimitToolResults_SpillsOversized(t *testing.T) {
+	spill := toolspill.New(filepath.Join(t.TempDir(), "tool-results"))
+	h := &Handler{config: &config.Config{ToolResultMaxBytes: 1000, ToolResultExcerptBytes: 100}}
+	h.SetToolSpill(spill)
+
+	big := "HEAD" + strings.Repeat("x", 5000) + "TAIL"
+	messages := []prompt.Message{{
+		Role: "user",
+		Content: prompt.MessageContent{Blocks: []prompt.ContentBlock{
+			{Type: "tool_result", ToolUseID: "t1", Content: big},
+			{Type: "tool_result", ToolUseID: "t2", Content: "small"},
+			{Type: "tool_result", ToolUseID: "t3", Content: []interface{}{
+				map[string]interface{}{"type": "text", "text": big},
+			}},
+		}},
+	}}
+
+	limited, count := h.limitToolResults(messages, "orchids")
+	if count != 2 {
+		t.Fatalf("count = %d, want 2", count)
+	}
+	if messages[0].Content.Blocks[0].Content != big {
+		t.Fatal("original messages were modified")
+	}
+	blocks := limited[0].Content.Blocks
+	got, _ := blocks[0].Content.(string)
+	id := toolspill.ID(big)
+	if !strings.Contains(got, "tool-result "+id) || !strings.HasPrefix(strings.SplitN(got, "\n", 2)[1], "HEAD") || !strings.HasSuffix(got, "TAIL") {
+		t.Fatalf("excerpt = %q", got)
+	}
+	if len(got) > 400 {
+		t.Fatalf("excerpt too long: %d bytes", len(got))
+	}
+	if blocks[1].Content != "small" {
+		t.Fatalf("small result changed: %v", blocks[1].Content)
+	}
+	if text, _ := blocks[2].Content.(string); !strings.Contains(text, "tool-result "+id) {
+		t.Fatalf("text block content not spilled: %v", blocks[2].Content)
+	}
+	stored, err := spill.Get(id)
+	if err != nil || string(stored) != big {
+		t.Fatalf("stored = %d bytes, %v", len(stored), err)
+	}
+}
+
+func TestToolResultExcerpt_UTF8Boundaries(t *testing.T) {
+	text := strings.Repeat("中", 1000)
+	got := toolResultExcerpt(text, "", 100)
+	body := strings.SplitN(got, "\n", 2)[1]
+	if !strings.HasPrefix(body, "中") || !strings.HasSuffix(body, "中") || strings.ContainsRune(body, '�') {
+		t.Fatalf("excerpt split a rune: %q", got)
+	}
+	if !strings.Contains(got, "bytes omitted") {
+		t.Fatalf("missing omission marker: %q", got)
+	}
+}
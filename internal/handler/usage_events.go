@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+	"time"
+
+	"orchids-api/internal/events"
+	"orchids-api/internal/middleware"
+	"orchids-api/internal/store"
+)
+
+// publishUsage 发出本次请求的 request.completed 事件，费用按上游模型的官方标价估算（见 modelFamilies）
+func (h *Handler) publishUsage(r *http.Request, req ClaudeRequest, sh *streamHandler, account *store.Account, channel, upstreamModel string, elapsed time.Duration) {
+	if h.events == nil {
+		return
+	}
+	ev := events.Usage{
+		ID:            events.NewID("usage_"),
+		TraceID:       middleware.GetTraceID(r.Context()),
+		Timestamp:     time.Now().UTC(),
+		APIKeyID:      middleware.APIKeyID(r),
+		Channel:       channel,
+		Model:         req.Model,
+		UpstreamModel: upstreamModel,
+		InputTokens:   sh.inputTokens,
+		OutputTokens:  sh.outputTokens,
+		Stream:        req.Stream,
+		DurationMs:    elapsed.Milliseconds(),
+	}
+	if account != nil {
+		ev.AccountID = account.ID
+	}
+	if family, ok := familyFor(upstreamModel); ok {
+		ev.Cost = (float64(ev.InputTokens)*family.pricing.InputPerMTok + float64(ev.OutputTokens)*family.pricing.OutputPerMTok) / 1e6
+		ev.Currency = family.pricing.Currency
+	}
+	h.events.Publish(events.RequestCompleted, ev)
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"encoding/json"
+	"math"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"orchids-api/internal/events"
+	"orchids-api/internal/store"
+)
+
+type recordingSink struct{ events []events.Event }
+
+func (s *recordingSink) Publish(e events.Event) { s.events = append(s.events, e) }
+func (s *recordingSink) Close() error           { return nil }
+
+func TestPublishUsage_EstimatesCost(t *testing.T) {
+	sink := &recordingSink{}
+	bus := &events.Bus{}
+	bus.Subscribe(sink, events.RequestCompleted)
+	h := &Handler{events: bus}
+	r := httptest.NewRequest("POST", "/orchids/v1/messages", nil)
+	r.Header.Set("x-api-key", "sk-billing")
+	sh := &streamHandler{inputTokens: 200000, outputTokens: 10000}
+
+	h.publishUsage(r, ClaudeRequest{Model: "claude-sonnet-4-5", Stream: true}, sh, &store.Account{ID: 7}, "orchids", "claude-sonnet-4-5", 1500*time.Millisecond)
+
+	if len(sink.events) != 1 || sink.events[0].Type != events.RequestCompleted {
+		t.Fatalf("events = %+v", sink.events)
+	}
+	var ev events.Usage
+	if err := json.Unmarshal(sink.events[0].Data, &ev); err != nil {
+		t.Fatal(err)
+	}
+	// sonnet: $3 / $15 per MTok
+	if math.Abs(ev.Cost-0.75) > 1e-9 || ev.Currency != "USD" {
+		t.Fatalf("cost = %v %s, want 0.75 USD", ev.Cost, ev.Currency)
+	}
+	if ev.APIKeyID == "" || ev.AccountID != 7 || ev.DurationMs != 1500 || !ev.Stream || ev.ID == "" {
+		t.Fatalf("event = %+v", ev)
+	}
+}
@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"orchids-api/internal/adapter"
+)
+
+// validationIssue 描述请求中某个字段的校验错误，Field 使用 messages[1].content[0].tool_use_id 形式的路径。
+type validationIssue struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// maxValidationIssues 限制单次返回的错误条数，避免超长历史产生巨大的错误响应。
+const maxValidationIssues = 20
+
+// validateClaudeRequest 在转发上游前检查请求结构，返回所有发现的问题。
+// OpenAI 兼容端点允许 system/developer/tool 角色以及 assistant 空内容（仅含 tool_calls 的情况）。
+func validateClaudeRequest(req ClaudeRequest, format adapter.ResponseFormat) []validationIssue {
+	var issues []validationIssue
+	add := func(field, format string, args ...interface{}) {
+		if len(issues) >= maxValidationIssues {
+			return
+		}
+		issues = append(issues, validationIssue{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	openAI := format == adapter.FormatOpenAI
+
+	if len(req.Messages) == 0 {
+		add("messages", "at least one message is required")
+		return issues
+	}
+
+	for i, item := range req.System {
+		field := fmt.Sprintf("system[%d]", i)
+		if t := strings.TrimSpace(item.Type); t != "" && t != "text" {
+			add(field+".type", "unsupported system block type %q (expected \"text\")", item.Type)
+		}
+	}
+
+	if c := req.ToolChoice; c != nil {
+		switch c.Type {
+		case toolChoiceAuto, toolChoiceNone:
+		case toolChoiceAny, toolChoiceTool:
+			if len(req.Tools) == 0 {
+				add("tool_choice", "tool_choice %q requires at least one tool", c.Type)
+				break
+			}
+			if c.Type != toolChoiceTool {
+				break
+			}
+			if c.Name == "" {
+				add("tool_choice.name", "tool_choice of type \"tool\" requires a name")
+				break
+			}
+			defined := false
+			for _, tool := range req.Tools {
+				if strings.EqualFold(toolSpecName(tool), c.Name) {
+					defined = true
+					break
+				}
+			}
+			if !defined {
+				add("tool_choice.name", "tool %q is not defined in tools", c.Name)
+			}
+		default:
+			add("tool_choice.type", "unknown tool_choice type %q (expected auto, any, tool or none)", c.Type)
+		}
+	}
+
+	knownToolUses := make(map[string]struct{})
+	for i, msg := range req.Messages {
+		field := fmt.Sprintf("messages[%d]", i)
+		role := strings.ToLower(strings.TrimSpace(msg.Role))
+		switch role {
+		case "user", "assistant":
+		case "system", "developer", "tool":
+			if !openAI {
+				add(field+".role", "unsupported role %q; use the top-level \"system\" field for system prompts", msg.Role)
+				continue
+			}
+		case "":
+			add(field+".role", "role is required")
+			continue
+		default:
+			add(field+".role", "unknown role %q (expected \"user\" or \"assistant\")", msg.Role)
+			continue
+		}
+
+		if msg.Content.IsString() {
+			if strings.TrimSpace(msg.Content.GetText()) == "" && !(openAI && role != "user") {
+				add(field+".content", "content must not be empty")
+			}
+			continue
+		}
+
+		blocks := msg.Content.GetBlocks()
+		if len(blocks) == 0 {
+			add(field+".content", "content must contain at least one block")
+			continue
+		}
+		for j, block := range blocks {
+			blockField := fmt.Sprintf("%s.content[%d]", field, j)
+			switch block.Type {
+			case "text":
+				// 空文本块上游会直接拒绝；末尾 assistant 预填充同样不允许空白。
+				if strings.TrimSpace(block.Text) == "" {
+					add(blockField+".text", "text block must not be empty")
+				}
+			case "image":
+				if block.Source == nil {
+					add(blockField+".source", "image block requires a source")
+				} else if block.Source.Data == "" && block.Source.URL == "" {
+					add(blockField+".source", "image source requires data or url")
+				}
+			case "tool_use":
+				if role != "assistant" {
+					add(blockField+".type", "tool_use blocks are only allowed in assistant messages")
+				}
+				if strings.TrimSpace(block.ID) == "" {
+					add(blockField+".id", "tool_use block requires an id")
+				} else {
+					knownToolUses[block.ID] = struct{}{}
+				}
+				if strings.TrimSpace(block.Name) == "" {
+					add(blockField+".name", "tool_use block requires a name")
+				}
+			case "tool_result":
+				if role != "user" {
+					add(blockField+".type", "tool_result blocks are only allowed in user messages")
+				}
+				id := strings.TrimSpace(block.ToolUseID)
+				if id == "" {
+					add(blockField+".tool_use_id", "tool_result block requires a tool_use_id")
+				} else if _, ok := knownToolUses[id]; !ok {
+					add(blockField+".tool_use_id", "tool_use_id %q does not match any preceding tool_use block", id)
+				}
+			case "":
+				add(blockField+".type", "block type is required")
+			}
+		}
+	}
+	return issues
+}
+
+// writeValidationError 以 422 返回字段级错误详情，格式沿用 Anthropic 错误结构并附加 details。
+func (h *Handler) writeValidationError(w http.ResponseWriter, issues []validationIssue) {
+	message := "Request validation failed"
+	if len(issues) > 0 {
+		message = fmt.Sprintf("%s: %s: %s", message, issues[0].Field, issues[0].Message)
+		if len(issues) > 1 {
+			message = fmt.Sprintf("%s (and %d more)", message, len(issues)-1)
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type": "error",
+		"error": map[string]interface{}{
+			"type":    "invalid_request_error",
+			"message": message,
+			"details": issues,
+		},
+	})
+}
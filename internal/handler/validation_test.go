@@ -0,0 +1,158 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"orchids-api/internal/adapter"
+	"orchids-api/internal/config"
+	"orchids-api/internal/prompt"
+)
+
+func TestValidateClaudeRequest_Valid(t *testing.T) {
+	req := ClaudeRequest{
+		Messages: []prompt.Message{
+			{Role: "user", Content: prompt.MessageContent{Text: "hi"}},
+			{Role: "assistant", Content: prompt.MessageContent{Blocks: []prompt.ContentBlock{
+				{Type: "tool_use", ID: "toolu_1", Name: "Read", Input: map[string]interface{}{}},
+			}}},
+			{Role: "user", Content: prompt.MessageContent{Blocks: []prompt.ContentBlock{
+				{Type: "tool_result", ToolUseID: "toolu_1", Content: "ok"},
+			}}},
+		},
+	}
+	if issues := validateClaudeRequest(req, adapter.FormatAnthropic); len(issues) != 0 {
+		t.Fatalf("expected no issues, got %+v", issues)
+	}
+}
+
+func TestValidateClaudeRequest_FieldPaths(t *testing.T) {
+	req := ClaudeRequest{
+		Messages: []prompt.Message{
+			{Role: "robot", Content: prompt.MessageContent{Text: "hi"}},
+			{Role: "user", Content: prompt.MessageContent{Text: "   "}},
+			{Role: "user", Content: prompt.MessageContent{Blocks: []prompt.ContentBlock{
+				{Type: "text", Text: "see result"},
+				{Type: "tool_result", ToolUseID: "toolu_missing"},
+			}}},
+		},
+	}
+	issues := validateClaudeRequest(req, adapter.FormatAnthropic)
+	want := []string{"messages[0].role", "messages[1].content", "messages[2].content[1].tool_use_id"}
+	if len(issues) != len(want) {
+		t.Fatalf("issues = %+v, want fields %v", issues, want)
+	}
+	for i, field := range want {
+		if issues[i].Field != field {
+			t.Fatalf("issues[%d].Field = %q, want %q", i, issues[i].Field, field)
+		}
+	}
+}
+
+func TestValidateClaudeRequest_OpenAIRoles(t *testing.T) {
+	req := ClaudeRequest{
+		Messages: []prompt.Message{
+			{Role: "system", Content: prompt.MessageContent{Text: "be brief"}},
+			{Role: "user", Content: prompt.MessageContent{Text: "hi"}},
+			{Role: "assistant", Content: prompt.MessageContent{}},
+		},
+	}
+	if issues := validateClaudeRequest(req, adapter.FormatOpenAI); len(issues) != 0 {
+		t.Fatalf("expected openai roles to pass, got %+v", issues)
+	}
+	if issues := validateClaudeRequest(req, adapter.FormatAnthropic); len(issues) == 0 {
+		t.Fatalf("expected system role to be rejected for anthropic format")
+	}
+}
+
+func TestHandleMessages_ValidationReturns422(t *testing.T) {
+	h := &Handler{
+		config:            &config.Config{},
+		client:            &fakePayloadClient{},
+		sessionWorkdirs:   map[string]string{},
+		sessionConvIDs:    map[string]string{},
+		sessionLastAccess: map[string]time.Time{},
+		recentRequests:    map[string]*recentRequest{},
+	}
+
+	body := `{"model":"claude-opus-4-6","messages":[{"role":"user","content":[]}]}`
+	req := httptest.NewRequest(http.MethodPost, "/orchids/v1/messages", bytes.NewReader([]byte(body)))
+	rec := httptest.NewRecorder()
+	h.HandleMessages(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusUnprocessableEntity)
+	}
+	var resp struct {
+		Error struct {
+			Type    string            `json:"type"`
+			Message string            `json:"message"`
+			Details []validationIssue `json:"details"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if resp.Error.Type != "invalid_request_error" {
+		t.Fatalf("error type = %q", resp.Error.Type)
+	}
+	if len(resp.Error.Details) != 1 || resp.Error.Details[0].Field != "messages[0].content" {
+		t.Fatalf("details = %+v", resp.Error.Details)
+	}
+	if !strings.Contains(resp.Error.Message, "messages[0].content") {
+		t.Fatalf("message = %q, want field path", resp.Error.Message)
+	}
+}
+
+func TestHandleMessages_SystemPromptRejectReturns413(t *testing.T) {
+	h := &Handler{
+		config:            &config.Config{SystemPromptMaxTokens: 10, SystemPromptTrimStrategy: "reject"},
+		client:            &fakePayloadClient{},
+		sessionWorkdirs:   map[string]string{},
+		sessionConvIDs:    map[string]string{},
+		sessionLastAccess: map[string]time.Time{},
+		recentRequests:    map[string]*recentRequest{},
+	}
+
+	body := `{"model":"claude-opus-4-6","system":"` + strings.Repeat("follow the rules ", 50) + `","messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/orchids/v1/messages", bytes.NewReader([]byte(body)))
+	rec := httptest.NewRecorder()
+	h.HandleMessages(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusRequestEntityTooLarge)
+	}
+	if !strings.Contains(rec.Body.String(), "request_too_large") {
+		t.Fatalf("body = %s", rec.Body.String())
+	}
+}
+
+func TestHandleMessages_ChannelModelDenied(t *testing.T) {
+	h := &Handler{
+		config: &config.Config{ChannelModels: map[string]config.ChannelModelPolicy{
+			"orchids": {Allow: []string{"claude-sonnet-*"}},
+		}},
+		client:            &fakePayloadClient{},
+		sessionWorkdirs:   map[string]string{},
+		sessionConvIDs:    map[string]string{},
+		sessionLastAccess: map[string]time.Time{},
+		recentRequests:    map[string]*recentRequest{},
+	}
+
+	body := `{"model":"claude-opus-4-6","messages":[{"role":"user","content":"hi"}]}`
+	req := httptest.NewRequest(http.MethodPost, "/orchids/v1/messages", bytes.NewReader([]byte(body)))
+	rec := httptest.NewRecorder()
+	h.HandleMessages(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+	if !strings.Contains(rec.Body.String(), "permission_error") {
+		t.Fatalf("body = %s", rec.Body.String())
+	}
+}
@@ -0,0 +1,145 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"orchids-api/internal/adapter"
+)
+
+// errUpstreamStalled 作为 context cause，表示上游长时间无数据被看门狗中止
+var errUpstreamStalled = errors.New("upstream stalled")
+
+// stallCheckInterval 为看门狗最大检查间隔；超时配置较短时按比例缩短
+const stallCheckInterval = 5 * time.Second
+
+// touchActivity 记录最近一次收到上游消息的时间
+func (h *streamHandler) touchActivity() {
+	h.lastActivity.Store(time.Now().UnixNano())
+}
+
+// idleFor 返回距离最近一次上游消息的时长
+func (h *streamHandler) idleFor() time.Duration {
+	last := h.lastActivity.Load()
+	if last == 0 {
+		return time.Since(h.startTime)
+	}
+	return time.Since(time.Unix(0, last))
+}
+
+// writeStallWarning 向客户端发送卡顿提示：Anthropic 格式使用 ping 事件，OpenAI 格式使用 SSE 注释
+func (h *streamHandler) writeStallWarning(idle time.Duration) {
+	if !h.isStream {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.hasReturn {
+		return
+	}
+	idleSeconds := int(idle / time.Second)
+	var err error
+	if h.responseFormat == adapter.FormatOpenAI {
+		_, err = fmt.Fprintf(h.w, ": stall-warning idle_seconds=%d\n\n", idleSeconds)
+	} else {
+		data, _ := json.Marshal(map[string]interface{}{
+			"type":          "ping",
+			"stall_warning": map[string]int{"idle_seconds": idleSeconds},
+		})
+		_, err = fmt.Fprintf(h.w, "event: ping\ndata: %s\n\n", data)
+	}
+	if err != nil {
+		h.markWriteErrorLocked("ping", err)
+		return
+	}
+	if h.flusher != nil {
+		h.flusher.Flush()
+	}
+}
+
+// InjectStallError 通知客户端上游已卡顿并被中止；Anthropic 流式响应发送标准 error 事件
+func (h *streamHandler) InjectStallError(idle time.Duration) {
+	errorMsg := fmt.Sprintf("Request aborted: upstream sent no data for %s", idle.Round(time.Second))
+	if !h.isStream || h.responseFormat == adapter.FormatOpenAI {
+		h.InjectErrorText("Injecting stall error to client", errorMsg)
+		return
+	}
+	data, _ := json.Marshal(map[string]interface{}{
+		"type": "error",
+		"error": map[string]string{
+			"type":    "api_error",
+			"message": errorMsg,
+		},
+	})
+	h.writeSSE("error", string(data))
+}
+
+// startStallWatchdog 监控上游数据流：空闲超过 StallTimeout 发送一次提示，
+// 超过 StallAbortTimeout 以 errUpstreamStalled 取消请求 context。配置为负数时关闭对应阶段。
+func (h *Handler) startStallWatchdog(r *http.Request, sh *streamHandler) (*http.Request, func()) {
+	warnAfter := time.Duration(h.config.StallTimeout) * time.Second
+	abortAfter := time.Duration(h.config.StallAbortTimeout) * time.Second
+	if warnAfter <= 0 && abortAfter <= 0 {
+		return r, func() {}
+	}
+
+	ctx, cancel := context.WithCancelCause(r.Context())
+	r = r.WithContext(ctx)
+
+	interval := stallCheckInterval
+	for _, d := range []time.Duration{warnAfter, abortAfter} {
+		if d > 0 && d/4 < interval {
+			interval = d / 4
+		}
+	}
+	if interval < 100*time.Millisecond {
+		interval = 100 * time.Millisecond
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		warned := false
+		for {
+			select {
+			case <-ticker.C:
+				sh.mu.Lock()
+				done := sh.hasReturn
+				sh.mu.Unlock()
+				if done {
+					return
+				}
+				idle := sh.idleFor()
+				if abortAfter > 0 && idle >= abortAfter {
+					slog.Warn("Upstream stalled, aborting request", "idle", idle, "msg_id", sh.msgID)
+					cancel(errUpstreamStalled)
+					return
+				}
+				if warnAfter > 0 && idle >= warnAfter {
+					if !warned {
+						slog.Warn("Upstream stall detected", "idle", idle, "msg_id", sh.msgID)
+						sh.writeStallWarning(idle)
+						warned = true
+					}
+				} else {
+					warned = false
+				}
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return r, func() {
+		close(stop)
+		cancel(nil)
+	}
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/prompt"
+)
+
+func TestStallWatchdog_WarnsThenAborts(t *testing.T) {
+	client := &blockingClient{started: make(chan struct{})}
+	h := &Handler{
+		config:            &config.Config{StallTimeout: 1, StallAbortTimeout: 2},
+		client:            client,
+		sessionWorkdirs:   map[string]string{},
+		sessionConvIDs:    map[string]string{},
+		sessionLastAccess: map[string]time.Time{},
+		recentRequests:    map[string]*recentRequest{},
+	}
+
+	body, err := json.Marshal(ClaudeRequest{
+		Model:    "claude-opus-4-6",
+		Messages: []prompt.Message{{Role: "user", Content: prompt.MessageContent{Text: "hello"}}},
+		Stream:   true,
+	})
+	if err != nil {
+		t.Fatalf("marshal request: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodPost, "/orchids/v1/messages", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	done := make(chan struct{})
+	go func() {
+		h.HandleMessages(rec, req)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatalf("HandleMessages did not abort stalled request")
+	}
+
+	out := rec.Body.String()
+	if !strings.Contains(out, "event: ping") || !strings.Contains(out, "stall_warning") {
+		t.Fatalf("expected stall warning ping, got %q", out)
+	}
+	if !strings.Contains(out, "event: error") || !strings.Contains(out, "upstream sent no data") {
+		t.Fatalf("expected structured stall error, got %q", out)
+	}
+	if !strings.Contains(out, "event: message_stop") {
+		t.Fatalf("expected stream to be finished, got %q", out)
+	}
+}
@@ -0,0 +1,194 @@
+// Package health 提供 Kubernetes 探针使用的存活（/health/live）与就绪（/health/ready）检查。
+// 存活检查只表示进程可以响应请求；就绪检查逐项检查依赖，任一失败返回 503，使流量暂时不再路由到本实例。
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"orchids-api/internal/store"
+)
+
+// DefaultTimeout 为单项检查的默认超时，应小于探针的 timeoutSeconds
+const DefaultTimeout = 2 * time.Second
+
+// Check 为一项依赖检查；Run 返回的 detail 会原样输出到响应中，error 非 nil 表示未就绪
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (detail interface{}, err error)
+}
+
+// Result 为单项检查的结果
+type Result struct {
+	Status    string      `json:"status"`
+	Error     string      `json:"error,omitempty"`
+	Detail    interface{} `json:"detail,omitempty"`
+	LatencyMs int64       `json:"latency_ms"`
+}
+
+// Report 为就绪检查的响应体
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Checker 并发执行所有检查
+type Checker struct {
+	checks  []Check
+	timeout time.Duration
+	started time.Time
+}
+
+// New 创建 Checker，timeout <= 0 时使用 DefaultTimeout
+func New(timeout time.Duration, checks ...Check) *Checker {
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	return &Checker{checks: checks, timeout: timeout, started: time.Now()}
+}
+
+// Run 执行所有检查并汇总结果
+func (c *Checker) Run(ctx context.Context) Report {
+	report := Report{Status: "ok", Checks: make(map[string]Result, len(c.checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, check := range c.checks {
+		wg.Add(1)
+		go func(check Check) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, c.timeout)
+			defer cancel()
+			start := time.Now()
+			detail, err := runCheck(checkCtx, check)
+			result := Result{Status: "ok", Detail: detail, LatencyMs: time.Since(start).Milliseconds()}
+			if err != nil {
+				result.Status = "fail"
+				result.Error = err.Error()
+			}
+			mu.Lock()
+			report.Checks[check.Name] = result
+			if err != nil {
+				report.Status = "fail"
+			}
+			mu.Unlock()
+		}(check)
+	}
+	wg.Wait()
+	return report
+}
+
+// runCheck 在超时后立即返回，不等待卡住的依赖
+func runCheck(ctx context.Context, check Check) (interface{}, error) {
+	type outcome struct {
+		detail interface{}
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("panic: %v", r)}
+			}
+		}()
+		detail, err := check.Run(ctx)
+		done <- outcome{detail, err}
+	}()
+	select {
+	case o := <-done:
+		return o.detail, o.err
+	case <-ctx.Done():
+		return nil, fmt.Errorf("timed out: %w", ctx.Err())
+	}
+}
+
+// ServeReady 处理 /health/ready：全部检查通过返回 200，否则返回 503
+func (c *Checker) ServeReady(w http.ResponseWriter, r *http.Request) {
+	report := c.Run(r.Context())
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
+
+// ServeLive 处理 /health/live：不检查任何依赖，依赖故障时不应触发重启
+func (c *Checker) ServeLive(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status":         "ok",
+		"uptime_seconds": int64(time.Since(c.started).Seconds()),
+	})
+}
+
+// PingCheck 检查存储是否可访问
+func PingCheck(name string, ping func(ctx context.Context) error) Check {
+	return Check{Name: name, Run: func(ctx context.Context) (interface{}, error) {
+		return nil, ping(ctx)
+	}}
+}
+
+// channelAccounts 为一个渠道的账号数量
+type channelAccounts struct {
+	Total   int `json:"total"`
+	Enabled int `json:"enabled"`
+}
+
+// AccountsCheck 检查每个已配置账号的渠道（orchids / warp 等，按 account_type 区分）至少有一个启用的账号；
+// 一个账号都没有时同样视为未就绪
+func AccountsCheck(list func(ctx context.Context) ([]*store.Account, error)) Check {
+	return Check{Name: "accounts", Run: func(ctx context.Context) (interface{}, error) {
+		accounts, err := list(ctx)
+		if err != nil {
+			return nil, err
+		}
+		channels := map[string]*channelAccounts{}
+		for _, acc := range accounts {
+			channel := strings.ToLower(strings.TrimSpace(acc.AccountType))
+			if channel == "" {
+				channel = "orchids"
+			}
+			if channels[channel] == nil {
+				channels[channel] = &channelAccounts{}
+			}
+			channels[channel].Total++
+			if acc.Enabled {
+				channels[channel].Enabled++
+			}
+		}
+		if len(channels) == 0 {
+			return channels, errors.New("no accounts configured")
+		}
+		var empty []string
+		for channel, counts := range channels {
+			if counts.Enabled == 0 {
+				empty = append(empty, channel)
+			}
+		}
+		if len(empty) > 0 {
+			sort.Strings(empty)
+			return channels, fmt.Errorf("no enabled accounts for channel %s", strings.Join(empty, ", "))
+		}
+		return channels, nil
+	}}
+}
+
+// LoadedCheck 检查某个组件是否已加载
+func LoadedCheck(name string, loaded func() bool) Check {
+	return Check{Name: name, Run: func(ctx context.Context) (interface{}, error) {
+		if !loaded() {
+			return nil, errors.New("not loaded")
+		}
+		return nil, nil
+	}}
+}
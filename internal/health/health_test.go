@@ -0,0 +1,83 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"orchids-api/internal/store"
+)
+
+func listAccounts(accounts ...*store.Account) func(context.Context) ([]*store.Account, error) {
+	return func(context.Context) ([]*store.Account, error) { return accounts, nil }
+}
+
+func TestAccountsCheck(t *testing.T) {
+	ctx := context.Background()
+	cases := []struct {
+		name     string
+		accounts []*store.Account
+		wantErr  string
+	}{
+		{"none", nil, "no accounts configured"},
+		{"all enabled", []*store.Account{{AccountType: "orchids", Enabled: true}, {AccountType: "warp", Enabled: true}}, ""},
+		{"warp disabled", []*store.Account{{AccountType: "", Enabled: true}, {AccountType: "Warp", Enabled: false}}, "channel warp"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := AccountsCheck(listAccounts(tc.accounts...)).Run(ctx)
+			if tc.wantErr == "" && err != nil {
+				t.Fatalf("err = %v, want nil", err)
+			}
+			if tc.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tc.wantErr)) {
+				t.Fatalf("err = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestServeReady(t *testing.T) {
+	ok := New(0,
+		PingCheck("redis", func(context.Context) error { return nil }),
+		LoadedCheck("templates", func() bool { return true }),
+	)
+	rec := httptest.NewRecorder()
+	ok.ServeReady(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	failing := New(50*time.Millisecond,
+		PingCheck("redis", func(context.Context) error { return errors.New("connection refused") }),
+		PingCheck("slow", func(ctx context.Context) error { <-ctx.Done(); time.Sleep(time.Second); return nil }),
+		LoadedCheck("templates", func() bool { return true }),
+	)
+	rec = httptest.NewRecorder()
+	start := time.Now()
+	failing.ServeReady(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Fatalf("ready check waited %v for a stuck dependency", elapsed)
+	}
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("status = %d, want 503", rec.Code)
+	}
+	var report Report
+	if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if report.Status != "fail" || report.Checks["redis"].Error != "connection refused" ||
+		!strings.Contains(report.Checks["slow"].Error, "timed out") || report.Checks["templates"].Status != "ok" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	rec = httptest.NewRecorder()
+	failing.ServeLive(rec, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("live status = %d, want 200 regardless of dependencies", rec.Code)
+	}
+}
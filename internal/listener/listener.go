@@ -0,0 +1,70 @@
+// Package listener 创建 HTTP 服务的监听：TCP 端口之外可以监听 Unix domain socket（与 nginx 同机部署时
+// 不占用端口、用文件权限控制访问），或继承 systemd socket 激活传入的监听（LISTEN_FDS 协议）。
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+)
+
+// systemdFirstFD 为 systemd 传入的第一个文件描述符（SD_LISTEN_FDS_START）
+const systemdFirstFD = 3
+
+// Unix 在 path 监听 Unix domain socket 并将文件权限设为 mode。上次异常退出遗留的 socket 文件会先删除，
+// path 已存在且不是 socket 时返回错误。关闭监听时删除 socket 文件
+func Unix(path string, mode os.FileMode) (net.Listener, error) {
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, mode); err != nil {
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
+
+// Systemd 返回 systemd socket 激活传入的监听。未由 systemd 激活（LISTEN_PID 不是当前进程）时返回 nil。
+// 读取后清除 LISTEN_* 环境变量，避免子进程误用
+func Systemd() ([]net.Listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, fmt.Errorf("invalid LISTEN_FDS %q", os.Getenv("LISTEN_FDS"))
+	}
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	listeners := make([]net.Listener, 0, n)
+	for fd := systemdFirstFD; fd < systemdFirstFD+n; fd++ {
+		f := os.NewFile(uintptr(fd), "systemd-fd-"+strconv.Itoa(fd))
+		l, err := net.FileListener(f)
+		// FileListener 复制了描述符，原文件可以关闭
+		f.Close()
+		if err != nil {
+			for _, opened := range listeners {
+				opened.Close()
+			}
+			return nil, fmt.Errorf("fd %d: %w", fd, err)
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
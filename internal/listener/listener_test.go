@@ -0,0 +1,59 @@
+package listener
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestUnix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "orchids.sock")
+	l, err := Unix(path, 0660)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Mode().Perm() != 0660 {
+		t.Fatalf("mode = %v, %v", info.Mode(), err)
+	}
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+
+	// 模拟异常退出遗留的 socket 文件：不删除文件直接再次监听
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	l.Close()
+	l, err = Unix(path, 0600)
+	if err != nil {
+		t.Fatalf("stale socket not replaced: %v", err)
+	}
+	l.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("socket not removed on close: %v", err)
+	}
+
+	regular := filepath.Join(t.TempDir(), "file")
+	os.WriteFile(regular, []byte("x"), 0644)
+	if _, err := Unix(regular, 0660); err == nil {
+		t.Fatal("regular file replaced by socket")
+	}
+}
+
+func TestSystemd_NotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()+1))
+	t.Setenv("LISTEN_FDS", "1")
+	if ls, err := Systemd(); ls != nil || err != nil {
+		t.Fatalf("other pid: %v %v", ls, err)
+	}
+	t.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+	t.Setenv("LISTEN_FDS", "0")
+	if _, err := Systemd(); err == nil {
+		t.Fatal("LISTEN_FDS=0 accepted")
+	}
+}
@@ -5,12 +5,15 @@ import (
 	"fmt"
 	"log/slog"
 	"math/rand/v2"
+	"strconv"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"orchids-api/internal/auth"
+	"orchids-api/internal/events"
+	"orchids-api/internal/metrics"
 	"orchids-api/internal/orchids"
 	"orchids-api/internal/store"
 	"orchids-api/internal/warp"
@@ -28,6 +31,12 @@ type LoadBalancer struct {
 	cacheTTL       time.Duration
 	activeConns    sync.Map // map[int64]*atomic.Int64
 	sfGroup        singleflight.Group
+	events         *events.Bus
+
+	// 账号达到 max_concurrent 时等待连接释放的最长时间，返回 <= 0 时不等待直接报错
+	queueTimeout func() time.Duration
+	releaseMu    sync.Mutex
+	released     chan struct{} // 任一账号释放连接时关闭并重建，唤醒等待中的选择
 }
 
 func NewWithCacheTTL(s *store.Store, cacheTTL time.Duration) *LoadBalancer {
@@ -40,6 +49,16 @@ func NewWithCacheTTL(s *store.Store, cacheTTL time.Duration) *LoadBalancer {
 	}
 }
 
+// SetEventBus 设置账号状态变化事件的发布目标
+func (lb *LoadBalancer) SetEventBus(b *events.Bus) {
+	lb.events = b
+}
+
+// SetQueueTimeout 设置所有候选账号都达到 max_concurrent 时的排队等待时间，timeout 每次选择时调用以读取当前配置
+func (lb *LoadBalancer) SetQueueTimeout(timeout func() time.Duration) {
+	lb.queueTimeout = timeout
+}
+
 func (lb *LoadBalancer) GetModelChannel(ctx context.Context, modelID string) string {
 	if lb.Store == nil {
 		return ""
@@ -51,51 +70,106 @@ func (lb *LoadBalancer) GetModelChannel(ctx context.Context, modelID string) str
 	return m.Channel
 }
 
+// GetNextAccountExcludingByChannel 选择账号并占用一个连接，调用方在请求结束时调用 ReleaseConnection。
+// 达到 max_concurrent 的账号被跳过；所有候选账号都已满时排队等待连接释放，最长等待 queueTimeout
 func (lb *LoadBalancer) GetNextAccountExcludingByChannel(ctx context.Context, excludeIDs []int64, channel string) (*store.Account, error) {
-	accounts, err := lb.getEnabledAccounts(ctx)
-	if err != nil {
-		return nil, err
-	}
-
-	var filtered []*store.Account
 	excludeSet := make(map[int64]bool)
 	for _, id := range excludeIDs {
 		excludeSet[id] = true
 	}
 
-	for _, acc := range accounts {
-		if excludeSet[acc.ID] {
-			continue
+	var deadline time.Time
+	queued := false
+	defer func() {
+		if queued {
+			metrics.AccountQueueLength.Dec()
 		}
-		if !lb.isAccountAvailable(ctx, acc) {
-			continue
+	}()
+	for {
+		// 先取得释放通知再检查连接数，避免检查之后、等待之前的释放被错过
+		released := lb.releaseSignal()
+		accounts, err := lb.getEnabledAccounts(ctx)
+		if err != nil {
+			return nil, err
 		}
-		if channel != "" {
-			accType := acc.AccountType
-			if strings.TrimSpace(accType) == "" {
-				accType = "orchids"
+
+		var filtered, backingOffAccounts []*store.Account
+		saturated := 0
+		for _, acc := range accounts {
+			if excludeSet[acc.ID] {
+				continue
 			}
-			if !strings.EqualFold(accType, channel) && !strings.EqualFold(acc.AgentMode, channel) {
+			if !lb.isAccountAvailable(ctx, acc) {
 				continue
 			}
+			if channel != "" {
+				accType := acc.AccountType
+				if strings.TrimSpace(accType) == "" {
+					accType = "orchids"
+				}
+				if !strings.EqualFold(accType, channel) && !strings.EqualFold(acc.AgentMode, channel) {
+					continue
+				}
+			}
+			if lb.saturated(acc) {
+				saturated++
+				metrics.AccountSaturationTotal.WithLabelValues(strconv.FormatInt(acc.ID, 10)).Inc()
+				continue
+			}
+			// token 换取处于退避中的账号排到最后，只有没有其他可用账号时才选中
+			if !strings.EqualFold(acc.AccountType, "warp") {
+				if _, backingOff := orchids.TokenBackoffRemaining(acc.SessionID, acc.ID); backingOff {
+					backingOffAccounts = append(backingOffAccounts, acc)
+					continue
+				}
+			}
+			filtered = append(filtered, acc)
+		}
+		if len(filtered) == 0 {
+			filtered = backingOffAccounts
 		}
-		filtered = append(filtered, acc)
-	}
-	accounts = filtered
 
-	if len(accounts) == 0 {
-		return nil, fmt.Errorf("no enabled accounts available for channel: %s", channel)
-	}
+		account := lb.acquireAccount(filtered)
+		if account == nil && (saturated > 0 || len(filtered) > 0) {
+			// 候选账号都已满（或在选择期间被其他请求占满），排队等待连接释放
+			if deadline.IsZero() {
+				timeout := time.Duration(0)
+				if lb.queueTimeout != nil {
+					timeout = lb.queueTimeout()
+				}
+				deadline = time.Now().Add(timeout)
+			}
+			if !queued && time.Now().Before(deadline) {
+				queued = true
+				metrics.AccountQueueLength.Inc()
+				slog.Info("All candidate accounts at max concurrency, waiting", "channel", channel, "saturated", saturated)
+			}
+			if lb.waitForRelease(ctx, released, deadline) {
+				continue
+			}
+			result := "full"
+			if queued {
+				result = "timeout"
+			}
+			metrics.AccountQueueWaitsTotal.WithLabelValues(result).Inc()
+			return nil, fmt.Errorf("all accounts for channel %s are at max concurrency", channel)
+		}
+		if account == nil {
+			return nil, fmt.Errorf("no enabled accounts available for channel: %s", channel)
+		}
+		if queued {
+			metrics.AccountQueueWaitsTotal.WithLabelValues("acquired").Inc()
+		}
 
-	account := lb.selectAccount(accounts)
+		slog.Info("Selected account", "name", account.Name, "email", account.Email, "session", auth.MaskSensitive(account.SessionID))
 
-	slog.Info("Selected account", "name", account.Name, "email", account.Email, "session", auth.MaskSensitive(account.SessionID))
+		if err := lb.Store.IncrementRequestCount(ctx, account.ID); err != nil {
+			lb.ReleaseConnection(account.ID)
+			return nil, err
+		}
 
-	if err := lb.Store.IncrementRequestCount(ctx, account.ID); err != nil {
-		return nil, err
+		return account, nil
 	}
-
-	return account, nil
 }
 
 // deepCopyAccounts 深拷贝账号切片，避免并发请求共享同一指针导致数据竞争
@@ -178,9 +252,62 @@ func (lb *LoadBalancer) selectAccount(accounts []*store.Account) *store.Account
 	return accounts[0]
 }
 
+// ActiveConnections 返回账号当前的活跃连接数
+func (lb *LoadBalancer) ActiveConnections(accountID int64) int64 {
+	if val, ok := lb.activeConns.Load(accountID); ok {
+		return val.(*atomic.Int64).Load()
+	}
+	return 0
+}
+
 func (lb *LoadBalancer) AcquireConnection(accountID int64) {
 	val, _ := lb.activeConns.LoadOrStore(accountID, &atomic.Int64{})
 	val.(*atomic.Int64).Add(1)
+	metrics.AccountConnections.WithLabelValues(strconv.FormatInt(accountID, 10)).Inc()
+}
+
+// tryAcquireConnection 在账号未达到 max_concurrent 时占用一个连接
+func (lb *LoadBalancer) tryAcquireConnection(acc *store.Account) bool {
+	if acc.MaxConcurrent <= 0 {
+		lb.AcquireConnection(acc.ID)
+		return true
+	}
+	val, _ := lb.activeConns.LoadOrStore(acc.ID, &atomic.Int64{})
+	counter := val.(*atomic.Int64)
+	for {
+		current := counter.Load()
+		if current >= int64(acc.MaxConcurrent) {
+			return false
+		}
+		if counter.CompareAndSwap(current, current+1) {
+			metrics.AccountConnections.WithLabelValues(strconv.FormatInt(acc.ID, 10)).Inc()
+			return true
+		}
+	}
+}
+
+// saturated 报告账号是否已达到 max_concurrent
+func (lb *LoadBalancer) saturated(acc *store.Account) bool {
+	return acc.MaxConcurrent > 0 && lb.ActiveConnections(acc.ID) >= int64(acc.MaxConcurrent)
+}
+
+// acquireAccount 按负载从 accounts 中选择账号并占用连接；选中的账号在此期间被占满时换下一个，全部占满返回 nil
+func (lb *LoadBalancer) acquireAccount(accounts []*store.Account) *store.Account {
+	candidates := accounts
+	for len(candidates) > 0 {
+		acc := lb.selectAccount(candidates)
+		if lb.tryAcquireConnection(acc) {
+			return acc
+		}
+		rest := make([]*store.Account, 0, len(candidates)-1)
+		for _, c := range candidates {
+			if c != acc {
+				rest = append(rest, c)
+			}
+		}
+		candidates = rest
+	}
+	return nil
 }
 
 func (lb *LoadBalancer) ReleaseConnection(accountID int64) {
@@ -189,12 +316,51 @@ func (lb *LoadBalancer) ReleaseConnection(accountID int64) {
 		for {
 			current := counter.Load()
 			if current <= 0 {
-				break
+				return
 			}
 			if counter.CompareAndSwap(current, current-1) {
 				break
 			}
 		}
+		metrics.AccountConnections.WithLabelValues(strconv.FormatInt(accountID, 10)).Dec()
+		lb.notifyRelease()
+	}
+}
+
+// releaseSignal 返回下一次连接释放时关闭的 channel
+func (lb *LoadBalancer) releaseSignal() <-chan struct{} {
+	lb.releaseMu.Lock()
+	defer lb.releaseMu.Unlock()
+	if lb.released == nil {
+		lb.released = make(chan struct{})
+	}
+	return lb.released
+}
+
+func (lb *LoadBalancer) notifyRelease() {
+	lb.releaseMu.Lock()
+	if lb.released != nil {
+		close(lb.released)
+		lb.released = nil
+	}
+	lb.releaseMu.Unlock()
+}
+
+// waitForRelease 等待 released 关闭，超过 deadline 或 ctx 结束时返回 false
+func (lb *LoadBalancer) waitForRelease(ctx context.Context, released <-chan struct{}, deadline time.Time) bool {
+	wait := time.Until(deadline)
+	if wait <= 0 {
+		return false
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-released:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
 	}
 }
 
@@ -257,11 +423,12 @@ func (lb *LoadBalancer) clearAccountStatus(ctx context.Context, acc *store.Accou
 		warp.InvalidateSession(acc.ID)
 	}
 	lb.mu.Lock()
+	previous := acc.StatusCode
 	acc.StatusCode = ""
 	acc.LastAttempt = time.Time{}
 	acc.QuotaResetAt = time.Time{}
 	lb.mu.Unlock()
-	lb.persistAccountStatus(ctx, acc, reason)
+	lb.persistAccountStatus(ctx, acc, previous, reason)
 }
 
 // MarkAccountStatus 标记账号状态（供后台刷新等外部调用使用）。
@@ -274,13 +441,14 @@ func (lb *LoadBalancer) MarkAccountStatus(ctx context.Context, acc *store.Accoun
 		lb.mu.Unlock()
 		return
 	}
+	previous := acc.StatusCode
 	acc.StatusCode = status
 	acc.LastAttempt = time.Now()
 	lb.mu.Unlock()
-	lb.persistAccountStatus(ctx, acc, "后台刷新失败: "+status)
+	lb.persistAccountStatus(ctx, acc, previous, "后台刷新失败: "+status)
 }
 
-func (lb *LoadBalancer) persistAccountStatus(ctx context.Context, acc *store.Account, reason string) {
+func (lb *LoadBalancer) persistAccountStatus(ctx context.Context, acc *store.Account, previous, reason string) {
 	if lb.Store == nil {
 		return
 	}
@@ -289,4 +457,12 @@ func (lb *LoadBalancer) persistAccountStatus(ctx context.Context, acc *store.Acc
 		return
 	}
 	slog.Info("账号状态已更新", "account_id", acc.ID, "status", acc.StatusCode, "reason", reason)
+	lb.events.Publish(events.AccountStatusChanged, events.AccountStatus{
+		AccountID:   acc.ID,
+		AccountName: acc.Name,
+		AccountType: acc.AccountType,
+		Previous:    previous,
+		Status:      acc.StatusCode,
+		Reason:      reason,
+	})
 }
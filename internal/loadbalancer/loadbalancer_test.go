@@ -1,7 +1,9 @@
 package loadbalancer
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"orchids-api/internal/store"
 )
@@ -80,3 +82,56 @@ func TestSelectAccount_ActiveConnections(t *testing.T) {
 		}
 	}
 }
+
+func TestAcquireAccount_MaxConcurrent(t *testing.T) {
+	lb := &LoadBalancer{}
+	limited := &store.Account{ID: 1, Name: "Limited", Weight: 1, MaxConcurrent: 1}
+	busy := &store.Account{ID: 2, Name: "Busy", Weight: 1}
+	for i := 0; i < 3; i++ {
+		lb.AcquireConnection(busy.ID)
+	}
+
+	if acc := lb.acquireAccount([]*store.Account{limited, busy}); acc != limited {
+		t.Fatalf("expected least loaded account, got %v", acc)
+	}
+	if !lb.saturated(limited) {
+		t.Fatal("account at max_concurrent not reported saturated")
+	}
+	// 已满的账号被跳过，即使负载评分更低
+	lb.ReleaseConnection(busy.ID)
+	lb.ReleaseConnection(busy.ID)
+	lb.ReleaseConnection(busy.ID)
+	if acc := lb.acquireAccount([]*store.Account{limited, busy}); acc != busy {
+		t.Fatalf("expected saturated account skipped, got %v", acc)
+	}
+	if acc := lb.acquireAccount([]*store.Account{limited}); acc != nil {
+		t.Fatalf("expected nil when all accounts full, got %v", acc)
+	}
+	if got := lb.ActiveConnections(limited.ID); got != 1 {
+		t.Fatalf("active connections = %d, want 1", got)
+	}
+}
+
+func TestWaitForRelease(t *testing.T) {
+	lb := &LoadBalancer{}
+	lb.AcquireConnection(1)
+
+	released := lb.releaseSignal()
+	if lb.waitForRelease(context.Background(), released, time.Now().Add(20*time.Millisecond)) {
+		t.Fatal("wait returned true without a release")
+	}
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		lb.ReleaseConnection(1)
+	}()
+	if !lb.waitForRelease(context.Background(), released, time.Now().Add(5*time.Second)) {
+		t.Fatal("release did not wake waiter")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if lb.waitForRelease(ctx, lb.releaseSignal(), time.Now().Add(5*time.Second)) {
+		t.Fatal("canceled context did not stop wait")
+	}
+}
@@ -0,0 +1,121 @@
+// Package logsink 将 slog JSON 日志额外输出到外部目标（轮转文件、syslog、Loki），
+// 供没有 stdout 日志采集的部署保留日志
+package logsink
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+
+	"orchids-api/internal/config"
+)
+
+// Sink 为一个日志目标。Write 以整行 JSON 调用，Close 负责刷出缓冲
+type Sink interface {
+	io.Writer
+	Close() error
+	Name() string
+}
+
+// Set 将日志同时写入主输出与所有 sink；单个 sink 失败不影响其他输出
+type Set struct {
+	primary io.Writer
+	sinks   []Sink
+	recent  *recentBuffer
+
+	errMu    sync.Mutex
+	reported map[string]bool
+}
+
+// Open 按配置打开所有启用的 sink。某个 sink 打开失败时返回已打开的部分与合并后的错误
+func Open(primary io.Writer, cfg *config.Config) (*Set, error) {
+	set := &Set{primary: primary, recent: newRecentBuffer(recentErrorsSize), reported: map[string]bool{}}
+	if cfg == nil {
+		return set, nil
+	}
+
+	var errs []error
+	if cfg.LogFile != "" {
+		sink, err := NewFile(cfg.LogFile, int64(cfg.LogFileMaxSizeMB)<<20, cfg.LogFileMaxBackups)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("log file: %w", err))
+		} else {
+			set.sinks = append(set.sinks, sink)
+		}
+	}
+	if cfg.LogSyslogAddr != "" {
+		sink, err := NewSyslog(cfg.LogSyslogAddr, "orchids-api")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("syslog: %w", err))
+		} else {
+			set.sinks = append(set.sinks, sink)
+		}
+	}
+	if cfg.LogLokiURL != "" {
+		set.sinks = append(set.sinks, NewLoki(cfg.LogLokiURL, cfg.LogLokiLabels))
+	}
+	return set, errors.Join(errs...)
+}
+
+// Names 返回已启用的 sink 名称
+func (s *Set) Names() []string {
+	names := make([]string, 0, len(s.sinks))
+	for _, sink := range s.sinks {
+		names = append(names, sink.Name())
+	}
+	return names
+}
+
+// Write 实现 io.Writer：返回主输出的错误；sink 的错误不向上返回，仅在首次出现时写一条提示到主输出
+func (s *Set) Write(p []byte) (int, error) {
+	if s.recent != nil {
+		s.recent.add(p)
+	}
+	for _, sink := range s.sinks {
+		if _, err := sink.Write(p); err != nil {
+			s.reportOnce(sink.Name(), err)
+		}
+	}
+	if s.primary == nil {
+		return len(p), nil
+	}
+	return s.primary.Write(p)
+}
+
+func (s *Set) reportOnce(name string, err error) {
+	s.errMu.Lock()
+	first := !s.reported[name]
+	s.reported[name] = true
+	s.errMu.Unlock()
+	if first && s.primary != nil {
+		// 直接写主输出，避免经由 slog 再次进入失败的 sink
+		fmt.Fprintf(s.primary, "{\"level\":\"WARN\",\"msg\":\"log sink write failed\",\"sink\":%q,\"error\":%q}\n", name, err.Error())
+	}
+}
+
+// RecentErrors 返回最近的 WARN/ERROR 日志（最多 200 条），按时间先后排列
+func (s *Set) RecentErrors() []json.RawMessage {
+	if s == nil || s.recent == nil {
+		return nil
+	}
+	return s.recent.snapshot()
+}
+
+// Close 依次关闭所有 sink，刷出尚未发送的日志
+func (s *Set) Close() error {
+	var errs []error
+	for _, sink := range s.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Handler 返回写入该 Set 的 JSON handler
+func (s *Set) Handler(opts *slog.HandlerOptions) slog.Handler {
+	return slog.NewJSONHandler(s, opts)
+}
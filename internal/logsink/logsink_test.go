@@ -0,0 +1,213 @@
+package logsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestFileSink_RotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "server.log")
+	sink, err := NewFile(path, 64, 2)
+	if err != nil {
+		t.Fatalf("NewFile: %v", err)
+	}
+	line := strings.Repeat("x", 39) + "\n" // 40 bytes
+	for i := 0; i < 5; i++ {
+		if _, err := sink.Write([]byte(line)); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("read %s: %v", name, err)
+		}
+		if string(data) != line {
+			t.Fatalf("%s = %q, want one line", name, data)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Fatalf("expected only 2 backups, stat .3: %v", err)
+	}
+}
+
+func TestLokiSink_FlushesOnClose(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		values [][2]string
+		labels map[string]string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Streams []struct {
+				Stream map[string]string `json:"stream"`
+				Values [][2]string       `json:"values"`
+			} `json:"streams"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decode push body: %v", err)
+		}
+		mu.Lock()
+		for _, st := range body.Streams {
+			labels = st.Stream
+			values = append(values, st.Values...)
+		}
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	sink := NewLoki(srv.URL, map[string]string{"app": "test"})
+	for i := 0; i < 3; i++ {
+		fmt.Fprintf(sink, "{\"msg\":\"line %d\"}\n", i)
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if labels["app"] != "test" || len(values) != 3 || values[2][1] != `{"msg":"line 2"}` {
+		t.Fatalf("unexpected push: labels=%v values=%v", labels, values)
+	}
+}
+
+func TestLokiSink_KeepsLinesWhenPushFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	sink := NewLoki(srv.URL, nil).(*lokiSink)
+	sink.Write([]byte("{\"msg\":\"kept\"}\n"))
+	if err := sink.Close(); err == nil {
+		t.Fatalf("expected push error")
+	}
+	if sink.batcher.Pending() != 1 {
+		t.Fatalf("pending = %d, want 1", sink.batcher.Pending())
+	}
+}
+
+func TestLokiSink_ReportsDroppedLines(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		fail   = true
+		values [][2]string
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var body struct {
+			Streams []struct {
+				Values [][2]string `json:"values"`
+			} `json:"streams"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		for _, st := range body.Streams {
+			values = append(values, st.Values...)
+		}
+	}))
+	defer srv.Close()
+
+	sink := NewLoki(srv.URL, nil).(*lokiSink)
+	for i := 0; i < lokiMaxPending+1; i++ {
+		fmt.Fprintf(sink, "{\"msg\":\"line %d\"}\n", i)
+	}
+	// 后台推送可能正带着部分日志失败重试，等到溢出的一行确实被丢弃后再恢复服务端
+	deadline := time.Now().Add(5 * time.Second)
+	for sink.dropped.Load() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("no line dropped, pending = %d", sink.batcher.Pending())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	mu.Lock()
+	fail = false
+	mu.Unlock()
+	if err := sink.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	var summaries []string
+	lines := 0
+	for _, v := range values {
+		if strings.Contains(v[1], "dropped log lines") {
+			summaries = append(summaries, v[1])
+			continue
+		}
+		lines++
+	}
+	if lines != lokiMaxPending || len(summaries) != 1 || !strings.Contains(summaries[0], `"dropped":1`) {
+		t.Fatalf("lines = %d, summaries = %v", lines, summaries)
+	}
+}
+
+type failingSink struct{ closed bool }
+
+func (f *failingSink) Write(p []byte) (int, error) { return 0, errors.New("boom") }
+func (f *failingSink) Close() error                { f.closed = true; return nil }
+func (f *failingSink) Name() string                { return "failing" }
+
+func TestSet_SinkFailureDoesNotBreakPrimary(t *testing.T) {
+	var primary bytes.Buffer
+	failing := &failingSink{}
+	set := &Set{primary: &primary, sinks: []Sink{failing}, reported: map[string]bool{}}
+
+	for i := 0; i < 2; i++ {
+		if _, err := io.WriteString(set, "{\"msg\":\"hello\"}\n"); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if got := strings.Count(primary.String(), "log sink write failed"); got != 1 {
+		t.Fatalf("sink failure reported %d times, want 1: %q", got, primary.String())
+	}
+	if got := strings.Count(primary.String(), "hello"); got != 2 {
+		t.Fatalf("primary lines = %d, want 2", got)
+	}
+	set.Close()
+	if !failing.closed {
+		t.Fatalf("sink not closed")
+	}
+}
+
+func TestSet_RecentErrorsKeepsLatestWarnings(t *testing.T) {
+	set, err := Open(io.Discard, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	set.recent = newRecentBuffer(2)
+	for _, line := range []string{
+		`{"level":"INFO","msg":"ignored"}`,
+		`{"level":"WARN","msg":"w1"}`,
+		`{"level":"ERROR","msg":"e1"}`,
+		`{"level":"ERROR","msg":"e2"}`,
+	} {
+		io.WriteString(set, line+"\n")
+	}
+
+	got := set.RecentErrors()
+	if len(got) != 2 || !strings.Contains(string(got[0]), "e1") || !strings.Contains(string(got[1]), "e2") {
+		t.Fatalf("unexpected recent errors: %s", got)
+	}
+}
@@ -0,0 +1,105 @@
+package logsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"orchids-api/internal/events"
+)
+
+const (
+	lokiFlushInterval = 2 * time.Second
+	lokiMaxBatch      = 500
+	// lokiMaxPending 为发送失败时最多保留的日志行数，超出后丢弃最旧的
+	lokiMaxPending = 10000
+	lokiTimeout    = 10 * time.Second
+)
+
+// lokiSink 将日志批量推送到 Loki push API（/loki/api/v1/push），缓冲与重试由 events.Batcher 负责
+type lokiSink struct {
+	url    string
+	labels map[string]string
+	client *http.Client
+
+	batcher *events.Batcher[[2]string]
+	// dropped 为缓冲溢出丢弃的行数，下次推送时附带一条汇总日志
+	dropped atomic.Int64
+}
+
+// NewLoki 创建 Loki sink 并启动后台推送；labels 为空时使用 {"app": "orchids-api"}
+func NewLoki(url string, labels map[string]string) Sink {
+	if len(labels) == 0 {
+		labels = map[string]string{"app": "orchids-api"}
+	}
+	s := &lokiSink{
+		url:    url,
+		labels: labels,
+		client: &http.Client{Timeout: lokiTimeout},
+	}
+	// 推送失败不写日志：日志本身会进入该 sink，Loki 不可用时会不断自我放大
+	s.batcher = events.NewBatcher(events.BatcherOptions{
+		FlushInterval: lokiFlushInterval,
+		MaxBatch:      lokiMaxBatch,
+		MaxPending:    lokiMaxPending,
+		SendTimeout:   lokiTimeout,
+		OnDrop:        func(n int) { s.dropped.Add(int64(n)) },
+	}, s.push)
+	return s
+}
+
+func (s *lokiSink) Name() string { return "loki" }
+
+func (s *lokiSink) Write(p []byte) (int, error) {
+	line := string(bytes.TrimRight(p, "\n"))
+	s.batcher.Publish([2]string{strconv.FormatInt(time.Now().UnixNano(), 10), line})
+	return len(p), nil
+}
+
+// push 推送一批日志；有丢弃的行时附带汇总日志，推送失败时计数保留到下一次
+func (s *lokiSink) push(ctx context.Context, batch [][2]string) (err error) {
+	if dropped := s.dropped.Swap(0); dropped > 0 {
+		batch = append(batch, [2]string{strconv.FormatInt(time.Now().UnixNano(), 10),
+			fmt.Sprintf(`{"level":"WARN","msg":"loki sink dropped log lines","dropped":%d}`, dropped)})
+		defer func() {
+			if err != nil {
+				s.dropped.Add(dropped)
+			}
+		}()
+	}
+
+	body, err := json.Marshal(map[string]interface{}{
+		"streams": []map[string]interface{}{
+			{"stream": s.labels, "values": batch},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("loki push: status %d: %s", resp.StatusCode, bytes.TrimSpace(msg))
+	}
+	return nil
+}
+
+// Close 停止后台推送并刷出剩余日志
+func (s *lokiSink) Close() error {
+	return s.batcher.Close()
+}
@@ -0,0 +1,54 @@
+package logsink
+
+import (
+	"bytes"
+	"encoding/json"
+	"sync"
+)
+
+// recentErrorsSize 为保留的最近 WARN/ERROR 日志条数
+const recentErrorsSize = 200
+
+// recentBuffer 在内存中保留最近的 WARN/ERROR 日志行，供支持包导出
+type recentBuffer struct {
+	mu    sync.Mutex
+	lines [][]byte
+	next  int
+	full  bool
+}
+
+func newRecentBuffer(size int) *recentBuffer {
+	return &recentBuffer{lines: make([][]byte, size)}
+}
+
+func (b *recentBuffer) add(p []byte) {
+	if !bytes.Contains(p, []byte(`"level":"ERROR"`)) && !bytes.Contains(p, []byte(`"level":"WARN"`)) {
+		return
+	}
+	line := append([]byte(nil), bytes.TrimRight(p, "\n")...)
+	b.mu.Lock()
+	b.lines[b.next] = line
+	b.next = (b.next + 1) % len(b.lines)
+	if b.next == 0 {
+		b.full = true
+	}
+	b.mu.Unlock()
+}
+
+// snapshot 按时间顺序返回保留的日志行
+func (b *recentBuffer) snapshot() []json.RawMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var ordered [][]byte
+	if b.full {
+		ordered = append(ordered, b.lines[b.next:]...)
+	}
+	ordered = append(ordered, b.lines[:b.next]...)
+	out := make([]json.RawMessage, 0, len(ordered))
+	for _, line := range ordered {
+		if json.Valid(line) {
+			out = append(out, json.RawMessage(line))
+		}
+	}
+	return out
+}
@@ -0,0 +1,45 @@
+//go:build !windows && !plan9
+
+package logsink
+
+import (
+	"fmt"
+	"log/syslog"
+	"strings"
+)
+
+type syslogSink struct {
+	w *syslog.Writer
+}
+
+// NewSyslog 连接 syslog。addr 为 "local" 时使用本机 syslog 守护进程，
+// 否则形如 "udp://host:514" 或 "tcp://host:514"
+func NewSyslog(addr, tag string) (Sink, error) {
+	var (
+		w   *syslog.Writer
+		err error
+	)
+	if addr == "local" {
+		w, err = syslog.New(syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	} else {
+		network, host, ok := strings.Cut(addr, "://")
+		if !ok || (network != "udp" && network != "tcp") || host == "" {
+			return nil, fmt.Errorf("invalid syslog address %q (want local, udp://host:port or tcp://host:port)", addr)
+		}
+		w, err = syslog.Dial(network, host, syslog.LOG_INFO|syslog.LOG_DAEMON, tag)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &syslogSink{w: w}, nil
+}
+
+func (s *syslogSink) Name() string { return "syslog" }
+
+func (s *syslogSink) Write(p []byte) (int, error) {
+	return s.w.Write(p)
+}
+
+func (s *syslogSink) Close() error {
+	return s.w.Close()
+}
@@ -0,0 +1,10 @@
+//go:build windows || plan9
+
+package logsink
+
+import "errors"
+
+// NewSyslog 在不支持 log/syslog 的平台上返回错误
+func NewSyslog(addr, tag string) (Sink, error) {
+	return nil, errors.New("syslog is not supported on this platform")
+}
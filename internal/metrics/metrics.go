@@ -39,6 +39,60 @@ var (
 		},
 	)
 
+	// AdminRequestsInflight tracks in-progress admin API requests per route pattern.
+	AdminRequestsInflight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "admin_requests_inflight",
+			Help:      "Current number of in-progress admin API requests.",
+		},
+		[]string{"route"},
+	)
+
+	// AdminRequestDuration measures admin API latency; batch operations can take minutes.
+	AdminRequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "admin_request_duration_seconds",
+			Help:      "Admin API request duration in seconds.",
+			Buckets:   []float64{.01, .05, .1, .5, 1, 5, 10, 30, 60, 120, 300, 600},
+		},
+		[]string{"route", "method", "status"},
+	)
+
+	// RequestBodyBytes measures message request body sizes per channel.
+	RequestBodyBytes = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "request_body_bytes",
+			Help:      "Message request body size in bytes.",
+			Buckets:   prometheus.ExponentialBuckets(1024, 4, 9), // 1KiB .. 64MiB
+		},
+		[]string{"channel"},
+	)
+
+	// ResponseBodyBytes measures message response sizes per channel.
+	ResponseBodyBytes = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "response_body_bytes",
+			Help:      "Message response body size in bytes.",
+			Buckets:   prometheus.ExponentialBuckets(256, 4, 9), // 256B .. 16MiB
+		},
+		[]string{"channel"},
+	)
+
+	// SSEChunks measures the number of SSE frames written per streaming response.
+	SSEChunks = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "sse_chunks",
+			Help:      "SSE frames written per streaming response.",
+			Buckets:   prometheus.ExponentialBuckets(4, 4, 8), // 4 .. 65536
+		},
+		[]string{"channel"},
+	)
+
 	// UpstreamRequestsTotal counts upstream API calls.
 	UpstreamRequestsTotal = promauto.NewCounterVec(
 		prometheus.CounterOpts{
@@ -77,7 +131,47 @@ var (
 			Name:      "cache_operations_total",
 			Help:      "Total cache operations.",
 		},
-		[]string{"cache", "result"}, // cache: "summary", result: "hit" or "miss"
+		[]string{"cache", "result"}, // cache: "summary"/"token_count"/"orchids_token", result: "hit" or "miss"
+	)
+
+	// CacheEvictions counts entries removed from caches before being read again.
+	CacheEvictions = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "cache_evictions_total",
+			Help:      "Total cache evictions.",
+		},
+		[]string{"cache", "reason"}, // reason: "capacity" or "expired"
+	)
+
+	// AdminTasks counts outcomes of background and admin-triggered maintenance tasks.
+	AdminTasks = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "admin_tasks_total",
+			Help:      "Total admin/background task runs by outcome.",
+		},
+		[]string{"task", "result"}, // task: "token_refresh"/"model_sync"/"accounts_batch", result: "success"/"failure"/"skipped"
+	)
+
+	// BusEvents counts system events delivered to event bus sinks.
+	BusEvents = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "events_total",
+			Help:      "Total event bus deliveries by sink and result.",
+		},
+		[]string{"sink", "result"}, // sink: sink name (defaults to its type), result: "sent"/"failed"/"dropped"
+	)
+
+	// SystemPromptTrims counts requests whose system prompt exceeded system_prompt_max_tokens.
+	SystemPromptTrims = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "system_prompt_trims_total",
+			Help:      "Total requests whose system prompt exceeded the token limit.",
+		},
+		[]string{"strategy"}, // "truncate", "summarize" or "reject"
 	)
 
 	// ToolCalls counts tool invocations.
@@ -100,6 +194,15 @@ var (
 		[]string{"type"},
 	)
 
+	// PanicsTotal counts handler panics recovered by middleware.Recover.
+	PanicsTotal = promauto.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "panics_total",
+			Help:      "Total panics recovered in HTTP handlers.",
+		},
+	)
+
 	// AccountConnections tracks connections per account.
 	AccountConnections = promauto.NewGaugeVec(
 		prometheus.GaugeOpts{
@@ -109,4 +212,81 @@ var (
 		},
 		[]string{"account"},
 	)
+
+	// ConcurrencyQueueLength tracks requests waiting for a concurrency limiter slot.
+	ConcurrencyQueueLength = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "concurrency_queue_length",
+			Help:      "Current number of requests waiting for a concurrency slot.",
+		},
+	)
+
+	// ConcurrencyActive tracks requests holding a concurrency limiter slot.
+	ConcurrencyActive = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "concurrency_active",
+			Help:      "Current number of requests holding a concurrency slot.",
+		},
+	)
+
+	// ConcurrencyRejectedTotal counts requests rejected by the concurrency limiter by reason (queue_full / wait_timeout).
+	ConcurrencyRejectedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "concurrency_rejected_total",
+			Help:      "Total requests rejected by the concurrency limiter.",
+		},
+		[]string{"reason"},
+	)
+
+	// ToolInjectionDetectionsTotal counts instruction-injection patterns found in new tool_result blocks by pattern and mode (log / neutralize).
+	ToolInjectionDetectionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "tool_injection_detections_total",
+			Help:      "Total prompt injection patterns detected in tool results.",
+		},
+		[]string{"pattern", "mode"},
+	)
+
+	// OutputFilterRedactionsTotal counts model output matches masked by output_filter_rules, by rule name.
+	OutputFilterRedactionsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "output_filter_redactions_total",
+			Help:      "Total model output matches masked by the output filter.",
+		},
+		[]string{"rule"},
+	)
+
+	// AccountSaturationTotal counts account selections skipped because the account reached max_concurrent.
+	AccountSaturationTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "account_saturation_total",
+			Help:      "Total times an account was skipped in selection for being at max_concurrent.",
+		},
+		[]string{"account"},
+	)
+
+	// AccountQueueLength tracks requests waiting because every candidate account is at max_concurrent.
+	AccountQueueLength = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "account_queue_length",
+			Help:      "Current number of requests waiting for an account below max_concurrent.",
+		},
+	)
+
+	// AccountQueueWaitsTotal counts queued account selections by result (acquired / timeout / full: not queued).
+	AccountQueueWaitsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "account_queue_waits_total",
+			Help:      "Total account selections that waited for a free account.",
+		},
+		[]string{"result"},
+	)
 )
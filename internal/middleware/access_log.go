@@ -0,0 +1,210 @@
+package middleware
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"time"
+
+	"orchids-api/internal/metrics"
+)
+
+// accessLogKey 是 context 中存储 *AccessLogFields 的 key
+type accessLogKey struct{}
+
+// AccessLogFields 由下游 handler 填充，请求结束时写入访问日志
+type AccessLogFields struct {
+	AccountID    int64
+	Model        string
+	InputTokens  int
+	OutputTokens int
+	// Channel 非空时按渠道记录请求/响应大小与 SSE 帧数指标
+	Channel   string
+	SSEChunks int
+}
+
+// AccessLogFieldsFrom 返回当前请求的访问日志字段，不在 AccessLog 中间件内时返回 nil。
+// 所有 setter 均可在 nil 上调用。
+func AccessLogFieldsFrom(ctx context.Context) *AccessLogFields {
+	if ctx == nil {
+		return nil
+	}
+	f, _ := ctx.Value(accessLogKey{}).(*AccessLogFields)
+	return f
+}
+
+// WithoutAccessLog 返回不携带访问日志字段的 context，用于在请求返回后继续运行的后台任务：
+// 访问日志在请求结束时读取字段，后台任务继续写入会产生数据竞争
+func WithoutAccessLog(ctx context.Context) context.Context {
+	return context.WithValue(ctx, accessLogKey{}, (*AccessLogFields)(nil))
+}
+
+// SetAccount 记录本次请求最终使用的账号
+func (f *AccessLogFields) SetAccount(id int64) {
+	if f != nil {
+		f.AccountID = id
+	}
+}
+
+// SetModel 记录客户端请求的模型
+func (f *AccessLogFields) SetModel(model string) {
+	if f != nil {
+		f.Model = model
+	}
+}
+
+// SetChannel 记录请求所属的渠道（orchids / warp）
+func (f *AccessLogFields) SetChannel(channel string) {
+	if f != nil {
+		f.Channel = channel
+	}
+}
+
+// SetSSEChunks 记录流式响应写出的 SSE 帧数
+func (f *AccessLogFields) SetSSEChunks(n int) {
+	if f != nil {
+		f.SSEChunks = n
+	}
+}
+
+// SetTokens 记录本次请求的 token 用量
+func (f *AccessLogFields) SetTokens(input, output int) {
+	if f != nil {
+		f.InputTokens = input
+		f.OutputTokens = output
+	}
+}
+
+// AccessLogOptions 配置访问日志
+type AccessLogOptions struct {
+	// SuccessSampleRate 为状态码 < 400 的请求的记录比例（0-1）；错误请求始终记录
+	SuccessSampleRate float64
+	// RouteLevels 按路径前缀覆盖成功请求的日志级别（debug/info/warn/error/off），最长前缀优先
+	RouteLevels map[string]string
+}
+
+// apiKeyIDLen 为访问日志中 API Key 摘要保留的长度，足以区分不同 key 且不泄露 key 本身
+const apiKeyIDLen = 12
+
+// AccessLog 返回访问日志中间件：错误请求全部记录，成功请求按比例采样，
+// 并附带 handler 填充的账号、模型与 token 用量
+func AccessLog(opts AccessLogOptions) func(http.Handler) http.Handler {
+	routeLevels := make(map[string]string, len(opts.RouteLevels))
+	for prefix, level := range opts.RouteLevels {
+		routeLevels[prefix] = strings.ToLower(strings.TrimSpace(level))
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			traceID := GetTraceID(r.Context())
+
+			// 包装 ResponseWriter
+			wrapped := NewTracedResponseWriter(w)
+			fields := &AccessLogFields{}
+			r = r.WithContext(context.WithValue(r.Context(), accessLogKey{}, fields))
+
+			// 记录请求开始
+			slog.Debug("Request started",
+				"trace_id", traceID,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"remote_addr", r.RemoteAddr,
+				"client_ip", ClientIP(r),
+			)
+
+			body := &countingBody{ReadCloser: r.Body}
+			if r.Body != nil && r.Body != http.NoBody {
+				r.Body = body
+			}
+
+			// 处理请求
+			next.ServeHTTP(wrapped, r)
+			if fields.Channel != "" {
+				metrics.RequestBodyBytes.WithLabelValues(fields.Channel).Observe(float64(body.n))
+				metrics.ResponseBodyBytes.WithLabelValues(fields.Channel).Observe(float64(wrapped.BytesWritten))
+				if fields.SSEChunks > 0 {
+					metrics.SSEChunks.WithLabelValues(fields.Channel).Observe(float64(fields.SSEChunks))
+				}
+			}
+
+			// 记录请求完成
+			duration := time.Since(start)
+			level := slog.LevelInfo
+			if wrapped.StatusCode >= 500 {
+				level = slog.LevelError
+			} else if wrapped.StatusCode >= 400 {
+				level = slog.LevelWarn
+			} else {
+				override, ok := matchRouteLevel(routeLevels, r.URL.Path)
+				if ok {
+					if override == "off" {
+						return
+					}
+					level = parseLevel(override, level)
+				}
+				if opts.SuccessSampleRate < 1 && (opts.SuccessSampleRate <= 0 || rand.Float64() >= opts.SuccessSampleRate) {
+					return
+				}
+			}
+			if !slog.Default().Enabled(r.Context(), level) {
+				return
+			}
+
+			attrs := []any{
+				"trace_id", traceID,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"status", wrapped.StatusCode,
+				"bytes", wrapped.BytesWritten,
+				"duration", duration,
+				"client_ip", ClientIP(r),
+			}
+			if keyID := APIKeyID(r); keyID != "" {
+				attrs = append(attrs, "api_key_id", keyID)
+			}
+			if fields.AccountID != 0 {
+				attrs = append(attrs, "account_id", fields.AccountID)
+			}
+			if fields.Model != "" {
+				attrs = append(attrs, "model", fields.Model)
+			}
+			if fields.InputTokens > 0 || fields.OutputTokens > 0 {
+				attrs = append(attrs, "input_tokens", fields.InputTokens, "output_tokens", fields.OutputTokens)
+			}
+			slog.Log(r.Context(), level, "Request completed", attrs...)
+		})
+	}
+}
+
+// countingBody 统计 handler 实际读取的请求体字节数
+type countingBody struct {
+	io.ReadCloser
+	n int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func matchRouteLevel(routeLevels map[string]string, path string) (string, bool) {
+	best, level := -1, ""
+	for prefix, l := range routeLevels {
+		if strings.HasPrefix(path, prefix) && len(prefix) > best {
+			best, level = len(prefix), l
+		}
+	}
+	return level, best >= 0
+}
+
+func parseLevel(s string, fallback slog.Level) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return fallback
+	}
+	return level
+}
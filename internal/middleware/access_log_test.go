@@ -0,0 +1,136 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"orchids-api/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func captureAccessLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})))
+	t.Cleanup(func() { slog.SetDefault(prev) })
+	return &buf
+}
+
+func completedRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var out []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var rec map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &rec); err != nil {
+			t.Fatalf("invalid log line %q: %v", line, err)
+		}
+		if rec["msg"] == "Request completed" {
+			out = append(out, rec)
+		}
+	}
+	return out
+}
+
+func TestAccessLog_SamplesSuccessesButKeepsErrors(t *testing.T) {
+	buf := captureAccessLog(t)
+	status := http.StatusOK
+	handler := AccessLog(AccessLogOptions{SuccessSampleRate: -1})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/models", nil))
+	status = http.StatusBadGateway
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/v1/models", nil))
+
+	records := completedRecords(t, buf)
+	if len(records) != 1 || records[0]["status"] != float64(http.StatusBadGateway) {
+		t.Fatalf("unexpected records: %v", records)
+	}
+}
+
+func TestAccessLog_RouteLevelOverride(t *testing.T) {
+	buf := captureAccessLog(t)
+	handler := AccessLog(AccessLogOptions{
+		SuccessSampleRate: 1,
+		RouteLevels:       map[string]string{"/api/": "debug", "/api/accounts": "warn", "/metrics": "off"},
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	for _, path := range []string{"/metrics", "/api/keys", "/api/accounts/1"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	records := completedRecords(t, buf)
+	if len(records) != 1 || records[0]["path"] != "/api/accounts/1" || records[0]["level"] != "WARN" {
+		t.Fatalf("unexpected records: %v", records)
+	}
+}
+
+func TestAccessLog_IncludesHandlerFields(t *testing.T) {
+	buf := captureAccessLog(t)
+	handler := AccessLog(AccessLogOptions{SuccessSampleRate: 1})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fields := AccessLogFieldsFrom(r.Context())
+		fields.SetAccount(7)
+		fields.SetModel("claude-sonnet-4-5")
+		fields.SetTokens(120, 30)
+	}))
+
+	req := httptest.NewRequest("POST", "/orchids/v1/messages", nil)
+	req.Header.Set("x-api-key", "sk-test")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	records := completedRecords(t, buf)
+	if len(records) != 1 {
+		t.Fatalf("expected one record, got %v", records)
+	}
+	rec := records[0]
+	if rec["account_id"] != float64(7) || rec["model"] != "claude-sonnet-4-5" ||
+		rec["input_tokens"] != float64(120) || rec["output_tokens"] != float64(30) {
+		t.Fatalf("missing handler fields: %v", rec)
+	}
+	if rec["api_key_id"] != HashAPIKey("sk-test")[:apiKeyIDLen] {
+		t.Fatalf("api_key_id = %v", rec["api_key_id"])
+	}
+
+	// 不在中间件内时 setter 为空操作
+	AccessLogFieldsFrom(httptest.NewRequest("GET", "/", nil).Context()).SetAccount(1)
+}
+
+func TestAccessLog_RecordsChannelSizeHistograms(t *testing.T) {
+	captureAccessLog(t)
+	handler := AccessLog(AccessLogOptions{SuccessSampleRate: -1})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fields := AccessLogFieldsFrom(r.Context())
+		fields.SetChannel("test-size")
+		io.ReadAll(r.Body)
+		w.Write([]byte("data: {}\n\ndata: [DONE]\n\n"))
+		fields.SetSSEChunks(2)
+	}))
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/v1/messages", strings.NewReader(`{"model":"x"}`)))
+
+	observe := func(h *prometheus.HistogramVec) (uint64, float64) {
+		var m dto.Metric
+		h.WithLabelValues("test-size").(prometheus.Histogram).Write(&m)
+		return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+	}
+	if n, sum := observe(metrics.RequestBodyBytes); n != 1 || sum != 13 {
+		t.Fatalf("request bytes = (%d, %v), want (1, 13)", n, sum)
+	}
+	if n, sum := observe(metrics.ResponseBodyBytes); n != 1 || sum != 24 {
+		t.Fatalf("response bytes = (%d, %v), want (1, 24)", n, sum)
+	}
+	if n, sum := observe(metrics.SSEChunks); n != 1 || sum != 2 {
+		t.Fatalf("sse chunks = (%d, %v), want (1, 2)", n, sum)
+	}
+}
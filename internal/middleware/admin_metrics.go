@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"orchids-api/internal/metrics"
+)
+
+// adminRoutePrefixes 为计入管理接口指标的路由前缀
+var adminRoutePrefixes = []string{"/api/", "/debug/pprof/"}
+
+// AdminMetrics 为管理接口记录按路由的进行中请求数（orchids_admin_requests_inflight）与耗时
+// （orchids_admin_request_duration_seconds）。route 返回请求匹配到的路由模式（通常为 ServeMux.Handler 的 pattern），
+// 用作指标标签，避免路径中的 ID 造成标签基数膨胀；不属于管理接口的请求直接放行
+func AdminMetrics(route func(r *http.Request) string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pattern := route(r)
+			if !isAdminRoute(pattern) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			inflight := metrics.AdminRequestsInflight.WithLabelValues(pattern)
+			inflight.Inc()
+			start := time.Now()
+			wrapped := NewTracedResponseWriter(w)
+			defer func() {
+				inflight.Dec()
+				metrics.AdminRequestDuration.
+					WithLabelValues(pattern, r.Method, strconv.Itoa(wrapped.StatusCode)).
+					Observe(time.Since(start).Seconds())
+			}()
+			next.ServeHTTP(wrapped, r)
+		})
+	}
+}
+
+func isAdminRoute(pattern string) bool {
+	for _, prefix := range adminRoutePrefixes {
+		if strings.HasPrefix(pattern, prefix) {
+			return true
+		}
+	}
+	return false
+}
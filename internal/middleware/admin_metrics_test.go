@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"orchids-api/internal/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestAdminMetrics_RecordsByRoutePattern(t *testing.T) {
+	mux := http.NewServeMux()
+	var inflightDuring float64
+	mux.HandleFunc("/api/accounts/", func(w http.ResponseWriter, r *http.Request) {
+		var m dto.Metric
+		metrics.AdminRequestsInflight.WithLabelValues("/api/accounts/").Write(&m)
+		inflightDuring = m.GetGauge().GetValue()
+		w.WriteHeader(http.StatusAccepted)
+	})
+	mux.HandleFunc("/v1/messages", func(w http.ResponseWriter, r *http.Request) {})
+	handler := AdminMetrics(func(r *http.Request) string {
+		_, pattern := mux.Handler(r)
+		return pattern
+	})(mux)
+
+	histogramCount := func(route, status string) uint64 {
+		var m dto.Metric
+		metrics.AdminRequestDuration.WithLabelValues(route, http.MethodPost, status).(prometheus.Histogram).Write(&m)
+		return m.GetHistogram().GetSampleCount()
+	}
+	before := histogramCount("/api/accounts/", "202")
+
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/api/accounts/42/refresh", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/v1/messages", nil))
+
+	if inflightDuring != 1 {
+		t.Fatalf("inflight during request = %v, want 1", inflightDuring)
+	}
+	var m dto.Metric
+	metrics.AdminRequestsInflight.WithLabelValues("/api/accounts/").Write(&m)
+	if got := m.GetGauge().GetValue(); got != 0 {
+		t.Fatalf("inflight after request = %v, want 0", got)
+	}
+	if got := histogramCount("/api/accounts/", "202") - before; got != 1 {
+		t.Fatalf("duration samples = %d, want 1", got)
+	}
+	if got := histogramCount("/v1/messages", "200"); got != 0 {
+		t.Fatalf("non-admin route recorded %d samples", got)
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// APIKeyFromRequest 从 x-api-key 或 Authorization: Bearer 中提取客户端 API Key
+func APIKeyFromRequest(r *http.Request) string {
+	if r == nil {
+		return ""
+	}
+	if key := strings.TrimSpace(r.Header.Get("x-api-key")); key != "" {
+		return key
+	}
+	authHeader := strings.TrimSpace(r.Header.Get("Authorization"))
+	if len(authHeader) > 7 && strings.EqualFold(authHeader[:7], "Bearer ") {
+		return strings.TrimSpace(authHeader[7:])
+	}
+	return ""
+}
+
+// HashAPIKey 返回与 store.ApiKey.KeyHash 一致的 sha256 十六进制摘要，空 key 返回空字符串
+func HashAPIKey(key string) string {
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyID 返回请求 API Key 摘要的前 12 位，用于日志与按 key 灰度；无 key 时返回空字符串
+func APIKeyID(r *http.Request) string {
+	hash := HashAPIKey(APIKeyFromRequest(r))
+	if hash == "" {
+		return ""
+	}
+	return hash[:apiKeyIDLen]
+}
@@ -0,0 +1,95 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type basePathKey struct{}
+
+// BasePathFrom 返回 BasePath 中间件去掉的路径前缀，未配置 base_path 时为空。
+// 在响应体中返回站内地址（如长轮询的 events_url）时需拼在前面
+func BasePathFrom(r *http.Request) string {
+	base, _ := r.Context().Value(basePathKey{}).(string)
+	return base
+}
+
+// BasePath 返回路径前缀中间件，用于部署在不剥离前缀的反向代理之后（如 https://example.com/ai-gateway/）。
+// 请求去掉 base 后交给后续中间件与路由，base 以外的路径返回 404，仅访问 base 时重定向到 base/；
+// 响应中以 / 开头的 Location 自动补上 base，管理界面的登录跳转与路由的斜杠重定向不会跳出前缀。
+// base 为空时直接返回 next。放在中间件链最外层
+func BasePath(base string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if base == "" {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == base {
+				target := base + "/"
+				if r.URL.RawQuery != "" {
+					target += "?" + r.URL.RawQuery
+				}
+				http.Redirect(w, r, target, http.StatusMovedPermanently)
+				return
+			}
+			path, ok := strings.CutPrefix(r.URL.Path, base)
+			if !ok || !strings.HasPrefix(path, "/") {
+				http.NotFound(w, r)
+				return
+			}
+			rawPath := ""
+			if r.URL.RawPath != "" {
+				if rawPath, ok = strings.CutPrefix(r.URL.RawPath, base); !ok {
+					http.NotFound(w, r)
+					return
+				}
+			}
+			r2 := r.WithContext(context.WithValue(r.Context(), basePathKey{}, base))
+			r2.URL = new(url.URL)
+			*r2.URL = *r.URL
+			r2.URL.Path = path
+			r2.URL.RawPath = rawPath
+			next.ServeHTTP(&basePathWriter{ResponseWriter: w, base: base}, r2)
+		})
+	}
+}
+
+// basePathWriter 在写出响应头前为站内绝对路径的 Location 补上 base
+type basePathWriter struct {
+	http.ResponseWriter
+	base string
+}
+
+// WriteHeader 实现 http.ResponseWriter
+func (w *basePathWriter) WriteHeader(code int) {
+	if loc := w.Header().Get("Location"); strings.HasPrefix(loc, "/") && !strings.HasPrefix(loc, "//") {
+		w.Header().Set("Location", w.base+loc)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Flush 实现 http.Flusher
+func (w *basePathWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 实现 http.Hijacker，供 WebSocket 升级使用
+func (w *basePathWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	return hj.Hijack()
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter
+func (w *basePathWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
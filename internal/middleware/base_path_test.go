@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestBasePath(t *testing.T) {
+	mux := http.NewServeMux()
+	var gotPath string
+	mux.HandleFunc("/admin/", func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		http.Redirect(w, r, "/admin/login.html", http.StatusFound)
+	})
+	mux.HandleFunc("/v1/models", func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+	})
+	h := BasePath("/ai-gateway")(mux)
+	do := func(path string) *httptest.ResponseRecorder {
+		gotPath = ""
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	if rec := do("/ai-gateway/v1/models?x=1"); rec.Code != http.StatusOK || gotPath != "/v1/models" {
+		t.Fatalf("stripped: %d %q", rec.Code, gotPath)
+	}
+	if rec := do("/ai-gateway/admin/"); rec.Code != http.StatusFound || rec.Header().Get("Location") != "/ai-gateway/admin/login.html" {
+		t.Fatalf("handler redirect: %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	// 路由自身的斜杠重定向同样保持在前缀内
+	if rec := do("/ai-gateway/admin"); rec.Header().Get("Location") != "/ai-gateway/admin/" {
+		t.Fatalf("mux redirect: %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	if rec := do("/ai-gateway"); rec.Code != http.StatusMovedPermanently || rec.Header().Get("Location") != "/ai-gateway/" {
+		t.Fatalf("bare base: %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	for _, path := range []string{"/v1/models", "/ai-gatewayx/v1/models"} {
+		if rec := do(path); rec.Code != http.StatusNotFound || gotPath != "" {
+			t.Errorf("%s: %d %q", path, rec.Code, gotPath)
+		}
+	}
+
+	// 未配置时不做改写
+	rec := httptest.NewRecorder()
+	BasePath("")(mux).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/models", nil))
+	if rec.Code != http.StatusOK || gotPath != "/v1/models" {
+		t.Fatalf("disabled: %d %q", rec.Code, gotPath)
+	}
+}
+
+func TestBasePathFrom(t *testing.T) {
+	var got string
+	h := BasePath("/ai-gateway")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = BasePathFrom(r)
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/ai-gateway/v1/models", nil))
+	if got != "/ai-gateway" {
+		t.Fatalf("BasePathFrom = %q", got)
+	}
+	if got := BasePathFrom(httptest.NewRequest(http.MethodGet, "/v1/models", nil)); got != "" {
+		t.Fatalf("BasePathFrom without middleware = %q", got)
+	}
+}
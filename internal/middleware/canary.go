@@ -0,0 +1,202 @@
+package middleware
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CanaryHeader 标记已被转发到 canary 的请求，canary 实例收到后不会再次转发，避免两个实例互相转发
+const CanaryHeader = "X-Orchids-Canary"
+
+// 参与分流的消息接口（需带渠道前缀），长轮询、取消与 WebSocket 等有实例状态的接口不分流
+var canaryRouteSuffixes = []string{"/v1/messages", "/v1/messages/count_tokens", "/v1/chat/completions"}
+
+// CanaryTargetStats 为一个目标（primary / canary）的累计统计
+type CanaryTargetStats struct {
+	Requests     int64            `json:"requests"`
+	Errors       int64            `json:"errors"`
+	ErrorRate    float64          `json:"error_rate"`
+	AvgLatencyMs float64          `json:"avg_latency_ms"`
+	MaxLatencyMs int64            `json:"max_latency_ms"`
+	Statuses     map[string]int64 `json:"statuses"`
+}
+
+// CanaryStats 为 /api/canary 返回的对比结果
+type CanaryStats struct {
+	URL     string            `json:"url"`
+	Percent float64           `json:"percent"`
+	Since   time.Time         `json:"since"`
+	Primary CanaryTargetStats `json:"primary"`
+	Canary  CanaryTargetStats `json:"canary"`
+}
+
+type canaryCounter struct {
+	requests  int64
+	errors    int64
+	latencyMs int64
+	maxMs     int64
+	statuses  map[string]int64 // 按 2xx / 4xx / 5xx 等分类
+}
+
+func (c *canaryCounter) record(status int, elapsed time.Duration) {
+	ms := elapsed.Milliseconds()
+	c.requests++
+	c.latencyMs += ms
+	if ms > c.maxMs {
+		c.maxMs = ms
+	}
+	if status >= http.StatusInternalServerError {
+		c.errors++
+	}
+	if c.statuses == nil {
+		c.statuses = map[string]int64{}
+	}
+	c.statuses[strconv.Itoa(status/100)+"xx"]++
+}
+
+func (c *canaryCounter) snapshot() CanaryTargetStats {
+	st := CanaryTargetStats{
+		Requests:     c.requests,
+		Errors:       c.errors,
+		MaxLatencyMs: c.maxMs,
+		Statuses:     map[string]int64{},
+	}
+	for k, v := range c.statuses {
+		st.Statuses[k] = v
+	}
+	if c.requests > 0 {
+		st.ErrorRate = float64(c.errors) / float64(c.requests)
+		st.AvgLatencyMs = float64(c.latencyMs) / float64(c.requests)
+	}
+	return st
+}
+
+// CanarySplitter 按比例把消息请求转发到 canary 实例，并分别统计本实例（primary）与 canary 的
+// 状态码与耗时（到响应结束，含流式输出），用于用真实流量验证新版本。
+// target 每个请求调用一次以读取当前配置，URL 为空或比例 <= 0 时不分流
+type CanarySplitter struct {
+	target func() (rawURL string, percent float64)
+
+	mu      sync.Mutex
+	url     string
+	proxy   *httputil.ReverseProxy
+	since   time.Time
+	primary canaryCounter
+	canary  canaryCounter
+}
+
+// NewCanarySplitter 创建分流器
+func NewCanarySplitter(target func() (rawURL string, percent float64)) *CanarySplitter {
+	return &CanarySplitter{target: target, since: time.Now()}
+}
+
+// Middleware 返回分流中间件
+func (s *CanarySplitter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || !isCanaryRoute(r.URL.Path) || r.Header.Get(CanaryHeader) != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rawURL, percent := s.target()
+		proxy := s.proxyFor(rawURL)
+		if proxy == nil || percent <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		wrapped := NewTracedResponseWriter(w)
+		start := time.Now()
+		toCanary := rand.Float64()*100 < percent
+		if toCanary {
+			r.Header.Set(CanaryHeader, "1")
+			proxy.ServeHTTP(wrapped, r)
+		} else {
+			next.ServeHTTP(wrapped, r)
+		}
+		elapsed := time.Since(start)
+
+		s.mu.Lock()
+		if toCanary {
+			s.canary.record(wrapped.StatusCode, elapsed)
+		} else {
+			s.primary.record(wrapped.StatusCode, elapsed)
+		}
+		s.mu.Unlock()
+	})
+}
+
+// proxyFor 返回 rawURL 对应的反向代理，URL 变化时重建并清零统计，使对比结果只针对当前 canary
+func (s *CanarySplitter) proxyFor(rawURL string) *httputil.ReverseProxy {
+	rawURL = strings.TrimSpace(rawURL)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if rawURL == s.url {
+		return s.proxy
+	}
+	s.url = rawURL
+	s.proxy = nil
+	s.since = time.Now()
+	s.primary, s.canary = canaryCounter{}, canaryCounter{}
+	if rawURL == "" {
+		return nil
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		slog.Warn("Invalid canary URL, traffic splitting disabled", "url", rawURL, "error", err)
+		return nil
+	}
+	proxy := httputil.NewSingleHostReverseProxy(u)
+	// SSE 需要立即刷新
+	proxy.FlushInterval = -1
+	proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		slog.Warn("Canary request failed", "trace_id", GetTraceID(r.Context()), "path", r.URL.Path, "error", err)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(`{"type":"error","error":{"type":"api_error","message":"Canary upstream unavailable"}}`))
+	}
+	s.proxy = proxy
+	return proxy
+}
+
+// Stats 返回当前 canary 配置与两侧的累计统计
+func (s *CanarySplitter) Stats() CanaryStats {
+	rawURL, percent := s.target()
+	s.proxyFor(rawURL)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return CanaryStats{
+		URL:     s.url,
+		Percent: percent,
+		Since:   s.since,
+		Primary: s.primary.snapshot(),
+		Canary:  s.canary.snapshot(),
+	}
+}
+
+// Reset 清零统计
+func (s *CanarySplitter) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.since = time.Now()
+	s.primary, s.canary = canaryCounter{}, canaryCounter{}
+}
+
+func isCanaryRoute(path string) bool {
+	// 只转发带渠道前缀的接口（前缀可配置），不含 /v1/... 公共接口
+	if strings.HasPrefix(path, "/v1/") || !isV1Route(path) {
+		return false
+	}
+	for _, suffix := range canaryRouteSuffixes {
+		if strings.HasSuffix(path, suffix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCanarySplitter_ForwardsAndRecords(t *testing.T) {
+	var canaryHeader string
+	canarySrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		canaryHeader = r.Header.Get(CanaryHeader)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer canarySrv.Close()
+
+	percent := 100.0
+	s := NewCanarySplitter(func() (string, float64) { return canarySrv.URL, percent })
+	primaryHits := 0
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHits++
+	}))
+	post := func(path string, header string) int {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{}`))
+		if header != "" {
+			req.Header.Set(CanaryHeader, header)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := post("/orchids/v1/messages", ""); code != http.StatusBadGateway || canaryHeader != "1" {
+		t.Fatalf("canary forward: code=%d header=%q", code, canaryHeader)
+	}
+	// 已转发过的请求和不参与分流的接口留在本实例
+	post("/warp/v1/chat/completions", "1")
+	post("/orchids/v1/streams", "")
+	percent = 0
+	post("/orchids/v1/messages", "")
+
+	if primaryHits != 3 {
+		t.Fatalf("primary hits = %d, want 3", primaryHits)
+	}
+	st := s.Stats()
+	if st.Canary.Requests != 1 || st.Canary.Errors != 1 || st.Canary.Statuses["5xx"] != 1 {
+		t.Fatalf("canary stats = %+v", st.Canary)
+	}
+	// percent 为 0 时不分流也不统计
+	if st.Primary.Requests != 0 {
+		t.Fatalf("primary stats = %+v", st.Primary)
+	}
+
+	percent = 100
+	s.Reset()
+	if st := s.Stats(); st.Canary.Requests != 0 || st.URL != canarySrv.URL {
+		t.Fatalf("stats after reset = %+v", st)
+	}
+}
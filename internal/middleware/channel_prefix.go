@@ -0,0 +1,55 @@
+package middleware
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// ChannelPrefixes 返回渠道前缀改写中间件，使部署可以用自定义前缀（如 /ai）代替 /orchids、/warp，
+// 不暴露实际使用的上游。prefixes 每个请求调用一次，返回前缀到渠道的映射（如 {"/ai": "orchids"}），
+// 为空时保持默认的 /{channel}/... 路由。配置后只接受映射中的前缀：匹配的请求改写为 /{channel}/... 交给路由，
+// 未出现在映射中的默认前缀返回 404。放在中间件链最内层，签名校验与 canary 转发看到的仍是客户端请求的路径
+func ChannelPrefixes(prefixes func() map[string]string, channels ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mapping := prefixes()
+			if len(mapping) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			path := r.URL.Path
+			if prefix, channel, ok := matchChannelPrefix(mapping, path); ok {
+				if prefix == "/"+channel {
+					next.ServeHTTP(w, r)
+					return
+				}
+				r2 := new(http.Request)
+				*r2 = *r
+				r2.URL = new(url.URL)
+				*r2.URL = *r.URL
+				r2.URL.Path = "/" + channel + strings.TrimPrefix(path, prefix)
+				r2.URL.RawPath = ""
+				next.ServeHTTP(w, r2)
+				return
+			}
+			for _, channel := range channels {
+				if strings.HasPrefix(path, "/"+channel+"/") {
+					http.NotFound(w, r)
+					return
+				}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// matchChannelPrefix 返回 path 匹配的最长前缀及其渠道
+func matchChannelPrefix(mapping map[string]string, path string) (prefix, channel string, ok bool) {
+	for p, ch := range mapping {
+		if strings.HasPrefix(path, p+"/") && len(p) > len(prefix) {
+			prefix, channel, ok = p, ch, true
+		}
+	}
+	return prefix, channel, ok
+}
@@ -0,0 +1,66 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestChannelPrefixes(t *testing.T) {
+	mapping := map[string]string{}
+	var gotPath string
+	h := ChannelPrefixes(func() map[string]string { return mapping }, "orchids", "warp")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+	}))
+	do := func(path string) (int, string) {
+		gotPath = ""
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, nil))
+		return rec.Code, gotPath
+	}
+
+	// 未配置时保持默认前缀
+	if code, path := do("/warp/v1/messages"); code != http.StatusOK || path != "/warp/v1/messages" {
+		t.Fatalf("default: %d %q", code, path)
+	}
+
+	mapping["/ai"] = "orchids"
+	mapping["/ai-fast"] = "warp"
+	mapping["/warp"] = "warp"
+	cases := []struct {
+		in   string
+		code int
+		want string
+	}{
+		{"/ai/v1/messages", http.StatusOK, "/orchids/v1/messages"},
+		{"/ai-fast/v1/chat/completions", http.StatusOK, "/warp/v1/chat/completions"},
+		{"/warp/v1/models", http.StatusOK, "/warp/v1/models"},
+		{"/orchids/v1/messages", http.StatusNotFound, ""},
+		{"/v1/models", http.StatusOK, "/v1/models"},
+		{"/aix/v1/messages", http.StatusOK, "/aix/v1/messages"},
+	}
+	for _, c := range cases {
+		code, path := do(c.in)
+		if code != c.code || path != c.want {
+			t.Errorf("%s: got %d %q, want %d %q", c.in, code, path, c.code, c.want)
+		}
+	}
+}
+
+func TestIsV1Route_CustomPrefixes(t *testing.T) {
+	for path, want := range map[string]bool{
+		"/v1/models":           true,
+		"/orchids/v1/messages": true,
+		"/ai/v1/messages":      true,
+		"/api/accounts":        false,
+		"/ai/v2/messages":      false,
+		"/health":              false,
+	} {
+		if got := isV1Route(path); got != want {
+			t.Errorf("isV1Route(%q) = %v, want %v", path, got, want)
+		}
+	}
+	if isCanaryRoute("/v1/messages") || !isCanaryRoute("/ai/v1/messages") {
+		t.Fatal("canary routes should cover custom channel prefixes only")
+	}
+}
@@ -0,0 +1,116 @@
+package middleware
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+)
+
+type clientIPKey struct{}
+
+// ClientIP 返回 RealIP 中间件解析出的客户端 IP；未经过该中间件时返回 RemoteAddr 中的地址
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteHost(r.RemoteAddr)
+}
+
+// RealIP 返回客户端 IP 解析中间件。trusted 每个请求调用一次，返回受信任代理的 IP 或 CIDR 列表（trusted_proxies）。
+// 只有直接连接的对端属于受信任代理时才读取 X-Forwarded-For / X-Real-IP：X-Forwarded-For 从右向左跳过受信任代理，
+// 第一个不受信任的地址即为客户端；未配置时始终使用连接的对端地址，请求头可被客户端伪造，不会被采信。
+// 经 Unix socket 连接的请求来自本机反向代理，视为受信任。解析结果通过 ClientIP 读取，日志、审计与限流统一使用
+func RealIP(trusted func() []string) func(http.Handler) http.Handler {
+	var (
+		mu       sync.Mutex
+		lastRaw  []string
+		prefixes []netip.Prefix
+	)
+	proxies := func() []netip.Prefix {
+		raw := trusted()
+		mu.Lock()
+		defer mu.Unlock()
+		if !slices.Equal(raw, lastRaw) {
+			lastRaw = slices.Clone(raw)
+			prefixes = ParseTrustedProxies(raw)
+		}
+		return prefixes
+	}
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ip := resolveClientIP(r, proxies())
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPKey{}, ip)))
+		})
+	}
+}
+
+// ParseTrustedProxies 解析 IP 或 CIDR 列表，无法解析的项被忽略（由配置校验报告）
+func ParseTrustedProxies(raw []string) []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, s := range raw {
+		s = strings.TrimSpace(s)
+		if p, err := netip.ParsePrefix(s); err == nil {
+			prefixes = append(prefixes, p.Masked())
+		} else if addr, err := netip.ParseAddr(s); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+		}
+	}
+	return prefixes
+}
+
+func resolveClientIP(r *http.Request, proxies []netip.Prefix) string {
+	remote := remoteHost(r.RemoteAddr)
+	if len(proxies) == 0 {
+		return remote
+	}
+	peer, err := netip.ParseAddr(remote)
+	if err == nil && !isTrustedProxy(peer, proxies) {
+		return remote
+	}
+
+	// X-Forwarded-For 可能有多个请求头，每个头内逗号分隔，按出现顺序连接
+	var hops []string
+	for _, v := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(v, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(hops[i])
+		if err != nil {
+			// 无法解析的地址之前的内容不可信，停在这里
+			break
+		}
+		if !isTrustedProxy(addr, proxies) || i == 0 {
+			return addr.Unmap().String()
+		}
+	}
+	if addr, err := netip.ParseAddr(strings.TrimSpace(r.Header.Get("X-Real-IP"))); err == nil {
+		return addr.Unmap().String()
+	}
+	return remote
+}
+
+func isTrustedProxy(addr netip.Addr, proxies []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, p := range proxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteHost 去掉 RemoteAddr 中的端口；Unix socket 连接的 RemoteAddr 为空或 "@"
+func remoteHost(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRealIP(t *testing.T) {
+	trusted := []string{}
+	var got string
+	h := RealIP(func() []string { return trusted })(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = ClientIP(r)
+	}))
+	do := func(remote string, headers map[string]string) string {
+		req := httptest.NewRequest(http.MethodGet, "/v1/models", nil)
+		req.RemoteAddr = remote
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		h.ServeHTTP(httptest.NewRecorder(), req)
+		return got
+	}
+
+	// 未配置受信任代理时忽略请求头
+	if ip := do("203.0.113.7:4321", map[string]string{"X-Forwarded-For": "1.2.3.4"}); ip != "203.0.113.7" {
+		t.Fatalf("untrusted config: %q", ip)
+	}
+
+	trusted = []string{"10.0.0.0/8", "192.168.1.1"}
+	cases := []struct {
+		name    string
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"direct client", "203.0.113.7:4321", map[string]string{"X-Forwarded-For": "1.2.3.4"}, "203.0.113.7"},
+		{"single proxy", "10.1.2.3:80", map[string]string{"X-Forwarded-For": "198.51.100.9"}, "198.51.100.9"},
+		{"spoofed left hops", "10.1.2.3:80", map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.9, 192.168.1.1"}, "198.51.100.9"},
+		{"all trusted", "10.1.2.3:80", map[string]string{"X-Forwarded-For": "10.0.0.5, 10.0.0.6"}, "10.0.0.5"},
+		{"real ip header", "192.168.1.1:80", map[string]string{"X-Real-IP": "198.51.100.10"}, "198.51.100.10"},
+		{"no headers", "10.1.2.3:80", nil, "10.1.2.3"},
+		{"ipv4-mapped", "[::ffff:10.1.2.3]:80", map[string]string{"X-Forwarded-For": "2001:db8::1"}, "2001:db8::1"},
+		{"unix socket", "@", map[string]string{"X-Forwarded-For": "198.51.100.11"}, "198.51.100.11"},
+	}
+	for _, c := range cases {
+		if ip := do(c.remote, c.headers); ip != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, ip, c.want)
+		}
+	}
+}
@@ -2,14 +2,28 @@ package middleware
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log/slog"
 	"net/http"
 	"sort"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"golang.org/x/sync/semaphore"
+
+	"orchids-api/internal/metrics"
+)
+
+// StatusOverloaded 为过载时返回的状态码，与 Anthropic API 的 529 overloaded_error 一致
+const StatusOverloaded = 529
+
+// 过载响应中的请求头：当前排队的请求数与并发上限
+const (
+	QueueDepthHeader       = "X-Queue-Depth"
+	ConcurrencyLimitHeader = "X-Concurrency-Limit"
 )
 
 // ConcurrencyLimiter limits concurrent request processing using a weighted semaphore.
@@ -19,9 +33,19 @@ type ConcurrencyLimiter struct {
 	maxConcurrent int64
 	timeout       time.Duration
 	activeCount   int64
+	queuedCount   int64
 	totalReqs     int64
 	rejectedReqs  int64
 
+	// maxQueue 返回排队上限，超过时立即拒绝而不是继续排队（<= 0 不限制）
+	maxQueue func() int
+
+	// 按 API key 公平排队（SetFairShare）：fairEnabled 返回 true 时排队请求进入 fair，
+	// 释放的槽位直接交给 fair 选出的下一个请求
+	fairEnabled func() bool
+	fairMu      sync.Mutex
+	fair        *fairQueue
+
 	// Adaptive timeout
 	adaptive      bool
 	latencyWindow []int64 // Milliseconds
@@ -48,51 +72,40 @@ func NewConcurrencyLimiter(maxConcurrent int, timeout time.Duration, adaptive bo
 	}
 }
 
-func (cl *ConcurrencyLimiter) Limit(next http.HandlerFunc) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		atomic.AddInt64(&cl.totalReqs, 1)
-
-		// Calculate wait timeout
-		waitTimeout := 60 * time.Second
-		if cl.adaptive {
-			p95 := cl.GetP95()
-			if p95 > 0 {
-				// Allow 1.5x P95 wait time, clamped
-				calcWait := time.Duration(float64(p95)*1.5) * time.Millisecond
-				if calcWait < 5*time.Second {
-					waitTimeout = 5 * time.Second
-				} else if calcWait > 60*time.Second {
-					waitTimeout = 60 * time.Second
-				} else {
-					waitTimeout = calcWait
-				}
-			}
-		}
+// SetMaxQueue 设置排队上限，limit 每个请求调用一次以读取当前配置
+func (cl *ConcurrencyLimiter) SetMaxQueue(limit func() int) {
+	cl.maxQueue = limit
+}
 
-		if cl.timeout < waitTimeout {
-			waitTimeout = cl.timeout
-		}
+// SetFairShare 启用按 API key 的公平调度：enabled 每个请求调用一次以读取当前配置，
+// weight 返回 key 的权重（<= 0 按 1），排队的 key 按权重比例轮流获得释放的槽位
+func (cl *ConcurrencyLimiter) SetFairShare(enabled func() bool, weight func(key string) int) {
+	cl.fairEnabled = enabled
+	cl.fair = newFairQueue(weight)
+}
 
-		waitCtx, cancelWait := context.WithTimeout(r.Context(), waitTimeout)
-		defer cancelWait()
+// QueueLength 返回当前等待并发槽位的请求数
+func (cl *ConcurrencyLimiter) QueueLength() int64 {
+	return atomic.LoadInt64(&cl.queuedCount)
+}
 
-		// Try to acquire semaphore with wait timeout
-		acquireStart := time.Now()
-		if err := cl.sem.Acquire(waitCtx, 1); err != nil {
+func (cl *ConcurrencyLimiter) Limit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&cl.totalReqs, 1)
+		if err := cl.acquire(r.Context(), APIKeyID(r)); err != nil {
 			atomic.AddInt64(&cl.rejectedReqs, 1)
-			slog.Warn("Concurrency limit: Wait timeout", "duration", time.Since(acquireStart), "total_rejected", atomic.LoadInt64(&cl.rejectedReqs), "wait_timeout", waitTimeout)
-			http.Error(w, "Request timed out while waiting for a worker slot or server busy", http.StatusServiceUnavailable)
+			cl.writeOverloaded(w, err.Error())
 			return
 		}
 
-		slog.Debug("Concurrency limit: Slot acquired", "wait_duration", time.Since(acquireStart), "active", atomic.LoadInt64(&cl.activeCount)+1)
-
 		atomic.AddInt64(&cl.activeCount, 1)
+		metrics.ConcurrencyActive.Inc()
 		reqStart := time.Now()
 
 		defer func() {
-			cl.sem.Release(1)
+			cl.release()
 			atomic.AddInt64(&cl.activeCount, -1)
+			metrics.ConcurrencyActive.Dec()
 
 			duration := time.Since(reqStart)
 			if cl.adaptive {
@@ -110,6 +123,144 @@ func (cl *ConcurrencyLimiter) Limit(next http.HandlerFunc) http.HandlerFunc {
 	}
 }
 
+// acquire 获取并发槽位：有空闲槽位时立即返回；否则排队等待，排队数达到上限或等待超时时返回错误。
+// key 为请求的 API key id，启用公平调度时按 key 排队
+func (cl *ConcurrencyLimiter) acquire(ctx context.Context, key string) error {
+	fair := cl.fair != nil && cl.fairEnabled != nil && cl.fairEnabled()
+	if fair {
+		if cl.tryAcquireFair() {
+			return nil
+		}
+	} else if cl.sem.TryAcquire(1) {
+		return nil
+	}
+	queued := atomic.AddInt64(&cl.queuedCount, 1)
+	metrics.ConcurrencyQueueLength.Inc()
+	defer func() {
+		atomic.AddInt64(&cl.queuedCount, -1)
+		metrics.ConcurrencyQueueLength.Dec()
+	}()
+	if cl.maxQueue != nil {
+		// queued 包含本请求
+		if limit := cl.maxQueue(); limit > 0 && queued > int64(limit) {
+			metrics.ConcurrencyRejectedTotal.WithLabelValues("queue_full").Inc()
+			slog.Warn("Concurrency limit: Queue full, shedding request", "queued", queued-1, "max_queue", limit)
+			return fmt.Errorf("server overloaded: %d requests already queued", queued-1)
+		}
+	}
+
+	waitTimeout := cl.waitTimeout()
+	waitCtx, cancelWait := context.WithTimeout(ctx, waitTimeout)
+	defer cancelWait()
+	acquireStart := time.Now()
+	var err error
+	if fair {
+		err = cl.waitFair(waitCtx, key)
+	} else {
+		err = cl.sem.Acquire(waitCtx, 1)
+	}
+	if err != nil {
+		metrics.ConcurrencyRejectedTotal.WithLabelValues("wait_timeout").Inc()
+		slog.Warn("Concurrency limit: Wait timeout", "duration", time.Since(acquireStart), "total_rejected", atomic.LoadInt64(&cl.rejectedReqs)+1, "wait_timeout", waitTimeout)
+		return fmt.Errorf("server overloaded: timed out after %s waiting for a worker slot", waitTimeout.Round(time.Second))
+	}
+	slog.Debug("Concurrency limit: Slot acquired", "wait_duration", time.Since(acquireStart), "active", atomic.LoadInt64(&cl.activeCount)+1)
+	return nil
+}
+
+// tryAcquireFair 在没有请求排队时获取空闲槽位，有请求排队时新请求不能插队
+func (cl *ConcurrencyLimiter) tryAcquireFair() bool {
+	cl.fairMu.Lock()
+	defer cl.fairMu.Unlock()
+	return cl.fair.length == 0 && cl.sem.TryAcquire(1)
+}
+
+// waitFair 在 key 的队列中等待 release 交出的槽位
+func (cl *ConcurrencyLimiter) waitFair(ctx context.Context, key string) error {
+	cl.fairMu.Lock()
+	if cl.fair.length == 0 && cl.sem.TryAcquire(1) {
+		cl.fairMu.Unlock()
+		return nil
+	}
+	w := &fairWaiter{granted: make(chan struct{})}
+	cl.fair.push(key, w)
+	cl.fairMu.Unlock()
+
+	select {
+	case <-w.granted:
+		return nil
+	case <-ctx.Done():
+	}
+	cl.fairMu.Lock()
+	defer cl.fairMu.Unlock()
+	if w.done {
+		// 超时的同时被放行，槽位已属于本请求
+		return nil
+	}
+	w.done = true
+	cl.fair.remove(key, w)
+	return ctx.Err()
+}
+
+// release 释放槽位：有公平队列中的请求时直接交给下一个，否则归还信号量
+func (cl *ConcurrencyLimiter) release() {
+	if cl.fair == nil {
+		cl.sem.Release(1)
+		return
+	}
+	cl.fairMu.Lock()
+	defer cl.fairMu.Unlock()
+	if w := cl.fair.next(); w != nil {
+		w.done = true
+		close(w.granted)
+		return
+	}
+	cl.sem.Release(1)
+}
+
+// waitTimeout 返回排队的最长等待时间：adaptive 时为 P95 延迟的 1.5 倍（5s～60s），不超过 timeout
+func (cl *ConcurrencyLimiter) waitTimeout() time.Duration {
+	waitTimeout := 60 * time.Second
+	if cl.adaptive {
+		p95 := cl.GetP95()
+		if p95 > 0 {
+			// Allow 1.5x P95 wait time, clamped
+			calcWait := time.Duration(float64(p95)*1.5) * time.Millisecond
+			if calcWait < 5*time.Second {
+				waitTimeout = 5 * time.Second
+			} else if calcWait > 60*time.Second {
+				waitTimeout = 60 * time.Second
+			} else {
+				waitTimeout = calcWait
+			}
+		}
+	}
+	if cl.timeout < waitTimeout {
+		waitTimeout = cl.timeout
+	}
+	return waitTimeout
+}
+
+// writeOverloaded 返回 529 overloaded_error，附带当前排队深度与建议的重试间隔
+func (cl *ConcurrencyLimiter) writeOverloaded(w http.ResponseWriter, message string) {
+	retryAfter := int64(5)
+	if p95 := cl.GetP95(); p95 > 0 {
+		retryAfter = min(max((p95+999)/1000, 1), 60)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(QueueDepthHeader, strconv.FormatInt(cl.QueueLength(), 10))
+	w.Header().Set(ConcurrencyLimitHeader, strconv.FormatInt(cl.maxConcurrent, 10))
+	w.Header().Set("Retry-After", strconv.FormatInt(retryAfter, 10))
+	w.WriteHeader(StatusOverloaded)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type": "error",
+		"error": map[string]string{
+			"type":    "overloaded_error",
+			"message": message,
+		},
+	})
+}
+
 // UpdateStats records request latency for adaptive timeout
 func (cl *ConcurrencyLimiter) UpdateStats(d time.Duration) {
 	ms := d.Milliseconds()
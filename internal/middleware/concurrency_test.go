@@ -0,0 +1,51 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiter_ShedsPastQueueLimit(t *testing.T) {
+	cl := NewConcurrencyLimiter(1, 2*time.Second, false)
+	cl.SetMaxQueue(func() int { return 1 })
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	h := cl.Limit(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+	})
+	serve := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/orchids/v1/messages", nil))
+		return rec
+	}
+
+	done := make(chan *httptest.ResponseRecorder, 2)
+	go func() { done <- serve() }()
+	<-started
+	go func() { done <- serve() }()
+	for cl.QueueLength() != 1 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 已有 1 个请求排队，达到上限后直接拒绝
+	rec := serve()
+	if rec.Code != StatusOverloaded {
+		t.Fatalf("status = %d, want %d", rec.Code, StatusOverloaded)
+	}
+	if rec.Header().Get(QueueDepthHeader) != "1" || rec.Header().Get(ConcurrencyLimitHeader) != "1" || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("headers = %v", rec.Header())
+	}
+
+	close(release)
+	for i := 0; i < 2; i++ {
+		if rec := <-done; rec.Code != http.StatusOK {
+			t.Fatalf("queued request status = %d", rec.Code)
+		}
+	}
+	if cl.QueueLength() != 0 {
+		t.Fatalf("queue length = %d after drain", cl.QueueLength())
+	}
+}
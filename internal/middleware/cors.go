@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORS 路由分组
+const (
+	CORSGroupPublic = "public" // /health、/metrics 等其余路由
+	CORSGroupAPI    = "api"    // /v1/...、/orchids/v1/...、/warp/v1/... 消息与模型接口
+	CORSGroupAdmin  = "admin"  // /api/... 管理接口与 /debug/pprof
+)
+
+// CORSGroups 为可配置的全部分组
+var CORSGroups = []string{CORSGroupPublic, CORSGroupAPI, CORSGroupAdmin}
+
+// CORSPolicy 为一个路由分组的跨域策略，字段与 config.CORSPolicy 一一对应
+type CORSPolicy struct {
+	// AllowedOrigins 支持精确匹配、"*" 与子域名通配（如 https://*.example.com）
+	AllowedOrigins []string
+	// AllowedMethods 为空时允许 GET、POST、DELETE、OPTIONS
+	AllowedMethods []string
+	// AllowedHeaders 为空时回显预检请求的 Access-Control-Request-Headers
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge 为预检结果的缓存秒数，0 表示不发送
+	MaxAge int
+}
+
+var defaultCORSMethods = []string{http.MethodGet, http.MethodPost, http.MethodDelete, http.MethodOptions}
+
+// CORSGroup 返回路径所属的 CORS 分组
+func CORSGroup(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/api/"), strings.HasPrefix(path, "/debug/"):
+		return CORSGroupAdmin
+	case strings.Contains(path, "/v1/"):
+		return CORSGroupAPI
+	default:
+		return CORSGroupPublic
+	}
+}
+
+// CORS 返回跨域中间件。policy 在每个请求时调用，返回分组当前的策略（未配置时返回 false），
+// 因此通过 /api/config 修改后立即生效。未配置的分组或不在白名单内的 Origin 不添加任何 CORS 头
+func CORS(policy func(group string) (CORSPolicy, bool)) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			p, ok := policy(CORSGroup(r.URL.Path))
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			// 响应随 Origin 变化，共享缓存不能跨 Origin 复用
+			w.Header().Add("Vary", "Origin")
+			allowOrigin, ok := p.allowOrigin(origin)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("Access-Control-Allow-Origin", allowOrigin)
+			if p.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			preflight := r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != ""
+			if !preflight {
+				if len(p.ExposedHeaders) > 0 {
+					h.Set("Access-Control-Expose-Headers", strings.Join(p.ExposedHeaders, ", "))
+				}
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			methods := p.AllowedMethods
+			if len(methods) == 0 {
+				methods = defaultCORSMethods
+			}
+			h.Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+			if len(p.AllowedHeaders) > 0 {
+				h.Set("Access-Control-Allow-Headers", strings.Join(p.AllowedHeaders, ", "))
+			} else if requested := r.Header.Get("Access-Control-Request-Headers"); requested != "" {
+				h.Add("Vary", "Access-Control-Request-Headers")
+				h.Set("Access-Control-Allow-Headers", requested)
+			}
+			if p.MaxAge > 0 {
+				h.Set("Access-Control-Max-Age", strconv.Itoa(p.MaxAge))
+			}
+			w.WriteHeader(http.StatusNoContent)
+		})
+	}
+}
+
+// AllowsOrigin 返回 origin 是否在策略的白名单内，供不经过 CORS 头约束的连接（如 WebSocket 升级）复用同一策略
+func (p CORSPolicy) AllowsOrigin(origin string) bool {
+	_, ok := p.allowOrigin(origin)
+	return ok
+}
+
+// allowOrigin 返回 Access-Control-Allow-Origin 的值。"*" 原样返回：即使配置了 allow_credentials，
+// 浏览器也会拒绝携带凭据的请求，不会因此向任意站点开放管理会话
+func (p CORSPolicy) allowOrigin(origin string) (string, bool) {
+	for _, allowed := range p.AllowedOrigins {
+		allowed = strings.TrimSpace(allowed)
+		switch {
+		case allowed == "*":
+			return "*", true
+		case strings.EqualFold(allowed, origin):
+			return origin, true
+		case strings.Contains(allowed, "://*."):
+			scheme, domain, _ := strings.Cut(allowed, "://*")
+			if prefix := scheme + "://"; len(origin) > len(prefix)+len(domain) &&
+				strings.EqualFold(origin[:len(prefix)], prefix) &&
+				strings.HasSuffix(strings.ToLower(origin), strings.ToLower(domain)) {
+				return origin, true
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCORS_PerGroupPolicy(t *testing.T) {
+	policies := map[string]CORSPolicy{
+		CORSGroupAPI:   {AllowedOrigins: []string{"https://*.example.com"}, ExposedHeaders: []string{"X-Conversation-Tokens"}, MaxAge: 600},
+		CORSGroupAdmin: {AllowedOrigins: []string{"https://admin.example.com"}, AllowedMethods: []string{"GET", "POST"}, AllowCredentials: true},
+	}
+	called := false
+	h := CORS(func(group string) (CORSPolicy, bool) {
+		p, ok := policies[group]
+		return p, ok
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	do := func(method, path, origin string, preflight bool) *httptest.ResponseRecorder {
+		called = false
+		req := httptest.NewRequest(method, path, nil)
+		if origin != "" {
+			req.Header.Set("Origin", origin)
+		}
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", "POST")
+			req.Header.Set("Access-Control-Request-Headers", "content-type, x-api-key")
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := do(http.MethodOptions, "/orchids/v1/messages", "https://app.example.com", true)
+	if rec.Code != http.StatusNoContent || called {
+		t.Fatalf("preflight status = %d, reached handler = %v", rec.Code, called)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Fatalf("allow-origin = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Headers"); got != "content-type, x-api-key" {
+		t.Fatalf("allow-headers = %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Max-Age"); got != "600" {
+		t.Fatalf("max-age = %q", got)
+	}
+
+	rec = do(http.MethodPost, "/v1/models", "https://app.example.com", false)
+	if !called || rec.Header().Get("Access-Control-Expose-Headers") != "X-Conversation-Tokens" {
+		t.Fatalf("simple request headers = %v", rec.Header())
+	}
+
+	// API 分组的通配不适用于管理接口
+	rec = do(http.MethodOptions, "/api/config", "https://app.example.com", true)
+	if !called || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatalf("admin accepted api origin: %v", rec.Header())
+	}
+	rec = do(http.MethodOptions, "/api/config", "https://admin.example.com", true)
+	if rec.Header().Get("Access-Control-Allow-Credentials") != "true" || rec.Header().Get("Access-Control-Allow-Methods") != "GET, POST" {
+		t.Fatalf("admin preflight headers = %v", rec.Header())
+	}
+
+	// 未配置的分组不添加任何头
+	rec = do(http.MethodGet, "/health", "https://app.example.com", false)
+	if !called || len(rec.Header()) != 0 {
+		t.Fatalf("public group headers = %v", rec.Header())
+	}
+}
+
+func TestCORSGroup(t *testing.T) {
+	cases := map[string]string{
+		"/orchids/v1/messages":      CORSGroupAPI,
+		"/warp/v1/chat/completions": CORSGroupAPI,
+		"/v1/streams/abc/events":    CORSGroupAPI,
+		"/api/config":               CORSGroupAdmin,
+		"/debug/pprof/":             CORSGroupAdmin,
+		"/health":                   CORSGroupPublic,
+		"/admin/":                   CORSGroupPublic,
+	}
+	for path, want := range cases {
+		if got := CORSGroup(path); got != want {
+			t.Errorf("CORSGroup(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package middleware
+
+// fairWaiter 为一个排队中的请求，granted 在获得槽位时关闭
+type fairWaiter struct {
+	granted chan struct{}
+	done    bool // 已获得槽位或已放弃，受 fairQueue 的锁保护
+}
+
+type fairKeyQueue struct {
+	waiters []*fairWaiter
+	deficit int
+}
+
+// fairQueue 按 API key 做 deficit round-robin：每轮访问一个 key 时为其增加 weight 的额度，
+// 每放行一个请求消耗 1，额度用完后轮到下一个 key。排队中的 key 按到达顺序轮转，
+// 某个 key 的请求再多也只能获得与权重成比例的槽位。调用方负责加锁
+type fairQueue struct {
+	queues map[string]*fairKeyQueue
+	active []string // 有请求排队的 key，按轮转顺序
+	pos    int
+	weight func(key string) int
+	length int
+}
+
+func newFairQueue(weight func(key string) int) *fairQueue {
+	return &fairQueue{queues: make(map[string]*fairKeyQueue), weight: weight}
+}
+
+func (q *fairQueue) push(key string, w *fairWaiter) {
+	kq, ok := q.queues[key]
+	if !ok {
+		kq = &fairKeyQueue{}
+		q.queues[key] = kq
+		q.active = append(q.active, key)
+	}
+	kq.waiters = append(kq.waiters, w)
+	q.length++
+}
+
+// remove 移除放弃等待的请求（超时或客户端断开）
+func (q *fairQueue) remove(key string, w *fairWaiter) {
+	kq, ok := q.queues[key]
+	if !ok {
+		return
+	}
+	for i, it := range kq.waiters {
+		if it == w {
+			kq.waiters = append(kq.waiters[:i], kq.waiters[i+1:]...)
+			q.length--
+			break
+		}
+	}
+	if len(kq.waiters) == 0 {
+		q.dropKey(key)
+	}
+}
+
+// next 按 deficit round-robin 取出下一个放行的请求，队列为空时返回 nil
+func (q *fairQueue) next() *fairWaiter {
+	if len(q.active) == 0 {
+		return nil
+	}
+	if q.pos >= len(q.active) {
+		q.pos = 0
+	}
+	key := q.active[q.pos]
+	kq := q.queues[key]
+	if kq.deficit < 1 {
+		kq.deficit += q.keyWeight(key)
+	}
+	w := kq.waiters[0]
+	kq.waiters = kq.waiters[1:]
+	kq.deficit--
+	q.length--
+	switch {
+	case len(kq.waiters) == 0:
+		// 队列清空的 key 不保留额度，下次排队从新一轮开始
+		q.dropKey(key)
+	case kq.deficit < 1:
+		q.pos = (q.pos + 1) % len(q.active)
+	}
+	return w
+}
+
+func (q *fairQueue) keyWeight(key string) int {
+	if q.weight == nil {
+		return 1
+	}
+	return max(q.weight(key), 1)
+}
+
+func (q *fairQueue) dropKey(key string) {
+	delete(q.queues, key)
+	for i, k := range q.active {
+		if k == key {
+			q.active = append(q.active[:i], q.active[i+1:]...)
+			if i < q.pos {
+				q.pos--
+			}
+			break
+		}
+	}
+	if q.pos >= len(q.active) {
+		q.pos = 0
+	}
+}
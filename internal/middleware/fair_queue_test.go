@@ -0,0 +1,92 @@
+package middleware
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFairQueue_DeficitRoundRobin(t *testing.T) {
+	q := newFairQueue(func(key string) int {
+		if key == "b" {
+			return 2
+		}
+		return 0
+	})
+	keys := map[*fairWaiter]string{}
+	enqueue := func(key string, n int) {
+		for i := 0; i < n; i++ {
+			w := &fairWaiter{}
+			keys[w] = key
+			q.push(key, w)
+		}
+	}
+	// a 先到且请求最多，也只能与其他 key 轮流；b 的权重为 2
+	enqueue("a", 5)
+	enqueue("b", 4)
+	enqueue("c", 1)
+
+	var order []string
+	for w := q.next(); w != nil; w = q.next() {
+		order = append(order, keys[w])
+	}
+	if got, want := strings.Join(order, ""), "abbcabbaaa"; got != want {
+		t.Fatalf("order = %s, want %s", got, want)
+	}
+	if q.length != 0 || len(q.active) != 0 {
+		t.Fatalf("queue not empty: length=%d active=%v", q.length, q.active)
+	}
+}
+
+func TestConcurrencyLimiter_FairShare(t *testing.T) {
+	cl := NewConcurrencyLimiter(1, 5*time.Second, false)
+	cl.SetFairShare(func() bool { return true }, nil)
+	if err := cl.acquire(context.Background(), "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	queued := func() int {
+		cl.fairMu.Lock()
+		defer cl.fairMu.Unlock()
+		return cl.fair.length
+	}
+	granted := make(chan string, 4)
+	wait := func(key string) {
+		n := queued()
+		go func() {
+			if err := cl.acquire(context.Background(), key); err == nil {
+				granted <- key
+			}
+		}()
+		// 等到请求进入公平队列再发下一个，保证排队顺序
+		for queued() == n {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	wait("a")
+	wait("a")
+	wait("a")
+	wait("b")
+
+	// 放行顺序 a b a a：后到的 b 不必等 a 的请求全部完成
+	var order []string
+	for i := 0; i < 4; i++ {
+		cl.release()
+		order = append(order, <-granted)
+	}
+	if got := strings.Join(order, ""); got != "abaa" {
+		t.Fatalf("order = %s, want abaa", got)
+	}
+
+	// 等待超时的请求离开队列，槽位仍可被后续请求获取
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := cl.waitFair(ctx, "c"); err == nil {
+		t.Fatal("wait without a free slot succeeded")
+	}
+	cl.release()
+	if !cl.tryAcquireFair() {
+		t.Fatal("free slot not acquirable after timed-out waiter left")
+	}
+}
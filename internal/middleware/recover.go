@@ -0,0 +1,119 @@
+package middleware
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"runtime/debug"
+	"strings"
+
+	"orchids-api/internal/metrics"
+)
+
+// Recover 捕获 handler 中的 panic：记录带 trace ID 的调用栈并累加 orchids_panics_total，
+// 尚未写出响应时返回 JSON 500（含 trace_id）；SSE 响应已开始时追加一个 error 事件后结束流。
+// 需放在 TraceMiddleware 之后，放在 AccessLog 之后可让访问日志记录 500。
+// http.ErrAbortHandler 原样继续向上抛出，保持 net/http 中止连接的语义
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &recoverWriter{ResponseWriter: w}
+		defer func() {
+			v := recover()
+			if v == nil {
+				return
+			}
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			traceID := GetTraceID(r.Context())
+			metrics.PanicsTotal.Inc()
+			slog.Error("Handler panic recovered",
+				"trace_id", traceID,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"client_ip", ClientIP(r),
+				"panic", fmt.Sprint(v),
+				"stack", string(debug.Stack()),
+			)
+			rw.writePanicResponse(traceID)
+		}()
+		next.ServeHTTP(rw, r)
+	})
+}
+
+// panicErrorBody 为 panic 时返回给客户端的错误体，不包含 panic 内容以免泄露内部信息
+func panicErrorBody(traceID string) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"type": "error",
+		"error": map[string]string{
+			"type":     "api_error",
+			"message":  "Internal server error",
+			"trace_id": traceID,
+		},
+	})
+	return data
+}
+
+// recoverWriter 记录响应是否已开始，以决定 panic 后还能写出什么
+type recoverWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+	hijacked    bool
+}
+
+func (w *recoverWriter) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *recoverWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush 实现 http.Flusher
+func (w *recoverWriter) Flush() {
+	w.wroteHeader = true
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 实现 http.Hijacker，连接被接管后 panic 时不再写出响应
+func (w *recoverWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	w.hijacked = true
+	return hj.Hijack()
+}
+
+func (w *recoverWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *recoverWriter) writePanicResponse(traceID string) {
+	switch {
+	case w.hijacked:
+		return
+	case !w.wroteHeader:
+		h := w.Header()
+		// 清除 handler 可能已设置的内容相关头（如 SSE 的 Content-Type）
+		for _, name := range []string{"Content-Type", "Content-Length", "Content-Encoding", "Cache-Control"} {
+			h.Del(name)
+		}
+		h.Set("Content-Type", "application/json")
+		h.Set(TraceIDHeader, traceID)
+		w.ResponseWriter.WriteHeader(http.StatusInternalServerError)
+		w.ResponseWriter.Write(panicErrorBody(traceID))
+	case strings.HasPrefix(w.Header().Get("Content-Type"), "text/event-stream"):
+		fmt.Fprintf(w.ResponseWriter, "event: error\ndata: %s\n\n", panicErrorBody(traceID))
+		if f, ok := w.ResponseWriter.(http.Flusher); ok {
+			f.Flush()
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package middleware
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"orchids-api/internal/metrics"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func panicsTotal(t *testing.T) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := metrics.PanicsTotal.Write(&m); err != nil {
+		t.Fatalf("read panics_total: %v", err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+func TestRecover_JSONErrorBeforeResponse(t *testing.T) {
+	before := panicsTotal(t)
+	handler := Chain(TraceMiddleware, Recover)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		panic("boom")
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/messages", nil)
+	req.Header.Set(TraceIDHeader, "trace-panic")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("status = %d, want 500", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("Content-Type = %q, want application/json", ct)
+	}
+	var body struct {
+		Error struct {
+			Type    string `json:"type"`
+			TraceID string `json:"trace_id"`
+		} `json:"error"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body %q: %v", rec.Body.String(), err)
+	}
+	if body.Error.Type != "api_error" || body.Error.TraceID != "trace-panic" {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+	if strings.Contains(rec.Body.String(), "boom") {
+		t.Fatalf("panic value leaked to client: %s", rec.Body.String())
+	}
+	if got := panicsTotal(t) - before; got != 1 {
+		t.Fatalf("panics_total increased by %v, want 1", got)
+	}
+}
+
+func TestRecover_SSEErrorEventAfterStreamStarted(t *testing.T) {
+	handler := Chain(TraceMiddleware, Recover)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event: ping\ndata: {\"type\":\"ping\"}\n\n"))
+		w.(http.Flusher).Flush()
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/messages", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200 (already sent)", rec.Code)
+	}
+	if !strings.HasSuffix(rec.Body.String(), "\n\n") || !strings.Contains(rec.Body.String(), "event: error\ndata: {") {
+		t.Fatalf("missing error event: %q", rec.Body.String())
+	}
+}
+
+func TestRecover_ReraisesAbortHandler(t *testing.T) {
+	handler := Recover(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+	defer func() {
+		if v := recover(); v != http.ErrAbortHandler {
+			t.Fatalf("recovered %v, want http.ErrAbortHandler", v)
+		}
+	}()
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
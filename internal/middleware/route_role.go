@@ -0,0 +1,38 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+)
+
+// IsAdminRoute 判断 path（已去掉 base_path）是否属于管理面：/api/...、管理界面、/metrics 与 /debug/pprof/
+func IsAdminRoute(path, adminPath string) bool {
+	switch {
+	case path == "/api" || strings.HasPrefix(path, "/api/"):
+		return true
+	case path == adminPath || strings.HasPrefix(path, adminPath+"/"):
+		return true
+	case path == "/metrics" || strings.HasPrefix(path, "/debug/"):
+		return true
+	}
+	return false
+}
+
+// IsProbeRoute 判断 path 是否为两个监听都提供的健康检查与版本接口
+func IsProbeRoute(path string) bool {
+	return path == "/health" || strings.HasPrefix(path, "/health/") || path == "/version"
+}
+
+// RestrictRoutes 返回只放行 allow(path) 为 true 的请求的中间件，其余返回 404。
+// 配置 admin_listen_addr 后，公开监听与管理监听分别只提供 API 与管理路由
+func RestrictRoutes(allow func(path string) bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !allow(r.URL.Path) {
+				http.NotFound(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRestrictRoutes(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	public := RestrictRoutes(func(path string) bool { return !IsAdminRoute(path, "/admin") })(ok)
+	admin := RestrictRoutes(func(path string) bool { return IsAdminRoute(path, "/admin") || IsProbeRoute(path) })(ok)
+
+	cases := []struct {
+		path          string
+		public, admin int
+	}{
+		{"/orchids/v1/messages", http.StatusOK, http.StatusNotFound},
+		{"/v1/models", http.StatusOK, http.StatusNotFound},
+		{"/api/accounts", http.StatusNotFound, http.StatusOK},
+		{"/admin/", http.StatusNotFound, http.StatusOK},
+		{"/admin", http.StatusNotFound, http.StatusOK},
+		{"/metrics", http.StatusNotFound, http.StatusOK},
+		{"/debug/pprof/", http.StatusNotFound, http.StatusOK},
+		{"/health/ready", http.StatusOK, http.StatusOK},
+		{"/version", http.StatusOK, http.StatusOK},
+		{"/administrator", http.StatusOK, http.StatusNotFound},
+	}
+	for _, c := range cases {
+		for _, side := range []struct {
+			name string
+			h    http.Handler
+			want int
+		}{{"public", public, c.public}, {"admin", admin, c.admin}} {
+			rec := httptest.NewRecorder()
+			side.h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, c.path, nil))
+			if rec.Code != side.want {
+				t.Errorf("%s %s: %d, want %d", side.name, c.path, rec.Code, side.want)
+			}
+		}
+	}
+}
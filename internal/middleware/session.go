@@ -5,32 +5,89 @@ import (
 	"orchids-api/internal/auth"
 )
 
+// CSRFHeader 为会话 cookie 鉴权的写操作需要携带的请求头，值由管理页面渲染时注入
+const CSRFHeader = "X-CSRF-Token"
+
+// adminAuth 为管理员请求的鉴权方式
+type adminAuth int
+
+const (
+	adminAuthNone adminAuth = iota
+	adminAuthToken
+	adminAuthBasic
+	adminAuthSession
+)
+
 func SessionAuth(adminPass, adminToken string, next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		cookie, err := r.Cookie("session_token")
-		if err == nil && auth.ValidateSessionToken(cookie.Value) {
-			next(w, r)
+		method := adminAuthMethod(r, adminPass, adminToken)
+		if method == adminAuthNone {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
-
-		authHeader := r.Header.Get("Authorization")
-		if adminToken != "" {
-			if authHeader == "Bearer "+adminToken || authHeader == adminToken {
-				next(w, r)
-				return
-			}
-			if r.Header.Get("X-Admin-Token") == adminToken {
-				next(w, r)
-				return
-			}
+		if !csrfAllowed(r, method) {
+			http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+			return
 		}
+		next(w, r)
+	}
+}
 
-		_, pass, ok := r.BasicAuth()
-		if ok && pass == adminPass {
-			next(w, r)
+// CSRFProtect 用于不经 SessionAuth 的会话写操作（如 /api/logout）：携带有效会话 cookie 的写请求必须带上 CSRF token
+func CSRFProtect(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("session_token"); err == nil && auth.ValidateSessionToken(cookie.Value) &&
+			!csrfAllowed(r, adminAuthSession) {
+			http.Error(w, "Invalid CSRF token", http.StatusForbidden)
 			return
 		}
+		next(w, r)
+	}
+}
 
-		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+// IsAdminRequest 报告请求是否携带管理员凭据（会话 cookie、admin token 或 Basic Auth）
+func IsAdminRequest(r *http.Request, adminPass, adminToken string) bool {
+	return adminAuthMethod(r, adminPass, adminToken) != adminAuthNone
+}
+
+// adminAuthMethod 按 admin token、Basic Auth、会话 cookie 的顺序识别鉴权方式。
+// 显式携带 token 的请求优先，即使浏览器同时带有会话 cookie 也不要求 CSRF token
+func adminAuthMethod(r *http.Request, adminPass, adminToken string) adminAuth {
+	authHeader := r.Header.Get("Authorization")
+	if adminToken != "" {
+		if authHeader == "Bearer "+adminToken || authHeader == adminToken {
+			return adminAuthToken
+		}
+		if r.Header.Get("X-Admin-Token") == adminToken {
+			return adminAuthToken
+		}
+	}
+
+	if _, pass, ok := r.BasicAuth(); ok && pass == adminPass {
+		return adminAuthBasic
+	}
+
+	cookie, err := r.Cookie("session_token")
+	if err == nil && auth.ValidateSessionToken(cookie.Value) {
+		return adminAuthSession
+	}
+	return adminAuthNone
+}
+
+// csrfAllowed 检查写操作的 CSRF 防护。会话 cookie 由浏览器自动携带，必须回传与会话绑定的 token；
+// 浏览器缓存的 Basic Auth 同样会被自动携带，拒绝浏览器标记为跨站的请求（curl 等客户端不发送 Sec-Fetch-Site）
+func csrfAllowed(r *http.Request, method adminAuth) bool {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	switch method {
+	case adminAuthSession:
+		cookie, err := r.Cookie("session_token")
+		return err == nil && auth.ValidateCSRFToken(cookie.Value, r.Header.Get(CSRFHeader))
+	case adminAuthBasic:
+		return r.Header.Get("Sec-Fetch-Site") != "cross-site"
+	default:
+		return true
 	}
 }
@@ -0,0 +1,60 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"orchids-api/internal/auth"
+)
+
+func TestSessionAuth_RequiresCSRFTokenForCookieMutations(t *testing.T) {
+	session, err := auth.GenerateSessionToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := SessionAuth("pass", "admin-token", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	do := func(method string, setup func(r *http.Request)) int {
+		req := httptest.NewRequest(method, "/api/config", nil)
+		setup(req)
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		return rec.Code
+	}
+	withCookie := func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "session_token", Value: session}) }
+
+	cases := []struct {
+		name   string
+		method string
+		setup  func(r *http.Request)
+		want   int
+	}{
+		{"cookie read", http.MethodGet, withCookie, http.StatusOK},
+		{"cookie write without token", http.MethodPost, withCookie, http.StatusForbidden},
+		{"cookie write with wrong token", http.MethodPost, func(r *http.Request) {
+			withCookie(r)
+			r.Header.Set(CSRFHeader, auth.CSRFToken("other-session"))
+		}, http.StatusForbidden},
+		{"cookie write with token", http.MethodPost, func(r *http.Request) {
+			withCookie(r)
+			r.Header.Set(CSRFHeader, auth.CSRFToken(session))
+		}, http.StatusOK},
+		{"admin token write", http.MethodDelete, func(r *http.Request) {
+			withCookie(r)
+			r.Header.Set("X-Admin-Token", "admin-token")
+		}, http.StatusOK},
+		{"basic write", http.MethodPost, func(r *http.Request) { r.SetBasicAuth("admin", "pass") }, http.StatusOK},
+		{"basic cross-site write", http.MethodPost, func(r *http.Request) {
+			r.SetBasicAuth("admin", "pass")
+			r.Header.Set("Sec-Fetch-Site", "cross-site")
+		}, http.StatusForbidden},
+		{"no credentials", http.MethodPost, func(r *http.Request) {}, http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		if got := do(tc.method, tc.setup); got != tc.want {
+			t.Errorf("%s: status = %d, want %d", tc.name, got, tc.want)
+		}
+	}
+}
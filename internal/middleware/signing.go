@@ -0,0 +1,181 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 请求签名头：X-Orchids-Signature 为 "v1=" + hex(HMAC-SHA256(secret, SigningString(...)))
+const (
+	SignatureTimestampHeader = "X-Orchids-Timestamp"
+	SignatureHeader          = "X-Orchids-Signature"
+	signatureVersion         = "v1="
+)
+
+// SigningSettings 为签名校验的当前配置，secrets 为 API Key ID（见 APIKeyID）到共享密钥的映射
+type SigningSettings struct {
+	Secrets      map[string]string
+	MaxSkew      time.Duration
+	MaxBodyBytes int64
+}
+
+// SigningString 返回待签名的内容：时间戳、方法、带查询参数的路径与请求体 sha256，以换行分隔
+func SigningString(timestamp, method, requestURI string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return timestamp + "\n" + strings.ToUpper(method) + "\n" + requestURI + "\n" + hex.EncodeToString(sum[:])
+}
+
+// Sign 计算签名头的值，供客户端与测试使用
+func Sign(secret, timestamp, method, requestURI string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(SigningString(timestamp, method, requestURI, body)))
+	return signatureVersion + hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureVerifier 校验 /v1 接口的 HMAC 请求签名。只有配置了共享密钥的 API Key 需要签名，
+// 其余请求不受影响；时间戳超出 MaxSkew 或签名在窗口内重复使用时拒绝，防止重放
+type SignatureVerifier struct {
+	settings func() SigningSettings
+	now      func() time.Time
+
+	mu        sync.Mutex
+	seen      map[string]time.Time
+	lastPrune time.Time
+}
+
+// NewSignatureVerifier 创建签名校验器，settings 每个请求调用一次以读取当前配置
+func NewSignatureVerifier(settings func() SigningSettings) *SignatureVerifier {
+	return &SignatureVerifier{settings: settings, now: time.Now, seen: make(map[string]time.Time)}
+}
+
+// Middleware 返回签名校验中间件
+func (v *SignatureVerifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isV1Route(r.URL.Path) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		settings := v.settings()
+		keyID := APIKeyID(r)
+		secret, ok := settings.Secrets[keyID]
+		if keyID == "" || !ok || secret == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		timestamp := strings.TrimSpace(r.Header.Get(SignatureTimestampHeader))
+		signature := strings.TrimSpace(r.Header.Get(SignatureHeader))
+		if timestamp == "" || signature == "" {
+			v.reject(w, r, keyID, "Missing request signature")
+			return
+		}
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			v.reject(w, r, keyID, "Invalid signature timestamp")
+			return
+		}
+		now := v.now()
+		if skew := now.Sub(time.Unix(ts, 0)); skew > settings.MaxSkew || skew < -settings.MaxSkew {
+			v.reject(w, r, keyID, "Signature timestamp outside allowed window")
+			return
+		}
+
+		var body []byte
+		if r.Body != nil {
+			reader := io.Reader(r.Body)
+			if settings.MaxBodyBytes > 0 {
+				reader = io.LimitReader(r.Body, settings.MaxBodyBytes+1)
+			}
+			body, err = io.ReadAll(reader)
+			r.Body.Close()
+			if err != nil {
+				writeSigningError(w, http.StatusBadRequest, "invalid_request_error", "Failed to read request body")
+				return
+			}
+			if settings.MaxBodyBytes > 0 && int64(len(body)) > settings.MaxBodyBytes {
+				writeSigningError(w, http.StatusRequestEntityTooLarge, "request_too_large", "Request body too large")
+				return
+			}
+			// 校验后还原请求体，供后续 handler 读取
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		// 签名覆盖客户端实际请求的路径：base_path 去掉前缀后 r.URL 已改写，r.RequestURI 保留原始请求行
+		requestURI := r.RequestURI
+		if requestURI == "" {
+			requestURI = r.URL.RequestURI()
+		}
+		expected := Sign(secret, timestamp, r.Method, requestURI, body)
+		if !hmac.Equal([]byte(signature), []byte(expected)) {
+			v.reject(w, r, keyID, "Invalid request signature")
+			return
+		}
+		if !v.markUsed(keyID+":"+signature, now, settings.MaxSkew) {
+			v.reject(w, r, keyID, "Request signature already used")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// markUsed 记录签名，窗口内重复出现时返回 false。时间戳允许 ±window 的偏差，
+// 签名最多在首次出现后 2*window 内仍可能通过时间校验，记录保留到那之后，每个窗口清理一次
+func (v *SignatureVerifier) markUsed(key string, now time.Time, window time.Duration) bool {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if now.Sub(v.lastPrune) > window {
+		for k, at := range v.seen {
+			if now.Sub(at) > 2*window {
+				delete(v.seen, k)
+			}
+		}
+		v.lastPrune = now
+	}
+	if _, ok := v.seen[key]; ok {
+		return false
+	}
+	v.seen[key] = now
+	return true
+}
+
+func (v *SignatureVerifier) reject(w http.ResponseWriter, r *http.Request, keyID, message string) {
+	slog.Warn("Request signature rejected",
+		"trace_id", GetTraceID(r.Context()),
+		"api_key_id", keyID,
+		"path", r.URL.Path,
+		"client_ip", ClientIP(r),
+		"reason", message,
+	)
+	writeSigningError(w, http.StatusUnauthorized, "authentication_error", message)
+}
+
+func writeSigningError(w http.ResponseWriter, status int, errType, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"type": "error",
+		"error": map[string]string{
+			"type":    errType,
+			"message": message,
+		},
+	})
+}
+
+// isV1Route 报告是否为 /v1/... 或 /{channel}/v1/... 接口；渠道前缀可配置（见 ChannelPrefixes），只看第二段是否为 v1
+func isV1Route(path string) bool {
+	if strings.HasPrefix(path, "/v1/") {
+		return true
+	}
+	_, rest, ok := strings.Cut(strings.TrimPrefix(path, "/"), "/")
+	return ok && strings.HasPrefix(rest, "v1/")
+}
@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignatureVerifier(t *testing.T) {
+	const apiKey, secret = "sk-signed", "0123456789abcdef"
+	now := time.Unix(1_700_000_000, 0)
+	v := NewSignatureVerifier(func() SigningSettings {
+		return SigningSettings{
+			Secrets: map[string]string{HashAPIKey(apiKey)[:apiKeyIDLen]: secret},
+			MaxSkew: 5 * time.Minute,
+		}
+	})
+	v.now = func() time.Time { return now }
+	var gotBody string
+	handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		gotBody = string(data)
+	}))
+
+	const body = `{"model":"claude-sonnet-4-5"}`
+	send := func(key string, ts time.Time, sign func(timestamp string) string) int {
+		req := httptest.NewRequest(http.MethodPost, "/orchids/v1/messages?beta=true", strings.NewReader(body))
+		req.Header.Set("x-api-key", key)
+		if sign != nil {
+			timestamp := strconv.FormatInt(ts.Unix(), 10)
+			req.Header.Set(SignatureTimestampHeader, timestamp)
+			req.Header.Set(SignatureHeader, sign(timestamp))
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+	valid := func(timestamp string) string {
+		return Sign(secret, timestamp, http.MethodPost, "/orchids/v1/messages?beta=true", []byte(body))
+	}
+
+	if code := send(apiKey, now, valid); code != http.StatusOK || gotBody != body {
+		t.Fatalf("valid signature: code=%d body=%q", code, gotBody)
+	}
+	if code := send(apiKey, now, valid); code != http.StatusUnauthorized {
+		t.Fatalf("replayed signature: code=%d, want 401", code)
+	}
+	if code := send(apiKey, now.Add(-10*time.Minute), valid); code != http.StatusUnauthorized {
+		t.Fatalf("stale timestamp: code=%d, want 401", code)
+	}
+	if code := send(apiKey, now.Add(time.Second), func(ts string) string {
+		return Sign("wrong-secret-value", ts, http.MethodPost, "/orchids/v1/messages?beta=true", []byte(body))
+	}); code != http.StatusUnauthorized {
+		t.Fatalf("wrong secret: code=%d, want 401", code)
+	}
+	if code := send(apiKey, now, nil); code != http.StatusUnauthorized {
+		t.Fatalf("unsigned request for enrolled key: code=%d, want 401", code)
+	}
+	// 未配置密钥的 key 不需要签名
+	if code := send("sk-other", now, nil); code != http.StatusOK {
+		t.Fatalf("unsigned request for other key: code=%d, want 200", code)
+	}
+}
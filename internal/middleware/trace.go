@@ -2,10 +2,13 @@
 package middleware
 
 import (
+	"bufio"
 	"context"
 	"crypto/rand"
 	"encoding/hex"
+	"fmt"
 	"log/slog"
+	"net"
 	"net/http"
 	"time"
 )
@@ -118,44 +121,19 @@ func (w *TracedResponseWriter) Flush() {
 	}
 }
 
-// LoggingMiddleware 记录请求日志，包含 trace ID 和耗时
-func LoggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		start := time.Now()
-		traceID := GetTraceID(r.Context())
-
-		// 包装 ResponseWriter
-		wrapped := NewTracedResponseWriter(w)
-
-		// 记录请求开始
-		slog.Debug("Request started",
-			"trace_id", traceID,
-			"method", r.Method,
-			"path", r.URL.Path,
-			"remote_addr", r.RemoteAddr,
-		)
-
-		// 处理请求
-		next.ServeHTTP(wrapped, r)
-
-		// 记录请求完成
-		duration := time.Since(start)
-		level := slog.LevelInfo
-		if wrapped.StatusCode >= 500 {
-			level = slog.LevelError
-		} else if wrapped.StatusCode >= 400 {
-			level = slog.LevelWarn
-		}
+// Hijack 实现 http.Hijacker，供 WebSocket 升级使用
+func (w *TracedResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+	w.StatusCode = http.StatusSwitchingProtocols
+	return hj.Hijack()
+}
 
-		slog.Log(r.Context(), level, "Request completed",
-			"trace_id", traceID,
-			"method", r.Method,
-			"path", r.URL.Path,
-			"status", wrapped.StatusCode,
-			"bytes", wrapped.BytesWritten,
-			"duration", duration,
-		)
-	})
+// LoggingMiddleware 记录请求日志，包含 trace ID 和耗时（不采样）
+func LoggingMiddleware(next http.Handler) http.Handler {
+	return AccessLog(AccessLogOptions{SuccessSampleRate: 1})(next)
 }
 
 // Chain 链式组合多个中间件
@@ -0,0 +1,336 @@
+// Package modelsync 从上游渠道同步模型列表到本地 store，并生成差异报告
+package modelsync
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"orchids-api/internal/config"
+	"orchids-api/internal/events"
+	"orchids-api/internal/metrics"
+	"orchids-api/internal/orchids"
+	"orchids-api/internal/store"
+	"orchids-api/internal/warp"
+)
+
+// 支持的同步来源
+const (
+	SourceOrchids = "orchids"
+	SourceWarp    = "warp"
+)
+
+// fetchTimeout 为单个来源拉取上游模型列表的超时
+const fetchTimeout = 30 * time.Second
+
+// Change 描述一个模型的差异
+type Change struct {
+	ModelID string `json:"model_id"`
+	Channel string `json:"channel"`
+	Name    string `json:"name,omitempty"`
+	// Upstream 仅用于 changed：上游给出的新名称
+	Upstream string `json:"upstream,omitempty"`
+}
+
+// Report 为一次同步的结果。Added 会写入 store；Removed/Changed 只报告，
+// 以免覆盖管理员对本地模型的手动调整。
+type Report struct {
+	Source        string    `json:"source"`
+	StartedAt     time.Time `json:"started_at"`
+	DurationMs    int64     `json:"duration_ms"`
+	UpstreamTotal int       `json:"upstream_total"`
+	Added         []Change  `json:"added"`
+	Removed       []Change  `json:"removed"`
+	Changed       []Change  `json:"changed"`
+	Skipped       string    `json:"skipped,omitempty"`
+	Error         string    `json:"error,omitempty"`
+}
+
+// HasChanges 报告本次同步是否发现差异
+func (r Report) HasChanges() bool {
+	return len(r.Added) > 0 || len(r.Removed) > 0 || len(r.Changed) > 0
+}
+
+// Syncer 执行模型同步，串行化定时与手动触发
+type Syncer struct {
+	store  *store.Store
+	cfg    *config.Config
+	events *events.Bus
+
+	mu     sync.Mutex
+	lastMu sync.RWMutex
+	last   []Report
+}
+
+func New(s *store.Store, cfg *config.Config) *Syncer {
+	return &Syncer{store: s, cfg: cfg}
+}
+
+// SetEventBus 设置每个来源同步结束后 task.finished 事件的发布目标
+func (sy *Syncer) SetEventBus(b *events.Bus) {
+	sy.events = b
+}
+
+// Sources 返回配置的同步来源；未配置时同步全部来源
+func (sy *Syncer) Sources() []string {
+	if sy.cfg == nil || len(sy.cfg.ModelSyncSources) == 0 {
+		return []string{SourceOrchids, SourceWarp}
+	}
+	return sy.cfg.ModelSyncSources
+}
+
+// Run 依次同步 sources（为空时使用配置的来源），返回每个来源的报告
+func (sy *Syncer) Run(ctx context.Context, sources ...string) []Report {
+	if len(sources) == 0 {
+		sources = sy.Sources()
+	}
+	sy.mu.Lock()
+	defer sy.mu.Unlock()
+
+	reports := make([]Report, 0, len(sources))
+	for _, source := range sources {
+		var report Report
+		switch strings.ToLower(strings.TrimSpace(source)) {
+		case SourceOrchids:
+			report = sy.syncOrchids(ctx)
+		case SourceWarp:
+			report = sy.syncWarp(ctx)
+		default:
+			report = Report{Source: source, StartedAt: time.Now(), Error: "unknown source"}
+		}
+		logReport(report)
+		sy.publishReport(report)
+		reports = append(reports, report)
+	}
+
+	sy.lastMu.Lock()
+	sy.last = reports
+	sy.lastMu.Unlock()
+	return reports
+}
+
+// LastReports 返回最近一次同步的报告
+func (sy *Syncer) LastReports() []Report {
+	sy.lastMu.RLock()
+	defer sy.lastMu.RUnlock()
+	return append([]Report(nil), sy.last...)
+}
+
+// ValidSource 报告 source 是否为支持的来源
+func ValidSource(source string) bool {
+	switch strings.ToLower(strings.TrimSpace(source)) {
+	case SourceOrchids, SourceWarp:
+		return true
+	}
+	return false
+}
+
+type upstreamModel struct {
+	id      string
+	channel string
+	name    string
+}
+
+func (sy *Syncer) syncOrchids(ctx context.Context) Report {
+	report := Report{Source: SourceOrchids, StartedAt: time.Now()}
+	defer func() { report.DurationMs = time.Since(report.StartedAt).Milliseconds() }()
+
+	accounts, err := sy.store.GetEnabledAccounts(ctx)
+	if err != nil {
+		report.Error = fmt.Sprintf("list accounts: %v", err)
+		return report
+	}
+	// 找到第一个可用的 Orchids 账号来获取上游模型
+	var client *orchids.Client
+	for _, acc := range accounts {
+		if strings.EqualFold(acc.AccountType, "warp") {
+			continue
+		}
+		client = orchids.NewFromAccount(acc, sy.cfg)
+		break
+	}
+	if client == nil {
+		report.Skipped = "no orchids account"
+		return report
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	models, err := client.FetchUpstreamModels(fetchCtx)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	upstream := make([]upstreamModel, 0, len(models))
+	for _, um := range models {
+		channel := "Orchids"
+		if strings.TrimSpace(um.OwnedBy) != "" {
+			channel = um.OwnedBy
+		}
+		upstream = append(upstream, upstreamModel{id: um.ID, channel: channel, name: um.ID})
+	}
+	sy.apply(ctx, &report, upstream, "Orchids", false)
+	return report
+}
+
+func (sy *Syncer) syncWarp(ctx context.Context) Report {
+	report := Report{Source: SourceWarp, StartedAt: time.Now()}
+	defer func() { report.DurationMs = time.Since(report.StartedAt).Milliseconds() }()
+
+	accounts, err := sy.store.GetEnabledAccounts(ctx)
+	if err != nil {
+		report.Error = fmt.Sprintf("list accounts: %v", err)
+		return report
+	}
+	var warpAcc *store.Account
+	for _, acc := range accounts {
+		if strings.EqualFold(acc.AccountType, "warp") && strings.TrimSpace(acc.Token) != "" {
+			warpAcc = acc
+			break
+		}
+	}
+	if warpAcc == nil {
+		report.Skipped = "no warp account with token"
+		return report
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, fetchTimeout)
+	defer cancel()
+	choices, err := warp.NewFromAccount(warpAcc, sy.cfg).GetFeatureModelChoices(fetchCtx)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+
+	var upstream []upstreamModel
+	for _, cat := range []*warp.FeatureModelCategory{choices.AgentMode, choices.Planning, choices.Coding, choices.CliAgent} {
+		if cat == nil {
+			continue
+		}
+		for _, choice := range cat.Choices {
+			name := choice.DisplayName
+			if name == "" {
+				name = strings.TrimSpace(choice.ID)
+			}
+			upstream = append(upstream, upstreamModel{id: choice.ID, channel: "Warp", name: name + " (Warp)"})
+		}
+	}
+	sy.apply(ctx, &report, upstream, "Warp", true)
+	return report
+}
+
+// apply 将上游模型与本地模型对比：新增写入 store，缺失和名称变化只记录。缺失只在该来源负责的渠道中计算：
+// 默认渠道 channel 以及上游模型实际写入的渠道（如 Orchids 按 owned_by 建立的渠道）。
+// compareNames 为 false 时（上游不提供展示名）不比较名称。
+// 上游返回空列表时视为异常而跳过，避免把渠道下的全部模型报告为已移除。
+func (sy *Syncer) apply(ctx context.Context, report *Report, upstream []upstreamModel, channel string, compareNames bool) {
+	if len(upstream) == 0 {
+		report.Skipped = "upstream returned no models"
+		return
+	}
+
+	local, err := sy.store.ListModels(ctx)
+	if err != nil {
+		report.Error = fmt.Sprintf("list models: %v", err)
+		return
+	}
+	byModelID := make(map[string]*store.Model, len(local))
+	for _, m := range local {
+		byModelID[m.ModelID] = m
+	}
+
+	channels := map[string]bool{strings.ToLower(channel): true}
+	seen := make(map[string]bool, len(upstream))
+	for _, um := range upstream {
+		modelID := strings.TrimSpace(um.id)
+		if modelID == "" || seen[modelID] {
+			continue
+		}
+		seen[modelID] = true
+		channels[strings.ToLower(um.channel)] = true
+		report.UpstreamTotal++
+
+		if existing, ok := byModelID[modelID]; ok {
+			if compareNames && existing.Name != um.name {
+				report.Changed = append(report.Changed, Change{ModelID: modelID, Channel: existing.Channel, Name: existing.Name, Upstream: um.name})
+			}
+			continue
+		}
+		newModel := &store.Model{
+			Channel: um.channel,
+			ModelID: modelID,
+			Name:    um.name,
+			Status:  store.ModelStatusAvailable,
+		}
+		if err := sy.store.CreateModel(ctx, newModel); err != nil {
+			slog.Warn("模型同步: 创建模型失败", "source", report.Source, "model_id", modelID, "error", err)
+			continue
+		}
+		report.Added = append(report.Added, Change{ModelID: modelID, Channel: um.channel, Name: um.name})
+	}
+
+	for _, m := range local {
+		if channels[strings.ToLower(m.Channel)] && !seen[m.ModelID] {
+			report.Removed = append(report.Removed, Change{ModelID: m.ModelID, Channel: m.Channel, Name: m.Name})
+		}
+	}
+}
+
+// reportResult 返回与 orchids_admin_tasks_total 一致的结果标签
+func reportResult(r Report) string {
+	switch {
+	case r.Error != "":
+		return "failure"
+	case r.Skipped != "":
+		return "skipped"
+	}
+	return "success"
+}
+
+func (sy *Syncer) publishReport(r Report) {
+	detail := r.Source
+	switch {
+	case r.Error != "":
+		detail += ": " + r.Error
+	case r.Skipped != "":
+		detail += ": " + r.Skipped
+	case r.HasChanges():
+		detail += fmt.Sprintf(": added %d, removed %d, changed %d", len(r.Added), len(r.Removed), len(r.Changed))
+	}
+	sy.events.Publish(events.TaskFinished, events.Task{
+		Task:       "model_sync",
+		Result:     reportResult(r),
+		Detail:     detail,
+		DurationMs: r.DurationMs,
+	})
+}
+
+// logReport 输出同步结果；发现差异时记录一条审计日志
+func logReport(r Report) {
+	metrics.AdminTasks.WithLabelValues("model_sync", reportResult(r)).Inc()
+
+	switch {
+	case r.Error != "":
+		slog.Warn("模型同步失败", "source", r.Source, "error", r.Error)
+	case r.Skipped != "":
+		slog.Debug("模型同步跳过", "source", r.Source, "reason", r.Skipped)
+	case r.HasChanges():
+		slog.Info("模型同步变更", "audit", "model_sync", "source", r.Source,
+			"upstream_total", r.UpstreamTotal,
+			"added", changeIDs(r.Added), "removed", changeIDs(r.Removed), "changed", changeIDs(r.Changed))
+	default:
+		slog.Debug("模型同步完成，无变化", "source", r.Source, "upstream_total", r.UpstreamTotal)
+	}
+}
+
+func changeIDs(changes []Change) []string {
+	ids := make([]string, 0, len(changes))
+	for _, c := range changes {
+		ids = append(ids, c.ModelID)
+	}
+	return ids
+}
@@ -0,0 +1,155 @@
+package modelsync
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"orchids-api/internal/store"
+	"orchids-api/internal/testing/fakeredis"
+)
+
+func newTestSyncer(t *testing.T, local []store.Model) *Syncer {
+	t.Helper()
+	srv := fakeredis.Start(t)
+	s, err := store.New(store.Options{RedisAddr: srv.Addr(), SkipSeed: true})
+	if err != nil {
+		t.Fatalf("store.New: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	for i := range local {
+		if err := s.CreateModel(context.Background(), &local[i]); err != nil {
+			t.Fatalf("CreateModel: %v", err)
+		}
+	}
+	return New(s, nil)
+}
+
+func modelIDs(changes []Change) []string {
+	ids := changeIDs(changes)
+	sort.Strings(ids)
+	return ids
+}
+
+func equalIDs(got, want []string) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestApply(t *testing.T) {
+	tests := []struct {
+		name         string
+		local        []store.Model
+		upstream     []upstreamModel
+		channel      string
+		compareNames bool
+		wantAdded    []string
+		wantRemoved  []string
+		wantChanged  []string
+		wantSkipped  bool
+	}{
+		{
+			name:         "added",
+			local:        []store.Model{{Channel: "Warp", ModelID: "gpt-5", Name: "GPT-5 (Warp)"}},
+			upstream:     []upstreamModel{{id: "gpt-5", channel: "Warp", name: "GPT-5 (Warp)"}, {id: "o3", channel: "Warp", name: "o3 (Warp)"}},
+			channel:      "Warp",
+			compareNames: true,
+			wantAdded:    []string{"o3"},
+		},
+		{
+			name:        "removed only within channel",
+			local:       []store.Model{{Channel: "Warp", ModelID: "gpt-5"}, {Channel: "Warp", ModelID: "gone"}, {Channel: "Orchids", ModelID: "claude-opus"}},
+			upstream:    []upstreamModel{{id: "gpt-5", channel: "Warp"}},
+			channel:     "Warp",
+			wantRemoved: []string{"gone"},
+		},
+		{
+			name:         "changed name",
+			local:        []store.Model{{Channel: "Warp", ModelID: "gpt-5", Name: "GPT-5"}},
+			upstream:     []upstreamModel{{id: "gpt-5", channel: "Warp", name: "GPT-5 (Warp)"}},
+			channel:      "Warp",
+			compareNames: true,
+			wantChanged:  []string{"gpt-5"},
+		},
+		{
+			name:     "names ignored without compareNames",
+			local:    []store.Model{{Channel: "Orchids", ModelID: "claude-opus", Name: "Claude Opus"}},
+			upstream: []upstreamModel{{id: "claude-opus", channel: "Orchids", name: "claude-opus"}},
+			channel:  "Orchids",
+		},
+		{
+			name: "removed in owned_by channels",
+			local: []store.Model{
+				{Channel: "Anthropic", ModelID: "claude-opus"},
+				{Channel: "Anthropic", ModelID: "claude-old"},
+				{Channel: "Orchids", ModelID: "orchids-old"},
+				{Channel: "Warp", ModelID: "gpt-5"},
+			},
+			upstream:    []upstreamModel{{id: "claude-opus", channel: "anthropic"}, {id: "claude-new", channel: "Anthropic"}},
+			channel:     "Orchids",
+			wantAdded:   []string{"claude-new"},
+			wantRemoved: []string{"claude-old", "orchids-old"},
+		},
+		{
+			name:        "empty upstream skipped",
+			local:       []store.Model{{Channel: "Warp", ModelID: "gpt-5"}, {Channel: "Warp", ModelID: "o3"}},
+			channel:     "Warp",
+			wantSkipped: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sy := newTestSyncer(t, tt.local)
+			var report Report
+			sy.apply(context.Background(), &report, tt.upstream, tt.channel, tt.compareNames)
+
+			if report.Error != "" {
+				t.Fatalf("Error = %q", report.Error)
+			}
+			if got := report.Skipped != ""; got != tt.wantSkipped {
+				t.Fatalf("Skipped = %q, want skipped %v", report.Skipped, tt.wantSkipped)
+			}
+			if got := modelIDs(report.Added); !equalIDs(got, tt.wantAdded) {
+				t.Errorf("Added = %v, want %v", got, tt.wantAdded)
+			}
+			if got := modelIDs(report.Removed); !equalIDs(got, tt.wantRemoved) {
+				t.Errorf("Removed = %v, want %v", got, tt.wantRemoved)
+			}
+			if got := modelIDs(report.Changed); !equalIDs(got, tt.wantChanged) {
+				t.Errorf("Changed = %v, want %v", got, tt.wantChanged)
+			}
+
+			models, err := sy.store.ListModels(context.Background())
+			if err != nil {
+				t.Fatalf("ListModels: %v", err)
+			}
+			if want := len(tt.local) + len(tt.wantAdded); len(models) != want {
+				t.Fatalf("len(models) = %d, want %d (removed/changed must not be written)", len(models), want)
+			}
+		})
+	}
+}
+
+func TestRun_SkipsSourcesWithoutAccounts(t *testing.T) {
+	sy := newTestSyncer(t, nil)
+	reports := sy.Run(context.Background())
+	if len(reports) != 2 {
+		t.Fatalf("len(reports) = %d, want 2", len(reports))
+	}
+	for _, r := range reports {
+		if r.Skipped == "" || r.Error != "" {
+			t.Fatalf("report %+v, want skipped", r)
+		}
+	}
+	if got := sy.LastReports(); len(got) != 2 || got[0].Source != SourceOrchids || got[1].Source != SourceWarp {
+		t.Fatalf("LastReports = %+v", got)
+	}
+}
@@ -16,9 +16,11 @@ import (
 	"sync"
 	"time"
 
+	"orchids-api/internal/auth"
 	"orchids-api/internal/clerk"
 	"orchids-api/internal/config"
 	"orchids-api/internal/debug"
+	"orchids-api/internal/metrics"
 	"orchids-api/internal/perf"
 	"orchids-api/internal/prompt"
 	"orchids-api/internal/store"
@@ -69,6 +71,9 @@ type cachedToken struct {
 	expiresAt time.Time
 }
 
+// tokenCacheMetricsLabel 为 Prometheus 指标中 JWT 缓存的 cache 标签
+const tokenCacheMetricsLabel = "orchids_token"
+
 var tokenCache = struct {
 	mu    sync.RWMutex
 	items map[string]cachedToken
@@ -194,15 +199,34 @@ func (c *Client) GetToken() (string, error) {
 		return c.config.UpstreamToken, nil
 	}
 
-	if c.config.AutoRefreshToken {
-		return c.forceRefreshToken()
+	if !c.config.AutoRefreshToken {
+		if cached, ok := getCachedToken(c.config.SessionID); ok {
+			return cached, nil
+		}
 	}
 
-	if cached, ok := getCachedToken(c.config.SessionID); ok {
-		return cached, nil
+	// 负缓存：最近换取失败的账号在退避期内直接返回上次错误，避免每次请求都打到 Clerk
+	failureKey := c.tokenFailureKey()
+	if err, ok := recentTokenFailure(failureKey); ok {
+		return "", err
 	}
 
-	return c.fetchToken()
+	var (
+		token string
+		err   error
+	)
+	if c.config.AutoRefreshToken {
+		token, err = c.forceRefreshToken()
+	} else {
+		token, err = c.fetchToken()
+	}
+	if err != nil {
+		backoff := recordTokenFailure(failureKey, err)
+		slog.Warn("Orchids token exchange failed, backing off", "session", auth.MaskSensitive(c.config.SessionID), "backoff", backoff, "error", err)
+		return "", err
+	}
+	clearTokenFailure(failureKey)
+	return token, nil
 }
 
 func (c *Client) forceRefreshToken() (string, error) {
@@ -710,6 +734,7 @@ func getCachedToken(sessionID string) (string, bool) {
 	entry, ok := tokenCache.items[sessionID]
 	tokenCache.mu.RUnlock()
 	if !ok {
+		metrics.CacheHits.WithLabelValues(tokenCacheMetricsLabel, "miss").Inc()
 		return "", false
 	}
 
@@ -717,11 +742,14 @@ func getCachedToken(sessionID string) (string, bool) {
 		tokenCache.mu.Lock()
 		if current, ok := tokenCache.items[sessionID]; ok && current.token == entry.token && current.expiresAt.Equal(entry.expiresAt) {
 			delete(tokenCache.items, sessionID)
+			metrics.CacheEvictions.WithLabelValues(tokenCacheMetricsLabel, "expired").Inc()
 		}
 		tokenCache.mu.Unlock()
+		metrics.CacheHits.WithLabelValues(tokenCacheMetricsLabel, "miss").Inc()
 		return "", false
 	}
 
+	metrics.CacheHits.WithLabelValues(tokenCacheMetricsLabel, "hit").Inc()
 	return entry.token, true
 }
 
@@ -743,7 +771,7 @@ func setCachedToken(sessionID, token string) {
 	tokenCache.mu.Unlock()
 }
 
-// InvalidateCachedToken 清除指定 sessionID 的 token 缓存，
+// InvalidateCachedToken 清除指定 sessionID 的 token 缓存及换取失败退避，
 // 用于账号 401 冷却恢复后强制重新获取 token。
 func InvalidateCachedToken(sessionID string) {
 	if sessionID == "" {
@@ -752,6 +780,40 @@ func InvalidateCachedToken(sessionID string) {
 	tokenCache.mu.Lock()
 	delete(tokenCache.items, sessionID)
 	tokenCache.mu.Unlock()
+	clearTokenFailure(sessionID)
+}
+
+// CachedTokenInfo 为管理接口展示的 token 缓存条目，token 已脱敏
+type CachedTokenInfo struct {
+	Token      string    `json:"token"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	TTLSeconds int64     `json:"ttl_seconds"`
+}
+
+// LookupCachedToken 返回指定 sessionID 当前缓存的 token 信息（不含已过期条目）
+func LookupCachedToken(sessionID string) (CachedTokenInfo, bool) {
+	if sessionID == "" {
+		return CachedTokenInfo{}, false
+	}
+	tokenCache.mu.RLock()
+	entry, ok := tokenCache.items[sessionID]
+	tokenCache.mu.RUnlock()
+	ttl := time.Until(entry.expiresAt)
+	if !ok || ttl <= 0 {
+		return CachedTokenInfo{}, false
+	}
+	return CachedTokenInfo{
+		Token:      maskCachedToken(entry.token),
+		ExpiresAt:  entry.expiresAt,
+		TTLSeconds: int64(ttl / time.Second),
+	}, true
+}
+
+func maskCachedToken(token string) string {
+	if len(token) <= 12 {
+		return "***"
+	}
+	return token[:6] + "..." + token[len(token)-4:]
 }
 
 func tokenExpiry(token string) time.Time {
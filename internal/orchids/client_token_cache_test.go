@@ -0,0 +1,32 @@
+package orchids
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLookupCachedToken_MasksAndSkipsExpired(t *testing.T) {
+	const sessionID = "sess_lookup_test"
+	token := "eyJhbGciOi.payload-part.signature1234"
+	setCachedToken(sessionID, token)
+	defer InvalidateCachedToken(sessionID)
+
+	info, ok := LookupCachedToken(sessionID)
+	if !ok {
+		t.Fatal("expected cached token")
+	}
+	if strings.Contains(info.Token, "payload-part") || !strings.HasSuffix(info.Token, "1234") {
+		t.Fatalf("token not masked: %q", info.Token)
+	}
+	if info.TTLSeconds <= 0 {
+		t.Fatalf("ttl = %d, want > 0", info.TTLSeconds)
+	}
+
+	tokenCache.mu.Lock()
+	tokenCache.items[sessionID] = cachedToken{token: token, expiresAt: time.Now().Add(-time.Second)}
+	tokenCache.mu.Unlock()
+	if _, ok := LookupCachedToken(sessionID); ok {
+		t.Fatal("expired token should not be listed")
+	}
+}
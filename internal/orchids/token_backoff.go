@@ -0,0 +1,115 @@
+package orchids
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// token 换取失败后的负缓存退避区间：5s 起按失败次数翻倍，上限 5 分钟
+const (
+	tokenFailureBaseBackoff = 5 * time.Second
+	tokenFailureMaxBackoff  = 5 * time.Minute
+)
+
+type tokenFailure struct {
+	err      error
+	failures int
+	until    time.Time
+}
+
+var tokenFailureCache = struct {
+	mu    sync.Mutex
+	items map[string]*tokenFailure
+}{
+	items: map[string]*tokenFailure{},
+}
+
+// tokenFailureKey 返回负缓存键：优先 sessionID，其次账号 ID；都没有时不缓存
+func (c *Client) tokenFailureKey() string {
+	if c.config != nil {
+		if sid := strings.TrimSpace(c.config.SessionID); sid != "" {
+			return sid
+		}
+	}
+	if c.account != nil && c.account.ID > 0 {
+		return fmt.Sprintf("acct:%d", c.account.ID)
+	}
+	return ""
+}
+
+// recentTokenFailure 在退避期内返回上一次失败的错误，调用方据此直接失败而不再请求 Clerk
+func recentTokenFailure(key string) (error, bool) {
+	if key == "" {
+		return nil, false
+	}
+	tokenFailureCache.mu.Lock()
+	defer tokenFailureCache.mu.Unlock()
+	entry, ok := tokenFailureCache.items[key]
+	if !ok {
+		return nil, false
+	}
+	remaining := time.Until(entry.until)
+	if remaining <= 0 {
+		return nil, false
+	}
+	return fmt.Errorf("token exchange backing off for %s after %d failure(s): %w", remaining.Round(time.Second), entry.failures, entry.err), true
+}
+
+// recordTokenFailure 记录一次换取失败并返回本次退避时长
+func recordTokenFailure(key string, err error) time.Duration {
+	if key == "" || err == nil {
+		return 0
+	}
+	tokenFailureCache.mu.Lock()
+	defer tokenFailureCache.mu.Unlock()
+	entry, ok := tokenFailureCache.items[key]
+	if !ok {
+		entry = &tokenFailure{}
+		tokenFailureCache.items[key] = entry
+	}
+	entry.failures++
+	entry.err = err
+
+	backoff := tokenFailureBaseBackoff
+	for i := 1; i < entry.failures && backoff < tokenFailureMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > tokenFailureMaxBackoff {
+		backoff = tokenFailureMaxBackoff
+	}
+	entry.until = time.Now().Add(backoff)
+	return backoff
+}
+
+func clearTokenFailure(key string) {
+	if key == "" {
+		return
+	}
+	tokenFailureCache.mu.Lock()
+	delete(tokenFailureCache.items, key)
+	tokenFailureCache.mu.Unlock()
+}
+
+// TokenBackoffRemaining 返回账号 token 换取仍处于退避中的剩余时长，供负载均衡跳过该账号
+func TokenBackoffRemaining(sessionID string, accountID int64) (time.Duration, bool) {
+	key := strings.TrimSpace(sessionID)
+	if key == "" && accountID > 0 {
+		key = fmt.Sprintf("acct:%d", accountID)
+	}
+	if key == "" {
+		return 0, false
+	}
+	tokenFailureCache.mu.Lock()
+	defer tokenFailureCache.mu.Unlock()
+	entry, ok := tokenFailureCache.items[key]
+	if !ok {
+		return 0, false
+	}
+	remaining := time.Until(entry.until)
+	if remaining <= 0 {
+		return 0, false
+	}
+	return remaining, true
+}
@@ -0,0 +1,77 @@
+package orchids
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"orchids-api/internal/config"
+)
+
+func TestRecordTokenFailure_ExponentialBackoff(t *testing.T) {
+	const key = "sess_backoff_exp"
+	defer clearTokenFailure(key)
+
+	want := []time.Duration{5 * time.Second, 10 * time.Second, 20 * time.Second, 40 * time.Second}
+	for i, w := range want {
+		if got := recordTokenFailure(key, errors.New("boom")); got != w {
+			t.Fatalf("failure %d backoff = %v, want %v", i+1, got, w)
+		}
+	}
+	for i := 0; i < 20; i++ {
+		recordTokenFailure(key, errors.New("boom"))
+	}
+	if got := recordTokenFailure(key, errors.New("boom")); got != tokenFailureMaxBackoff {
+		t.Fatalf("backoff not capped: %v", got)
+	}
+}
+
+func TestGetToken_NegativeCacheSkipsExchange(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "session revoked", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	const sessionID = "sess_negative_cache"
+	defer InvalidateCachedToken(sessionID)
+
+	c := &Client{
+		config:     &config.Config{SessionID: sessionID},
+		httpClient: &http.Client{Transport: rewriteTransport{target: srv.URL}},
+	}
+	if _, err := c.GetToken(); err == nil {
+		t.Fatal("expected first exchange to fail")
+	}
+	if _, err := c.GetToken(); err == nil {
+		t.Fatal("expected backoff error")
+	}
+	if calls.Load() != 1 {
+		t.Fatalf("token endpoint called %d times, want 1", calls.Load())
+	}
+	if _, ok := TokenBackoffRemaining(sessionID, 0); !ok {
+		t.Fatal("expected account to be backing off")
+	}
+
+	InvalidateCachedToken(sessionID)
+	if _, ok := TokenBackoffRemaining(sessionID, 0); ok {
+		t.Fatal("invalidate should clear backoff")
+	}
+}
+
+// rewriteTransport 将所有请求转发到测试服务器
+type rewriteTransport struct {
+	target string
+}
+
+func (t rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := req.Clone(req.Context())
+	u, _ := out.URL.Parse(t.target)
+	out.URL.Scheme = u.Scheme
+	out.URL.Host = u.Host
+	return http.DefaultTransport.RoundTrip(out)
+}
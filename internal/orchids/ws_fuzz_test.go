@@ -0,0 +1,81 @@
+package orchids
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"sync"
+	"testing"
+
+	"orchids-api/internal/upstream"
+)
+
+func FuzzExtractOrchidsText(f *testing.F) {
+	seeds := []string{
+		`{"type":"output_text_delta","text":"hello"}`,
+		`{"type":"response.chunk","data":{"delta":"hi"}}`,
+		`{"type":"error","data":{"code":"x","message":"boom"}}`,
+		`{"type":"response_done","response":{"output":[{"type":"function_call","name":"Read","arguments":"{\"file_path\":\"a\"}","callId":"c1"}]}}`,
+		`{"data":null}`,
+		`{}`,
+	}
+	for _, s := range seeds {
+		f.Add([]byte(s))
+	}
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		var msg map[string]interface{}
+		if err := json.Unmarshal(data, &msg); err != nil {
+			return
+		}
+		_ = extractOrchidsText(msg)
+		_, _ = extractOrchidsError(msg)
+		for _, call := range extractToolCallsFromResponse(msg) {
+			if call.id == "" || call.name == "" {
+				t.Fatalf("tool call missing id or name: %#v", call)
+			}
+		}
+	})
+}
+
+// FuzzHandleOrchidsMessage 以 JSONL 形式输入一串上游消息，验证解析不会 panic 且输出事件都带类型。
+// fs_operation 与 write 完成事件会真实操作文件系统/连接，这里跳过。
+func FuzzHandleOrchidsMessage(f *testing.F) {
+	f.Add([]byte(`{"type":"coding_agent.reasoning.chunk","data":{"text":"think"}}
+{"type":"coding_agent.reasoning.completed"}
+{"type":"output_text_delta","text":"hello"}
+{"type":"response_done"}`))
+	f.Add([]byte(`{"type":"model","event":{"type":"text-start","id":"0"}}
+{"type":"model","event":{"type":"text-delta","id":"0","delta":"x"}}
+{"type":"model","event":{"type":"finish","finishReason":"stop"}}`))
+	f.Add([]byte(`{"type":"coding_agent.Write.started","data":{"file_path":"a.go"}}
+{"type":"coding_agent.Write.content.chunk","data":{"file_path":"a.go","text":"package a"}}`))
+	f.Add([]byte(`{"type":"coding_agent.credits_exhausted","data":{}}`))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		c := &Client{}
+		state := &requestState{}
+		var fsWG sync.WaitGroup
+		onMessage := func(m upstream.SSEMessage) {
+			if m.Type == "" {
+				t.Fatalf("emitted message without type: %#v", m)
+			}
+		}
+
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(make([]byte, 0, 64*1024), 1<<20)
+		for scanner.Scan() {
+			var msg map[string]interface{}
+			if err := json.Unmarshal(scanner.Bytes(), &msg); err != nil {
+				continue
+			}
+			msgType, _ := msg["type"].(string)
+			if msgType == "" || msgType == EventFS || msgType == EventWriteCompleted {
+				continue
+			}
+			if c.handleOrchidsMessage(msg, scanner.Bytes(), state, onMessage, nil, nil, &fsWG, "") {
+				break
+			}
+		}
+	})
+}
@@ -0,0 +1,47 @@
+package perf
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"testing"
+)
+
+func assertMatchesMarshal(t *testing.T, s string) {
+	t.Helper()
+	want, err := json.Marshal(s)
+	if err != nil {
+		t.Fatalf("marshal %q: %v", s, err)
+	}
+	var buf bytes.Buffer
+	WriteJSONString(&buf, s)
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Fatalf("input %q:\n got  %s\n want %s", s, buf.Bytes(), want)
+	}
+}
+
+func TestWriteJSONString_MatchesMarshal(t *testing.T) {
+	inputs := []string{
+		"",
+		"plain",
+		"quote \" backslash \\ newline \n tab \t cr \r bs \b ff \f",
+		"<html> & 'single'",
+		"unicode 中文 emoji 🎉 sep \u2028\u2029",
+		// 非法 UTF-8：截断的多字节序列、过长编码、代理区码点与孤立的续字节
+		"\xe4\xb8", "\xf0\x9f\x8e", "\xc0\xaf", "\xe0\x80\xaf", "\xed\xa0\x80", "\xf4\x90\x80\x80", "\x80\xbf", "a\xffb\xfe",
+	}
+	for _, in := range inputs {
+		assertMatchesMarshal(t, in)
+	}
+	for b := 0; b < 256; b++ {
+		assertMatchesMarshal(t, "x"+string([]byte{byte(b)})+"y")
+	}
+
+	// 运行时与当前 Go 版本的 encoding/json 对比任意字节序列，标准库行为变化时在此暴露
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 5000; i++ {
+		b := make([]byte, rng.Intn(16))
+		rng.Read(b)
+		assertMatchesMarshal(t, string(b))
+	}
+}
@@ -67,11 +67,18 @@ func AcquireByteBuffer() *bytes.Buffer {
 	return ByteBufferPool.Get().(*bytes.Buffer)
 }
 
+// maxPooledByteBuffer caps the capacity of buffers returned to the pool so a
+// single huge payload doesn't pin memory for the lifetime of the process.
+const maxPooledByteBuffer = 1 << 20
+
 // ReleaseByteBuffer resets and returns a bytes.Buffer to the pool.
 func ReleaseByteBuffer(b *bytes.Buffer) {
 	if b == nil {
 		return
 	}
+	if b.Cap() > maxPooledByteBuffer {
+		return
+	}
 	b.Reset()
 	ByteBufferPool.Put(b)
 }
@@ -792,6 +792,20 @@ func summarizeMessagesWithCache(ctx context.Context, opts PromptOptions, message
 	}
 
 	hashes := hashMessages(messages)
+	if ok && !isPrefix(entry.Hashes, hashes) && len(entry.Lines) == len(entry.Hashes) {
+		// 会话分叉（重新生成或编辑了较早的消息）：丢弃分叉点之后的摘要行，复用共同前缀。
+		// 摘要行与消息一一对应时才能按位置截断，否则走下面的全量重建
+		if n := commonPrefixLen(entry.Hashes, hashes); n > 0 {
+			entry = SummaryCacheEntry{
+				Summary:   joinLines(entry.Lines[:n]),
+				Lines:     entry.Lines[:n:n],
+				Hashes:    entry.Hashes[:n:n],
+				Budget:    entry.Budget,
+				UpdatedAt: time.Now(),
+			}
+			cache.Put(ctx, key, entry)
+		}
+	}
 	if ok && isPrefix(entry.Hashes, hashes) {
 		if len(entry.Hashes) == len(hashes) {
 			if entry.Summary != "" && tiktoken.EstimateTextTokens(entry.Summary) <= maxTokens {
@@ -995,6 +1009,19 @@ func writeToolResultHash(hasher hash.Hash, content interface{}) {
 	}
 }
 
+// HashMessages 返回每条消息的内容哈希，与摘要缓存使用的哈希一致
+func HashMessages(messages []Message) []string {
+	return hashMessages(messages)
+}
+
+func commonPrefixLen(a, b []string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
+
 func isPrefix(prefix []string, full []string) bool {
 	if len(prefix) > len(full) {
 		return false
@@ -0,0 +1,68 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// benchHistory 构造交替的 user/assistant 对话；toolResultSize > 0 时每轮附带 tool_use/tool_result
+func benchHistory(turns, toolResultSize int) []Message {
+	msgs := make([]Message, 0, turns*2+1)
+	for i := 0; i < turns; i++ {
+		user := Message{Role: "user", Content: MessageContent{Text: fmt.Sprintf("请帮我检查第 %d 个模块里的错误处理，并解释为什么会失败。", i)}}
+		assistant := Message{Role: "assistant", Content: MessageContent{Blocks: []ContentBlock{
+			{Type: "text", Text: strings.Repeat("Looking at the handler, the error path drops the context. ", 4)},
+		}}}
+		if toolResultSize > 0 {
+			id := fmt.Sprintf("toolu_%d", i)
+			assistant.Content.Blocks = append(assistant.Content.Blocks, ContentBlock{
+				Type:  "tool_use",
+				ID:    id,
+				Name:  "Read",
+				Input: map[string]interface{}{"file_path": fmt.Sprintf("/repo/internal/mod%d/file.go", i)},
+			})
+			msgs = append(msgs, user, assistant, Message{Role: "user", Content: MessageContent{Blocks: []ContentBlock{
+				{Type: "tool_result", ToolUseID: id, Content: strings.Repeat("func example() error { return nil }\n", toolResultSize/36+1)},
+			}}})
+			continue
+		}
+		msgs = append(msgs, user, assistant)
+	}
+	msgs = append(msgs, Message{Role: "user", Content: MessageContent{Text: "继续"}})
+	return msgs
+}
+
+func benchmarkBuildPrompt(b *testing.B, msgs []Message, opts PromptOptions) {
+	req := ClaudeAPIRequest{
+		Model:    "claude-opus-4-6",
+		Messages: msgs,
+		System:   []SystemItem{{Type: "text", Text: "You are a coding assistant."}},
+		Tools:    []interface{}{map[string]interface{}{"name": "Read"}, map[string]interface{}{"name": "Edit"}},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = BuildPromptV2WithOptions(req, opts)
+	}
+}
+
+func BenchmarkBuildPromptV2_100Messages(b *testing.B) {
+	benchmarkBuildPrompt(b, benchHistory(50, 0), PromptOptions{})
+}
+
+func BenchmarkBuildPromptV2_1000Messages(b *testing.B) {
+	benchmarkBuildPrompt(b, benchHistory(500, 0), PromptOptions{})
+}
+
+func BenchmarkBuildPromptV2_1000Messages_Budget(b *testing.B) {
+	benchmarkBuildPrompt(b, benchHistory(500, 0), PromptOptions{MaxTokens: 12000})
+}
+
+func BenchmarkBuildPromptV2_LargeToolResults(b *testing.B) {
+	benchmarkBuildPrompt(b, benchHistory(40, 64*1024), PromptOptions{})
+}
+
+func BenchmarkBuildPromptV2_LargeToolResults_Budget(b *testing.B) {
+	benchmarkBuildPrompt(b, benchHistory(40, 64*1024), PromptOptions{MaxTokens: 12000})
+}
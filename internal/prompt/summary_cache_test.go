@@ -0,0 +1,51 @@
+package prompt
+
+import (
+	"context"
+	"testing"
+)
+
+type mapSummaryCache map[string]SummaryCacheEntry
+
+func (c mapSummaryCache) Get(_ context.Context, key string) (SummaryCacheEntry, bool) {
+	entry, ok := c[key]
+	return entry, ok
+}
+
+func (c mapSummaryCache) Put(_ context.Context, key string, entry SummaryCacheEntry) {
+	c[key] = entry
+}
+
+func (c mapSummaryCache) GetStats(context.Context) (int64, int64, error) { return 0, 0, nil }
+
+func (c mapSummaryCache) Clear(context.Context) error { return nil }
+
+func TestSummarizeMessagesWithCache_BranchKeepsCommonPrefix(t *testing.T) {
+	cache := mapSummaryCache{}
+	opts := PromptOptions{ConversationID: "conv", SummaryCache: cache}
+	msg := func(role, text string) Message {
+		return Message{Role: role, Content: MessageContent{Text: text}}
+	}
+	history := []Message{msg("user", "first question"), msg("assistant", "first answer"), msg("user", "second question")}
+	summarizeMessagesWithCache(context.Background(), opts, history, 1000)
+
+	// 修改最后一条消息：前两条的摘要行保留，分叉之后的重新生成
+	cache["conv"] = SummaryCacheEntry{
+		Lines:  []string{"- user: cached first", "- assistant: cached answer", "- user: cached second"},
+		Hashes: cache["conv"].Hashes,
+		Budget: 1000,
+	}
+	branched := []Message{history[0], history[1], msg("user", "edited question")}
+	summary := summarizeMessagesWithCache(context.Background(), opts, branched, 1000)
+
+	entry := cache["conv"]
+	if len(entry.Lines) != 3 || entry.Lines[0] != "- user: cached first" || entry.Lines[1] != "- assistant: cached answer" {
+		t.Fatalf("common prefix not reused: %q", entry.Lines)
+	}
+	if entry.Lines[2] == "- user: cached second" || !isPrefix(entry.Hashes, hashMessages(branched)) {
+		t.Fatalf("suffix of abandoned branch kept: %q", entry.Lines)
+	}
+	if summary == "" {
+		t.Fatal("empty summary")
+	}
+}
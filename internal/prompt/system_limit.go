@@ -0,0 +1,134 @@
+package prompt
+
+import (
+	"fmt"
+	"strings"
+
+	"orchids-api/internal/tiktoken"
+)
+
+// 系统提示超出上限时的处理策略
+const (
+	SystemTrimTruncate  = "truncate"  // 保留开头，截掉超出预算的尾部
+	SystemTrimSummarize = "summarize" // 按段落压缩，每段保留开头，整体不超过预算
+	SystemTrimReject    = "reject"    // 拒绝请求
+)
+
+// SystemPromptTooLargeError 为 reject 策略下系统提示超出上限的错误
+type SystemPromptTooLargeError struct {
+	Tokens    int
+	MaxTokens int
+}
+
+func (e *SystemPromptTooLargeError) Error() string {
+	return fmt.Sprintf("system prompt is %d tokens, exceeding the limit of %d tokens", e.Tokens, e.MaxTokens)
+}
+
+// SystemPromptTokens 估算系统提示中文本项的 token 数
+func SystemPromptTokens(items []SystemItem) int {
+	total := 0
+	for _, item := range items {
+		if item.Type == "text" && item.Text != "" {
+			total += tiktoken.EstimateTextTokens(item.Text)
+		}
+	}
+	return total
+}
+
+// LimitSystemPrompt 将系统提示限制在 maxTokens 以内，返回处理后的系统提示以及是否发生了裁剪。
+// maxTokens <= 0 表示不限制；reject 策略超出上限时返回 *SystemPromptTooLargeError。
+// 不修改传入的 items，cache_control 等字段原样保留
+func LimitSystemPrompt(items []SystemItem, maxTokens int, strategy string) ([]SystemItem, bool, error) {
+	if maxTokens <= 0 {
+		return items, false, nil
+	}
+	tokens := SystemPromptTokens(items)
+	if tokens <= maxTokens {
+		return items, false, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(strategy)) {
+	case SystemTrimReject:
+		return items, false, &SystemPromptTooLargeError{Tokens: tokens, MaxTokens: maxTokens}
+	case SystemTrimSummarize:
+		return summarizeSystemItems(items, tokens, maxTokens), true, nil
+	default:
+		return truncateSystemItems(items, maxTokens), true, nil
+	}
+}
+
+// truncateSystemItems 按顺序保留系统提示，超出预算的那一项截断，其后的文本项丢弃
+func truncateSystemItems(items []SystemItem, maxTokens int) []SystemItem {
+	out := make([]SystemItem, 0, len(items))
+	remaining := maxTokens
+	for _, item := range items {
+		if item.Type != "text" || item.Text == "" {
+			out = append(out, item)
+			continue
+		}
+		if remaining <= 0 {
+			continue
+		}
+		tokens := tiktoken.EstimateTextTokens(item.Text)
+		if tokens > remaining {
+			item.Text = truncateToTokens(item.Text, remaining)
+			tokens = remaining
+		}
+		remaining -= tokens
+		if item.Text != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// summarizeSystemItems 按原始大小给每个文本项分配预算，项内按空行切分段落并截断每段，
+// 与 summarizeMessages 相同：逐步收紧每段预算直到整体不超过上限
+func summarizeSystemItems(items []SystemItem, totalTokens, maxTokens int) []SystemItem {
+	out := make([]SystemItem, len(items))
+	copy(out, items)
+	for i, item := range out {
+		if item.Type != "text" || item.Text == "" {
+			continue
+		}
+		budget := tiktoken.EstimateTextTokens(item.Text) * maxTokens / totalTokens
+		out[i].Text = summarizeParagraphs(item.Text, budget)
+	}
+	// 各项取整后的误差由截断兜底
+	if SystemPromptTokens(out) > maxTokens {
+		out = truncateSystemItems(out, maxTokens)
+	}
+	return out
+}
+
+func summarizeParagraphs(text string, maxTokens int) string {
+	if maxTokens <= 0 {
+		return ""
+	}
+	var paragraphs []string
+	for _, p := range strings.Split(text, "\n\n") {
+		if p = strings.TrimSpace(p); p != "" {
+			paragraphs = append(paragraphs, p)
+		}
+	}
+	if len(paragraphs) == 0 {
+		return ""
+	}
+
+	perParagraph := maxTokens / len(paragraphs)
+	for perParagraph >= 4 {
+		var sb strings.Builder
+		for i, p := range paragraphs {
+			if i > 0 {
+				sb.WriteString("\n\n")
+			}
+			sb.WriteString(truncateToTokens(p, perParagraph))
+		}
+		if joined := sb.String(); tiktoken.EstimateTextTokens(joined) <= maxTokens {
+			return joined
+		}
+		perParagraph = int(float64(perParagraph) * 0.7)
+	}
+	// 段落过多时只能保留开头
+	return truncateToTokens(text, maxTokens)
+}
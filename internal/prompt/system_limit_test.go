@@ -0,0 +1,66 @@
+package prompt
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func longSystem() []SystemItem {
+	var paragraphs []string
+	for i := 0; i < 6; i++ {
+		paragraphs = append(paragraphs, "Rule "+strings.Repeat("keep the code style consistent ", 40))
+	}
+	return []SystemItem{
+		{Type: "text", Text: strings.Join(paragraphs, "\n\n"), CacheControl: &CacheControl{Type: "ephemeral"}},
+		{Type: "text", Text: "TAIL " + strings.Repeat("trailing instructions ", 200)},
+	}
+}
+
+func TestLimitSystemPrompt_UnderLimit(t *testing.T) {
+	items := []SystemItem{{Type: "text", Text: "short"}}
+	got, trimmed, err := LimitSystemPrompt(items, 100, SystemTrimReject)
+	if err != nil || trimmed || len(got) != 1 || got[0].Text != "short" {
+		t.Fatalf("got %+v trimmed=%v err=%v", got, trimmed, err)
+	}
+	if _, trimmed, _ := LimitSystemPrompt(longSystem(), 0, SystemTrimTruncate); trimmed {
+		t.Fatal("max_tokens 0 should disable the limit")
+	}
+}
+
+func TestLimitSystemPrompt_Strategies(t *testing.T) {
+	const limit = 200
+	items := longSystem()
+	original := items[0].Text
+
+	truncated, trimmed, err := LimitSystemPrompt(items, limit, SystemTrimTruncate)
+	if err != nil || !trimmed {
+		t.Fatalf("truncate: trimmed=%v err=%v", trimmed, err)
+	}
+	if tokens := SystemPromptTokens(truncated); tokens > limit {
+		t.Fatalf("truncate: %d tokens > %d", tokens, limit)
+	}
+	if len(truncated) != 1 || !strings.HasPrefix(original, strings.TrimSuffix(truncated[0].Text, "…")) || truncated[0].CacheControl == nil {
+		t.Fatalf("truncate should keep the head of the first item: %+v", truncated)
+	}
+	if items[0].Text != original {
+		t.Fatal("input items modified")
+	}
+
+	summarized, trimmed, err := LimitSystemPrompt(items, limit, SystemTrimSummarize)
+	if err != nil || !trimmed {
+		t.Fatalf("summarize: trimmed=%v err=%v", trimmed, err)
+	}
+	if tokens := SystemPromptTokens(summarized); tokens > limit {
+		t.Fatalf("summarize: %d tokens > %d", tokens, limit)
+	}
+	if len(summarized) != 2 || strings.Count(summarized[0].Text, "Rule") != 6 || !strings.HasPrefix(summarized[1].Text, "TAIL") {
+		t.Fatalf("summarize should keep every paragraph and item: %+v", summarized)
+	}
+
+	_, _, err = LimitSystemPrompt(items, limit, SystemTrimReject)
+	var tooLarge *SystemPromptTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.MaxTokens != limit || tooLarge.Tokens <= limit {
+		t.Fatalf("reject: err=%v", err)
+	}
+}
@@ -0,0 +1,404 @@
+// Package report 汇总一个周期内的请求、token、账号错误、被禁用账号与额度情况，
+// 按 daily / weekly 定时通过 SMTP 发送报表邮件。
+// 请求与 token 来自事件总线的 request.completed，周期内的计数只保存在内存中，发送成功后清零
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"orchids-api/internal/accountstats"
+	"orchids-api/internal/config"
+	"orchids-api/internal/events"
+	"orchids-api/internal/store"
+)
+
+// 报表周期
+const (
+	Daily  = "daily"
+	Weekly = "weekly"
+)
+
+const (
+	// topN 为模型 / API Key / 错误账号排行的条数
+	topN = 10
+	// maxStatusChanges 为一个周期内保留的账号状态变化条数
+	maxStatusChanges      = 50
+	defaultQuotaWarnRatio = 0.9
+)
+
+// UsageRow 为模型或 API Key 的用量排行中的一项
+type UsageRow struct {
+	Name     string `json:"name"`
+	Requests int64  `json:"requests"`
+	Tokens   int64  `json:"tokens"`
+}
+
+// AccountErrorRow 为错误账号排行中的一项
+type AccountErrorRow struct {
+	AccountID int64   `json:"account_id"`
+	Name      string  `json:"name,omitempty"`
+	Requests  int64   `json:"requests"`
+	Errors    int64   `json:"errors"`
+	ErrorRate float64 `json:"error_rate"`
+	LastError string  `json:"last_error,omitempty"`
+}
+
+// AccountRow 为被禁用或处于异常状态的账号
+type AccountRow struct {
+	AccountID   int64  `json:"account_id"`
+	Name        string `json:"name"`
+	AccountType string `json:"account_type,omitempty"`
+	Enabled     bool   `json:"enabled"`
+	StatusCode  string `json:"status_code,omitempty"`
+}
+
+// QuotaRow 为额度接近上限的账号
+type QuotaRow struct {
+	AccountID int64   `json:"account_id"`
+	Name      string  `json:"name"`
+	Used      float64 `json:"used"`
+	Limit     float64 `json:"limit"`
+	Ratio     float64 `json:"ratio"`
+}
+
+// Summary 为一份报表的内容
+type Summary struct {
+	Period       string     `json:"period"`
+	From         time.Time  `json:"from"`
+	To           time.Time  `json:"to"`
+	Requests     int64      `json:"requests"`
+	InputTokens  int64      `json:"input_tokens"`
+	OutputTokens int64      `json:"output_tokens"`
+	Cost         float64    `json:"cost"`
+	Currency     string     `json:"currency,omitempty"`
+	TopModels    []UsageRow `json:"top_models"`
+	TopKeys      []UsageRow `json:"top_keys"`
+	// 账号错误来自 accountstats，覆盖最近 ErrorWindowHours 小时而非整个报表周期
+	ErrorWindowHours int                    `json:"error_window_hours"`
+	AccountErrors    []AccountErrorRow      `json:"account_errors"`
+	Anomalies        []accountstats.Anomaly `json:"anomalies"`
+	StatusChanges    []events.AccountStatus `json:"status_changes"`
+	DisabledAccounts []AccountRow           `json:"disabled_accounts"`
+	QuotaWarnings    []QuotaRow             `json:"quota_warnings"`
+}
+
+type usageCounter struct {
+	requests int64
+	tokens   int64
+}
+
+// Reporter 累积周期内的用量并生成、发送报表；作为事件总线的目标接收
+// request.completed 与 account.status_changed
+type Reporter struct {
+	store *store.Store
+	stats *accountstats.Tracker
+	cfg   *config.Config
+	now   func() time.Time
+	send  func(cfg *config.Config, subject, body string) error
+
+	mu            sync.Mutex
+	since         time.Time
+	requests      int64
+	inputTokens   int64
+	outputTokens  int64
+	cost          float64
+	currency      string
+	models        map[string]*usageCounter
+	keys          map[string]*usageCounter
+	statusChanges []events.AccountStatus
+}
+
+// New 创建报表器，cfg 在每次生成与调度时读取
+func New(s *store.Store, stats *accountstats.Tracker, cfg *config.Config) *Reporter {
+	r := &Reporter{store: s, stats: stats, cfg: cfg, now: time.Now, send: sendMail}
+	r.reset(r.now())
+	return r
+}
+
+// Enabled 报告是否配置了定时报表
+func (r *Reporter) Enabled() bool {
+	return r.cfg.ReportSchedule != "" && len(r.cfg.ReportRecipients) > 0 && r.cfg.ReportSMTPAddr != ""
+}
+
+// reset 清零周期计数，调用方持有 r.mu 或尚未共享 r
+func (r *Reporter) reset(now time.Time) {
+	r.since = now
+	r.requests, r.inputTokens, r.outputTokens, r.cost, r.currency = 0, 0, 0, 0, ""
+	r.models = map[string]*usageCounter{}
+	r.keys = map[string]*usageCounter{}
+	r.statusChanges = nil
+}
+
+// Publish 实现 events.Sink
+func (r *Reporter) Publish(e events.Event) {
+	switch e.Type {
+	case events.RequestCompleted:
+		var u events.Usage
+		if json.Unmarshal(e.Data, &u) != nil {
+			return
+		}
+		tokens := int64(u.InputTokens + u.OutputTokens)
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.requests++
+		r.inputTokens += int64(u.InputTokens)
+		r.outputTokens += int64(u.OutputTokens)
+		r.cost += u.Cost
+		if u.Currency != "" {
+			r.currency = u.Currency
+		}
+		add(r.models, u.Model, tokens)
+		key := u.APIKeyID
+		if key == "" {
+			key = "(no key)"
+		}
+		add(r.keys, key, tokens)
+	case events.AccountStatusChanged:
+		var s events.AccountStatus
+		if json.Unmarshal(e.Data, &s) != nil {
+			return
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if len(r.statusChanges) >= maxStatusChanges {
+			r.statusChanges = r.statusChanges[1:]
+		}
+		r.statusChanges = append(r.statusChanges, s)
+	}
+}
+
+// Close 实现 events.Sink，计数只在内存中，无需刷新
+func (r *Reporter) Close() error { return nil }
+
+func add(m map[string]*usageCounter, name string, tokens int64) {
+	c, ok := m[name]
+	if !ok {
+		c = &usageCounter{}
+		m[name] = c
+	}
+	c.requests++
+	c.tokens += tokens
+}
+
+func topUsage(m map[string]*usageCounter) []UsageRow {
+	rows := make([]UsageRow, 0, len(m))
+	for name, c := range m {
+		rows = append(rows, UsageRow{Name: name, Requests: c.requests, Tokens: c.tokens})
+	}
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Tokens != rows[j].Tokens {
+			return rows[i].Tokens > rows[j].Tokens
+		}
+		return rows[i].Name < rows[j].Name
+	})
+	if len(rows) > topN {
+		rows = rows[:topN]
+	}
+	return rows
+}
+
+// Build 生成当前周期的报表，不清零计数
+func (r *Reporter) Build(ctx context.Context, period string) Summary {
+	r.mu.Lock()
+	sum := Summary{
+		Period:        period,
+		From:          r.since,
+		To:            r.now(),
+		Requests:      r.requests,
+		InputTokens:   r.inputTokens,
+		OutputTokens:  r.outputTokens,
+		Cost:          r.cost,
+		Currency:      r.currency,
+		TopModels:     topUsage(r.models),
+		TopKeys:       topUsage(r.keys),
+		StatusChanges: append([]events.AccountStatus{}, r.statusChanges...),
+	}
+	r.mu.Unlock()
+
+	byID := map[int64]*store.Account{}
+	if r.store != nil {
+		accounts, err := r.store.ListAccounts(ctx)
+		if err != nil {
+			slog.Warn("Report: list accounts failed", "error", err)
+		}
+		ratio := r.cfg.ReportQuotaWarnRatio
+		if ratio <= 0 {
+			ratio = defaultQuotaWarnRatio
+		}
+		for _, acc := range accounts {
+			byID[acc.ID] = acc
+			if !acc.Enabled || acc.StatusCode != "" {
+				sum.DisabledAccounts = append(sum.DisabledAccounts, AccountRow{
+					AccountID: acc.ID, Name: acc.Name, AccountType: acc.AccountType, Enabled: acc.Enabled, StatusCode: acc.StatusCode,
+				})
+			}
+			if acc.Enabled && acc.UsageLimit > 0 && acc.UsageCurrent/acc.UsageLimit >= ratio {
+				sum.QuotaWarnings = append(sum.QuotaWarnings, QuotaRow{
+					AccountID: acc.ID, Name: acc.Name, Used: acc.UsageCurrent, Limit: acc.UsageLimit, Ratio: acc.UsageCurrent / acc.UsageLimit,
+				})
+			}
+		}
+		sort.Slice(sum.QuotaWarnings, func(i, j int) bool { return sum.QuotaWarnings[i].Ratio > sum.QuotaWarnings[j].Ratio })
+	}
+
+	if r.stats != nil {
+		stats := r.stats.Snapshot()
+		sum.ErrorWindowHours = r.stats.WindowHours()
+		sum.Anomalies = accountstats.Detect(stats, accountstats.Thresholds{
+			ErrorRate:   r.cfg.AnomalyErrorRate,
+			MinRequests: r.cfg.AnomalyMinRequests,
+			SpikeFactor: r.cfg.AnomalySpikeFactor,
+		})
+		sort.SliceStable(stats, func(i, j int) bool { return stats[i].Errors > stats[j].Errors })
+		for _, st := range stats {
+			if st.Errors == 0 || len(sum.AccountErrors) >= topN {
+				break
+			}
+			row := AccountErrorRow{AccountID: st.AccountID, Requests: st.Requests, Errors: st.Errors, ErrorRate: st.ErrorRate, LastError: st.LastError}
+			if acc, ok := byID[st.AccountID]; ok {
+				row.Name = acc.Name
+			}
+			sum.AccountErrors = append(sum.AccountErrors, row)
+		}
+	}
+	return sum
+}
+
+// Send 生成并发送报表；发送成功后计数清零，开始新的周期
+func (r *Reporter) Send(ctx context.Context, period string) (Summary, error) {
+	sum := r.Build(ctx, period)
+	subject, body := Render(sum)
+	if err := r.send(r.cfg, subject, body); err != nil {
+		return sum, err
+	}
+	r.mu.Lock()
+	r.reset(sum.To)
+	r.mu.Unlock()
+	slog.Info("Report sent", "period", period, "recipients", len(r.cfg.ReportRecipients), "requests", sum.Requests)
+	return sum, nil
+}
+
+// Render 将报表渲染为邮件标题与纯文本正文
+func Render(s Summary) (string, string) {
+	title := "Daily"
+	if s.Period == Weekly {
+		title = "Weekly"
+	}
+	subject := fmt.Sprintf("[Orchids-2api] %s report %s", title, s.To.Format("2006-01-02"))
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "Period: %s - %s\n\n", s.From.Format(time.RFC3339), s.To.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Requests: %d\nInput tokens: %d\nOutput tokens: %d\n", s.Requests, s.InputTokens, s.OutputTokens)
+	if s.Currency != "" {
+		fmt.Fprintf(&b, "Estimated cost: %.2f %s\n", s.Cost, s.Currency)
+	}
+
+	writeUsage := func(heading string, rows []UsageRow) {
+		if len(rows) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n%s\n", heading)
+		for _, row := range rows {
+			fmt.Fprintf(&b, "  %-40s %8d requests %12d tokens\n", row.Name, row.Requests, row.Tokens)
+		}
+	}
+	writeUsage("Top models", s.TopModels)
+	writeUsage("Top API keys", s.TopKeys)
+
+	if len(s.AccountErrors) > 0 {
+		fmt.Fprintf(&b, "\nAccount errors (last %dh)\n", s.ErrorWindowHours)
+		for _, row := range s.AccountErrors {
+			fmt.Fprintf(&b, "  #%d %-30s %d/%d errors (%.1f%%) last=%s\n", row.AccountID, row.Name, row.Errors, row.Requests, row.ErrorRate*100, row.LastError)
+		}
+	}
+	if len(s.Anomalies) > 0 {
+		fmt.Fprintf(&b, "\nError spikes / anomalies\n")
+		for _, a := range s.Anomalies {
+			fmt.Fprintf(&b, "  #%d %s value=%.2f threshold=%.2f\n", a.AccountID, a.Kind, a.Value, a.Threshold)
+		}
+	}
+	if len(s.StatusChanges) > 0 {
+		fmt.Fprintf(&b, "\nAccount status changes\n")
+		for _, c := range s.StatusChanges {
+			status := c.Status
+			if status == "" {
+				status = "recovered"
+			}
+			fmt.Fprintf(&b, "  #%d %s: %s -> %s (%s)\n", c.AccountID, c.AccountName, c.Previous, status, c.Reason)
+		}
+	}
+	if len(s.DisabledAccounts) > 0 {
+		fmt.Fprintf(&b, "\nDisabled / flagged accounts\n")
+		for _, a := range s.DisabledAccounts {
+			state := "disabled"
+			if a.Enabled {
+				state = "status " + a.StatusCode
+			}
+			fmt.Fprintf(&b, "  #%d %s [%s] %s\n", a.AccountID, a.Name, a.AccountType, state)
+		}
+	}
+	if len(s.QuotaWarnings) > 0 {
+		fmt.Fprintf(&b, "\nQuota nearing limit\n")
+		for _, q := range s.QuotaWarnings {
+			fmt.Fprintf(&b, "  #%d %s %.0f/%.0f (%.0f%%)\n", q.AccountID, q.Name, q.Used, q.Limit, q.Ratio*100)
+		}
+	}
+	return subject, b.String()
+}
+
+// nextRun 返回 now 之后的下一次发送时间：每天 hour 点，weekly 为每周一
+func nextRun(now time.Time, schedule string, hour int) time.Time {
+	next := time.Date(now.Year(), now.Month(), now.Day(), hour, 0, 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	if schedule == Weekly {
+		for next.Weekday() != time.Monday {
+			next = next.AddDate(0, 0, 1)
+		}
+	}
+	return next
+}
+
+// Run 按 report_schedule 定时发送报表，直到 ctx 结束。多副本部署时每个周期只由抢到锁的副本发送，
+// 注意各副本只统计自身处理的请求
+func (r *Reporter) Run(ctx context.Context) {
+	for {
+		schedule := strings.ToLower(r.cfg.ReportSchedule)
+		if schedule != Daily && schedule != Weekly {
+			return
+		}
+		next := nextRun(r.now(), schedule, r.cfg.ReportHour)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Until(next)):
+		}
+		if !r.Enabled() {
+			continue
+		}
+		if r.store != nil {
+			locked, err := r.store.TryLock(ctx, "report:"+schedule+":"+next.Format("2006-01-02"), time.Hour)
+			if err != nil {
+				slog.Warn("Report: acquire lock failed, sending anyway", "error", err)
+			} else if !locked {
+				// 本副本的计数不会被发送，同样开始新的周期
+				slog.Debug("Report: sent by another replica", "period", schedule)
+				r.mu.Lock()
+				r.reset(r.now())
+				r.mu.Unlock()
+				continue
+			}
+		}
+		if _, err := r.Send(ctx, schedule); err != nil {
+			slog.Warn("Report send failed", "period", schedule, "error", err)
+		}
+	}
+}
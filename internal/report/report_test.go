@@ -0,0 +1,106 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"orchids-api/internal/accountstats"
+	"orchids-api/internal/config"
+	"orchids-api/internal/events"
+)
+
+func usageEvent(t *testing.T, u events.Usage) events.Event {
+	t.Helper()
+	data, err := json.Marshal(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return events.Event{Type: events.RequestCompleted, Data: data}
+}
+
+func TestReporter_SummarizesAndResetsAfterSend(t *testing.T) {
+	stats := accountstats.New(24)
+	stats.RecordSuccess(1, 100)
+	stats.RecordError(2, "429")
+	stats.RecordError(2, "429")
+
+	cfg := &config.Config{ReportRecipients: []string{"ops@example.com"}}
+	r := New(nil, stats, cfg)
+	var sent []string
+	fail := true
+	r.send = func(_ *config.Config, subject, body string) error {
+		if fail {
+			return errors.New("smtp down")
+		}
+		sent = append(sent, subject+"\n"+body)
+		return nil
+	}
+
+	r.Publish(usageEvent(t, events.Usage{Model: "claude-sonnet-4-5", APIKeyID: "aaa", InputTokens: 100, OutputTokens: 20, Cost: 0.5, Currency: "USD"}))
+	r.Publish(usageEvent(t, events.Usage{Model: "claude-opus-4-5", APIKeyID: "bbb", InputTokens: 1000, OutputTokens: 200}))
+	r.Publish(usageEvent(t, events.Usage{Model: "claude-sonnet-4-5", APIKeyID: "aaa", InputTokens: 10}))
+	status, _ := json.Marshal(events.AccountStatus{AccountID: 2, AccountName: "acc-2", Status: "429", Reason: "上游请求失败"})
+	r.Publish(events.Event{Type: events.AccountStatusChanged, Data: status})
+
+	sum := r.Build(context.Background(), Daily)
+	if sum.Requests != 3 || sum.InputTokens != 1110 || sum.OutputTokens != 220 || sum.Currency != "USD" {
+		t.Fatalf("totals = %+v", sum)
+	}
+	if len(sum.TopModels) != 2 || sum.TopModels[0].Name != "claude-opus-4-5" || sum.TopModels[1].Requests != 2 {
+		t.Fatalf("top models = %+v", sum.TopModels)
+	}
+	if len(sum.AccountErrors) != 1 || sum.AccountErrors[0].AccountID != 2 || sum.AccountErrors[0].Errors != 2 {
+		t.Fatalf("account errors = %+v", sum.AccountErrors)
+	}
+	if len(sum.StatusChanges) != 1 {
+		t.Fatalf("status changes = %+v", sum.StatusChanges)
+	}
+
+	if _, err := r.Send(context.Background(), Daily); err == nil {
+		t.Fatal("send should fail")
+	}
+	if r.Build(context.Background(), Daily).Requests != 3 {
+		t.Fatal("counters must be kept when sending fails")
+	}
+	fail = false
+	if _, err := r.Send(context.Background(), Daily); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 || !strings.Contains(sent[0], "Daily report") || !strings.Contains(sent[0], "claude-opus-4-5") || !strings.Contains(sent[0], "acc-2") {
+		t.Fatalf("sent = %q", sent)
+	}
+	if after := r.Build(context.Background(), Daily); after.Requests != 0 || len(after.StatusChanges) != 0 {
+		t.Fatalf("counters not reset: %+v", after)
+	}
+}
+
+func TestNextRun(t *testing.T) {
+	loc := time.UTC
+	// 2026-10-14 为周三
+	now := time.Date(2026, 10, 14, 10, 30, 0, 0, loc)
+	cases := []struct {
+		schedule string
+		hour     int
+		want     time.Time
+	}{
+		{Daily, 11, time.Date(2026, 10, 14, 11, 0, 0, 0, loc)},
+		{Daily, 9, time.Date(2026, 10, 15, 9, 0, 0, 0, loc)},
+		{Weekly, 9, time.Date(2026, 10, 19, 9, 0, 0, 0, loc)},
+	}
+	for _, c := range cases {
+		if got := nextRun(now, c.schedule, c.hour); !got.Equal(c.want) {
+			t.Errorf("nextRun(%s, %d) = %v, want %v", c.schedule, c.hour, got, c.want)
+		}
+	}
+}
+
+func TestBuildMessage_EncodesSubject(t *testing.T) {
+	msg := string(buildMessage("bot@example.com", []string{"a@example.com", "b@example.com"}, "报表", "line1\nline2"))
+	if !strings.Contains(msg, "To: a@example.com, b@example.com\r\n") || !strings.Contains(msg, "Subject: =?utf-8?q?") || !strings.HasSuffix(msg, "line1\r\nline2") {
+		t.Fatalf("message = %q", msg)
+	}
+}
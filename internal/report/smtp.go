@@ -0,0 +1,95 @@
+package report
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"net"
+	"net/smtp"
+	"strings"
+	"time"
+
+	"orchids-api/internal/config"
+)
+
+const smtpTimeout = 30 * time.Second
+
+// sendMail 通过 report_smtp_addr 发送纯文本邮件。465 端口使用隐式 TLS，
+// 其他端口在服务端支持时升级 STARTTLS；配置了用户名时使用 PLAIN 认证
+func sendMail(cfg *config.Config, subject, body string) error {
+	if cfg.ReportSMTPAddr == "" || len(cfg.ReportRecipients) == 0 {
+		return errors.New("report: smtp address or recipients not configured")
+	}
+	host, port, err := net.SplitHostPort(cfg.ReportSMTPAddr)
+	if err != nil {
+		return fmt.Errorf("report: invalid smtp address: %w", err)
+	}
+	from := cfg.ReportFrom
+	if from == "" {
+		from = cfg.ReportSMTPUser
+	}
+
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	var conn net.Conn
+	if port == "465" {
+		conn, err = tls.DialWithDialer(dialer, "tcp", cfg.ReportSMTPAddr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", cfg.ReportSMTPAddr)
+	}
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(smtpTimeout))
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if port != "465" {
+		if ok, _ := c.Extension("STARTTLS"); ok {
+			if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+				return err
+			}
+		}
+	}
+	if cfg.ReportSMTPUser != "" {
+		if err := c.Auth(smtp.PlainAuth("", cfg.ReportSMTPUser, cfg.ReportSMTPPass, host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from); err != nil {
+		return err
+	}
+	for _, rcpt := range cfg.ReportRecipients {
+		if err := c.Rcpt(strings.TrimSpace(rcpt)); err != nil {
+			return err
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(buildMessage(from, cfg.ReportRecipients, subject, body)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
+
+func buildMessage(from string, to []string, subject, body string) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.WriteString(strings.ReplaceAll(body, "\n", "\r\n"))
+	return []byte(b.String())
+}
@@ -0,0 +1,183 @@
+// Package sseconform 校验（并在需要时修复）对客户端输出的 SSE 流，保证事件序列符合协议约定。
+// 服务端在 debug 模式下逐帧经过校验器，测试中可直接用 Validate* 检查完整响应体。
+package sseconform
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Frame 为一个 SSE 帧，Event 为空表示只有 data 行（OpenAI 格式）
+type Frame struct {
+	Event string
+	Data  []byte
+}
+
+// ParseFrames 将响应体切分为 SSE 帧，注释帧（如 ": ping"）被忽略
+func ParseFrames(body string) ([]Frame, error) {
+	var frames []Frame
+	for i, raw := range strings.Split(strings.TrimSpace(body), "\n\n") {
+		if raw == "" || strings.HasPrefix(raw, ":") {
+			continue
+		}
+		var frame Frame
+		hasData := false
+		for _, line := range strings.Split(raw, "\n") {
+			switch {
+			case strings.HasPrefix(line, "event: "):
+				frame.Event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: ") && !hasData:
+				frame.Data = []byte(strings.TrimPrefix(line, "data: "))
+				hasData = true
+			default:
+				return nil, fmt.Errorf("frame %d malformed: %q", i, raw)
+			}
+		}
+		if !hasData {
+			return nil, fmt.Errorf("frame %d missing data: %q", i, raw)
+		}
+		frames = append(frames, frame)
+	}
+	return frames, nil
+}
+
+// syntheticMessageStart 用于修复缺少 message_start 的流
+const syntheticMessageStart = `{"type":"message_start","message":{"id":"","type":"message","role":"assistant","content":[],"model":"","usage":{"input_tokens":0,"output_tokens":0}}}`
+
+// AnthropicValidator 逐帧校验 Anthropic Messages 流：message_start 与 message_stop 各恰好一次，
+// content_block 的 start/delta/stop 成对出现、同一时刻最多一个打开的块，且块索引从 0 起严格递增。
+// 零值不可用，使用 NewAnthropicValidator 创建；非并发安全
+type AnthropicValidator struct {
+	started bool
+	stopped bool
+	// open 为当前打开的块在上游事件中的索引，emitted 为实际输出的索引（修复后可能不同）
+	open      int
+	emitted   int
+	lastIndex int
+}
+
+func NewAnthropicValidator() *AnthropicValidator {
+	return &AnthropicValidator{open: -1, emitted: -1, lastIndex: -1}
+}
+
+// Repair 校验下一帧并返回应当写出的帧序列：合规时原样返回该帧；
+// 可修复的违规（缺少 content_block_stop / message_start、块索引不递增）会补发或改写帧，
+// 无法修复的违规（非法 JSON、没有打开块的 delta/stop、message_stop 之后的帧、重复的 message_start）返回空序列即丢弃。
+// 存在违规时 error 描述第一处问题
+func (v *AnthropicValidator) Repair(event string, data []byte) ([]Frame, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return nil, fmt.Errorf("%s: invalid json: %v", event, err)
+	}
+	if payload["type"] != event {
+		return nil, fmt.Errorf("%s: type %v does not match event", event, payload["type"])
+	}
+	if v.stopped {
+		return nil, fmt.Errorf("%s after message_stop", event)
+	}
+	frame := Frame{Event: event, Data: data}
+	switch event {
+	case "ping", "error":
+		return []Frame{frame}, nil
+	case "message_start":
+		if v.started {
+			return nil, fmt.Errorf("duplicate message_start")
+		}
+		v.started = true
+		return []Frame{frame}, nil
+	}
+
+	var out []Frame
+	var violation error
+	if !v.started {
+		v.started = true
+		out = append(out, Frame{Event: "message_start", Data: []byte(syntheticMessageStart)})
+		violation = fmt.Errorf("%s before message_start", event)
+	}
+
+	index := -1
+	if n, ok := payload["index"].(float64); ok {
+		index = int(n)
+	}
+	switch event {
+	case "content_block_start":
+		if v.open >= 0 {
+			out = append(out, v.stopFrame())
+			violation = firstErr(violation, fmt.Errorf("content_block_start %d while block %d is open", index, v.open))
+		}
+		v.open, v.emitted = index, index
+		if index <= v.lastIndex {
+			v.emitted = v.lastIndex + 1
+			violation = firstErr(violation, fmt.Errorf("content_block_start index %d not increasing (last %d)", index, v.lastIndex))
+			frame.Data = reindex(payload, v.emitted)
+		}
+		v.lastIndex = v.emitted
+		out = append(out, frame)
+	case "content_block_delta", "content_block_stop":
+		if v.open < 0 || index != v.open {
+			return nil, firstErr(violation, fmt.Errorf("%s for block %d, open block %d", event, index, v.open))
+		}
+		if v.emitted != index {
+			frame.Data = reindex(payload, v.emitted)
+		}
+		if event == "content_block_stop" {
+			v.open, v.emitted = -1, -1
+		}
+		out = append(out, frame)
+	case "message_delta", "message_stop":
+		if v.open >= 0 {
+			out = append(out, v.stopFrame())
+			violation = firstErr(violation, fmt.Errorf("%s with block %d still open", event, v.open))
+		}
+		if event == "message_stop" {
+			v.stopped = true
+		}
+		out = append(out, frame)
+	default:
+		out = append(out, frame)
+	}
+	return out, violation
+}
+
+// Finish 在流结束时调用，报告是否缺少 message_stop
+func (v *AnthropicValidator) Finish() error {
+	if !v.stopped {
+		return fmt.Errorf("stream missing message_stop")
+	}
+	return nil
+}
+
+func (v *AnthropicValidator) stopFrame() Frame {
+	data := fmt.Sprintf(`{"type":"content_block_stop","index":%d}`, v.emitted)
+	v.open, v.emitted = -1, -1
+	return Frame{Event: "content_block_stop", Data: []byte(data)}
+}
+
+// ValidateAnthropic 校验完整的 Anthropic 流式响应体，返回第一处违规
+func ValidateAnthropic(body string) error {
+	frames, err := ParseFrames(body)
+	if err != nil {
+		return err
+	}
+	v := NewAnthropicValidator()
+	for i, frame := range frames {
+		if _, err := v.Repair(frame.Event, frame.Data); err != nil {
+			return fmt.Errorf("frame %d: %w", i, err)
+		}
+	}
+	return v.Finish()
+}
+
+func reindex(payload map[string]interface{}, index int) []byte {
+	payload["index"] = index
+	data, _ := json.Marshal(payload)
+	return data
+}
+
+func firstErr(existing, err error) error {
+	if existing != nil {
+		return existing
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package sseconform
+
+import (
+	"strings"
+	"testing"
+)
+
+const testMessageStart = `{"type":"message_start","message":{"id":"msg_1"}}`
+
+func anthropicBody(frames ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(frames); i += 2 {
+		b.WriteString("event: " + frames[i] + "\ndata: " + frames[i+1] + "\n\n")
+	}
+	return b.String()
+}
+
+func TestValidateAnthropic_WellFormed(t *testing.T) {
+	body := anthropicBody(
+		"message_start", testMessageStart,
+		"content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		"content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"hi"}}`,
+		"content_block_stop", `{"type":"content_block_stop","index":0}`,
+		"ping", `{"type":"ping"}`,
+		"content_block_start", `{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"t1","name":"Read"}}`,
+		"content_block_stop", `{"type":"content_block_stop","index":1}`,
+		"message_delta", `{"type":"message_delta","delta":{"stop_reason":"tool_use"}}`,
+		"message_stop", `{"type":"message_stop"}`,
+	) + ": ping\n\n"
+	if err := ValidateAnthropic(body); err != nil {
+		t.Fatalf("ValidateAnthropic: %v", err)
+	}
+}
+
+func TestValidateAnthropic_Violations(t *testing.T) {
+	start0 := `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`
+	stop := `{"type":"message_stop"}`
+	cases := map[string]string{
+		"missing message_stop": anthropicBody("message_start", testMessageStart),
+		"duplicate message_start": anthropicBody("message_start", testMessageStart, "message_start", testMessageStart,
+			"message_stop", stop),
+		"missing message_start": anthropicBody("message_stop", stop),
+		"unclosed block": anthropicBody("message_start", testMessageStart, "content_block_start", start0,
+			"message_stop", stop),
+		"index not increasing": anthropicBody("message_start", testMessageStart, "content_block_start", start0,
+			"content_block_stop", `{"type":"content_block_stop","index":0}`, "content_block_start", start0,
+			"content_block_stop", `{"type":"content_block_stop","index":0}`, "message_stop", stop),
+		"delta without block": anthropicBody("message_start", testMessageStart,
+			"content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"x"}}`,
+			"message_stop", stop),
+		"frame after stop": anthropicBody("message_start", testMessageStart, "message_stop", stop, "ping", `{"type":"ping"}`),
+		"type mismatch":    anthropicBody("message_start", testMessageStart, "message_stop", `{"type":"message_delta"}`),
+		"invalid json":     anthropicBody("message_start", "{", "message_stop", stop),
+	}
+	for name, body := range cases {
+		if err := ValidateAnthropic(body); err == nil {
+			t.Errorf("%s: expected violation", name)
+		}
+	}
+}
+
+func TestAnthropicValidator_Repair(t *testing.T) {
+	v := NewAnthropicValidator()
+	var out []string
+	write := func(event, data string) {
+		frames, _ := v.Repair(event, []byte(data))
+		for _, f := range frames {
+			out = append(out, f.Event, string(f.Data))
+		}
+	}
+	write("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`)
+	write("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"a"}}`)
+	// 未关闭上一个块且索引重复：补发 stop 并改写为下一个索引
+	write("content_block_start", `{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`)
+	write("content_block_delta", `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"b"}}`)
+	// 没有对应打开块的 delta 被丢弃
+	write("content_block_delta", `{"type":"content_block_delta","index":7,"delta":{"type":"text_delta","text":"c"}}`)
+	write("message_stop", `{"type":"message_stop"}`)
+	write("message_stop", `{"type":"message_stop"}`)
+
+	body := anthropicBody(out...)
+	if err := ValidateAnthropic(body); err != nil {
+		t.Fatalf("repaired stream invalid: %v\n%s", err, body)
+	}
+	for _, want := range []string{`"text":"a"`, `"index":1,"type":"content_block_delta"`, `"text":"b"`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("repaired stream missing %s:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `"text":"c"`) || strings.Count(body, "event: message_stop") != 1 {
+		t.Fatalf("invalid frames not dropped:\n%s", body)
+	}
+}
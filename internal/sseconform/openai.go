@@ -0,0 +1,204 @@
+package sseconform
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// OpenAIDone 为 OpenAI 流的结束标记
+const OpenAIDone = "[DONE]"
+
+// openAIFinishReasons 将 Anthropic stop_reason 映射为 OpenAI finish_reason，OpenAI 自身的取值原样保留
+var openAIFinishReasons = map[string]string{
+	"stop":           "stop",
+	"length":         "length",
+	"tool_calls":     "tool_calls",
+	"content_filter": "content_filter",
+	"function_call":  "function_call",
+	"end_turn":       "stop",
+	"stop_sequence":  "stop",
+	"max_tokens":     "length",
+	"tool_use":       "tool_calls",
+}
+
+// OpenAIValidator 逐帧校验 chat.completion.chunk 流：首个 chunk 的 delta 只含 role，
+// 所有 chunk 的 id/created/model 一致，finish_reason 只出现一次且之后不再有内容，最后以 [DONE] 结束。
+// 零值不可用，使用 NewOpenAIValidator 创建；非并发安全
+type OpenAIValidator struct {
+	id       string
+	created  interface{}
+	model    string
+	roleSent bool
+	finished bool
+	done     bool
+}
+
+func NewOpenAIValidator() *OpenAIValidator {
+	return &OpenAIValidator{}
+}
+
+// Repair 校验下一帧的 data（chunk JSON 或 [DONE]）并返回应当写出的 data 序列：
+// 缺少的 role chunk 与 finish_reason chunk 会补发，不一致的 id/created/model、非 OpenAI 取值的 finish_reason
+// 以及 role 之后重复出现的 role 字段会被改写；finish_reason 之后的 chunk、[DONE] 之后的帧与非法 JSON 被丢弃。
+// 存在违规时 error 描述第一处问题
+func (v *OpenAIValidator) Repair(data []byte) ([][]byte, error) {
+	if v.done {
+		return nil, fmt.Errorf("frame after [DONE]")
+	}
+	if string(data) == OpenAIDone {
+		v.done = true
+		if v.finished {
+			return [][]byte{data}, nil
+		}
+		var out [][]byte
+		if !v.roleSent {
+			v.roleSent = true
+			out = append(out, v.chunk(map[string]interface{}{"role": "assistant"}, nil))
+		}
+		v.finished = true
+		out = append(out, v.chunk(map[string]interface{}{}, "stop"), data)
+		return out, fmt.Errorf("[DONE] without finish_reason")
+	}
+
+	var chunk map[string]interface{}
+	if err := json.Unmarshal(data, &chunk); err != nil {
+		return nil, fmt.Errorf("invalid json: %v", err)
+	}
+	var violation error
+	changed := false
+	fix := func(field string, want interface{}) {
+		if chunk[field] != want {
+			violation = firstErr(violation, fmt.Errorf("%s %v, want %v", field, chunk[field], want))
+			chunk[field] = want
+			changed = true
+		}
+	}
+	fix("object", "chat.completion.chunk")
+	if id, _ := chunk["id"].(string); v.id == "" && id != "" {
+		v.id, v.created = id, chunk["created"]
+	}
+	fix("id", v.id)
+	fix("created", v.created)
+	if model, _ := chunk["model"].(string); v.model == "" && model != "" {
+		v.model = model
+	} else if model != v.model {
+		// 首个 chunk 之外的空 model 属于常见写法，只改写不报告
+		if model != "" {
+			violation = firstErr(violation, fmt.Errorf("model %q, want %q", model, v.model))
+		}
+		chunk["model"] = v.model
+		changed = true
+	}
+
+	choices, _ := chunk["choices"].([]interface{})
+	if len(choices) != 1 {
+		// 不含 choice 的 chunk（如 usage）只需字段一致
+		if v.finished && len(choices) > 0 {
+			return nil, firstErr(violation, fmt.Errorf("chunk after finish_reason"))
+		}
+		return [][]byte{v.encode(chunk, data, changed)}, violation
+	}
+	choice, _ := choices[0].(map[string]interface{})
+	if choice == nil {
+		return nil, firstErr(violation, fmt.Errorf("choice is not an object"))
+	}
+	if v.finished {
+		return nil, firstErr(violation, fmt.Errorf("chunk after finish_reason"))
+	}
+	delta, _ := choice["delta"].(map[string]interface{})
+	if delta == nil {
+		delta = map[string]interface{}{}
+		choice["delta"] = delta
+		changed = true
+	}
+
+	var out [][]byte
+	if _, hasRole := delta["role"]; !v.roleSent {
+		v.roleSent = true
+		if !hasRole || len(delta) != 1 || choice["finish_reason"] != nil {
+			violation = firstErr(violation, fmt.Errorf("first chunk is not role-only"))
+			out = append(out, v.chunk(map[string]interface{}{"role": "assistant"}, nil))
+			if hasRole {
+				delete(delta, "role")
+				changed = true
+			}
+		} else if delta["role"] != "assistant" {
+			violation = firstErr(violation, fmt.Errorf("role %v, want assistant", delta["role"]))
+			delta["role"] = "assistant"
+			changed = true
+		}
+	} else if hasRole {
+		violation = firstErr(violation, fmt.Errorf("role repeated after first chunk"))
+		delete(delta, "role")
+		changed = true
+	}
+
+	if reason, ok := choice["finish_reason"].(string); ok {
+		v.finished = true
+		mapped, known := openAIFinishReasons[reason]
+		if !known {
+			mapped = "stop"
+		}
+		if mapped != reason {
+			violation = firstErr(violation, fmt.Errorf("finish_reason %q, want %q", reason, mapped))
+			choice["finish_reason"] = mapped
+			changed = true
+		}
+	} else if len(delta) == 0 && len(out) > 0 {
+		// 去掉 role 后没有剩余内容，补发的 role chunk 已足够
+		return out, violation
+	}
+	return append(out, v.encode(chunk, data, changed)), violation
+}
+
+// Finish 在流结束时调用，报告是否缺少 [DONE]
+func (v *OpenAIValidator) Finish() error {
+	if !v.done {
+		return fmt.Errorf("stream missing [DONE]")
+	}
+	return nil
+}
+
+// chunk 生成一个补发用的 chunk，finishReason 为 nil 时输出 null
+func (v *OpenAIValidator) chunk(delta map[string]interface{}, finishReason interface{}) []byte {
+	data, _ := json.Marshal(map[string]interface{}{
+		"id":      v.id,
+		"object":  "chat.completion.chunk",
+		"created": v.created,
+		"model":   v.model,
+		"choices": []interface{}{map[string]interface{}{"index": 0, "delta": delta, "finish_reason": finishReason}},
+	})
+	return data
+}
+
+func (v *OpenAIValidator) encode(chunk map[string]interface{}, original []byte, changed bool) []byte {
+	if !changed {
+		return original
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(chunk); err != nil {
+		return original
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+}
+
+// ValidateOpenAI 校验完整的 OpenAI 流式响应体，返回第一处违规
+func ValidateOpenAI(body string) error {
+	frames, err := ParseFrames(body)
+	if err != nil {
+		return err
+	}
+	v := NewOpenAIValidator()
+	for i, frame := range frames {
+		if frame.Event != "" {
+			return fmt.Errorf("frame %d: unexpected event %q", i, frame.Event)
+		}
+		if _, err := v.Repair(frame.Data); err != nil {
+			return fmt.Errorf("frame %d: %w", i, err)
+		}
+	}
+	return v.Finish()
+}
@@ -0,0 +1,81 @@
+package sseconform
+
+import (
+	"strings"
+	"testing"
+)
+
+func openAIBody(chunks ...string) string {
+	var b strings.Builder
+	for _, c := range chunks {
+		b.WriteString("data: " + c + "\n\n")
+	}
+	return b.String()
+}
+
+const (
+	roleChunk    = `{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"role":"assistant"},"finish_reason":null}]}`
+	textChunk    = `{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"content":"hi"},"finish_reason":null}]}`
+	finishChunk  = `{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`
+	otherIDChunk = `{"id":"c2","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"content":"x"}}]}`
+)
+
+func TestValidateOpenAI_WellFormed(t *testing.T) {
+	body := openAIBody(roleChunk, textChunk, finishChunk, OpenAIDone) + ": ping\n\n"
+	if err := ValidateOpenAI(body); err != nil {
+		t.Fatalf("ValidateOpenAI: %v", err)
+	}
+}
+
+func TestValidateOpenAI_Violations(t *testing.T) {
+	cases := map[string]string{
+		"missing [DONE]":       openAIBody(roleChunk, finishChunk),
+		"first not role-only":  openAIBody(textChunk, finishChunk, OpenAIDone),
+		"inconsistent id":      openAIBody(roleChunk, otherIDChunk, finishChunk, OpenAIDone),
+		"missing finish":       openAIBody(roleChunk, textChunk, OpenAIDone),
+		"chunk after finish":   openAIBody(roleChunk, finishChunk, textChunk, OpenAIDone),
+		"anthropic stop":       openAIBody(roleChunk, strings.Replace(finishChunk, `"stop"`, `"end_turn"`, 1), OpenAIDone),
+		"frame after [DONE]":   openAIBody(roleChunk, finishChunk, OpenAIDone, textChunk),
+		"invalid json":         openAIBody(roleChunk, "{", finishChunk, OpenAIDone),
+		"anthropic event line": "event: message_stop\ndata: {}\n\n",
+	}
+	for name, body := range cases {
+		if err := ValidateOpenAI(body); err == nil {
+			t.Errorf("%s: expected violation", name)
+		}
+	}
+}
+
+func TestOpenAIValidator_Repair(t *testing.T) {
+	v := NewOpenAIValidator()
+	var out []string
+	for _, c := range []string{
+		`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"m","choices":[{"index":0,"delta":{"role":"assistant","content":"a"}}]}`,
+		otherIDChunk,
+		`{"id":"c1","object":"chat.completion.chunk","created":1,"model":"","choices":[{"index":0,"delta":{},"finish_reason":"tool_use"}]}`,
+		finishChunk,
+		OpenAIDone,
+		OpenAIDone,
+	} {
+		frames, _ := v.Repair([]byte(c))
+		for _, f := range frames {
+			out = append(out, string(f))
+		}
+	}
+
+	body := openAIBody(out...)
+	if err := ValidateOpenAI(body); err != nil {
+		t.Fatalf("repaired stream invalid: %v\n%s", err, body)
+	}
+	if len(out) != 5 {
+		t.Fatalf("repaired frames = %d, want 5:\n%s", len(out), body)
+	}
+	for _, want := range []string{`"content":"a"`, `"content":"x"`, `"finish_reason":"tool_calls"`} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("repaired stream missing %s:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `"c2"`) || strings.Contains(body, `"model":""`) {
+		t.Fatalf("ids/model not made consistent:\n%s", body)
+	}
+}
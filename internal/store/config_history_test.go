@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"orchids-api/internal/testing/fakeredis"
+)
+
+func TestAppendConfigVersion_TrimsToKeep(t *testing.T) {
+	srv := fakeredis.Start(t)
+	s, err := New(Options{RedisAddr: srv.Addr(), SkipSeed: true})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	defer s.Close()
+	ctx := context.Background()
+
+	for i := 1; i <= 5; i++ {
+		if err := s.AppendConfigVersion(ctx, &ConfigVersion{Author: "admin", Config: `{"port":"` + strconv.Itoa(i) + `"}`}, 3); err != nil {
+			t.Fatalf("AppendConfigVersion %d: %v", i, err)
+		}
+	}
+	versions, err := s.ListConfigVersions(ctx)
+	if err != nil {
+		t.Fatalf("ListConfigVersions: %v", err)
+	}
+	if len(versions) != 3 {
+		t.Fatalf("len(versions) = %d, want 3", len(versions))
+	}
+	for i, want := range []int64{5, 4, 3} {
+		if versions[i].Version != want {
+			t.Fatalf("versions[%d].Version = %d, want %d", i, versions[i].Version, want)
+		}
+	}
+	if v, err := s.GetConfigVersion(ctx, 2); err != nil || v != nil {
+		t.Fatalf("GetConfigVersion(2) = %+v, %v; want trimmed", v, err)
+	}
+	if v, _ := s.GetConfigVersion(ctx, 4); v == nil || v.Config != `{"port":"4"}` {
+		t.Fatalf("GetConfigVersion(4) = %+v", v)
+	}
+
+	// keep <= 0 表示不裁剪
+	if err := s.AppendConfigVersion(ctx, &ConfigVersion{Author: "admin"}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if versions, _ := s.ListConfigVersions(ctx); len(versions) != 4 {
+		t.Fatalf("len(versions) = %d after keep=0, want 4", len(versions))
+	}
+}
@@ -0,0 +1,227 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+// MigrationSettingKeys 为迁移的设置项：运行时配置与功能开关（与 flags.SettingKey 一致）。
+// 配置历史与运行期状态（锁、会话、缓存）不迁移
+var MigrationSettingKeys = []string{"config", "flags"}
+
+// 迁移条目的类型
+const (
+	MigrateAccount = "account"
+	MigrateApiKey  = "api_key"
+	MigrateModel   = "model"
+	MigrateSetting = "setting"
+)
+
+// 迁移动作
+const (
+	ActionCreate    = "create"
+	ActionOverwrite = "overwrite"
+	ActionSkip      = "skip"
+	ActionConflict  = "conflict"
+)
+
+// Snapshot 为一个存储中可迁移的全部数据
+type Snapshot struct {
+	Accounts []*Account        `json:"accounts"`
+	ApiKeys  []*ApiKey         `json:"api_keys"`
+	Models   []*Model          `json:"models"`
+	Settings map[string]string `json:"settings"`
+}
+
+// Export 读取存储中的账号、API Key、模型与 MigrationSettingKeys 中的设置
+func (s *Store) Export(ctx context.Context) (*Snapshot, error) {
+	snap := &Snapshot{Settings: map[string]string{}}
+	var err error
+	if snap.Accounts, err = s.ListAccounts(ctx); err != nil {
+		return nil, fmt.Errorf("list accounts: %w", err)
+	}
+	if snap.ApiKeys, err = s.ListApiKeys(ctx); err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+	if snap.Models, err = s.ListModels(ctx); err != nil {
+		return nil, fmt.Errorf("list models: %w", err)
+	}
+	for _, key := range MigrationSettingKeys {
+		value, err := s.GetSetting(ctx, key)
+		if err != nil {
+			return nil, fmt.Errorf("get setting %s: %w", key, err)
+		}
+		if value != "" {
+			snap.Settings[key] = value
+		}
+	}
+	return snap, nil
+}
+
+// MigrationItem 为一条数据的迁移动作。Reason 说明跳过或冲突的原因
+type MigrationItem struct {
+	Kind   string `json:"kind"`
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Action string `json:"action"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// MigrationPlan 为源存储到目标存储的迁移计划
+type MigrationPlan struct {
+	Items []MigrationItem `json:"items"`
+}
+
+// Count 返回指定动作的条目数
+func (p MigrationPlan) Count(action string) int {
+	n := 0
+	for _, item := range p.Items {
+		if item.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// PlanMigration 对比源与目标快照，生成迁移计划。所有数据保留原 ID：
+// 目标中不存在的创建；已存在且内容相同的跳过；内容不同的为冲突，overwrite 时改为覆盖。
+// API Key 哈希或模型（渠道 + model_id）已被目标中其他 ID 占用时总是冲突，覆盖会产生重复
+func PlanMigration(src, dst *Snapshot, overwrite bool) MigrationPlan {
+	var plan MigrationPlan
+	decide := func(kind, id, name string, exists, same bool) {
+		item := MigrationItem{Kind: kind, ID: id, Name: name, Action: ActionCreate}
+		switch {
+		case !exists:
+		case same:
+			item.Action, item.Reason = ActionSkip, "identical"
+		case overwrite:
+			item.Action, item.Reason = ActionOverwrite, "differs from target"
+		default:
+			item.Action, item.Reason = ActionConflict, "differs from target"
+		}
+		plan.Items = append(plan.Items, item)
+	}
+
+	dstAccounts := make(map[int64]*Account, len(dst.Accounts))
+	for _, acc := range dst.Accounts {
+		dstAccounts[acc.ID] = acc
+	}
+	for _, acc := range src.Accounts {
+		existing, ok := dstAccounts[acc.ID]
+		decide(MigrateAccount, strconv.FormatInt(acc.ID, 10), acc.Name, ok, ok && sameJSON(acc, existing))
+	}
+
+	dstKeys := make(map[int64]*ApiKey, len(dst.ApiKeys))
+	dstKeyHashes := make(map[string]int64, len(dst.ApiKeys))
+	for _, k := range dst.ApiKeys {
+		dstKeys[k.ID] = k
+		if k.KeyHash != "" {
+			dstKeyHashes[k.KeyHash] = k.ID
+		}
+	}
+	for _, k := range src.ApiKeys {
+		id := strconv.FormatInt(k.ID, 10)
+		if other, ok := dstKeyHashes[k.KeyHash]; ok && k.KeyHash != "" && other != k.ID {
+			plan.Items = append(plan.Items, MigrationItem{Kind: MigrateApiKey, ID: id, Name: k.Name, Action: ActionConflict,
+				Reason: fmt.Sprintf("same key already exists as id %d", other)})
+			continue
+		}
+		existing, ok := dstKeys[k.ID]
+		decide(MigrateApiKey, id, k.Name, ok, ok && sameJSON(apiKeyRecordFromKey(k), apiKeyRecordFromKey(existing)))
+	}
+
+	dstModels := make(map[string]*Model, len(dst.Models))
+	dstModelIDs := make(map[string]string, len(dst.Models))
+	for _, m := range dst.Models {
+		dstModels[m.ID] = m
+		dstModelIDs[m.Channel+"/"+m.ModelID] = m.ID
+	}
+	for _, m := range src.Models {
+		if other, ok := dstModelIDs[m.Channel+"/"+m.ModelID]; ok && other != m.ID {
+			plan.Items = append(plan.Items, MigrationItem{Kind: MigrateModel, ID: m.ID, Name: m.ModelID, Action: ActionConflict,
+				Reason: fmt.Sprintf("%s/%s already exists as id %s", m.Channel, m.ModelID, other)})
+			continue
+		}
+		existing, ok := dstModels[m.ID]
+		decide(MigrateModel, m.ID, m.ModelID, ok, ok && sameJSON(m, existing))
+	}
+
+	keys := make([]string, 0, len(src.Settings))
+	for key := range src.Settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		existing, ok := dst.Settings[key]
+		decide(MigrateSetting, key, "", ok && existing != "", existing == src.Settings[key])
+	}
+	return plan
+}
+
+func sameJSON(a, b interface{}) bool {
+	ja, errA := json.Marshal(a)
+	jb, errB := json.Marshal(b)
+	return errA == nil && errB == nil && string(ja) == string(jb)
+}
+
+// restoreStore 按原 ID 写入数据并推进 ID 计数器，供迁移使用
+type restoreStore interface {
+	RestoreAccount(ctx context.Context, acc *Account) error
+	RestoreApiKey(ctx context.Context, key *ApiKey) error
+	RestoreModel(ctx context.Context, m *Model) error
+}
+
+// ApplyMigration 按计划将 src 中动作为 create / overwrite 的条目写入当前存储，遇到错误即停止。
+// 已写入的条目不会回滚，修正后重新执行即可（相同条目会被跳过）
+func (s *Store) ApplyMigration(ctx context.Context, src *Snapshot, plan MigrationPlan) (applied int, err error) {
+	r, ok := s.accounts.(restoreStore)
+	if !ok {
+		return 0, fmt.Errorf("store does not support migration")
+	}
+
+	accounts := make(map[string]*Account, len(src.Accounts))
+	for _, acc := range src.Accounts {
+		accounts[strconv.FormatInt(acc.ID, 10)] = acc
+	}
+	keys := make(map[string]*ApiKey, len(src.ApiKeys))
+	for _, k := range src.ApiKeys {
+		keys[strconv.FormatInt(k.ID, 10)] = k
+	}
+	models := make(map[string]*Model, len(src.Models))
+	for _, m := range src.Models {
+		models[m.ID] = m
+	}
+
+	// 通知目标存储上运行中的副本刷新模型列表缓存
+	modelsTouched := false
+	defer func() {
+		if modelsTouched {
+			s.modelsChanged(ctx)
+		}
+	}()
+
+	for _, item := range plan.Items {
+		if item.Action != ActionCreate && item.Action != ActionOverwrite {
+			continue
+		}
+		switch item.Kind {
+		case MigrateAccount:
+			err = r.RestoreAccount(ctx, accounts[item.ID])
+		case MigrateApiKey:
+			err = r.RestoreApiKey(ctx, keys[item.ID])
+		case MigrateModel:
+			err = r.RestoreModel(ctx, models[item.ID])
+			modelsTouched = true
+		case MigrateSetting:
+			err = s.SetSetting(ctx, item.ID, src.Settings[item.ID])
+		}
+		if err != nil {
+			return applied, fmt.Errorf("%s %s: %w", item.Kind, item.ID, err)
+		}
+		applied++
+	}
+	return applied, nil
+}
@@ -0,0 +1,44 @@
+package store
+
+import "testing"
+
+func TestPlanMigration(t *testing.T) {
+	src := &Snapshot{
+		Accounts: []*Account{{ID: 1, Name: "a"}, {ID: 2, Name: "b"}, {ID: 3, Name: "c"}},
+		ApiKeys:  []*ApiKey{{ID: 1, Name: "k1", KeyHash: "h1"}, {ID: 2, Name: "k2", KeyHash: "h2"}},
+		Models:   []*Model{{ID: "6", Channel: "Orchids", ModelID: "claude-sonnet-4-5"}, {ID: "7", Channel: "Orchids", ModelID: "claude-opus-4-5"}},
+		Settings: map[string]string{"config": `{"port":"3002"}`, "flags": `{}`},
+	}
+	dst := &Snapshot{
+		Accounts: []*Account{{ID: 2, Name: "b"}, {ID: 3, Name: "other"}},
+		ApiKeys:  []*ApiKey{{ID: 5, Name: "k2", KeyHash: "h2"}},
+		Models:   []*Model{{ID: "1", Channel: "Orchids", ModelID: "claude-sonnet-4-5"}},
+		Settings: map[string]string{"config": `{"port":"3002"}`},
+	}
+
+	plan := PlanMigration(src, dst, false)
+	want := map[string]string{
+		"account/1":      ActionCreate,
+		"account/2":      ActionSkip,
+		"account/3":      ActionConflict,
+		"api_key/1":      ActionCreate,
+		"api_key/2":      ActionConflict, // 哈希已被目标中的 ID 5 使用
+		"model/6":        ActionConflict, // 模型已以 ID 1 存在
+		"model/7":        ActionCreate,
+		"setting/config": ActionSkip,
+		"setting/flags":  ActionCreate,
+	}
+	if len(plan.Items) != len(want) {
+		t.Fatalf("len(items)=%d want %d: %+v", len(plan.Items), len(want), plan.Items)
+	}
+	for _, item := range plan.Items {
+		if got := item.Action; got != want[item.Kind+"/"+item.ID] {
+			t.Errorf("%s/%s action=%s want %s", item.Kind, item.ID, got, want[item.Kind+"/"+item.ID])
+		}
+	}
+
+	plan = PlanMigration(src, dst, true)
+	if plan.Count(ActionOverwrite) != 1 || plan.Count(ActionConflict) != 2 {
+		t.Fatalf("overwrite should only resolve content conflicts: %+v", plan.Items)
+	}
+}
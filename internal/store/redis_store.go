@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 	"sort"
 	"strconv"
 	"strings"
@@ -14,6 +15,12 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
+// lockOwner 标识持锁副本，便于在 Redis 中排查
+var lockOwner = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s:%d", host, os.Getpid())
+}()
+
 type redisStore struct {
 	client *redis.Client
 	prefix string
@@ -31,6 +38,26 @@ type apiKeyRecord struct {
 	CreatedAt  time.Time  `json:"created_at"`
 }
 
+// PingRedis 检查给定 Redis 是否可达，用于在应用配置前校验连接参数
+func PingRedis(ctx context.Context, addr, password string, db int) error {
+	addr = strings.TrimSpace(addr)
+	if addr == "" {
+		return fmt.Errorf("redis address is required")
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+	defer client.Close()
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return fmt.Errorf("redis ping failed: %w", err)
+	}
+	return nil
+}
+
 func newRedisStore(addr, password string, db int, prefix string) (*redisStore, error) {
 	addr = strings.TrimSpace(addr)
 	if addr == "" {
@@ -69,6 +96,13 @@ func (s *redisStore) Close() error {
 	return s.client.Close()
 }
 
+func (s *redisStore) Ping(ctx context.Context) error {
+	if s == nil || s.client == nil {
+		return fmt.Errorf("redis client not initialized")
+	}
+	return s.client.Ping(ctx).Err()
+}
+
 func (s *redisStore) CreateAccount(ctx context.Context, acc *Account) error {
 	if s == nil || s.client == nil {
 		return fmt.Errorf("redis store not configured")
@@ -142,6 +176,7 @@ func (s *redisStore) UpdateAccount(ctx context.Context, acc *Account) error {
 	updated.AgentMode = acc.AgentMode
 	updated.Email = acc.Email
 	updated.Weight = acc.Weight
+	updated.MaxConcurrent = acc.MaxConcurrent
 	updated.Enabled = acc.Enabled
 	updated.Token = acc.Token
 	updated.Subscription = acc.Subscription
@@ -172,6 +207,68 @@ func (s *redisStore) UpdateAccount(ctx context.Context, acc *Account) error {
 	return err
 }
 
+// BatchUpdateAccounts 在一个 MULTI/EXEC 事务中对 ids 应用同一份部分更新。
+// 任一账号不存在或事务期间被并发修改时整体不生效。
+func (s *redisStore) BatchUpdateAccounts(ctx context.Context, ids []int64, patch AccountPatch) ([]*Account, error) {
+	if s == nil || s.client == nil {
+		return nil, fmt.Errorf("redis store not configured")
+	}
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	keys := make([]string, 0, len(ids))
+	for _, id := range ids {
+		keys = append(keys, s.accountsKey(id))
+	}
+
+	var updated []*Account
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		updated = make([]*Account, 0, len(ids))
+		for _, id := range ids {
+			value, err := tx.Get(ctx, s.accountsKey(id)).Result()
+			if err == redis.Nil {
+				return fmt.Errorf("account %d: %w", id, ErrNoRows)
+			}
+			if err != nil {
+				return err
+			}
+			var acc Account
+			if err := json.Unmarshal([]byte(value), &acc); err != nil {
+				return err
+			}
+			acc.ID = id
+			patch.apply(&acc)
+			acc.UpdatedAt = time.Now()
+			updated = append(updated, &acc)
+		}
+
+		_, err := tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, acc := range updated {
+				data, err := json.Marshal(acc)
+				if err != nil {
+					return err
+				}
+				pipe.Set(ctx, s.accountsKey(acc.ID), data, 0)
+				if acc.Enabled {
+					pipe.SAdd(ctx, s.accountsEnabledKey(), acc.ID)
+				} else {
+					pipe.SRem(ctx, s.accountsEnabledKey(), acc.ID)
+				}
+			}
+			return nil
+		})
+		return err
+	}, keys...)
+	if err == redis.TxFailedErr {
+		return nil, fmt.Errorf("batch update: %w", ErrConflict)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
 func (s *redisStore) DeleteAccount(ctx context.Context, id int64) error {
 	if s == nil || s.client == nil {
 		return fmt.Errorf("redis store not configured")
@@ -492,6 +589,103 @@ func (s *redisStore) SetSetting(ctx context.Context, key, value string) error {
 	return s.client.Set(ctx, s.settingsKey(key), value, 0).Err()
 }
 
+func (s *redisStore) PublishSettingChange(ctx context.Context, key string) error {
+	if s == nil || s.client == nil {
+		return fmt.Errorf("redis store not configured")
+	}
+	return s.client.Publish(ctx, s.settingsChannel(), strings.TrimSpace(key)).Err()
+}
+
+func (s *redisStore) WatchSettingChanges(ctx context.Context) <-chan string {
+	out := make(chan string, 16)
+	if s == nil || s.client == nil {
+		close(out)
+		return out
+	}
+	// go-redis 在连接断开后会自动重新订阅
+	sub := s.client.Subscribe(ctx, s.settingsChannel())
+	go func() {
+		defer close(out)
+		defer sub.Close()
+		msgs := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-msgs:
+				if !ok {
+					return
+				}
+				select {
+				case out <- msg.Payload:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out
+}
+
+func (s *redisStore) AppendConfigVersion(ctx context.Context, v *ConfigVersion, keep int) error {
+	if s == nil || s.client == nil {
+		return fmt.Errorf("redis store not configured")
+	}
+	if v == nil {
+		return nil
+	}
+	id, err := s.client.Incr(ctx, s.configHistoryNextIDKey()).Result()
+	if err != nil {
+		return err
+	}
+	v.Version = id
+	if v.CreatedAt.IsZero() {
+		v.CreatedAt = time.Now()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.LPush(ctx, s.configHistoryKey(), data)
+		if keep > 0 {
+			pipe.LTrim(ctx, s.configHistoryKey(), 0, int64(keep-1))
+		}
+		return nil
+	})
+	return err
+}
+
+func (s *redisStore) ListConfigVersions(ctx context.Context) ([]*ConfigVersion, error) {
+	if s == nil || s.client == nil {
+		return nil, fmt.Errorf("redis store not configured")
+	}
+	items, err := s.client.LRange(ctx, s.configHistoryKey(), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	versions := make([]*ConfigVersion, 0, len(items))
+	for _, item := range items {
+		var v ConfigVersion
+		if err := json.Unmarshal([]byte(item), &v); err != nil {
+			continue
+		}
+		versions = append(versions, &v)
+	}
+	return versions, nil
+}
+
+func (s *redisStore) TryLock(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	if s == nil || s.client == nil {
+		return false, fmt.Errorf("redis store not configured")
+	}
+	name = strings.TrimSpace(name)
+	if name == "" || ttl <= 0 {
+		return false, fmt.Errorf("invalid lock %q ttl %s", name, ttl)
+	}
+	return s.client.SetNX(ctx, s.lockKey(name), lockOwner, ttl).Result()
+}
+
 func (s *redisStore) CreateApiKey(ctx context.Context, key *ApiKey) error {
 	if s == nil || s.client == nil {
 		return fmt.Errorf("redis store not configured")
@@ -747,6 +941,87 @@ func (s *redisStore) getApiKeysByIDs(ctx context.Context, ids []string) ([]*ApiK
 	return items, nil
 }
 
+// bumpNextIDScript 将 ID 计数器推进到不小于 ARGV[1]，使迁移后新建的数据不与保留的原 ID 冲突
+var bumpNextIDScript = redis.NewScript(`
+	local cur = tonumber(redis.call("GET", KEYS[1]) or "0")
+	local id = tonumber(ARGV[1])
+	if cur < id then redis.call("SET", KEYS[1], id) end
+	return "OK"
+`)
+
+// RestoreAccount 按原 ID 写入账号（覆盖已有记录），用于存储迁移
+func (s *redisStore) RestoreAccount(ctx context.Context, acc *Account) error {
+	if s == nil || s.client == nil {
+		return fmt.Errorf("redis store not configured")
+	}
+	if acc == nil || acc.ID <= 0 {
+		return fmt.Errorf("account id is required")
+	}
+	data, err := json.Marshal(acc)
+	if err != nil {
+		return err
+	}
+
+	pipe := s.client.Pipeline()
+	pipe.Set(ctx, s.accountsKey(acc.ID), data, 0)
+	pipe.SAdd(ctx, s.accountsIDsKey(), acc.ID)
+	if acc.Enabled {
+		pipe.SAdd(ctx, s.accountsEnabledKey(), acc.ID)
+	} else {
+		pipe.SRem(ctx, s.accountsEnabledKey(), acc.ID)
+	}
+	bumpNextIDScript.Eval(ctx, pipe, []string{s.accountsNextIDKey()}, acc.ID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// RestoreApiKey 按原 ID 写入 API Key（覆盖已有记录并清理其旧哈希索引），用于存储迁移
+func (s *redisStore) RestoreApiKey(ctx context.Context, key *ApiKey) error {
+	if s == nil || s.client == nil {
+		return fmt.Errorf("redis store not configured")
+	}
+	if key == nil || key.ID <= 0 {
+		return fmt.Errorf("api key id is required")
+	}
+	existing, err := s.getApiKeyByID(ctx, key.ID)
+	if err != nil && err != ErrNoRows {
+		return err
+	}
+	data, err := json.Marshal(apiKeyRecordFromKey(key))
+	if err != nil {
+		return err
+	}
+
+	pipe := s.client.Pipeline()
+	if existing != nil && existing.KeyHash != "" && existing.KeyHash != key.KeyHash {
+		pipe.Del(ctx, s.apiKeysHashKey(existing.KeyHash))
+	}
+	pipe.Set(ctx, s.apiKeysKey(key.ID), data, 0)
+	pipe.SAdd(ctx, s.apiKeysIDsKey(), key.ID)
+	if key.KeyHash != "" {
+		pipe.Set(ctx, s.apiKeysHashKey(key.KeyHash), key.ID, 0)
+	}
+	bumpNextIDScript.Eval(ctx, pipe, []string{s.apiKeysNextIDKey()}, key.ID)
+	_, err = pipe.Exec(ctx)
+	return err
+}
+
+// RestoreModel 按原 ID 写入模型，数字 ID 同时推进模型 ID 计数器，用于存储迁移
+func (s *redisStore) RestoreModel(ctx context.Context, m *Model) error {
+	if m == nil {
+		return fmt.Errorf("model is required")
+	}
+	if err := s.UpdateModel(ctx, m); err != nil {
+		return err
+	}
+	if id, err := strconv.ParseInt(m.ID, 10, 64); err == nil && id > 0 {
+		if err := bumpNextIDScript.Run(ctx, s.client, []string{s.modelsNextIDKey()}, id).Err(); err != nil && err != redis.Nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (s *redisStore) accountsKey(id int64) string {
 	return fmt.Sprintf("%saccounts:id:%d", s.prefix, id)
 }
@@ -767,6 +1042,22 @@ func (s *redisStore) settingsKey(key string) string {
 	return s.prefix + "settings:" + key
 }
 
+func (s *redisStore) settingsChannel() string {
+	return s.prefix + "settings:changed"
+}
+
+func (s *redisStore) configHistoryKey() string {
+	return s.prefix + "settings:config:history"
+}
+
+func (s *redisStore) configHistoryNextIDKey() string {
+	return s.prefix + "settings:config:history:next_id"
+}
+
+func (s *redisStore) lockKey(name string) string {
+	return s.prefix + "locks:" + name
+}
+
 func (s *redisStore) apiKeysKey(id int64) string {
 	return fmt.Sprintf("%sapi_keys:id:%d", s.prefix, id)
 }
@@ -800,11 +1091,19 @@ func apiKeyRecordFromKey(key *ApiKey) apiKeyRecord {
 	}
 }
 
+// apiKeyIDLen 与 middleware.APIKeyID 的长度保持一致
+const apiKeyIDLen = 12
+
 func (r apiKeyRecord) toApiKey() *ApiKey {
+	keyID := r.KeyHash
+	if len(keyID) > apiKeyIDLen {
+		keyID = keyID[:apiKeyIDLen]
+	}
 	return &ApiKey{
 		ID:         r.ID,
 		Name:       r.Name,
 		KeyHash:    r.KeyHash,
+		KeyID:      keyID,
 		KeyFull:    r.KeyFull,
 		KeyPrefix:  r.KeyPrefix,
 		KeySuffix:  r.KeySuffix,
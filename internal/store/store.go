@@ -4,11 +4,15 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"sync/atomic"
 	"time"
 )
 
 var ErrNoRows = fmt.Errorf("no rows in result set")
 
+// ErrConflict 表示事务期间数据被并发修改，调用方可直接重试
+var ErrConflict = fmt.Errorf("concurrent modification, retry")
+
 type Account struct {
 	ID            int64     `json:"id"`
 	Name          string    `json:"name"`
@@ -23,6 +27,7 @@ type Account struct {
 	AgentMode     string    `json:"agent_mode"`
 	Email         string    `json:"email"`
 	Weight        int       `json:"weight"`
+	MaxConcurrent int       `json:"max_concurrent"` // 同时进行的请求上限，0 为不限制
 	Enabled       bool      `json:"enabled"`
 	Token         string    `json:"token"`        // Truncated display token
 	Subscription  string    `json:"subscription"` // "free", "pro", etc.
@@ -40,16 +45,55 @@ type Account struct {
 	UpdatedAt     time.Time `json:"updated_at"`
 }
 
+// AccountPatch 为批量更新的部分字段，nil 表示不修改
+type AccountPatch struct {
+	Enabled       *bool   `json:"enabled,omitempty"`
+	Weight        *int    `json:"weight,omitempty"`
+	MaxConcurrent *int    `json:"max_concurrent,omitempty"`
+	AgentMode     *string `json:"agent_mode,omitempty"`
+}
+
+// IsEmpty 报告 patch 是否未包含任何字段
+func (p AccountPatch) IsEmpty() bool {
+	return p.Enabled == nil && p.Weight == nil && p.MaxConcurrent == nil && p.AgentMode == nil
+}
+
+func (p AccountPatch) apply(acc *Account) {
+	if p.Enabled != nil {
+		acc.Enabled = *p.Enabled
+	}
+	if p.Weight != nil {
+		acc.Weight = *p.Weight
+	}
+	if p.MaxConcurrent != nil {
+		acc.MaxConcurrent = *p.MaxConcurrent
+	}
+	if p.AgentMode != nil {
+		acc.AgentMode = *p.AgentMode
+	}
+}
+
 type Settings struct {
 	ID    int64  `json:"id"`
 	Key   string `json:"key"`
 	Value string `json:"value"`
 }
 
+// ConfigVersion 为一次保存到 Redis 的配置快照，用于查看历史与回滚
+type ConfigVersion struct {
+	Version    int64     `json:"version"`
+	Author     string    `json:"author"`
+	CreatedAt  time.Time `json:"created_at"`
+	Changed    []string  `json:"changed,omitempty"`
+	RollbackOf int64     `json:"rollback_of,omitempty"`
+	Config     string    `json:"config,omitempty"`
+}
+
 type ApiKey struct {
 	ID         int64      `json:"id"`
 	Name       string     `json:"name"`
 	KeyHash    string     `json:"-"`
+	KeyID      string     `json:"key_id,omitempty"` // KeyHash 前 12 位，与访问日志的 api_key_id 一致
 	KeyFull    string     `json:"key_full,omitempty"`
 	KeyPrefix  string     `json:"key_prefix"`
 	KeySuffix  string     `json:"key_suffix"`
@@ -63,14 +107,23 @@ type Store struct {
 	settings settingsStore
 	apiKeys  apiKeyStore
 	models   modelStore
+	locks    lockStore
+
+	// modelsGen 在模型列表变更（本副本写入或收到其他副本的通知）时递增，供模型列表缓存判断失效
+	modelsGen atomic.Uint64
 }
 
+// ModelsChangedKey 为模型变更时通过设置变更通知广播的 key
+const ModelsChangedKey = "models"
+
 type Options struct {
 	StoreMode     string
 	RedisAddr     string
 	RedisPassword string
 	RedisDB       int
 	RedisPrefix   string
+	// SkipSeed 为 true 时不写入默认模型（迁移工具打开目标存储时使用）
+	SkipSeed bool
 }
 
 type accountStore interface {
@@ -83,11 +136,16 @@ type accountStore interface {
 	IncrementRequestCount(ctx context.Context, id int64) error
 	IncrementUsage(ctx context.Context, id int64, usage float64) error
 	IncrementAccountStats(ctx context.Context, id int64, usage float64, count int64) error
+	BatchUpdateAccounts(ctx context.Context, ids []int64, patch AccountPatch) ([]*Account, error)
 }
 
 type settingsStore interface {
 	GetSetting(ctx context.Context, key string) (string, error)
 	SetSetting(ctx context.Context, key, value string) error
+	AppendConfigVersion(ctx context.Context, v *ConfigVersion, keep int) error
+	ListConfigVersions(ctx context.Context) ([]*ConfigVersion, error)
+	PublishSettingChange(ctx context.Context, key string) error
+	WatchSettingChanges(ctx context.Context) <-chan string
 }
 
 type apiKeyStore interface {
@@ -108,10 +166,19 @@ type modelStore interface {
 	ListModels(ctx context.Context) ([]*Model, error)
 }
 
+// lockStore 提供多副本间的互斥锁，锁到期自动释放
+type lockStore interface {
+	TryLock(ctx context.Context, name string, ttl time.Duration) (bool, error)
+}
+
 type closeableStore interface {
 	Close() error
 }
 
+type pingableStore interface {
+	Ping(ctx context.Context) error
+}
+
 func New(opts Options) (*Store, error) {
 	store := &Store{}
 	redisStore, err := newRedisStore(opts.RedisAddr, opts.RedisPassword, opts.RedisDB, opts.RedisPrefix)
@@ -122,6 +189,10 @@ func New(opts Options) (*Store, error) {
 	store.settings = redisStore
 	store.apiKeys = redisStore
 	store.models = redisStore
+	store.locks = redisStore
+	if opts.SkipSeed {
+		return store, nil
+	}
 	if err := store.seedModels(); err != nil {
 		slog.Warn("failed to seed models in redis", "error", err)
 	}
@@ -189,6 +260,16 @@ func (s *Store) Close() error {
 	return nil
 }
 
+// Ping 检查底层存储是否可访问
+func (s *Store) Ping(ctx context.Context) error {
+	if s.accounts != nil {
+		if p, ok := s.accounts.(pingableStore); ok {
+			return p.Ping(ctx)
+		}
+	}
+	return nil
+}
+
 func (s *Store) CreateAccount(ctx context.Context, acc *Account) error {
 	if s.accounts != nil {
 		return s.accounts.CreateAccount(ctx, acc)
@@ -203,6 +284,13 @@ func (s *Store) UpdateAccount(ctx context.Context, acc *Account) error {
 	return fmt.Errorf("store not configured")
 }
 
+func (s *Store) BatchUpdateAccounts(ctx context.Context, ids []int64, patch AccountPatch) ([]*Account, error) {
+	if s.accounts != nil {
+		return s.accounts.BatchUpdateAccounts(ctx, ids, patch)
+	}
+	return nil, fmt.Errorf("store not configured")
+}
+
 func (s *Store) DeleteAccount(ctx context.Context, id int64) error {
 	if s.accounts != nil {
 		return s.accounts.DeleteAccount(ctx, id)
@@ -259,6 +347,67 @@ func (s *Store) SetSetting(ctx context.Context, key, value string) error {
 	return fmt.Errorf("settings store not configured")
 }
 
+// PublishSettingChange 通知所有副本 key 对应的设置已变更
+func (s *Store) PublishSettingChange(ctx context.Context, key string) error {
+	if s.settings != nil {
+		return s.settings.PublishSettingChange(ctx, key)
+	}
+	return fmt.Errorf("settings store not configured")
+}
+
+// WatchSettingChanges 订阅设置变更通知，返回变更的 key；ctx 结束时关闭 channel。
+// 未配置设置存储时返回的 channel 在 ctx 结束前不会产生任何通知
+func (s *Store) WatchSettingChanges(ctx context.Context) <-chan string {
+	if s.settings != nil {
+		return s.settings.WatchSettingChanges(ctx)
+	}
+	ch := make(chan string)
+	go func() {
+		<-ctx.Done()
+		close(ch)
+	}()
+	return ch
+}
+
+// AppendConfigVersion 记录一个配置版本并分配版本号，只保留最近 keep 个
+func (s *Store) AppendConfigVersion(ctx context.Context, v *ConfigVersion, keep int) error {
+	if s.settings != nil {
+		return s.settings.AppendConfigVersion(ctx, v, keep)
+	}
+	return fmt.Errorf("settings store not configured")
+}
+
+// ListConfigVersions 返回保留的配置版本，最新的在前
+func (s *Store) ListConfigVersions(ctx context.Context) ([]*ConfigVersion, error) {
+	if s.settings != nil {
+		return s.settings.ListConfigVersions(ctx)
+	}
+	return nil, fmt.Errorf("settings store not configured")
+}
+
+// GetConfigVersion 按版本号查找配置快照，不存在（或已被淘汰）时返回 nil
+func (s *Store) GetConfigVersion(ctx context.Context, version int64) (*ConfigVersion, error) {
+	versions, err := s.ListConfigVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range versions {
+		if v.Version == version {
+			return v, nil
+		}
+	}
+	return nil, nil
+}
+
+// TryLock 尝试获取名为 name 的跨副本锁，持有到 ttl 到期。
+// 未配置锁存储（单实例）时总是成功。
+func (s *Store) TryLock(ctx context.Context, name string, ttl time.Duration) (bool, error) {
+	if s.locks != nil {
+		return s.locks.TryLock(ctx, name, ttl)
+	}
+	return true, nil
+}
+
 func (s *Store) CreateApiKey(ctx context.Context, key *ApiKey) error {
 	if s.apiKeys != nil {
 		return s.apiKeys.CreateApiKey(ctx, key)
@@ -309,7 +458,9 @@ func (s *Store) CreateModel(ctx context.Context, m *Model) error {
 				}
 			}
 		}
-		return s.models.CreateModel(ctx, m)
+		err := s.models.CreateModel(ctx, m)
+		s.modelsChanged(ctx)
+		return err
 	}
 	return fmt.Errorf("models store not configured")
 }
@@ -327,18 +478,50 @@ func (s *Store) UpdateModel(ctx context.Context, m *Model) error {
 				}
 			}
 		}
-		return s.models.UpdateModel(ctx, m)
+		err := s.models.UpdateModel(ctx, m)
+		s.modelsChanged(ctx)
+		return err
 	}
 	return fmt.Errorf("models store not configured")
 }
 
 func (s *Store) DeleteModel(ctx context.Context, id string) error {
 	if s.models != nil {
-		return s.models.DeleteModel(ctx, id)
+		err := s.models.DeleteModel(ctx, id)
+		s.modelsChanged(ctx)
+		return err
 	}
 	return fmt.Errorf("models store not configured")
 }
 
+// modelsChanged 使本副本的模型列表缓存失效并通知其他副本。写入失败时也会调用（默认模型可能已被修改）
+func (s *Store) modelsChanged(ctx context.Context) {
+	s.modelsGen.Add(1)
+	if s.settings == nil {
+		return
+	}
+	if err := s.settings.PublishSettingChange(ctx, ModelsChangedKey); err != nil {
+		slog.Warn("Failed to publish model change", "error", err)
+	}
+}
+
+// ModelsGeneration 返回模型列表的变更代数，代数不变时模型列表未被修改
+func (s *Store) ModelsGeneration() uint64 {
+	return s.modelsGen.Load()
+}
+
+// WatchModelChanges 订阅其他副本的模型变更通知并递增变更代数，直到 ctx 结束
+func (s *Store) WatchModelChanges(ctx context.Context) {
+	changes := s.WatchSettingChanges(ctx)
+	go func() {
+		for key := range changes {
+			if key == ModelsChangedKey {
+				s.modelsGen.Add(1)
+			}
+		}
+	}()
+}
+
 func (s *Store) GetModel(ctx context.Context, id string) (*Model, error) {
 	if s.models != nil {
 		return s.models.GetModel(ctx, id)
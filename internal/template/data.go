@@ -4,10 +4,16 @@ package template
 type PageData struct {
 	Title     string
 	AdminPath string
+	// BasePath 为反向代理路径前缀，页面中的静态资源与接口地址都拼在它之后
+	BasePath  string
 	ActiveTab string
 	User      *UserInfo
 	Stats     *Stats
 	Config    *ConfigData
+	// CSRFToken 注入页面 meta，前端写操作通过 X-CSRF-Token 请求头回传
+	CSRFToken string
+	// Version 为构建版本，显示在侧边栏底部便于反馈问题时注明
+	Version string
 }
 
 // UserInfo represents user information
@@ -8,8 +8,10 @@ import (
 	"strings"
 	"sync"
 
+	"orchids-api/internal/auth"
 	"orchids-api/internal/config"
 	"orchids-api/internal/store"
+	"orchids-api/internal/version"
 	"orchids-api/web"
 )
 
@@ -106,8 +108,13 @@ func (r *Renderer) RenderIndex(w http.ResponseWriter, req *http.Request, cfg *co
 	data := &PageData{
 		Title:     "API 管理面板",
 		AdminPath: cfg.AdminPath,
+		BasePath:  cfg.BasePath,
 		ActiveTab: activeTab,
 		Stats:     stats,
+		Version:   version.Get().String(),
+	}
+	if cookie, err := req.Cookie("session_token"); err == nil {
+		data.CSRFToken = auth.CSRFToken(cookie.Value)
 	}
 
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
@@ -130,6 +137,16 @@ func (r *Renderer) RenderIndex(w http.ResponseWriter, req *http.Request, cfg *co
 	return r.templates.ExecuteTemplate(w, templateName, data)
 }
 
+// Loaded reports whether the templates used by RenderIndex are available
+func (r *Renderer) Loaded() bool {
+	if r == nil {
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.templates != nil && r.templates.Lookup("page-accounts") != nil
+}
+
 // getActiveTab extracts the active tab from the request
 func getActiveTab(req *http.Request) string {
 	tab := req.URL.Query().Get("tab")
@@ -0,0 +1,484 @@
+// Package fakeredis 提供进程内的最小 Redis（RESP2）服务端，实现 store 用到的字符串、列表、集合、
+// PUBLISH 与 WATCH/MULTI/EXEC 命令，使依赖 store.Store 的测试不需要真实 Redis。
+// 不支持过期时间与订阅：SET 的 EX/PX 参数被忽略，SUBSCRIBE 返回错误
+package fakeredis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Server 为一个监听在 127.0.0.1 随机端口上的 fake Redis
+type Server struct {
+	ln    net.Listener
+	wg    sync.WaitGroup
+	once  sync.Once
+	connM sync.Mutex
+	conns map[net.Conn]struct{}
+
+	mu       sync.Mutex
+	strs     map[string]string
+	lists    map[string][]string
+	sets     map[string]map[string]struct{}
+	versions map[string]int64 // 每次写入递增，WATCH 据此判断 key 是否被修改
+	failures map[string]string
+	conflict bool
+}
+
+// Start 启动服务端，测试结束时自动关闭
+func Start(t testing.TB) *Server {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("fakeredis: listen: %v", err)
+	}
+	s := &Server{
+		ln:       ln,
+		conns:    make(map[net.Conn]struct{}),
+		strs:     make(map[string]string),
+		lists:    make(map[string][]string),
+		sets:     make(map[string]map[string]struct{}),
+		versions: make(map[string]int64),
+		failures: make(map[string]string),
+	}
+	s.wg.Add(1)
+	go s.acceptLoop()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Addr 返回 host:port，用作 store.Options.RedisAddr
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Close 关闭监听与所有连接，并等待连接处理结束
+func (s *Server) Close() {
+	s.once.Do(func() {
+		s.ln.Close()
+		s.connM.Lock()
+		for nc := range s.conns {
+			nc.Close()
+		}
+		s.connM.Unlock()
+	})
+	s.wg.Wait()
+}
+
+// FailCommand 使之后的 name 命令（如 "SET"）返回 Redis 错误 msg，msg 为空时恢复正常
+func (s *Server) FailCommand(name, msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if msg == "" {
+		delete(s.failures, strings.ToUpper(name))
+		return
+	}
+	s.failures[strings.ToUpper(name)] = msg
+}
+
+// ConflictNextExec 使下一个带 WATCH 的事务在 EXEC 时失败，模拟被监视的 key 被并发修改
+func (s *Server) ConflictNextExec() {
+	s.mu.Lock()
+	s.conflict = true
+	s.mu.Unlock()
+}
+
+// List 返回列表 key 的当前内容
+func (s *Server) List(key string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.lists[key]...)
+}
+
+// Get 返回字符串 key 的值
+func (s *Server) Get(key string) (string, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.strs[key]
+	return v, ok
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		nc, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		s.connM.Lock()
+		s.conns[nc] = struct{}{}
+		s.connM.Unlock()
+		s.wg.Add(1)
+		go func() {
+			defer s.wg.Done()
+			s.serve(nc)
+			s.connM.Lock()
+			delete(s.conns, nc)
+			s.connM.Unlock()
+		}()
+	}
+}
+
+// conn 为单个连接的事务状态
+type conn struct {
+	multi   bool
+	queued  [][]string
+	watched map[string]int64
+}
+
+type (
+	simple   string
+	nilBulk  struct{}
+	nilArray struct{}
+	respErr  string
+)
+
+func (s *Server) serve(nc net.Conn) {
+	defer nc.Close()
+	r := bufio.NewReader(nc)
+	w := bufio.NewWriter(nc)
+	c := &conn{}
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		writeReply(w, s.dispatch(c, args))
+		if r.Buffered() == 0 {
+			if err := w.Flush(); err != nil {
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) dispatch(c *conn, args []string) interface{} {
+	name := strings.ToUpper(args[0])
+	switch name {
+	case "MULTI":
+		c.multi, c.queued = true, nil
+		return simple("OK")
+	case "DISCARD":
+		c.multi, c.queued, c.watched = false, nil, nil
+		return simple("OK")
+	case "WATCH":
+		s.mu.Lock()
+		if c.watched == nil {
+			c.watched = make(map[string]int64)
+		}
+		for _, key := range args[1:] {
+			c.watched[key] = s.versions[key]
+		}
+		s.mu.Unlock()
+		return simple("OK")
+	case "UNWATCH":
+		c.watched = nil
+		return simple("OK")
+	case "EXEC":
+		if !c.multi {
+			return respErr("ERR EXEC without MULTI")
+		}
+		queued, watched := c.queued, c.watched
+		c.multi, c.queued, c.watched = false, nil, nil
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if len(watched) > 0 && s.conflict {
+			s.conflict = false
+			return nilArray{}
+		}
+		for key, v := range watched {
+			if s.versions[key] != v {
+				return nilArray{}
+			}
+		}
+		replies := make([]interface{}, 0, len(queued))
+		for _, cmd := range queued {
+			replies = append(replies, s.exec(cmd))
+		}
+		return replies
+	}
+	if c.multi {
+		c.queued = append(c.queued, args)
+		return simple("QUEUED")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.exec(args)
+}
+
+// exec 执行一条命令，调用方需持有 s.mu
+func (s *Server) exec(args []string) interface{} {
+	name := strings.ToUpper(args[0])
+	if msg, ok := s.failures[name]; ok {
+		return respErr(msg)
+	}
+	key := ""
+	if len(args) > 1 {
+		key = args[1]
+	}
+	switch name {
+	case "PING":
+		return simple("PONG")
+	case "HELLO":
+		return respErr("ERR unknown command 'HELLO'")
+	case "CLIENT", "SELECT", "AUTH":
+		return simple("OK")
+	case "GET":
+		if v, ok := s.strs[key]; ok {
+			return v
+		}
+		return nilBulk{}
+	case "MGET":
+		out := make([]interface{}, 0, len(args)-1)
+		for _, k := range args[1:] {
+			if v, ok := s.strs[k]; ok {
+				out = append(out, v)
+			} else {
+				out = append(out, nilBulk{})
+			}
+		}
+		return out
+	case "SET":
+		if len(args) < 3 {
+			return wrongArgs(name)
+		}
+		for _, opt := range args[3:] {
+			switch strings.ToUpper(opt) {
+			case "NX":
+				if _, ok := s.strs[key]; ok {
+					return nilBulk{}
+				}
+			case "XX":
+				if _, ok := s.strs[key]; !ok {
+					return nilBulk{}
+				}
+			}
+		}
+		s.setString(key, args[2])
+		return simple("OK")
+	case "SETNX":
+		if len(args) < 3 {
+			return wrongArgs(name)
+		}
+		if _, ok := s.strs[key]; ok {
+			return int64(0)
+		}
+		s.setString(key, args[2])
+		return int64(1)
+	case "INCR":
+		n, err := strconv.ParseInt(s.strs[key], 10, 64)
+		if err != nil && s.strs[key] != "" {
+			return respErr("ERR value is not an integer or out of range")
+		}
+		n++
+		s.setString(key, strconv.FormatInt(n, 10))
+		return n
+	case "DEL":
+		var n int64
+		for _, k := range args[1:] {
+			if s.exists(k) {
+				delete(s.strs, k)
+				delete(s.lists, k)
+				delete(s.sets, k)
+				s.versions[k]++
+				n++
+			}
+		}
+		return n
+	case "EXISTS":
+		var n int64
+		for _, k := range args[1:] {
+			if s.exists(k) {
+				n++
+			}
+		}
+		return n
+	case "LPUSH", "RPUSH":
+		list := s.lists[key]
+		for _, v := range args[2:] {
+			if name == "LPUSH" {
+				list = append([]string{v}, list...)
+			} else {
+				list = append(list, v)
+			}
+		}
+		s.lists[key] = list
+		s.versions[key]++
+		return int64(len(list))
+	case "LRANGE", "LTRIM":
+		if len(args) != 4 {
+			return wrongArgs(name)
+		}
+		start, err1 := strconv.Atoi(args[2])
+		stop, err2 := strconv.Atoi(args[3])
+		if err1 != nil || err2 != nil {
+			return respErr("ERR value is not an integer or out of range")
+		}
+		list := s.lists[key]
+		lo, hi := listRange(len(list), start, stop)
+		if name == "LTRIM" {
+			s.lists[key] = append([]string(nil), list[lo:hi]...)
+			s.versions[key]++
+			return simple("OK")
+		}
+		out := make([]interface{}, 0, hi-lo)
+		for _, v := range list[lo:hi] {
+			out = append(out, v)
+		}
+		return out
+	case "LLEN":
+		return int64(len(s.lists[key]))
+	case "SADD", "SREM":
+		set := s.sets[key]
+		if set == nil {
+			set = make(map[string]struct{})
+			s.sets[key] = set
+		}
+		var n int64
+		for _, m := range args[2:] {
+			_, ok := set[m]
+			switch {
+			case name == "SADD" && !ok:
+				set[m] = struct{}{}
+				n++
+			case name == "SREM" && ok:
+				delete(set, m)
+				n++
+			}
+		}
+		s.versions[key]++
+		return n
+	case "SMEMBERS":
+		members := make([]string, 0, len(s.sets[key]))
+		for m := range s.sets[key] {
+			members = append(members, m)
+		}
+		sort.Strings(members)
+		out := make([]interface{}, 0, len(members))
+		for _, m := range members {
+			out = append(out, m)
+		}
+		return out
+	case "SISMEMBER":
+		if len(args) < 3 {
+			return wrongArgs(name)
+		}
+		if _, ok := s.sets[key][args[2]]; ok {
+			return int64(1)
+		}
+		return int64(0)
+	case "SCARD":
+		return int64(len(s.sets[key]))
+	case "PUBLISH":
+		return int64(0)
+	}
+	return respErr(fmt.Sprintf("ERR unknown command '%s'", args[0]))
+}
+
+func (s *Server) setString(key, value string) {
+	s.strs[key] = value
+	s.versions[key]++
+}
+
+func (s *Server) exists(key string) bool {
+	if _, ok := s.strs[key]; ok {
+		return true
+	}
+	if _, ok := s.lists[key]; ok {
+		return true
+	}
+	_, ok := s.sets[key]
+	return ok
+}
+
+func wrongArgs(name string) respErr {
+	return respErr(fmt.Sprintf("ERR wrong number of arguments for '%s' command", strings.ToLower(name)))
+}
+
+// listRange 按 Redis 的 LRANGE/LTRIM 语义（闭区间，负数从末尾计）返回切片下标
+func listRange(n, start, stop int) (int, int) {
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	start = max(start, 0)
+	stop = min(stop, n-1)
+	if start > stop {
+		return 0, 0
+	}
+	return start, stop + 1
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 1 {
+		return nil, errors.New("fakeredis: invalid array header")
+	}
+	args := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		header, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(header, "$") {
+			return nil, errors.New("fakeredis: expected bulk string")
+		}
+		size, err := strconv.Atoi(header[1:])
+		if err != nil || size < 0 {
+			return nil, errors.New("fakeredis: invalid bulk length")
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func writeReply(w *bufio.Writer, v interface{}) {
+	switch v := v.(type) {
+	case simple:
+		fmt.Fprintf(w, "+%s\r\n", string(v))
+	case respErr:
+		fmt.Fprintf(w, "-%s\r\n", string(v))
+	case int64:
+		fmt.Fprintf(w, ":%d\r\n", v)
+	case string:
+		fmt.Fprintf(w, "$%d\r\n%s\r\n", len(v), v)
+	case nilBulk:
+		w.WriteString("$-1\r\n")
+	case nilArray:
+		w.WriteString("*-1\r\n")
+	case []interface{}:
+		fmt.Fprintf(w, "*%d\r\n", len(v))
+		for _, item := range v {
+			writeReply(w, item)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+// Package golden 提供上游 SSE/WS 交互的录制与回放工具，
+// 用于在单元测试中以固定 transcript 代替真实账号，对协议转换做回归测试。
+package golden
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"orchids-api/internal/debug"
+	"orchids-api/internal/upstream"
+)
+
+// UpdateEnv 设置为 1 时 Assert 会覆盖写入 golden 文件而不是比较
+const UpdateEnv = "UPDATE_GOLDEN"
+
+// Entry 是 transcript 中的一行：一条上游消息，或以 Error 结束本次上游调用
+type Entry struct {
+	Type  string                 `json:"type,omitempty"`
+	Event map[string]interface{} `json:"event,omitempty"`
+	Error string                 `json:"error,omitempty"`
+}
+
+// Load 读取 JSONL 格式的 transcript，空行和 # 开头的注释行会被忽略
+func Load(path string) ([]Entry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		raw := bytes.TrimSpace(scanner.Bytes())
+		if len(raw) == 0 || raw[0] == '#' {
+			continue
+		}
+		var e Entry
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.UseNumber()
+		if err := dec.Decode(&e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// Recorder 以 JSONL 形式记录上游消息，可并发使用
+type Recorder struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewRecorder 创建写入 w 的录制器
+func NewRecorder(w io.Writer) *Recorder {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	return &Recorder{enc: enc}
+}
+
+// Record 写入一条上游消息
+func (r *Recorder) Record(msg upstream.SSEMessage) {
+	r.write(Entry{Type: msg.Type, Event: msg.Event})
+}
+
+// RecordError 记录上游调用以错误结束
+func (r *Recorder) RecordError(err error) {
+	if err == nil {
+		return
+	}
+	r.write(Entry{Error: err.Error()})
+}
+
+func (r *Recorder) write(e Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_ = r.enc.Encode(e)
+}
+
+// Wrap 返回先录制再转发给 next 的回调
+func (r *Recorder) Wrap(next func(upstream.SSEMessage)) func(upstream.SSEMessage) {
+	return func(msg upstream.SSEMessage) {
+		r.Record(msg)
+		if next != nil {
+			next(msg)
+		}
+	}
+}
+
+// PayloadClient 与 handler.UpstreamPayloadClient 方法集一致
+type PayloadClient interface {
+	SendRequestWithPayload(ctx context.Context, req upstream.UpstreamRequest, onMessage func(upstream.SSEMessage), logger *debug.Logger) error
+}
+
+// RecordingClient 包装真实上游客户端，把收到的每条消息同时写入 Recorder，用于采集新的 transcript
+type RecordingClient struct {
+	Inner    PayloadClient
+	Recorder *Recorder
+}
+
+func (c *RecordingClient) SendRequest(ctx context.Context, prompt string, chatHistory []interface{}, model string, onMessage func(upstream.SSEMessage), logger *debug.Logger) error {
+	return c.SendRequestWithPayload(ctx, upstream.UpstreamRequest{Prompt: prompt, ChatHistory: chatHistory, Model: model}, onMessage, logger)
+}
+
+func (c *RecordingClient) SendRequestWithPayload(ctx context.Context, req upstream.UpstreamRequest, onMessage func(upstream.SSEMessage), logger *debug.Logger) error {
+	err := c.Inner.SendRequestWithPayload(ctx, req, c.Recorder.Wrap(onMessage), logger)
+	c.Recorder.RecordError(err)
+	return err
+}
+
+// ReplayClient 按 transcript 顺序回放上游消息，满足 handler 的 UpstreamClient/UpstreamPayloadClient 接口
+type ReplayClient struct {
+	entries []Entry
+
+	mu    sync.Mutex
+	calls []upstream.UpstreamRequest
+}
+
+// NewReplayClient 创建回放客户端，每次调用都会完整回放同一份 transcript
+func NewReplayClient(entries []Entry) *ReplayClient {
+	return &ReplayClient{entries: entries}
+}
+
+// LoadReplayClient 从文件加载 transcript 并创建回放客户端
+func LoadReplayClient(path string) (*ReplayClient, error) {
+	entries, err := Load(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReplayClient(entries), nil
+}
+
+func (c *ReplayClient) SendRequest(ctx context.Context, prompt string, chatHistory []interface{}, model string, onMessage func(upstream.SSEMessage), logger *debug.Logger) error {
+	return c.SendRequestWithPayload(ctx, upstream.UpstreamRequest{Prompt: prompt, ChatHistory: chatHistory, Model: model}, onMessage, logger)
+}
+
+func (c *ReplayClient) SendRequestWithPayload(ctx context.Context, req upstream.UpstreamRequest, onMessage func(upstream.SSEMessage), logger *debug.Logger) error {
+	c.mu.Lock()
+	c.calls = append(c.calls, req)
+	c.mu.Unlock()
+
+	for _, e := range c.entries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if e.Error != "" {
+			return errors.New(e.Error)
+		}
+		onMessage(upstream.SSEMessage{Type: e.Type, Event: e.Event})
+	}
+	return nil
+}
+
+// Calls 返回回放期间收到的上游请求快照
+func (c *ReplayClient) Calls() []upstream.UpstreamRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]upstream.UpstreamRequest, len(c.calls))
+	copy(out, c.calls)
+	return out
+}
+
+// Assert 将 got 与 golden 文件比较；设置 UPDATE_GOLDEN=1 时改为写入 golden 文件
+func Assert(t testing.TB, path string, got []byte) {
+	t.Helper()
+	if os.Getenv(UpdateEnv) == "1" {
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatalf("create golden dir: %v", err)
+		}
+		if err := os.WriteFile(path, got, 0644); err != nil {
+			t.Fatalf("write golden %s: %v", path, err)
+		}
+		return
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden %s: %v (run with %s=1 to create it)", path, err, UpdateEnv)
+	}
+	want = bytes.ReplaceAll(want, []byte("\r\n"), []byte("\n"))
+	if !bytes.Equal(got, want) {
+		t.Fatalf("output does not match golden %s (run with %s=1 to update)\n--- got ---\n%s\n--- want ---\n%s", path, UpdateEnv, got, want)
+	}
+}
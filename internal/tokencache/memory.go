@@ -6,9 +6,15 @@ import (
 	"encoding/hex"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"orchids-api/internal/metrics"
 )
 
+// metricsLabel 为 Prometheus 指标中该缓存的 cache 标签
+const metricsLabel = "token_count"
+
 type Cache interface {
 	Get(ctx context.Context, key string) (int, bool)
 	Put(ctx context.Context, key string, tokens int)
@@ -24,6 +30,10 @@ type MemoryCache struct {
 	items      map[string]cacheItem
 	sizeBytes  int64
 	done       chan struct{}
+
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+	evictions atomic.Uint64
 }
 
 type cacheItem struct {
@@ -87,6 +97,7 @@ func (c *MemoryCache) Get(ctx context.Context, key string) (int, bool) {
 	item, ok := c.items[key]
 	if !ok {
 		c.mu.RUnlock()
+		c.recordMiss()
 		return 0, false
 	}
 	if c.ttl > 0 && !item.expiresAt.IsZero() && time.Now().After(item.expiresAt) {
@@ -96,12 +107,16 @@ func (c *MemoryCache) Get(ctx context.Context, key string) (int, bool) {
 			if c.ttl > 0 && !current.expiresAt.IsZero() && time.Now().After(current.expiresAt) {
 				c.sizeBytes -= current.size
 				delete(c.items, key)
+				c.recordEviction("expired")
 			}
 		}
 		c.mu.Unlock()
+		c.recordMiss()
 		return 0, false
 	}
 	c.mu.RUnlock()
+	c.hits.Add(1)
+	metrics.CacheHits.WithLabelValues(metricsLabel, "hit").Inc()
 	return item.tokens, true
 }
 
@@ -143,6 +158,7 @@ func (c *MemoryCache) evictOldestLocked() {
 	if !first {
 		c.sizeBytes -= c.items[oldestKey].size
 		delete(c.items, oldestKey)
+		c.recordEviction("capacity")
 	}
 }
 
@@ -158,6 +174,24 @@ func (c *MemoryCache) GetStats(ctx context.Context) (int64, int64, error) {
 	return count, size, nil
 }
 
+// Counters 返回自启动以来的命中、未命中与淘汰（容量或过期）次数
+func (c *MemoryCache) Counters() (hits, misses, evictions uint64) {
+	if c == nil {
+		return 0, 0, 0
+	}
+	return c.hits.Load(), c.misses.Load(), c.evictions.Load()
+}
+
+func (c *MemoryCache) recordMiss() {
+	c.misses.Add(1)
+	metrics.CacheHits.WithLabelValues(metricsLabel, "miss").Inc()
+}
+
+func (c *MemoryCache) recordEviction(reason string) {
+	c.evictions.Add(1)
+	metrics.CacheEvictions.WithLabelValues(metricsLabel, reason).Inc()
+}
+
 func (c *MemoryCache) Clear(ctx context.Context) error {
 	if c == nil {
 		return nil
@@ -177,6 +211,7 @@ func (c *MemoryCache) pruneExpiredLocked(now time.Time) {
 		if !item.expiresAt.IsZero() && now.After(item.expiresAt) {
 			c.sizeBytes -= item.size
 			delete(c.items, key)
+			c.recordEviction("expired")
 		}
 	}
 }
@@ -0,0 +1,129 @@
+// Package toolspill 保存超出大小上限的 tool_result 原文。prompt 中只保留引用与首尾摘录，
+// 原文按内容哈希存为文件，客户端每轮重发相同的历史时引用保持不变，可通过管理接口查看。
+package toolspill
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrNotFound 表示指定的 tool_result 不存在或已被清理
+var ErrNotFound = errors.New("tool result not found")
+
+// idLen 为引用 ID 的长度（sha256 前 8 字节的十六进制）
+const idLen = 16
+
+// Store 将 tool_result 原文保存在 dir 下，每条一个 <id>.txt 文件
+type Store struct {
+	dir string
+}
+
+// New 创建保存在 dir 下的 Store，dir 在首次写入时创建
+func New(dir string) *Store {
+	return &Store{dir: dir}
+}
+
+// ID 返回内容对应的引用 ID
+func ID(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:idLen/2])
+}
+
+// Put 保存 content 并返回引用 ID。相同内容只保存一次，重复写入时刷新修改时间以延后清理
+func (s *Store) Put(content string) (string, error) {
+	id := ID(content)
+	path := s.path(id)
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err == nil {
+		return id, nil
+	}
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", err
+	}
+	// 先写临时文件再重命名，并发写入同一内容时不会读到半截文件
+	tmp, err := os.CreateTemp(s.dir, id+".*.tmp")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.WriteString(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	return id, nil
+}
+
+// Get 读取引用 ID 对应的原文
+func (s *Store) Get(id string) ([]byte, error) {
+	if !validID(id) {
+		return nil, ErrNotFound
+	}
+	data, err := os.ReadFile(s.path(id))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// Remove 删除引用 ID 对应的原文，不存在时忽略
+func (s *Store) Remove(id string) error {
+	if !validID(id) {
+		return nil
+	}
+	if err := os.Remove(s.path(id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// Prune 删除超过 maxAge 未被引用的文件（maxAge <= 0 不清理），返回删除的文件数
+func (s *Store) Prune(maxAge time.Duration) (int, error) {
+	if maxAge <= 0 {
+		return 0, nil
+	}
+	files, err := os.ReadDir(s.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		info, err := f.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, f.Name())); err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func (s *Store) path(id string) string {
+	return filepath.Join(s.dir, id+".txt")
+}
+
+// validID 只接受 ID 生成的十六进制字符串，避免读取目录以外的文件
+func validID(id string) bool {
+	return len(id) == idLen && strings.Trim(id, "0123456789abcdef") == ""
+}
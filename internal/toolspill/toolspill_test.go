@@ -0,0 +1,50 @@
+package toolspill
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStore_PutGetPrune(t *testing.T) {
+	s := New(filepath.Join(t.TempDir(), "tool-results"))
+
+	id, err := s.Put("hello world")
+	if err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	if id != ID("hello world") || len(id) != idLen {
+		t.Fatalf("id = %q", id)
+	}
+	again, err := s.Put("hello world")
+	if err != nil || again != id {
+		t.Fatalf("second Put = %q, %v", again, err)
+	}
+	data, err := s.Get(id)
+	if err != nil || string(data) != "hello world" {
+		t.Fatalf("Get = %q, %v", data, err)
+	}
+	for _, bad := range []string{"../../etc/passwd", "0123456789ABCDEF", "ffffffffffffffff"} {
+		if _, err := s.Get(bad); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("Get(%q) err = %v", bad, err)
+		}
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(s.path(id), old, old); err != nil {
+		t.Fatal(err)
+	}
+	fresh, _ := s.Put("fresh")
+	removed, err := s.Prune(time.Hour)
+	if err != nil || removed != 1 {
+		t.Fatalf("Prune = %d, %v", removed, err)
+	}
+	if _, err := s.Get(id); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expired entry still readable: %v", err)
+	}
+	if _, err := s.Get(fresh); err != nil {
+		t.Fatalf("fresh entry removed: %v", err)
+	}
+}
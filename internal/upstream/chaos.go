@@ -0,0 +1,153 @@
+package upstream
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ChaosConfig 控制混沌测试层对上游消息的扰动概率，各 Rate 取值 0~1
+type ChaosConfig struct {
+	Seed         int64
+	DelayRate    float64
+	MaxDelay     time.Duration
+	DropRate     float64
+	TruncateRate float64
+	CorruptRate  float64
+}
+
+// Active 返回配置是否会产生任何扰动
+func (c ChaosConfig) Active() bool {
+	return (c.DelayRate > 0 && c.MaxDelay > 0) || c.DropRate > 0 || c.TruncateRate > 0 || c.CorruptRate > 0
+}
+
+// Chaos 按固定种子随机延迟、丢弃、截断或破坏上游消息，用于 soak 测试验证
+// SSE 转换层在异常输入下不会 panic 或输出畸形的 Anthropic 事件。相同种子产生相同扰动序列。
+type Chaos struct {
+	cfg ChaosConfig
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+// NewChaos 创建混沌层；Seed 为 0 时使用当前时间
+func NewChaos(cfg ChaosConfig) *Chaos {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Chaos{cfg: cfg, rng: rand.New(rand.NewSource(seed))}
+}
+
+func (c *Chaos) roll(rate float64) bool {
+	if rate <= 0 {
+		return false
+	}
+	return c.rng.Float64() < rate
+}
+
+// Wrap 返回在转发给 next 前施加扰动的回调。原始消息不会被修改。
+func (c *Chaos) Wrap(ctx context.Context, next func(SSEMessage)) func(SSEMessage) {
+	return func(msg SSEMessage) {
+		c.mu.Lock()
+		var delay time.Duration
+		if c.cfg.MaxDelay > 0 && c.roll(c.cfg.DelayRate) {
+			delay = time.Duration(c.rng.Int63n(int64(c.cfg.MaxDelay)) + 1)
+		}
+		drop := c.roll(c.cfg.DropRate)
+		if !drop {
+			if c.roll(c.cfg.TruncateRate) {
+				msg = c.truncate(msg)
+			}
+			if c.roll(c.cfg.CorruptRate) {
+				msg = c.corrupt(msg)
+			}
+		}
+		c.mu.Unlock()
+
+		if delay > 0 {
+			timer := time.NewTimer(delay)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			}
+		}
+		if drop {
+			return
+		}
+		next(msg)
+	}
+}
+
+// truncate 将事件中的字符串字段截断到随机字节位置（可能切断 UTF-8 多字节字符）
+func (c *Chaos) truncate(msg SSEMessage) SSEMessage {
+	msg.Event = cloneEvent(msg.Event)
+	truncateStrings(c.rng, msg.Event)
+	return msg
+}
+
+func truncateStrings(rng *rand.Rand, m map[string]interface{}) {
+	for _, k := range sortedKeys(m) {
+		switch val := m[k].(type) {
+		case string:
+			if len(val) > 0 {
+				m[k] = val[:rng.Intn(len(val))]
+			}
+		case map[string]interface{}:
+			truncateStrings(rng, val)
+		}
+	}
+}
+
+// corrupt 随机选择一种破坏方式：删除字段、替换为错误类型、清空事件或篡改类型
+func (c *Chaos) corrupt(msg SSEMessage) SSEMessage {
+	msg.Event = cloneEvent(msg.Event)
+	keys := sortedKeys(msg.Event)
+	switch c.rng.Intn(4) {
+	case 0:
+		if len(keys) > 0 {
+			delete(msg.Event, keys[c.rng.Intn(len(keys))])
+		}
+	case 1:
+		if len(keys) > 0 {
+			msg.Event[keys[c.rng.Intn(len(keys))]] = c.rng.Float64()
+		}
+	case 2:
+		msg.Event = nil
+	case 3:
+		if msg.Event != nil {
+			msg.Event["type"] = []interface{}{"chaos"}
+		} else {
+			msg.Type = "chaos.unknown"
+		}
+	}
+	return msg
+}
+
+// sortedKeys 返回排序后的键；map 遍历顺序随机，不排序时相同种子也会选中不同字段
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func cloneEvent(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if nested, ok := v.(map[string]interface{}); ok {
+			out[k] = cloneEvent(nested)
+			continue
+		}
+		out[k] = v
+	}
+	return out
+}
@@ -6,11 +6,17 @@ import (
 	"fmt"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// parallelSlots 限制全进程范围内 ParallelFor 额外启动的 helper goroutine 数量。
+// 并发请求或嵌套调用时不会再按调用次数成倍创建 goroutine。
+var parallelSlots = make(chan struct{}, runtime.GOMAXPROCS(0))
+
 // ParallelFor 并行执行 n 个任务，每个任务接收索引 [0, n)
-// 自动根据 CPU 核心数调整并发度，对于小批量任务会串行执行以避免 goroutine 开销
+// 调用方自身参与执行，并在全局槽位允许时借用 helper goroutine；索引通过原子计数分发，
+// 对于小批量任务会串行执行以避免 goroutine 开销
 func ParallelFor(n int, fn func(int)) {
 	if n <= 0 {
 		return
@@ -27,38 +33,49 @@ func ParallelFor(n int, fn func(int)) {
 		return
 	}
 
-	workers := runtime.GOMAXPROCS(0)
-	if workers < 1 {
-		workers = 1
+	var next atomic.Int64
+	run := func() {
+		for {
+			idx := int(next.Add(1) - 1)
+			if idx >= n {
+				return
+			}
+			func() {
+				defer func() {
+					if r := recover(); r != nil {
+						// Prevent crash from panic in worker
+					}
+				}()
+				fn(idx)
+			}()
+		}
 	}
-	if workers > n {
-		workers = n
+
+	helpers := runtime.GOMAXPROCS(0) - 1
+	if helpers > n-1 {
+		helpers = n - 1
 	}
 
 	var wg sync.WaitGroup
-	jobs := make(chan int, workers)
-
-	for i := 0; i < workers; i++ {
+acquire:
+	for i := 0; i < helpers; i++ {
+		select {
+		case parallelSlots <- struct{}{}:
+		default:
+			// 槽位已被其他调用占满，剩余工作由调用方完成
+			break acquire
+		}
 		wg.Add(1)
 		go func() {
-			defer wg.Done()
-			for idx := range jobs {
-				func() {
-					defer func() {
-						if r := recover(); r != nil {
-							// Prevent crash from panic in worker
-						}
-					}()
-					fn(idx)
-				}()
-			}
+			defer func() {
+				<-parallelSlots
+				wg.Done()
+			}()
+			run()
 		}()
 	}
 
-	for i := 0; i < n; i++ {
-		jobs <- i
-	}
-	close(jobs)
+	run()
 	wg.Wait()
 }
 
@@ -287,3 +287,28 @@ func TestRetryWithBackoff(t *testing.T) {
 		}
 	})
 }
+
+func TestParallelFor_NestedBounded(t *testing.T) {
+	var total int64
+	ParallelFor(16, func(i int) {
+		ParallelFor(16, func(j int) {
+			atomic.AddInt64(&total, 1)
+		})
+	})
+	if total != 16*16 {
+		t.Errorf("total = %d, want %d", total, 16*16)
+	}
+	if len(parallelSlots) != 0 {
+		t.Errorf("slots leaked: %d", len(parallelSlots))
+	}
+}
+
+func BenchmarkParallelFor_SmallTasks(b *testing.B) {
+	results := make([]int, 1000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ParallelFor(len(results), func(idx int) {
+			results[idx] = idx * 2
+		})
+	}
+}
@@ -0,0 +1,84 @@
+// Package version 保存构建时通过 ldflags 注入的版本信息：
+//
+//	go build -ldflags "-X orchids-api/internal/version.Version=v1.2.3 \
+//	  -X orchids-api/internal/version.Commit=$(git rev-parse --short HEAD) \
+//	  -X orchids-api/internal/version.BuildDate=$(date -u +%Y-%m-%dT%H:%M:%SZ)" ./cmd/server
+//
+// 未注入时 Commit / BuildDate 回退为 Go 工具链记录的 vcs.revision / vcs.time
+package version
+
+import (
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// 由 ldflags 注入
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// shortCommitLen 为展示用的提交哈希长度
+const shortCommitLen = 12
+
+// Info 为当前二进制的构建信息
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	Modified  bool   `json:"modified,omitempty"`
+	GoVersion string `json:"go_version"`
+	Platform  string `json:"platform"`
+}
+
+// Get 返回构建信息
+func Get() Info {
+	info := Info{
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	if bi, ok := debug.ReadBuildInfo(); ok {
+		for _, s := range bi.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				if info.Commit == "" {
+					info.Commit = s.Value
+				}
+			case "vcs.time":
+				if info.BuildDate == "" {
+					info.BuildDate = s.Value
+				}
+			case "vcs.modified":
+				info.Modified = s.Value == "true"
+			}
+		}
+	}
+	if len(info.Commit) > shortCommitLen {
+		info.Commit = info.Commit[:shortCommitLen]
+	}
+	return info
+}
+
+// String 返回单行描述，如 "v1.2.3 (3f9a0c1d2e4b, 2026-10-01T08:00:00Z)"
+func (i Info) String() string {
+	var parts []string
+	if i.Commit != "" {
+		commit := i.Commit
+		if i.Modified {
+			commit += "-dirty"
+		}
+		parts = append(parts, commit)
+	}
+	if i.BuildDate != "" {
+		parts = append(parts, i.BuildDate)
+	}
+	if len(parts) == 0 {
+		return i.Version
+	}
+	return i.Version + " (" + strings.Join(parts, ", ") + ")"
+}
@@ -0,0 +1,29 @@
+package version
+
+import "testing"
+
+func TestInfoString(t *testing.T) {
+	cases := []struct {
+		info Info
+		want string
+	}{
+		{Info{Version: "dev"}, "dev"},
+		{Info{Version: "v1.2.3", Commit: "3f9a0c1d2e4b", BuildDate: "2026-10-01T08:00:00Z"}, "v1.2.3 (3f9a0c1d2e4b, 2026-10-01T08:00:00Z)"},
+		{Info{Version: "dev", Commit: "3f9a0c1d2e4b", Modified: true}, "dev (3f9a0c1d2e4b-dirty)"},
+	}
+	for _, tc := range cases {
+		if got := tc.info.String(); got != tc.want {
+			t.Errorf("String() = %q, want %q", got, tc.want)
+		}
+	}
+}
+
+func TestGet_UsesInjectedValues(t *testing.T) {
+	defer func(v, c, d string) { Version, Commit, BuildDate = v, c, d }(Version, Commit, BuildDate)
+	Version, Commit, BuildDate = "v1.2.3", "0123456789abcdef0123", "2026-10-01T08:00:00Z"
+
+	info := Get()
+	if info.Version != "v1.2.3" || info.Commit != "0123456789ab" || info.BuildDate != "2026-10-01T08:00:00Z" {
+		t.Fatalf("Get() = %+v", info)
+	}
+}
@@ -0,0 +1,41 @@
+package warp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func FuzzParseResponseEvent(f *testing.F) {
+	toolCall := testEncodeMessage(
+		testEncodeStringField(1, "call_1"),
+		testEncodeBytesField(8, testEncodeMessage(testEncodeStringField(1, "ls -la"))),
+	)
+	f.Add([]byte{})
+	f.Add([]byte{0x0a, 0x00})
+	f.Add([]byte{0x1a, 0x02, 0x08, 0x01})
+	f.Add([]byte{0x22, 0x03, 'e', 'r', 'r'})
+	f.Add(testEncodeBytesField(2, testEncodeBytesField(1, toolCall)))
+	f.Add([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x01})
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		out, _ := parseResponseEvent(data)
+		if out == nil {
+			t.Fatalf("parseResponseEvent returned nil event")
+		}
+	})
+}
+
+func FuzzParseFallbackToolInput(f *testing.F) {
+	names := []string{"run_shell_command", "read_files", "apply_file_diffs", "grep", "unknown"}
+	f.Add(uint8(0), []byte{0x0a, 0x06, 'l', 's', ' ', '-', 'l', 'a', 0x10, 0x01})
+	f.Add(uint8(2), testEncodeMessage(testEncodeBytesField(3, testEncodeMessage(testEncodeStringField(1, "/tmp/a.txt")))))
+	f.Add(uint8(4), []byte{0x12})
+
+	f.Fuzz(func(t *testing.T, nameIdx uint8, payload []byte) {
+		name := names[int(nameIdx)%len(names)]
+		_, input := parseFallbackToolInput(name, payload)
+		if input != "" && !json.Valid([]byte(input)) {
+			t.Fatalf("tool %s produced invalid JSON input %q", name, input)
+		}
+	})
+}
@@ -471,6 +471,41 @@ func (s *session) currentRefreshToken() string {
 	return s.refreshToken
 }
 
+// SessionInfo 为 Warp 账号当前缓存的会话状态（不含 token 内容）
+type SessionInfo struct {
+	HasToken   bool      `json:"has_token"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+	TTLSeconds int64     `json:"ttl_seconds"`
+	LoggedIn   bool      `json:"logged_in"`
+	LastLogin  time.Time `json:"last_login,omitempty"`
+	LastUsed   time.Time `json:"last_used,omitempty"`
+}
+
+// LookupSession 返回指定账号缓存的会话状态，尚未创建会话时返回 false
+func LookupSession(accountID int64) (SessionInfo, bool) {
+	if accountID <= 0 {
+		return SessionInfo{}, false
+	}
+	val, ok := sessionCache.Load(fmt.Sprintf("warp:%d", accountID))
+	if !ok {
+		return SessionInfo{}, false
+	}
+	sess := val.(*session)
+	sess.mu.Lock()
+	defer sess.mu.Unlock()
+	info := SessionInfo{
+		HasToken:  sess.jwt != "",
+		ExpiresAt: sess.expiresAt,
+		LoggedIn:  sess.loggedIn,
+		LastLogin: sess.lastLogin,
+		LastUsed:  sess.lastUsed,
+	}
+	if ttl := time.Until(sess.expiresAt); info.HasToken && ttl > 0 {
+		info.TTLSeconds = int64(ttl.Seconds())
+	}
+	return info, true
+}
+
 // InvalidateSession 清除指定账号的 session 缓存，使下次请求重新创建会话。
 func InvalidateSession(accountID int64) {
 	if accountID <= 0 {
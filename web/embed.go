@@ -1,7 +1,9 @@
 package web
 
 import (
+	"bytes"
 	"embed"
+	"html"
 	"io/fs"
 	"net/http"
 )
@@ -16,3 +18,14 @@ func StaticHandler() http.Handler {
 	subFS, _ := fs.Sub(staticFS, "static")
 	return http.FileServer(http.FS(subFS))
 }
+
+// LoginHandler 返回登录页，basePath 写入页面的 base-path meta，登录请求据此拼接 /api/login
+func LoginHandler(basePath string) http.Handler {
+	page, _ := staticFS.ReadFile("static/login.html")
+	page = bytes.Replace(page, []byte(`<meta name="base-path" content="">`),
+		[]byte(`<meta name="base-path" content="`+html.EscapeString(basePath)+`">`), 1)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(page)
+	})
+}
@@ -1,22 +1,46 @@
 // Accounts management JavaScript
 
+// accounts 只包含当前页，筛选、排序与分页由服务端完成
 let accounts = [];
+let accountSummary = { total: 0, enabled: 0, abnormal: 0, usage_daily: 0, by_type: {} };
 let currentPlatform = '';
 let accountHealth = {};
 let pageSize = 20;
 let currentPage = 1;
+let totalFiltered = 0;
+let totalPages = 1;
+let searchQuery = '';
+let searchTimer = null;
 
-// Load accounts from API
+// Load the current page of accounts from API (sorted by ID desc)
 async function loadAccounts() {
   try {
-    const res = await fetch("/api/accounts");
+    const params = new URLSearchParams({
+      page: String(currentPage),
+      page_size: String(pageSize),
+      sort: "-id",
+    });
+    if (currentPlatform) params.set("type", currentPlatform);
+    if (searchQuery) params.set("q", searchQuery);
+    const res = await fetch(`/api/accounts?${params}`);
     if (res.status === 401) {
       window.location.href = "./login.html";
       return;
     }
-    accounts = await res.json();
-    sortAccounts();
+    const data = await res.json();
+    // 删除后当前页可能超出范围，回到最后一页
+    if (data.total > 0 && data.items.length === 0 && currentPage > data.total_pages) {
+      currentPage = data.total_pages;
+      return loadAccounts();
+    }
+    accounts = data.items || [];
+    accountSummary = data.summary || accountSummary;
+    totalFiltered = data.total;
+    totalPages = data.total_pages;
+    // 首次加载时由标签栏选定默认渠道，按该渠道重新请求
+    const requested = currentPlatform;
     renderPlatformTabs();
+    if (currentPlatform !== requested) return loadAccounts();
     renderAccounts();
     updateStats();
     autoRefreshWarpAccounts();
@@ -26,9 +50,21 @@ async function loadAccounts() {
   }
 }
 
-// Sort accounts (Default by ID desc)
-function sortAccounts() {
-  accounts.sort((a, b) => b.id - a.id);
+// Fetch every account (unpaged), for bulk operations across pages
+async function fetchAllAccounts() {
+  const res = await fetch("/api/accounts");
+  if (!res.ok) throw new Error(`HTTP ${res.status}`);
+  return res.json();
+}
+
+// Debounced server-side search
+function searchAccounts(value) {
+  clearTimeout(searchTimer);
+  searchTimer = setTimeout(() => {
+    searchQuery = value.trim();
+    currentPage = 1;
+    loadAccounts();
+  }, 300);
 }
 
 // Normalize account type
@@ -66,7 +102,7 @@ function renderPlatformTabs() {
   const container = document.getElementById("platformFilters");
   if (!container) return;
   const defaultTypes = ["orchids", "warp"];
-  const types = new Set([...defaultTypes, ...accounts.map(normalizeAccountType)]);
+  const types = new Set([...defaultTypes, ...Object.keys(accountSummary.by_type || {})]);
   const sorted = Array.from(types).sort();
   const tabs = [...sorted];
 
@@ -171,9 +207,10 @@ async function autoRefreshWarpAccounts() {
 
 // Delete all accounts
 async function deleteAllAccounts() {
-  if (!accounts.length) return;
-  if (!confirm(`确定要删除全部 ${accounts.length} 个账号吗？此操作不可恢复。`)) return;
-  for (const acc of accounts) {
+  const all = await fetchAllAccounts();
+  if (!all.length) return;
+  if (!confirm(`确定要删除全部 ${all.length} 个账号吗？此操作不可恢复。`)) return;
+  for (const acc of all) {
     await fetch(`/api/accounts/${acc.id}`, { method: "DELETE" });
   }
   await loadAccounts();
@@ -182,7 +219,8 @@ async function deleteAllAccounts() {
 
 // Clear abnormal accounts
 async function clearAbnormalAccounts() {
-  const abnormal = accounts.filter(a => !a.enabled || a.status_code || (accountHealth[a.id] && !accountHealth[a.id].ok));
+  const all = await fetchAllAccounts();
+  const abnormal = all.filter(a => !a.enabled || a.status_code || (accountHealth[a.id] && !accountHealth[a.id].ok));
   if (abnormal.length === 0) {
     showToast("没有异常账号", "info");
     return;
@@ -212,20 +250,8 @@ async function batchDeleteAccounts() {
 // Render accounts table
 function renderAccounts() {
   const container = document.getElementById("accountsList");
-  const filtered = accounts.filter(acc => {
-    if (!currentPlatform) return true;
-    const key = currentPlatform.toLowerCase();
-    return normalizeAccountType(acc).includes(key);
-  });
-
-  const total = filtered.length;
-  const totalPages = Math.ceil(total / pageSize) || 1;
-  if (currentPage > totalPages) currentPage = totalPages;
-  if (currentPage < 1) currentPage = 1;
-
-  const start = (currentPage - 1) * pageSize;
-  const end = start + pageSize;
-  const pageItems = filtered.slice(start, end);
+  const total = totalFiltered;
+  const pageItems = accounts;
 
   if (pageItems.length === 0) {
     container.innerHTML = "";
@@ -242,7 +268,7 @@ function renderAccounts() {
     icon.style.marginBottom = "16px";
     icon.textContent = "📂";
     const text = document.createElement("p");
-    text.textContent = `暂无 ${currentPlatform ? currentPlatform : ''} 账号数据`;
+    text.textContent = searchQuery ? `没有匹配“${searchQuery}”的账号` : `暂无 ${currentPlatform ? currentPlatform : ''} 账号数据`;
     empty.appendChild(icon);
     empty.appendChild(text);
     container.appendChild(empty);
@@ -489,11 +515,9 @@ function renderPagination(current, total) {
 }
 
 function goToPage(page) {
-  if (page < 1) return;
-  // We can't check 'total' easily here without storing it or querying DOM
-  // But renderAccounts will clamp it.
+  if (page < 1 || page > totalPages) return;
   currentPage = page;
-  renderAccounts();
+  loadAccounts();
 }
 
 // Filter by platform
@@ -507,21 +531,21 @@ function filterByPlatform(platform) {
   if (subtitle) {
     subtitle.textContent = currentPlatform ? `管理您的 ${currentPlatform} API 凭证` : "管理您的所有 API 凭证";
   }
-  renderAccounts();
+  loadAccounts();
 }
 
 // Update page size
 function updatePageSize(size) {
   pageSize = parseInt(size);
   currentPage = 1;
-  renderAccounts();
+  loadAccounts();
 }
 
 // Update statistics
 function updateStats() {
-  const total = accounts.length;
-  const enabled = accounts.filter((a) => a.enabled).length;
-  const abnormal = accounts.filter((a) => !a.enabled || a.status_code || (accountHealth[a.id] && !accountHealth[a.id].ok)).length;
+  const total = accountSummary.total;
+  const enabled = accountSummary.enabled;
+  const abnormal = accountSummary.abnormal;
 
   document.getElementById("totalAccounts").textContent = total;
   document.getElementById("enabledAccounts").textContent = enabled;
@@ -530,7 +554,7 @@ function updateStats() {
   // Attempt to update selected if element exists (it should)
   updateSelectedCount();
 
-  const totalUsage = accounts.reduce((sum, acc) => sum + (acc.usage_daily || 0), 0);
+  const totalUsage = accountSummary.usage_daily || 0;
 
   // Update sidebar footer
   const footerTotal = document.getElementById("footerTotal");
@@ -580,6 +604,7 @@ function openModal(account = null) {
     document.getElementById("clientCookie").value = getAccountToken(account);
     document.getElementById("agentMode").value = account.agent_mode || 'claude-opus-4.5';
     document.getElementById("weight").value = account.weight || 1;
+    document.getElementById("maxConcurrent").value = account.max_concurrent || 0;
     document.getElementById("enabled").checked = account.enabled;
   } else {
     title.textContent = "添加账号";
@@ -587,6 +612,7 @@ function openModal(account = null) {
     document.getElementById("accountId").value = "";
     document.getElementById("agentMode").value = "claude-opus-4.5";
     document.getElementById("weight").value = "1";
+    document.getElementById("maxConcurrent").value = "0";
     document.getElementById("accountType").value = "orchids";
     document.getElementById("enabled").checked = true;
   }
@@ -612,6 +638,7 @@ async function saveAccount(e) {
     account_type: type,
     agent_mode: document.getElementById("agentMode").value,
     weight: parseInt(document.getElementById("weight").value) || 1,
+    max_concurrent: Math.max(parseInt(document.getElementById("maxConcurrent").value) || 0, 0),
     enabled: document.getElementById("enabled").checked,
   };
   if (type === 'warp') {
@@ -734,7 +761,7 @@ function formatTime(iso) {
 
 // Export accounts
 function exportAccounts() {
-  window.location.href = "/api/export";
+  window.location.href = withBasePath("/api/export");
 }
 
 // Import accounts
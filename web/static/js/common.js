@@ -1,5 +1,33 @@
 // Common JavaScript functions
 
+// Base path of the deployment behind a path-prefixing reverse proxy (empty when served at the root)
+const BASE_PATH = (document.querySelector('meta[name="base-path"]') || {}).content || "";
+
+// Resolve a same-origin absolute path ("/api/...") under BASE_PATH
+function withBasePath(path) {
+  return typeof path === "string" && path.startsWith("/") && !path.startsWith("//") ? BASE_PATH + path : path;
+}
+
+// Prefix absolute paths with BASE_PATH and attach the CSRF token rendered into the page
+// to every state-changing same-origin request
+(function () {
+  const meta = document.querySelector('meta[name="csrf-token"]');
+  const csrfToken = meta ? meta.content : "";
+  if (!csrfToken && !BASE_PATH) return;
+  const nativeFetch = window.fetch.bind(window);
+  window.fetch = function (input, init = {}) {
+    input = withBasePath(input);
+    const method = (init.method || (input instanceof Request ? input.method : "GET")).toUpperCase();
+    const url = new URL(input instanceof Request ? input.url : input, window.location.href);
+    if (csrfToken && !["GET", "HEAD", "OPTIONS"].includes(method) && url.origin === window.location.origin) {
+      const headers = new Headers(init.headers || (input instanceof Request ? input.headers : undefined));
+      headers.set("X-CSRF-Token", csrfToken);
+      init = { ...init, headers };
+    }
+    return nativeFetch(input, init);
+  };
+})();
+
 // Show toast notification
 function showToast(msg, type = 'success') {
   const container = document.getElementById("toastContainer") || document.body;
@@ -68,11 +96,12 @@ async function updateSidebarUsage() {
   if (!footerUsage) return;
 
   try {
-    const res = await fetch("/api/accounts");
+    // 只需要汇总统计，请求一条记录即可
+    const res = await fetch("/api/accounts?page_size=1");
     if (!res.ok) return; // Silent fail if unauthorized or error
-    const accounts = await res.json();
+    const data = await res.json();
 
-    const totalUsage = accounts.reduce((sum, acc) => sum + (acc.usage_daily || 0), 0);
+    const totalUsage = (data.summary && data.summary.usage_daily) || 0;
     footerUsage.textContent = `${Math.floor(totalUsage)}`;
   } catch (e) {
     console.error("Failed to update sidebar usage", e);